@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// accountRoutes is a loaded -accounts file: the named RescueTime accounts and which
+// application goes to which. Summaries of any other application go to the default account.
+type accountRoutes struct {
+	names   []string          // account names, sorted
+	byClass map[string]string // lowercased WmClass (or -merge-apps target) to account name
+}
+
+// loadAccounts reads an -accounts file mapping an account name to the WmClasses submitted to
+// it, e.g. {"work": ["code", "slack"]}. Classes match case-insensitively, like the ignore
+// list and -merge-apps, and each can go to one account only. An account's keys come from
+// .env with its name as a suffix; see accountEnvKey.
+func loadAccounts(path string) (accountRoutes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return accountRoutes{}, fmt.Errorf("failed to read accounts file: %v", err)
+	}
+
+	var accounts map[string][]string
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return accountRoutes{}, fmt.Errorf("failed to parse accounts file %s%s: %v\n\nExpected a JSON object of account name to WmClass list, e.g. {\"work\": [\"code\", \"slack\"]}", path, jsonErrorPosition(data, err), err)
+	}
+
+	routes := accountRoutes{byClass: make(map[string]string)}
+	for name := range accounts {
+		routes.names = append(routes.names, name)
+	}
+	// Sorted so a conflict is reported the same way every run
+	sort.Strings(routes.names)
+
+	suffixes := make(map[string]string)
+	for _, name := range routes.names {
+		suffix := accountEnvSuffix(name)
+		if suffix == "" {
+			return accountRoutes{}, fmt.Errorf("accounts file %s has an account name without letters or digits: %q", path, name)
+		}
+		if existing, ok := suffixes[suffix]; ok {
+			return accountRoutes{}, fmt.Errorf("accounts file %s: accounts %q and %q would both read %s keys", path, existing, name, accountEnvKey("RESCUE_TIME_API_KEY", name))
+		}
+		suffixes[suffix] = name
+
+		for _, class := range accounts[name] {
+			key := strings.ToLower(strings.TrimSpace(class))
+			if key == "" {
+				continue
+			}
+			if existing, ok := routes.byClass[key]; ok && existing != name {
+				return accountRoutes{}, fmt.Errorf("accounts file %s routes %q to both %q and %q; each WmClass can only go to one account", path, class, existing, name)
+			}
+			routes.byClass[key] = name
+		}
+	}
+	return routes, nil
+}
+
+// account returns the account appClass is routed to, or "" for the default account
+func (r accountRoutes) account(appClass string) string {
+	return r.byClass[strings.ToLower(appClass)]
+}
+
+// accountEnvSuffix returns the .env key suffix for an account name: uppercased, with
+// anything but letters and digits as underscores ("work-laptop" reads *_WORK_LAPTOP)
+func accountEnvSuffix(name string) string {
+	suffix := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(name))
+	if strings.Trim(suffix, "_") == "" {
+		return ""
+	}
+	return suffix
+}
+
+// accountEnvKey returns the .env key holding the account's value for key, e.g.
+// RESCUE_TIME_API_KEY_WORK
+func accountEnvKey(key, account string) string {
+	return key + "_" + accountEnvSuffix(account)
+}
+
+// accountEnvPrefixes are the .env keys an -accounts account has its own copy of
+var accountEnvPrefixes = []string{"RESCUE_TIME_API_KEY_", "RESCUE_TIME_ACCOUNT_KEY_", "RESCUE_TIME_DATA_KEY_"}
+
+// isAccountEnvKey reports whether key is an -accounts account's key
+func isAccountEnvKey(key string) bool {
+	for _, prefix := range accountEnvPrefixes {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAccountClient returns a RescueTime client for a named account, configured like the
+// default one but with only the account's own keys. It never falls back to the default
+// account's keys, which would send the account's time to the wrong place.
+func newAccountClient(name string, getenv func(string) string) (*rescuetime.Client, error) {
+	client := newRescueTimeClient("")
+	client.APIKey = getenv(accountEnvKey("RESCUE_TIME_API_KEY", name))
+	client.AccountKey = getenv(accountEnvKey("RESCUE_TIME_ACCOUNT_KEY", name))
+	client.DataKey = getenv(accountEnvKey("RESCUE_TIME_DATA_KEY", name))
+	client.Credentials = nil // -refresh-keys logs in as the default account
+	if client.APIKey == "" && client.AccountKey == "" && client.DataKey == "" {
+		return nil, fmt.Errorf("account %q has no keys: set %s (or %s) in .env", name, accountEnvKey("RESCUE_TIME_API_KEY", name), accountEnvKey("RESCUE_TIME_ACCOUNT_KEY", name))
+	}
+	return client, nil
+}
+
+// accountQueuePath returns where a named account's unsent summaries are queued: next to the
+// default account's queue at defaultPath, e.g. .rescuetime-queue-work.json
+func accountQueuePath(defaultPath, name string) string {
+	return strings.TrimSuffix(defaultPath, ".json") + "-" + strings.ToLower(accountEnvSuffix(name)) + ".json"
+}
+
+// filterSummaries returns the summaries keep accepts
+func filterSummaries(summaries map[string]ActivitySummary, keep func(ActivitySummary) bool) map[string]ActivitySummary {
+	kept := make(map[string]ActivitySummary, len(summaries))
+	for key, summary := range summaries {
+		if keep(summary) {
+			kept[key] = summary
+		}
+	}
+	return kept
+}
+
+// rescueTimeSubmitters returns a submitter per RescueTime account: the default one for
+// client, getting every application routes doesn't send elsewhere, then one per named
+// account with its own client, queue and -submission-log tag
+func rescueTimeSubmitters(client *rescuetime.Client, queue *submissionQueue, routes accountRoutes, log *submissionLog, getenv func(string) string) ([]*rescueTimeSubmitter, error) {
+	defaultAccount := &rescueTimeSubmitter{client: client, queue: queue, log: log}
+	submitters := []*rescueTimeSubmitter{defaultAccount}
+	if len(routes.names) == 0 {
+		return submitters, nil
+	}
+	defaultAccount.accept = func(summary ActivitySummary) bool { return routes.account(summary.AppClass) == "" }
+
+	for _, name := range routes.names {
+		name := name
+		accountClient, err := newAccountClient(name, getenv)
+		if err != nil {
+			return nil, err
+		}
+		accountQueue := newSubmissionQueue(accountQueuePath(queue.path, name))
+		accountClient.Queue = accountQueue
+		submitters = append(submitters, &rescueTimeSubmitter{
+			client:  accountClient,
+			queue:   accountQueue,
+			log:     log,
+			account: name,
+			accept:  func(summary ActivitySummary) bool { return routes.account(summary.AppClass) == name },
+		})
+	}
+	return submitters, nil
+}
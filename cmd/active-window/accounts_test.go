@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadAccounts tests parsing -accounts files and rejecting ambiguous ones
+func TestLoadAccounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `{"work": ["Code", "slack"], "side-project": ["gimp"]}`, ""},
+		{"class in two accounts", `{"work": ["code"], "personal": ["CODE"]}`, "each WmClass can only go to one account"},
+		{"name without letters", `{"--": ["code"]}`, "without letters or digits"},
+		{"names with the same keys", `{"work-laptop": ["code"], "work_laptop": ["gimp"]}`, "RESCUE_TIME_API_KEY_WORK_LAPTOP"},
+		{"not an object", `["code"]`, "Expected a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "accounts.json")
+			os.WriteFile(path, []byte(tt.content), 0644)
+
+			routes, err := loadAccounts(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadAccounts failed: %v", err)
+			}
+			if !reflect.DeepEqual(routes.names, []string{"side-project", "work"}) {
+				t.Errorf("Expected sorted account names, got %v", routes.names)
+			}
+			for class, want := range map[string]string{"code": "work", "SLACK": "work", "gimp": "side-project", "firefox": ""} {
+				if got := routes.account(class); got != want {
+					t.Errorf("account(%q) = %q, expected %q", class, got, want)
+				}
+			}
+			if got := accountEnvKey("RESCUE_TIME_API_KEY", "side-project"); got != "RESCUE_TIME_API_KEY_SIDE_PROJECT" {
+				t.Errorf("Expected side-project's key in RESCUE_TIME_API_KEY_SIDE_PROJECT, got %s", got)
+			}
+		})
+	}
+}
+
+// TestAccountRouting tests that a mixed batch is partitioned between the default account and
+// a work account, each queueing only its own summaries and logging under its own name
+func TestAccountRouting(t *testing.T) {
+	t.Setenv("RESCUE_TIME_ACCOUNT_KEY", "")
+	t.Setenv("RESCUE_TIME_DATA_KEY", "")
+	dir := t.TempDir()
+	routes := accountRoutes{names: []string{"work"}, byClass: map[string]string{"code": "work", "slack": "work"}}
+	env := map[string]string{"RESCUE_TIME_API_KEY_WORK": "work-key"}
+	log := &submissionLog{path: filepath.Join(dir, "submissions.jsonl")}
+
+	defaultQueue := newSubmissionQueue(filepath.Join(dir, "queue.json"))
+	submitters, err := rescueTimeSubmitters(newRescueTimeClient("personal-key"), defaultQueue, routes, log, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("rescueTimeSubmitters failed: %v", err)
+	}
+	if len(submitters) != 2 || submitters[1].client.APIKey != "work-key" || submitters[1].queue.path != filepath.Join(dir, "queue-work.json") {
+		t.Fatalf("Expected the default and a work submitter with its own key and queue, got %d", len(submitters))
+	}
+
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	summary := func(app string) ActivitySummary {
+		return ActivitySummary{AppClass: app, TotalDuration: 10 * time.Minute, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)}
+	}
+	batch := map[string]ActivitySummary{"Code": summary("Code"), "slack": summary("slack"), "firefox": summary("firefox"), "gimp": summary("gimp")}
+
+	// Out of time before anything is sent, so each account queues exactly what it was given
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, submitter := range submitters {
+		submitter.Submit(ctx, Submission{Completed: batch})
+	}
+
+	queued := func(queue *submissionQueue) []string {
+		summaries, err := queue.Take()
+		if err != nil {
+			t.Fatalf("Take failed: %v", err)
+		}
+		var apps []string
+		for _, summary := range summaries {
+			apps = append(apps, summary.AppClass)
+		}
+		sort.Strings(apps)
+		return apps
+	}
+	if got := queued(defaultQueue); !reflect.DeepEqual(got, []string{"firefox", "gimp"}) {
+		t.Errorf("Expected the default account to get firefox and gimp, got %v", got)
+	}
+	if got := queued(submitters[1].queue); !reflect.DeepEqual(got, []string{"Code", "slack"}) {
+		t.Errorf("Expected the work account to get Code and slack, got %v", got)
+	}
+
+	file, err := os.Open(log.path)
+	if err != nil {
+		t.Fatalf("Expected a submission log: %v", err)
+	}
+	defer file.Close()
+	accounts := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry submissionLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Bad log line %s: %v", scanner.Text(), err)
+		}
+		accounts[entry.AppClass] = entry.Account
+	}
+	if want := map[string]string{"Code": "work", "slack": "work", "firefox": "", "gimp": ""}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("Expected log entries tagged %v, got %v", want, accounts)
+	}
+}
+
+// TestAccountWithoutKeys tests that an account without keys of its own is an error rather
+// than falling back to the default account's keys
+func TestAccountWithoutKeys(t *testing.T) {
+	t.Setenv("RESCUE_TIME_API_KEY", "personal-key")
+	routes := accountRoutes{names: []string{"work"}, byClass: map[string]string{"code": "work"}}
+	_, err := rescueTimeSubmitters(newRescueTimeClient(""), newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json")), routes, nil, os.Getenv)
+	if err == nil || !strings.Contains(err.Error(), "RESCUE_TIME_API_KEY_WORK") {
+		t.Errorf("Expected a missing RESCUE_TIME_API_KEY_WORK error, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// anonymizeSecretKey is the .env key holding the -anonymize secret
+const anonymizeSecretKey = "ANONYMIZE_SECRET"
+
+// Anonymization settings from -anonymize, -rescuetime-anonymize and -anonymize-titles
+var (
+	anonymizeRescueTime bool
+	anonymizeTitles     = anonymize.TitlesDrop
+	anonymizeSecret     string
+)
+
+// loadAnonymizeSecret returns ANONYMIZE_SECRET from the environment or envPath, generating
+// one and saving it to envPath the first time. It must stay the same across runs, or the
+// same application would get a new pseudonym remotely.
+func loadAnonymizeSecret(envPath string) (string, error) {
+	if os.Getenv(anonymizeSecretKey) == "" {
+		loadEnvFile(envPath) // Optional - the secret may be in the environment
+	}
+	if secret := os.Getenv(anonymizeSecretKey); secret != "" {
+		return secret, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate %s: %v", anonymizeSecretKey, err)
+	}
+	secret := hex.EncodeToString(buf)
+	if err := setEnvValues(envPath, map[string]string{anonymizeSecretKey: secret}); err != nil {
+		return "", fmt.Errorf("failed to save %s: %v", anonymizeSecretKey, err)
+	}
+	os.Setenv(anonymizeSecretKey, secret)
+	logging.Info("Generated %s and saved it to %s; keep it to look up pseudonyms with -deanonymize", anonymizeSecretKey, envPath)
+	return secret, nil
+}
+
+// deanonymizeCandidates returns the application classes this machine has recorded in
+// cleartext: the app statistics and, if set, the -submission-log history
+func deanonymizeCandidates(appStatsPath, submissionLogPath string) []string {
+	seen := make(map[string]bool)
+	if data, err := os.ReadFile(appStatsPath); err == nil {
+		var saved map[string]json.RawMessage
+		if err := json.Unmarshal(data, &saved); err == nil {
+			for class := range saved {
+				seen[class] = true
+			}
+		}
+	}
+	if submissionLogPath != "" {
+		if file, err := os.Open(submissionLogPath); err == nil {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var entry submissionLogEntry
+				if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.AppClass != "" {
+					seen[entry.AppClass] = true
+				}
+			}
+			file.Close()
+		}
+	}
+
+	candidates := make([]string, 0, len(seen))
+	for class := range seen {
+		candidates = append(candidates, class)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// runDeanonymize prints the application class behind an -anonymize pseudonym. Returns false
+// if no recorded class hashes to it.
+func runDeanonymize(hash string) bool {
+	if os.Getenv(anonymizeSecretKey) == "" {
+		loadEnvFile(".env") // Optional - the secret may be in the environment
+	}
+	secret := os.Getenv(anonymizeSecretKey)
+	if secret == "" {
+		logging.Error("%s is not set, so there is nothing to look pseudonyms up with", anonymizeSecretKey)
+		return false
+	}
+	candidates := deanonymizeCandidates(defaultAppStatsPath, submissionLogPath)
+	if class, ok := anonymize.Reveal(hash, secret, candidates); ok {
+		fmt.Println(class)
+		return true
+	}
+	logging.Error("No recorded application hashes to %s (checked %d from %s and -submission-log)", hash, len(candidates), defaultAppStatsPath)
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestLoadAnonymizeSecret tests that a missing secret is generated and saved to .env, and
+// that a later run reads the same one back, so pseudonyms stay stable across runs
+func TestLoadAnonymizeSecret(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, ".env")
+	os.WriteFile(envPath, []byte("RESCUE_TIME_API_KEY=key\n"), 0600)
+	t.Setenv(anonymizeSecretKey, "")
+
+	secret, err := loadAnonymizeSecret(envPath)
+	if err != nil || len(secret) != 64 {
+		t.Fatalf("Expected a generated secret, got %q, %v", secret, err)
+	}
+	data, _ := os.ReadFile(envPath)
+	if !strings.Contains(string(data), "RESCUE_TIME_API_KEY=key\n") || !strings.Contains(string(data), anonymizeSecretKey+"="+secret) {
+		t.Errorf("Expected the secret saved alongside the existing keys, got %q", data)
+	}
+
+	os.Setenv(anonymizeSecretKey, "") // A new run starts without it in the environment
+	again, err := loadAnonymizeSecret(envPath)
+	if err != nil || again != secret {
+		t.Errorf("Expected the saved secret back, got %q, %v", again, err)
+	}
+	if anonymize.App("code", again) != anonymize.App("code", secret) {
+		t.Error("Expected the same pseudonym across runs")
+	}
+}
+
+// TestDeanonymizeCandidates tests that classes come from the app statistics and the
+// submission log, so a pseudonym can be looked up
+func TestDeanonymizeCandidates(t *testing.T) {
+	dir := t.TempDir()
+	statsPath := filepath.Join(dir, "app-stats.json")
+	os.WriteFile(statsPath, []byte(`{"code":{"count":1},"firefox":{"count":2}}`), 0600)
+	logPath := filepath.Join(dir, "submissions.jsonl")
+	var result rescuetime.SubmissionResult
+	result.Add(rescuetime.ActivitySummary{AppClass: "slack", TotalDuration: time.Minute}, rescuetime.OutcomeSkippedShort, "")
+	(&submissionLog{path: logPath}).Append(time.Now(), "", result)
+
+	candidates := deanonymizeCandidates(statsPath, logPath)
+	if strings.Join(candidates, ",") != "code,firefox,slack" {
+		t.Fatalf("Expected code, firefox and slack, got %v", candidates)
+	}
+	if class, ok := anonymize.Reveal(anonymize.App("slack", "secret"), "secret", candidates); !ok || class != "slack" {
+		t.Errorf("Expected slack revealed, got %q, %v", class, ok)
+	}
+	if len(deanonymizeCandidates(filepath.Join(dir, "missing.json"), "")) != 0 {
+		t.Error("Expected no candidates without local records")
+	}
+}
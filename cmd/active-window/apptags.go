@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// appTag is how the text summary shows one application (or -group-by category)
+type appTag struct {
+	Color string `json:"color"` // a name in tagColors; "" hashes one from the name
+	Emoji string `json:"emoji"` // shown before the name; "" for none
+}
+
+// appTags is a loaded -app-tags file, keyed by lowercased summary key
+type appTags map[string]appTag
+
+// summaryTags is -app-tags; nil tags nothing, so every application gets a hashed color
+var summaryTags appTags
+
+// tagColors are the color names an -app-tags entry may use
+var tagColors = map[string]color.Attribute{
+	"red":        color.FgRed,
+	"green":      color.FgGreen,
+	"yellow":     color.FgYellow,
+	"blue":       color.FgBlue,
+	"magenta":    color.FgMagenta,
+	"cyan":       color.FgCyan,
+	"white":      color.FgWhite,
+	"hi-red":     color.FgHiRed,
+	"hi-green":   color.FgHiGreen,
+	"hi-yellow":  color.FgHiYellow,
+	"hi-blue":    color.FgHiBlue,
+	"hi-magenta": color.FgHiMagenta,
+	"hi-cyan":    color.FgHiCyan,
+	"hi-white":   color.FgHiWhite,
+}
+
+// hashedColors are the colors untagged applications are spread over. Yellow and cyan are
+// left out, since the summary uses them for warnings and percentages, and so are the
+// grays it uses for details.
+var hashedColors = []color.Attribute{
+	color.FgGreen, color.FgBlue, color.FgMagenta, color.FgRed,
+	color.FgHiGreen, color.FgHiBlue, color.FgHiMagenta, color.FgHiRed,
+}
+
+// loadAppTags reads an -app-tags file mapping an application's WmClass (or a -group-by
+// category, or a -merge-apps target) to its color and emoji, e.g.
+// {"code": {"color": "blue", "emoji": "💻"}}. Names match case-insensitively.
+func loadAppTags(path string) (appTags, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app tags file: %v", err)
+	}
+
+	var entries map[string]appTag
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse app tags file %s%s: %v\n\nExpected a JSON object of application to color and emoji, e.g. {\"code\": {\"color\": \"blue\", \"emoji\": \"💻\"}}", path, jsonErrorPosition(data, err), err)
+	}
+
+	tags := make(appTags, len(entries))
+	for app, tag := range entries {
+		tag.Color = strings.ToLower(strings.TrimSpace(tag.Color))
+		if _, ok := tagColors[tag.Color]; tag.Color != "" && !ok {
+			return nil, fmt.Errorf("app tags file %s gives %q the unknown color %q (valid: %s)", path, app, tag.Color, strings.Join(tagColorNames(), ", "))
+		}
+		tags[strings.ToLower(strings.TrimSpace(app))] = tag
+	}
+	return tags, nil
+}
+
+// tagColorNames returns the valid -app-tags color names, sorted
+func tagColorNames() []string {
+	names := make([]string, 0, len(tagColors))
+	for name := range tagColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// style returns the color and emoji app is shown with: its tag's, else a color hashed from
+// its name, so an application keeps its color from run to run
+func (t appTags) style(app string) (color.Attribute, string) {
+	tag := t[strings.ToLower(app)]
+	if attr, ok := tagColors[tag.Color]; ok {
+		return attr, tag.Emoji
+	}
+	return hashedColor(app), tag.Emoji
+}
+
+// hashedColor picks one of hashedColors from app's lowercased name
+func hashedColor(app string) color.Attribute {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(app)))
+	return hashedColors[h.Sum32()%uint32(len(hashedColors))]
+}
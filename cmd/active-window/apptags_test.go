@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestHashedColor tests that untagged applications get a fixed color from their name,
+// whatever its case, so they look the same every run
+func TestHashedColor(t *testing.T) {
+	for app, want := range map[string]color.Attribute{
+		"firefox": color.FgMagenta,
+		"code":    color.FgHiGreen,
+		"Code":    color.FgHiGreen,
+		"slack":   color.FgHiRed,
+		"gimp":    color.FgGreen,
+	} {
+		if got := hashedColor(app); got != want {
+			t.Errorf("hashedColor(%q) = %v, expected %v", app, got, want)
+		}
+	}
+
+	var untagged appTags
+	if attr, emoji := untagged.style("firefox"); attr != color.FgMagenta || emoji != "" {
+		t.Errorf("Expected firefox's hashed color without -app-tags, got %v %q", attr, emoji)
+	}
+}
+
+// TestLoadAppTags tests that tagged applications get their color and emoji, that an emoji
+// alone keeps the hashed color, and that unknown colors are rejected
+func TestLoadAppTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+	os.WriteFile(path, []byte(`{"Code": {"color": "Blue", "emoji": "💻"}, "slack": {"emoji": "💬"}}`), 0644)
+
+	tags, err := loadAppTags(path)
+	if err != nil {
+		t.Fatalf("loadAppTags failed: %v", err)
+	}
+	if attr, emoji := tags.style("code"); attr != color.FgBlue || emoji != "💻" {
+		t.Errorf("Expected code in blue with 💻, got %v %q", attr, emoji)
+	}
+	if attr, emoji := tags.style("slack"); attr != hashedColor("slack") || emoji != "💬" {
+		t.Errorf("Expected slack in its hashed color with 💬, got %v %q", attr, emoji)
+	}
+
+	os.WriteFile(path, []byte(`{"code": {"color": "purple"}}`), 0644)
+	if _, err := loadAppTags(path); err == nil || !strings.Contains(err.Error(), "hi-magenta") {
+		t.Errorf("Expected an unknown color error listing the valid ones, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+	"github.com/fatih/color"
+)
+
+// benchmarkMockLatency is how long the -benchmark-dry stand-in takes to answer
+const benchmarkMockLatency = 50 * time.Millisecond
+
+// runBenchmark submits a one-minute synthetic summary n times to each RescueTime endpoint
+// client has keys for and prints the latency and error rate of each. With dry the
+// submissions go to a local mock instead, with placeholder keys where none are set, so both
+// endpoints run. It returns false if no endpoint could be benchmarked or one failed every time.
+func runBenchmark(ctx context.Context, client *rescuetime.Client, n int, dry bool, out io.Writer) bool {
+	if dry {
+		server := rescuetime.NewBenchmarkServer(benchmarkMockLatency)
+		defer server.Close()
+		client.UseServer(server.URL)
+		if client.APIKey == "" {
+			client.APIKey = "benchmark"
+		}
+		if client.DataKey == "" && client.AccountKey == "" {
+			client.DataKey = "benchmark"
+		}
+		logging.Info("DRY-RUN: benchmarking against a local mock that answers after %v", benchmarkMockLatency)
+	} else {
+		logging.Warning("This submits %d one-minute %q activities per endpoint to your RescueTime account (-benchmark-dry uses a local mock instead)", n, rescuetime.BenchmarkApp)
+	}
+
+	results := client.Benchmark(ctx, rescuetime.BenchmarkSummary(time.Now()), n, func(endpoint string, i int, took time.Duration, err error) {
+		if err != nil {
+			logging.Verbose("%s %d/%d: failed after %v: %v", endpoint, i, n, took.Round(time.Millisecond), err)
+			return
+		}
+		logging.Verbose("%s %d/%d: %v", endpoint, i, n, took.Round(time.Millisecond))
+	})
+	if len(results) == 0 {
+		logging.Error("No RescueTime keys to benchmark: set RESCUE_TIME_API_KEY (legacy) or RESCUE_TIME_DATA_KEY/RESCUE_TIME_ACCOUNT_KEY (native)")
+		return false
+	}
+
+	color.New(color.FgCyan, color.Bold).Fprintln(out, "\n=== RescueTime API latency ===")
+	ok := true
+	for _, result := range results {
+		fmt.Fprintln(out, result)
+		if result.LastError != nil {
+			fmt.Fprintf(out, "  last error: %v\n", result.LastError)
+		}
+		if result.Requests > 0 && result.Errors == result.Requests {
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestRunBenchmarkDry tests that -benchmark-dry runs both endpoints against the mock even
+// without keys and prints a line for each
+func TestRunBenchmarkDry(t *testing.T) {
+	t.Setenv("RESCUE_TIME_API_KEY", "")
+	t.Setenv("RESCUE_TIME_ACCOUNT_KEY", "")
+	t.Setenv("RESCUE_TIME_DATA_KEY", "")
+
+	var out bytes.Buffer
+	if !runBenchmark(context.Background(), rescuetime.NewClient("", "", ""), 2, true, &out) {
+		t.Fatalf("Expected the dry benchmark to succeed, got:\n%s", out.String())
+	}
+	for _, want := range []string{"legacy: 2 submissions", "native: 2 submissions", "0% errors"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+
+	if runBenchmark(context.Background(), rescuetime.NewClient("", "", ""), 2, false, &out) {
+		t.Error("Expected a benchmark without keys to fail")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// Strategies for windows that report no WmClass (some dialogs and XWayland apps), selectable via -blank-class
+const (
+	blankClassTitle   = "title"   // the application name at the end of the title, else unknownAppClass
+	blankClassUnknown = "unknown" // always unknownAppClass
+)
+
+// unknownAppClass is the application windows without a WmClass are tracked under
+// when no better name is available
+const unknownAppClass = "Unknown"
+
+// maxTitleAppNameLength is the longest trailing title segment taken as an application name;
+// anything longer is more likely a document or page title than an app
+const maxTitleAppNameLength = 40
+
+// titleAppSeparators split "Document - Application" style titles, tried from the end
+var titleAppSeparators = []string{" - ", " — ", " – ", " | ", " · "}
+
+// blankClassMode is -blank-class
+var blankClassMode = blankClassTitle
+
+// validBlankClassMode reports whether mode is a -blank-class strategy
+func validBlankClassMode(mode string) bool {
+	return mode == blankClassTitle || mode == blankClassUnknown
+}
+
+// resolveAmbiguousClass returns the class to track window under. A window with a WmClass
+// keeps it; one without is named by blankClassMode instead of becoming a session keyed
+// on "", e.g. "Export — GIMP" gives "GIMP" with the title strategy.
+func resolveAmbiguousClass(window *common.MutterWindow) string {
+	if strings.TrimSpace(window.WmClass) != "" {
+		return window.WmClass
+	}
+	if blankClassMode == blankClassTitle {
+		if name := titleAppName(window.Title); name != "" {
+			return name
+		}
+	}
+	return unknownAppClass
+}
+
+// titleAppName returns the application name trailing a title after its last separator,
+// or "" when the title has none or the segment doesn't look like a name
+func titleAppName(title string) string {
+	cut := -1
+	width := 0
+	for _, sep := range titleAppSeparators {
+		if i := strings.LastIndex(title, sep); i > cut {
+			cut, width = i, len(sep)
+		}
+	}
+	if cut < 0 {
+		return ""
+	}
+
+	name := strings.TrimSpace(title[cut+width:])
+	if name == "" || len(name) > maxTitleAppNameLength || !strings.ContainsFunc(name, unicode.IsLetter) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestResolveAmbiguousClass tests both -blank-class strategies on windows with and without a WmClass
+func TestResolveAmbiguousClass(t *testing.T) {
+	defer func(mode string) { blankClassMode = mode }(blankClassMode)
+
+	tests := []struct {
+		mode  string
+		class string
+		title string
+		want  string
+	}{
+		{blankClassTitle, "firefox", "GitHub - Mozilla Firefox", "firefox"},
+		{blankClassTitle, "", "Untitled 1 - LibreOffice Calc", "LibreOffice Calc"},
+		{blankClassTitle, "", "Export Image as PNG — GIMP", "GIMP"},
+		{blankClassTitle, "", "notes.txt – Kate", "Kate"},
+		{blankClassTitle, "", "Inbox | Proton Mail", "Proton Mail"},
+		{blankClassTitle, "", "Wine - Setup - Notepad++ ", "Notepad++"},
+		{blankClassTitle, "", "Save As", unknownAppClass},
+		{blankClassTitle, "", "Progress - 42%", unknownAppClass},
+		{blankClassTitle, "", "Report - a very long trailing segment that reads like a document name", unknownAppClass},
+		{blankClassTitle, "", "Document -", unknownAppClass},
+		{blankClassTitle, "  ", "", unknownAppClass},
+		{blankClassUnknown, "", "Untitled 1 - LibreOffice Calc", unknownAppClass},
+		{blankClassUnknown, "steam", "Steam", "steam"},
+	}
+	for _, tt := range tests {
+		blankClassMode = tt.mode
+		window := &common.MutterWindow{WmClass: tt.class, Title: tt.title}
+		if got := resolveAmbiguousClass(window); got != tt.want {
+			t.Errorf("%s: class %q, title %q: expected %q, got %q", tt.mode, tt.class, tt.title, tt.want, got)
+		}
+	}
+}
+
+// TestBlankClassWindowTracked tests that the monitor tracks a window without a WmClass
+// under its resolved class rather than ""
+func TestBlankClassWindowTracked(t *testing.T) {
+	monitor, _, notifier := newTestMonitor(t)
+	monitor.Backend = &fakeBackend{windows: []*common.MutterWindow{{Title: "Export Image as PNG — GIMP"}}}
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	session, _ := monitor.Tracker.CurrentSession()
+	if session.AppClass != "GIMP" || session.Key != "GIMP" {
+		t.Errorf("Expected the session under GIMP, got class %q key %q", session.AppClass, session.Key)
+	}
+	if len(notifier.windows) != 1 || notifier.windows[0] != "GIMP" {
+		t.Errorf("Expected the window change reported as GIMP, got %v", notifier.windows)
+	}
+}
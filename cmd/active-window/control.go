@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+const (
+	maxContextLength   = 32              // longest -context label
+	controlReadTimeout = 5 * time.Second // how long a control client has to send its command
+	controlNoContext   = "none"          // "context none" clears the context
+)
+
+// controlCommand is a line a client sent to the control socket. The monitor loop answers
+// it on reply, so commands run between polls like everything else that touches the tracker.
+type controlCommand struct {
+	line  string
+	reply chan string
+}
+
+// defaultControlSocketPath is where the control socket goes unless -control-socket says
+// otherwise: the user's runtime directory, else a per-user name in the temp directory
+func defaultControlSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "active-window.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("active-window-%d.sock", os.Getuid()))
+}
+
+// validContextLabel checks a -context or "context" label: letters, digits, '-' and '_',
+// so it reads cleanly as the "[work] firefox" prefix RescueTime shows
+func validContextLabel(label string) error {
+	if label == "" || len(label) > maxContextLength {
+		return fmt.Errorf("context must be 1 to %d characters, got %q", maxContextLength, label)
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("context %q may only contain letters, digits, '-' and '_'", label)
+		}
+	}
+	if label == controlNoContext {
+		return fmt.Errorf("context %q is reserved for clearing the context", label)
+	}
+	return nil
+}
+
+// listenControl listens for commands on a unix socket at path, passing each line a client
+// sends to commands and writing the answer back. A socket left by a run that crashed is
+// replaced; one another instance still answers on is an error.
+func listenControl(path string, commands chan<- controlCommand) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is in use by another instance", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale control socket %s: %v", path, err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %s: %v", path, err)
+	}
+	// Only this user may set the context
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket %s: %v", path, err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					logging.Warning("Control socket stopped: %v", err)
+				}
+				return
+			}
+			go serveControlConn(conn, commands)
+		}
+	}()
+	return listener, nil
+}
+
+// serveControlConn answers each command line on conn until the client hangs up or stops
+// sending within controlReadTimeout
+func serveControlConn(conn net.Conn, commands chan<- controlCommand) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+		if !scanner.Scan() {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		command := controlCommand{line: line, reply: make(chan string, 1)}
+		commands <- command
+		if _, err := fmt.Fprintln(conn, <-command.reply); err != nil {
+			return
+		}
+	}
+}
+
+// sendControl sends one command line to the control socket at path and returns the answer
+func sendControl(path, line string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, controlReadTimeout)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return "", fmt.Errorf("no tracker is listening on %s (start one with -track)", path)
+		}
+		return "", fmt.Errorf("failed to connect to control socket %s: %v", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlReadTimeout))
+
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return "", fmt.Errorf("failed to send command: %v", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read the answer: %v", err)
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// onCommand runs a control socket command and returns the answer; failures start with
+// "error:" so sendControl's caller can tell
+func (m *Monitor) onCommand(line string) string {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return "error: empty command"
+	case fields[0] == "help":
+		return "commands: context (show it), context <label> (e.g. work), context none (clear it), status (memory and state sizes), reset [reload] (drop all tracked sessions, reload re-reads the ignore list)"
+	case fields[0] == "status" && len(fields) == 1:
+		return "status: " + m.stateReport()
+	case fields[0] == "reset" && (len(fields) == 1 || len(fields) == 2 && fields[1] == "reload"):
+		reload := len(fields) == 2
+		if err := m.Tracker.Reset(reload); err != nil {
+			return "error: " + err.Error()
+		}
+		if reload {
+			logging.Info("Tracker reset, ignore list reloaded")
+			return "reset, ignore list reloaded"
+		}
+		logging.Info("Tracker reset")
+		return "reset"
+	case fields[0] == "context" && len(fields) == 1:
+		if context := m.Tracker.Context(); context != "" {
+			return "context: " + context
+		}
+		return "context: none"
+	case fields[0] == "context" && len(fields) == 2:
+		label := fields[1]
+		if label == controlNoContext {
+			label = ""
+		} else if err := validContextLabel(label); err != nil {
+			return "error: " + err.Error()
+		}
+		if label == m.Tracker.Context() {
+			return "context unchanged"
+		}
+		m.Tracker.SetContext(label)
+		if label == "" {
+			logging.Info("Context cleared")
+			return "context cleared"
+		}
+		logging.Info("Context: %s", label)
+		return "context: " + label
+	default:
+		return fmt.Sprintf("error: unknown command %q (try help)", line)
+	}
+}
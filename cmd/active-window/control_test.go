@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestControlContext tests the context command: setting, showing, clearing and rejecting labels
+func TestControlContext(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+
+	tests := []struct {
+		command string
+		reply   string
+		context string
+	}{
+		{"context", "context: none", ""},
+		{"context work", "context: work", "work"},
+		{"context", "context: work", "work"},
+		{"context work", "context unchanged", "work"},
+		{"context [work]", "error: context \"[work]\" may only contain", "work"},
+		{"context " + strings.Repeat("x", maxContextLength+1), "error: context must be 1 to", "work"},
+		{"context none", "context cleared", ""},
+		{"context a b", "error: unknown command", ""},
+		{"pause", "error: unknown command \"pause\"", ""},
+		{"reset now", "error: unknown command \"reset now\"", ""},
+		{"status", "status: heap ", ""},
+	}
+	for _, tt := range tests {
+		if reply := monitor.onCommand(tt.command); !strings.HasPrefix(reply, tt.reply) {
+			t.Errorf("%q: expected a reply starting %q, got %q", tt.command, tt.reply, reply)
+		}
+		if label := monitor.Tracker.Context(); label != tt.context {
+			t.Errorf("%q: expected context %q, got %q", tt.command, tt.context, label)
+		}
+	}
+}
+
+// TestControlSocket tests that -control commands reach a running Monitor through the socket,
+// that a second instance can't take the socket over and that a stale one is replaced
+func TestControlSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	commands := make(chan controlCommand)
+	listener, err := listenControl(path, commands)
+	if err != nil {
+		t.Fatalf("listenControl failed: %v", err)
+	}
+	defer listener.Close()
+
+	monitor, _, _ := newTestMonitor(t)
+	monitor.Backend = &fakeBackend{windows: []*common.MutterWindow{{WmClass: "firefox", Title: "Jira"}}}
+	monitor.Clock = &tickerClock{}
+	monitor.Commands = commands
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- monitor.Run(ctx) }()
+
+	reply, err := sendControl(path, "context work")
+	if err != nil || reply != "context: work" {
+		t.Errorf("Expected the context to be set, got %q, %v", reply, err)
+	}
+	if _, err := listenControl(path, make(chan controlCommand)); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected a second listener to be refused, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if label := monitor.Tracker.Context(); label != "work" {
+		t.Errorf("Expected the work context, got %q", label)
+	}
+	listener.Close()
+
+	if _, err := sendControl(path, "context"); err == nil || !strings.Contains(err.Error(), "no tracker is listening") {
+		t.Errorf("Expected no tracker to answer once the listener is closed, got %v", err)
+	}
+
+	// A socket file nobody listens on, as a crash leaves it
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	listener, err = listenControl(path, make(chan controlCommand))
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+	listener.Close()
+}
+
+// TestControlReset tests that reset through the socket drops the running tracker's sessions
+// and that the tracker keeps taking commands afterwards
+func TestControlReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	commands := make(chan controlCommand)
+	listener, err := listenControl(path, commands)
+	if err != nil {
+		t.Fatalf("listenControl failed: %v", err)
+	}
+	defer listener.Close()
+
+	monitor, _, _ := newTestMonitor(t)
+	monitor.Backend = &fakeBackend{windows: []*common.MutterWindow{{WmClass: "firefox", Title: "Jira"}}}
+	monitor.Clock = &tickerClock{}
+	monitor.Commands = commands
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) == 0 {
+		t.Fatal("Expected the test monitor to start with sessions")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- monitor.Run(ctx) }()
+
+	reply, err := sendControl(path, "reset")
+	if err != nil || reply != "reset" {
+		t.Errorf("Expected the tracker to be reset, got %q, %v", reply, err)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected no sessions after reset, got %d", len(sessions))
+	}
+	reply, err = sendControl(path, "reset reload")
+	if err != nil || reply != "reset, ignore list reloaded" {
+		t.Errorf("Expected the tracker to be reset with a reload, got %q, %v", reply, err)
+	}
+
+	if reply, err := sendControl(path, "context work"); err != nil || reply != "context: work" {
+		t.Errorf("Expected the reset tracker to take a context, got %q, %v", reply, err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+// defaultMinCoverage is the -min-coverage default: sessions and summaries seen by fewer than
+// this share of their expected polls are flagged in reports
+const defaultMinCoverage = 0.8
+
+// minCoveragePolls is how many polls the current session must span before the monitor judges
+// its coverage; a couple of missed polls early in a session are noise
+const minCoveragePolls = 10
+
+// minCoverage is -min-coverage
+var minCoverage = defaultMinCoverage
+
+// lowCoverage reports whether coverage is known and below threshold
+func lowCoverage(coverage, threshold float64) bool {
+	return coverage > 0 && coverage < threshold
+}
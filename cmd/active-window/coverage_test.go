@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestCoverageWatch tests that the change filter reports coverage falling below -min-coverage
+// once, ignores sessions too short to judge, and reports the recovery
+func TestCoverageWatch(t *testing.T) {
+	filter := newWindowChangeFilter(defaultMaxSwitchRate, false)
+	filter.minCoverage = 0.8
+
+	if lowCoverage(0, defaultMinCoverage) {
+		t.Error("Expected unknown coverage not to count as low")
+	}
+	if dropped, _ := filter.ObserveCoverage(0.2, minCoveragePolls-1); dropped {
+		t.Error("Expected a session shorter than minCoveragePolls not to be judged")
+	}
+	if dropped, _ := filter.ObserveCoverage(0.5, 20); !dropped {
+		t.Error("Expected coverage 0.5 to be reported as dropping below 0.8")
+	}
+	if dropped, recovered := filter.ObserveCoverage(0.4, 30); dropped || recovered {
+		t.Error("Expected no second report while coverage stays low")
+	}
+	if _, recovered := filter.ObserveCoverage(0.95, 40); !recovered {
+		t.Error("Expected coverage 0.95 to be reported as recovered")
+	}
+
+	filter.minCoverage = 0
+	if dropped, _ := filter.ObserveCoverage(0.1, 100); dropped {
+		t.Error("Expected -min-coverage 0 to disable the check")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultDailyTotalsPath is where today's totals are kept so a restart mid-day doesn't zero them
+const defaultDailyTotalsPath = ".rescuetime-today.json"
+
+// dailyTopApps is how many applications the "Today" line lists
+const dailyTopApps = 5
+
+// formatDailyTotals describes today's total and top applications, e.g.
+// "3h 5m tracked (code 2h 10m, firefox 40m, ...)"
+func formatDailyTotals(totals map[string]time.Duration) string {
+	keys := make([]string, 0, len(totals))
+	var total time.Duration
+	for key, duration := range totals {
+		keys = append(keys, key)
+		total += duration
+	}
+	if len(keys) == 0 {
+		return "nothing tracked yet"
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if totals[keys[i]] != totals[keys[j]] {
+			return totals[keys[i]] > totals[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	top := make([]string, 0, dailyTopApps+1)
+	for i, key := range keys {
+		if i == dailyTopApps {
+			top = append(top, "...")
+			break
+		}
+		top = append(top, fmt.Sprintf("%s %s", key, HumanDuration(totals[key])))
+	}
+	return fmt.Sprintf("%s tracked (%s)", HumanDuration(total), strings.Join(top, ", "))
+}
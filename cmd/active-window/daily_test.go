@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormatDailyTotals tests the "Today" line
+func TestFormatDailyTotals(t *testing.T) {
+	if got := formatDailyTotals(nil); got != "nothing tracked yet" {
+		t.Errorf("Unexpected empty line: %q", got)
+	}
+	totals := map[string]time.Duration{
+		"firefox": 40 * time.Minute,
+		"code":    2*time.Hour + 10*time.Minute,
+		"a":       time.Minute, "b": time.Minute, "c": time.Minute, "d": time.Minute,
+	}
+	got := formatDailyTotals(totals)
+	if !strings.HasPrefix(got, HumanDuration(2*time.Hour+54*time.Minute)+" tracked (code ") || !strings.HasSuffix(got, ", ...)") {
+		t.Errorf("Unexpected line: %q", got)
+	}
+}
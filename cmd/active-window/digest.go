@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/notify"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// -notify-schedule values besides a daily HH:MM
+const (
+	digestAfterSubmission = "submission" // after every submission tick
+	digestAtShutdown      = "shutdown"   // once, when the tracker stops
+)
+
+// defaultDigestSchedule is the -notify-schedule default: an end-of-day digest
+const defaultDigestSchedule = "18:00"
+
+// digestTimeout bounds one digest post, so a slow chat service can't hold up shutdown
+const digestTimeout = 15 * time.Second
+
+// digestSender posts a digest (implemented by *notify.Client)
+type digestSender interface {
+	Send(ctx context.Context, digest notify.Digest) error
+}
+
+// digestNotifier posts today's totals to Slack, Discord or Matrix on its schedule. Posts run beside
+// tracking and submission: a failure is logged and nothing else is affected.
+type digestNotifier struct {
+	sender   digestSender
+	schedule string        // digestAfterSubmission, digestAtShutdown, or "" for daily at
+	at       time.Duration // daily post time as an offset from midnight in the tracker's location
+	next     time.Time     // next daily post; zero until the first poll arms it
+	top      int           // entries listed before "other" (-notify-top); 0 for notify.DefaultTopEntries
+	async    bool          // post from a goroutine (except at shutdown); tests post inline
+}
+
+// newDigestNotifier parses a -notify-schedule value: "submission", "shutdown" or a daily
+// local time HH:MM
+func newDigestNotifier(sender digestSender, schedule string) (*digestNotifier, error) {
+	d := &digestNotifier{sender: sender, async: true}
+	switch schedule {
+	case digestAfterSubmission, digestAtShutdown:
+		d.schedule = schedule
+		return d, nil
+	}
+	clock, err := time.Parse("15:04", schedule)
+	if err != nil {
+		return nil, fmt.Errorf("-notify-schedule must be %s, %s or a daily time HH:MM, got %q", digestAfterSubmission, digestAtShutdown, schedule)
+	}
+	d.at = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	return d, nil
+}
+
+// nextDailyPost returns the first daily post time after now, counting days in loc
+func (d *digestNotifier) nextDailyPost(now time.Time, loc *time.Location) time.Time {
+	start, end := tracker.DayBounds(now, loc)
+	if next := start.Add(d.at); next.After(now) {
+		return next
+	}
+	return end.Add(d.at)
+}
+
+// onPoll posts the daily digest once its time has passed. The first call only arms it, so
+// starting the tracker in the evening doesn't post a near-empty digest straight away.
+func (d *digestNotifier) onPoll(tracker *ActivityTracker, now time.Time) {
+	if d == nil || d.schedule != "" {
+		return
+	}
+	if d.next.IsZero() {
+		d.next = d.nextDailyPost(now, tracker.Location())
+		return
+	}
+	if now.Before(d.next) {
+		return
+	}
+	d.next = d.nextDailyPost(now, tracker.Location())
+	d.post(tracker, now, d.async)
+}
+
+// onSubmission posts after a submission tick with the "submission" schedule
+func (d *digestNotifier) onSubmission(tracker *ActivityTracker, now time.Time) {
+	if d != nil && d.schedule == digestAfterSubmission {
+		d.post(tracker, now, d.async)
+	}
+}
+
+// onShutdown posts with the "shutdown" schedule, waiting for it so the process doesn't exit first
+func (d *digestNotifier) onShutdown(tracker *ActivityTracker, now time.Time) {
+	if d != nil && d.schedule == digestAtShutdown {
+		d.post(tracker, now, false)
+	}
+}
+
+// post builds the digest from the tracker's daily totals and sends it
+func (d *digestNotifier) post(tracker *ActivityTracker, now time.Time, async bool) {
+	digest := notify.NewDigest("Today", now.In(tracker.Location()), tracker.GetDailyTotals(), d.top)
+	send := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), digestTimeout)
+		defer cancel()
+		if err := d.sender.Send(ctx, digest); err != nil {
+			logging.Warning("Failed to post the activity digest: %v", err)
+			return
+		}
+		logging.Verbose("Posted the activity digest: %s", digest.Text())
+	}
+	if async {
+		go send()
+	} else {
+		send()
+	}
+}
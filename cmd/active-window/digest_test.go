@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/notify"
+)
+
+// recordingSender records the digests it's asked to post, failing with err if set
+type recordingSender struct {
+	digests []notify.Digest
+	err     error
+}
+
+func (s *recordingSender) Send(ctx context.Context, digest notify.Digest) error {
+	s.digests = append(s.digests, digest)
+	return s.err
+}
+
+// TestNewDigestNotifier tests the -notify-schedule values
+func TestNewDigestNotifier(t *testing.T) {
+	for _, schedule := range []string{"submission", "shutdown", "18:00", "00:00"} {
+		if _, err := newDigestNotifier(&recordingSender{}, schedule); err != nil {
+			t.Errorf("%q: unexpected error: %v", schedule, err)
+		}
+	}
+	for _, schedule := range []string{"", "daily", "25:00", "6pm"} {
+		if _, err := newDigestNotifier(&recordingSender{}, schedule); err == nil {
+			t.Errorf("%q: expected an error", schedule)
+		}
+	}
+}
+
+// TestDailyDigest tests that the daily digest is armed by the first poll, posts once when
+// its time passes, and again the next day
+func TestDailyDigest(t *testing.T) {
+	sender := &recordingSender{}
+	digest, err := newDigestNotifier(sender, "18:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest.async = false
+	tracker := NewActivityTracker()
+	day := time.Date(2025, 10, 27, 0, 0, 0, 0, time.Local)
+
+	digest.onPoll(tracker, day.Add(19*time.Hour)) // started after 18:00: arms for tomorrow
+	digest.onPoll(tracker, day.Add(20*time.Hour))
+	if len(sender.digests) != 0 {
+		t.Fatalf("Expected no digest on the first evening, got %d", len(sender.digests))
+	}
+
+	digest.onPoll(tracker, day.Add(24*time.Hour+17*time.Hour))
+	digest.onPoll(tracker, day.Add(24*time.Hour+18*time.Hour))
+	digest.onPoll(tracker, day.Add(24*time.Hour+18*time.Hour+time.Second))
+	if len(sender.digests) != 1 {
+		t.Fatalf("Expected one digest at 18:00, got %d", len(sender.digests))
+	}
+
+	// A failed post is logged, and the next day's still comes
+	sender.err = errors.New("503")
+	digest.onPoll(tracker, day.Add(48*time.Hour+18*time.Hour))
+	if len(sender.digests) != 2 {
+		t.Errorf("Expected the next day's digest, got %d", len(sender.digests))
+	}
+	digest.onSubmission(tracker, day.Add(48*time.Hour+19*time.Hour))
+	digest.onShutdown(tracker, day.Add(48*time.Hour+20*time.Hour))
+	if len(sender.digests) != 2 {
+		t.Errorf("Expected the daily schedule to ignore submissions and shutdown, got %d", len(sender.digests))
+	}
+}
+
+// TestSubmissionDigest tests that the "submission" schedule posts today's totals after every
+// submission tick, whether or not the submission succeeded, listing -notify-top entries
+func TestSubmissionDigest(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	sender := &recordingSender{}
+	digest, err := newDigestNotifier(sender, digestAfterSubmission)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest.async = false
+	digest.top = 1
+	monitor.Digest = digest
+	monitor.DailyTotalsPath = ""
+	runSession(monitor.Tracker, "code", time.Now().Add(-20*time.Minute), time.Now().Add(-5*time.Minute))
+	runSession(monitor.Tracker, "firefox", time.Now().Add(-5*time.Minute), time.Now())
+
+	monitor.onSubmitTick()
+	monitor.onPoll()
+	if len(sender.digests) != 1 {
+		t.Fatalf("Expected one digest, got %d", len(sender.digests))
+	}
+	if got := sender.digests[0]; got.Total <= 0 || len(got.Entries) != 2 || got.Entries[0].Name != "code" || got.Entries[1].Name != "other" {
+		t.Errorf("Expected today's totals as code and other, got %+v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/godbus/dbus/v5"
+)
+
+// Mutter's DisplayConfig PowerSaveMode values (DPMS states)
+const (
+	powerSaveUnknown = -1
+	powerSaveOn      = 0
+	powerSaveStandby = 1
+	powerSaveSuspend = 2
+	powerSaveOff     = 3
+)
+
+// DisplayPowerSource reports whether the display is powered. The compositor can keep
+// reporting a focused window with the screen off, so the monitor pauses tracking then.
+type DisplayPowerSource interface {
+	DisplayPowered() (bool, error)
+}
+
+// dbusDisplayPower is the DisplayPowerSource for a GNOME session (Mutter DisplayConfig)
+type dbusDisplayPower struct{}
+
+func (dbusDisplayPower) DisplayPowered() (bool, error) { return getDisplayPowered() }
+
+// getDisplayPowered reads Mutter's PowerSaveMode over D-Bus and reports whether the display
+// is on. Standby, suspend and off all count as off. An unknown mode is an error, so callers
+// keep tracking.
+func getDisplayPowered() (bool, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	defer conn.Close()
+
+	obj := conn.Object(common.DisplayConfigDestination, dbus.ObjectPath(common.DisplayConfigObjectPath))
+	variant, err := obj.GetProperty(common.DisplayConfigPowerSave)
+	if err != nil {
+		return false, fmt.Errorf("failed to read DisplayConfig.PowerSaveMode: %v", err)
+	}
+	mode, ok := variant.Value().(int32)
+	if !ok {
+		return false, fmt.Errorf("unexpected PowerSaveMode value %v", variant)
+	}
+	logging.Debug("Display power save mode: %d", mode)
+	return displayPoweredMode(mode)
+}
+
+// displayPoweredMode maps a PowerSaveMode value to whether the display is on
+func displayPoweredMode(mode int32) (bool, error) {
+	switch mode {
+	case powerSaveOn:
+		return true, nil
+	case powerSaveStandby, powerSaveSuspend, powerSaveOff:
+		return false, nil
+	}
+	return false, fmt.Errorf("display power state unknown (PowerSaveMode %d)", mode)
+}
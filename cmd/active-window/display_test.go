@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// fakePower reports a fixed display power state
+type fakePower struct {
+	powered bool
+	err     error
+}
+
+func (p *fakePower) DisplayPowered() (bool, error) { return p.powered, p.err }
+
+// TestDisplayPoweredMode tests the mapping of Mutter's PowerSaveMode values
+func TestDisplayPoweredMode(t *testing.T) {
+	for mode, want := range map[int32]bool{powerSaveOn: true, powerSaveStandby: false, powerSaveSuspend: false, powerSaveOff: false} {
+		if got, err := displayPoweredMode(mode); err != nil || got != want {
+			t.Errorf("Mode %d: expected %v, got %v (%v)", mode, want, got, err)
+		}
+	}
+	if _, err := displayPoweredMode(powerSaveUnknown); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+// TestDisplayOffPausesTracking tests that a powered-off display ends the session even though
+// a window is still focused, and that the same window starts a new one when it comes back
+func TestDisplayOffPausesTracking(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	power := &fakePower{powered: true}
+	monitor.Power = power
+	tracking := func() bool {
+		_, ok := monitor.Tracker.CurrentSession()
+		return ok
+	}
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if !tracking() {
+		t.Fatal("Expected a session with the display on")
+	}
+
+	power.powered = false
+	monitor.onPoll()
+	monitor.onPoll()
+	if tracking() {
+		t.Error("Expected no session while the display is off")
+	}
+
+	power.powered = true
+	monitor.onPoll()
+	if session, ok := monitor.Tracker.CurrentSession(); !ok || session.AppClass != "code" {
+		t.Error("Expected the still-focused window to start a new session when the display came back")
+	}
+
+	// An unreadable power state keeps tracking
+	power.err = errors.New("no DisplayConfig")
+	power.powered = false
+	monitor.onPoll()
+	if !tracking() {
+		t.Error("Expected tracking to continue when the power state can't be read")
+	}
+}
+
+// TestStartWithDisplayOff tests that no session starts while the display is off
+func TestStartWithDisplayOff(t *testing.T) {
+	monitor := newMonitor(NewActivityTracker(), &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code", Title: "main.go"}}})
+	monitor.Notifier = &recordingNotifier{}
+	monitor.Power = &fakePower{powered: false}
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if session, ok := monitor.Tracker.CurrentSession(); ok {
+		t.Errorf("Expected no session with the display off, got %+v", session)
+	}
+}
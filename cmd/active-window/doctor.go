@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/webhook"
+	"github.com/fatih/color"
+)
+
+// doctorStatus is the outcome of a -doctor check
+type doctorStatus int
+
+const (
+	doctorPass doctorStatus = iota
+	doctorWarn              // works, but something is degraded
+	doctorFail              // critical: tracking or submission won't work
+	doctorSkip              // not configured
+)
+
+// String names the status in -json output
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorPass:
+		return "pass"
+	case doctorWarn:
+		return "warn"
+	case doctorFail:
+		return "fail"
+	default:
+		return "skip"
+	}
+}
+
+// checksJSON is -json: -doctor and -validate-config print a checksReport instead of the checklist
+var checksJSON bool
+
+const (
+	minAPIKeyLength  = 20               // Shortest plausible RescueTime API key
+	doctorAPITimeout = 15 * time.Second // Bound on the RescueTime API check
+)
+
+// doctorCheck is the result of one -doctor check
+type doctorCheck struct {
+	Name   string
+	Status doctorStatus
+	Detail string
+	Hint   string // remediation, shown when the check doesn't pass
+}
+
+// checksReport is the -json form of a run of checks
+type checksReport struct {
+	Title  string        `json:"title"`
+	OK     bool          `json:"ok"` // false if any critical check failed
+	Checks []checkResult `json:"checks"`
+}
+
+// checkResult is one check in a checksReport
+type checkResult struct {
+	Component string `json:"component"`
+	Status    string `json:"status"` // pass, warn, fail or skip
+	Detail    string `json:"detail"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+// Backends probed by -doctor (implemented by the rescuetime, webhook and postgres clients)
+type (
+	rescueTimePinger interface {
+		Ping(ctx context.Context) error
+	}
+	webhookPinger interface {
+		Ping() error
+	}
+	postgresProber interface {
+		Ping() error
+		SchemaVersion() (int, error)
+	}
+)
+
+// checkBuild reports the build, so a doctor report pasted into an issue says which one ran
+func checkBuild() doctorCheck {
+	return doctorCheck{Name: "Build", Status: doctorPass, Detail: "active-window " + version.String()}
+}
+
+// checkFocusedWindow verifies the D-Bus session bus and the FocusedWindow extension's Get call
+func checkFocusedWindow(getWindow func() (*common.MutterWindow, error)) doctorCheck {
+	check := doctorCheck{Name: "FocusedWindow extension (D-Bus)"}
+	window, err := getWindow()
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Hint = "Make sure you're in a GNOME session and the FocusedWindow extension is installed and enabled:\nhttps://extensions.gnome.org/extension/5839/focused-window-dbus/"
+		return check
+	}
+	check.Detail = fmt.Sprintf("focused: %s (%s)", window.Title, window.WmClass)
+	return check
+}
+
+// checkExtensionVersion reports which methods and fields the installed extension version
+// has. Missing required fields fail; a missing List or optional field only warns, since
+// tracking works without it.
+func checkExtensionVersion(probe func() (common.ExtensionCapabilities, error)) doctorCheck {
+	check := doctorCheck{Name: "FocusedWindow extension version"}
+	caps, err := probe()
+	if err != nil {
+		check.Status = doctorWarn
+		check.Detail = err.Error()
+		check.Hint = "Couldn't tell which features the extension supports; see the FocusedWindow check above"
+		return check
+	}
+	check.Detail = caps.Summary()
+	warnings := caps.Warnings()
+	if len(warnings) == 0 {
+		return check
+	}
+	check.Status = doctorWarn
+	if len(caps.MissingRequired()) > 0 || !caps.HasMethod("Get") {
+		check.Status = doctorFail
+	}
+	check.Detail += "; " + strings.Join(warnings, "; ")
+	check.Hint = "Update the extension to its latest version: " + common.ExtensionURL
+	return check
+}
+
+// checkIdleMonitor verifies Mutter's IdleMonitor; without it tracking works but never pauses
+func checkIdleMonitor(getIdle func() (time.Duration, error)) doctorCheck {
+	check := doctorCheck{Name: "Mutter IdleMonitor"}
+	idle, err := getIdle()
+	if err != nil {
+		check.Status = doctorWarn
+		check.Detail = err.Error()
+		check.Hint = "Idle detection will be disabled. Make sure you're running GNOME/Mutter."
+		return check
+	}
+	check.Detail = fmt.Sprintf("idle for %v", idle.Round(time.Second))
+	return check
+}
+
+// checkCredentials verifies the RescueTime API key is present and plausible.
+// envFileErr is the result of loading .env (nil if it loaded or wasn't needed).
+func checkCredentials(getenv func(string) string, envFileErr error) doctorCheck {
+	check := doctorCheck{Name: "RescueTime credentials"}
+	apiKey := getenv("RESCUE_TIME_API_KEY")
+	switch {
+	case apiKey == "":
+		check.Status = doctorFail
+		check.Detail = "RESCUE_TIME_API_KEY is not set"
+		if envFileErr != nil {
+			check.Detail += fmt.Sprintf(" (%v)", envFileErr)
+		}
+		check.Hint = "Run: cp .env.example .env\nThen edit .env and add your API key from https://www.rescuetime.com/anapi/manage"
+		return check
+	case len(apiKey) < minAPIKeyLength:
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("RESCUE_TIME_API_KEY appears invalid (too short: %d chars)", len(apiKey))
+		check.Hint = "Get your API key from https://www.rescuetime.com/anapi/manage"
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("API key set (%d chars)", len(apiKey))
+	if getenv("RESCUE_TIME_ACCOUNT_KEY") != "" || getenv("RESCUE_TIME_DATA_KEY") != "" {
+		check.Detail += ", native API keys set"
+	}
+	return check
+}
+
+// checkRescueTimeAPI makes an authenticated read-only request with the API key
+func checkRescueTimeAPI(ctx context.Context, client rescueTimePinger, apiKey string) doctorCheck {
+	check := doctorCheck{Name: "RescueTime API"}
+	if apiKey == "" {
+		check.Status = doctorSkip
+		check.Detail = "no API key"
+		return check
+	}
+	if err := client.Ping(ctx); err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Hint = "Verify the key at https://www.rescuetime.com/anapi/manage\nCheck network access: curl -I https://www.rescuetime.com"
+		return check
+	}
+	check.Detail = "API key accepted"
+	return check
+}
+
+// checkWebhook pings the webhook endpoint. setupErr is the error from creating the client, if any.
+func checkWebhook(url string, client webhookPinger, setupErr error) doctorCheck {
+	check := doctorCheck{Name: "Webhook"}
+	switch {
+	case url == "":
+		check.Status = doctorSkip
+		check.Detail = "not configured (-webhook or WEBHOOK_URL)"
+		return check
+	case setupErr != nil:
+		check.Status = doctorFail
+		check.Detail = setupErr.Error()
+		return check
+	}
+	if err := client.Ping(); err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Hint = fmt.Sprintf("Check the endpoint is running and reachable: curl -X POST %s", url)
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s accepted a ping", url)
+	return check
+}
+
+// checkPostgres pings PostgreSQL and compares the schema version with this build.
+// setupErr is the error from connecting, if any.
+func checkPostgres(configured bool, client postgresProber, setupErr error) doctorCheck {
+	check := doctorCheck{Name: "PostgreSQL"}
+	switch {
+	case !configured:
+		check.Status = doctorSkip
+		check.Detail = "not configured (-postgres or POSTGRES_CONNECTION_STRING)"
+		return check
+	case setupErr != nil:
+		check.Status = doctorFail
+		check.Detail = setupErr.Error()
+		return check
+	}
+	if err := client.Ping(); err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Hint = "Check PostgreSQL is running: sudo systemctl status postgresql"
+		return check
+	}
+
+	version, err := client.SchemaVersion()
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		return check
+	}
+	latest := postgres.LatestSchemaVersion()
+	check.Detail = fmt.Sprintf("connected, schema version %d of %d", version, latest)
+	if version < latest {
+		check.Status = doctorWarn
+		check.Hint = "Pending migrations run automatically when the tracker starts with -postgres\nPreview them with -migrate-dry-run"
+	}
+	return check
+}
+
+// checkIgnoreFile parses the ignore list. A missing file is fine (nothing is ignored).
+func checkIgnoreFile(path string) doctorCheck {
+	check := doctorCheck{Name: "Ignore list (" + path + ")"}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		check.Detail = "not present, nothing ignored"
+		return check
+	}
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Hint = fmt.Sprintf("Fix the file permissions or remove %s", path)
+		return check
+	}
+	defer file.Close()
+
+	entries := 0
+	seen := make(map[string]string)
+	var duplicates []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries++
+		if first, ok := seen[strings.ToLower(line)]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("%s/%s", first, line))
+			continue
+		}
+		seen[strings.ToLower(line)] = line
+	}
+	if err := scanner.Err(); err != nil {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("failed to read: %v", err)
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%d entries", entries)
+	if len(duplicates) > 0 {
+		check.Status = doctorWarn
+		check.Detail += fmt.Sprintf(", duplicates: %s", strings.Join(duplicates, ", "))
+		check.Hint = "Entries match regardless of case, so one of each duplicate can be removed"
+	}
+	if list, err := tracker.ReadIgnoreList(path); err == nil && len(list.Includes) > 0 {
+		check.Detail += fmt.Sprintf(", %d more from %d included file(s)", len(list.Included), len(list.Files))
+		if len(list.Warnings) > 0 {
+			check.Status = doctorWarn
+			check.Detail += "; " + strings.Join(list.Warnings, "; ")
+			if check.Hint == "" {
+				check.Hint = "Include paths are relative to the file with the #include line"
+			}
+		}
+	}
+	return check
+}
+
+// runDoctorChecks runs each check in order, printing results as they complete,
+// followed by an overall verdict. Returns false if any critical check failed.
+func runDoctorChecks(checks []func() doctorCheck) bool {
+	return runChecks("active-window doctor", checks)
+}
+
+// runChecks is runDoctorChecks under a different title, shared with -validate-config
+func runChecks(title string, checks []func() doctorCheck) bool {
+	if checksJSON {
+		return writeChecksJSON(os.Stdout, title, checks)
+	}
+	color.New(color.FgCyan, color.Bold).Printf("\n=== %s ===\n\n", title)
+
+	failed, warned := 0, 0
+	for _, run := range checks {
+		check := run()
+		switch check.Status {
+		case doctorPass:
+			color.Green("[PASS] %s: %s\n", check.Name, check.Detail)
+		case doctorWarn:
+			warned++
+			color.Yellow("[WARN] %s: %s\n", check.Name, check.Detail)
+		case doctorFail:
+			failed++
+			color.Red("[FAIL] %s: %s\n", check.Name, check.Detail)
+		case doctorSkip:
+			color.HiBlack("[SKIP] %s: %s\n", check.Name, check.Detail)
+		}
+		if check.Hint != "" && check.Status != doctorPass {
+			for _, line := range strings.Split(check.Hint, "\n") {
+				fmt.Printf("       %s\n", line)
+			}
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case failed > 0:
+		color.New(color.FgRed, color.Bold).Printf("%d critical check(s) failed\n", failed)
+	case warned > 0:
+		color.New(color.FgYellow, color.Bold).Printf("Ready, with %d warning(s)\n", warned)
+	default:
+		color.New(color.FgGreen, color.Bold).Println("All checks passed")
+	}
+	return failed == 0
+}
+
+// writeChecksJSON runs the checks and writes them to w as a checksReport, for scripts that
+// act on individual components. Returns false if any critical check failed.
+func writeChecksJSON(w io.Writer, title string, checks []func() doctorCheck) bool {
+	report := checksReport{Title: title, OK: true, Checks: []checkResult{}}
+	for _, run := range checks {
+		check := run()
+		if check.Status == doctorFail {
+			report.OK = false
+		}
+		result := checkResult{Component: check.Name, Status: check.Status.String(), Detail: check.Detail}
+		if check.Status != doctorPass {
+			result.Hint = check.Hint
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write JSON: %v\n", err)
+		return false
+	}
+	return report.OK
+}
+
+// runDoctor checks every part of the pipeline using the same flags and environment as tracking.
+// Returns false if any critical check failed.
+func runDoctor(postgresConn, webhookURL string) bool {
+	var envFileErr error
+	if os.Getenv("RESCUE_TIME_API_KEY") == "" || (postgresConn == "" && os.Getenv("POSTGRES_CONNECTION_STRING") == "") || (webhookURL == "" && os.Getenv("WEBHOOK_URL") == "") {
+		envFileErr = loadEnvFile(".env") // Optional - settings may come from .env
+	}
+	apiKey := os.Getenv("RESCUE_TIME_API_KEY")
+	if postgresConn == "" {
+		postgresConn = os.Getenv("POSTGRES_CONNECTION_STRING")
+	}
+	if webhookURL == "" {
+		webhookURL = os.Getenv("WEBHOOK_URL")
+	}
+
+	return runDoctorChecks([]func() doctorCheck{
+		checkBuild,
+		func() doctorCheck { return checkFocusedWindow(getActiveWindow) },
+		func() doctorCheck { return checkExtensionVersion(probeExtension) },
+		func() doctorCheck { return checkIdleMonitor(getIdleTime) },
+		func() doctorCheck { return checkCredentials(os.Getenv, envFileErr) },
+		func() doctorCheck {
+			ctx, cancel := context.WithTimeout(context.Background(), doctorAPITimeout)
+			defer cancel()
+			return checkRescueTimeAPI(ctx, newRescueTimeClient(apiKey), apiKey)
+		},
+		func() doctorCheck {
+			if webhookURL == "" {
+				return checkWebhook("", nil, nil)
+			}
+			client, err := webhook.NewClient(webhookURL)
+			if err != nil {
+				return checkWebhook(webhookURL, nil, err)
+			}
+			client.DebugMode = debugMode
+			return checkWebhook(webhookURL, client, nil)
+		},
+		func() doctorCheck {
+			if postgresConn == "" {
+				return checkPostgres(false, nil, nil)
+			}
+			// Doctor only inspects; NewClient would apply migrations
+			client, err := postgres.NewClientWithoutMigrations(postgresConn)
+			if err != nil {
+				return checkPostgres(true, nil, err)
+			}
+			defer client.Close()
+			return checkPostgres(true, client, nil)
+		},
+		func() doctorCheck { return checkIgnoreFile(defaultIgnorePath) },
+	})
+}
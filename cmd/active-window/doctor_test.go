@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+)
+
+// fakePinger is a backend whose Ping result and schema version are fixed
+type fakePinger struct {
+	err     error
+	version int
+}
+
+func (f fakePinger) Ping() error                 { return f.err }
+func (f fakePinger) SchemaVersion() (int, error) { return f.version, nil }
+
+// fakeRescueTimePinger is a RescueTime client whose Ping result is fixed
+type fakeRescueTimePinger struct{ err error }
+
+func (f fakeRescueTimePinger) Ping(ctx context.Context) error { return f.err }
+
+// TestCheckBuild tests that the doctor report names the build
+func TestCheckBuild(t *testing.T) {
+	oldVersion, oldCommit := version.Version, version.Commit
+	version.Version, version.Commit = "v9.9.9", "abc1234"
+	defer func() { version.Version, version.Commit = oldVersion, oldCommit }()
+
+	check := checkBuild()
+	if check.Status != doctorPass || !strings.Contains(check.Detail, "v9.9.9") || !strings.Contains(check.Detail, "abc1234") {
+		t.Errorf("Expected a pass naming v9.9.9 and abc1234, got %+v", check)
+	}
+}
+
+// TestCheckFocusedWindowAndIdle tests the D-Bus checks with injected probes
+func TestCheckFocusedWindowAndIdle(t *testing.T) {
+	ok := checkFocusedWindow(func() (*common.MutterWindow, error) {
+		return &common.MutterWindow{Title: "main.go", WmClass: "code"}, nil
+	})
+	if ok.Status != doctorPass {
+		t.Errorf("Expected pass, got %+v", ok)
+	}
+	missing := checkFocusedWindow(func() (*common.MutterWindow, error) {
+		return nil, errors.New("org.gnome.Shell was not provided by any .service files")
+	})
+	if missing.Status != doctorFail || missing.Hint == "" {
+		t.Errorf("Expected fail with hint, got %+v", missing)
+	}
+
+	if check := checkIdleMonitor(func() (time.Duration, error) { return 3 * time.Second, nil }); check.Status != doctorPass {
+		t.Errorf("Expected idle pass, got %+v", check)
+	}
+	// Idle detection is optional: missing is a warning, not a failure
+	if check := checkIdleMonitor(func() (time.Duration, error) { return 0, errors.New("no idle monitor") }); check.Status != doctorWarn {
+		t.Errorf("Expected idle warning, got %+v", check)
+	}
+}
+
+// TestCheckExtensionVersion tests that a current extension passes, one without List warns,
+// one without wm_class fails, and a failed probe only warns
+func TestCheckExtensionVersion(t *testing.T) {
+	probe := func(caps common.ExtensionCapabilities, err error) func() (common.ExtensionCapabilities, error) {
+		return func() (common.ExtensionCapabilities, error) { return caps, err }
+	}
+	fields := []string{"area", "focus", "in_current_workspace", "maximized", "title", "wm_class", "wm_class_instance"}
+
+	if check := checkExtensionVersion(probe(common.ExtensionCapabilities{Methods: []string{"Get", "List"}, Fields: fields}, nil)); check.Status != doctorPass {
+		t.Errorf("Expected pass, got %+v", check)
+	}
+	if check := checkExtensionVersion(probe(common.ExtensionCapabilities{Methods: []string{"Get"}, Fields: fields}, nil)); check.Status != doctorWarn || !strings.Contains(check.Detail, "List") || check.Hint == "" {
+		t.Errorf("Expected a warning about List with a hint, got %+v", check)
+	}
+	if check := checkExtensionVersion(probe(common.ExtensionCapabilities{Methods: []string{"Get"}, Fields: []string{"title"}}, nil)); check.Status != doctorFail || !strings.Contains(check.Detail, "wm_class") {
+		t.Errorf("Expected fail for a missing wm_class, got %+v", check)
+	}
+	if check := checkExtensionVersion(probe(common.ExtensionCapabilities{}, errors.New("no such object"))); check.Status != doctorWarn {
+		t.Errorf("Expected warning for a failed probe, got %+v", check)
+	}
+}
+
+// TestCheckCredentials tests API key presence and length checks
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want doctorStatus
+	}{
+		{"missing", nil, doctorFail},
+		{"too short", map[string]string{"RESCUE_TIME_API_KEY": "abc123"}, doctorFail},
+		{"valid", map[string]string{"RESCUE_TIME_API_KEY": "B63xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"}, doctorPass},
+	}
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		if check := checkCredentials(getenv, os.ErrNotExist); check.Status != tt.want {
+			t.Errorf("%s: expected status %v, got %+v", tt.name, tt.want, check)
+		}
+	}
+}
+
+// TestCheckBackends tests the RescueTime, webhook and PostgreSQL checks with fake clients
+func TestCheckBackends(t *testing.T) {
+	ctx := context.Background()
+	if check := checkRescueTimeAPI(ctx, fakeRescueTimePinger{}, ""); check.Status != doctorSkip {
+		t.Errorf("Expected skip without key, got %+v", check)
+	}
+	if check := checkRescueTimeAPI(ctx, fakeRescueTimePinger{}, "key"); check.Status != doctorPass {
+		t.Errorf("Expected API pass, got %+v", check)
+	}
+	if check := checkRescueTimeAPI(ctx, fakeRescueTimePinger{err: errors.New("API rejected the key")}, "key"); check.Status != doctorFail {
+		t.Errorf("Expected API fail, got %+v", check)
+	}
+
+	if check := checkWebhook("", nil, nil); check.Status != doctorSkip {
+		t.Errorf("Expected webhook skip, got %+v", check)
+	}
+	if check := checkWebhook("ftp://example.com", nil, errors.New("invalid webhook URL")); check.Status != doctorFail {
+		t.Errorf("Expected webhook setup fail, got %+v", check)
+	}
+	if check := checkWebhook("http://localhost:8080", fakePinger{err: errors.New("connection refused")}, nil); check.Status != doctorFail {
+		t.Errorf("Expected webhook ping fail, got %+v", check)
+	}
+	if check := checkWebhook("http://localhost:8080", fakePinger{}, nil); check.Status != doctorPass {
+		t.Errorf("Expected webhook pass, got %+v", check)
+	}
+
+	latest := postgres.LatestSchemaVersion()
+	if check := checkPostgres(false, nil, nil); check.Status != doctorSkip {
+		t.Errorf("Expected postgres skip, got %+v", check)
+	}
+	if check := checkPostgres(true, nil, errors.New("failed to connect")); check.Status != doctorFail {
+		t.Errorf("Expected postgres connect fail, got %+v", check)
+	}
+	if check := checkPostgres(true, fakePinger{version: latest}, nil); check.Status != doctorPass {
+		t.Errorf("Expected postgres pass, got %+v", check)
+	}
+	if check := checkPostgres(true, fakePinger{version: latest - 1}, nil); check.Status != doctorWarn {
+		t.Errorf("Expected warning for pending migrations, got %+v", check)
+	}
+}
+
+// TestCheckIgnoreFile tests ignore list parsing
+func TestCheckIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if check := checkIgnoreFile(filepath.Join(dir, "missing")); check.Status != doctorPass {
+		t.Errorf("Expected a missing ignore file to pass, got %+v", check)
+	}
+
+	path := filepath.Join(dir, ".rescuetime-ignore")
+	os.WriteFile(path, []byte("# comment\nSlack\n\nDiscord\n"), 0644)
+	if check := checkIgnoreFile(path); check.Status != doctorPass || check.Detail != "2 entries" {
+		t.Errorf("Expected 2 entries, got %+v", check)
+	}
+
+	os.WriteFile(path, []byte("Code\ncode\n"), 0644)
+	if check := checkIgnoreFile(path); check.Status != doctorWarn {
+		t.Errorf("Expected warning for case duplicates, got %+v", check)
+	}
+
+	os.WriteFile(path, []byte("#include missing\nSlack\n"), 0644)
+	if check := checkIgnoreFile(path); check.Status != doctorWarn || !strings.Contains(check.Detail, "missing") {
+		t.Errorf("Expected a warning about the missing include, got %+v", check)
+	}
+}
+
+// TestRunDoctorChecksVerdict tests that only failed checks make the run fail
+func TestRunDoctorChecksVerdict(t *testing.T) {
+	result := func(status doctorStatus) func() doctorCheck {
+		return func() doctorCheck { return doctorCheck{Name: "check", Status: status, Detail: "detail", Hint: "hint"} }
+	}
+
+	if !runDoctorChecks([]func() doctorCheck{result(doctorPass), result(doctorWarn), result(doctorSkip)}) {
+		t.Error("Expected warnings and skips not to fail the run")
+	}
+	if runDoctorChecks([]func() doctorCheck{result(doctorPass), result(doctorFail)}) {
+		t.Error("Expected a failed check to fail the run")
+	}
+}
+
+// TestWriteChecksJSON tests that -json reports each component's status, and is not ok
+// only when a check failed
+func TestWriteChecksJSON(t *testing.T) {
+	checks := []func() doctorCheck{
+		func() doctorCheck {
+			return doctorCheck{Name: "FocusedWindow extension (D-Bus)", Status: doctorPass, Detail: "focused: main.go (code)", Hint: "unused"}
+		},
+		func() doctorCheck {
+			return doctorCheck{Name: "Webhook", Status: doctorFail, Detail: "connection refused", Hint: "Check the endpoint"}
+		},
+		func() doctorCheck {
+			return doctorCheck{Name: "PostgreSQL", Status: doctorSkip, Detail: "not configured"}
+		},
+	}
+	var buf bytes.Buffer
+	if writeChecksJSON(&buf, "active-window doctor", checks) {
+		t.Error("Expected a failed check to fail the run")
+	}
+	var report checksReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", buf.String(), err)
+	}
+	if report.OK || len(report.Checks) != 3 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if c := report.Checks[0]; c.Status != "pass" || c.Hint != "" {
+		t.Errorf("Expected a passing check without a hint, got %+v", c)
+	}
+	if c := report.Checks[1]; c.Component != "Webhook" || c.Status != "fail" || c.Hint != "Check the endpoint" {
+		t.Errorf("Unexpected webhook result %+v", c)
+	}
+	if c := report.Checks[2]; c.Status != "skip" {
+		t.Errorf("Expected skip, got %+v", c)
+	}
+
+	buf.Reset()
+	if !writeChecksJSON(&buf, "active-window doctor", checks[2:]) || !strings.Contains(buf.String(), `"ok": true`) {
+		t.Errorf("Expected skipped checks to pass, got %s", buf.String())
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/godbus/dbus/v5"
+)
+
+// introspectMethod is the standard D-Bus method that describes an object's interfaces
+const introspectMethod = "org.freedesktop.DBus.Introspectable.Introspect"
+
+// probeExtension introspects the FocusedWindow extension's object for its methods and reads
+// one Get response for its fields. A failed Get (nothing focused) only leaves the fields unknown.
+func probeExtension() (common.ExtensionCapabilities, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return common.ExtensionCapabilities{}, fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	defer conn.Close()
+
+	obj := conn.Object(common.DbusDestination, common.DbusObjectPath)
+	var introspectXML string
+	if err := obj.Call(introspectMethod, 0).Store(&introspectXML); err != nil {
+		return common.ExtensionCapabilities{}, fmt.Errorf("failed to introspect the FocusedWindow extension: %v", err)
+	}
+
+	var sample string
+	if err := obj.Call(common.DbusMethod, 0).Store(&sample); err != nil {
+		logging.Debug("No sample Get response for the extension probe: %v", err)
+		sample = ""
+	}
+	return common.ProbeExtension(introspectXML, sample)
+}
+
+// applyExtensionCapabilities logs what the installed extension version can't do and turns
+// off the options that depend on it, so they don't fail on every poll instead: -background-apps
+// without List, -track-geometry without maximized or area. -group-by instance only gets a
+// warning, since it falls back to the class on its own.
+func applyExtensionCapabilities(caps common.ExtensionCapabilities, groupBy string) {
+	logging.Verbose("FocusedWindow extension: %s", caps.Summary())
+	if missing := caps.MissingRequired(); len(missing) > 0 {
+		logging.Error("The FocusedWindow extension is too old: its responses lack %v. Update it from %s", missing, common.ExtensionURL)
+	}
+	for _, warning := range caps.Warnings() {
+		logging.Debug("Extension compatibility: %s", warning)
+	}
+
+	if len(backgroundApps) > 0 && !caps.HasMethod("List") {
+		logging.Warning("-background-apps needs the FocusedWindow extension's List method, which this version lacks; ignoring it. Update the extension from %s", common.ExtensionURL)
+		backgroundApps = nil
+	}
+	if trackGeometry && !caps.HasField("maximized") && !caps.HasField("area") {
+		logging.Warning("-track-geometry needs the maximized and area fields this FocusedWindow extension version doesn't send; ignoring it. Update the extension from %s", common.ExtensionURL)
+		trackGeometry = false
+	}
+	if groupBy == "instance" && !caps.HasField("wm_class_instance") {
+		logging.Warning("-group-by instance needs the wm_class_instance field this FocusedWindow extension version doesn't send; sessions are grouped by class")
+	}
+}
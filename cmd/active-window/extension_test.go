@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestApplyExtensionCapabilities tests that -background-apps is dropped without List and
+// -track-geometry without maximized and area, and that a current extension keeps both
+func TestApplyExtensionCapabilities(t *testing.T) {
+	defer func(apps []string, geometry bool) { backgroundApps, trackGeometry = apps, geometry }(backgroundApps, trackGeometry)
+
+	current := common.ExtensionCapabilities{
+		Methods: []string{"Get", "List"},
+		Fields:  []string{"area", "focus", "in_current_workspace", "maximized", "title", "wm_class", "wm_class_instance"},
+	}
+	backgroundApps, trackGeometry = []string{"zoom"}, true
+	applyExtensionCapabilities(current, "instance")
+	if len(backgroundApps) != 1 || !trackGeometry {
+		t.Errorf("Expected a current extension to keep both options, got %v, %v", backgroundApps, trackGeometry)
+	}
+
+	old := common.ExtensionCapabilities{Methods: []string{"Get"}, Fields: []string{"title", "wm_class"}}
+	applyExtensionCapabilities(old, "instance")
+	if backgroundApps != nil || trackGeometry {
+		t.Errorf("Expected an old extension to turn both options off, got %v, %v", backgroundApps, trackGeometry)
+	}
+
+	// Without a sample response the fields are unknown, so -track-geometry stays on
+	backgroundApps, trackGeometry = []string{"zoom"}, true
+	applyExtensionCapabilities(common.ExtensionCapabilities{Methods: []string{"Get", "List"}}, "class")
+	if len(backgroundApps) != 1 || !trackGeometry {
+		t.Errorf("Expected unknown fields to keep both options, got %v, %v", backgroundApps, trackGeometry)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// defaultMaxSwitchRate is the -max-switch-rate default: window changes per minute above which
+// the extension is assumed to be flapping. Even fast alt-tabbing rarely sustains one a second.
+const defaultMaxSwitchRate = 60
+
+// switchRateWindow is the trailing period window changes are counted over
+const switchRateWindow = time.Minute
+
+// windowChangeFilter decides which polled windows start a new session. It debounces titles
+// flickering to empty and watches the change rate, since a misbehaving extension can report a
+// different window every poll and produce thousands of one-second sessions.
+type windowChangeFilter struct {
+	lastAppClass    string
+	lastWindowTitle string
+
+	maxPerMinute int  // changes per switchRateWindow above which we're flapping; 0 disables
+	suppress     bool // stop tracking while flapping instead of only warning
+
+	changes  []time.Time // change times within the last switchRateWindow, oldest first
+	flapping bool
+
+	// A window source answering only some polls flaps too, as missed polls rather than
+	// changes; see ObserveCoverage
+	minCoverage float64 // current session coverage below which polls are failing; 0 disables
+	gappy       bool
+}
+
+// windowObservation is what the monitor loop should do after a poll
+type windowObservation struct {
+	Changed     bool // start a session for the window
+	FlapStarted bool // the change rate just went over the limit (end the session if suppressing)
+	FlapEnded   bool // the change rate is back under the limit
+}
+
+// newWindowChangeFilter creates a filter warning above maxPerMinute changes (0 disables)
+// and, if suppress, pausing tracking until the rate normalizes
+func newWindowChangeFilter(maxPerMinute int, suppress bool) *windowChangeFilter {
+	return &windowChangeFilter{maxPerMinute: maxPerMinute, suppress: suppress}
+}
+
+// Reset records window as the current one without counting a change (e.g. the initial window)
+func (f *windowChangeFilter) Reset(window *common.MutterWindow) {
+	f.lastAppClass = window.WmClass
+	f.lastWindowTitle = window.Title
+}
+
+// Observe compares a polled window with the last one at time now
+func (f *windowChangeFilter) Observe(window *common.MutterWindow, now time.Time) windowObservation {
+	var obs windowObservation
+
+	// A title dropping to empty for the same application is flicker, not a new window;
+	// keeping the real title means flicking back isn't a change either
+	flicker := window.WmClass == f.lastAppClass && window.Title == "" && f.lastWindowTitle != ""
+	changed := !flicker && (window.WmClass != f.lastAppClass || window.Title != f.lastWindowTitle)
+	if changed {
+		f.lastAppClass = window.WmClass
+		f.lastWindowTitle = window.Title
+		f.changes = append(f.changes, now)
+	}
+
+	rate := f.Rate(now)
+	switch {
+	case f.maxPerMinute > 0 && !f.flapping && rate > f.maxPerMinute:
+		f.flapping = true
+		obs.FlapStarted = true
+		obs.Changed = changed && !f.suppress
+	case f.flapping && rate <= f.maxPerMinute:
+		f.flapping = false
+		obs.FlapEnded = true
+		// Tracking was paused, so resume with whatever window is focused now
+		obs.Changed = changed || f.suppress
+	default:
+		obs.Changed = changed && !(f.flapping && f.suppress)
+	}
+	return obs
+}
+
+// ObserveCoverage takes the current session's poll coverage and expected poll count and
+// returns whether it just fell below minCoverage (polls are failing) or just recovered.
+// Sessions spanning fewer than minCoveragePolls aren't judged.
+func (f *windowChangeFilter) ObserveCoverage(coverage float64, expected int) (dropped, recovered bool) {
+	if f.minCoverage <= 0 || expected < minCoveragePolls {
+		return false, false
+	}
+	gappy := lowCoverage(coverage, f.minCoverage)
+	if gappy == f.gappy {
+		return false, false
+	}
+	f.gappy = gappy
+	return gappy, !gappy
+}
+
+// Rate returns the number of window changes in the last switchRateWindow before now
+func (f *windowChangeFilter) Rate(now time.Time) int {
+	cutoff := now.Add(-switchRateWindow)
+	i := 0
+	for i < len(f.changes) && !f.changes[i].After(cutoff) {
+		i++
+	}
+	f.changes = f.changes[i:]
+	return len(f.changes)
+}
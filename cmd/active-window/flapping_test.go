@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestTitleFlickerDebounced tests that a title dropping to empty for the same class isn't a change
+func TestTitleFlickerDebounced(t *testing.T) {
+	filter := newWindowChangeFilter(defaultMaxSwitchRate, false)
+	filter.Reset(&common.MutterWindow{WmClass: "firefox", Title: "GitHub"})
+	now := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	for i := 0; i < 200; i++ {
+		title := ""
+		if i%2 == 1 {
+			title = "GitHub"
+		}
+		now = now.Add(time.Second)
+		if obs := filter.Observe(&common.MutterWindow{WmClass: "firefox", Title: title}, now); obs.Changed || obs.FlapStarted {
+			t.Fatalf("Poll %d (title %q): expected no change, got %+v", i, title, obs)
+		}
+	}
+
+	// A real title change, or another application with no title, still counts
+	if obs := filter.Observe(&common.MutterWindow{WmClass: "firefox", Title: "Docs"}, now); !obs.Changed {
+		t.Error("Expected a new title to be a change")
+	}
+	if obs := filter.Observe(&common.MutterWindow{WmClass: "code", Title: ""}, now); !obs.Changed {
+		t.Error("Expected a different class with an empty title to be a change")
+	}
+}
+
+// flap feeds count alternating windows one second apart starting at start, as a broken
+// extension would, and returns the observations
+func flap(filter *windowChangeFilter, start time.Time, count int) []windowObservation {
+	observations := make([]windowObservation, 0, count)
+	for i := 0; i < count; i++ {
+		window := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+		if i%2 == 1 {
+			window.Title = "main.go - rescuetime"
+		}
+		observations = append(observations, filter.Observe(window, start.Add(time.Duration(i)*time.Second)))
+	}
+	return observations
+}
+
+// TestFlappingWarnsOnce tests that exceeding the rate reports the flap once and keeps tracking
+func TestFlappingWarnsOnce(t *testing.T) {
+	filter := newWindowChangeFilter(30, false)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	observations := flap(filter, start, 120)
+	started, changed := 0, 0
+	for i, obs := range observations {
+		if obs.FlapStarted {
+			started++
+			if i != 30 {
+				t.Errorf("Expected the flap to be detected on change 31, got %d", i+1)
+			}
+		}
+		if obs.Changed {
+			changed++
+		}
+	}
+	if started != 1 {
+		t.Errorf("Expected one flap warning, got %d", started)
+	}
+	if changed != len(observations) {
+		t.Errorf("Expected every change to be tracked without -suppress-flapping, got %d of %d", changed, len(observations))
+	}
+	if rate := filter.Rate(start.Add(119 * time.Second)); rate != 60 {
+		t.Errorf("Expected 60 changes in the last minute, got %d", rate)
+	}
+}
+
+// TestFlappingSuppressesUntilNormal tests that -suppress-flapping pauses sessions and resumes them
+func TestFlappingSuppressesUntilNormal(t *testing.T) {
+	filter := newWindowChangeFilter(30, true)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	observations := flap(filter, start, 120)
+	for i, obs := range observations[31:] {
+		if obs.Changed {
+			t.Fatalf("Change %d: expected tracking to be suppressed while flapping", i+32)
+		}
+	}
+
+	// The extension settles on one window; once the last minute is calm, tracking resumes
+	calm := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+	var resumed bool
+	for s := 120; s < 240 && !resumed; s++ {
+		obs := filter.Observe(calm, start.Add(time.Duration(s)*time.Second))
+		if obs.FlapEnded {
+			if !obs.Changed {
+				t.Error("Expected tracking to resume with the focused window when flapping ends")
+			}
+			resumed = true
+		} else if obs.Changed {
+			t.Fatalf("Second %d: expected no session while still flapping", s)
+		}
+	}
+	if !resumed {
+		t.Fatal("Expected flapping to end once changes stopped")
+	}
+}
+
+// TestFlappingCheckDisabled tests that -max-switch-rate 0 never reports flapping
+func TestFlappingCheckDisabled(t *testing.T) {
+	filter := newWindowChangeFilter(0, true)
+	for _, obs := range flap(filter, time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local), 200) {
+		if obs.FlapStarted || !obs.Changed {
+			t.Fatalf("Expected every change to be tracked with the check disabled, got %+v", obs)
+		}
+	}
+}
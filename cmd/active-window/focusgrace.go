@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// pendingFocus is a newly focused window waiting out Monitor.FocusGrace before it starts a
+// session, so windows Alt-Tab passes through on the way to another are never tracked
+type pendingFocus struct {
+	window *common.MutterWindow
+	since  time.Time // when it was first polled with focus
+	polls  int       // polls that saw it while pending, credited to its session
+}
+
+// holdFocus starts the grace period for a newly focused window, replacing any window still
+// pending: focus moved on before its grace elapsed
+func (m *Monitor) holdFocus(window *common.MutterWindow, now time.Time) {
+	m.pending = &pendingFocus{window: window, since: now}
+}
+
+// settleFocus starts the session for the pending window once it has held focus for
+// FocusGrace, backdated to when it was first focused. Returns the window, or nil if none
+// settled. Focus returning to the tracked window before the grace elapsed just continues
+// its session.
+func (m *Monitor) settleFocus(now time.Time) *common.MutterWindow {
+	pending := m.pending
+	if pending == nil || now.Sub(pending.since) < m.FocusGrace {
+		return nil
+	}
+	m.pending = nil
+	if m.FocusGrace <= 0 {
+		m.Tracker.StartWindowSession(pending.window)
+		return pending.window
+	}
+	if m.Tracker.IsTracking(pending.window) {
+		return nil
+	}
+	m.Tracker.StartWindowSessionAt(pending.window, pending.since)
+	for i := 0; i < pending.polls; i++ {
+		m.Tracker.ObservePoll()
+	}
+	return pending.window
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// altTabMonitor returns a monitor with a -focus-grace of 500ms, polling every 250ms, that has
+// been tracking code for a minute, and the clock the test advances between polls
+func altTabMonitor(t *testing.T, windows ...*common.MutterWindow) (*Monitor, *recordingNotifier, *tickerClock) {
+	t.Helper()
+	tracker := NewActivityTracker()
+	clock := &tickerClock{now: time.Now().Add(-time.Hour)}
+	code := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+	tracker.StartWindowSessionAt(code, clock.now.Add(-time.Minute))
+
+	notifier := &recordingNotifier{}
+	monitor := newMonitor(tracker, &fakeBackend{windows: windows})
+	monitor.Clock = clock
+	monitor.Notifier = notifier
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	monitor.PollInterval = 250 * time.Millisecond
+	monitor.FocusGrace = 500 * time.Millisecond
+	monitor.Changes.Reset(code)
+	return monitor, notifier, clock
+}
+
+// currentSession returns a copy of the tracker's active session, nil if there is none
+func currentSession(tracker *ActivityTracker) *ActivitySession {
+	session, ok := tracker.CurrentSession()
+	if !ok {
+		return nil
+	}
+	return &session
+}
+
+// poll runs n polls 250ms apart
+func poll(monitor *Monitor, clock *tickerClock, n int) {
+	for i := 0; i < n; i++ {
+		clock.now = clock.now.Add(250 * time.Millisecond)
+		monitor.onPoll()
+	}
+}
+
+// TestFocusGraceAltTab tests that Alt-Tab through three windows on the way to a fourth
+// tracks only the fourth, from when it got focus
+func TestFocusGraceAltTab(t *testing.T) {
+	gimp := &common.MutterWindow{WmClass: "gimp", Title: "photo.png"}
+	monitor, notifier, clock := altTabMonitor(t,
+		&common.MutterWindow{WmClass: "firefox", Title: "News"},
+		&common.MutterWindow{WmClass: "slack", Title: "general"},
+		&common.MutterWindow{WmClass: "gnome-terminal", Title: "~"},
+		gimp,
+	)
+	focusedAt := clock.now.Add(time.Second) // the fourth poll
+
+	poll(monitor, clock, 4)
+	if len(notifier.windows) != 0 || !monitor.Tracker.IsTracking(&common.MutterWindow{WmClass: "code", Title: "main.go"}) {
+		t.Fatalf("Expected code to stay tracked while gimp waits out the grace, got changes %v", notifier.windows)
+	}
+
+	poll(monitor, clock, 2)
+	if !reflect.DeepEqual(notifier.windows, []string{"gimp"}) {
+		t.Errorf("Expected only gimp to be tracked, got %v", notifier.windows)
+	}
+	current := currentSession(monitor.Tracker)
+	if current == nil || current.AppClass != "gimp" || !current.StartTime.Equal(focusedAt) {
+		t.Fatalf("Expected a gimp session from %v, got %+v", focusedAt, current)
+	}
+	if current.Polls != 3 {
+		t.Errorf("Expected the 3 polls since gimp got focus to count for it, got %d", current.Polls)
+	}
+
+	sessions := monitor.Tracker.GetSessions()
+	if len(sessions) != 1 || sessions[0].AppClass != "code" || !sessions[0].EndTime.Equal(focusedAt) {
+		t.Errorf("Expected only the code session, ending when gimp got focus, got %+v", sessions)
+	}
+}
+
+// TestFocusGraceDefaultCycling tests that with the default grace, holding Tab to cycle past
+// every window, the tracked one included, leaves the tracked session alone until the window
+// settled on has kept focus for the grace, and then counts it from when it got focus
+func TestFocusGraceDefaultCycling(t *testing.T) {
+	code := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+	gimp := &common.MutterWindow{WmClass: "gimp", Title: "photo.png"}
+	var cycle []*common.MutterWindow
+	for round := 0; round < 2; round++ {
+		cycle = append(cycle,
+			&common.MutterWindow{WmClass: "firefox", Title: "News"},
+			&common.MutterWindow{WmClass: "slack", Title: "general"},
+			code,
+			&common.MutterWindow{WmClass: "gnome-terminal", Title: "~"},
+		)
+	}
+	monitor, notifier, clock := altTabMonitor(t, append(cycle, gimp)...)
+	monitor.FocusGrace = defaultFocusGrace
+	start := currentSession(monitor.Tracker).StartTime
+	focusedAt := clock.now.Add(time.Duration(len(cycle)+1) * 250 * time.Millisecond)
+
+	poll(monitor, clock, len(cycle)+int(defaultFocusGrace/(250*time.Millisecond)))
+	if len(notifier.windows) != 0 || len(monitor.Tracker.GetSessions()) != 0 {
+		t.Fatalf("Expected no session changes while cycling, got %v and %d stored sessions", notifier.windows, len(monitor.Tracker.GetSessions()))
+	}
+	if current := currentSession(monitor.Tracker); current == nil || current.AppClass != "code" || !current.StartTime.Equal(start) {
+		t.Fatalf("Expected the code session to continue, got %+v", current)
+	}
+
+	poll(monitor, clock, 1)
+	if !reflect.DeepEqual(notifier.windows, []string{"gimp"}) {
+		t.Errorf("Expected only gimp to be tracked, got %v", notifier.windows)
+	}
+	if current := currentSession(monitor.Tracker); current == nil || current.AppClass != "gimp" || !current.StartTime.Equal(focusedAt) {
+		t.Errorf("Expected a gimp session from %v, got %+v", focusedAt, current)
+	}
+}
+
+// TestFocusGraceReturnToTracked tests that Alt-Tab away and back within the grace keeps the
+// tracked session going
+func TestFocusGraceReturnToTracked(t *testing.T) {
+	code := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+	monitor, notifier, clock := altTabMonitor(t,
+		&common.MutterWindow{WmClass: "firefox", Title: "News"},
+		code,
+	)
+	start := currentSession(monitor.Tracker).StartTime
+
+	poll(monitor, clock, 4)
+	if len(notifier.windows) != 0 || len(monitor.Tracker.GetSessions()) != 0 {
+		t.Errorf("Expected no session changes, got %v and %d stored sessions", notifier.windows, len(monitor.Tracker.GetSessions()))
+	}
+	if current := currentSession(monitor.Tracker); current == nil || current.AppClass != "code" || !current.StartTime.Equal(start) {
+		t.Errorf("Expected the code session to continue, got %+v", current)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Console display settings for -time-format and -date-order
+const (
+	timeFormatAuto = "auto"
+	timeFormat24h  = "24h"
+	timeFormat12h  = "12h"
+
+	dateOrderAuto = "auto"
+	dateOrderYMD  = "ymd"
+	dateOrderDMY  = "dmy"
+	dateOrderMDY  = "mdy"
+)
+
+// Locale tables used when -time-format/-date-order are "auto". Keys are POSIX locale
+// names without encoding ("en_US"), or just the language ("ar") to match every region.
+var (
+	clock12hLocales = map[string]bool{
+		"en_US": true, "en_CA": true, "en_AU": true, "en_NZ": true, "en_PH": true, "en_IN": true,
+		"es_US": true, "es_MX": true, "hi_IN": true, "ar": true, "ko_KR": true,
+	}
+	mdyLocales = map[string]bool{
+		"en_US": true, "en_PH": true, "es_US": true,
+	}
+	ymdLocales = map[string]bool{
+		"zh": true, "ja": true, "ko": true, "hu": true, "lt": true, "sv": true, "en_CA": true,
+	}
+	dotDateLocales = map[string]bool{
+		"de": true, "ru": true, "pl": true, "cs": true, "fi": true, "nb": true, "da": true, "tr": true, "uk": true,
+	}
+)
+
+// displayFormat controls how durations and timestamps are shown in console output
+// (summaries, reports and log line timestamps). Machine formats (JSON, API payloads) don't use it.
+type displayFormat struct {
+	Clock24   bool
+	DateOrder string // dateOrderYMD, dateOrderDMY or dateOrderMDY
+	DateSep   string
+}
+
+// display is the console format, set from flags/locale in main()
+var display = displayFormat{Clock24: true, DateOrder: dateOrderYMD, DateSep: "-"}
+
+// localeName returns the effective LC_TIME locale (LC_ALL > LC_TIME > LANG) without encoding or modifier
+func localeName(getenv func(string) string) string {
+	for _, key := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if value := getenv(key); value != "" {
+			if i := strings.IndexAny(value, ".@"); i >= 0 {
+				value = value[:i]
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+// lookupLocale reports whether locale (or its language) is in table
+func lookupLocale(table map[string]bool, locale string) bool {
+	if table[locale] {
+		return true
+	}
+	language, _, _ := strings.Cut(locale, "_")
+	return table[language]
+}
+
+// newDisplayFormat builds the console format from -time-format and -date-order,
+// filling "auto" settings from the locale environment. C/POSIX and unset locales
+// keep the ISO-style defaults (24h, YYYY-MM-DD).
+func newDisplayFormat(clock, order string, getenv func(string) string) (displayFormat, error) {
+	locale := localeName(getenv)
+	isoLocale := locale == "" || locale == "C" || locale == "POSIX"
+	f := displayFormat{Clock24: true, DateOrder: dateOrderYMD, DateSep: "-"}
+
+	switch clock {
+	case timeFormatAuto, "":
+		f.Clock24 = isoLocale || !lookupLocale(clock12hLocales, locale)
+	case timeFormat24h:
+		f.Clock24 = true
+	case timeFormat12h:
+		f.Clock24 = false
+	default:
+		return f, fmt.Errorf("unknown time format %q\nValid options: %s, %s, %s", clock, timeFormatAuto, timeFormat24h, timeFormat12h)
+	}
+
+	switch order {
+	case dateOrderAuto, "":
+		switch {
+		case isoLocale || lookupLocale(ymdLocales, locale):
+			f.DateOrder = dateOrderYMD
+		case lookupLocale(mdyLocales, locale):
+			f.DateOrder = dateOrderMDY
+		default:
+			f.DateOrder = dateOrderDMY
+		}
+	case dateOrderYMD, dateOrderDMY, dateOrderMDY:
+		f.DateOrder = order
+	default:
+		return f, fmt.Errorf("unknown date order %q\nValid options: %s, %s, %s, %s", order, dateOrderAuto, dateOrderYMD, dateOrderDMY, dateOrderMDY)
+	}
+
+	switch {
+	case f.DateOrder == dateOrderYMD:
+		f.DateSep = "-"
+	case !isoLocale && lookupLocale(dotDateLocales, locale):
+		f.DateSep = "."
+	default:
+		f.DateSep = "/"
+	}
+	return f, nil
+}
+
+// Clock formats the time of day, e.g. "14:05" or "2:05 PM"
+func (f displayFormat) Clock(t time.Time) string {
+	if f.Clock24 {
+		return t.Format("15:04")
+	}
+	return t.Format("3:04 PM")
+}
+
+// Date formats a calendar date in the configured order, e.g. "2025-10-29", "29.10.2025" or "10/29/2025"
+func (f displayFormat) Date(t time.Time) string {
+	switch f.DateOrder {
+	case dateOrderDMY:
+		return t.Format("02" + f.DateSep + "01" + f.DateSep + "2006")
+	case dateOrderMDY:
+		return t.Format("01" + f.DateSep + "02" + f.DateSep + "2006")
+	}
+	return t.Format("2006" + f.DateSep + "01" + f.DateSep + "02")
+}
+
+// LocalTimestamp formats t in local time as date and time of day
+func (f displayFormat) LocalTimestamp(t time.Time) string {
+	t = t.In(time.Local)
+	return f.Date(t) + " " + f.Clock(t)
+}
+
+// HumanDuration formats a duration for people rather than machines: "2h 05m", "12m 30s", "45s".
+// Durations of an hour or more drop seconds. Unit letters are the same in every locale.
+func HumanDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		return "-" + HumanDuration(-d)
+	}
+
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh %02dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %02ds", minutes, seconds)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
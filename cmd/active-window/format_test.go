@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHumanDuration tests console duration formatting
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{45 * time.Second, "45s"},
+		{12*time.Minute + 30*time.Second, "12m 30s"},
+		{2*time.Hour + 5*time.Minute + 59*time.Second, "2h 05m"},
+		{1500 * time.Millisecond, "2s"},
+		{-90 * time.Second, "-1m 30s"},
+	}
+
+	for _, tt := range tests {
+		if got := HumanDuration(tt.d); got != tt.want {
+			t.Errorf("HumanDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestDisplayFormatLocales tests clock style and date order across locales and overrides
+func TestDisplayFormatLocales(t *testing.T) {
+	ts := time.Date(2025, 10, 29, 14, 5, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		clock     string
+		order     string
+		wantClock string
+		wantDate  string
+	}{
+		{name: "unset locale", env: nil, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "2025-10-29"},
+		{name: "C locale", env: map[string]string{"LANG": "C.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "2025-10-29"},
+		{name: "en_US", env: map[string]string{"LANG": "en_US.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "2:05 PM", wantDate: "10/29/2025"},
+		{name: "en_GB", env: map[string]string{"LANG": "en_GB.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "29/10/2025"},
+		{name: "de_DE", env: map[string]string{"LANG": "de_DE.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "29.10.2025"},
+		{name: "ja_JP", env: map[string]string{"LANG": "ja_JP.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "2025-10-29"},
+		{name: "ar_EG", env: map[string]string{"LANG": "ar_EG.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "2:05 PM", wantDate: "29/10/2025"},
+		{name: "LC_TIME overrides LANG", env: map[string]string{"LANG": "en_US.UTF-8", "LC_TIME": "fr_FR.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "14:05", wantDate: "29/10/2025"},
+		{name: "LC_ALL overrides LC_TIME", env: map[string]string{"LC_TIME": "fr_FR.UTF-8", "LC_ALL": "en_US.UTF-8"}, clock: timeFormatAuto, order: dateOrderAuto, wantClock: "2:05 PM", wantDate: "10/29/2025"},
+		{name: "flags override locale", env: map[string]string{"LANG": "en_US.UTF-8"}, clock: timeFormat24h, order: dateOrderYMD, wantClock: "14:05", wantDate: "2025-10-29"},
+		{name: "12h with dmy", env: map[string]string{"LANG": "de_DE.UTF-8"}, clock: timeFormat12h, order: dateOrderDMY, wantClock: "2:05 PM", wantDate: "29.10.2025"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			f, err := newDisplayFormat(tt.clock, tt.order, getenv)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := f.Clock(ts); got != tt.wantClock {
+				t.Errorf("Clock() = %q, want %q", got, tt.wantClock)
+			}
+			if got := f.Date(ts); got != tt.wantDate {
+				t.Errorf("Date() = %q, want %q", got, tt.wantDate)
+			}
+			if got, want := f.LocalTimestamp(ts), tt.wantDate+" "+tt.wantClock; got != want {
+				t.Errorf("LocalTimestamp() = %q, want %q", got, want)
+			}
+		})
+	}
+
+	getenv := func(string) string { return "" }
+	if _, err := newDisplayFormat("13h", dateOrderAuto, getenv); err == nil {
+		t.Error("Expected error for unknown time format")
+	}
+	if _, err := newDisplayFormat(timeFormatAuto, "ydm", getenv); err == nil {
+		t.Error("Expected error for unknown date order")
+	}
+}
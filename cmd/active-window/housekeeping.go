@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// defaultHousekeepingInterval is how often a long run compacts its state and reports its size
+const defaultHousekeepingInterval = time.Hour
+
+// housekeeper is a Submitter with state that grows over a long run, which the housekeeping
+// tick compacts and reports on
+type housekeeper interface {
+	// housekeep compacts the submitter's state
+	housekeep() error
+	// stateSize describes what it holds in a few words, e.g. "queue 3, pending 0"
+	stateSize() string
+}
+
+// housekeep keeps RescueTime's offline queue under maxQueuedSummaries and forgets the
+// client's pending summaries the queue already holds
+func (s *rescueTimeSubmitter) housekeep() error {
+	if s.queue == nil {
+		return nil
+	}
+	dropped, err := s.queue.Trim(maxQueuedSummaries)
+	if err != nil {
+		return err
+	}
+	if dropped > 0 {
+		logging.Warning("%s: dropped the %d oldest queued activities, keeping %d", s.Name(), dropped, maxQueuedSummaries)
+	}
+	queued, err := s.queue.Keys()
+	if err != nil {
+		return err
+	}
+	if pruned := s.client.PrunePending(queued); pruned > 0 {
+		logging.Debug("%s: forgot %d pending activities already queued", s.Name(), pruned)
+	}
+	return nil
+}
+
+// stateSize reports the offline queue's length and the client's pending summaries
+func (s *rescueTimeSubmitter) stateSize() string {
+	queued := "none"
+	if s.queue != nil {
+		if n, err := s.queue.Len(); err != nil {
+			queued = "unreadable"
+		} else {
+			queued = fmt.Sprint(n)
+		}
+	}
+	return fmt.Sprintf("queue %s, pending %d", queued, s.client.PendingCount())
+}
+
+// onHousekeeping is the periodic tick of a long run: it checkpoints the active session to
+// the WAL, drops the sessions every destination has consumed (rewriting the WAL to the
+// rest), saves today's totals, compacts each submitter's state and logs the state report
+func (m *Monitor) onHousekeeping() {
+	m.Tracker.Checkpoint(m.Clock.Now())
+	m.Tracker.CompactSessions()
+	m.saveDailyTotals()
+	for _, submitter := range m.Submitters {
+		if keeper, ok := submitter.(housekeeper); ok {
+			if err := keeper.housekeep(); err != nil {
+				logging.Warning("%s housekeeping failed: %v", submitter.Name(), err)
+			}
+		}
+	}
+	logging.Verbose("Housekeeping: %s", m.stateReport())
+}
+
+// stateReport describes the process's memory and goroutines and the size of the tracker's
+// and submitters' state in one line, for housekeeping and the control socket's status
+func (m *Monitor) stateReport() string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	parts := []string{
+		fmt.Sprintf("heap %.1f MiB, %d goroutines", float64(mem.HeapAlloc)/(1<<20), runtime.NumGoroutine()),
+		m.Tracker.StateSize().String(),
+	}
+	for _, submitter := range m.Submitters {
+		if keeper, ok := submitter.(housekeeper); ok {
+			parts = append(parts, submitter.Name()+": "+keeper.stateSize())
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// countingSubmitter counts submissions without keeping them, so a soak test's own memory
+// doesn't grow
+type countingSubmitter struct {
+	submissions int
+	sessions    int
+}
+
+func (s *countingSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	s.submissions++
+	s.sessions += len(submission.Sessions)
+	return nil, nil
+}
+func (s *countingSubmitter) Close(context.Context) error { return nil }
+func (s *countingSubmitter) Name() string                { return "counting" }
+
+// TestRescueTimeHousekeeping tests that housekeeping forgets the client's pending summaries
+// once the offline queue holds them, and reports both
+func TestRescueTimeHousekeeping(t *testing.T) {
+	t.Setenv("RESCUE_TIME_ACCOUNT_KEY", "")
+	t.Setenv("RESCUE_TIME_DATA_KEY", "")
+
+	queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+	client := newRescueTimeClient("test-key")
+	client.Queue = queue
+	submitter := &rescueTimeSubmitter{client: client, queue: queue}
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"code":    {AppClass: "code", TotalDuration: 20 * time.Minute, FirstSeen: start, LastSeen: start.Add(20 * time.Minute)},
+	}
+
+	// Cut off by the deadline, as by BackendTimeout: unsent, queued, and still pending
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := client.SubmitActivitiesResult(ctx, summaries)
+	if err := queue.Append(result.Unsent); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if got := submitter.stateSize(); got != "queue 2, pending 2" {
+		t.Fatalf("Expected 2 queued and pending, got %q", got)
+	}
+
+	if err := submitter.housekeep(); err != nil {
+		t.Fatalf("housekeep failed: %v", err)
+	}
+	if got := submitter.stateSize(); got != "queue 2, pending 0" {
+		t.Errorf("Expected the queued summaries no longer pending, got %q", got)
+	}
+	if n, err := queue.Len(); err != nil || n != 2 {
+		t.Errorf("Expected the queue to keep both summaries, got %d, %v", n, err)
+	}
+}
+
+// TestHousekeepingSoak simulates a month of tracking on a fake clock: a window change every
+// two minutes, a submission every 15 minutes and housekeeping every hour. The tracker's
+// state, the WAL and the goroutine count must stay bounded the whole time.
+func TestHousekeepingSoak(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "sessions.wal")
+	wal, err := tracker.OpenWAL(walPath)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+
+	apps := []string{"code", "firefox", "slack", "kitty", "zoom"}
+	start := time.Date(2025, 11, 1, 0, 0, 0, 0, time.Local)
+	clock := &tickerClock{now: start}
+	activity := NewActivityTracker()
+	activity.SetClock(clock.Now)
+	activity.SetWAL(wal)
+	backend := &fakeBackend{windows: []*common.MutterWindow{{WmClass: apps[0]}}}
+	submitters := []*countingSubmitter{{}, {}}
+
+	monitor := newMonitor(activity, backend)
+	monitor.Clock = clock
+	monitor.Notifier = &recordingNotifier{}
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	monitor.Submit = true
+	for _, submitter := range submitters {
+		monitor.Submitters = append(monitor.Submitters, submitter)
+	}
+	goroutines := runtime.NumGoroutine()
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	var most tracker.StateSize
+	var largestWAL int64
+	const step = 2 * time.Minute
+	for i := 1; clock.now.Before(start.AddDate(0, 1, 0)); i++ {
+		clock.now = clock.now.Add(step)
+		backend.windows = []*common.MutterWindow{{WmClass: apps[i%len(apps)], Title: "window"}}
+		monitor.onPoll()
+		if elapsed := time.Duration(i) * step; elapsed%(15*time.Minute) == 0 {
+			monitor.onSubmitTick()
+		}
+		if elapsed := time.Duration(i) * step; elapsed%time.Hour == 0 {
+			monitor.onHousekeeping()
+
+			size := activity.StateSize()
+			most.Sessions = max(most.Sessions, size.Sessions)
+			most.DailyTotals = max(most.DailyTotals, size.DailyTotals)
+			most.AppStats = max(most.AppStats, size.AppStats)
+			if info, err := os.Stat(walPath); err == nil {
+				largestWAL = max(largestWAL, info.Size())
+			}
+		}
+	}
+	monitor.onShutdown()
+
+	// Sessions complete every two minutes and are drained every 15, so at most a submission
+	// interval's worth is ever held
+	if most.Sessions > 8 {
+		t.Errorf("Expected at most 8 sessions held at housekeeping, saw %d", most.Sessions)
+	}
+	if most.DailyTotals > len(apps) || most.AppStats > len(apps) {
+		t.Errorf("Expected per-application state bounded by the %d applications, saw %+v", len(apps), most)
+	}
+	if largestWAL > 8<<10 {
+		t.Errorf("Expected the WAL to stay small, saw %d bytes", largestWAL)
+	}
+	if now := runtime.NumGoroutine(); now > goroutines+2 {
+		t.Errorf("Expected no goroutines left behind, had %d and now %d", goroutines, now)
+	}
+
+	// Every two-minute session of the month (some split by drains) reached both submitters
+	if submitters[0].sessions != submitters[1].sessions || submitters[0].sessions < 30*24*30 {
+		t.Errorf("Expected both submitters to get every session, got %d and %d", submitters[0].sessions, submitters[1].sessions)
+	}
+	if report := monitor.stateReport(); !strings.Contains(report, "goroutines") || !strings.Contains(report, "sessions 0") {
+		t.Errorf("Unexpected state report %q", report)
+	}
+}
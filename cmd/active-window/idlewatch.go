@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/godbus/dbus/v5"
+)
+
+// IdleWatcher reports idle and active transitions as they happen, so the monitor doesn't
+// have to poll IdleTime. Each event is how long the user has been without input when it
+// fired: one of the thresholds passed to Watch, or 0 when the user came back.
+type IdleWatcher interface {
+	// Watch registers a watch for each threshold; idleNow means the user is already idle,
+	// so the first event expected is their return. An error leaves the monitor polling.
+	Watch(thresholds []time.Duration, idleNow bool) (<-chan time.Duration, error)
+	Close() error
+}
+
+// idleMonitorBus is the part of the session bus an idleMonitorWatcher uses, so tests can
+// stand in for Mutter's IdleMonitor
+type idleMonitorBus interface {
+	// call invokes an IdleMonitor method, storing its reply (if any) in ret
+	call(method string, ret interface{}, args ...interface{}) error
+	// fired delivers the ID of each WatchFired signal; it's closed with the bus
+	fired() <-chan uint32
+	close() error
+}
+
+// sessionIdleMonitor is the idleMonitorBus on its own session bus connection
+type sessionIdleMonitor struct {
+	conn    *dbus.Conn
+	ids     chan uint32
+	obj     dbus.BusObject
+	signals chan *dbus.Signal
+}
+
+// connectIdleMonitor opens a session bus connection subscribed to IdleMonitor's WatchFired
+func connectIdleMonitor() (idleMonitorBus, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(dbus.ObjectPath(common.IdleMonitorObjectPath)),
+		dbus.WithMatchInterface(common.IdleMonitorInterface),
+		dbus.WithMatchMember(common.IdleMonitorWatchFired),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %v", common.IdleMonitorWatchFired, err)
+	}
+
+	bus := &sessionIdleMonitor{
+		conn:    conn,
+		ids:     make(chan uint32, 8),
+		obj:     conn.Object(common.IdleMonitorDestination, dbus.ObjectPath(common.IdleMonitorObjectPath)),
+		signals: make(chan *dbus.Signal, 8),
+	}
+	conn.Signal(bus.signals)
+	go func() {
+		// The connection closes the signal channel when it closes
+		defer close(bus.ids)
+		for signal := range bus.signals {
+			if signal.Name != common.IdleMonitorInterface+"."+common.IdleMonitorWatchFired || len(signal.Body) == 0 {
+				continue
+			}
+			if id, ok := signal.Body[0].(uint32); ok {
+				bus.ids <- id
+			}
+		}
+	}()
+	return bus, nil
+}
+
+func (b *sessionIdleMonitor) call(method string, ret interface{}, args ...interface{}) error {
+	call := b.obj.Call(method, 0, args...)
+	if call.Err != nil {
+		return call.Err
+	}
+	if ret == nil {
+		return nil
+	}
+	return call.Store(ret)
+}
+
+func (b *sessionIdleMonitor) fired() <-chan uint32 { return b.ids }
+func (b *sessionIdleMonitor) close() error         { return b.conn.Close() }
+
+// idleMonitorWatcher is the IdleWatcher for Mutter's IdleMonitor. It keeps an idle watch
+// per threshold registered, and after one fires, a user-active watch for the return.
+type idleMonitorWatcher struct {
+	connect func() (idleMonitorBus, error)
+
+	mu          sync.Mutex
+	bus         idleMonitorBus
+	idleWatches map[uint32]time.Duration // watch ID to threshold
+	activeWatch uint32                   // 0 when none is registered
+	events      chan time.Duration
+	done        chan struct{}
+}
+
+// newIdleMonitorWatcher returns an IdleWatcher on the session bus's IdleMonitor
+func newIdleMonitorWatcher() *idleMonitorWatcher {
+	return &idleMonitorWatcher{connect: connectIdleMonitor}
+}
+
+func (w *idleMonitorWatcher) Watch(thresholds []time.Duration, idleNow bool) (<-chan time.Duration, error) {
+	bus, err := w.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bus = bus
+	w.idleWatches = make(map[uint32]time.Duration)
+	for _, threshold := range thresholds {
+		var id uint32
+		if err := bus.call(common.IdleMonitorAddIdleWatch, &id, uint64(threshold.Milliseconds())); err != nil {
+			w.removeWatchesUnsafe()
+			bus.close()
+			return nil, fmt.Errorf("AddIdleWatch(%v) failed: %v", threshold, err)
+		}
+		w.idleWatches[id] = threshold
+	}
+	if idleNow {
+		if err := w.addActiveWatchUnsafe(); err != nil {
+			w.removeWatchesUnsafe()
+			bus.close()
+			return nil, err
+		}
+	}
+
+	w.events = make(chan time.Duration, 8)
+	w.done = make(chan struct{})
+	go w.run(bus.fired())
+	return w.events, nil
+}
+
+// run turns fired watch IDs into events until the bus closes
+func (w *idleMonitorWatcher) run(fired <-chan uint32) {
+	for id := range fired {
+		w.mu.Lock()
+		event, ok := w.idleWatches[id]
+		if ok {
+			// The return is only interesting once the user has gone idle
+			if w.activeWatch == 0 {
+				if err := w.addActiveWatchUnsafe(); err != nil {
+					logging.Warning("Failed to watch for the return from idle: %v", err)
+				}
+			}
+		} else if id == w.activeWatch && id != 0 {
+			// User-active watches fire once and are gone
+			w.activeWatch = 0
+			event, ok = 0, true
+		}
+		w.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+		// After Close, keep draining until the bus closes the channel
+		select {
+		case w.events <- event:
+		case <-w.done:
+		}
+	}
+}
+
+// addActiveWatchUnsafe registers a user-active watch. Caller must hold the lock.
+func (w *idleMonitorWatcher) addActiveWatchUnsafe() error {
+	var id uint32
+	if err := w.bus.call(common.IdleMonitorAddUserActiveWatch, &id); err != nil {
+		return fmt.Errorf("AddUserActiveWatch failed: %v", err)
+	}
+	w.activeWatch = id
+	return nil
+}
+
+// removeWatchesUnsafe removes every registered watch, ignoring errors. Caller must hold
+// the lock.
+func (w *idleMonitorWatcher) removeWatchesUnsafe() {
+	for id := range w.idleWatches {
+		w.bus.call(common.IdleMonitorRemoveWatch, nil, id)
+	}
+	if w.activeWatch != 0 {
+		w.bus.call(common.IdleMonitorRemoveWatch, nil, w.activeWatch)
+	}
+	w.idleWatches, w.activeWatch = nil, 0
+}
+
+// Close removes the watches and closes the connection
+func (w *idleMonitorWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.bus == nil {
+		return nil
+	}
+	w.removeWatchesUnsafe()
+	close(w.done)
+	err := w.bus.close()
+	w.bus = nil
+	return err
+}
+
+// watchIdle registers IdleWatcher's watches at IdleThreshold (and PresentIdleThreshold, if
+// below it). It reports whether they're registered; if not, onPoll keeps polling IdleTime.
+func (m *Monitor) watchIdle() bool {
+	if m.IdleWatcher == nil {
+		return false
+	}
+	thresholds := []time.Duration{m.IdleThreshold}
+	if m.PresentIdleThreshold > 0 && m.PresentIdleThreshold < m.IdleThreshold {
+		thresholds = append(thresholds, m.PresentIdleThreshold)
+	}
+	events, err := m.IdleWatcher.Watch(thresholds, m.wasIdle)
+	if err != nil {
+		logging.Warning("Couldn't register IdleMonitor watches, polling idle time instead: %v", err)
+		return false
+	}
+	m.idleEvents = events
+	if m.wasIdle {
+		m.idleSince = m.Clock.Now().Add(-m.IdleThreshold)
+	}
+	logging.Verbose("Watching IdleMonitor for idle and active transitions (%v)", thresholds)
+	return true
+}
+
+// idleTime is how long the user has been without input: polled from the backend, or with
+// IdleMonitor watches, worked out from the last event
+func (m *Monitor) idleTime() (time.Duration, error) {
+	if m.idleEvents == nil {
+		return m.Backend.IdleTime()
+	}
+	if m.idleSince.IsZero() {
+		return 0, nil
+	}
+	return m.Clock.Now().Sub(m.idleSince), nil
+}
+
+// onIdleEvent handles an IdleMonitor watch firing, idle without input (0 on the user's
+// return), with a poll right away so tracking pauses or resumes without waiting for the
+// poll ticker
+func (m *Monitor) onIdleEvent(idle time.Duration) {
+	if idle == 0 {
+		m.idleSince = time.Time{}
+	} else if since := m.Clock.Now().Add(-idle); m.idleSince.IsZero() || since.Before(m.idleSince) {
+		m.idleSince = since
+	}
+	m.onPoll()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// fakeIdleMonitor stands in for Mutter's IdleMonitor: it hands out watch IDs and fires them
+// when the test says the user went idle or came back
+type fakeIdleMonitor struct {
+	mu           sync.Mutex
+	nextID       uint32
+	idleWatches  map[uint32]time.Duration
+	activeWatch  uint32
+	removed      int
+	addErr       error // returned by AddIdleWatch, as from a Mutter without watches
+	ids          chan uint32
+	closed       bool
+	activeCalled int
+}
+
+func newFakeIdleMonitor() *fakeIdleMonitor {
+	return &fakeIdleMonitor{idleWatches: make(map[uint32]time.Duration), ids: make(chan uint32, 8)}
+}
+
+func (f *fakeIdleMonitor) call(method string, ret interface{}, args ...interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch method {
+	case common.IdleMonitorAddIdleWatch:
+		if f.addErr != nil {
+			return f.addErr
+		}
+		f.nextID++
+		f.idleWatches[f.nextID] = time.Duration(args[0].(uint64)) * time.Millisecond
+		*ret.(*uint32) = f.nextID
+	case common.IdleMonitorAddUserActiveWatch:
+		f.nextID++
+		f.activeWatch = f.nextID
+		f.activeCalled++
+		*ret.(*uint32) = f.nextID
+	case common.IdleMonitorRemoveWatch:
+		id := args[0].(uint32)
+		delete(f.idleWatches, id)
+		if id == f.activeWatch {
+			f.activeWatch = 0
+		}
+		f.removed++
+	default:
+		return errors.New("unknown method " + method)
+	}
+	return nil
+}
+
+func (f *fakeIdleMonitor) fired() <-chan uint32 { return f.ids }
+
+func (f *fakeIdleMonitor) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.ids)
+	}
+	return nil
+}
+
+// goIdle fires the idle watch at threshold
+func (f *fakeIdleMonitor) goIdle(t *testing.T, threshold time.Duration) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, interval := range f.idleWatches {
+		if interval == threshold {
+			f.ids <- id
+			return
+		}
+	}
+	t.Fatalf("No idle watch at %v, have %v", threshold, f.idleWatches)
+}
+
+// comeBack fires the user-active watch, which like Mutter's only fires once
+func (f *fakeIdleMonitor) comeBack(t *testing.T) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.activeWatch == 0 {
+		t.Fatal("No user-active watch registered")
+	}
+	f.ids <- f.activeWatch
+	f.activeWatch = 0
+}
+
+// nextIdleEvent waits for the watcher's next event
+func nextIdleEvent(t *testing.T, events <-chan time.Duration) time.Duration {
+	t.Helper()
+	select {
+	case idle := <-events:
+		return idle
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for an idle event")
+		return 0
+	}
+}
+
+// TestIdleWatchPausesAndResumes runs the monitor against a fake IdleMonitor: the idle watch
+// firing pauses tracking, polls don't ask for the idle time, and the user-active watch
+// firing resumes tracking with the focused window
+func TestIdleWatchPausesAndResumes(t *testing.T) {
+	bus := newFakeIdleMonitor()
+	backend := &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code", Title: "main.go"}}}
+	notifier := &recordingNotifier{}
+	monitor := newMonitor(NewActivityTracker(), backend)
+	monitor.Notifier = notifier
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	monitor.IdleThreshold = 5 * time.Minute
+	monitor.PresentIdleThreshold = time.Minute
+	monitor.IdleWatcher = &idleMonitorWatcher{connect: func() (idleMonitorBus, error) { return bus, nil }}
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if !monitor.watchIdle() {
+		t.Fatal("Expected the watches to be registered")
+	}
+	if len(bus.idleWatches) != 2 || bus.activeWatch != 0 {
+		t.Fatalf("Expected idle watches at both thresholds and no user-active watch yet, got %v, %d", bus.idleWatches, bus.activeWatch)
+	}
+
+	// Polled, the backend would say the user is idle; watched, it isn't asked
+	backend.idle = time.Hour
+	monitor.onPoll()
+	if monitor.wasIdle {
+		t.Fatal("Expected a poll not to ask the backend for the idle time")
+	}
+
+	// Reading for a minute is still active time; five minutes is idle
+	bus.goIdle(t, time.Minute)
+	if idle := nextIdleEvent(t, monitor.idleEvents); idle != time.Minute {
+		t.Fatalf("Expected a 1m event, got %v", idle)
+	}
+	monitor.onIdleEvent(time.Minute)
+	if monitor.wasIdle {
+		t.Fatal("Expected the present-idle watch not to end the session")
+	}
+	bus.goIdle(t, 5*time.Minute)
+	monitor.onIdleEvent(nextIdleEvent(t, monitor.idleEvents))
+	if !monitor.wasIdle {
+		t.Fatal("Expected the idle watch to pause tracking")
+	}
+	if bus.activeCalled != 1 {
+		t.Errorf("Expected one user-active watch for both idle watches, got %d", bus.activeCalled)
+	}
+
+	// The user comes back to a different window
+	backend.windows = []*common.MutterWindow{{WmClass: "firefox", Title: "Docs"}}
+	bus.comeBack(t)
+	if idle := nextIdleEvent(t, monitor.idleEvents); idle != 0 {
+		t.Fatalf("Expected a return event, got %v", idle)
+	}
+	monitor.onIdleEvent(0)
+	if monitor.wasIdle || len(notifier.windows) != 2 || notifier.windows[1] != "firefox" {
+		t.Errorf("Expected the return to resume tracking right away, got %v", notifier.windows)
+	}
+
+	monitor.IdleWatcher.Close()
+	if bus.removed != 2 || !bus.closed {
+		t.Errorf("Expected Close to remove both idle watches and close the bus, removed %d", bus.removed)
+	}
+}
+
+// TestIdleWatchFallsBackToPolling tests that a failed watch registration leaves the monitor
+// polling GetIdletime as before
+func TestIdleWatchFallsBackToPolling(t *testing.T) {
+	bus := newFakeIdleMonitor()
+	bus.addErr = errors.New("org.freedesktop.DBus.Error.UnknownMethod")
+	backend := &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code", Title: "main.go"}}}
+	monitor := newMonitor(NewActivityTracker(), backend)
+	monitor.Notifier = &recordingNotifier{}
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	monitor.IdleWatcher = &idleMonitorWatcher{connect: func() (idleMonitorBus, error) { return bus, nil }}
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if monitor.watchIdle() || monitor.idleEvents != nil {
+		t.Fatal("Expected the failed registration to leave the monitor polling")
+	}
+	if !bus.closed {
+		t.Error("Expected the bus to be closed after the failed registration")
+	}
+
+	backend.idle = monitor.IdleThreshold
+	monitor.onPoll()
+	if !monitor.wasIdle {
+		t.Error("Expected the polled idle time to pause tracking")
+	}
+}
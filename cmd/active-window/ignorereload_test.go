@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// newPurgeTracker returns a tracker with an ignore file that doesn't exist yet, completed
+// slack and firefox sessions, one consumed by an early cursor, and slack focused for the last
+// 10 minutes
+func newPurgeTracker(t *testing.T, ignorePath string) (*ActivityTracker, *tracker.Cursor) {
+	t.Helper()
+	activityTracker := tracker.New()
+	activityTracker.SetIgnoreFile(ignorePath)
+	early := activityTracker.NewCursor("RescueTime")
+	activityTracker.NewCursor("webhook")
+	start := time.Now().Add(-time.Hour)
+
+	runSession(activityTracker, "slack", start, start.Add(10*time.Minute))
+	activityTracker.Consume(early)
+	runSession(activityTracker, "firefox", start.Add(10*time.Minute), start.Add(30*time.Minute))
+	runSession(activityTracker, "Slack", start.Add(30*time.Minute), start.Add(50*time.Minute))
+	startSessionAt(activityTracker, "slack", "", start.Add(50*time.Minute))
+	return activityTracker, early
+}
+
+// TestReloadIgnoreListPurges tests that the monitor picks up an entry added to the ignore
+// file while running and, with RetroactiveIgnore, purges the RescueTime queue as well
+func TestReloadIgnoreListPurges(t *testing.T) {
+	for _, retroactive := range []bool{false, true} {
+		ignorePath := filepath.Join(t.TempDir(), ".rescuetime-ignore")
+		tracker, early := newPurgeTracker(t, ignorePath)
+		monitor := newMonitor(tracker, &fakeBackend{})
+		monitor.RetroactiveIgnore = retroactive
+		queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+		monitor.Queues = []*submissionQueue{queue}
+		queue.Append(map[string]ActivitySummary{
+			"slack":   {AppClass: "slack", TotalDuration: 5 * time.Minute},
+			"firefox": {AppClass: "firefox", TotalDuration: 5 * time.Minute},
+		})
+
+		monitor.reloadIgnoreList() // no file yet
+		if err := os.WriteFile(ignorePath, []byte("Slack\n"), 0644); err != nil {
+			t.Fatalf("Failed to write ignore file: %v", err)
+		}
+		monitor.reloadIgnoreList()
+		monitor.reloadIgnoreList() // unchanged: nothing more to do
+
+		tracker.EndCurrentSession()
+		submission := tracker.Consume(early)
+		_, keptStored := submission.Completed["Slack"]
+		queued, err := queue.Take()
+		if err != nil {
+			t.Fatalf("Take failed: %v", err)
+		}
+		if _, ok := submission.Completed["slack"]; ok {
+			t.Errorf("retroactive=%v: expected the active slack session to be dropped", retroactive)
+		}
+		if keptStored == retroactive {
+			t.Errorf("retroactive=%v: stored Slack session kept = %v", retroactive, keptStored)
+		}
+		if wantQueued := map[bool]int{false: 2, true: 1}[retroactive]; len(queued) != wantQueued {
+			t.Errorf("retroactive=%v: expected %d queued summaries left, got %d", retroactive, wantQueued, len(queued))
+		}
+	}
+}
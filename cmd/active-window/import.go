@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/fatih/color"
+)
+
+// Import configuration
+const (
+	importBatchSize = 500 // sessions per database transaction when streaming an import
+
+	importFormatActivityWatch = "activitywatch"
+	importFormatRescueTime    = "rescuetime"
+)
+
+// Source attribution values stored with imported sessions
+const (
+	sourceActivityWatch = "activitywatch"
+	sourceRescueTime    = "rescuetime-export"
+	sourceCSV           = "csv"
+)
+
+// rescueTimeExportTimeLayout is the "Date" column format of RescueTime analytic data exports
+const rescueTimeExportTimeLayout = "2006-01-02T15:04:05"
+
+// awEvent is a single event in an ActivityWatch bucket export
+type awEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Duration  float64   `json:"duration"` // seconds
+	Data      struct {
+		App   string `json:"app"`
+		Title string `json:"title"`
+	} `json:"data"`
+}
+
+// sessionImporter stores streamed sessions in batches so large exports never
+// have to be held in memory
+type sessionImporter struct {
+	client     *postgres.Client
+	batch      []postgres.ActivitySession
+	inserted   int
+	duplicates int
+	skipped    int
+}
+
+// add queues a session and flushes the batch when it is full
+func (si *sessionImporter) add(session postgres.ActivitySession) error {
+	si.batch = append(si.batch, session)
+	if len(si.batch) >= importBatchSize {
+		return si.flush()
+	}
+	return nil
+}
+
+// flush stores the queued sessions
+func (si *sessionImporter) flush() error {
+	if len(si.batch) == 0 {
+		return nil
+	}
+	result, err := si.client.ImportSessions(si.batch)
+	if err != nil {
+		return err
+	}
+	for _, skip := range result.Skipped {
+		session := si.batch[skip.Index]
+		logging.Warning("Skipped %s at %s: %v", session.AppClass, session.StartTime.Format(time.RFC3339), skip.Err)
+	}
+	si.inserted += result.Inserted
+	si.duplicates += result.Duplicates
+	si.skipped += len(result.Skipped)
+	logging.Verbose("Imported batch: %d inserted, %d duplicates, %d skipped", result.Inserted, result.Duplicates, len(result.Skipped))
+	si.batch = si.batch[:0]
+	return nil
+}
+
+// detectImportFormat picks the import format from the file extension when not given explicitly
+func detectImportFormat(path, format string) (string, error) {
+	if format != "" {
+		switch format {
+		case importFormatActivityWatch, importFormatRescueTime:
+			return format, nil
+		}
+		return "", fmt.Errorf("unknown import format %q\nValid options: %s, %s", format, importFormatActivityWatch, importFormatRescueTime)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return importFormatActivityWatch, nil
+	case ".csv":
+		return importFormatRescueTime, nil
+	}
+	return "", fmt.Errorf("cannot detect import format from %q\nSet -import-format to %s or %s", path, importFormatActivityWatch, importFormatRescueTime)
+}
+
+// parseActivityWatchExport streams window events from an ActivityWatch bucket export
+// ({"buckets": {"<id>": {"events": [...]}}}) and calls fn for each one. Events without
+// an app (e.g. AFK bucket events) and zero-length heartbeats are skipped and counted.
+func parseActivityWatchExport(r io.Reader, fn func(postgres.ActivitySession) error) (int, error) {
+	dec := json.NewDecoder(r)
+	skipped := 0
+
+	var walk func() error
+	decodeEvents := func() error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("expected events array, got %v", tok)
+		}
+		for dec.More() {
+			var event awEvent
+			if err := dec.Decode(&event); err != nil {
+				return fmt.Errorf("invalid event: %v", err)
+			}
+			if event.Data.App == "" || event.Duration <= 0 {
+				skipped++
+				continue
+			}
+			duration := time.Duration(event.Duration * float64(time.Second))
+			if err := fn(postgres.ActivitySession{
+				StartTime:   event.Timestamp,
+				EndTime:     event.Timestamp.Add(duration),
+				AppClass:    event.Data.App,
+				WindowTitle: event.Data.Title,
+				Duration:    duration,
+				Source:      sourceActivityWatch,
+			}); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token() // closing ']'
+		return err
+	}
+
+	walk = func() error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if key, _ := keyTok.(string); key == "events" {
+					err = decodeEvents()
+				} else {
+					err = walk()
+				}
+				if err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token() // closing '}'
+			return err
+		case json.Delim('['):
+			for dec.More() {
+				if err := walk(); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token() // closing ']'
+			return err
+		}
+		return nil // scalar value
+	}
+
+	if err := walk(); err != nil {
+		return skipped, fmt.Errorf("failed to parse ActivityWatch export: %v", err)
+	}
+	return skipped, nil
+}
+
+// parseRescueTimeExport streams rows of a RescueTime analytic data CSV export
+// ("Date","Time Spent (seconds)","Number of People","Activity","Category","Productivity")
+// and calls fn for each one. Rows that can't be parsed are returned as skipped rows.
+func parseRescueTimeExport(r io.Reader, fn func(postgres.ActivitySession) error) ([]csvSkippedRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := map[string]int{"date": -1, "time spent (seconds)": -1, "activity": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	for name, idx := range columns {
+		if idx < 0 {
+			return nil, fmt.Errorf("RescueTime export is missing the %q column (found: %s)", name, strings.Join(header, ", "))
+		}
+	}
+
+	var skipped []csvSkippedRow
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: err})
+			continue
+		}
+		if len(record) <= columns["date"] || len(record) <= columns["time spent (seconds)"] || len(record) <= columns["activity"] {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("row has %d columns", len(record))})
+			continue
+		}
+
+		start, err := time.ParseInLocation(rescueTimeExportTimeLayout, strings.TrimSpace(record[columns["date"]]), dayLocation)
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("invalid date: %v", err)})
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(record[columns["time spent (seconds)"]]))
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("invalid time spent: %v", err)})
+			continue
+		}
+		duration := time.Duration(seconds) * time.Second
+
+		if err := fn(postgres.ActivitySession{
+			StartTime: start,
+			EndTime:   start.Add(duration),
+			AppClass:  strings.TrimSpace(record[columns["activity"]]),
+			Duration:  duration,
+			Source:    sourceRescueTime,
+		}); err != nil {
+			return skipped, err
+		}
+	}
+
+	return skipped, nil
+}
+
+// importHistory imports an ActivityWatch or RescueTime export into PostgreSQL
+func importHistory(path, format string, postgresClient *postgres.Client) error {
+	format, err := detectImportFormat(path, format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %v", err)
+	}
+	defer file.Close()
+
+	importer := &sessionImporter{client: postgresClient}
+	var parseSkipped int
+
+	logging.Info("Importing %s export from %s", format, path)
+	switch format {
+	case importFormatActivityWatch:
+		parseSkipped, err = parseActivityWatchExport(file, importer.add)
+	case importFormatRescueTime:
+		var rows []csvSkippedRow
+		rows, err = parseRescueTimeExport(file, importer.add)
+		for _, row := range rows {
+			logging.Warning("Skipped CSV line %d: %v", row.Line, row.Err)
+		}
+		parseSkipped = len(rows)
+	}
+	if err == nil {
+		err = importer.flush()
+	}
+
+	color.New(color.FgCyan, color.Bold).Printf("\n=== Import Summary ===\n")
+	color.Green("Imported: %d sessions\n", importer.inserted)
+	if importer.duplicates > 0 {
+		color.Yellow("Duplicates: %d (already stored)\n", importer.duplicates)
+	}
+	if total := importer.skipped + parseSkipped; total > 0 {
+		color.Yellow("Skipped: %d\n", total)
+	}
+
+	if err != nil {
+		return fmt.Errorf("import stopped: %v (batches before the error were stored; re-running skips them as duplicates)", err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/fatih/color"
+)
+
+// Default CSV layout for -import-csv: start,end,app_class,window_title
+const defaultCSVColumns = "start=0,end=1,app_class=2,window_title=3"
+
+// csvFields lists the session fields that can be mapped to CSV columns
+var csvFields = []string{"start", "end", "app_class", "window_title"}
+
+// csvTimeLayouts are tried in order when -csv-time-format is not set
+var csvTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+}
+
+// csvColumnMapping maps a session field to either a column index or a header name
+type csvColumnMapping map[string]string
+
+// csvSkippedRow records a CSV row that could not be imported
+type csvSkippedRow struct {
+	Line int
+	Err  error
+}
+
+// parseCSVColumnMapping parses a -csv-columns value such as
+// "start=Start Time,end=End Time,app_class=Application,window_title=Title" or "start=0,end=1,app_class=2".
+// Values may be 0-based column indexes or header names. window_title is optional.
+func parseCSVColumnMapping(spec string) (csvColumnMapping, error) {
+	mapping := make(csvColumnMapping)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q (expected field=column)", part)
+		}
+		field := strings.TrimSpace(kv[0])
+		valid := false
+		for _, f := range csvFields {
+			if f == field {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown field %q in column mapping (valid fields: %s)", field, strings.Join(csvFields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(kv[1])
+	}
+
+	for _, required := range []string{"start", "end", "app_class"} {
+		if _, ok := mapping[required]; !ok {
+			return nil, fmt.Errorf("column mapping is missing required field %q", required)
+		}
+	}
+	return mapping, nil
+}
+
+// resolve converts the mapping into column indexes, using the header row for named columns
+func (m csvColumnMapping) resolve(header []string) (map[string]int, error) {
+	indexes := make(map[string]int, len(m))
+	for field, column := range m {
+		if idx, err := strconv.Atoi(column); err == nil {
+			if idx < 0 {
+				return nil, fmt.Errorf("column index for %s must be non-negative, got %d", field, idx)
+			}
+			indexes[field] = idx
+			continue
+		}
+
+		if header == nil {
+			return nil, fmt.Errorf("column %q for %s is a name but the CSV has no header row (use an index or drop -csv-no-header)", column, field)
+		}
+		found := false
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				indexes[field] = i
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column %q for %s not found in CSV header: %s", column, field, strings.Join(header, ", "))
+		}
+	}
+	return indexes, nil
+}
+
+// parseCSVTime parses a timestamp using the given layout, or the common layouts if empty
+func parseCSVTime(value, layout string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if layout != "" {
+		return time.ParseInLocation(layout, value, dayLocation)
+	}
+	for _, l := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(l, value, dayLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time format %q (set -csv-time-format)", value)
+}
+
+// parseCSVSessions reads historical sessions from CSV. Rows that can't be parsed are
+// returned as skipped rows instead of failing the whole import.
+func parseCSVSessions(r io.Reader, mapping csvColumnMapping, hasHeader bool, timeLayout string) ([]postgres.ActivitySession, []csvSkippedRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // layouts vary, column count is checked per row
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	line := 0
+	if hasHeader {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CSV header: %v", err)
+		}
+		header = record
+		line++
+	}
+
+	indexes, err := mapping.resolve(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sessions []postgres.ActivitySession
+	var skipped []csvSkippedRow
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: err})
+			continue
+		}
+
+		column := func(field string) (string, bool) {
+			idx, ok := indexes[field]
+			if !ok || idx >= len(record) {
+				return "", false
+			}
+			return record[idx], true
+		}
+
+		startValue, okStart := column("start")
+		endValue, okEnd := column("end")
+		appClass, okApp := column("app_class")
+		if !okStart || !okEnd || !okApp {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("row has %d columns, missing mapped column", len(record))})
+			continue
+		}
+
+		start, err := parseCSVTime(startValue, timeLayout)
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("invalid start: %v", err)})
+			continue
+		}
+		end, err := parseCSVTime(endValue, timeLayout)
+		if err != nil {
+			skipped = append(skipped, csvSkippedRow{Line: line, Err: fmt.Errorf("invalid end: %v", err)})
+			continue
+		}
+
+		windowTitle, _ := column("window_title")
+
+		sessions = append(sessions, postgres.ActivitySession{
+			StartTime:   start,
+			EndTime:     end,
+			AppClass:    strings.TrimSpace(appClass),
+			WindowTitle: windowTitle,
+			Duration:    end.Sub(start),
+			Source:      sourceCSV,
+		})
+	}
+
+	return sessions, skipped, nil
+}
+
+// importCSVToPostgres loads historical sessions from a CSV file into PostgreSQL
+func importCSVToPostgres(path string, postgresClient *postgres.Client, columns string, hasHeader bool, timeLayout string) error {
+	mapping, err := parseCSVColumnMapping(columns)
+	if err != nil {
+		return fmt.Errorf("invalid -csv-columns: %v\n\nExample: -csv-columns \"start=Start,end=End,app_class=Application,window_title=Title\"", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %v", err)
+	}
+	defer file.Close()
+
+	sessions, skippedRows, err := parseCSVSessions(file, mapping, hasHeader, timeLayout)
+	if err != nil {
+		return err
+	}
+
+	logging.Info("Parsed %d sessions from %s", len(sessions), path)
+
+	result, err := postgresClient.ImportSessions(sessions)
+	if err != nil {
+		return fmt.Errorf("import failed (no rows were stored): %v", err)
+	}
+
+	for _, row := range skippedRows {
+		logging.Warning("Skipped CSV line %d: %v", row.Line, row.Err)
+	}
+	for _, skip := range result.Skipped {
+		session := sessions[skip.Index]
+		logging.Warning("Skipped %s at %s: %v", session.AppClass, session.StartTime.Format(time.RFC3339), skip.Err)
+	}
+
+	color.New(color.FgCyan, color.Bold).Printf("\n=== CSV Import Summary ===\n")
+	color.Green("Imported: %d sessions\n", result.Inserted)
+	if result.Duplicates > 0 {
+		color.Yellow("Duplicates: %d (already stored)\n", result.Duplicates)
+	}
+	if total := len(skippedRows) + len(result.Skipped); total > 0 {
+		color.Yellow("Skipped: %d rows\n", total)
+	}
+	return nil
+}
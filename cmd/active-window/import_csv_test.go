@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseCSVColumnMapping tests parsing of the -csv-columns flag
+func TestParseCSVColumnMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "default layout", spec: defaultCSVColumns, wantErr: false},
+		{name: "header names", spec: "start=Start Time,end=End Time,app_class=Application", wantErr: false},
+		{name: "missing end", spec: "start=0,app_class=2", wantErr: true},
+		{name: "unknown field", spec: "start=0,end=1,app_class=2,category=4", wantErr: true},
+		{name: "malformed pair", spec: "start=0,end,app_class=2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCSVColumnMapping(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCSVColumnMapping(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestParseCSVSessions tests parsing rows into sessions and reporting skipped rows
+func TestParseCSVSessions(t *testing.T) {
+	input := `Application,Title,Start Time,End Time
+Firefox,GitHub,2025-10-29 10:00:00,2025-10-29 10:30:00
+Code,main.go,2025-10-29T10:30:00Z,2025-10-29T11:00:00Z
+Terminal,bash,not-a-time,2025-10-29 11:10:00
+Short
+`
+	mapping, err := parseCSVColumnMapping("start=Start Time,end=End Time,app_class=Application,window_title=Title")
+	if err != nil {
+		t.Fatalf("Failed to parse mapping: %v", err)
+	}
+
+	sessions, skipped, err := parseCSVSessions(strings.NewReader(input), mapping, true, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	if sessions[0].AppClass != "Firefox" || sessions[0].WindowTitle != "GitHub" {
+		t.Errorf("Unexpected first session: %+v", sessions[0])
+	}
+	if sessions[0].Duration != 30*time.Minute {
+		t.Errorf("Expected duration 30m, got %v", sessions[0].Duration)
+	}
+	if sessions[1].Duration != 30*time.Minute {
+		t.Errorf("Expected duration 30m for RFC3339 row, got %v", sessions[1].Duration)
+	}
+
+	if len(skipped) != 2 {
+		t.Fatalf("Expected 2 skipped rows, got %d: %v", len(skipped), skipped)
+	}
+	if skipped[0].Line != 4 || skipped[1].Line != 5 {
+		t.Errorf("Expected skipped lines 4 and 5, got %d and %d", skipped[0].Line, skipped[1].Line)
+	}
+}
+
+// TestParseCSVSessionsNoHeader tests positional mapping without a header row
+func TestParseCSVSessionsNoHeader(t *testing.T) {
+	input := "2025-10-29 10:00:00,2025-10-29 10:15:00,Firefox,GitHub\n"
+
+	mapping, _ := parseCSVColumnMapping(defaultCSVColumns)
+	sessions, skipped, err := parseCSVSessions(strings.NewReader(input), mapping, false, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sessions) != 1 || len(skipped) != 0 {
+		t.Fatalf("Expected 1 session and 0 skipped, got %d and %d", len(sessions), len(skipped))
+	}
+
+	// Named columns require a header row
+	named, _ := parseCSVColumnMapping("start=Start,end=End,app_class=App")
+	if _, _, err := parseCSVSessions(strings.NewReader(input), named, false, ""); err == nil {
+		t.Error("Expected error for named columns without header")
+	}
+}
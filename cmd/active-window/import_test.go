@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+)
+
+// TestParseActivityWatchExport tests streaming events from an ActivityWatch bucket export fixture
+func TestParseActivityWatchExport(t *testing.T) {
+	file, err := os.Open("testdata/activitywatch-export.json")
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	var sessions []postgres.ActivitySession
+	skipped, err := parseActivityWatchExport(file, func(s postgres.ActivitySession) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Zero-length heartbeat and AFK event are skipped
+	if skipped != 2 {
+		t.Errorf("Expected 2 skipped events, got %d", skipped)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+
+	first := sessions[0]
+	if first.AppClass != "firefox" || first.WindowTitle != "GitHub - Projects" {
+		t.Errorf("Unexpected first session: %+v", first)
+	}
+	if first.Duration != 600500*time.Millisecond {
+		t.Errorf("Expected duration 10m0.5s, got %v", first.Duration)
+	}
+	if !first.EndTime.Equal(first.StartTime.Add(first.Duration)) {
+		t.Errorf("EndTime should equal StartTime + Duration")
+	}
+	for _, s := range sessions {
+		if s.Source != sourceActivityWatch {
+			t.Errorf("Expected source %q, got %q", sourceActivityWatch, s.Source)
+		}
+	}
+}
+
+// TestParseRescueTimeExport tests parsing a RescueTime analytic data CSV export fixture
+func TestParseRescueTimeExport(t *testing.T) {
+	file, err := os.Open("testdata/rescuetime-export.csv")
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	var sessions []postgres.ActivitySession
+	skipped, err := parseRescueTimeExport(file, func(s postgres.ActivitySession) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	if len(skipped) != 1 || skipped[0].Line != 4 {
+		t.Errorf("Expected line 4 to be skipped, got %v", skipped)
+	}
+	if sessions[1].AppClass != "github.com" || sessions[1].Duration != 4*time.Minute {
+		t.Errorf("Unexpected second session: %+v", sessions[1])
+	}
+	if sessions[0].Source != sourceRescueTime {
+		t.Errorf("Expected source %q, got %q", sourceRescueTime, sessions[0].Source)
+	}
+}
+
+// TestDetectImportFormat tests format detection from file extensions
+func TestDetectImportFormat(t *testing.T) {
+	tests := []struct {
+		path    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{path: "aw-buckets.json", want: importFormatActivityWatch},
+		{path: "rescuetime.CSV", want: importFormatRescueTime},
+		{path: "export.txt", format: importFormatRescueTime, want: importFormatRescueTime},
+		{path: "export.txt", wantErr: true},
+		{path: "export.json", format: "toggl", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := detectImportFormat(tt.path, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("detectImportFormat(%q, %q) error = %v, wantErr %v", tt.path, tt.format, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("detectImportFormat(%q, %q) = %q, want %q", tt.path, tt.format, got, tt.want)
+		}
+	}
+}
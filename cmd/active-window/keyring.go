@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// keyringService is the Secret Service attribute the RescueTime login is stored under:
+//
+//	secret-tool store --label "RescueTime email" service rescuetime-linux-mutter key email
+//	secret-tool store --label "RescueTime password" service rescuetime-linux-mutter key password
+const keyringService = "rescuetime-linux-mutter"
+
+// keyringCredentials is the -refresh-keys rescuetime.CredentialStore: it reads the login from
+// the desktop keyring with secret-tool (libsecret) and writes refreshed keys to the .env file
+type keyringCredentials struct {
+	lookup  func(key string) (string, error) // secretToolLookup, replaced in tests
+	envPath string
+}
+
+func newKeyringCredentials(envPath string) *keyringCredentials {
+	return &keyringCredentials{lookup: secretToolLookup, envPath: envPath}
+}
+
+// secretToolLookup returns the keyring secret stored for key under keyringService
+func secretToolLookup(key string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "key", key)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret-tool lookup %s failed: %v %s", key, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (k *keyringCredentials) Login() (string, string, error) {
+	email, err := k.lookup("email")
+	if err != nil {
+		return "", "", err
+	}
+	password, err := k.lookup("password")
+	if err != nil {
+		return "", "", err
+	}
+	if email == "" || password == "" {
+		return "", "", fmt.Errorf("no RescueTime login in the keyring (service %s, keys email and password)", keyringService)
+	}
+	return email, password, nil
+}
+
+func (k *keyringCredentials) SaveKeys(keys *rescuetime.ActivationResponse) error {
+	values := map[string]string{"RESCUE_TIME_ACCOUNT_KEY": keys.AccountKey}
+	if keys.DataKey != "" {
+		values["RESCUE_TIME_DATA_KEY"] = keys.DataKey
+	}
+	return setEnvValues(k.envPath, values)
+}
+
+// setEnvValues replaces the KEY=value lines for values in a .env file, appending keys it
+// doesn't have yet and keeping everything else (comments, other keys) as written.
+// The file is replaced atomically; os.CreateTemp makes it owner-only, as it holds credentials.
+func setEnvValues(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var out bytes.Buffer
+	written := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, _, found := strings.Cut(strings.TrimSpace(line), "=")
+		key = strings.TrimSpace(key)
+		if value, ok := values[key]; found && ok && !written[key] {
+			line = key + "=" + value
+			written[key] = true
+		} else if found && ok {
+			continue // A later duplicate would override the new value when loading
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var missing []string
+	for key := range values {
+		if !written[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		out.WriteString(key + "=" + values[key] + "\n")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".env-*")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %v", path, err)
+	}
+	return nil
+}
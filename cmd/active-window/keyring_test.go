@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestKeyringCredentialsLogin tests reading the login through an injected lookup
+func TestKeyringCredentialsLogin(t *testing.T) {
+	secrets := map[string]string{"email": "jane@example.com", "password": "secret"}
+	creds := &keyringCredentials{lookup: func(key string) (string, error) {
+		if secret, ok := secrets[key]; ok {
+			return secret, nil
+		}
+		return "", errors.New("not found")
+	}}
+
+	email, password, err := creds.Login()
+	if err != nil || email != "jane@example.com" || password != "secret" {
+		t.Errorf("Expected the stored login, got %q %q %v", email, password, err)
+	}
+
+	secrets["password"] = ""
+	if _, _, err := creds.Login(); err == nil {
+		t.Error("Expected an error for an empty password")
+	}
+	delete(secrets, "email")
+	if _, _, err := creds.Login(); err == nil {
+		t.Error("Expected the lookup error for a missing email")
+	}
+}
+
+// TestSaveKeysUpdatesEnvFile tests that refreshed keys replace their lines in .env, other
+// lines are kept, missing keys are appended and the file stays private
+func TestSaveKeysUpdatesEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("# RescueTime\nRESCUE_TIME_API_KEY=legacy\nRESCUE_TIME_ACCOUNT_KEY = old\nRESCUE_TIME_ACCOUNT_KEY=older\n"), 0644)
+
+	creds := &keyringCredentials{envPath: path}
+	if err := creds.SaveKeys(&rescuetime.ActivationResponse{AccountKey: "new-account", DataKey: "new-data"}); err != nil {
+		t.Fatalf("SaveKeys failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "# RescueTime\nRESCUE_TIME_API_KEY=legacy\nRESCUE_TIME_ACCOUNT_KEY=new-account\nRESCUE_TIME_DATA_KEY=new-data\n"
+	if string(data) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a 0600 .env, got %v", info.Mode().Perm())
+	}
+
+	// Activate doesn't return a data key yet; the saved one is kept
+	creds.SaveKeys(&rescuetime.ActivationResponse{AccountKey: "newer-account"})
+	data, _ = os.ReadFile(path)
+	want = "# RescueTime\nRESCUE_TIME_API_KEY=legacy\nRESCUE_TIME_ACCOUNT_KEY=newer-account\nRESCUE_TIME_DATA_KEY=new-data\n"
+	if string(data) != want {
+		t.Errorf("Expected the data key kept:\n%s\ngot:\n%s", want, data)
+	}
+}
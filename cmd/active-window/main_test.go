@@ -1,8 +1,11 @@
 package main
 
 import (
+"math/rand"
 "testing"
 "time"
+
+"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
 )
 
 // TestConstants verifies configuration constants are set correctly
@@ -20,3 +23,102 @@ if defaultIdleThreshold != 5*time.Minute {
 t.Errorf("defaultIdleThreshold should be 5m, got %v", defaultIdleThreshold)
 }
 }
+
+// TestBuiltinKeyFuncs tests each built-in grouping strategy
+func TestBuiltinKeyFuncs(t *testing.T) {
+	chromeApp := &common.MutterWindow{WmClass: "Google-chrome", WmClassInstance: "crx_calendar", Title: "Calendar"}
+	firefox := &common.MutterWindow{WmClass: "firefox", WmClassInstance: "Navigator", Title: "GitHub"}
+	sameInstance := &common.MutterWindow{WmClass: "kitty", WmClassInstance: "kitty", Title: "bash"}
+	unknown := &common.MutterWindow{WmClass: "MyCustomApp", Title: "Main"}
+
+	tests := []struct {
+		name    string
+		keyFunc KeyFunc
+		window  *common.MutterWindow
+		want    string
+	}{
+		{name: "class", keyFunc: keyFuncs["class"], window: firefox, want: "firefox"},
+		{name: "instance with distinct instance", keyFunc: keyFuncs["instance"], window: chromeApp, want: "Google-chrome/crx_calendar"},
+		{name: "instance equal to class", keyFunc: keyFuncs["instance"], window: sameInstance, want: "kitty"},
+		{name: "instance missing", keyFunc: keyFuncs["instance"], window: unknown, want: "MyCustomApp"},
+		{name: "category known app", keyFunc: keyFuncs["category"], window: firefox, want: "Browsing"},
+		{name: "category case-insensitive", keyFunc: keyFuncs["category"], window: chromeApp, want: "Browsing"},
+		{name: "category unknown app falls back to class", keyFunc: keyFuncs["category"], window: unknown, want: "MyCustomApp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.keyFunc(tt.window); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseRetention tests parsing of the -prune retention window
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "90d", want: 90 * 24 * time.Hour},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "0d", wantErr: true},
+		{value: "-5d", wantErr: true},
+		{value: "ninety", wantErr: true},
+		{value: "d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRetention(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRetention(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseRetention(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestParseWebhookMetadata tests parsing of the -webhook-metadata flag
+func TestParseWebhookMetadata(t *testing.T) {
+	metadata, err := parseWebhookMetadata("environment=work, machine = laptop,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(metadata) != 2 || metadata["environment"] != "work" || metadata["machine"] != "laptop" {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+
+	for _, spec := range []string{"environment", "=work"} {
+		if _, err := parseWebhookMetadata(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+// TestNextSubmitInterval tests that jittered intervals stay within ±jitter of the base
+func TestNextSubmitInterval(t *testing.T) {
+	base := 15 * time.Minute
+	jitter := 2 * time.Minute
+
+	if got := nextSubmitInterval(base, 0, rand.Int63n); got != base {
+		t.Errorf("Zero jitter should return base %v, got %v", base, got)
+	}
+
+	// Extremes of the random source map to the bounds
+	lowest := nextSubmitInterval(base, jitter, func(int64) int64 { return 0 })
+	highest := nextSubmitInterval(base, jitter, func(n int64) int64 { return n - 1 })
+	if lowest != base-jitter || highest != base+jitter {
+		t.Errorf("Expected bounds %v..%v, got %v..%v", base-jitter, base+jitter, lowest, highest)
+	}
+
+	for i := 0; i < 1000; i++ {
+		got := nextSubmitInterval(base, jitter, rand.Int63n)
+		if got < base-jitter || got > base+jitter {
+			t.Fatalf("Interval %v outside %v..%v", got, base-jitter, base+jitter)
+		}
+	}
+}
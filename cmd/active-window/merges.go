@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// loadMerges reads a -merge-apps file mapping a target name to the WmClasses merged into it,
+// e.g. {"Chat": ["slack", "discord", "element"]}. The result maps each lowercased source
+// class to its target, since WmClass casing varies between applications and versions.
+func loadMerges(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merge file: %v", err)
+	}
+
+	var targets map[string][]string
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("failed to parse merge file %s%s: %v\n\nExpected a JSON object of target name to WmClass list, e.g. {\"Chat\": [\"slack\", \"discord\"]}", path, jsonErrorPosition(data, err), err)
+	}
+
+	// Sorted so a conflict is reported the same way every run
+	names := make([]string, 0, len(targets))
+	for target := range targets {
+		names = append(names, target)
+	}
+	sort.Strings(names)
+
+	merges := make(map[string]string)
+	for _, target := range names {
+		if strings.TrimSpace(target) == "" {
+			return nil, fmt.Errorf("merge file %s has an empty target name", path)
+		}
+		for _, source := range targets[target] {
+			key := strings.ToLower(strings.TrimSpace(source))
+			if key == "" {
+				continue
+			}
+			if existing, ok := merges[key]; ok && existing != target {
+				return nil, fmt.Errorf("merge file %s maps %q to both %q and %q; each WmClass can only merge into one target", path, source, existing, target)
+			}
+			merges[key] = target
+		}
+	}
+	return merges, nil
+}
+
+// jsonErrorPosition returns " at line L, column C" for a json.Unmarshal error that carries
+// a byte offset into data, or "" if it doesn't
+func jsonErrorPosition(data []byte, err error) string {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return ""
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf(" at line %d, column %d", line, column)
+}
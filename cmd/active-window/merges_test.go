@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeMerges writes a -merge-apps file into a temp dir and returns its path
+func writeMerges(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "merges.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestMergedChatAppsSummary tests that three chat apps roll into one summary
+func TestMergedChatAppsSummary(t *testing.T) {
+	merges, err := loadMerges(writeMerges(t, `{"Chat": ["slack", "discord", "Element"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewActivityTracker()
+	tracker.SetMerges(merges)
+
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	runSession(tracker, "Slack", start, start.Add(10*time.Minute))
+	runSession(tracker, "code", start.Add(10*time.Minute), start.Add(20*time.Minute))
+	runSession(tracker, "discord", start.Add(20*time.Minute), start.Add(25*time.Minute))
+	runSession(tracker, "code", start.Add(25*time.Minute), start.Add(30*time.Minute))
+	runSession(tracker, "element", start.Add(30*time.Minute), start.Add(45*time.Minute))
+
+	summaries := tracker.GetActivitySummaries()
+	if len(summaries) != 2 {
+		t.Fatalf("Expected Chat and code summaries, got %v", summaries)
+	}
+	chat, ok := summaries["Chat"]
+	if !ok {
+		t.Fatalf("Expected a Chat summary, got %v", summaries)
+	}
+	if chat.AppClass != "Chat" || chat.SessionCount != 3 || chat.TotalDuration != 30*time.Minute {
+		t.Errorf("Expected Chat with 3 sessions totalling 30m, got %+v", chat)
+	}
+
+	// Sessions keep the raw application class
+	for _, session := range tracker.GetSessions() {
+		if session.AppClass == "Chat" {
+			t.Errorf("Expected session AppClass to remain the WmClass, got %q", session.AppClass)
+		}
+	}
+}
+
+// TestLoadMergesErrors tests that malformed and conflicting merge files are rejected
+func TestLoadMergesErrors(t *testing.T) {
+	tests := map[string]string{
+		"not json":     `["slack"]`,
+		"conflict":     `{"Chat": ["slack"], "Work": ["Slack"]}`,
+		"empty target": `{" ": ["slack"]}`,
+	}
+	for name, content := range tests {
+		if _, err := loadMerges(writeMerges(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err := loadMerges(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read merge file") {
+		t.Errorf("Expected a read error for a missing file, got %v", err)
+	}
+}
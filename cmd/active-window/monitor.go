@@ -0,0 +1,1091 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/webhook"
+	"github.com/fatih/color"
+)
+
+// WindowBackend reports the focused window, the open windows and how long the user has been idle
+type WindowBackend interface {
+	ActiveWindow() (*common.MutterWindow, error)
+	ListWindows() ([]common.MutterWindow, error) // every open window, for -background-apps
+	IdleTime() (time.Duration, error)
+}
+
+// dbusBackend is the WindowBackend for a GNOME session (FocusedWindow extension + IdleMonitor)
+type dbusBackend struct{}
+
+func (dbusBackend) ActiveWindow() (*common.MutterWindow, error) { return getActiveWindow() }
+func (dbusBackend) ListWindows() ([]common.MutterWindow, error) { return getWindowList() }
+func (dbusBackend) IdleTime() (time.Duration, error)            { return getIdleTime() }
+
+// Clock provides the current time and tickers, so tests can drive the monitor loop
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of *time.Ticker the monitor loop uses
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                   { return time.Now() }
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+// realTicker adapts *time.Ticker to Ticker
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Submitter is an output backend (RescueTime, PostgreSQL, webhook) the monitor submits to
+type Submitter interface {
+	// Submit sends one interval's data; it should stop when ctx ends. If it couldn't deliver
+	// everything it returns an error and the part still to deliver, which the monitor hands it
+	// again before the next snapshot (nil if there's nothing to retry, e.g. it was queued).
+	Submit(ctx context.Context, submission Submission) (*Submission, error)
+	// Close flushes and releases the backend at shutdown, within ctx where it can
+	Close(ctx context.Context) error
+	// Name identifies the backend in log messages
+	Name() string
+}
+
+// intervalSubmitter is a Submitter with its own submission interval (-postgres-interval,
+// -webhook-interval); 0 submits every Monitor.SubmitInterval like the others
+type intervalSubmitter interface {
+	Interval() time.Duration
+}
+
+// rescueTimeSubmitter submits completed summaries to RescueTime, retrying summaries queued
+// by a previous run first. Unsent summaries are queued by the client on Close; ones
+// RescueTime couldn't be reached for are returned to retry.
+type rescueTimeSubmitter struct {
+	client *rescuetime.Client
+	queue  *submissionQueue
+	log    *submissionLog // -submission-log, if set
+
+	// With -accounts there is one submitter per account, each sending the summaries accept
+	// routes to it; account is "" for the default account and accept nil takes everything
+	account string
+	accept  func(ActivitySummary) bool
+
+	// keyMu guards newKey, an API key from a reload that the next Submit or Close hands to
+	// client, which a Submit still running may be using
+	keyMu  sync.Mutex
+	newKey string
+}
+
+func (s *rescueTimeSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	s.applyAPIKey()
+	if !submission.Final && s.queue != nil {
+		// Retry anything left over from a previous shutdown first
+		s.record(submitQueuedActivities(ctx, s.queue, s.client))
+	}
+	completed := submission.Completed
+	if s.accept != nil {
+		completed = filterSummaries(completed, s.accept)
+	}
+	result := s.client.SubmitActivitiesResult(ctx, completed)
+	s.record(result)
+	unsent := result.Unsent
+
+	// Cut off by the backend timeout: retry on the next tick (at shutdown, Close queues them)
+	if len(unsent) > 0 && !submission.Final && s.queue != nil {
+		if err := s.queue.Append(unsent); err != nil {
+			logging.Error("Failed to queue %d unsent activities: %v", len(unsent), err)
+		}
+	}
+
+	// RescueTime couldn't be reached: the monitor hands them back on the next tick, or at
+	// shutdown, with no next tick, the queue keeps them for the next run
+	failed := result.Failed
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	if !submission.Final {
+		return &Submission{Completed: failed}, fmt.Errorf("%d activities not submitted, retrying on the next tick", len(failed))
+	}
+	if s.queue == nil {
+		return nil, fmt.Errorf("%d activities not submitted and no queue is configured", len(failed))
+	}
+	if err := s.queue.Append(failed); err != nil {
+		return nil, fmt.Errorf("failed to queue %d activities that weren't submitted: %v", len(failed), err)
+	}
+	return nil, fmt.Errorf("%d activities not submitted, queued for the next run", len(failed))
+}
+
+// record appends a submission's outcomes to the -submission-log history
+func (s *rescueTimeSubmitter) record(result rescuetime.SubmissionResult) {
+	if s.log == nil {
+		return
+	}
+	if err := s.log.Append(time.Now(), s.account, result); err != nil {
+		logging.Warning("%v", err)
+	}
+}
+
+// usesAPIKey reports whether s sends with RESCUE_TIME_API_KEY; -accounts accounts have their own
+func (s *rescueTimeSubmitter) usesAPIKey() bool { return s.account == "" }
+
+// setAPIKey hands a reloaded API key to the next Submit or Close
+func (s *rescueTimeSubmitter) setAPIKey(key string) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.newKey = key
+}
+
+// applyAPIKey switches the client to the key setAPIKey was given, if any
+func (s *rescueTimeSubmitter) applyAPIKey() {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if s.newKey != "" {
+		s.client.APIKey = s.newKey
+		s.newKey = ""
+	}
+}
+
+// queueSummaries appends the summaries s accepts to its offline queue
+func (s *rescueTimeSubmitter) queueSummaries(summaries map[string]ActivitySummary) error {
+	if s.accept != nil {
+		summaries = filterSummaries(summaries, s.accept)
+	}
+	if s.queue == nil {
+		return fmt.Errorf("no submission queue")
+	}
+	return s.queue.Append(summaries)
+}
+
+func (s *rescueTimeSubmitter) Close(ctx context.Context) error {
+	s.applyAPIKey()
+	return s.client.Close(ctx)
+}
+func (s *rescueTimeSubmitter) Name() string {
+	if s.account != "" {
+		return "RescueTime (" + s.account + ")"
+	}
+	return "RescueTime"
+}
+
+// postgresSubmitter stores sessions and summaries, including the active session, in PostgreSQL
+type postgresSubmitter struct {
+	client   *postgres.Client
+	interval time.Duration
+}
+
+func (s *postgresSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	summaries, sessions := submitActivitiesToPostgres(ctx, s.client, submission.All, submission.Sessions)
+	if len(summaries) == 0 && len(sessions) == 0 {
+		return nil, nil
+	}
+	unsent := &Submission{All: summaries, Sessions: sessions, Final: submission.Final}
+	return unsent, fmt.Errorf("%d summaries and %d sessions not stored", len(summaries), len(sessions))
+}
+
+func (s *postgresSubmitter) Close(context.Context) error { return s.client.Close() }
+func (s *postgresSubmitter) Name() string                { return "PostgreSQL" }
+func (s *postgresSubmitter) Interval() time.Duration     { return s.interval }
+
+// webhookSubmitter sends sessions and summaries, including the active session, to a webhook
+type webhookSubmitter struct {
+	client   *webhook.Client
+	interval time.Duration
+}
+
+func (s *webhookSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	if err := submitActivitiesToWebhook(s.client, submission.All, submission.Sessions); err != nil {
+		return &submission, err
+	}
+	return nil, nil
+}
+
+func (s *webhookSubmitter) Close(context.Context) error { return s.client.Close() }
+func (s *webhookSubmitter) Name() string                { return "webhook" }
+func (s *webhookSubmitter) Interval() time.Duration     { return s.interval }
+
+// Notifier shows the user what the monitor is doing
+type Notifier interface {
+	// WindowChanged reports a newly focused window
+	WindowChanged(tracker *ActivityTracker, window *common.MutterWindow)
+	// Preview shows what would be submitted to RescueTime in dry-run mode
+	Preview(summaries map[string]ActivitySummary)
+	// Summary reports the tracked activity at shutdown
+	Summary(tracker *ActivityTracker)
+}
+
+// consoleNotifier is the Notifier writing to the terminal (or -log-file)
+type consoleNotifier struct{}
+
+func (consoleNotifier) WindowChanged(tracker *ActivityTracker, window *common.MutterWindow) {
+	// Verbose only, and not with -quiet
+	logging.Activity("%s", formatWindowOutput(tracker, window.Title, window.WmClass))
+}
+func (consoleNotifier) Preview(summaries map[string]ActivitySummary) { previewSubmission(summaries) }
+func (consoleNotifier) Summary(tracker *ActivityTracker)             { printActivitySummary(tracker) }
+
+// Monitor is the -track/-monitor loop: it polls the focused window into the tracker and
+// hands the tracked data to each submitter every SubmitInterval (or its own Interval) and
+// at shutdown. Run drives it from tickers; tests can call onPoll, onSubmitTick,
+// onScheduleTick and onShutdown directly.
+type Monitor struct {
+	Tracker    *ActivityTracker
+	Backend    WindowBackend
+	Clock      Clock
+	Submitters []Submitter
+	Notifier   Notifier
+	Changes    *windowChangeFilter
+	Power      DisplayPowerSource // nothing is tracked while it reports the display off; nil disables
+	Digest     *digestNotifier    // posts today's totals to Slack/Discord (-notify); nil disables
+	State      statePublisher     // mirrors the focused window and today's totals to MQTT (-mqtt); nil disables
+
+	PollInterval    time.Duration
+	SubmitInterval  time.Duration
+	SubmitJitter    time.Duration // randomize each interval by up to ± this; 0 keeps it fixed
+	Submit          bool          // submit every SubmitInterval and at shutdown
+	DryRun          bool          // preview RescueTime submissions instead of submitting (takes precedence over Submit)
+	SavePath        string        // write summaries here after each interval and at shutdown; "" disables
+	DailyTotalsPath string        // where today's totals are saved; "" disables
+	AppStatsPath    string        // where per-application statistics are saved; "" disables
+	ResumePath      string        // where the active session is saved at shutdown to resume it; "" disables
+	ResumeWindow    time.Duration // how soon after shutdown a restart resumes the saved session
+	IdleThreshold   time.Duration
+	ShutdownTimeout time.Duration // bound on the final submission
+	BackendTimeout  time.Duration // bound on each submitter's share of a submission
+	SkipPopups      bool          // ignore focus changes to tiny transient windows
+	FocusGrace      time.Duration // a newly focused window must keep focus this long to start a session; 0 starts it at once
+
+	// IdleWatcher, if set, reports idle and active transitions instead of IdleTime being
+	// polled (if its watches can't be registered, the monitor polls after all). A
+	// PresentIdleThreshold below IdleThreshold is watched too, for ObserveIdle.
+	IdleWatcher          IdleWatcher
+	PresentIdleThreshold time.Duration
+
+	// HousekeepingInterval is how often onHousekeeping compacts state and logs its size; 0 disables
+	HousekeepingInterval time.Duration
+
+	// MinBatchDuration skips a RescueTime destination's tick when it has less than this to
+	// submit, leaving the sessions for its next tick; 0 disables
+	MinBatchDuration time.Duration
+
+	// RetroactiveIgnore also purges stored sessions and Queues entries of an application added
+	// to the ignore list while running; otherwise only its active session is dropped
+	RetroactiveIgnore bool
+	Queues            []*submissionQueue // RescueTime's offline queues, one per -accounts account
+
+	// Reloads (SIGHUP) re-reads the API key with ReloadAPIKey and revalidates the
+	// configuration; while it's invalid, RescueTime destinations are held back. nil disables.
+	Reloads      <-chan os.Signal
+	ReloadAPIKey func() (string, error)
+	Alert        func(summary, body string) // desktop notification when submission pauses or resumes; nil disables
+	Status       func(status string)        // the status line, e.g. systemctl status; nil disables
+
+	Commands <-chan controlCommand // lines from the control socket (-control-socket); nil disables
+
+	randInt63n   func(int64) int64 // rand.Int63n, for the submit jitter
+	destinations []*destination    // built from Submitters on first use, see destinationList
+	wasIdle      bool
+	idleEvents   <-chan time.Duration // IdleWatcher's events; nil while polling IdleTime
+	idleSince    time.Time            // when input stopped, going by idleEvents; zero while active
+	displayOff   bool
+	stateCleared bool          // State shows nothing focused, so the next window is published even if unchanged
+	pending      *pendingFocus // newly focused window waiting out FocusGrace; nil if none
+	configErr    error         // why the last reload's configuration is invalid; nil if valid
+	noList       bool          // the extension has no List method, so observeSecondary stopped asking
+
+	// busy holds the indexes (in Submitters) of submitters with a Submit call still running,
+	// which can outlive BackendTimeout if it ignores its context; they are skipped until it returns
+	busyMu sync.Mutex
+	busy   map[int]bool
+}
+
+// destination is one Submitter with its own interval and its own cursor over the tracker's
+// completed sessions, so each backend gets every session exactly once at its own pace.
+// In dry-run the only destination is the RescueTime preview, with a nil submitter.
+type destination struct {
+	name      string
+	submitter Submitter
+	index     int // in Monitor.Submitters, for the busy check
+	interval  time.Duration
+	cursor    *tracker.Cursor
+
+	// retry holds what Submit couldn't deliver, snapshots whose Submit panicked, and those the
+	// watchdog held back after BackendTimeout, handed over again before the next one (at most
+	// maxRetrySnapshots). Only the goroutine holding the destination busy touches it.
+	retry []Submission
+
+	// overlaps counts ticks skipped because the previous Submit was still running
+	overlaps int
+}
+
+// maxRetrySnapshots bounds a destination's retry snapshots: a day of 15-minute submissions
+const maxRetrySnapshots = 96
+
+// submitSchedule is one submit ticker and the destinations that are due when it fires
+type submitSchedule struct {
+	interval     time.Duration
+	ticker       Ticker
+	destinations []*destination
+}
+
+// submitOutcome is how one submitter's share of a submission went
+type submitOutcome struct {
+	Name     string
+	Duration time.Duration
+	Err      error // nil on success; the recovered panic, timeout, or skip otherwise
+}
+
+// newMonitor creates a Monitor with the real clock and console output and no submitters.
+// Callers fill in the intervals and options.
+func newMonitor(tracker *ActivityTracker, backend WindowBackend) *Monitor {
+	return &Monitor{
+		Tracker:         tracker,
+		Backend:         backend,
+		Clock:           realClock{},
+		Notifier:        consoleNotifier{},
+		Changes:         newWindowChangeFilter(0, false),
+		PollInterval:    defaultPollInterval,
+		SubmitInterval:  defaultSubmitInterval,
+		IdleThreshold:   defaultIdleThreshold,
+		ShutdownTimeout: defaultShutdownTimeout,
+		BackendTimeout:  defaultBackendTimeout,
+		DailyTotalsPath: defaultDailyTotalsPath,
+		AppStatsPath:    defaultAppStatsPath,
+		randInt63n:      rand.Int63n,
+		Status:          systemdStatus,
+
+		HousekeepingInterval: defaultHousekeepingInterval,
+	}
+}
+
+// Run starts tracking the focused window and loops until ctx is cancelled (e.g. by
+// SIGINT/SIGTERM), then submits the remaining data and closes the submitters.
+// It only returns an error if the first window can't be read.
+func (m *Monitor) Run(ctx context.Context) error {
+	if err := m.start(); err != nil {
+		return err
+	}
+
+	pollTicker := m.Clock.NewTicker(m.PollInterval)
+	defer pollTicker.Stop()
+
+	if m.watchIdle() {
+		defer m.IdleWatcher.Close()
+	}
+
+	var schedules []*submitSchedule
+	if m.Submit || m.DryRun {
+		schedules = m.newSchedules()
+		for _, schedule := range schedules {
+			defer schedule.ticker.Stop()
+		}
+		if m.DryRun {
+			logging.Info("DRY-RUN mode: will show what would be submitted every %v (no actual API calls)", m.SubmitInterval)
+		} else {
+			for _, schedule := range schedules {
+				names := make([]string, len(schedule.destinations))
+				for i, dest := range schedule.destinations {
+					names[i] = dest.name
+				}
+				logging.Info("API submission enabled: will submit to %s every %v", strings.Join(names, ", "), schedule.interval)
+			}
+		}
+		if m.SubmitJitter > 0 {
+			logging.Verbose("Submit interval jitter: ±%v", m.SubmitJitter)
+		}
+	}
+	m.publishStatus()
+
+	// A nil channel never fires, so housekeeping can be off
+	var housekeeping <-chan time.Time
+	if m.HousekeepingInterval > 0 {
+		housekeepingTicker := m.Clock.NewTicker(m.HousekeepingInterval)
+		defer housekeepingTicker.Stop()
+		housekeeping = housekeepingTicker.C()
+	}
+
+	// There's a submit ticker per distinct interval, so select over them with reflect;
+	// every handler still runs on this goroutine
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pollTicker.C())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.idleEvents)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.Reloads)}, // a nil channel never fires
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.Commands)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(housekeeping)},
+	}
+	fixed := len(cases)
+	for _, schedule := range schedules {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(schedule.ticker.C())})
+	}
+
+	for {
+		chosen, received, _ := reflect.Select(cases)
+		switch chosen {
+		case 0:
+			color.Yellow("\nShutting down window monitor...")
+			logging.Info("Received shutdown signal")
+			m.onShutdown()
+			return nil
+		case 1:
+			m.onPoll()
+		case 2:
+			m.onIdleEvent(received.Interface().(time.Duration))
+		case 3:
+			m.onReload()
+		case 4:
+			command := received.Interface().(controlCommand)
+			command.reply <- m.onCommand(command.line)
+		case 5:
+			m.onHousekeeping()
+		default:
+			m.onScheduleTick(schedules[chosen-fixed])
+		}
+	}
+}
+
+// destinationList returns the destinations, registering a tracker cursor for each on
+// first use. A Submitter without its own Interval uses SubmitInterval.
+func (m *Monitor) destinationList() []*destination {
+	if m.destinations != nil {
+		return m.destinations
+	}
+
+	m.destinations = make([]*destination, 0, len(m.Submitters))
+	if m.DryRun {
+		m.destinations = append(m.destinations, &destination{name: "preview", index: -1, interval: m.SubmitInterval})
+	} else {
+		for i, submitter := range m.Submitters {
+			interval := m.SubmitInterval
+			if own, ok := submitter.(intervalSubmitter); ok && own.Interval() > 0 {
+				interval = own.Interval()
+			}
+			m.destinations = append(m.destinations, &destination{name: submitter.Name(), submitter: submitter, index: i, interval: interval})
+		}
+	}
+	for _, dest := range m.destinations {
+		dest.cursor = m.Tracker.NewCursor(dest.name)
+	}
+	return m.destinations
+}
+
+// newSchedules starts one submit ticker per distinct destination interval, in the order
+// the intervals first appear
+func (m *Monitor) newSchedules() []*submitSchedule {
+	var schedules []*submitSchedule
+	byInterval := make(map[time.Duration]*submitSchedule)
+	for _, dest := range m.destinationList() {
+		schedule, ok := byInterval[dest.interval]
+		if !ok {
+			schedule = &submitSchedule{
+				interval: dest.interval,
+				ticker:   m.Clock.NewTicker(nextSubmitInterval(dest.interval, m.SubmitJitter, m.randInt63n)),
+			}
+			byInterval[dest.interval] = schedule
+			schedules = append(schedules, schedule)
+		}
+		schedule.destinations = append(schedule.destinations, dest)
+	}
+	return schedules
+}
+
+// start reads the initial window and starts its session unless the user is idle
+func (m *Monitor) start() error {
+	m.Tracker.SetPollInterval(m.PollInterval)
+	window, err := m.activeWindow()
+	if err != nil {
+		return err
+	}
+
+	m.publishTotals()
+
+	if m.checkDisplayOff() {
+		logging.Verbose("Display is off, not starting tracking yet")
+		return nil
+	}
+
+	idleTime, err := m.Backend.IdleTime()
+	if err != nil {
+		logging.Error("Error getting initial idle time: %v", err)
+		// Continue anyway, will retry on next poll
+	} else if idleTime >= m.IdleThreshold {
+		m.wasIdle = true
+		logging.Verbose("User is currently idle (%v), not starting tracking yet", idleTime)
+		return nil
+	}
+
+	// Start the initial session only if not idle, continuing the one in progress at
+	// shutdown when this is a quick restart onto the same window
+	if m.ResumePath != "" && m.Tracker.ResumeSession(m.ResumePath, window, m.Clock.Now(), m.ResumeWindow) {
+		logging.Verbose("Resumed the session for %s from before the restart", window.WmClass)
+	} else {
+		m.Tracker.StartWindowSession(window)
+	}
+	m.Tracker.ObservePoll()
+	m.Changes.Reset(window)
+	m.Notifier.WindowChanged(m.Tracker, window)
+	m.publishWindow(window)
+	return nil
+}
+
+// reloadIgnoreList picks up entries added to the ignore file since the last poll (e.g. by
+// the ignoreApplication tool) and purges the newly ignored applications' tracked time
+func (m *Monitor) reloadIgnoreList() {
+	added, err := m.Tracker.ReloadIgnoredApps()
+	if err != nil {
+		logging.Warning("Failed to reload the ignore list: %v", err)
+		return
+	}
+
+	for _, appClass := range added {
+		purged := m.Tracker.PurgeApp(appClass, m.RetroactiveIgnore)
+		if !m.RetroactiveIgnore {
+			logging.Info("Now ignoring %s (its current session is dropped, earlier sessions are kept)", appClass)
+			continue
+		}
+
+		queued := 0
+		for _, queue := range m.Queues {
+			n, err := queue.Purge(func(summary ActivitySummary) bool { return m.Tracker.IsIgnored(summary.AppClass) })
+			if err != nil {
+				logging.Warning("Failed to purge %s from the submission queue: %v", appClass, err)
+			}
+			queued += n
+		}
+		logging.Info("Now ignoring %s: purged %d stored sessions and %d queued summaries", appClass, purged, queued)
+	}
+}
+
+// activeWindow reads the focused window from the backend, naming a window without a
+// WmClass with resolveAmbiguousClass so it isn't tracked under ""
+func (m *Monitor) activeWindow() (*common.MutterWindow, error) {
+	window, err := m.Backend.ActiveWindow()
+	if err != nil {
+		return nil, err
+	}
+	window.WmClass = resolveAmbiguousClass(window)
+	return window, nil
+}
+
+// onPoll handles the idle state and starts a session when the focused window changes
+func (m *Monitor) onPoll() {
+	m.reloadIgnoreList()
+	m.Digest.onPoll(m.Tracker, m.Clock.Now())
+
+	// A powered-off display means nobody is there, whatever window is focused
+	if m.checkDisplayOff() {
+		return
+	}
+
+	// Check idle status first
+	idleTime, err := m.idleTime()
+	if err != nil {
+		logging.Debug("Error getting idle time: %v", err)
+		// Continue with window tracking even if idle detection fails
+	} else {
+		// Splits the focused session into active and idle-but-present time
+		m.Tracker.ObserveIdle(m.Clock.Now(), idleTime)
+		isIdle := idleTime >= m.IdleThreshold
+
+		// Handle idle state transitions
+		if isIdle && !m.wasIdle {
+			// User just became idle - end current session
+			logging.Info("User is idle, pausing tracking")
+			m.Tracker.EndCurrentSession()
+			m.Tracker.EndSecondarySession()
+			m.pending = nil
+			m.publishWindow(nil)
+			m.wasIdle = true
+			return // Skip window tracking while idle
+		} else if !isIdle && m.wasIdle {
+			// User returned from idle - resume tracking
+			logging.Info("User returned from idle, resuming tracking")
+			m.wasIdle = false
+			// Will start new session below if window info is available
+		} else if isIdle {
+			// Still idle - skip this poll
+			return
+		}
+	}
+
+	window, err := m.activeWindow()
+	if err != nil {
+		// Don't spam errors, just skip this poll
+		logging.Debug("Error getting window: %v", err)
+		return
+	}
+
+	// Tooltips and menus keep the current session going
+	if m.SkipPopups && common.IsTinyPopup(window) {
+		logging.Debug("Skipping tiny popup: %s (%s, %dx%d)", window.Title, window.WmClass, window.Width, window.Height)
+		m.Tracker.ObservePoll()
+		return
+	}
+
+	// Check if the application or window title changed (debounced, and rate-checked for a flapping extension)
+	now := m.Clock.Now()
+	obs := m.Changes.Observe(window, now)
+	if obs.FlapStarted {
+		logging.Warning("Window changed %d times in the last minute; the FocusedWindow extension may be misbehaving (limit: -max-switch-rate %d)", m.Changes.Rate(now), m.Changes.maxPerMinute)
+		if m.Changes.suppress {
+			logging.Warning("Pausing tracking until window changes slow down")
+			m.Tracker.EndCurrentSession()
+			m.Tracker.EndSecondarySession()
+			m.pending = nil
+		}
+	}
+	if obs.FlapEnded {
+		logging.Info("Window change rate is back to normal")
+	}
+	if obs.Changed {
+		// The new window/app starts a session once it has held focus for FocusGrace
+		m.holdFocus(window, now)
+	}
+	if focused := m.settleFocus(now); focused != nil {
+		m.Notifier.WindowChanged(m.Tracker, focused)
+		m.publishWindow(focused)
+	} else if m.stateCleared && m.pending == nil {
+		m.publishWindow(window)
+	}
+	if m.pending != nil {
+		m.pending.polls++
+	} else {
+		m.Tracker.ObservePoll()
+		m.Tracker.ObserveGeometry(now, window)
+	}
+	m.observeSecondary(now)
+	m.checkCoverage(now)
+}
+
+// observeSecondary lists the open windows for the tracker's -background-apps, so one that's
+// open behind the focused window accrues a secondary session. A failed listing keeps the
+// secondary session as it is. An extension without List gets one warning and isn't asked
+// again, since it won't grow the method until it's updated and GNOME Shell restarts.
+func (m *Monitor) observeSecondary(now time.Time) {
+	if !m.Tracker.TracksSecondary() || m.noList {
+		return
+	}
+	windows, err := m.Backend.ListWindows()
+	if errors.Is(err, common.ErrListUnsupported) {
+		logging.Warning("%v; -background-apps is off until restart", err)
+		m.noList = true
+		m.Tracker.EndSecondarySession()
+		return
+	}
+	if err != nil {
+		logging.Debug("Error listing windows: %v", err)
+		return
+	}
+	m.Tracker.ObserveOpenWindows(now, windows)
+}
+
+// checkCoverage warns when so many polls have failed to read the window during the current
+// session that its duration is an estimate, and when they succeed again
+func (m *Monitor) checkCoverage(now time.Time) {
+	coverage, expected := m.Tracker.CurrentCoverage(now)
+	dropped, recovered := m.Changes.ObserveCoverage(coverage, expected)
+	if dropped {
+		logging.Warning("Only %.0f%% of polls read the focused window during this session; the window source may be failing intermittently (-min-coverage %.2f)", coverage*100, m.Changes.minCoverage)
+	}
+	if recovered {
+		logging.Info("Polls are reading the focused window reliably again")
+	}
+}
+
+// checkDisplayOff asks Power whether the display is off, ending the current session when it
+// turns off. When it turns back on the next focused window starts a new session. Errors
+// count as on, so a compositor without the property is tracked as before.
+func (m *Monitor) checkDisplayOff() bool {
+	if m.Power == nil {
+		return false
+	}
+	powered, err := m.Power.DisplayPowered()
+	if err != nil {
+		logging.Debug("Error getting display power state: %v", err)
+		powered = true
+	}
+
+	switch {
+	case !powered && !m.displayOff:
+		logging.Info("Display is off, pausing tracking")
+		m.Tracker.EndCurrentSession()
+		m.Tracker.EndSecondarySession()
+		m.pending = nil
+		m.publishWindow(nil)
+		m.displayOff = true
+	case powered && m.displayOff:
+		logging.Info("Display is on, resuming tracking")
+		m.displayOff = false
+		// Forget the last window so the focused one starts a session even if it's the same
+		m.Changes.Reset(&common.MutterWindow{})
+	}
+	return m.displayOff
+}
+
+// onSubmitTick submits (or previews) to every destination at once, as when they all share
+// SubmitInterval
+func (m *Monitor) onSubmitTick() {
+	m.submitDue(m.destinationList())
+}
+
+// onScheduleTick submits to the destinations due on schedule's interval
+func (m *Monitor) onScheduleTick(schedule *submitSchedule) {
+	m.submitDue(schedule.destinations)
+
+	// Re-arm with a fresh random offset so submissions don't fall into a fixed pattern
+	if m.SubmitJitter > 0 {
+		next := nextSubmitInterval(schedule.interval, m.SubmitJitter, m.randInt63n)
+		schedule.ticker.Reset(next)
+		logging.Debug("Next submission in %v", next)
+	}
+}
+
+// submitDue hands each of destinations (or the dry-run preview) the sessions it hasn't
+// consumed yet, then drops the sessions every destination has consumed
+func (m *Monitor) submitDue(destinations []*destination) {
+	if m.DryRun {
+		logging.Info("DRY-RUN: Submission preview")
+		m.previewAll(destinations)
+	} else {
+		release, hold := m.releasable(destinations)
+		m.submitAll(context.Background(), m.skipTrivialBatches(release), false)
+		for _, dest := range hold {
+			logging.Warning("%s submission paused, holding its sessions: %s", dest.name, firstLine(m.configErr.Error()))
+		}
+	}
+
+	// Save all summaries including active sessions for debugging
+	m.saveSummaries(m.Tracker.GetActivitySummaries(), logging.Verbose)
+
+	// Only after submission, so a crash mid-submission replays them (today's totals are kept)
+	m.Tracker.CompactSessions()
+	m.saveDailyTotals()
+	logging.Verbose("Today: %s", formatDailyTotals(m.Tracker.GetDailyTotals()))
+	m.Digest.onSubmission(m.Tracker, m.Clock.Now())
+	m.publishTotals()
+	logging.Debug("Window changes in the last minute: %d", m.Changes.Rate(m.Clock.Now()))
+}
+
+// skipTrivialBatches drops the RescueTime destinations with less than MinBatchDuration to
+// submit. Their cursors don't move, so the sessions go with their next tick, and the final
+// submission sends whatever is left. A destination with nothing at all still gets its tick,
+// which retries its offline queue.
+func (m *Monitor) skipTrivialBatches(destinations []*destination) []*destination {
+	if m.MinBatchDuration <= 0 {
+		return destinations
+	}
+	now := m.Clock.Now()
+	due := make([]*destination, 0, len(destinations))
+	for _, dest := range destinations {
+		if _, ok := dest.submitter.(apiKeySubmitter); ok {
+			if pending := m.Tracker.PendingDuration(dest.cursor, now); pending > 0 && pending < m.MinBatchDuration {
+				logging.Verbose("%s: only %v to submit (-min-batch-duration %v), carrying it to the next submission", dest.name, pending.Round(time.Second), m.MinBatchDuration)
+				continue
+			}
+		}
+		due = append(due, dest)
+	}
+	return due
+}
+
+// previewAll shows what the destinations would have submitted to RescueTime
+func (m *Monitor) previewAll(destinations []*destination) {
+	for _, submission := range m.Tracker.DrainCompletedSessions(m.Clock.Now(), cursors(destinations)...) {
+		m.Notifier.Preview(submission.Completed)
+	}
+}
+
+// cursors returns the destinations' cursors, in order
+func cursors(destinations []*destination) []*tracker.Cursor {
+	list := make([]*tracker.Cursor, len(destinations))
+	for i, dest := range destinations {
+		list[i] = dest.cursor
+	}
+	return list
+}
+
+// onShutdown ends the current session, submits (or previews) everything left, closes the
+// submitters and prints the activity summary
+func (m *Monitor) onShutdown() {
+	if m.ResumePath != "" {
+		if err := m.Tracker.SaveResumeState(m.ResumePath, m.Clock.Now()); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+	m.Tracker.EndCurrentSession()
+	m.Tracker.EndSecondarySession()
+	m.saveDailyTotals()
+
+	// After EndCurrentSession(), all sessions are completed; each destination gets
+	// whatever it hasn't consumed yet
+	summaries := m.Tracker.GetActivitySummaries()
+	if m.DryRun {
+		logging.Info("DRY-RUN: Final submission preview")
+		m.previewAll(m.destinationList())
+	} else if m.Submit {
+		logging.Info("Submitting final data before shutdown (timeout %v)...", m.ShutdownTimeout)
+		destinations, hold := m.releasable(m.destinationList())
+		m.queueHeld(hold)
+		submitOnShutdown(m.ShutdownTimeout, func(ctx context.Context) {
+			m.submitAll(ctx, destinations, true)
+		})
+	}
+
+	m.saveSummaries(summaries, logging.Info)
+	m.reportOverlaps()
+
+	// Flush and close every backend (unsent RescueTime data goes to the queue)
+	closeSubmitters(m.Submitters)
+
+	m.Notifier.Summary(m.Tracker)
+	m.Digest.onShutdown(m.Tracker, m.Clock.Now())
+	m.closeState()
+
+	if m.Submit || m.DryRun {
+		// Keep what a destination still lacks in the session log, for ReplayWAL on the next
+		// start; the rest was handed off (unsent RescueTime data is queued)
+		m.keepUndelivered()
+		m.Tracker.CompactSessions()
+	} else {
+		// Nothing is submitted without Submit, so nothing is owed either
+		m.Tracker.ClearCompletedSessions()
+	}
+}
+
+// keepUndelivered rewinds each destination's cursor over the sessions in its retry snapshots,
+// which the final submission couldn't deliver, so CompactSessions keeps them
+func (m *Monitor) keepUndelivered() {
+	for _, dest := range m.destinations {
+		if !m.markBusy(dest.index) {
+			logging.Warning("%s: still submitting at exit, its last snapshot may be lost", dest.name)
+			continue
+		}
+		var sessions []ActivitySession
+		for _, submission := range dest.retry {
+			sessions = append(sessions, submission.Sessions...)
+		}
+		dest.retry = nil
+		m.clearBusy(dest.index)
+		if len(sessions) == 0 {
+			continue
+		}
+		dropped := m.Tracker.Rewind(dest.cursor, sessions)
+		if dropped > 0 {
+			logging.Error("%s: %d undelivered sessions from earlier submissions are lost", dest.name, dropped)
+		}
+		if kept := len(sessions) - dropped; kept > 0 {
+			logging.Warning("%s: %d sessions were not delivered; with -wal they're submitted again on the next start", dest.name, kept)
+		}
+	}
+}
+
+// reportOverlaps logs how many ticks each destination skipped because its previous
+// submission was still running, a sign BackendTimeout or its interval is too short
+func (m *Monitor) reportOverlaps() {
+	for _, dest := range m.destinations {
+		if dest.overlaps > 0 {
+			logging.Warning("%s: %d submission ticks skipped while the previous submission was still running", dest.name, dest.overlaps)
+		}
+	}
+}
+
+// saveSummaries writes summaries to SavePath if set, reporting success at the given level
+func (m *Monitor) saveSummaries(summaries map[string]ActivitySummary, report func(format string, args ...interface{})) {
+	if m.SavePath == "" {
+		return
+	}
+	if err := saveSummariesToFile(m.SavePath, summaries); err != nil {
+		logging.Error("Failed to save sessions to file: %v", err)
+		return
+	}
+	report("Saved sessions to %s", m.SavePath)
+}
+
+// saveDailyTotals saves today's totals to DailyTotalsPath and the per-application
+// statistics to AppStatsPath, each if set
+func (m *Monitor) saveDailyTotals() {
+	if m.DailyTotalsPath != "" {
+		if err := m.Tracker.SaveDailyTotals(m.DailyTotalsPath); err != nil {
+			logging.Debug("Failed to save daily totals: %v", err)
+		}
+	}
+	if m.AppStatsPath != "" {
+		if err := m.Tracker.SaveAppStats(m.AppStatsPath); err != nil {
+			logging.Debug("Failed to save app stats: %v", err)
+		}
+	}
+}
+
+// submitAll hands each destination what it hasn't consumed at once, each in its own
+// goroutine with its own BackendTimeout and panic recovery, so a wedged or crashing backend
+// (e.g. PostgreSQL down) can't delay RescueTime or take the daemon with it. A destination
+// whose previous Submit is still running is skipped without consuming, so it gets those
+// sessions next time. It returns the outcomes, in destination order, once each submitter
+// has finished or timed out.
+func (m *Monitor) submitAll(ctx context.Context, destinations []*destination, final bool) []submitOutcome {
+	outcomes := make([]submitOutcome, len(destinations))
+	var due []*destination
+	var dueAt []int
+	for i, dest := range destinations {
+		if !m.markBusy(dest.index) {
+			dest.overlaps++
+			outcomes[i] = submitOutcome{Name: dest.name, Err: fmt.Errorf("skipped: the previous submission is still running (%d overlapping ticks so far)", dest.overlaps)}
+			continue
+		}
+		due = append(due, dest)
+		dueAt = append(dueAt, i)
+	}
+	if len(due) == 0 {
+		return outcomes
+	}
+
+	// Drained in one go before any network call, so every destination sees the same
+	// moment and sessions completed while submitting wait for the next drain
+	snapshots := m.Tracker.DrainCompletedSessions(m.Clock.Now(), cursors(due)...)
+
+	var wg sync.WaitGroup
+	for j, dest := range due {
+		snapshot := snapshots[j]
+		snapshot.Final = final
+		submissions := append(dest.retry, snapshot)
+		dest.retry = nil
+
+		wg.Add(1)
+		go func(i int, dest *destination) {
+			defer wg.Done()
+			outcomes[i] = m.submitOne(ctx, dest, submissions)
+		}(dueAt[j], dest)
+	}
+	wg.Wait()
+
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			logging.Error("%s submission failed after %v: %v", outcome.Name, outcome.Duration.Round(time.Millisecond), outcome.Err)
+		} else {
+			logging.Debug("%s submission finished in %v", outcome.Name, outcome.Duration.Round(time.Millisecond))
+		}
+	}
+	return outcomes
+}
+
+// submitOne runs a destination's Submit (already marked busy) over submissions in order,
+// recovering a panic and giving up waiting after BackendTimeout. A Submit that ignores its
+// context keeps running in the background, and the destination is skipped until it returns
+// rather than piling up calls. What Submit reports it couldn't deliver (a failed write, or
+// one cut off by BackendTimeout) is queued on the destination for the next submission,
+// leaving the tracker alone. After a panic, the snapshot that panicked and the ones after it
+// are queued the same way; a snapshot that panics on its retry too is dropped. Once
+// BackendTimeout has passed, the snapshots not handed over yet are queued as well, except in
+// the final submission, where Submit is the last chance to hand them to the backend.
+func (m *Monitor) submitOne(ctx context.Context, dest *destination, submissions []Submission) submitOutcome {
+	outcome := submitOutcome{Name: dest.name}
+	if m.BackendTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.BackendTimeout)
+		defer cancel()
+	}
+
+	retries := len(submissions) - 1 // all but the latest were queued by an earlier failure
+	final := submissions[retries].Final
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		next := 0
+		var undelivered []Submission // what the snapshots submitted so far couldn't deliver
+		var failure error
+		defer func() {
+			err := failure
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				if next < retries {
+					logging.Error("%s: dropping a snapshot of %d sessions that failed twice", dest.name, len(submissions[next].Sessions))
+					next++
+				}
+			} else if next < len(submissions) {
+				logging.Warning("%s: requeued %d snapshots not submitted within %v", dest.name, len(submissions)-next, m.BackendTimeout)
+			}
+			if len(undelivered) > 0 {
+				logging.Warning("%s: requeued what %d snapshots couldn't deliver", dest.name, len(undelivered))
+			}
+			dest.retry = limitRetries(dest.name, append(undelivered, submissions[next:]...))
+			if overrun := time.Since(start) - m.BackendTimeout; m.BackendTimeout > 0 && overrun > 0 {
+				logging.Warning("%s: submission returned %v after the %v timeout", dest.name, overrun.Round(time.Millisecond), m.BackendTimeout)
+			}
+			m.clearBusy(dest.index)
+			done <- err
+		}()
+		for ; next < len(submissions) && (ctx.Err() == nil || final); next++ {
+			// Retries handed over at shutdown get no next tick either
+			submission := submissions[next]
+			submission.Final = submission.Final || final
+			unsent, err := dest.submitter.Submit(ctx, submission)
+			if err != nil {
+				failure = err
+				if unsent != nil {
+					undelivered = append(undelivered, *unsent)
+				}
+			}
+		}
+	}()
+
+	select {
+	case outcome.Err = <-done:
+	case <-ctx.Done():
+		outcome.Err = fmt.Errorf("timed out: %v", ctx.Err())
+	}
+	outcome.Duration = time.Since(start)
+	return outcome
+}
+
+// limitRetries keeps the newest maxRetrySnapshots of a destination's retry snapshots, so a
+// backend that stays down can't grow them without bound
+func limitRetries(name string, retry []Submission) []Submission {
+	if len(retry) <= maxRetrySnapshots {
+		return retry
+	}
+	dropped := 0
+	for _, submission := range retry[:len(retry)-maxRetrySnapshots] {
+		dropped += len(submission.Sessions)
+	}
+	logging.Error("%s: dropping %d sessions in the oldest undelivered snapshots, more than %d are waiting", name, dropped, maxRetrySnapshots)
+	return retry[len(retry)-maxRetrySnapshots:]
+}
+
+// markBusy records that the submitter at index is submitting, returning false if it already was
+func (m *Monitor) markBusy(index int) bool {
+	m.busyMu.Lock()
+	defer m.busyMu.Unlock()
+	if m.busy[index] {
+		return false
+	}
+	if m.busy == nil {
+		m.busy = make(map[int]bool)
+	}
+	m.busy[index] = true
+	return true
+}
+
+// clearBusy records that the submitter at index returned from Submit
+func (m *Monitor) clearBusy(index int) {
+	m.busyMu.Lock()
+	defer m.busyMu.Unlock()
+	delete(m.busy, index)
+}
@@ -0,0 +1,993 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// fakeBackend returns queued windows in order, repeating the last one, and open as the
+// open windows
+type fakeBackend struct {
+	mu      sync.Mutex
+	windows []*common.MutterWindow
+	open    []common.MutterWindow
+	listErr error
+	lists   int
+	idle    time.Duration
+}
+
+func (b *fakeBackend) ActiveWindow() (*common.MutterWindow, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	window := b.windows[0]
+	if len(b.windows) > 1 {
+		b.windows = b.windows[1:]
+	}
+	return window, nil
+}
+
+func (b *fakeBackend) ListWindows() ([]common.MutterWindow, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lists++
+	if b.listErr != nil {
+		return nil, b.listErr
+	}
+	return b.open, nil
+}
+
+func (b *fakeBackend) IdleTime() (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.idle, nil
+}
+
+// tickerClock hands out tickers whose channels the test fires by hand, keyed by interval.
+// Now is the wall clock unless now is set.
+type tickerClock struct {
+	ticks map[time.Duration]chan time.Time
+	now   time.Time
+}
+
+func (c *tickerClock) Now() time.Time {
+	if !c.now.IsZero() {
+		return c.now
+	}
+	return time.Now()
+}
+func (c *tickerClock) NewTicker(d time.Duration) Ticker {
+	ch, ok := c.ticks[d]
+	if !ok {
+		ch = make(chan time.Time) // never fires
+	}
+	return fakeTicker(ch)
+}
+
+type fakeTicker chan time.Time
+
+func (t fakeTicker) C() <-chan time.Time { return t }
+func (t fakeTicker) Reset(time.Duration) {}
+func (t fakeTicker) Stop()               {}
+
+// recordingSubmitter records submissions along with how many completed sessions the
+// tracker still held at the time
+type recordingSubmitter struct {
+	tracker       *ActivityTracker
+	submissions   []Submission
+	sessionCounts []int
+	closed        bool
+}
+
+func (s *recordingSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	s.submissions = append(s.submissions, submission)
+	s.sessionCounts = append(s.sessionCounts, len(s.tracker.GetSessions()))
+	return nil, nil
+}
+
+func (s *recordingSubmitter) Close(context.Context) error { s.closed = true; return nil }
+func (s *recordingSubmitter) Name() string                { return "recording" }
+
+// recordingNotifier records what the monitor shows the user
+type recordingNotifier struct {
+	mu        sync.Mutex
+	windows   []string
+	previews  []map[string]ActivitySummary
+	summaries int
+}
+
+func (n *recordingNotifier) WindowChanged(tracker *ActivityTracker, window *common.MutterWindow) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.windows = append(n.windows, window.WmClass)
+}
+
+func (n *recordingNotifier) Preview(summaries map[string]ActivitySummary) {
+	n.previews = append(n.previews, summaries)
+}
+
+func (n *recordingNotifier) Summary(*ActivityTracker) { n.summaries++ }
+
+// runSession tracks a session of appClass from start to end
+func runSession(tracker *ActivityTracker, appClass string, start, end time.Time) {
+	startSessionAt(tracker, appClass, "", start)
+	endSessionAt(tracker, end)
+}
+
+// startSessionAt starts a session of appClass at start, as if the tracker's clock read start,
+// ending the active session there
+func startSessionAt(tracker *ActivityTracker, appClass, title string, start time.Time) {
+	tracker.SetClock(func() time.Time { return start })
+	defer tracker.SetClock(nil)
+	tracker.StartSession(appClass, title)
+}
+
+// endSessionAt ends the active session at end, as if the tracker's clock read end
+func endSessionAt(tracker *ActivityTracker, end time.Time) {
+	tracker.SetClock(func() time.Time { return end })
+	defer tracker.SetClock(nil)
+	tracker.EndCurrentSession()
+}
+
+// newTestMonitor returns a monitor over a tracker holding two completed sessions, with
+// recording submitters and notifier and nothing written to the working directory
+func newTestMonitor(t *testing.T) (*Monitor, []*recordingSubmitter, *recordingNotifier) {
+	t.Helper()
+	tracker := NewActivityTracker()
+	start := time.Now().Add(-time.Hour)
+	runSession(tracker, "code", start, start.Add(20*time.Minute))
+	runSession(tracker, "firefox", start.Add(20*time.Minute), start.Add(30*time.Minute))
+
+	submitters := []*recordingSubmitter{{tracker: tracker}, {tracker: tracker}}
+	notifier := &recordingNotifier{}
+
+	monitor := newMonitor(tracker, &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code", Title: "main.go"}}})
+	monitor.Notifier = notifier
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	for _, submitter := range submitters {
+		monitor.Submitters = append(monitor.Submitters, submitter)
+	}
+	return monitor, submitters, notifier
+}
+
+// TestSubmitTickSubmitsThenClears tests that every backend gets the interval's data before
+// the completed sessions are cleared
+func TestSubmitTickSubmitsThenClears(t *testing.T) {
+	monitor, submitters, notifier := newTestMonitor(t)
+	monitor.Submit = true
+
+	monitor.onSubmitTick()
+
+	for i, submitter := range submitters {
+		if len(submitter.submissions) != 1 {
+			t.Fatalf("Submitter %d: expected 1 submission, got %d", i, len(submitter.submissions))
+		}
+		if submitter.sessionCounts[0] != 2 {
+			t.Errorf("Submitter %d: expected the 2 sessions to still be tracked when submitting, got %d", i, submitter.sessionCounts[0])
+		}
+		submission := submitter.submissions[0]
+		if len(submission.Completed) != 2 || len(submission.All) != 2 || len(submission.Sessions) != 2 || submission.Final {
+			t.Errorf("Submitter %d: unexpected submission %+v", i, submission)
+		}
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected completed sessions to be cleared after submitting, got %d", len(sessions))
+	}
+	if len(notifier.previews) != 0 {
+		t.Errorf("Expected no preview outside dry-run, got %d", len(notifier.previews))
+	}
+}
+
+// TestSubmitTickDryRun tests that dry-run previews the completed summaries instead of submitting
+func TestSubmitTickDryRun(t *testing.T) {
+	monitor, submitters, notifier := newTestMonitor(t)
+	monitor.Submit = true
+	monitor.DryRun = true
+
+	monitor.onSubmitTick()
+
+	for i, submitter := range submitters {
+		if len(submitter.submissions) != 0 {
+			t.Errorf("Submitter %d: expected no submissions in dry-run, got %d", i, len(submitter.submissions))
+		}
+	}
+	if len(notifier.previews) != 1 || len(notifier.previews[0]) != 2 {
+		t.Fatalf("Expected one preview of 2 summaries, got %v", notifier.previews)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected previewed sessions to be cleared like submitted ones, got %d", len(sessions))
+	}
+}
+
+// TestSubmitTickSavesToFile tests that -save writes the interval's summaries
+func TestSubmitTickSavesToFile(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	monitor.SavePath = filepath.Join(t.TempDir(), "rescuetime-sessions.json")
+
+	monitor.onSubmitTick()
+
+	data, err := os.ReadFile(monitor.SavePath)
+	if err != nil {
+		t.Fatalf("Expected the summaries file to be written: %v", err)
+	}
+	var saved struct {
+		Summaries []struct {
+			AppClass     string `json:"app_class"`
+			SessionCount int    `json:"session_count"`
+		} `json:"summaries"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Failed to parse saved summaries: %v", err)
+	}
+	classes := make(map[string]int)
+	for _, summary := range saved.Summaries {
+		classes[summary.AppClass] = summary.SessionCount
+	}
+	if len(classes) != 2 || classes["code"] != 1 || classes["firefox"] != 1 {
+		t.Errorf("Expected code and firefox summaries, got %+v", saved.Summaries)
+	}
+}
+
+// TestShutdownSubmitsFinalData tests that shutdown submits the active session, closes the
+// backends and prints the summary
+func TestShutdownSubmitsFinalData(t *testing.T) {
+	monitor, submitters, notifier := newTestMonitor(t)
+	monitor.Submit = true
+	startSessionAt(monitor.Tracker, "slack", "", time.Now().Add(-time.Minute))
+
+	monitor.onShutdown()
+
+	for i, submitter := range submitters {
+		if len(submitter.submissions) != 1 || !submitter.submissions[0].Final {
+			t.Fatalf("Submitter %d: expected one final submission, got %+v", i, submitter.submissions)
+		}
+		if _, ok := submitter.submissions[0].Completed["slack"]; !ok {
+			t.Errorf("Submitter %d: expected the ended active session to be submitted", i)
+		}
+		if !submitter.closed {
+			t.Errorf("Submitter %d: expected to be closed", i)
+		}
+	}
+	if notifier.summaries != 1 {
+		t.Errorf("Expected the activity summary to be printed once, got %d", notifier.summaries)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected sessions to be cleared at shutdown, got %d", len(sessions))
+	}
+}
+
+// TestRunPollsAndShutsDown drives Run with a fake clock: a poll picks up a window change and
+// cancelling the context shuts the monitor down
+func TestRunPollsAndShutsDown(t *testing.T) {
+	monitor, submitters, notifier := newTestMonitor(t)
+	monitor.Backend = &fakeBackend{windows: []*common.MutterWindow{
+		{WmClass: "code", Title: "main.go"},
+		{WmClass: "firefox", Title: "Docs"},
+	}}
+	poll := make(chan time.Time)
+	monitor.Clock = &tickerClock{ticks: map[time.Duration]chan time.Time{monitor.PollInterval: poll}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- monitor.Run(ctx) }()
+
+	poll <- time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(notifier.windows) != 2 || notifier.windows[0] != "code" || notifier.windows[1] != "firefox" {
+		t.Errorf("Expected the initial window and one change, got %v", notifier.windows)
+	}
+	for i, submitter := range submitters {
+		if len(submitter.submissions) != 0 {
+			t.Errorf("Submitter %d: expected no submissions without -submit, got %d", i, len(submitter.submissions))
+		}
+		if !submitter.closed {
+			t.Errorf("Submitter %d: expected to be closed at shutdown", i)
+		}
+	}
+}
+
+// panickingSubmitter panics on every submission
+type panickingSubmitter struct{}
+
+func (panickingSubmitter) Submit(context.Context, Submission) (*Submission, error) {
+	panic("database exploded")
+}
+func (panickingSubmitter) Close(context.Context) error { return nil }
+func (panickingSubmitter) Name() string                { return "panicking" }
+
+// hangingSubmitter blocks every submission until release is closed, ignoring its context. A
+// submission whose context ended meanwhile reports the snapshot undelivered, like a request
+// cancelled mid-flight; the rest are recorded.
+type hangingSubmitter struct {
+	release   chan struct{}
+	mu        sync.Mutex
+	delivered []Submission
+}
+
+func (s *hangingSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	<-s.release
+	if err := ctx.Err(); err != nil {
+		return &submission, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered = append(s.delivered, submission)
+	return nil, nil
+}
+func (s *hangingSubmitter) Close(context.Context) error { return nil }
+func (s *hangingSubmitter) Name() string                { return "hanging" }
+
+// TestSubmitIsolatesPanickingBackend tests that a panicking backend doesn't stop the others
+// or the tick
+func TestSubmitIsolatesPanickingBackend(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	monitor.Submit = true
+	monitor.Submitters = []Submitter{submitters[0], panickingSubmitter{}, submitters[1]}
+
+	outcomes := monitor.submitAll(context.Background(), monitor.destinationList(), false)
+	if len(outcomes) != 3 || outcomes[1].Name != "panicking" || outcomes[1].Err == nil {
+		t.Fatalf("Expected the panic to be reported in the second outcome, got %+v", outcomes)
+	}
+	for _, i := range []int{0, 2} {
+		if outcomes[i].Err != nil {
+			t.Errorf("Outcome %d: expected success, got %v", i, outcomes[i].Err)
+		}
+	}
+
+	monitor.onSubmitTick()
+	for i, submitter := range submitters {
+		if len(submitter.submissions) != 2 {
+			t.Errorf("Submitter %d: expected both submissions despite the panicking backend, got %d", i, len(submitter.submissions))
+		}
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected the tick to finish and clear sessions, got %d", len(sessions))
+	}
+}
+
+// flakySubmitter panics on its first submission and records the rest
+type flakySubmitter struct {
+	panicked    bool
+	submissions []Submission
+}
+
+func (s *flakySubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	if !s.panicked {
+		s.panicked = true
+		panic("connection reset")
+	}
+	s.submissions = append(s.submissions, submission)
+	return nil, nil
+}
+
+func (s *flakySubmitter) Close(context.Context) error { return nil }
+func (s *flakySubmitter) Name() string                { return "flaky" }
+
+// TestPanickedSnapshotIsRequeued tests that a snapshot whose Submit panicked is submitted
+// again, before the next one, without going back to the tracker
+func TestPanickedSnapshotIsRequeued(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	flaky := &flakySubmitter{}
+	monitor.Submitters = []Submitter{flaky, submitters[0]}
+
+	monitor.onSubmitTick()
+	start := time.Now().Add(-20 * time.Minute)
+	runSession(monitor.Tracker, "slack", start, start.Add(5*time.Minute))
+	monitor.onSubmitTick()
+
+	if len(flaky.submissions) != 2 {
+		t.Fatalf("Expected the failed snapshot and the next one, got %d submissions", len(flaky.submissions))
+	}
+	if len(flaky.submissions[0].Sessions) != 2 || len(flaky.submissions[1].Sessions) != 1 || flaky.submissions[1].Sessions[0].AppClass != "slack" {
+		t.Errorf("Expected the first snapshot's 2 sessions, then slack, got %+v", flaky.submissions)
+	}
+	if len(submitters[0].submissions) != 2 || len(submitters[0].submissions[1].Sessions) != 1 {
+		t.Errorf("Expected the other backend to get each session once, got %+v", submitters[0].submissions)
+	}
+}
+
+// failingSubmitter fails its first submission, returning only its first session as undelivered,
+// and records the rest
+type failingSubmitter struct {
+	failed      bool
+	submissions []Submission
+}
+
+func (s *failingSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	if !s.failed {
+		s.failed = true
+		return &Submission{Sessions: submission.Sessions[:1]}, errors.New("connection refused")
+	}
+	s.submissions = append(s.submissions, submission)
+	return nil, nil
+}
+
+func (s *failingSubmitter) Close(context.Context) error { return nil }
+func (s *failingSubmitter) Name() string                { return "failing" }
+
+// TestFailedSnapshotIsRequeued tests that the part of a snapshot Submit reports undelivered
+// is submitted again, before the next one, without going back to the tracker
+func TestFailedSnapshotIsRequeued(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	failing := &failingSubmitter{}
+	monitor.Submitters = []Submitter{failing, submitters[0]}
+
+	outcomes := monitor.submitAll(context.Background(), monitor.destinationList(), false)
+	if outcomes[0].Err == nil || outcomes[1].Err != nil {
+		t.Fatalf("Expected only the failing backend to report an error, got %+v", outcomes)
+	}
+	monitor.Tracker.CompactSessions()
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected the tracker to be left alone, got %d sessions", len(sessions))
+	}
+
+	start := time.Now().Add(-20 * time.Minute)
+	runSession(monitor.Tracker, "slack", start, start.Add(5*time.Minute))
+	monitor.onSubmitTick()
+
+	if len(failing.submissions) != 2 {
+		t.Fatalf("Expected the undelivered part and the next snapshot, got %d submissions", len(failing.submissions))
+	}
+	if sessions := failing.submissions[0].Sessions; len(sessions) != 1 || sessions[0].AppClass != "code" {
+		t.Errorf("Expected only the undelivered code session to be retried, got %+v", sessions)
+	}
+	if sessions := failing.submissions[1].Sessions; len(sessions) != 1 || sessions[0].AppClass != "slack" {
+		t.Errorf("Expected the next snapshot to hold slack, got %+v", sessions)
+	}
+	if dest := monitor.destinationList()[0]; len(dest.retry) != 0 {
+		t.Errorf("Expected nothing left to retry once delivered, got %d", len(dest.retry))
+	}
+}
+
+// TestRescueTimeFailureIsRetried tests that summaries RescueTime couldn't be reached for
+// are submitted again on the next tick, rather than dropped with the snapshot
+func TestRescueTimeFailureIsRetried(t *testing.T) {
+	api, client := newFakeRescueTime(t)
+	api.setDown(true)
+	monitor, _, _ := newTestMonitor(t)
+	monitor.Submitters = []Submitter{&rescueTimeSubmitter{client: client}}
+	monitor.Submit = true
+
+	monitor.onSubmitTick()
+	if dest := monitor.destinationList()[0]; len(dest.retry) != 1 || len(dest.retry[0].Completed) != 2 {
+		t.Fatalf("Expected both summaries kept to retry, got %+v", dest.retry)
+	}
+
+	api.setDown(false)
+	monitor.onSubmitTick()
+
+	if accepted := api.acceptedApps(); !reflect.DeepEqual(accepted, []string{"code", "firefox"}) {
+		t.Errorf("Expected code and firefox submitted on the next tick, got %v", accepted)
+	}
+	if dest := monitor.destinationList()[0]; len(dest.retry) != 0 {
+		t.Errorf("Expected nothing left to retry once delivered, got %d", len(dest.retry))
+	}
+}
+
+// downSubmitter fails every submission, returning all of it as undelivered, like a database
+// that's down
+type downSubmitter struct{}
+
+func (downSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	return &submission, errors.New("connection refused")
+}
+func (downSubmitter) Close(context.Context) error { return nil }
+func (downSubmitter) Name() string                { return "down" }
+
+// TestShutdownKeepsUndeliveredSessions tests that sessions the final submission couldn't
+// deliver stay in the WAL for the next start, and that delivered ones don't
+func TestShutdownKeepsUndeliveredSessions(t *testing.T) {
+	for name, down := range map[string]bool{"delivered": false, "undelivered": true} {
+		t.Run(name, func(t *testing.T) {
+			wal, err := tracker.OpenWAL(filepath.Join(t.TempDir(), "sessions.wal"))
+			if err != nil {
+				t.Fatalf("OpenWAL failed: %v", err)
+			}
+			defer wal.Close()
+			activity := NewActivityTracker()
+			activity.SetWAL(wal)
+			start := time.Now().Add(-time.Hour)
+			runSession(activity, "code", start, start.Add(20*time.Minute))
+
+			monitor := newMonitor(activity, &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code"}}})
+			monitor.Notifier = &recordingNotifier{}
+			monitor.DailyTotalsPath = ""
+			monitor.AppStatsPath = ""
+			monitor.Submit = true
+			monitor.Submitters = []Submitter{&recordingSubmitter{tracker: activity}}
+			if down {
+				monitor.Submitters = append(monitor.Submitters, downSubmitter{})
+			}
+			monitor.onShutdown()
+
+			entries, _, err := wal.Entries()
+			if err != nil {
+				t.Fatalf("Entries failed: %v", err)
+			}
+			if want := map[bool]int{false: 0, true: 1}[down]; len(entries) != want {
+				t.Errorf("Expected %d sessions left in the WAL, got %d", want, len(entries))
+			}
+		})
+	}
+}
+
+// slowSubmitter records every submission, blocking each until the test releases it
+type slowSubmitter struct {
+	entered     chan struct{}
+	release     chan struct{}
+	submissions []Submission
+}
+
+func (s *slowSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	s.entered <- struct{}{}
+	<-s.release
+	s.submissions = append(s.submissions, submission)
+	return nil, nil
+}
+
+func (s *slowSubmitter) Close(context.Context) error { return nil }
+func (s *slowSubmitter) Name() string                { return "slow" }
+
+// TestSlowSubmissionLosesNoSessions tests that sessions completed while a slow backend is
+// submitting reach every backend exactly once, in a later submission, and that the active
+// session's time is split between submissions rather than repeated
+func TestSlowSubmissionLosesNoSessions(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	monitor.Submit = true
+	slow := &slowSubmitter{entered: make(chan struct{}), release: make(chan struct{})}
+	monitor.Submitters = []Submitter{slow, submitters[0]}
+	clock := &tickerClock{}
+	monitor.Clock = clock
+
+	// After newTestMonitor's 30 minutes, code is focused for the rest of the test but for a
+	// minute of late-N each tick: 0-6 code, 6-7 late-0, 7-13 code, ... 21-25 code
+	t0 := time.Now().Add(-25 * time.Minute)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+	startSessionAt(monitor.Tracker, "code", "", t0)
+
+	for tick := 0; tick < 3; tick++ {
+		offset := 7 * tick
+		clock.now = at(offset + 5)
+		done := make(chan struct{})
+		go func() {
+			monitor.onSubmitTick()
+			close(done)
+		}()
+		<-slow.entered
+
+		// The window changes while the slow backend is still on the network
+		endSessionAt(monitor.Tracker, at(offset+6))
+		runSession(monitor.Tracker, fmt.Sprintf("late-%d", tick), at(offset+6), at(offset+7))
+		startSessionAt(monitor.Tracker, "code", "", at(offset+7))
+
+		slow.release <- struct{}{}
+		<-done
+	}
+
+	// Shutdown ends the active session now and submits the rest
+	clock.now = time.Time{}
+	go func() {
+		<-slow.entered
+		slow.release <- struct{}{}
+	}()
+	monitor.onShutdown()
+	tracked := 30*time.Minute + time.Since(t0)
+
+	for name, submissions := range map[string][]Submission{"slow": slow.submissions, "recording": submitters[0].submissions} {
+		seen := make(map[ActivitySession]bool)
+		var total time.Duration
+		for _, submission := range submissions {
+			for _, session := range submission.Sessions {
+				if seen[session] {
+					t.Errorf("%s: %s session at %v submitted twice", name, session.AppClass, session.StartTime)
+				}
+				seen[session] = true
+				total += session.Duration
+			}
+		}
+		for tick := 0; tick < 3; tick++ {
+			if !hasSession(submissions, fmt.Sprintf("late-%d", tick)) {
+				t.Errorf("%s: session late-%d, completed during a submission, was lost", name, tick)
+			}
+		}
+		if diff := tracked - total; diff < 0 || diff > time.Second {
+			t.Errorf("%s: expected %v submitted in total, got %v", name, tracked, total)
+		}
+	}
+}
+
+// hasSession reports whether any of submissions holds a session of appClass
+func hasSession(submissions []Submission, appClass string) bool {
+	for _, submission := range submissions {
+		for _, session := range submission.Sessions {
+			if session.AppClass == appClass {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestSubmitTimesOutHangingBackend tests that a hanging backend is abandoned after its
+// timeout and skipped until its submission returns, and that the snapshot it was stuck on is
+// delivered on the next tick
+func TestSubmitTimesOutHangingBackend(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	hanging := &hangingSubmitter{release: make(chan struct{})}
+	monitor.Submitters = []Submitter{hanging, submitters[0]}
+	monitor.BackendTimeout = 50 * time.Millisecond
+
+	began := time.Now()
+	outcomes := monitor.submitAll(context.Background(), monitor.destinationList(), false)
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("Expected submitAll to stop waiting at the timeout, took %v", elapsed)
+	}
+	if outcomes[0].Err == nil || outcomes[1].Err != nil {
+		t.Fatalf("Expected only the hanging backend to fail, got %+v", outcomes)
+	}
+	if len(submitters[0].submissions) != 1 {
+		t.Errorf("Expected the other backend to submit, got %d submissions", len(submitters[0].submissions))
+	}
+
+	// Still wedged: skipped rather than called again
+	outcomes = monitor.submitAll(context.Background(), monitor.destinationList(), false)
+	if outcomes[0].Err == nil || outcomes[0].Duration != 0 {
+		t.Errorf("Expected the wedged backend to be skipped, got %+v", outcomes[0])
+	}
+
+	// Once the abandoned Submit returns, the next tick submits again instead of skipping. The
+	// snapshot in flight at the timeout wasn't confirmed, so it goes first.
+	close(hanging.release)
+	deadline := time.Now().Add(time.Second)
+	for outcomes[0].Duration == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the backend to be usable again once its submission returned")
+		}
+		time.Sleep(5 * time.Millisecond)
+		outcomes = monitor.submitAll(context.Background(), monitor.destinationList(), false)
+	}
+	if outcomes[0].Err != nil {
+		t.Fatalf("Expected the next submission to succeed, got %v", outcomes[0].Err)
+	}
+	hanging.mu.Lock()
+	defer hanging.mu.Unlock()
+	if len(hanging.delivered) != 2 || !hasSession(hanging.delivered[:1], "code") || !hasSession(hanging.delivered[:1], "firefox") {
+		t.Errorf("Expected the stuck snapshot to be delivered ahead of the next one, got %+v", hanging.delivered)
+	}
+}
+
+// gatedSubmitter records every submission, its first blocking until the test closes
+// release whatever its context says, like a backend stuck in a DNS lookup
+type gatedSubmitter struct {
+	release     chan struct{}
+	mu          sync.Mutex
+	submissions []Submission
+}
+
+func (s *gatedSubmitter) Submit(ctx context.Context, submission Submission) (*Submission, error) {
+	s.mu.Lock()
+	first := len(s.submissions) == 0
+	s.submissions = append(s.submissions, submission)
+	s.mu.Unlock()
+	if first {
+		<-s.release
+	}
+	return nil, nil
+}
+
+func (s *gatedSubmitter) Close(context.Context) error { return nil }
+func (s *gatedSubmitter) Name() string                { return "gated" }
+
+// TestOverlappingTicksLoseNoSnapshots tests that ticks arriving while a submission hangs past
+// its timeout are skipped and counted, that the snapshot the watchdog held back is requeued,
+// and that every session reaches the backend once it recovers
+func TestOverlappingTicksLoseNoSnapshots(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	monitor.Submit = true
+	gated := &gatedSubmitter{release: make(chan struct{})}
+	monitor.Submitters = []Submitter{gated}
+	monitor.BackendTimeout = 50 * time.Millisecond
+
+	// A snapshot left over from an earlier panic goes first and hangs
+	dest := monitor.destinationList()[0]
+	dest.retry = []Submission{{Sessions: []ActivitySession{{AppClass: "retried"}}}}
+	monitor.onSubmitTick()
+
+	start := time.Now().Add(-20 * time.Minute)
+	runSession(monitor.Tracker, "slack", start, start.Add(5*time.Minute))
+	monitor.onSubmitTick()
+	monitor.onSubmitTick()
+	if dest.overlaps != 2 {
+		t.Errorf("Expected 2 overlapping ticks, got %d", dest.overlaps)
+	}
+
+	close(gated.release)
+	deadline := time.Now().Add(time.Second)
+	for !monitor.markBusy(dest.index) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the backend to be usable again once its submission returned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	monitor.clearBusy(dest.index)
+	monitor.onSubmitTick()
+
+	gated.mu.Lock()
+	defer gated.mu.Unlock()
+	if len(gated.submissions) != 3 {
+		t.Fatalf("Expected the retried snapshot, the requeued one and the next, got %d submissions", len(gated.submissions))
+	}
+	for _, app := range []string{"retried", "code", "firefox", "slack"} {
+		if !hasSession(gated.submissions, app) {
+			t.Errorf("Expected %s to be submitted", app)
+		}
+	}
+	if !hasSession(gated.submissions[1:2], "code") || !hasSession(gated.submissions[2:], "slack") {
+		t.Errorf("Expected the requeued snapshot before the one taken after the overlap, got %+v", gated.submissions)
+	}
+}
+
+// intervalRecorder is a recordingSubmitter with its own submission interval
+type intervalRecorder struct {
+	*recordingSubmitter
+	interval time.Duration
+}
+
+func (s intervalRecorder) Interval() time.Duration { return s.interval }
+
+// TestDestinationsAtDifferentCadences tests that destinations on their own intervals each
+// get every session exactly once, including at shutdown
+func TestDestinationsAtDifferentCadences(t *testing.T) {
+	tracker := NewActivityTracker()
+	monitor := newMonitor(tracker, &fakeBackend{windows: []*common.MutterWindow{{WmClass: "code"}}})
+	monitor.Notifier = &recordingNotifier{}
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	monitor.Submit = true
+	monitor.SubmitInterval = 30 * time.Minute
+	monitor.Clock = &tickerClock{}
+
+	// RescueTime on the default interval, a near-real-time webhook, PostgreSQL every 5 minutes
+	recorders := []*recordingSubmitter{{tracker: tracker}, {tracker: tracker}, {tracker: tracker}}
+	intervals := []time.Duration{0, time.Minute, 5 * time.Minute}
+	for i, recorder := range recorders {
+		monitor.Submitters = append(monitor.Submitters, intervalRecorder{recorder, intervals[i]})
+	}
+	schedules := monitor.newSchedules()
+	if len(schedules) != 3 {
+		t.Fatalf("Expected a schedule per interval, got %d", len(schedules))
+	}
+
+	start := time.Now().Add(-2 * time.Hour)
+	for minute := 1; minute <= 62; minute++ {
+		// Two segments 5s apart that merge, and 5s from the previous minute's, which was
+		// handed to the webhook in between
+		begin := start.Add(time.Duration(minute-1) * time.Minute)
+		runSession(tracker, "code", begin, begin.Add(25*time.Second))
+		runSession(tracker, "code", begin.Add(30*time.Second), begin.Add(55*time.Second))
+		for _, schedule := range schedules {
+			if minute%int(schedule.interval/time.Minute) == 0 {
+				monitor.onScheduleTick(schedule)
+			}
+		}
+	}
+	if sessions := tracker.GetSessions(); len(sessions) != 2 {
+		t.Errorf("Expected the 2 minutes since the last RescueTime tick to be held, got %d", len(sessions))
+	}
+	monitor.onShutdown()
+
+	for i, recorder := range recorders {
+		// Every interval's ticks plus the final submission
+		wantSubmissions := []int{3, 63, 13}[i]
+		if len(recorder.submissions) != wantSubmissions {
+			t.Errorf("Submitter %d: expected %d submissions, got %d", i, wantSubmissions, len(recorder.submissions))
+		}
+		seen := make(map[time.Time]bool)
+		var total time.Duration
+		for _, submission := range recorder.submissions {
+			for _, session := range submission.Sessions {
+				if seen[session.StartTime] {
+					t.Errorf("Submitter %d: session at %v received twice", i, session.StartTime)
+				}
+				seen[session.StartTime] = true
+				total += session.Duration
+			}
+		}
+		if len(seen) != 62 || total != 62*55*time.Second {
+			t.Errorf("Submitter %d: expected 62 sessions of 55s, got %d totalling %v", i, len(seen), total)
+		}
+		if last := recorder.submissions[len(recorder.submissions)-1]; !last.Final {
+			t.Errorf("Submitter %d: expected the last submission to be the final one", i)
+		}
+	}
+}
+
+// replayBackend decodes captured FocusedWindow responses in order, as getActiveWindow does
+type replayBackend struct {
+	responses []string
+}
+
+func (b *replayBackend) ActiveWindow() (*common.MutterWindow, error) {
+	response := b.responses[0]
+	if len(b.responses) > 1 {
+		b.responses = b.responses[1:]
+	}
+	return common.ParseFocusedWindow(response)
+}
+
+func (b *replayBackend) ListWindows() ([]common.MutterWindow, error) { return nil, nil }
+func (b *replayBackend) IdleTime() (time.Duration, error)            { return 0, nil }
+
+// TestStaleResponsesIgnored replays responses captured while switching workspaces, where the
+// extension briefly reports firefox without focus and VS Code without its title, and tests
+// that neither interrupts the VS Code session
+func TestStaleResponsesIgnored(t *testing.T) {
+	data, err := os.ReadFile("testdata/focus-flapping.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+	responses := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	tracker := NewActivityTracker()
+	notifier := &recordingNotifier{}
+	clock := &tickerClock{now: time.Now().Add(-time.Hour)}
+	monitor := newMonitor(tracker, &replayBackend{responses: responses})
+	monitor.Clock = clock
+	monitor.Notifier = notifier
+	monitor.DailyTotalsPath = ""
+	monitor.AppStatsPath = ""
+	for range responses[:len(responses)-2] {
+		clock.now = clock.now.Add(time.Second)
+		monitor.onPoll()
+	}
+
+	if !reflect.DeepEqual(notifier.windows, []string{"Code", "Code"}) {
+		t.Errorf("Expected only VS Code's title change, got changes %v", notifier.windows)
+	}
+	if current := currentSession(tracker); current == nil || !strings.HasPrefix(current.WindowTitle, "monitor.go") {
+		t.Errorf("Expected the monitor.go session to be current, got %+v", current)
+	}
+
+	// Focus really moving to firefox is tracked as usual
+	for i := 0; i < 2; i++ {
+		clock.now = clock.now.Add(time.Second)
+		monitor.onPoll()
+	}
+	if !reflect.DeepEqual(notifier.windows, []string{"Code", "Code", "firefox"}) {
+		t.Errorf("Expected focus to move to firefox, got changes %v", notifier.windows)
+	}
+}
+
+// TestSecondaryActivity tests a meeting left open behind the focused editor: each poll lists
+// the open windows, the secondary session pauses while the user is idle, and the submitters
+// get it as secondary sessions next to the primary ones but never in the summaries
+func TestSecondaryActivity(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	monitor.Tracker.ClearCompletedSessions()
+	clock := &tickerClock{now: time.Now()}
+	monitor.Clock = clock
+	monitor.Tracker.SetClock(clock.Now)
+	monitor.Tracker.SetSecondaryApps([]string{"zoom"})
+	monitor.Submit = true
+	backend := monitor.Backend.(*fakeBackend)
+	backend.open = []common.MutterWindow{{WmClass: "code", Title: "main.go"}, {WmClass: "zoom", Title: "Standup"}}
+	start := clock.now
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	monitor.onPoll()
+	if session, ok := monitor.Tracker.SecondarySession(); !ok || session.AppClass != "zoom" {
+		t.Fatalf("Expected zoom's secondary session behind code, got %+v, %v", session, ok)
+	}
+
+	// 10 minutes in the user goes idle, and is back in firefox at 20
+	clock.now = start.Add(10 * time.Minute)
+	backend.idle = time.Hour
+	monitor.onPoll()
+	if session, ok := monitor.Tracker.SecondarySession(); ok {
+		t.Errorf("Expected idle to end the secondary session, got %+v", session)
+	}
+	clock.now = start.Add(20 * time.Minute)
+	backend.idle = 0
+	backend.windows = []*common.MutterWindow{{WmClass: "firefox", Title: "Jira"}}
+	monitor.onPoll()
+	clock.now = start.Add(30 * time.Minute)
+	monitor.onPoll()
+	monitor.onShutdown()
+
+	var secondary, primary time.Duration
+	for _, submission := range submitters[0].submissions {
+		for _, session := range submission.Sessions {
+			if session.Secondary {
+				secondary += session.Duration
+			} else {
+				primary += session.Duration
+			}
+		}
+		if _, ok := submission.All["zoom"]; ok {
+			t.Errorf("Expected no zoom summary, got %+v", submission.All["zoom"])
+		}
+	}
+	if secondary != 20*time.Minute || primary != 20*time.Minute {
+		t.Errorf("Expected 20m of code and firefox alongside 20m of secondary zoom, got %v and %v", primary, secondary)
+	}
+}
+
+// TestMinBatchDurationCarriesForward tests that a RescueTime tick with less than
+// -min-batch-duration to send is skipped while other backends submit, and that the next tick
+// sends the skipped sessions along with the new ones
+func TestMinBatchDurationCarriesForward(t *testing.T) {
+	monitor, submitters, _ := newTestMonitor(t)
+	keyed := &keyedSubmitter{recordingSubmitter: recordingSubmitter{tracker: monitor.Tracker}}
+	monitor.Submitters = []Submitter{keyed, submitters[1]}
+	monitor.Submit = true
+	monitor.MinBatchDuration = 45 * time.Minute
+
+	// 30 minutes of code and firefox: below the threshold
+	monitor.onSubmitTick()
+	if len(keyed.submissions) != 0 {
+		t.Fatalf("Expected the 30m batch to be skipped, got %+v", keyed.submissions)
+	}
+	if len(submitters[1].submissions) != 1 || len(submitters[1].submissions[0].Sessions) != 2 {
+		t.Fatalf("Expected the other backend to submit as usual, got %+v", submitters[1].submissions)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 2 {
+		t.Fatalf("Expected the skipped sessions to be kept, got %d", len(sessions))
+	}
+
+	start := time.Now().Add(-30 * time.Minute)
+	runSession(monitor.Tracker, "slack", start, start.Add(20*time.Minute))
+	monitor.onSubmitTick()
+	if len(keyed.submissions) != 1 {
+		t.Fatalf("Expected the 50m batch to be submitted, got %d submissions", len(keyed.submissions))
+	}
+	completed := keyed.submissions[0].Completed
+	if len(completed) != 3 || completed["code"].TotalDuration != 20*time.Minute || completed["slack"].TotalDuration != 20*time.Minute {
+		t.Errorf("Expected the carried sessions along with the new one, got %+v", completed)
+	}
+	if len(submitters[1].submissions) != 2 || len(submitters[1].submissions[1].Sessions) != 1 {
+		t.Errorf("Expected the other backend to get only the new session, got %+v", submitters[1].submissions)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected the sessions to be cleared once every backend had them, got %d", len(sessions))
+	}
+}
+
+// TestSecondaryActivityWithoutList tests that an extension without List turns
+// -background-apps off after one attempt instead of failing every poll
+func TestSecondaryActivityWithoutList(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	monitor.Tracker.SetSecondaryApps([]string{"zoom"})
+	backend := monitor.Backend.(*fakeBackend)
+	backend.listErr = common.ErrListUnsupported
+
+	monitor.onPoll()
+	monitor.onPoll()
+	monitor.onPoll()
+	if backend.lists != 1 {
+		t.Errorf("Expected List to be tried once, got %d calls", backend.lists)
+	}
+	if session, ok := monitor.Tracker.SecondarySession(); ok {
+		t.Errorf("Expected no secondary session, got %+v", session)
+	}
+	if _, ok := monitor.Tracker.CurrentSession(); !ok {
+		t.Error("Expected the focused window to be tracked as usual")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+	"github.com/fatih/color"
+)
+
+// Push history configuration
+const (
+	pushHistoryBlock       = 4 * time.Hour                       // Offline time API accepts at most 4 hours per entry
+	pushHistoryMaxAge      = 14 * 24 * time.Hour                 // Offline time API rejects entries older than this
+	pushHistoryRateLimit   = 2 * time.Second                     // Delay between submissions to stay under API rate limits
+	pushHistoryMinDuration = rescuetime.DefaultLegacyMinDuration // Offline time API drops entries shorter than this (overridden by -min-submit)
+	pushHistoryDateLayout  = "2006-01-02"
+)
+
+// historyStore is the local storage read by -push-history (implemented by *postgres.Client)
+type historyStore interface {
+	GetSessionsInRange(start, end time.Time) ([]postgres.ActivitySession, error)
+	MarkSessionsPushed(ids []int64, pushedAt time.Time) error
+}
+
+// historySubmitter sends offline time entries to RescueTime (implemented by *rescuetime.Client)
+type historySubmitter interface {
+	SubmitLegacy(payload rescuetime.RescueTimePayload) error
+}
+
+// pushBlock is the time spent in one application within one 4-hour block
+type pushBlock struct {
+	Summary    ActivitySummary
+	SessionIDs []int64
+}
+
+// parsePushRange parses a -push-history value of "YYYY-MM-DD" or "YYYY-MM-DD..YYYY-MM-DD"
+// (inclusive, days in loc) and returns the half-open range [start, end). The end is clamped
+// to now, and ranges older than the offline time API accepts are refused.
+func parsePushRange(spec string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	fromValue, toValue, found := strings.Cut(strings.TrimSpace(spec), "..")
+	if !found {
+		toValue = fromValue
+	}
+
+	from, err := time.ParseInLocation(pushHistoryDateLayout, strings.TrimSpace(fromValue), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q (expected YYYY-MM-DD or YYYY-MM-DD..YYYY-MM-DD)", fromValue)
+	}
+	to, err := time.ParseInLocation(pushHistoryDateLayout, strings.TrimSpace(toValue), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q (expected YYYY-MM-DD or YYYY-MM-DD..YYYY-MM-DD)", toValue)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date %s is before start date %s", toValue, fromValue)
+	}
+
+	start, _ := tracker.DayBounds(from, loc)
+	_, end := tracker.DayBounds(to, loc)
+	if end.After(now) {
+		end = now
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range %s starts in the future", spec)
+	}
+
+	oldest := now.Add(-pushHistoryMaxAge)
+	if start.Before(oldest) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range starts %s, but RescueTime's offline time API only accepts data from the last %d days (since %s)\n\nTroubleshooting:\n  1. Narrow the range to start on or after %s\n  2. Older data stays in PostgreSQL and can still be queried locally",
+			start.Format(pushHistoryDateLayout), int(pushHistoryMaxAge.Hours()/24), oldest.Format(pushHistoryDateLayout), oldest.AddDate(0, 0, 1).Format(pushHistoryDateLayout))
+	}
+	return start, end, nil
+}
+
+// blockStart returns the start of the 4-hour block containing t, aligned to midnight in loc
+func blockStart(t time.Time, loc *time.Location) time.Time {
+	midnight, _ := tracker.DayBounds(t, loc)
+	t = t.In(midnight.Location())
+	hour := t.Hour() - t.Hour()%int(pushHistoryBlock.Hours())
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day(), hour, 0, 0, 0, midnight.Location())
+}
+
+// pushable reports whether -push-history should submit the session for [start, end): it must be
+// imported rather than tracked live (the tracker already submitted those), not yet pushed, and lie
+// wholly inside the range. A session crossing the range edge is left for a range that covers it,
+// since pushing only part of it could never be completed later without sending that part twice.
+func pushable(session postgres.ActivitySession, start, end time.Time) bool {
+	return !session.Tracked() && session.PushedAt.IsZero() && !session.StartTime.Before(start) && !session.EndTime.After(end)
+}
+
+// buildPushBlocks splits pushable sessions into per-application 4-hour blocks within [start, end).
+// Sessions outside active hours (tagged when tracked, or outside -active-hours now) are skipped;
+// sessions crossing a block boundary contribute to both blocks.
+func buildPushBlocks(sessions []postgres.ActivitySession, start, end time.Time) []pushBlock {
+	type blockKey struct {
+		start    time.Time
+		appClass string
+	}
+	blocks := make(map[blockKey]*pushBlock)
+
+	for _, session := range sessions {
+		if !pushable(session, start, end) {
+			continue
+		}
+		if session.OutsideActiveHours || (activeHours != nil && !activeHours.Contains(session.StartTime)) {
+			logging.Debug("Skipping %s at %s: outside active hours", session.AppClass, session.StartTime.Format("Mon 15:04"))
+			continue
+		}
+
+		sessionStart, sessionEnd := session.StartTime, session.EndTime
+		for pieceStart := sessionStart; pieceStart.Before(sessionEnd); {
+			bStart := blockStart(pieceStart, dayLocation)
+			pieceEnd := bStart.Add(pushHistoryBlock)
+			if pieceEnd.After(sessionEnd) {
+				pieceEnd = sessionEnd
+			}
+
+			key := blockKey{start: bStart, appClass: session.AppClass}
+			block, ok := blocks[key]
+			if !ok {
+				block = &pushBlock{Summary: ActivitySummary{
+					AppClass:  session.AppClass,
+					FirstSeen: pieceStart,
+					LastSeen:  pieceEnd,
+				}}
+				blocks[key] = block
+			}
+
+			summary := &block.Summary
+			if pieceStart.Before(summary.FirstSeen) {
+				summary.FirstSeen = pieceStart
+			}
+			if pieceEnd.After(summary.LastSeen) {
+				summary.LastSeen = pieceEnd
+			}
+			if summary.ActivityDetails == "" {
+				summary.ActivityDetails = session.WindowTitle
+				summary.RawTitle = session.WindowTitle
+			}
+			summary.TotalDuration += pieceEnd.Sub(pieceStart)
+			summary.SessionCount++
+			block.SessionIDs = append(block.SessionIDs, session.ID)
+
+			pieceStart = pieceEnd
+		}
+	}
+
+	result := make([]pushBlock, 0, len(blocks))
+	for _, block := range blocks {
+		// Overlapping sessions must not claim more time than the block's span
+		if span := block.Summary.LastSeen.Sub(block.Summary.FirstSeen); block.Summary.TotalDuration > span {
+			block.Summary.TotalDuration = span
+		}
+		result = append(result, *block)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Summary.FirstSeen.Equal(result[j].Summary.FirstSeen) {
+			return result[i].Summary.FirstSeen.Before(result[j].Summary.FirstSeen)
+		}
+		return result[i].Summary.AppClass < result[j].Summary.AppClass
+	})
+	return result
+}
+
+// pushHistory submits locally stored sessions in [start, end) to RescueTime's offline time API.
+// Only imported sessions are pushed; successfully pushed ones are marked so re-running over the
+// same range doesn't duplicate time.
+// In dry-run mode nothing is submitted or marked. sleep is called between submissions for rate limiting.
+func pushHistory(store historyStore, submitter historySubmitter, start, end time.Time, dryRun bool, sleep func(time.Duration)) error {
+	sessions, err := store.GetSessionsInRange(start, end)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %v", err)
+	}
+
+	alreadyPushed, tracked, crossing := 0, 0, 0
+	for _, session := range sessions {
+		switch {
+		case session.Tracked():
+			tracked++
+		case !session.PushedAt.IsZero():
+			alreadyPushed++
+		case !pushable(session, start, end):
+			crossing++
+		}
+	}
+
+	minDuration := pushHistoryMinDuration
+	if minSubmitOverride != nil {
+		minDuration = *minSubmitOverride
+	}
+
+	blocks := buildPushBlocks(sessions, start, end)
+	if dryRun {
+		logging.Info("[DRY RUN] Previewing push of %s to %s (%d blocks)", start.Format(pushHistoryDateLayout), end.Add(-time.Nanosecond).Format(pushHistoryDateLayout), len(blocks))
+	} else {
+		logging.Info("Pushing %s to %s to RescueTime (%d blocks)", start.Format(pushHistoryDateLayout), end.Add(-time.Nanosecond).Format(pushHistoryDateLayout), len(blocks))
+	}
+
+	pushedIDs := make(map[int64]bool)
+	failedIDs := make(map[int64]bool)
+	tooShort, failed, submitted := 0, 0, 0
+
+	currentDay := ""
+	var currentDate time.Time
+	var dayTotal time.Duration
+	dayEntries := 0
+	reportDay := func() {
+		if currentDay == "" {
+			return
+		}
+		if dryRun {
+			color.Cyan("%s: would push %s in %d entries\n", display.Date(currentDate), HumanDuration(dayTotal), dayEntries)
+		} else {
+			color.Green("%s: pushed %s in %d entries\n", display.Date(currentDate), HumanDuration(dayTotal), dayEntries)
+		}
+	}
+
+	for _, block := range blocks {
+		summary := block.Summary
+		day := summary.FirstSeen.Format(pushHistoryDateLayout)
+		if day != currentDay {
+			reportDay()
+			currentDay, currentDate, dayTotal, dayEntries = day, summary.FirstSeen, 0, 0
+		}
+
+		if summary.TotalDuration < minDuration {
+			logging.Info("Skipping %s at %s: duration %v is below the %v minimum", summary.AppClass, summary.FirstSeen.Format("15:04"), summary.TotalDuration, minDuration)
+			tooShort++
+			continue
+		}
+
+		payload := rescuetime.SummaryToPayloadMode(summary, payloadMode)
+		if err := rescuetime.ValidatePayload(payload); err != nil {
+			logging.Warning("Skipping %s at %s: %v", summary.AppClass, payload.StartTime, err)
+			failed++
+			continue
+		}
+
+		if dryRun {
+			logging.Verbose("[DRY RUN] %s %s (%d min)", payload.StartTime, payload.ActivityName, payload.Duration)
+		} else {
+			if submitted > 0 {
+				sleep(pushHistoryRateLimit)
+			}
+			submitted++
+			if err := submitter.SubmitLegacy(payload); err != nil {
+				logging.Error("Failed to push %s at %s: %v", summary.AppClass, payload.StartTime, err)
+				failed++
+				for _, id := range block.SessionIDs {
+					failedIDs[id] = true
+				}
+				continue
+			}
+			for _, id := range block.SessionIDs {
+				pushedIDs[id] = true
+			}
+		}
+
+		dayTotal += summary.TotalDuration
+		dayEntries++
+	}
+	reportDay()
+
+	if !dryRun {
+		// A session split across blocks is only marked once every block made it
+		var ids []int64
+		for id := range pushedIDs {
+			if !failedIDs[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if err := store.MarkSessionsPushed(ids, time.Now()); err != nil {
+			return fmt.Errorf("pushed to RescueTime but %v\nRe-running this range may submit the same time again", err)
+		}
+	}
+
+	color.New(color.FgCyan, color.Bold).Printf("\n=== Push History Summary ===\n")
+	if tracked > 0 {
+		color.Yellow("Tracked live: %d sessions (skipped, already submitted by the tracker)\n", tracked)
+	}
+	if alreadyPushed > 0 {
+		color.Yellow("Already pushed: %d sessions (skipped)\n", alreadyPushed)
+	}
+	if crossing > 0 {
+		color.Yellow("Crossing the range edge: %d sessions (skipped, widen the range to include them)\n", crossing)
+	}
+	if tooShort > 0 {
+		color.Yellow("Below %v minimum: %d blocks (skipped)\n", minDuration, tooShort)
+	}
+	if failed > 0 {
+		color.Red("Failed: %d blocks (re-run the same range to retry)\n", failed)
+		return fmt.Errorf("%d blocks could not be pushed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// fakeHistoryStore serves seeded sessions and records which were marked pushed
+type fakeHistoryStore struct {
+	sessions []postgres.ActivitySession
+	marked   []int64
+}
+
+func (f *fakeHistoryStore) GetSessionsInRange(start, end time.Time) ([]postgres.ActivitySession, error) {
+	var result []postgres.ActivitySession
+	for _, s := range f.sessions {
+		if s.StartTime.Before(end) && s.EndTime.After(start) {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeHistoryStore) MarkSessionsPushed(ids []int64, pushedAt time.Time) error {
+	f.marked = append(f.marked, ids...)
+	for i := range f.sessions {
+		for _, id := range ids {
+			if f.sessions[i].ID == id {
+				f.sessions[i].PushedAt = pushedAt
+			}
+		}
+	}
+	return nil
+}
+
+// fakeHistorySubmitter records payloads, failing for one application if set
+type fakeHistorySubmitter struct {
+	payloads []rescuetime.RescueTimePayload
+	failApp  string
+}
+
+func (f *fakeHistorySubmitter) SubmitLegacy(payload rescuetime.RescueTimePayload) error {
+	if payload.ActivityName == f.failApp {
+		return errors.New("simulated API error")
+	}
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+// seedWeek creates a week of imported sessions: a 9:00-17:00 editor session that crosses two
+// block boundaries, a 2 minute terminal session, and (on the first day) an already-pushed browser session
+func seedWeek(weekStart time.Time) []postgres.ActivitySession {
+	var sessions []postgres.ActivitySession
+	id := int64(1)
+	for day := 0; day < 7; day++ {
+		date := weekStart.AddDate(0, 0, day)
+		at := func(hour, minute int) time.Time {
+			return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.Local)
+		}
+		sessions = append(sessions,
+			postgres.ActivitySession{ID: id, AppClass: "code", WindowTitle: "main.go", StartTime: at(9, 0), EndTime: at(17, 0)},
+			postgres.ActivitySession{ID: id + 1, AppClass: "terminal", StartTime: at(17, 0), EndTime: at(17, 2)},
+		)
+		id += 2
+		if day == 0 {
+			sessions = append(sessions, postgres.ActivitySession{ID: id, AppClass: "firefox", StartTime: at(18, 0), EndTime: at(19, 0), PushedAt: at(20, 0)})
+			id++
+		}
+	}
+	for i := range sessions {
+		sessions[i].Duration = sessions[i].EndTime.Sub(sessions[i].StartTime)
+		sessions[i].Source = "rescuetime"
+	}
+	return sessions
+}
+
+// TestPushHistoryChunksAndSkips tests chunk boundaries and skip logic over a seeded week
+func TestPushHistoryChunksAndSkips(t *testing.T) {
+	weekStart := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)
+	store := &fakeHistoryStore{sessions: seedWeek(weekStart)}
+	submitter := &fakeHistorySubmitter{}
+	sleeps := 0
+
+	err := pushHistory(store, submitter, weekStart, weekStart.AddDate(0, 0, 7), false, func(time.Duration) { sleeps++ })
+	if err != nil {
+		t.Fatalf("pushHistory failed: %v", err)
+	}
+
+	// 9:00-17:00 splits at the 12:00 and 16:00 boundaries: 9-12, 12-16, 16-17 on each of 7 days
+	if len(submitter.payloads) != 21 {
+		t.Fatalf("Expected 21 payloads, got %d", len(submitter.payloads))
+	}
+	expected := []struct {
+		start    string
+		duration int
+	}{
+		{"09:00:00", 180},
+		{"12:00:00", 240},
+		{"16:00:00", 60},
+	}
+	for i, payload := range submitter.payloads[:3] {
+		if payload.StartTime != "2025-10-20 "+expected[i].start || payload.Duration != expected[i].duration {
+			t.Errorf("Payload %d: expected %s for %d min, got %s for %d min", i, expected[i].start, expected[i].duration, payload.StartTime, payload.Duration)
+		}
+	}
+	for _, payload := range submitter.payloads {
+		if payload.ActivityName != "code" {
+			t.Errorf("Expected only code to be pushed (terminal too short, firefox already pushed), got %s", payload.ActivityName)
+		}
+		if payload.Duration > int(pushHistoryBlock.Minutes()) {
+			t.Errorf("Payload exceeds 4h block: %d min", payload.Duration)
+		}
+	}
+	if sleeps != 20 {
+		t.Errorf("Expected rate limit between each of 21 submissions (20 sleeps), got %d", sleeps)
+	}
+	if len(store.marked) != 7 {
+		t.Errorf("Expected 7 code sessions marked pushed, got %d: %v", len(store.marked), store.marked)
+	}
+
+	// A second run over the same range submits nothing
+	submitter.payloads = nil
+	if err := pushHistory(store, submitter, weekStart, weekStart.AddDate(0, 0, 7), false, func(time.Duration) {}); err != nil {
+		t.Fatalf("Second pushHistory failed: %v", err)
+	}
+	if len(submitter.payloads) != 0 {
+		t.Errorf("Expected already-pushed sessions to be skipped, got %d payloads", len(submitter.payloads))
+	}
+}
+
+// TestPushHistoryDryRunAndFailures tests that dry runs and failed blocks don't mark sessions
+func TestPushHistoryDryRunAndFailures(t *testing.T) {
+	weekStart := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)
+
+	store := &fakeHistoryStore{sessions: seedWeek(weekStart)}
+	submitter := &fakeHistorySubmitter{}
+	if err := pushHistory(store, submitter, weekStart, weekStart.AddDate(0, 0, 7), true, func(time.Duration) {}); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(submitter.payloads) != 0 || len(store.marked) != 0 {
+		t.Errorf("Dry run should not submit or mark, got %d payloads and %d marked", len(submitter.payloads), len(store.marked))
+	}
+
+	submitter.failApp = "code"
+	if err := pushHistory(store, submitter, weekStart, weekStart.AddDate(0, 0, 7), false, func(time.Duration) {}); err == nil {
+		t.Error("Expected error when blocks fail")
+	}
+	if len(store.marked) != 0 {
+		t.Errorf("Failed sessions should not be marked pushed, got %v", store.marked)
+	}
+}
+
+// TestPushHistoryActiveHours tests that sessions outside active hours are never pushed
+func TestPushHistoryActiveHours(t *testing.T) {
+	weekStart := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local) // Monday
+	sessions := seedWeek(weekStart)
+	sessions[0].OutsideActiveHours = true // Monday's code session, tagged while tracking
+
+	schedule, err := rescuetime.ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	activeHours = schedule
+	t.Cleanup(func() { activeHours = nil })
+
+	store := &fakeHistoryStore{sessions: sessions}
+	submitter := &fakeHistorySubmitter{}
+	if err := pushHistory(store, submitter, weekStart, weekStart.AddDate(0, 0, 7), false, func(time.Duration) {}); err != nil {
+		t.Fatalf("pushHistory failed: %v", err)
+	}
+
+	// Tuesday to Friday only: 3 blocks per day
+	if len(submitter.payloads) != 12 {
+		t.Fatalf("Expected 12 payloads, got %d", len(submitter.payloads))
+	}
+	for _, payload := range submitter.payloads {
+		start, _ := time.ParseInLocation("2006-01-02 15:04:05", payload.StartTime, time.Local)
+		if day := start.Weekday(); day == time.Monday || day == time.Saturday || day == time.Sunday {
+			t.Errorf("Pushed %s on %s, outside active hours", payload.ActivityName, day)
+		}
+	}
+}
+
+// TestPushHistorySkipsTrackedAndCrossing tests that live-tracked sessions are never pushed, and
+// that a session crossing the range edge is left whole for a range that covers it
+func TestPushHistorySkipsTrackedAndCrossing(t *testing.T) {
+	monday := time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)
+	tuesday := monday.AddDate(0, 0, 1)
+	wednesday := monday.AddDate(0, 0, 2)
+	store := &fakeHistoryStore{sessions: []postgres.ActivitySession{
+		{ID: 1, AppClass: "code", Source: "rescuetime", StartTime: tuesday.Add(9 * time.Hour), EndTime: tuesday.Add(10 * time.Hour)},
+		{ID: 2, AppClass: "firefox", Source: "tracker", StartTime: tuesday.Add(11 * time.Hour), EndTime: tuesday.Add(12 * time.Hour)},
+		{ID: 3, AppClass: "terminal", StartTime: tuesday.Add(13 * time.Hour), EndTime: tuesday.Add(14 * time.Hour)},
+		{ID: 4, AppClass: "vlc", Source: "rescuetime", StartTime: monday.Add(23 * time.Hour), EndTime: tuesday.Add(time.Hour)},
+	}}
+	submitter := &fakeHistorySubmitter{}
+
+	if err := pushHistory(store, submitter, tuesday, wednesday, false, func(time.Duration) {}); err != nil {
+		t.Fatalf("pushHistory failed: %v", err)
+	}
+	if len(submitter.payloads) != 1 || submitter.payloads[0].ActivityName != "code" {
+		t.Fatalf("Expected only the imported code session to be pushed, got %+v", submitter.payloads)
+	}
+	if len(store.marked) != 1 || store.marked[0] != 1 {
+		t.Errorf("Expected only session 1 marked pushed, got %v", store.marked)
+	}
+
+	// Monday and Tuesday together cover the vlc session, which is pushed whole
+	submitter.payloads = nil
+	if err := pushHistory(store, submitter, monday, wednesday, false, func(time.Duration) {}); err != nil {
+		t.Fatalf("Second pushHistory failed: %v", err)
+	}
+	var minutes int
+	for _, payload := range submitter.payloads {
+		if payload.ActivityName != "vlc" {
+			t.Errorf("Expected only vlc on the wider range, got %s", payload.ActivityName)
+		}
+		minutes += payload.Duration
+	}
+	if minutes != 120 {
+		t.Errorf("Expected all 120 minutes of vlc pushed, got %d", minutes)
+	}
+	if len(store.marked) != 2 || store.marked[1] != 4 {
+		t.Errorf("Expected session 4 marked pushed, got %v", store.marked)
+	}
+}
+
+// TestParsePushRange tests date range parsing and the API age limit
+func TestParsePushRange(t *testing.T) {
+	now := time.Date(2025, 10, 29, 15, 0, 0, 0, time.Local)
+
+	start, end, err := parsePushRange("2025-10-20..2025-10-26", now, time.Local)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !start.Equal(time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local)) || !end.Equal(time.Date(2025, 10, 27, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected range %v - %v", start, end)
+	}
+
+	// Today is clamped to now
+	if _, end, err := parsePushRange("2025-10-29", now, time.Local); err != nil || !end.Equal(now) {
+		t.Errorf("Expected end clamped to now, got %v (err %v)", end, err)
+	}
+
+	for _, spec := range []string{"2025-09-01..2025-09-07", "2025-10-26..2025-10-20", "2025-11-01", "last week"} {
+		if _, _, err := parsePushRange(spec, now, time.Local); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+// TestParsePushRangeLocation tests that -push-history dates and blocks follow -timezone when
+// the system clock is in another zone
+func TestParsePushRangeLocation(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2025, 10, 29, 6, 0, 0, 0, time.UTC) // 15:00 in Tokyo
+
+	start, end, err := parsePushRange("2025-10-28", now, tokyo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Tokyo's 28th runs from 15:00 UTC on the 27th to 15:00 UTC on the 28th
+	if !start.Equal(time.Date(2025, 10, 27, 15, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 10, 28, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected range %v - %v", start.UTC(), end.UTC())
+	}
+
+	// 14:59 UTC is 23:59 in Tokyo, the last block of the day
+	if got := blockStart(time.Date(2025, 10, 28, 14, 59, 0, 0, time.UTC), tokyo); !got.Equal(time.Date(2025, 10, 28, 20, 0, 0, 0, tokyo)) {
+		t.Errorf("Expected the 20:00 Tokyo block, got %v", got)
+	}
+	if got := blockStart(time.Date(2025, 10, 28, 15, 0, 0, 0, time.UTC), tokyo); !got.Equal(time.Date(2025, 10, 29, 0, 0, 0, 0, tokyo)) {
+		t.Errorf("Expected Tokyo midnight to start a block, got %v", got)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+const (
+	// defaultQueuePath is where summaries that couldn't be submitted are kept until the next run
+	defaultQueuePath = ".rescuetime-queue.json"
+
+	// maxQueuedSummaries is how many summaries housekeeping leaves in the queue, about a month
+	// of 15-minute submissions of a dozen applications each; older ones are dropped
+	maxQueuedSummaries = 20000
+)
+
+// queuedSummary is a summary waiting to be submitted to RescueTime.
+// The idempotency key is stored so re-queuing the same data doesn't duplicate it.
+type queuedSummary struct {
+	Summary        ActivitySummary `json:"summary"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	QueuedAt       time.Time       `json:"queued_at"`
+}
+
+// submissionQueue persists unsent summaries to disk so they survive restarts
+type submissionQueue struct {
+	mu   sync.Mutex
+	path string
+}
+
+// newSubmissionQueue creates a queue backed by the given file
+func newSubmissionQueue(path string) *submissionQueue {
+	return &submissionQueue{path: path}
+}
+
+// loadUnsafe reads the queued items (must be called with lock held)
+func (q *submissionQueue) loadUnsafe() ([]queuedSummary, error) {
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue file %s: %v", q.path, err)
+	}
+
+	var items []queuedSummary
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse queue file %s: %v\n\nMove the file aside to start with an empty queue", q.path, err)
+	}
+	return items, nil
+}
+
+// saveUnsafe writes the queued items atomically, removing the file when empty (must be called with lock held)
+func (q *submissionQueue) saveUnsafe(items []queuedSummary) error {
+	if len(items) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove queue file: %v", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue: %v", err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't corrupt the queue
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write queue file: %v", err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("failed to replace queue file: %v", err)
+	}
+	return nil
+}
+
+// Append adds summaries to the queue, skipping ones that are already queued
+func (q *submissionQueue) Append(summaries map[string]ActivitySummary) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(items))
+	for _, item := range items {
+		existing[item.IdempotencyKey] = true
+	}
+
+	now := time.Now()
+	for _, summary := range summaries {
+		key := rescuetime.IdempotencyKey(summary)
+		if existing[key] {
+			continue
+		}
+		existing[key] = true
+		items = append(items, queuedSummary{Summary: summary, IdempotencyKey: key, QueuedAt: now})
+	}
+
+	return q.saveUnsafe(items)
+}
+
+// Take removes and returns all queued summaries, keyed by idempotency key
+func (q *submissionQueue) Take() (map[string]ActivitySummary, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]ActivitySummary, len(items))
+	for _, item := range items {
+		summaries[item.IdempotencyKey] = item.Summary
+	}
+
+	if err := q.saveUnsafe(nil); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// Peek returns all queued summaries, keyed by idempotency key, leaving them queued until
+// Settle says what became of them
+func (q *submissionQueue) Peek() (map[string]ActivitySummary, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]ActivitySummary, len(items))
+	for _, item := range items {
+		summaries[item.IdempotencyKey] = item.Summary
+	}
+	return summaries, nil
+}
+
+// Settle removes the summaries a Peek returned, now that they were submitted, and queues
+// retry, the ones (or parts of ones) that still have to be. Summaries queued since the Peek stay.
+func (q *submissionQueue) Settle(taken map[string]ActivitySummary, retry map[string]ActivitySummary) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return err
+	}
+	kept := items[:0]
+	existing := make(map[string]bool, len(items))
+	for _, item := range items {
+		if _, ok := taken[item.IdempotencyKey]; !ok {
+			kept = append(kept, item)
+			existing[item.IdempotencyKey] = true
+		}
+	}
+	now := time.Now()
+	for _, summary := range retry {
+		key := rescuetime.IdempotencyKey(summary)
+		if existing[key] {
+			continue
+		}
+		existing[key] = true
+		kept = append(kept, queuedSummary{Summary: summary, IdempotencyKey: key, QueuedAt: now})
+	}
+	return q.saveUnsafe(kept)
+}
+
+// Purge removes the queued summaries match selects (e.g. of an application ignored since
+// they were queued), returning how many were removed
+func (q *submissionQueue) Purge(match func(summary ActivitySummary) bool) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := items[:0]
+	for _, item := range items {
+		if !match(item.Summary) {
+			kept = append(kept, item)
+		}
+	}
+	purged := len(items) - len(kept)
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, q.saveUnsafe(kept)
+}
+
+// Len returns how many summaries are queued
+func (q *submissionQueue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	return len(items), err
+}
+
+// Keys returns the idempotency keys of the queued summaries
+func (q *submissionQueue) Keys() (map[string]bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(items))
+	for _, item := range items {
+		keys[item.IdempotencyKey] = true
+	}
+	return keys, nil
+}
+
+// Trim drops the oldest queued summaries beyond max, so a queue RescueTime never drains
+// can't grow without bound, returning how many were dropped
+func (q *submissionQueue) Trim(max int) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items, err := q.loadUnsafe()
+	if err != nil || len(items) <= max {
+		return 0, err
+	}
+	// Append adds to the end, so the oldest come first
+	dropped := len(items) - max
+	return dropped, q.saveUnsafe(items[dropped:])
+}
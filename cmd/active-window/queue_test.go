@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// fakeRescueTime is a native API endpoint that answers 503 while down and records the
+// applications it accepts otherwise
+type fakeRescueTime struct {
+	mu       sync.Mutex
+	down     bool
+	accepted []string
+}
+
+// newFakeRescueTime starts a fakeRescueTime and returns a client sending only to it, without
+// waiting between retries
+func newFakeRescueTime(t *testing.T) (*fakeRescueTime, *rescuetime.Client) {
+	t.Helper()
+	api := &fakeRescueTime{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if api.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload rescuetime.UserClientEventPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		api.accepted = append(api.accepted, payload.UserClientEvent.Application)
+	}))
+	t.Cleanup(server.Close)
+
+	client := rescuetime.NewClient("", "account-key", "data-key")
+	client.Profile.BaseURL = server.URL
+	client.MinLegacyDuration = time.Hour // native only, no fallback to the real legacy API
+	client.Sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	return api, client
+}
+
+func (f *fakeRescueTime) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// acceptedApps returns the applications accepted so far, sorted
+func (f *fakeRescueTime) acceptedApps() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	apps := append([]string(nil), f.accepted...)
+	sort.Strings(apps)
+	return apps
+}
+
+// TestSubmissionQueueAppendTake tests that queued summaries survive a round trip and aren't duplicated
+func TestSubmissionQueueAppendTake(t *testing.T) {
+	queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+	}
+
+	if err := queue.Append(summaries); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := queue.Append(summaries); err != nil {
+		t.Fatalf("Second Append failed: %v", err)
+	}
+
+	taken, err := queue.Take()
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	if len(taken) != 1 {
+		t.Fatalf("Expected 1 queued summary, got %d", len(taken))
+	}
+
+	taken, err = queue.Take()
+	if err != nil {
+		t.Fatalf("Second Take failed: %v", err)
+	}
+	if len(taken) != 0 {
+		t.Errorf("Expected empty queue after Take, got %d", len(taken))
+	}
+}
+
+// TestSubmissionQueueTrim tests that Trim drops the oldest summaries beyond the limit and
+// keeps the rest with their keys
+func TestSubmissionQueueTrim(t *testing.T) {
+	queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	for i, app := range []string{"firefox", "code", "slack"} {
+		summary := ActivitySummary{AppClass: app, TotalDuration: 10 * time.Minute, FirstSeen: start.Add(time.Duration(i) * time.Hour)}
+		if err := queue.Append(map[string]ActivitySummary{app: summary}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	if dropped, err := queue.Trim(5); err != nil || dropped != 0 {
+		t.Errorf("Expected nothing dropped under the limit, got %d, %v", dropped, err)
+	}
+	if dropped, err := queue.Trim(2); err != nil || dropped != 1 {
+		t.Fatalf("Expected the oldest summary dropped, got %d, %v", dropped, err)
+	}
+	if n, err := queue.Len(); err != nil || n != 2 {
+		t.Errorf("Expected 2 queued summaries, got %d, %v", n, err)
+	}
+	keys, err := queue.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	taken, err := queue.Take()
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	for key, summary := range taken {
+		if summary.AppClass == "firefox" || !keys[key] {
+			t.Errorf("Unexpected queued summary %s: %+v (in Keys: %v)", key, summary, keys[key])
+		}
+	}
+}
+
+// TestSubmitOnShutdownTimeoutQueues tests that data not submitted before the timeout is
+// queued when the backends are closed
+func TestSubmitOnShutdownTimeoutQueues(t *testing.T) {
+	t.Setenv("RESCUE_TIME_ACCOUNT_KEY", "")
+	t.Setenv("RESCUE_TIME_DATA_KEY", "")
+
+	queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+	client := newRescueTimeClient("test-key")
+	client.Queue = queue
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"code":    {AppClass: "code", TotalDuration: 20 * time.Minute, FirstSeen: start, LastSeen: start.Add(20 * time.Minute)},
+	}
+
+	began := time.Now()
+	submitOnShutdown(50*time.Millisecond, func(ctx context.Context) {
+		// Simulate an unresponsive API: the submission only starts once the deadline passed
+		<-ctx.Done()
+		client.SubmitActivitiesContext(ctx, summaries)
+	})
+	closeSubmitters([]Submitter{&rescueTimeSubmitter{client: client}})
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, expected it to stop at the timeout", elapsed)
+	}
+
+	queued, err := queue.Take()
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	if len(queued) != len(summaries) {
+		t.Errorf("Expected %d queued summaries, got %d", len(summaries), len(queued))
+	}
+}
+
+// TestSubmitQueuedKeepsUndelivered tests that queued summaries leave the queue only once
+// submitted: a failed retry or one cut off by its context keeps them for the next attempt
+func TestSubmitQueuedKeepsUndelivered(t *testing.T) {
+	api, client := newFakeRescueTime(t)
+	queue := newSubmissionQueue(filepath.Join(t.TempDir(), "queue.json"))
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	if err := queue.Append(map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"code":    {AppClass: "code", TotalDuration: 20 * time.Minute, SessionCount: 1, FirstSeen: start.Add(time.Hour), LastSeen: start.Add(80 * time.Minute)},
+	}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	api.setDown(true)
+	submitQueuedActivities(context.Background(), queue, client)
+	if n, err := queue.Len(); err != nil || n != 2 {
+		t.Fatalf("Expected both summaries kept after a failed retry, got %d, %v", n, err)
+	}
+
+	api.setDown(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	submitQueuedActivities(ctx, queue, client)
+	if n, err := queue.Len(); err != nil || n != 2 || len(api.acceptedApps()) != 0 {
+		t.Fatalf("Expected nothing sent past the deadline and both kept, got %d, %v", n, err)
+	}
+
+	submitQueuedActivities(context.Background(), queue, client)
+	if n, err := queue.Len(); err != nil || n != 0 {
+		t.Errorf("Expected an empty queue once submitted, got %d, %v", n, err)
+	}
+	if accepted := api.acceptedApps(); len(accepted) != 2 {
+		t.Errorf("Expected both summaries sent once, got %v", accepted)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// apiKeySubmitter is a Submitter that sends with RESCUE_TIME_API_KEY. A reload revalidates
+// the key and hands it over; while it's invalid, the Monitor holds such a destination back
+// instead of submitting with a key that can only be refused.
+type apiKeySubmitter interface {
+	usesAPIKey() bool
+	setAPIKey(key string)
+	// queueSummaries keeps summaries held back until shutdown for the next run to submit
+	queueSummaries(summaries map[string]ActivitySummary) error
+}
+
+// reloadAPIKey re-reads RESCUE_TIME_API_KEY with startup's precedence: a key the
+// environment set before .env was loaded (inherited, e.g. by op run) wins, otherwise
+// path's current value, "" if it no longer has one
+func reloadAPIKey(path, inherited string) (string, error) {
+	if inherited != "" {
+		return inherited, nil
+	}
+	values, err := readEnvFile(path)
+	if err != nil {
+		return "", err
+	}
+	return values["RESCUE_TIME_API_KEY"], nil
+}
+
+// onReload re-reads the API key (on SIGHUP) and revalidates the configuration with it.
+// A valid key goes to the RescueTime submitters; an invalid one pauses them until a later
+// reload fixes it, keeping their sessions in the tracker rather than losing them to
+// doomed submissions.
+func (m *Monitor) onReload() {
+	logging.Info("Reloading configuration")
+	if m.ReloadAPIKey == nil {
+		return
+	}
+
+	key, err := m.ReloadAPIKey()
+	if err == nil {
+		err = validateConfiguration(m.Submit, m.DryRun, key, m.SubmitInterval, m.SubmitJitter, m.PollInterval)
+	}
+	if err == nil {
+		for _, dest := range m.destinationList() {
+			if submitter, ok := dest.submitter.(apiKeySubmitter); ok && submitter.usesAPIKey() {
+				submitter.setAPIKey(key)
+			}
+		}
+	}
+	m.setConfigError(err)
+}
+
+// setConfigError records the outcome of a reload, reporting a change in either direction
+// in the log, on the desktop and in the status line
+func (m *Monitor) setConfigError(err error) {
+	previous := m.configErr
+	m.configErr = err
+	switch {
+	case err != nil && previous == nil:
+		logging.Error("Configuration is no longer valid: %v", err)
+		logging.Warning("RescueTime submission paused: completed sessions are kept until a reload (SIGHUP) fixes the configuration")
+		m.alert("RescueTime submission paused", firstLine(err.Error()))
+	case err != nil:
+		logging.Error("Configuration is still invalid: %v", err)
+		if firstLine(err.Error()) != firstLine(previous.Error()) {
+			m.alert("RescueTime submission still paused", firstLine(err.Error()))
+		}
+	case previous != nil:
+		logging.Info("Configuration is valid again: RescueTime submission resumed")
+		m.alert("RescueTime submission resumed", "The held back sessions go out with the next submission")
+	default:
+		logging.Info("Configuration reloaded")
+	}
+	m.publishStatus()
+}
+
+// submissionStatus describes whether the monitor is submitting, for the status line
+func (m *Monitor) submissionStatus() string {
+	switch {
+	case m.DryRun:
+		return "Dry run: previewing submissions"
+	case !m.Submit:
+		return "Tracking, not submitting"
+	case m.configErr != nil:
+		return "RescueTime submission paused: " + firstLine(m.configErr.Error())
+	default:
+		return "Tracking and submitting"
+	}
+}
+
+// publishStatus sends the submission status to Status (systemctl status shows it)
+func (m *Monitor) publishStatus() {
+	if m.Status != nil {
+		m.Status(m.submissionStatus())
+	}
+}
+
+// held reports whether dest is paused by an invalid configuration
+func (m *Monitor) held(dest *destination) bool {
+	if m.configErr == nil {
+		return false
+	}
+	submitter, ok := dest.submitter.(apiKeySubmitter)
+	return ok && submitter.usesAPIKey()
+}
+
+// releasable splits destinations into those to submit to and those held back
+func (m *Monitor) releasable(destinations []*destination) (release, hold []*destination) {
+	for _, dest := range destinations {
+		if m.held(dest) {
+			hold = append(hold, dest)
+		} else {
+			release = append(release, dest)
+		}
+	}
+	return release, hold
+}
+
+// queueHeld hands the sessions held back from destinations to their offline queues, so
+// the next run submits them once its configuration is valid
+func (m *Monitor) queueHeld(destinations []*destination) {
+	snapshots := m.Tracker.DrainCompletedSessions(m.Clock.Now(), cursors(destinations)...)
+	for i, dest := range destinations {
+		completed := snapshots[i].Completed
+		if len(completed) == 0 {
+			continue
+		}
+		if err := dest.submitter.(apiKeySubmitter).queueSummaries(completed); err != nil {
+			logging.Error("%s: failed to queue %d held back activities: %v", dest.name, len(completed), err)
+			continue
+		}
+		logging.Warning("%s: queued %d held back activities for the next run (%v)", dest.name, len(completed), firstLine(m.configErr.Error()))
+	}
+}
+
+// alert shows a desktop notification through Alert, if set
+func (m *Monitor) alert(summary, body string) {
+	if m.Alert != nil {
+		m.Alert(summary, body)
+	}
+}
+
+// desktopNotify shows a desktop notification with notify-send, logging rather than failing
+// when it isn't installed or there's no notification server
+func desktopNotify(summary, body string) {
+	if err := exec.Command("notify-send", "--app-name=active-window", "--urgency=critical", summary, body).Run(); err != nil {
+		logging.Debug("Desktop notification failed: %v", err)
+	}
+}
+
+// systemdStatus sets the unit's status line, shown by systemctl status
+func systemdStatus(status string) {
+	if err := sdNotify(fmt.Sprintf("STATUS=%s", status)); err != nil {
+		logging.Debug("%v", err)
+	}
+}
+
+// firstLine returns s up to its first newline; validateConfiguration's errors put hints on
+// the lines after
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyedSubmitter is a recordingSubmitter that takes RESCUE_TIME_API_KEY, like RescueTime's
+type keyedSubmitter struct {
+	recordingSubmitter
+	keys   []string
+	queued []map[string]ActivitySummary
+}
+
+func (s *keyedSubmitter) usesAPIKey() bool     { return true }
+func (s *keyedSubmitter) setAPIKey(key string) { s.keys = append(s.keys, key) }
+func (s *keyedSubmitter) Name() string         { return "keyed" }
+func (s *keyedSubmitter) queueSummaries(summaries map[string]ActivitySummary) error {
+	s.queued = append(s.queued, summaries)
+	return nil
+}
+
+// reloadMonitor returns a submitting test Monitor with a keyed and an unkeyed submitter,
+// whose reloads read the keys in turn, recording its alerts and status lines
+func reloadMonitor(t *testing.T, keys ...string) (*Monitor, *keyedSubmitter, *recordingSubmitter, *[]string, *[]string) {
+	t.Helper()
+	monitor, submitters, _ := newTestMonitor(t)
+	keyed := &keyedSubmitter{recordingSubmitter: recordingSubmitter{tracker: monitor.Tracker}}
+	monitor.Submitters = []Submitter{keyed, submitters[1]}
+	monitor.Submit = true
+	monitor.ReloadAPIKey = func() (string, error) {
+		key := keys[0]
+		keys = keys[1:]
+		return key, nil
+	}
+	var alerts, statuses []string
+	monitor.Alert = func(summary, body string) { alerts = append(alerts, summary) }
+	monitor.Status = func(status string) { statuses = append(statuses, status) }
+	return monitor, keyed, submitters[1], &alerts, &statuses
+}
+
+// TestReloadPausesAndResumes tests that a reload with a broken key holds RescueTime back
+// while other backends carry on, and that a later reload with a good key resumes it with
+// the held back sessions
+func TestReloadPausesAndResumes(t *testing.T) {
+	const validKey = "0123456789abcdefghijklmnop"
+	monitor, keyed, other, alerts, statuses := reloadMonitor(t, "too-short", "", validKey)
+
+	monitor.onReload()
+	monitor.onSubmitTick()
+	if len(keyed.submissions) != 0 {
+		t.Fatalf("Expected no submission with a too-short key, got %d", len(keyed.submissions))
+	}
+	if len(other.submissions) != 1 || len(other.submissions[0].Completed) != 2 {
+		t.Fatalf("Expected the other backend to get both applications, got %+v", other.submissions)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 2 {
+		t.Errorf("Expected the held back sessions to stay in the tracker, got %d", len(sessions))
+	}
+
+	// Still broken, for a different reason
+	monitor.onReload()
+	monitor.onSubmitTick()
+	if len(keyed.submissions) != 0 || len(keyed.keys) != 0 {
+		t.Fatalf("Expected no submission or key without a key, got %d submissions and keys %v", len(keyed.submissions), keyed.keys)
+	}
+
+	monitor.onReload()
+	if len(keyed.keys) != 1 || keyed.keys[0] != validKey {
+		t.Errorf("Expected the valid key to be handed over, got %v", keyed.keys)
+	}
+	monitor.onSubmitTick()
+	if len(keyed.submissions) != 1 {
+		t.Fatalf("Expected a submission once the key is valid, got %d", len(keyed.submissions))
+	}
+	completed := keyed.submissions[0].Completed
+	if completed["code"].TotalDuration != 20*time.Minute || completed["firefox"].TotalDuration != 10*time.Minute {
+		t.Errorf("Expected the held back sessions in the first submission, got %+v", completed)
+	}
+	if sessions := monitor.Tracker.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected the sessions to be compacted once submitted, got %d", len(sessions))
+	}
+
+	wantAlerts := []string{"RescueTime submission paused", "RescueTime submission still paused", "RescueTime submission resumed"}
+	if strings.Join(*alerts, "|") != strings.Join(wantAlerts, "|") {
+		t.Errorf("Expected alerts %v, got %v", wantAlerts, *alerts)
+	}
+	if len(*statuses) != 3 ||
+		!strings.Contains((*statuses)[0], "paused: RESCUE_TIME_API_KEY appears invalid") ||
+		!strings.Contains((*statuses)[1], "paused: RESCUE_TIME_API_KEY not found") ||
+		(*statuses)[2] != "Tracking and submitting" {
+		t.Errorf("Expected paused, paused and resumed status lines, got %q", *statuses)
+	}
+}
+
+// TestReloadQueuesHeldAtShutdown tests that sessions held back by an invalid configuration
+// go to the offline queue at shutdown instead of being submitted or dropped
+func TestReloadQueuesHeldAtShutdown(t *testing.T) {
+	monitor, keyed, other, _, _ := reloadMonitor(t, "too-short")
+
+	monitor.onReload()
+	monitor.onShutdown()
+	if len(keyed.submissions) != 0 {
+		t.Errorf("Expected no final submission with an invalid key, got %d", len(keyed.submissions))
+	}
+	if len(keyed.queued) != 1 || len(keyed.queued[0]) != 2 {
+		t.Errorf("Expected both applications queued for the next run, got %+v", keyed.queued)
+	}
+	if len(other.submissions) != 1 {
+		t.Errorf("Expected the other backend's final submission, got %d", len(other.submissions))
+	}
+	if !keyed.closed || !other.closed {
+		t.Error("Expected every submitter to be closed")
+	}
+}
+
+// TestRunReloadsOnSignal tests that Run revalidates on its Reloads channel
+func TestRunReloadsOnSignal(t *testing.T) {
+	monitor, keyed, _, alerts, _ := reloadMonitor(t, "too-short")
+	submit := make(chan time.Time)
+	monitor.Clock = &tickerClock{ticks: map[time.Duration]chan time.Time{monitor.SubmitInterval: submit}}
+	reloads := make(chan os.Signal)
+	monitor.Reloads = reloads
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- monitor.Run(ctx) }()
+
+	reloads <- os.Interrupt
+	submit <- time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(*alerts) != 1 || len(keyed.submissions) != 0 || len(keyed.queued) != 1 {
+		t.Errorf("Expected a paused alert and the sessions queued rather than submitted, got alerts %v, %d submissions, %d queued",
+			*alerts, len(keyed.submissions), len(keyed.queued))
+	}
+}
+
+// TestReloadAPIKey tests that an inherited key wins and that .env is otherwise re-read
+func TestReloadAPIKey(t *testing.T) {
+	path := writeConfig(t, ".env", "RESCUE_TIME_API_KEY=from-env-file\nWEBHOOK_URL=https://example.com\n")
+	if key, err := reloadAPIKey(path, "from-op-run"); err != nil || key != "from-op-run" {
+		t.Errorf("Expected the inherited key, got %q, %v", key, err)
+	}
+	if key, err := reloadAPIKey(path, ""); err != nil || key != "from-env-file" {
+		t.Errorf("Expected .env's key, got %q, %v", key, err)
+	}
+
+	// A key removed from .env is gone, not left over from the last load
+	if err := os.WriteFile(path, []byte("WEBHOOK_URL=https://example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := reloadAPIKey(path, ""); err != nil || key != "" {
+		t.Errorf("Expected no key, got %q, %v", key, err)
+	}
+	if _, err := reloadAPIKey(filepath.Join(t.TempDir(), ".env"), ""); err == nil {
+		t.Error("Expected an error for a missing .env")
+	}
+}
+
+// TestRescueTimeSubmitterAPIKey tests that a reloaded key reaches the client on the next
+// Close (or Submit), and only the default account takes it
+func TestRescueTimeSubmitterAPIKey(t *testing.T) {
+	submitter := &rescueTimeSubmitter{client: newRescueTimeClient("old-key")}
+	if !submitter.usesAPIKey() {
+		t.Error("Expected the default account to use RESCUE_TIME_API_KEY")
+	}
+	if (&rescueTimeSubmitter{account: "work"}).usesAPIKey() {
+		t.Error("Expected an -accounts account to use its own key")
+	}
+
+	submitter.setAPIKey("new-key")
+	if submitter.client.APIKey != "old-key" {
+		t.Errorf("Expected the key to wait for the next Submit or Close, got %q", submitter.client.APIKey)
+	}
+	submitter.Close(context.Background())
+	if submitter.client.APIKey != "new-key" {
+		t.Errorf("Expected the reloaded key, got %q", submitter.client.APIKey)
+	}
+}
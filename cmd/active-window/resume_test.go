@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestResumeContinuesSession tests that a restart onto the same window within the resume
+// window continues the session saved at shutdown
+func TestResumeContinuesSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.json")
+	window := &common.MutterWindow{WmClass: "code", Title: "main.go"}
+
+	// Shut down ten minutes into a session: its time is stored, and the restart continues it
+	monitor, _, _ := newTestMonitor(t)
+	monitor.ResumePath = path
+	monitor.ResumeWindow = defaultResumeWindow
+	startSessionAt(monitor.Tracker, "code", "main.go", time.Now().Add(-10*time.Minute))
+	shutdown := time.Now()
+	monitor.onShutdown()
+
+	restarted := newMonitor(NewActivityTracker(), &fakeBackend{windows: []*common.MutterWindow{window}})
+	restarted.Notifier = &recordingNotifier{}
+	restarted.ResumePath = path
+	restarted.ResumeWindow = defaultResumeWindow
+	if err := restarted.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	session, ok := restarted.Tracker.CurrentSession()
+	if !ok || session.AppClass != "code" {
+		t.Fatalf("Expected the code session to be resumed, got %+v", session)
+	}
+	if session.StartTime.Before(shutdown) || session.StartTime.After(time.Now()) {
+		t.Errorf("Expected the resumed session to start at shutdown (%v), got %v", shutdown, session.StartTime)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the resume file to be used once, got %v", err)
+	}
+
+	// The rest of a stored session counts however short it is
+	restarted.Tracker.EndCurrentSession()
+	if sessions := restarted.Tracker.GetSessions(); len(sessions) != 1 || sessions[0].AppClass != "code" {
+		t.Errorf("Expected the code session to be continued, got %+v", sessions)
+	}
+}
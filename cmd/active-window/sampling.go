@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/fatih/color"
+)
+
+// samplingInterval is how often -sampling-report polls: fast enough to see the short
+// windows a -interval of a second or more can miss
+const samplingInterval = 100 * time.Millisecond
+
+// streakBuckets are the upper bounds of the -sampling-report histogram's rows; longer
+// streaks go in a final open-ended row
+var streakBuckets = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// reportIntervals are the poll intervals the report estimates missed focus time for
+var reportIntervals = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// focusHistogram records focus streaks: how long one window (class and title) stayed
+// focused before another took over or the user went idle
+type focusHistogram struct {
+	streaks []time.Duration
+	total   time.Duration
+}
+
+// Add records a focus streak; zero and negative lengths are ignored
+func (h *focusHistogram) Add(streak time.Duration) {
+	if streak <= 0 {
+		return
+	}
+	h.streaks = append(h.streaks, streak)
+	h.total += streak
+}
+
+// streakBucket is one histogram row: streaks shorter than Below (0 for the open-ended row)
+type streakBucket struct {
+	Below time.Duration
+	Count int
+	Time  time.Duration
+}
+
+// Buckets returns the histogram rows for streakBuckets plus the open-ended row
+func (h *focusHistogram) Buckets() []streakBucket {
+	buckets := make([]streakBucket, len(streakBuckets)+1)
+	for i, below := range streakBuckets {
+		buckets[i].Below = below
+	}
+	for _, streak := range h.streaks {
+		i := sort.Search(len(streakBuckets), func(i int) bool { return streak < streakBuckets[i] })
+		buckets[i].Count++
+		buckets[i].Time += streak
+	}
+	return buckets
+}
+
+// MissedFraction estimates the fraction of focus time polling every interval would miss.
+// A poll lands in a streak shorter than interval with probability streak/interval, so on
+// average such a streak loses streak×(1-streak/interval); longer streaks are always seen.
+func (h *focusHistogram) MissedFraction(interval time.Duration) float64 {
+	if h.total <= 0 || interval <= 0 {
+		return 0
+	}
+	var missed float64
+	for _, streak := range h.streaks {
+		if streak < interval {
+			length := float64(streak)
+			missed += length * (1 - length/float64(interval))
+		}
+	}
+	return missed / float64(h.total)
+}
+
+// ShorterThan returns the fraction of focus time in streaks shorter than d
+func (h *focusHistogram) ShorterThan(d time.Duration) float64 {
+	if h.total <= 0 {
+		return 0
+	}
+	var short time.Duration
+	for _, streak := range h.streaks {
+		if streak < d {
+			short += streak
+		}
+	}
+	return float64(short) / float64(h.total)
+}
+
+// streakSampler turns polled windows into focus streaks
+type streakSampler struct {
+	histogram *focusHistogram
+	key       string // class and title of the focused window; "" while idle or unknown
+	since     time.Time
+}
+
+// Observe records the window focused at now (key "" when idle or unreadable), closing the
+// previous streak when it changed
+func (s *streakSampler) Observe(key string, now time.Time) {
+	if key == s.key {
+		return
+	}
+	if s.key != "" {
+		s.histogram.Add(now.Sub(s.since))
+	}
+	s.key = key
+	s.since = now
+}
+
+// runSamplingReport polls backend every samplingInterval for period, or until ctx ends, and
+// returns the focus streaks it saw. Time idle for idleThreshold or more isn't a streak.
+// Nothing is tracked, stored or submitted.
+func runSamplingReport(ctx context.Context, backend WindowBackend, clock Clock, period, idleThreshold time.Duration) *focusHistogram {
+	sampler := &streakSampler{histogram: &focusHistogram{}}
+	ticker := clock.NewTicker(samplingInterval)
+	defer ticker.Stop()
+
+	deadline := clock.Now().Add(period)
+	for {
+		now := clock.Now()
+		key := ""
+		if idle, err := backend.IdleTime(); err != nil || idle < idleThreshold {
+			if window, err := backend.ActiveWindow(); err == nil {
+				key = window.WmClass + "\x00" + window.Title
+			} else {
+				logging.Debug("Sampling: %v", err)
+			}
+		}
+		if !now.Before(deadline) {
+			key = "" // close the last streak at the deadline
+		}
+		sampler.Observe(key, now)
+		if !now.Before(deadline) {
+			return sampler.histogram
+		}
+
+		select {
+		case <-ctx.Done():
+			sampler.Observe("", clock.Now())
+			return sampler.histogram
+		case <-ticker.C():
+		}
+	}
+}
+
+// printSamplingReport prints the streak histogram and the estimated focus time missed at
+// each of reportIntervals, against the current poll interval and the session minimum
+func printSamplingReport(histogram *focusHistogram, pollInterval time.Duration) {
+	color.New(color.FgCyan, color.Bold).Println("\n=== Focus Sampling Report ===")
+	if len(histogram.streaks) == 0 {
+		color.Yellow("No focus streaks recorded.")
+		return
+	}
+	color.New(color.FgWhite, color.Bold).Printf("%d focus streaks over %s, sampled every %v\n\n", len(histogram.streaks), HumanDuration(histogram.total), samplingInterval)
+
+	fmt.Println("Streak length     Streaks   Focus time")
+	for i, bucket := range histogram.Buckets() {
+		label := fmt.Sprintf("< %v", bucket.Below)
+		if bucket.Below == 0 {
+			label = fmt.Sprintf(">= %v", streakBuckets[i-1])
+		}
+		fmt.Printf("%-16s %8d   %5.1f%%\n", label, bucket.Count, float64(bucket.Time)/float64(histogram.total)*100)
+	}
+
+	fmt.Println("\nPoll interval     Focus time missed (estimate)")
+	for _, interval := range reportIntervals {
+		line := fmt.Sprintf("%-16v  %5.1f%%", interval, histogram.MissedFraction(interval)*100)
+		if interval == pollInterval {
+			color.New(color.FgGreen, color.Bold).Println(line + "   <- current -interval")
+		} else {
+			fmt.Println(line)
+		}
+	}
+
+	color.New(color.FgHiBlack).Printf("\n%.1f%% of focus time is in streaks under the %v session minimum, which tracking drops at any interval.\n", histogram.ShorterThan(defaultMinDuration)*100, defaultMinDuration)
+	color.New(color.FgHiBlack).Printf("Streaks under %v are invisible to this report too.\n", samplingInterval)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// TestFocusHistogramMissedFraction tests the bucketing and the missed-time estimate on a
+// synthetic stream: an hour of long streaks with 60 half-second glances in between
+func TestFocusHistogramMissedFraction(t *testing.T) {
+	histogram := &focusHistogram{}
+	for i := 0; i < 60; i++ {
+		histogram.Add(time.Minute - 500*time.Millisecond)
+		histogram.Add(500 * time.Millisecond)
+	}
+	histogram.Add(0) // ignored
+
+	buckets := histogram.Buckets()
+	if len(buckets) != len(streakBuckets)+1 {
+		t.Fatalf("Expected %d buckets, got %d", len(streakBuckets)+1, len(buckets))
+	}
+	if buckets[2].Below != time.Second || buckets[2].Count != 60 || buckets[2].Time != 30*time.Second {
+		t.Errorf("Expected the glances in the < 1s row, got %+v", buckets[2])
+	}
+	if last := buckets[len(buckets)-1]; last.Below != 0 || last.Count != 0 {
+		t.Errorf("Expected nothing of a minute or more, got %+v", last)
+	}
+	if buckets[len(buckets)-2].Count != 60 {
+		t.Errorf("Expected the long streaks in the < 1m row, got %+v", buckets[len(buckets)-2])
+	}
+
+	// Glances are 1/120 of the time: always seen at 500ms, half lost on average at 1s,
+	// three quarters at 2s; the long streaks are seen at every interval
+	for _, tt := range []struct {
+		interval time.Duration
+		want     float64
+	}{
+		{250 * time.Millisecond, 0},
+		{500 * time.Millisecond, 0},
+		{time.Second, 0.5 / 120},
+		{2 * time.Second, 0.75 / 120},
+	} {
+		if got := histogram.MissedFraction(tt.interval); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%v: expected %.5f missed, got %.5f", tt.interval, tt.want, got)
+		}
+	}
+	if got := histogram.ShorterThan(defaultMinDuration); math.Abs(got-1.0/120) > 1e-9 {
+		t.Errorf("Expected 1/120 of the time under the session minimum, got %.5f", got)
+	}
+	if got := (&focusHistogram{}).MissedFraction(time.Second); got != 0 {
+		t.Errorf("Expected nothing missed without streaks, got %v", got)
+	}
+}
+
+// steppingClock advances by samplingInterval on every Now, and its tickers always fire
+type steppingClock struct{ now time.Time }
+
+func (c *steppingClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(samplingInterval)
+	return now
+}
+
+func (c *steppingClock) NewTicker(time.Duration) Ticker {
+	ch := make(chan time.Time)
+	close(ch)
+	return fakeTicker(ch)
+}
+
+// TestRunSamplingReport tests that polled windows become streaks, a title change is a new
+// streak, and the last streak is closed at the end of the period
+func TestRunSamplingReport(t *testing.T) {
+	var windows []*common.MutterWindow
+	for i := 0; i < 20; i++ {
+		windows = append(windows, &common.MutterWindow{WmClass: "code", Title: "main.go"})
+	}
+	for i := 0; i < 3; i++ {
+		windows = append(windows, &common.MutterWindow{WmClass: "slack", Title: "general"})
+	}
+	windows = append(windows, &common.MutterWindow{WmClass: "code", Title: "monitor.go"})
+	backend := &fakeBackend{windows: windows}
+	clock := &steppingClock{now: time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)}
+
+	histogram := runSamplingReport(context.Background(), backend, clock, 6*time.Second, defaultIdleThreshold)
+
+	want := []time.Duration{2 * time.Second, 300 * time.Millisecond, 3600 * time.Millisecond}
+	if len(histogram.streaks) != len(want) {
+		t.Fatalf("Expected streaks %v, got %v", want, histogram.streaks)
+	}
+	for i := range want {
+		if histogram.streaks[i] != want[i] {
+			t.Errorf("Streak %d: expected %v, got %v", i, want[i], histogram.streaks[i])
+		}
+	}
+
+	// Idle time isn't focus time
+	idle := &fakeBackend{windows: windows[:1], idle: 2 * defaultIdleThreshold}
+	if histogram := runSamplingReport(context.Background(), idle, clock, time.Second, defaultIdleThreshold); len(histogram.streaks) != 0 {
+		t.Errorf("Expected no streaks while idle, got %v", histogram.streaks)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/godbus/dbus/v5"
+)
+
+// Startup wait for the graphical session. systemd user units can start before the session
+// has imported WAYLAND_DISPLAY/DISPLAY or before GNOME Shell has loaded the extension.
+const (
+	defaultSessionWait      = 2 * time.Minute
+	sessionWaitInitialDelay = 1 * time.Second
+	sessionWaitMaxDelay     = 15 * time.Second
+	sessionWaitNotifySlack  = 5 * time.Second // Extra startup time requested from systemd to cover a probe
+)
+
+// focusedWindowExtensionUUID identifies the FocusedWindow extension to GNOME Shell
+const focusedWindowExtensionUUID = "focused-window-dbus@nichijou.github.io"
+
+// errExtensionNotInstalled means GNOME Shell is running but doesn't have the FocusedWindow
+// extension, so waiting for it is pointless
+var errExtensionNotInstalled = errors.New("the FocusedWindow GNOME Shell extension is not installed")
+
+// probeGraphicalSession checks what tracking actually needs: the FocusedWindow D-Bus
+// extension on the session bus. Display variables only make the error more helpful.
+func probeGraphicalSession() error {
+	_, err := getActiveWindow()
+	if err == nil {
+		return nil
+	}
+	if installed, shellErr := extensionInstalled(focusedWindowExtensionUUID); shellErr == nil && !installed {
+		return fmt.Errorf("%w (%s)", errExtensionNotInstalled, focusedWindowExtensionUUID)
+	}
+	if os.Getenv("WAYLAND_DISPLAY") == "" && os.Getenv("DISPLAY") == "" {
+		return fmt.Errorf("%v (neither WAYLAND_DISPLAY nor DISPLAY is set yet)", err)
+	}
+	return err
+}
+
+// extensionInstalled asks GNOME Shell whether it has the extension uuid. An error means Shell
+// couldn't be asked, e.g. because it hasn't started yet.
+func extensionInstalled(uuid string) (bool, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to session bus: %v", err)
+	}
+	defer conn.Close()
+
+	var info map[string]dbus.Variant
+	obj := conn.Object(common.DbusDestination, "/org/gnome/Shell")
+	if err := obj.Call("org.gnome.Shell.Extensions.GetExtensionInfo", 0, uuid).Store(&info); err != nil {
+		return false, fmt.Errorf("failed to query GNOME Shell extensions: %v", err)
+	}
+	// Shell answers with an empty dictionary for an extension it doesn't know
+	return len(info) > 0, nil
+}
+
+// delayStartup waits -startup-delay before the first probe, giving GNOME Shell time to load
+// its extensions after login, and asks systemd for the extra startup time
+func delayStartup(delay time.Duration, extend func(time.Duration), sleep func(time.Duration)) {
+	if delay <= 0 {
+		return
+	}
+	logging.Info("Waiting %v for the desktop to settle", delay)
+	extend(delay + sessionWaitNotifySlack)
+	sleep(delay)
+}
+
+// waitForSession calls probe until it succeeds or grace has elapsed, backing off exponentially
+// between attempts. Before each wait, extend is called with how much longer startup may take
+// (systemd's EXTEND_TIMEOUT_USEC). A grace of 0 probes once, and errExtensionNotInstalled
+// stops at once: it isn't going to appear. sleep and now are injectable for tests.
+func waitForSession(grace time.Duration, probe func() error, extend func(time.Duration), sleep func(time.Duration), now func() time.Time) error {
+	deadline := now().Add(grace)
+	delay := sessionWaitInitialDelay
+
+	for attempt := 1; ; attempt++ {
+		err := probe()
+		if err == nil {
+			if attempt > 1 {
+				logging.Info("Graphical session available after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		if errors.Is(err, errExtensionNotInstalled) {
+			return err
+		}
+
+		remaining := deadline.Sub(now())
+		if remaining <= 0 {
+			if grace > 0 {
+				return fmt.Errorf("graphical session did not become available within %v: %v", grace, err)
+			}
+			return err
+		}
+		if attempt == 1 {
+			logging.Warning("Graphical session not ready, waiting up to %v: %v", grace, err)
+		} else {
+			logging.Debug("Session probe %d failed: %v", attempt, err)
+		}
+
+		if delay > remaining {
+			delay = remaining
+		}
+		extend(delay + sessionWaitNotifySlack)
+		sleep(delay)
+
+		delay *= 2
+		if delay > sessionWaitMaxDelay {
+			delay = sessionWaitMaxDelay
+		}
+	}
+}
+
+// sdNotify sends a state line (e.g. "READY=1") to systemd's notify socket.
+// It's a no-op when not running under systemd (NOTIFY_SOCKET unset).
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract namespace sockets are given with a leading '@'
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd notify socket: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return nil
+}
+
+// extendStartupTimeout asks systemd to allow d more time for startup (Type=notify units)
+func extendStartupTimeout(d time.Duration) {
+	if err := sdNotify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", d.Microseconds())); err != nil {
+		logging.Debug("%v", err)
+	}
+}
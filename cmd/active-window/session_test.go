@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock advanced only by its sleep function
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+// TestWaitForSessionSucceeds tests that a session appearing during the grace period is picked up
+func TestWaitForSessionSucceeds(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 10, 29, 8, 0, 0, 0, time.UTC)}
+	probes := 0
+	probe := func() error {
+		probes++
+		if probes < 4 {
+			return errors.New("org.gnome.Shell not available")
+		}
+		return nil
+	}
+	var extensions []time.Duration
+	extend := func(d time.Duration) { extensions = append(extensions, d) }
+
+	if err := waitForSession(defaultSessionWait, probe, extend, clock.Sleep, clock.Now); err != nil {
+		t.Fatalf("Expected session to become available, got %v", err)
+	}
+	if probes != 4 {
+		t.Errorf("Expected 4 probes, got %d", probes)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(clock.sleeps) != len(want) {
+		t.Fatalf("Expected backoff %v, got %v", want, clock.sleeps)
+	}
+	for i := range want {
+		if clock.sleeps[i] != want[i] {
+			t.Errorf("Sleep %d: expected %v, got %v", i, want[i], clock.sleeps[i])
+		}
+		if extensions[i] != want[i]+sessionWaitNotifySlack {
+			t.Errorf("Extension %d: expected %v, got %v", i, want[i]+sessionWaitNotifySlack, extensions[i])
+		}
+	}
+}
+
+// TestWaitForSessionTimeout tests that waiting gives up once the grace period is used up
+func TestWaitForSessionTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 10, 29, 8, 0, 0, 0, time.UTC)}
+	probes := 0
+	probe := func() error {
+		probes++
+		return errors.New("org.gnome.Shell not available")
+	}
+
+	grace := 40 * time.Second
+	if err := waitForSession(grace, probe, func(time.Duration) {}, clock.Sleep, clock.Now); err == nil {
+		t.Fatal("Expected error when the session never appears")
+	}
+
+	// 1+2+4+8+15 = 30s, then the last wait is clamped to the remaining 10s
+	var total time.Duration
+	for _, d := range clock.sleeps {
+		if d > sessionWaitMaxDelay {
+			t.Errorf("Sleep %v exceeds the %v cap", d, sessionWaitMaxDelay)
+		}
+		total += d
+	}
+	if total != grace {
+		t.Errorf("Expected to wait exactly %v, waited %v (%v)", grace, total, clock.sleeps)
+	}
+	if probes != len(clock.sleeps)+1 {
+		t.Errorf("Expected a final probe after the last wait, got %d probes for %d waits", probes, len(clock.sleeps))
+	}
+
+	// No grace period: a single probe and no waiting
+	clock.sleeps = nil
+	probes = 0
+	if err := waitForSession(0, probe, func(time.Duration) {}, clock.Sleep, clock.Now); err == nil {
+		t.Error("Expected error with no grace period")
+	}
+	if probes != 1 || len(clock.sleeps) != 0 {
+		t.Errorf("Expected 1 probe and no waits, got %d probes and %v", probes, clock.sleeps)
+	}
+}
+
+// TestWaitForSessionNotInstalled tests that a missing extension fails at once instead of
+// waiting out the grace period like an extension that isn't loaded yet
+func TestWaitForSessionNotInstalled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 10, 29, 8, 0, 0, 0, time.UTC)}
+	probes := 0
+	probe := func() error {
+		probes++
+		if probes < 3 {
+			return errors.New("org.gnome.Shell not available")
+		}
+		return fmt.Errorf("%w (%s)", errExtensionNotInstalled, focusedWindowExtensionUUID)
+	}
+
+	err := waitForSession(defaultSessionWait, probe, func(time.Duration) {}, clock.Sleep, clock.Now)
+	if !errors.Is(err, errExtensionNotInstalled) {
+		t.Fatalf("Expected errExtensionNotInstalled, got %v", err)
+	}
+	if probes != 3 || len(clock.sleeps) != 2 {
+		t.Errorf("Expected to stop at the probe that found Shell without the extension, got %d probes and %v", probes, clock.sleeps)
+	}
+}
+
+// TestDelayStartup tests that -startup-delay waits before the first probe and asks systemd
+// for the time, and that 0 doesn't wait
+func TestDelayStartup(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 10, 29, 8, 0, 0, 0, time.UTC)}
+	var extensions []time.Duration
+	extend := func(d time.Duration) { extensions = append(extensions, d) }
+
+	delayStartup(0, extend, clock.Sleep)
+	if len(clock.sleeps) != 0 || len(extensions) != 0 {
+		t.Errorf("Expected no wait without a delay, got %v", clock.sleeps)
+	}
+
+	delayStartup(5*time.Second, extend, clock.Sleep)
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 5*time.Second {
+		t.Errorf("Expected a 5s wait, got %v", clock.sleeps)
+	}
+	if len(extensions) != 1 || extensions[0] != 5*time.Second+sessionWaitNotifySlack {
+		t.Errorf("Expected systemd to be asked for %v, got %v", 5*time.Second+sessionWaitNotifySlack, extensions)
+	}
+}
+
+// TestSdNotify tests that state lines reach the systemd notify socket
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("Expected no-op without NOTIFY_SOCKET, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := sdNotify("EXTEND_TIMEOUT_USEC=6000000"); err != nil {
+		t.Fatalf("sdNotify failed: %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if got := string(buf[:n]); got != "EXTEND_TIMEOUT_USEC=6000000" {
+		t.Errorf("Expected EXTEND_TIMEOUT_USEC=6000000, got %q", got)
+	}
+}
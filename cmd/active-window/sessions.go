@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+)
+
+// defaultSessionsListLimit is how many sessions "sessions list" shows without -limit
+const defaultSessionsListLimit = 20
+
+// sessionsUsage describes the sessions subcommand, shown with its parse errors
+const sessionsUsage = `usage:
+  active-window [flags] sessions list [-limit N]
+  active-window [flags] sessions delete ID
+  active-window [flags] sessions edit ID [-start TIME] [-end TIME] [-app CLASS]
+
+TIME is RFC3339 or local "YYYY-MM-DD HH:MM[:SS]"`
+
+// sessionStore is the local storage the sessions subcommand works on (implemented by *postgres.Client)
+type sessionStore interface {
+	GetRecentSessions(limit int) ([]postgres.ActivitySession, error)
+	DeleteSession(id int64) error
+	EditSession(id int64, newStart, newEnd time.Time, newAppClass string) error
+}
+
+// sessionsCommand is a parsed "sessions" subcommand
+type sessionsCommand struct {
+	Action   string // "list", "delete" or "edit"
+	ID       int64  // delete and edit
+	Limit    int    // list
+	Start    time.Time
+	End      time.Time
+	AppClass string
+}
+
+// parseSessionsCommand parses the arguments after "sessions"
+func parseSessionsCommand(args []string) (sessionsCommand, error) {
+	if len(args) == 0 {
+		return sessionsCommand{}, fmt.Errorf("missing action\n\n%s", sessionsUsage)
+	}
+	command := sessionsCommand{Action: args[0]}
+	args = args[1:]
+
+	fs := flag.NewFlagSet("sessions "+command.Action, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	switch command.Action {
+	case "list":
+		fs.IntVar(&command.Limit, "limit", defaultSessionsListLimit, "number of sessions to show")
+		if err := fs.Parse(args); err != nil {
+			return sessionsCommand{}, fmt.Errorf("%v\n\n%s", err, sessionsUsage)
+		}
+		if fs.NArg() > 0 {
+			return sessionsCommand{}, fmt.Errorf("unexpected argument %q\n\n%s", fs.Arg(0), sessionsUsage)
+		}
+		if command.Limit <= 0 {
+			return sessionsCommand{}, fmt.Errorf("-limit must be positive, got %d", command.Limit)
+		}
+
+	case "delete", "edit":
+		if len(args) == 0 {
+			return sessionsCommand{}, fmt.Errorf("%s needs a session ID\n\n%s", command.Action, sessionsUsage)
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || id <= 0 {
+			return sessionsCommand{}, fmt.Errorf("invalid session ID %q", args[0])
+		}
+		command.ID = id
+
+		var start, end string
+		if command.Action == "edit" {
+			fs.StringVar(&start, "start", "", "new start time")
+			fs.StringVar(&end, "end", "", "new end time")
+			fs.StringVar(&command.AppClass, "app", "", "new application class")
+		}
+		if err := fs.Parse(args[1:]); err != nil {
+			return sessionsCommand{}, fmt.Errorf("%v\n\n%s", err, sessionsUsage)
+		}
+		if fs.NArg() > 0 {
+			return sessionsCommand{}, fmt.Errorf("unexpected argument %q\n\n%s", fs.Arg(0), sessionsUsage)
+		}
+		if command.Action == "delete" {
+			break
+		}
+
+		if command.Start, err = parseSessionTime("-start", start); err != nil {
+			return sessionsCommand{}, err
+		}
+		if command.End, err = parseSessionTime("-end", end); err != nil {
+			return sessionsCommand{}, err
+		}
+		command.AppClass = strings.TrimSpace(command.AppClass)
+		if command.Start.IsZero() && command.End.IsZero() && command.AppClass == "" {
+			return sessionsCommand{}, fmt.Errorf("edit needs at least one of -start, -end or -app\n\n%s", sessionsUsage)
+		}
+
+	default:
+		return sessionsCommand{}, fmt.Errorf("unknown action %q\n\n%s", command.Action, sessionsUsage)
+	}
+	return command, nil
+}
+
+// parseSessionTime parses an edit's -start or -end value; "" is the zero time (unchanged)
+func parseSessionTime(name, value string) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), dayLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s time %q (expected RFC3339 or YYYY-MM-DD HH:MM[:SS])", name, value)
+}
+
+// runSessionsCommand lists, deletes or edits stored sessions, reporting to w
+func runSessionsCommand(store sessionStore, command sessionsCommand, w io.Writer) error {
+	switch command.Action {
+	case "list":
+		sessions, err := store.GetRecentSessions(command.Limit)
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			fmt.Fprintln(w, "No stored sessions.")
+			return nil
+		}
+		for _, session := range sessions {
+			fmt.Fprintf(w, "%8d  %s  %-9s %-24s %s", session.ID, display.LocalTimestamp(session.StartTime), HumanDuration(session.Duration), session.AppClass, session.WindowTitle)
+			// Few of its polls saw it, so the duration is an estimate
+			if lowCoverage(session.Coverage, minCoverage) {
+				fmt.Fprintf(w, "  [%.0f%% of polls]", session.Coverage*100)
+			}
+			fmt.Fprintln(w)
+		}
+
+	case "delete":
+		if err := store.DeleteSession(command.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Deleted session %d (it no longer counts in reports or -push-history)\n", command.ID)
+
+	case "edit":
+		if err := store.EditSession(command.ID, command.Start, command.End, command.AppClass); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Edited session %d\n", command.ID)
+	}
+	return nil
+}
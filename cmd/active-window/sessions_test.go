@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/postgres"
+)
+
+// TestParseSessionsCommand tests the sessions subcommand's actions, flags and errors
+func TestParseSessionsCommand(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		args    string
+		want    sessionsCommand
+		wantErr string
+	}{
+		{args: "list", want: sessionsCommand{Action: "list", Limit: defaultSessionsListLimit}},
+		{args: "list -limit 5", want: sessionsCommand{Action: "list", Limit: 5}},
+		{args: "delete 42", want: sessionsCommand{Action: "delete", ID: 42}},
+		{args: "edit 42 -end 2025-10-29T09:00:00", want: sessionsCommand{Action: "edit", ID: 42, End: start}},
+		{args: "edit 42 -start 2025-10-29T08:00:00 -app mpv", want: sessionsCommand{Action: "edit", ID: 42, Start: start.Add(-time.Hour), AppClass: "mpv"}},
+		{args: "", wantErr: "missing action"},
+		{args: "purge", wantErr: "unknown action"},
+		{args: "list -limit 0", wantErr: "must be positive"},
+		{args: "list extra", wantErr: "unexpected argument"},
+		{args: "delete", wantErr: "needs a session ID"},
+		{args: "delete abc", wantErr: "invalid session ID"},
+		{args: "delete -3", wantErr: "invalid session ID"},
+		{args: "delete 42 -app mpv", wantErr: "not defined"},
+		{args: "edit 42", wantErr: "at least one of"},
+		{args: "edit 42 -end yesterday", wantErr: "invalid -end time"},
+		{args: "edit -end 2025-10-29T09:00:00 42", wantErr: "invalid session ID"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSessionsCommand(strings.Fields(tt.args))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: expected an error containing %q, got %v", tt.args, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.args, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.args, tt.want, got)
+		}
+	}
+}
+
+// fakeSessionStore serves seeded sessions, soft-deleting and editing them in memory
+type fakeSessionStore struct {
+	sessions []postgres.ActivitySession
+	deleted  map[int64]bool
+}
+
+func (f *fakeSessionStore) GetRecentSessions(limit int) ([]postgres.ActivitySession, error) {
+	var result []postgres.ActivitySession
+	for _, session := range f.sessions {
+		if !f.deleted[session.ID] && len(result) < limit {
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeSessionStore) DeleteSession(id int64) error {
+	for _, session := range f.sessions {
+		if session.ID == id && !f.deleted[id] {
+			f.deleted[id] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", postgres.ErrSessionNotFound, id)
+}
+
+func (f *fakeSessionStore) EditSession(id int64, newStart, newEnd time.Time, newAppClass string) error {
+	for i, session := range f.sessions {
+		if session.ID == id && !f.deleted[id] {
+			if !newEnd.IsZero() {
+				f.sessions[i].EndTime = newEnd
+				f.sessions[i].Duration = newEnd.Sub(session.StartTime)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", postgres.ErrSessionNotFound, id)
+}
+
+// TestRunSessionsCommand tests that deleted sessions drop out of the list and that a
+// missing session is reported
+func TestRunSessionsCommand(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	store := &fakeSessionStore{deleted: make(map[int64]bool), sessions: []postgres.ActivitySession{
+		{ID: 1, StartTime: start, EndTime: start.Add(3 * time.Hour), AppClass: "mpv", Duration: 3 * time.Hour},
+		{ID: 2, StartTime: start.Add(3 * time.Hour), EndTime: start.Add(4 * time.Hour), AppClass: "code", Duration: time.Hour},
+	}}
+	run := func(args string) (string, error) {
+		command, err := parseSessionsCommand(strings.Fields(args))
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		var out bytes.Buffer
+		err = runSessionsCommand(store, command, &out)
+		return out.String(), err
+	}
+
+	if _, err := run("edit 1 -end 2025-10-29T09:30:00"); err != nil {
+		t.Fatalf("edit failed: %v", err)
+	}
+	if _, err := run("delete 2"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	out, err := run("list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !strings.Contains(out, "mpv") || !strings.Contains(out, "30m") || strings.Contains(out, "code") {
+		t.Errorf("Expected only the edited mpv session listed, got:\n%s", out)
+	}
+
+	if _, err := run("delete 2"); !errors.Is(err, postgres.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound deleting twice, got %v", err)
+	}
+}
+
+// TestTimesParsedInDayLocation tests that session edits and imports read wall-clock times in the -timezone zone.
+func TestTimesParsedInDayLocation(t *testing.T) {
+	saved := dayLocation
+	t.Cleanup(func() { dayLocation = saved })
+	dayLocation = time.FixedZone("UTC+5", 5*3600)
+	want := time.Date(2025, 10, 29, 9, 0, 0, 0, dayLocation)
+
+	if got, err := parseSessionTime("-start", "2025-10-29T09:00:00"); err != nil || !got.Equal(want) {
+		t.Errorf("parseSessionTime = %v, %v; want %v", got, err, want)
+	}
+	if got, err := parseCSVTime("2025-10-29T09:00:00", ""); err != nil || !got.Equal(want) {
+		t.Errorf("parseCSVTime = %v, %v; want %v", got, err, want)
+	}
+
+	export := "Date,Time Spent (seconds),Number of People,Activity,Category,Productivity\n2025-10-29T09:00:00,300,1,firefox,Browsers,0\n"
+	var starts []time.Time
+	if _, err := parseRescueTimeExport(strings.NewReader(export), func(s postgres.ActivitySession) error {
+		starts = append(starts, s.StartTime)
+		return nil
+	}); err != nil {
+		t.Fatalf("parseRescueTimeExport failed: %v", err)
+	}
+	if len(starts) != 1 || !starts[0].Equal(want) {
+		t.Errorf("Expected export row to start at %v, got %v", want, starts)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// Window source names for -sources
+const (
+	sourceMutter = "mutter" // FocusedWindow GNOME Shell extension over D-Bus
+	sourceX11    = "x11"    // _NET_ACTIVE_WINDOW via xprop
+	sourceFIFO   = "fifo"   // lines written to -window-fifo by another program
+)
+
+const (
+	// sourceSwitchAfter is how many polls in a row the source in use may fail before the
+	// chain moves to the next one
+	sourceSwitchAfter = 5
+	// sourceRetryInterval is how often a higher-priority source is tried again after a switch
+	sourceRetryInterval = time.Minute
+)
+
+// WindowSource reports the focused window. It returns an error rather than a nil window
+// when it can't tell.
+type WindowSource interface {
+	ActiveWindow() (*common.MutterWindow, error)
+}
+
+// namedWindowSource is a WindowSource with its -sources name, for log messages
+type namedWindowSource struct {
+	name   string
+	source WindowSource
+}
+
+// FallbackWindowSource tries its sources in priority order and uses the first that returns
+// a window. A poll the source in use fails is answered by the next source that works; after
+// sourceSwitchAfter failures in a row the chain switches to that source, and retries the
+// ones above it every sourceRetryInterval so it returns once the preferred source recovers.
+type FallbackWindowSource struct {
+	sources []namedWindowSource
+	now     func() time.Time
+
+	active    int       // index of the source in use
+	failures  int       // consecutive failures of the source in use
+	lastRetry time.Time // when the sources above active were last tried
+}
+
+// newFallbackWindowSource creates a FallbackWindowSource preferring sources in order
+func newFallbackWindowSource(sources []namedWindowSource, now func() time.Time) *FallbackWindowSource {
+	return &FallbackWindowSource{sources: sources, now: now}
+}
+
+// Active returns the name of the source in use
+func (f *FallbackWindowSource) Active() string {
+	return f.sources[f.active].name
+}
+
+// ActiveWindow returns the focused window from the first working source
+func (f *FallbackWindowSource) ActiveWindow() (*common.MutterWindow, error) {
+	// Go back up the chain now and then, in case a preferred source recovered
+	if f.active > 0 && f.now().Sub(f.lastRetry) >= sourceRetryInterval {
+		f.lastRetry = f.now()
+		for i := 0; i < f.active; i++ {
+			if window, err := f.sources[i].source.ActiveWindow(); err == nil && window != nil {
+				logging.Info("Window source %s is working again, switching back from %s", f.sources[i].name, f.Active())
+				f.active, f.failures = i, 0
+				return window, nil
+			}
+		}
+	}
+
+	window, err := f.sources[f.active].source.ActiveWindow()
+	if err == nil && window != nil {
+		f.failures = 0
+		return window, nil
+	}
+	if err == nil {
+		err = errors.New("no window")
+	}
+	f.failures++
+	failed, lastErr := f.Active(), err
+
+	for i := f.active + 1; i < len(f.sources); i++ {
+		window, err := f.sources[i].source.ActiveWindow()
+		if err != nil || window == nil {
+			continue
+		}
+		if f.failures >= sourceSwitchAfter {
+			logging.Warning("Window source %s failed %d times in a row (%v), switching to %s", failed, f.failures, lastErr, f.sources[i].name)
+			f.active, f.failures = i, 0
+			f.lastRetry = f.now()
+		}
+		return window, nil
+	}
+	return nil, fmt.Errorf("no window source returned a window (%s: %v)", failed, lastErr)
+}
+
+// sourceBackend is the WindowBackend for -sources: the focused window from the chain, the
+// open windows (only the FocusedWindow extension lists them) and idle time from Mutter
+type sourceBackend struct {
+	windows WindowSource
+}
+
+func (b sourceBackend) ActiveWindow() (*common.MutterWindow, error) { return b.windows.ActiveWindow() }
+func (b sourceBackend) ListWindows() ([]common.MutterWindow, error) { return getWindowList() }
+func (b sourceBackend) IdleTime() (time.Duration, error)            { return getIdleTime() }
+
+// parseWindowSources parses -sources, e.g. "mutter,x11,fifo"
+func parseWindowSources(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case sourceMutter, sourceX11, sourceFIFO:
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("unknown window source %q (valid: %s, %s, %s)", name, sourceMutter, sourceX11, sourceFIFO)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("window source %q is listed twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no window sources given")
+	}
+	return names, nil
+}
+
+// newWindowBackend returns the WindowBackend for the -sources names. Mutter alone is the
+// plain D-Bus backend; anything else goes through a FallbackWindowSource.
+func newWindowBackend(names []string, fifoPath string) (WindowBackend, error) {
+	if len(names) == 1 && names[0] == sourceMutter {
+		return dbusBackend{}, nil
+	}
+	sources := make([]namedWindowSource, 0, len(names))
+	for _, name := range names {
+		var source WindowSource
+		switch name {
+		case sourceMutter:
+			source = dbusBackend{}
+		case sourceX11:
+			source = x11Source{run: runCommand}
+		case sourceFIFO:
+			if fifoPath == "" {
+				return nil, errors.New("the fifo window source needs -window-fifo")
+			}
+			source = newFIFOSource(fifoPath)
+		}
+		sources = append(sources, namedWindowSource{name: name, source: source})
+	}
+	return sourceBackend{windows: newFallbackWindowSource(sources, time.Now)}, nil
+}
+
+// runCommand runs a command and returns its standard output
+func runCommand(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// x11Source reads the focused window from the X server's _NET_ACTIVE_WINDOW with xprop,
+// for sessions without the FocusedWindow extension (or XWayland-only fallbacks)
+type x11Source struct {
+	run func(name string, args ...string) ([]byte, error)
+}
+
+var (
+	xpropWindowID = regexp.MustCompile(`window id # (0x[0-9a-fA-F]+)`)
+	xpropProperty = regexp.MustCompile(`(?m)^([A-Z_]+)\([A-Z0-9_]+\) = (.*)$`)
+	xpropString   = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+func (s x11Source) ActiveWindow() (*common.MutterWindow, error) {
+	out, err := s.run("xprop", "-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return nil, fmt.Errorf("xprop -root failed: %v", err)
+	}
+	match := xpropWindowID.FindSubmatch(out)
+	if match == nil || string(match[1]) == "0x0" {
+		return nil, errors.New("X server reports no focused window")
+	}
+	id := string(match[1])
+	out, err = s.run("xprop", "-id", id, "WM_CLASS", "_NET_WM_NAME", "WM_NAME", "_NET_WM_PID")
+	if err != nil {
+		return nil, fmt.Errorf("xprop -id %s failed: %v", id, err)
+	}
+	window := parseXpropWindow(string(out))
+	window.Id, _ = strconv.ParseUint(strings.TrimPrefix(id, "0x"), 16, 64)
+	window.Focus = true
+	return window, nil
+}
+
+// parseXpropWindow reads WM_CLASS (instance, class), the title (_NET_WM_NAME, else
+// WM_NAME) and _NET_WM_PID from xprop -id output
+func parseXpropWindow(out string) *common.MutterWindow {
+	window := &common.MutterWindow{}
+	var wmName string
+	for _, property := range xpropProperty.FindAllStringSubmatch(out, -1) {
+		strs := xpropStrings(property[2])
+		switch property[1] {
+		case "WM_CLASS":
+			if len(strs) > 0 {
+				window.WmClassInstance = strs[0]
+			}
+			if len(strs) > 1 {
+				window.WmClass = strs[1]
+			}
+		case "_NET_WM_NAME":
+			if len(strs) > 0 {
+				window.Title = strs[0]
+			}
+		case "WM_NAME":
+			if len(strs) > 0 {
+				wmName = strs[0]
+			}
+		case "_NET_WM_PID":
+			pid, _ := strconv.ParseInt(strings.TrimSpace(property[2]), 10, 32)
+			window.Pid = int32(pid)
+		}
+	}
+	if window.Title == "" {
+		window.Title = wmName
+	}
+	return window
+}
+
+// xpropStrings returns the quoted strings in an xprop value, unescaped
+func xpropStrings(value string) []string {
+	var strs []string
+	for _, match := range xpropString.FindAllStringSubmatch(value, -1) {
+		if unquoted, err := strconv.Unquote(`"` + match[1] + `"`); err == nil {
+			strs = append(strs, unquoted)
+		} else {
+			strs = append(strs, match[1])
+		}
+	}
+	return strs
+}
+
+// fifoSource takes the focused window from lines another program writes to a named pipe:
+// either "class<TAB>title" or a FocusedWindow-style JSON object. The last line read is the
+// focused window until the next; when the writer closes the pipe there is none until a
+// writer opens it again.
+type fifoSource struct {
+	mu     sync.Mutex
+	window *common.MutterWindow
+	err    error
+}
+
+// newFIFOSource starts reading path in the background, creating the named pipe if needed
+func newFIFOSource(path string) *fifoSource {
+	s := &fifoSource{err: fmt.Errorf("nothing written to %s yet", path)}
+	go s.read(path)
+	return s
+}
+
+func (s *fifoSource) ActiveWindow() (*common.MutterWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	window := *s.window
+	return &window, nil
+}
+
+// set records the latest window, or the reason there is none
+func (s *fifoSource) set(window *common.MutterWindow, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window, s.err = window, err
+}
+
+// read opens the pipe (blocking until a writer does) and reads it line by line, reopening
+// it each time the writer closes it
+func (s *fifoSource) read(path string) {
+	if err := ensureFIFO(path); err != nil {
+		s.set(nil, err)
+		logging.Warning("Window source %s: %v", sourceFIFO, err)
+		return
+	}
+	for {
+		file, err := os.Open(path)
+		if err != nil {
+			s.set(nil, fmt.Errorf("failed to open %s: %v", path, err))
+			time.Sleep(sourceRetryInterval)
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if window, err := parseFIFOLine(scanner.Text()); err != nil {
+				logging.Debug("Window source %s: %v", sourceFIFO, err)
+			} else if window != nil {
+				s.set(window, nil)
+			}
+		}
+		file.Close()
+		s.set(nil, fmt.Errorf("the writer closed %s", path))
+	}
+}
+
+// ensureFIFO creates a named pipe at path, or checks that the existing file is one
+func ensureFIFO(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return fmt.Errorf("failed to create %s: %v", path, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s exists and is not a named pipe", path)
+	}
+	return nil
+}
+
+// parseFIFOLine parses one line written to the -window-fifo pipe. Blank lines give nil.
+func parseFIFOLine(line string) (*common.MutterWindow, error) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var window common.MutterWindow
+		if err := json.Unmarshal([]byte(line), &window); err != nil {
+			return nil, fmt.Errorf("invalid window JSON %q: %v", line, err)
+		}
+		return &window, nil
+	}
+	class, title, _ := strings.Cut(line, "\t")
+	return &common.MutterWindow{WmClass: strings.TrimSpace(class), Title: title, Focus: true}, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/godbus/dbus/v5"
+)
+
+// scriptedSource returns window, or err while it's set, counting calls
+type scriptedSource struct {
+	window *common.MutterWindow
+	err    error
+	calls  int
+}
+
+func (s *scriptedSource) ActiveWindow() (*common.MutterWindow, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.window, nil
+}
+
+// TestFallbackWindowSource tests that a failing primary is covered by the secondary at
+// once, that the chain switches to the secondary after repeated failures and stops asking
+// the primary on every poll, and that it switches back once the primary recovers
+func TestFallbackWindowSource(t *testing.T) {
+	primary := &scriptedSource{err: errors.New("org.gnome.Shell was not provided by any .service files")}
+	secondary := &scriptedSource{window: &common.MutterWindow{WmClass: "firefox", Title: "GitHub"}}
+	now := time.Date(2025, 10, 31, 9, 0, 0, 0, time.UTC)
+	chain := newFallbackWindowSource([]namedWindowSource{{"mutter", primary}, {"x11", secondary}}, func() time.Time { return now })
+
+	for i := 0; i < sourceSwitchAfter; i++ {
+		window, err := chain.ActiveWindow()
+		if err != nil || window.WmClass != "firefox" {
+			t.Fatalf("Poll %d: expected the secondary's window, got %v, %v", i+1, window, err)
+		}
+	}
+	if chain.Active() != "x11" {
+		t.Fatalf("Expected a switch to x11 after %d failures, still on %s", sourceSwitchAfter, chain.Active())
+	}
+
+	calls := primary.calls
+	chain.ActiveWindow()
+	if primary.calls != calls {
+		t.Error("Expected the primary left alone after the switch")
+	}
+
+	primary.err = nil
+	primary.window = &common.MutterWindow{WmClass: "code", Title: "main.go"}
+	now = now.Add(sourceRetryInterval)
+	if window, err := chain.ActiveWindow(); err != nil || window.WmClass != "code" || chain.Active() != "mutter" {
+		t.Errorf("Expected a switch back to the recovered primary, got %v, %v on %s", window, err, chain.Active())
+	}
+
+	primary.err = errors.New("timeout")
+	secondary.err = errors.New("no DISPLAY")
+	if _, err := chain.ActiveWindow(); err == nil {
+		t.Error("Expected an error when every source fails")
+	}
+}
+
+// TestParseWindowSources tests -sources parsing
+func TestParseWindowSources(t *testing.T) {
+	names, err := parseWindowSources(" mutter, X11 ,fifo")
+	if err != nil || len(names) != 3 || names[1] != sourceX11 {
+		t.Errorf("Expected [mutter x11 fifo], got %v, %v", names, err)
+	}
+	for _, value := range []string{"", "mutter,kde", "x11,x11"} {
+		if _, err := parseWindowSources(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+// TestX11Source tests reading the focused window from xprop output
+func TestX11Source(t *testing.T) {
+	source := x11Source{run: func(name string, args ...string) ([]byte, error) {
+		if args[0] == "-root" {
+			return []byte("_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3e00007\n"), nil
+		}
+		return []byte("WM_CLASS(STRING) = \"code\", \"Code\"\n" +
+			"_NET_WM_NAME(UTF8_STRING) = \"main.go - \\\"rescuetime\\\" - Visual Studio Code\"\n" +
+			"WM_NAME(STRING) = \"main.go\"\n" +
+			"_NET_WM_PID(CARDINAL) = 4242\n"), nil
+	}}
+	window, err := source.ActiveWindow()
+	if err != nil {
+		t.Fatalf("ActiveWindow failed: %v", err)
+	}
+	if window.WmClass != "Code" || window.WmClassInstance != "code" || window.Title != `main.go - "rescuetime" - Visual Studio Code` || window.Pid != 4242 || window.Id != 0x3e00007 {
+		t.Errorf("Unexpected window %+v", window)
+	}
+
+	none := x11Source{run: func(name string, args ...string) ([]byte, error) {
+		return []byte("_NET_ACTIVE_WINDOW(WINDOW): window id # 0x0\n"), nil
+	}}
+	if _, err := none.ActiveWindow(); err == nil {
+		t.Error("Expected an error without a focused window")
+	}
+}
+
+// TestFIFOSource tests that lines written to the pipe become the focused window, in both
+// formats, and that there is none once the writer closes it
+func TestFIFOSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "window.fifo")
+	source := newFIFOSource(path)
+	if _, err := source.ActiveWindow(); err == nil {
+		t.Error("Expected an error before anything is written")
+	}
+
+	// Wait for the reader to create the pipe, then write to it
+	var writer *os.File
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+			writer, _ = os.OpenFile(path, os.O_WRONLY, 0)
+			break
+		}
+	}
+	if writer == nil {
+		t.Fatal("The pipe was never created")
+	}
+	writer.WriteString("code\tmain.go\n{\"wm_class\":\"firefox\",\"title\":\"GitHub\"}\n")
+
+	waitFor := func(check func(*common.MutterWindow, error) bool) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if check(source.ActiveWindow()) {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitFor(func(w *common.MutterWindow, err error) bool { return err == nil && w.WmClass == "firefox" && w.Title == "GitHub" }) {
+		t.Error("Expected the last line written as the focused window")
+	}
+
+	writer.Close()
+	if !waitFor(func(w *common.MutterWindow, err error) bool { return err != nil }) {
+		t.Error("Expected no window once the writer closed the pipe")
+	}
+}
+
+// TestPrintWindowList tests the -windows dump: the listed windows as JSON, an empty list as
+// [], and a failed List call pointing at an outdated extension
+func TestPrintWindowList(t *testing.T) {
+	var out bytes.Buffer
+	windows := []common.MutterWindow{{WmClass: "firefox", Title: "Jira", Focus: true}, {WmClass: "Code", Title: "main.go"}}
+	if err := printWindowList(func() ([]common.MutterWindow, error) { return windows, nil }, &out); err != nil {
+		t.Fatalf("printWindowList failed: %v", err)
+	}
+	var decoded []common.MutterWindow
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].WmClass != "Code" || !decoded[0].Focus {
+		t.Errorf("Expected both windows back from the output, got %+v, %v:\n%s", decoded, err, out.String())
+	}
+
+	out.Reset()
+	if err := printWindowList(func() ([]common.MutterWindow, error) { return nil, nil }, &out); err != nil || strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("Expected [] for no windows, got %q, %v", out.String(), err)
+	}
+
+	err := printWindowList(func() ([]common.MutterWindow, error) { return nil, common.ErrListUnsupported }, &out)
+	if !errors.Is(err, common.ErrListUnsupported) {
+		t.Errorf("Expected ErrListUnsupported, got %v", err)
+	}
+}
+
+// TestWindowListError tests that only an UnknownMethod reply counts as an extension
+// without List
+func TestWindowListError(t *testing.T) {
+	unknown := dbus.Error{Name: common.DbusUnknownMethod, Body: []interface{}{"No such method 'List'"}}
+	if err := windowListError(unknown); !errors.Is(err, common.ErrListUnsupported) {
+		t.Errorf("Expected ErrListUnsupported for UnknownMethod, got %v", err)
+	}
+	other := dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown", Body: []interface{}{"The name is not activatable"}}
+	if err := windowListError(other); errors.Is(err, common.ErrListUnsupported) || !strings.Contains(err.Error(), "not activatable") {
+		t.Errorf("Expected the ServiceUnknown error passed on, got %v", err)
+	}
+}
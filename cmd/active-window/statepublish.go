@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/mqtt"
+)
+
+// statePublishTimeout bounds the final publish at shutdown, so an unreachable broker can't
+// hold up exit
+const statePublishTimeout = 5 * time.Second
+
+// statePublisher mirrors the focused window and today's totals for home automation
+// (implemented by *mqtt.Client). Publishing doesn't block; failures are handled inside.
+type statePublisher interface {
+	PublishWindow(appClass, title string)
+	PublishTotals(date time.Time, totals map[string]time.Duration)
+	Close(ctx context.Context) error
+}
+
+// newMQTTPublisher creates the -mqtt publisher. Credentials come from MQTT_USERNAME and
+// MQTT_PASSWORD (or the URL), and caFile, if set, replaces the system roots for mqtts://.
+func newMQTTPublisher(brokerURL, topicPrefix, discoveryPrefix, caFile string, getenv func(string) string) (*mqtt.Client, error) {
+	cfg := mqtt.Config{
+		BrokerURL:       brokerURL,
+		Username:        getenv("MQTT_USERNAME"),
+		Password:        getenv("MQTT_PASSWORD"),
+		TopicPrefix:     topicPrefix,
+		DiscoveryPrefix: discoveryPrefix,
+		NoDiscovery:     discoveryPrefix == "",
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -mqtt-ca-file: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("-mqtt-ca-file %s contains no PEM certificates", caFile)
+		}
+		cfg.TLS = &tls.Config{RootCAs: roots}
+	}
+	return mqtt.NewClient(cfg)
+}
+
+// publishWindow mirrors the focused window, or clears it when window is nil (tracking paused)
+func (m *Monitor) publishWindow(window *common.MutterWindow) {
+	if m.State == nil {
+		return
+	}
+	if window == nil {
+		m.State.PublishWindow("", "")
+		m.stateCleared = true
+		return
+	}
+	m.State.PublishWindow(window.WmClass, window.Title)
+	m.stateCleared = false
+}
+
+// publishTotals mirrors today's totals
+func (m *Monitor) publishTotals() {
+	if m.State != nil {
+		m.State.PublishTotals(m.Clock.Now().In(m.Tracker.Location()), m.Tracker.GetDailyTotals())
+	}
+}
+
+// closeState publishes the final state and disconnects
+func (m *Monitor) closeState() {
+	if m.State == nil {
+		return
+	}
+	m.publishWindow(nil)
+	m.publishTotals()
+	ctx, cancel := context.WithTimeout(context.Background(), statePublishTimeout)
+	defer cancel()
+	if err := m.State.Close(ctx); err != nil {
+		logging.Warning("Failed to publish the final state to MQTT: %v", err)
+	}
+}
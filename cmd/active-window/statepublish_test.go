@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingPublisher records what the monitor mirrors to MQTT
+type recordingPublisher struct {
+	windows []string // "app|title"
+	totals  []map[string]time.Duration
+	closed  bool
+}
+
+func (p *recordingPublisher) PublishWindow(appClass, title string) {
+	p.windows = append(p.windows, appClass+"|"+title)
+}
+
+func (p *recordingPublisher) PublishTotals(date time.Time, totals map[string]time.Duration) {
+	p.totals = append(p.totals, totals)
+}
+
+func (p *recordingPublisher) Close(ctx context.Context) error {
+	p.closed = true
+	return nil
+}
+
+// TestStatePublishing tests that the focused window is published at start and cleared while
+// idle, that the same window is published again on return, and that totals follow each
+// submission tick and shutdown
+func TestStatePublishing(t *testing.T) {
+	monitor, _, _ := newTestMonitor(t)
+	publisher := &recordingPublisher{}
+	monitor.State = publisher
+	backend := monitor.Backend.(*fakeBackend)
+
+	if err := monitor.start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	monitor.onPoll() // unchanged window: nothing new
+
+	backend.idle = time.Hour
+	monitor.onPoll()
+	backend.idle = 0
+	monitor.onPoll()
+
+	want := []string{"code|main.go", "|", "code|main.go"}
+	if len(publisher.windows) != len(want) {
+		t.Fatalf("Expected windows %v, got %v", want, publisher.windows)
+	}
+	for i := range want {
+		if publisher.windows[i] != want[i] {
+			t.Errorf("Window %d: expected %q, got %q", i, want[i], publisher.windows[i])
+		}
+	}
+
+	totalsAtStart := len(publisher.totals)
+	monitor.onSubmitTick()
+	if len(publisher.totals) != totalsAtStart+1 || len(publisher.totals[len(publisher.totals)-1]) == 0 {
+		t.Errorf("Expected today's totals published after the submission tick, got %v", publisher.totals)
+	}
+
+	monitor.onShutdown()
+	if !publisher.closed || publisher.windows[len(publisher.windows)-1] != "|" {
+		t.Errorf("Expected the window cleared and the publisher closed at shutdown, got %v (closed %v)", publisher.windows, publisher.closed)
+	}
+}
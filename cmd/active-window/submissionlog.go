@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// submissionLog is the -submission-log history: one JSON object per summary and
+// submission, saying which bucket it ended in (sent natively, skipped as too short, ...) and
+// why, so "nothing was submitted" can be told apart from a broken submission afterwards
+type submissionLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// submissionLogEntry is one line of the log
+type submissionLogEntry struct {
+	Time    time.Time `json:"time"`
+	Account string    `json:"account,omitempty"` // -accounts account it went to; "" for the default
+	rescuetime.SubmissionOutcome
+}
+
+// Append writes every outcome of result for account ("" for the default), stamped with at
+func (l *submissionLog) Append(at time.Time, account string, result rescuetime.SubmissionResult) error {
+	if len(result.Outcomes) == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open submission log %s: %v", l.path, err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, outcome := range result.Outcomes {
+		if err := encoder.Encode(submissionLogEntry{Time: at, Account: account, SubmissionOutcome: outcome}); err != nil {
+			return fmt.Errorf("failed to write submission log %s: %v", l.path, err)
+		}
+	}
+	return nil
+}
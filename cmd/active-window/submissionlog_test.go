@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestSubmissionLog tests that each outcome is appended as a JSON line with its reason
+func TestSubmissionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "submissions.jsonl")
+	log := &submissionLog{path: path}
+	at := time.Date(2025, 10, 31, 10, 15, 0, 0, time.UTC)
+
+	var result rescuetime.SubmissionResult
+	result.Add(rescuetime.ActivitySummary{AppClass: "code", TotalDuration: 10 * time.Minute, FirstSeen: at.Add(-10 * time.Minute)}, rescuetime.OutcomeSucceededNative, "")
+	result.Add(rescuetime.ActivitySummary{AppClass: "slack", TotalDuration: 2 * time.Minute, FirstSeen: at.Add(-2 * time.Minute)}, rescuetime.OutcomeSkippedShort, "duration 2m0s is below the 5m0s minimum")
+	if err := log.Append(at, "", result); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := log.Append(at, "", rescuetime.SubmissionResult{}); err != nil {
+		t.Fatalf("Append of an empty result failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer file.Close()
+	var entries []submissionLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry submissionLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", entries)
+	}
+	if entries[1].AppClass != "slack" || entries[1].Outcome != rescuetime.OutcomeSkippedShort || entries[1].Reason == "" || !entries[1].Time.Equal(at) {
+		t.Errorf("Unexpected entry %+v", entries[1])
+	}
+}
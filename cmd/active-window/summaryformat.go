@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// End-of-run summary formats for -summary-format
+const (
+	summaryFormatText = "text"
+	summaryFormatJSON = "json"
+	summaryFormatCSV  = "csv"
+)
+
+// summaryFormat is -summary-format: how printActivitySummary prints
+var summaryFormat = summaryFormatText
+
+// validSummaryFormat reports whether format is a known -summary-format
+func validSummaryFormat(format string) bool {
+	switch format {
+	case summaryFormatText, summaryFormatJSON, summaryFormatCSV:
+		return true
+	}
+	return false
+}
+
+// summaryRecord is one application's line in the json and csv summaries
+type summaryRecord struct {
+	App             string    `json:"app"`
+	DisplayName     string    `json:"display_name,omitempty"`
+	ActivityDetails string    `json:"activity_details"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	Percentage      float64   `json:"percentage"`
+	SessionCount    int       `json:"session_count"`
+	FirstSeen       time.Time `json:"first_seen"`
+	LastSeen        time.Time `json:"last_seen"`
+	Coverage        float64   `json:"coverage,omitempty"`
+	LowCoverage     bool      `json:"low_coverage,omitempty"`    // Coverage is under -min-coverage
+	MaximizedShare  float64   `json:"maximized_share,omitempty"` // with -track-geometry
+}
+
+// printActivitySummary prints the end-of-run summary of tracked activities in summaryFormat.
+// The json and csv formats go to stdout even with -log-file, so a pipeline can capture them
+// on Ctrl+C; logs are on stderr.
+func printActivitySummary(tracker *ActivityTracker) {
+	var err error
+	switch summaryFormat {
+	case summaryFormatJSON:
+		err = writeSummaryJSON(os.Stdout, tracker.GetActivitySummaries())
+	case summaryFormatCSV:
+		err = writeSummaryCSV(os.Stdout, tracker.GetActivitySummaries())
+	default:
+		printTextSummary(tracker)
+	}
+	if err != nil {
+		logging.Error("Failed to write the activity summary: %v", err)
+	}
+}
+
+// summaryRecords returns one record per summary, longest first (then by key)
+func summaryRecords(summaries map[string]ActivitySummary) []summaryRecord {
+	var total time.Duration
+	keys := make([]string, 0, len(summaries))
+	for key, summary := range summaries {
+		total += summary.TotalDuration
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := summaries[keys[i]].TotalDuration, summaries[keys[j]].TotalDuration
+		if a != b {
+			return a > b
+		}
+		return keys[i] < keys[j]
+	})
+
+	records := make([]summaryRecord, 0, len(keys))
+	for _, key := range keys {
+		summary := summaries[key]
+		var percentage float64
+		if total > 0 {
+			percentage = math.Round(float64(summary.TotalDuration)/float64(total)*1000) / 10
+		}
+		records = append(records, summaryRecord{
+			App:             key,
+			DisplayName:     summary.DisplayName,
+			ActivityDetails: summary.ActivityDetails,
+			DurationSeconds: int64(summary.TotalDuration.Round(time.Second) / time.Second),
+			Percentage:      percentage,
+			SessionCount:    summary.SessionCount,
+			FirstSeen:       summary.FirstSeen,
+			LastSeen:        summary.LastSeen,
+			Coverage:        math.Round(summary.Coverage*1000) / 1000,
+			LowCoverage:     lowCoverage(summary.Coverage, minCoverage),
+			MaximizedShare:  math.Round(summary.MaximizedShare()*1000) / 1000,
+		})
+	}
+	return records
+}
+
+// writeSummaryJSON writes the summaries as an indented JSON array of summaryRecord
+func writeSummaryJSON(w io.Writer, summaries map[string]ActivitySummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summaryRecords(summaries))
+}
+
+// writeSummaryCSV writes the summaries as app,duration_seconds,percentage,session_count
+// rows under a header
+func writeSummaryCSV(w io.Writer, summaries map[string]ActivitySummary) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"app", "duration_seconds", "percentage", "session_count"})
+	for _, record := range summaryRecords(summaries) {
+		writer.Write([]string{
+			record.App,
+			strconv.FormatInt(record.DurationSeconds, 10),
+			strconv.FormatFloat(record.Percentage, 'f', 1, 64),
+			strconv.Itoa(record.SessionCount),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testSummaries returns summaries for code (45m), firefox (15m) and slack (15m)
+func testSummaries() map[string]ActivitySummary {
+	return map[string]ActivitySummary{
+		"code":    {AppClass: "code", DisplayName: "Visual Studio Code", ActivityDetails: "main.go", TotalDuration: 45 * time.Minute, SessionCount: 3},
+		"slack":   {AppClass: "slack", ActivityDetails: "general", TotalDuration: 15*time.Minute + 400*time.Millisecond, SessionCount: 2},
+		"firefox": {AppClass: "firefox", ActivityDetails: "GitHub", TotalDuration: 15 * time.Minute, SessionCount: 1},
+	}
+}
+
+// TestWriteSummaryCSV tests the csv columns, longest first with ties by name
+func TestWriteSummaryCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeSummaryCSV(&out, testSummaries()); err != nil {
+		t.Fatalf("writeSummaryCSV failed: %v", err)
+	}
+	want := "app,duration_seconds,percentage,session_count\n" +
+		"code,2700,60.0,3\n" +
+		"slack,900,20.0,2\n" +
+		"firefox,900,20.0,1\n"
+	if out.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	writeSummaryCSV(&out, nil)
+	if out.String() != "app,duration_seconds,percentage,session_count\n" {
+		t.Errorf("Expected only the header without summaries, got %q", out.String())
+	}
+}
+
+// TestWriteSummaryJSON tests that the json summary is an array of records in the csv order
+func TestWriteSummaryJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeSummaryJSON(&out, testSummaries()); err != nil {
+		t.Fatalf("writeSummaryJSON failed: %v", err)
+	}
+	var records []summaryRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(records) != 3 || records[0].App != "code" || records[1].App != "slack" || records[2].App != "firefox" {
+		t.Fatalf("Expected code, slack, firefox, got %+v", records)
+	}
+	code := records[0]
+	if code.DisplayName != "Visual Studio Code" || code.DurationSeconds != 2700 || code.Percentage != 60 || code.SessionCount != 3 {
+		t.Errorf("Unexpected code record: %+v", code)
+	}
+
+	out.Reset()
+	writeSummaryJSON(&out, nil)
+	if out.String() != "[]\n" {
+		t.Errorf("Expected an empty array without summaries, got %q", out.String())
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// loadTitleRules reads a -title-rules file mapping a WmClass to regular expressions, e.g.
+// {"kitty": ["^(?P<activity>[A-Za-z0-9_.+-]+)(?:\\s|$)"]}. Each needs an (?P<activity>...)
+// capture. Classes are matched case-insensitively, like -merge-apps.
+func loadTitleRules(path string) (tracker.TitleRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read title rules file: %v", err)
+	}
+
+	var patterns map[string][]string
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return nil, fmt.Errorf("failed to parse title rules file %s%s: %v\n\nExpected a JSON object of WmClass to regular expressions with an (?P<activity>...) capture, e.g. {\"kitty\": [\"^(?P<activity>\\\\S+)\"]}", path, jsonErrorPosition(data, err), err)
+	}
+
+	rules := make(tracker.TitleRules)
+	for class, list := range patterns {
+		key := strings.ToLower(strings.TrimSpace(class))
+		if key == "" {
+			return nil, fmt.Errorf("title rules file %s has an empty WmClass", path)
+		}
+		for i, pattern := range list {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("title rules file %s: %s rule %d: %v", path, class, i+1, err)
+			}
+			if re.SubexpIndex(tracker.TitleRuleGroup) < 0 {
+				return nil, fmt.Errorf("title rules file %s: %s rule %d has no (?P<%s>...) capture", path, class, i+1, tracker.TitleRuleGroup)
+			}
+			rules[key] = append(rules[key], re)
+		}
+	}
+	return rules, nil
+}
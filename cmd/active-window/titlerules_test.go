@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// exampleTitleRules loads the rules shipped in title-rules.example.json
+func exampleTitleRules(t *testing.T) tracker.TitleRules {
+	t.Helper()
+	rules, err := loadTitleRules(filepath.Join("..", "..", "title-rules.example.json"))
+	if err != nil {
+		t.Fatalf("Failed to load the example title rules: %v", err)
+	}
+	return rules
+}
+
+// TestExampleTitleRules tests the shipped rules against real kitty, Alacritty and tmux titles
+func TestExampleTitleRules(t *testing.T) {
+	rules := exampleTitleRules(t)
+
+	tests := []struct {
+		class string
+		title string
+		want  string
+	}{
+		// kitty shows the running command, or the directory at a shell prompt
+		{"kitty", "nvim ~/proj/foo — kitty", "nvim"},
+		{"kitty", "htop", "htop"},
+		{"kitty", "python3 manage.py runserver", "python3"},
+		{"kitty", "git log --oneline", "git"},
+		{"kitty", "~/proj/foo", ""},
+		{"kitty", "/etc/nginx", ""},
+		{"kitty", "", ""},
+		// tmux's default set-titles-string, "#S:#I:#W - \"#T\"", names the window's program
+		{"kitty", `main:1:nvim - "laptop"`, "nvim"},
+		{"kitty", `work:12:cargo - "~/proj/foo" `, "cargo"},
+		{"Alacritty", `0:2:zsh - "laptop.local"`, "zsh"},
+		// Alacritty's default title and the usual bash "user@host: dir" prompt title
+		{"Alacritty", "Alacritty", ""},
+		{"Alacritty", "chris@laptop: ~/proj/foo", ""},
+		{"Alacritty", "nvim main.go", "nvim"},
+		{"alacritty", "cargo build --release", "cargo"},
+		// No rules for the class
+		{"firefox", "GitHub - Mozilla Firefox", ""},
+	}
+	for _, tt := range tests {
+		if got := rules.SubActivity(tt.class, tt.title); got != tt.want {
+			t.Errorf("%s %q: expected %q, got %q", tt.class, tt.title, tt.want, got)
+		}
+	}
+}
+
+// TestTitleRulesSplitSummaries tests that summaries are keyed by application and
+// sub-activity, with the sub-activity as details, and unmatched titles stay under the class
+func TestTitleRulesSplitSummaries(t *testing.T) {
+	tracker := NewActivityTracker()
+	tracker.SetTitleRules(exampleTitleRules(t))
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	runTitledSession := func(title string, from, to time.Time) {
+		startSessionAt(tracker, "kitty", title, from)
+		endSessionAt(tracker, to)
+	}
+
+	runTitledSession("nvim ~/proj/foo", at(0), at(20))
+	runTitledSession("~/proj/foo", at(20), at(25)) // back at the prompt, no rule matches
+	runTitledSession("nvim ~/proj/foo/main.go", at(25), at(35))
+	runTitledSession("nvim ~/proj/bar", at(35), at(40)) // same sub-activity: merged
+
+	if got := len(tracker.GetSessions()); got != 3 {
+		t.Errorf("Expected a switch of sub-activity to end the session, got %d sessions", got)
+	}
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	nvim, ok := summaries["kitty/nvim"]
+	if !ok || len(summaries) != 2 {
+		t.Fatalf("Expected kitty/nvim and kitty summaries, got %v", summaries)
+	}
+	if nvim.AppClass != "kitty" || nvim.ActivityDetails != "nvim" || nvim.RawTitle != "nvim ~/proj/bar" {
+		t.Errorf("Expected kitty / nvim with the raw title kept, got %+v", nvim)
+	}
+	if nvim.TotalDuration != 35*time.Minute || nvim.SessionCount != 2 {
+		t.Errorf("Expected 35m in 2 sessions of nvim, got %v in %d", nvim.TotalDuration, nvim.SessionCount)
+	}
+	if shell := summaries["kitty"]; shell.AppClass != "kitty" || shell.ActivityDetails != "~/proj/foo" || shell.TotalDuration != 5*time.Minute {
+		t.Errorf("Expected the unmatched title under plain kitty, got %+v", shell)
+	}
+}
+
+// TestLoadTitleRulesErrors tests that malformed rule files are rejected with the rule at fault
+func TestLoadTitleRulesErrors(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"no capture":  {`{"kitty": ["^(\\S+)"]}`, "kitty rule 1 has no (?P<activity>...) capture"},
+		"bad regex":   {`{"kitty": ["^(?P<activity>\\S+)", "(?P<activity>["]}`, "kitty rule 2"},
+		"not json":    {"{\n  \"kitty\": \"^(?P<activity>\\\\S+)\"\n}", "line 2"},
+		"empty class": {`{" ": ["^(?P<activity>\\S+)"]}`, "empty WmClass"},
+	}
+	for name, tt := range tests {
+		path := filepath.Join(t.TempDir(), "title-rules.json")
+		os.WriteFile(path, []byte(tt.content), 0644)
+		_, err := loadTitleRules(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tt.want, err)
+		}
+	}
+}
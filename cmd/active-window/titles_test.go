@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/webhook"
+	"github.com/fatih/color"
+)
+
+// TestAdversarialTitlesVerbatim tests that window titles with format verbs, HTML characters
+// or great length go from StartSession through the window change line, the summaries and the
+// webhook body unchanged
+func TestAdversarialTitlesVerbatim(t *testing.T) {
+	titles := []string{
+		"%s%n%% %!d(MISSING) <b>R&D</b> > 100%",
+		strings.Repeat("%v %s 100% & ", 1000),
+	}
+
+	var logs bytes.Buffer
+	oldOutput, oldError, oldNoColor := color.Output, color.Error, color.NoColor
+	color.NoColor = true
+	logging.SetOutput(&logs)
+	logging.SetActivity(true)
+	t.Cleanup(func() {
+		logging.SetOutput(os.Stderr)
+		color.Output, color.Error, color.NoColor = oldOutput, oldError, oldNoColor
+		logging.SetActivity(false)
+	})
+
+	var mu sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client, err := webhook.NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, title := range titles {
+		tracker := NewActivityTracker()
+		window := &common.MutterWindow{WmClass: "firefox", Title: title}
+		tracker.StartWindowSession(window)
+		consoleNotifier{}.WindowChanged(tracker, window)
+		endSessionAt(tracker, time.Now().Add(10*time.Minute))
+
+		if !strings.Contains(logs.String(), "Active Window: "+title+" (firefox)") {
+			t.Errorf("Window change line mangled the title %.40q: %.200q", title, logs.String())
+		}
+		summaries := tracker.GetCompletedActivitySummaries()
+		if got := summaries["firefox"].ActivityDetails; got != title {
+			t.Errorf("Summary details changed: %.40q", got)
+		}
+
+		bodies = nil
+		submitActivitiesToWebhook(client, summaries, tracker.GetAllSessions())
+		if len(bodies) != 1 {
+			t.Fatalf("Expected one webhook request, got %d", len(bodies))
+		}
+		// The summary's details and raw title and the session's title all appear unescaped
+		if count := bytes.Count(bodies[0], []byte(title)); count != 3 {
+			t.Errorf("Expected the title verbatim three times, found %d times in %.200s", count, bodies[0])
+		}
+		logs.Reset()
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "strings"
+
+// parseClassList splits a comma-separated list of WmClasses, e.g. -transient-helpers or
+// -background-apps
+func parseClassList(value string) []string {
+	var classes []string
+	for _, class := range strings.Split(value, ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
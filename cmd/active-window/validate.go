@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/webhook"
+)
+
+// knownEnvKeys are the .env settings the tracker and its clients read
+var knownEnvKeys = map[string]bool{
+	"RESCUE_TIME_API_KEY":        true,
+	"RESCUE_TIME_ACCOUNT_KEY":    true,
+	"RESCUE_TIME_DATA_KEY":       true,
+	"POSTGRES_CONNECTION_STRING": true,
+	"WEBHOOK_URL":                true,
+	"WEBHOOK_ANONYMIZE_SALT":     true,
+	"ANONYMIZE_SECRET":           true,
+	"MQTT_USERNAME":              true,
+	"MQTT_PASSWORD":              true,
+}
+
+// checkEnvFile parses a .env file with loadEnvFile's rules. loadEnvFile skips lines that
+// aren't KEY=value, so they fail here; keys nothing reads (usually typos) are warnings.
+// A missing file is fine, since settings may come from the environment.
+func checkEnvFile(path string) doctorCheck {
+	check := doctorCheck{Name: "Environment file (" + path + ")"}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		check.Status = doctorSkip
+		check.Detail = "not present, settings come from the environment"
+		return check
+	}
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		return check
+	}
+	defer file.Close()
+
+	var malformed, unknown []string
+	keys := 0
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		switch {
+		case len(parts) != 2 || key == "":
+			malformed = append(malformed, fmt.Sprintf("line %d", lineNumber))
+		case !knownEnvKeys[key] && !isAccountEnvKey(key):
+			unknown = append(unknown, fmt.Sprintf("%s (line %d)", key, lineNumber))
+		default:
+			keys++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("failed to read: %v", err)
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%d settings", keys)
+	var hints []string
+	if len(unknown) > 0 {
+		check.Status = doctorWarn
+		check.Detail += ", unknown keys: " + strings.Join(unknown, ", ")
+		hints = append(hints, "Known keys: "+strings.Join(sortedKeys(knownEnvKeys), ", "))
+	}
+	if len(malformed) > 0 {
+		check.Status = doctorFail
+		check.Detail += ", not KEY=value: " + strings.Join(malformed, ", ")
+		hints = append(hints, "Each line must be KEY=value, a # comment or blank; other lines are ignored when loading")
+	}
+	check.Hint = strings.Join(hints, "\n")
+	return check
+}
+
+// checkMergeFile parses a -merge-apps file with loadMerges
+func checkMergeFile(path string) doctorCheck {
+	check := doctorCheck{Name: "App merges"}
+	if path == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-merge-apps)"
+		return check
+	}
+	check.Name += " (" + path + ")"
+
+	merges, err := loadMerges(path)
+	if err != nil {
+		check.Status = doctorFail
+		// The expected format goes in the hint rather than the detail line
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n\n")
+		return check
+	}
+	check.Detail = fmt.Sprintf("%d WmClasses merged", len(merges))
+	return check
+}
+
+// checkAccountsFile parses an -accounts file with loadAccounts and checks every account has
+// keys of its own
+func checkAccountsFile(path string, getenv func(string) string) doctorCheck {
+	check := doctorCheck{Name: "RescueTime accounts"}
+	if path == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-accounts)"
+		return check
+	}
+	check.Name += " (" + path + ")"
+
+	routes, err := loadAccounts(path)
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n\n")
+		return check
+	}
+	for _, name := range routes.names {
+		if _, err := newAccountClient(name, getenv); err != nil {
+			check.Status = doctorFail
+			check.Detail = err.Error()
+			return check
+		}
+	}
+	check.Detail = fmt.Sprintf("%d accounts, %d WmClasses routed", len(routes.names), len(routes.byClass))
+	return check
+}
+
+// checkAppTagsFile parses an -app-tags file with loadAppTags
+func checkAppTagsFile(path string) doctorCheck {
+	check := doctorCheck{Name: "App tags"}
+	if path == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-app-tags)"
+		return check
+	}
+	check.Name += " (" + path + ")"
+
+	tags, err := loadAppTags(path)
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n\n")
+		return check
+	}
+	check.Detail = fmt.Sprintf("%d applications tagged", len(tags))
+	return check
+}
+
+// checkTitleRulesFile parses a -title-rules file with loadTitleRules
+func checkTitleRulesFile(path string) doctorCheck {
+	check := doctorCheck{Name: "Title rules"}
+	if path == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-title-rules)"
+		return check
+	}
+	check.Name += " (" + path + ")"
+
+	rules, err := loadTitleRules(path)
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n\n")
+		return check
+	}
+	count := 0
+	for _, list := range rules {
+		count += len(list)
+	}
+	check.Detail = fmt.Sprintf("%d rules for %d WmClasses", count, len(rules))
+	return check
+}
+
+// checkConfiguredCredentials checks the credentials that are set without contacting any
+// service. Unlike -doctor, a missing API key isn't an error: PostgreSQL- or webhook-only
+// setups don't need one.
+func checkConfiguredCredentials(getenv func(string) string, envFileErr error) doctorCheck {
+	if getenv("RESCUE_TIME_API_KEY") == "" {
+		return doctorCheck{Name: "RescueTime credentials", Status: doctorSkip, Detail: "RESCUE_TIME_API_KEY is not set (only needed for -submit)"}
+	}
+	return checkCredentials(getenv, envFileErr)
+}
+
+// checkWebhookURL checks the webhook URL the way webhook.NewClient does, without sending anything
+func checkWebhookURL(url string) doctorCheck {
+	check := doctorCheck{Name: "Webhook URL"}
+	if url == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-webhook or WEBHOOK_URL)"
+		return check
+	}
+	if _, err := webhook.NewClient(url); err != nil {
+		check.Status = doctorFail
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n\n")
+		return check
+	}
+	check.Detail = url
+	return check
+}
+
+// checkWebhookTemplateFile parses a -webhook-template file with webhook.LoadTemplate, which
+// also renders it against a sample payload
+func checkWebhookTemplateFile(path string) doctorCheck {
+	check := doctorCheck{Name: "Webhook template"}
+	if path == "" {
+		check.Status = doctorSkip
+		check.Detail = "not configured (-webhook-template)"
+		return check
+	}
+	check.Name += " (" + path + ")"
+
+	if _, err := webhook.LoadTemplate(path); err != nil {
+		check.Status = doctorFail
+		check.Detail, check.Hint, _ = strings.Cut(err.Error(), "\n")
+		return check
+	}
+	check.Detail = "renders valid JSON"
+	return check
+}
+
+// sortedKeys returns the keys of set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runValidateConfig parses every config file the tracker reads, without starting tracking or
+// contacting any service. Flags are already validated by the time it runs.
+// Returns false if any file has an error.
+func runValidateConfig(mergePath, titleRulesPath, accountsPath, appTagsPath, webhookURL, webhookTemplatePath string) bool {
+	var envFileErr error
+	if _, err := os.Stat(".env"); err == nil {
+		envFileErr = loadEnvFile(".env")
+	}
+	if webhookURL == "" {
+		webhookURL = os.Getenv("WEBHOOK_URL")
+	}
+
+	return runChecks("active-window config", []func() doctorCheck{
+		func() doctorCheck { return checkEnvFile(".env") },
+		func() doctorCheck { return checkConfiguredCredentials(os.Getenv, envFileErr) },
+		func() doctorCheck { return checkWebhookURL(webhookURL) },
+		func() doctorCheck { return checkWebhookTemplateFile(webhookTemplatePath) },
+		func() doctorCheck { return checkIgnoreFile(".rescuetime-ignore") },
+		func() doctorCheck { return checkMergeFile(mergePath) },
+		func() doctorCheck { return checkTitleRulesFile(titleRulesPath) },
+		func() doctorCheck { return checkAccountsFile(accountsPath, os.Getenv) },
+		func() doctorCheck { return checkAppTagsFile(appTagsPath) },
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes content to name in a temporary directory and returns its path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// TestCheckEnvFile tests that malformed lines fail with their line numbers and unknown keys warn
+func TestCheckEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		status  doctorStatus
+		detail  string
+	}{
+		{"valid", "# keys\nRESCUE_TIME_API_KEY=abc\n\nWEBHOOK_URL = https://example.com\n", doctorPass, "2 settings"},
+		{"missing equals", "RESCUE_TIME_API_KEY=abc\nPOSTGRES_CONNECTION_STRING postgres://localhost\n", doctorFail, "line 2"},
+		{"empty key", "=abc\n", doctorFail, "line 1"},
+		{"typo", "RESCUETIME_API_KEY=abc\nWEBHOOK_URL=https://example.com\n", doctorWarn, "RESCUETIME_API_KEY (line 1)"},
+	}
+	for _, tt := range tests {
+		check := checkEnvFile(writeConfig(t, ".env", tt.content))
+		if check.Status != tt.status || !strings.Contains(check.Detail, tt.detail) {
+			t.Errorf("%s: expected status %d with %q, got %+v", tt.name, tt.status, tt.detail, check)
+		}
+	}
+
+	if check := checkEnvFile(filepath.Join(t.TempDir(), ".env")); check.Status != doctorSkip {
+		t.Errorf("Expected a missing .env to be skipped, got %+v", check)
+	}
+}
+
+// TestCheckMergeFile tests that merge file syntax and type errors report where they are
+func TestCheckMergeFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		detail  string
+	}{
+		{"trailing comma", "{\n  \"Chat\": [\"slack\", \"discord\"],\n}\n", "line 3"},
+		{"string instead of list", "{\n  \"Chat\": [\"slack\"],\n  \"Work\": \"code\"\n}\n", "line 3"},
+		{"conflict", `{"Chat": ["slack"], "Work": ["Slack"]}`, "each WmClass can only merge into one target"},
+	}
+	for _, tt := range tests {
+		check := checkMergeFile(writeConfig(t, "merges.json", tt.content))
+		if check.Status != doctorFail || !strings.Contains(check.Detail, tt.detail) {
+			t.Errorf("%s: expected failure with %q, got %+v", tt.name, tt.detail, check)
+		}
+	}
+
+	check := checkMergeFile(writeConfig(t, "merges.json", `{"Chat": ["slack", "discord"]}`))
+	if check.Status != doctorPass || check.Detail != "2 WmClasses merged" {
+		t.Errorf("Expected a valid merge file to pass, got %+v", check)
+	}
+	if check := checkMergeFile(""); check.Status != doctorSkip {
+		t.Errorf("Expected no merge file to be skipped, got %+v", check)
+	}
+}
+
+// TestCheckConfiguredCredentialsAndWebhookURL tests the checks that don't contact a service
+func TestCheckConfiguredCredentialsAndWebhookURL(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	if check := checkConfiguredCredentials(getenv, nil); check.Status != doctorSkip {
+		t.Errorf("Expected a missing API key to be skipped, got %+v", check)
+	}
+	env["RESCUE_TIME_API_KEY"] = "short"
+	if check := checkConfiguredCredentials(getenv, nil); check.Status != doctorFail {
+		t.Errorf("Expected a short API key to fail, got %+v", check)
+	}
+
+	if check := checkWebhookURL("example.com/hook"); check.Status != doctorFail || strings.Contains(check.Detail, "\n") {
+		t.Errorf("Expected a one-line failure for a URL without a scheme, got %+v", check)
+	}
+	if check := checkWebhookURL("https://example.com/hook"); check.Status != doctorPass {
+		t.Errorf("Expected a valid URL to pass, got %+v", check)
+	}
+}
+
+// TestCheckWebhookTemplateFile tests that the example template passes and one rendering
+// invalid JSON fails on a single line
+func TestCheckWebhookTemplateFile(t *testing.T) {
+	if check := checkWebhookTemplateFile(filepath.Join("..", "..", "webhook-flat.example.tmpl")); check.Status != doctorPass {
+		t.Errorf("Expected the example template to pass, got %+v", check)
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Summaries}}{{.AppClass}}{{end}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if check := checkWebhookTemplateFile(path); check.Status != doctorFail || strings.Contains(check.Detail, "\n") {
+		t.Errorf("Expected a one-line failure for a template that isn't JSON, got %+v", check)
+	}
+}
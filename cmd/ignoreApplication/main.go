@@ -4,40 +4,54 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
 	"github.com/godbus/dbus/v5"
 )
 
 const (
-	monitorDuration = 10 * time.Second
-	pollInterval    = 500 * time.Millisecond
-	ignoreFilePath  = ".rescuetime-ignore"
+	defaultMonitorDuration = 10 * time.Second
+	defaultPollInterval    = 500 * time.Millisecond
+	minPollInterval        = 50 * time.Millisecond
+	ignoreFilePath         = ".rescuetime-ignore"
 )
 
-// SeenApplication tracks when we saw an application
-type SeenApplication struct {
-	WmClass     string
-	LastSeen    time.Time
-	WindowTitle string
+// ignoreIncludes are the ignore file's "#include other-file" lines, written back unchanged
+// by saveIgnoreList (active-window loads the included files' entries itself)
+var ignoreIncludes []string
+
+// windowSource reports the focused window
+type windowSource interface {
+	ActiveWindow() (*common.MutterWindow, error)
+}
+
+// dbusSource asks the GNOME Shell FocusedWindow extension for the focused window over one
+// session bus connection, kept for every poll instead of connecting each time
+type dbusSource struct {
+	conn *dbus.Conn
 }
 
-// getWindowFromDBus queries the GNOME Shell FocusedWindow extension
-func getWindowFromDBus() (*common.MutterWindow, error) {
+// newDBusSource connects to the session bus
+func newDBusSource() (*dbusSource, error) {
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to session bus: %v", err)
 	}
-	defer conn.Close()
+	return &dbusSource{conn: conn}, nil
+}
 
-	obj := conn.Object(common.DbusDestination, common.DbusObjectPath)
+// ActiveWindow queries the GNOME Shell FocusedWindow extension
+func (s *dbusSource) ActiveWindow() (*common.MutterWindow, error) {
+	obj := s.conn.Object(common.DbusDestination, common.DbusObjectPath)
 	call := obj.Call(common.DbusMethod, 0)
 
 	if call.Err != nil {
@@ -45,25 +59,44 @@ func getWindowFromDBus() (*common.MutterWindow, error) {
 	}
 
 	var jsonStr string
-	err = call.Store(&jsonStr)
+	err := call.Store(&jsonStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse D-Bus response: %v", err)
 	}
 
-	var window common.MutterWindow
-	err = json.Unmarshal([]byte(jsonStr), &window)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse window JSON: %v", err)
+	return common.ParseMutterWindow(jsonStr)
+}
+
+// ListWindows asks the extension for every open window. Extensions from before the List
+// method was added fail this call; main falls back to watching the focused window then.
+func (s *dbusSource) ListWindows() ([]common.MutterWindow, error) {
+	obj := s.conn.Object(common.DbusDestination, common.DbusObjectPath)
+	call := obj.Call(common.DbusListMethod, 0)
+
+	if dbusErr, ok := call.Err.(dbus.Error); ok && dbusErr.Name == common.DbusUnknownMethod {
+		return nil, common.ErrListUnsupported
+	}
+	if call.Err != nil {
+		return nil, fmt.Errorf("failed to call FocusedWindow.List: %v", call.Err)
+	}
+
+	var jsonStr string
+	if err := call.Store(&jsonStr); err != nil {
+		return nil, fmt.Errorf("failed to parse D-Bus response: %v", err)
 	}
 
-	return &window, nil
+	return common.ParseWindowList(jsonStr)
 }
 
-// loadCurrentIgnoreList reads the current ignore list from file
-func loadCurrentIgnoreList() map[string]bool {
+func (s *dbusSource) Close() error { return s.conn.Close() }
+
+// loadCurrentIgnoreList reads the current ignore list from path, replacing ignoreIncludes
+// with its #include lines
+func loadCurrentIgnoreList(path string) map[string]bool {
 	ignoredApps := make(map[string]bool)
+	ignoreIncludes = nil
 
-	file, err := os.Open(ignoreFilePath)
+	file, err := os.Open(path)
 	if err != nil {
 		// File doesn't exist yet, that's ok
 		return ignoredApps
@@ -73,6 +106,10 @@ func loadCurrentIgnoreList() map[string]bool {
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#include ") || strings.HasPrefix(line, "#include\t") {
+			ignoreIncludes = append(ignoreIncludes, line)
+			continue
+		}
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
@@ -82,9 +119,20 @@ func loadCurrentIgnoreList() map[string]bool {
 	return ignoredApps
 }
 
-// saveIgnoreList saves the ignore list to file
-func saveIgnoreList(ignoredApps map[string]bool) error {
-	file, err := os.Create(ignoreFilePath)
+// isIgnored reports whether wmClass is in the ignore list. Matching ignores case, like
+// active-window does by default ("Code" and "code" are the same application).
+func isIgnored(ignoredApps map[string]bool, wmClass string) bool {
+	for appClass := range ignoredApps {
+		if strings.EqualFold(appClass, wmClass) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveIgnoreList saves the ignore list to path
+func saveIgnoreList(path string, ignoredApps map[string]bool) error {
+	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create ignore file: %v", err)
 	}
@@ -98,148 +146,371 @@ func saveIgnoreList(ignoredApps map[string]bool) error {
 	fmt.Fprintln(writer, "# Lines starting with # are comments")
 	fmt.Fprintln(writer, "")
 
+	for _, include := range ignoreIncludes {
+		fmt.Fprintln(writer, include)
+	}
+	if len(ignoreIncludes) > 0 {
+		fmt.Fprintln(writer, "")
+	}
+
 	// Write ignored apps
-	for appClass := range ignoredApps {
+	for _, appClass := range sortedApps(ignoredApps) {
 		fmt.Fprintln(writer, appClass)
 	}
 
 	return writer.Flush()
 }
 
-func main() {
-	log.SetFlags(0) // No timestamps for this interactive tool
+// sortedApps returns the ignore list's entries in order, so saving it gives a stable file
+func sortedApps(ignoredApps map[string]bool) []string {
+	apps := make([]string, 0, len(ignoredApps))
+	for appClass := range ignoredApps {
+		apps = append(apps, appClass)
+	}
+	sort.Strings(apps)
+	return apps
+}
 
-	fmt.Println("=== RescueTime Application Ignore Tool ===")
-	fmt.Println()
-	fmt.Println("This tool will monitor your active windows for the next 10 seconds.")
-	fmt.Println("Switch between applications you want to review.")
-	fmt.Println()
-	fmt.Print("Press Enter to start monitoring...")
-	bufio.NewReader(os.Stdin).ReadBytes('\n')
+// addToIgnoreList adds the applications not ignored yet to the list at path and saves it,
+// returning the ones it added
+func addToIgnoreList(path string, wmClasses []string) ([]string, error) {
+	ignoredApps := loadCurrentIgnoreList(path)
+	var added []string
+	for _, wmClass := range wmClasses {
+		if isIgnored(ignoredApps, wmClass) {
+			continue
+		}
+		ignoredApps[wmClass] = true
+		added = append(added, wmClass)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	if err := saveIgnoreList(path, ignoredApps); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
 
-	// Check D-Bus connection
-	_, err := getWindowFromDBus()
+// ignoreCurrent adds the focused window's application to the ignore list at path. It
+// returns the WmClass and whether it was added (false if it already was ignored).
+func ignoreCurrent(source windowSource, path string) (string, bool, error) {
+	window, err := source.ActiveWindow()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to connect to GNOME Shell FocusedWindow extension.\n")
-		fmt.Fprintf(os.Stderr, "Make sure the extension is installed and enabled.\n")
-		fmt.Fprintf(os.Stderr, "Details: %v\n", err)
-		os.Exit(1)
+		return "", false, err
+	}
+	if window.WmClass == "" {
+		return "", false, fmt.Errorf("the focused window (%q) has no WmClass to ignore", window.Title)
+	}
+	added, err := addToIgnoreList(path, []string{window.WmClass})
+	if err != nil {
+		return window.WmClass, false, err
 	}
+	return window.WmClass, len(added) > 0, nil
+}
+
+// observeWindow feeds one poll to the tracker, starting a session when the focused
+// application or title changes
+func observeWindow(t *tracker.Tracker, window *common.MutterWindow) {
+	if window.WmClass == "" {
+		return
+	}
+	if current, ok := t.CurrentSession(); ok && current.AppClass == window.WmClass && current.WindowTitle == window.Title {
+		return
+	}
+	t.StartSession(window.WmClass, window.Title)
+}
+
+// newFocusTracker returns a tracker that keeps every session, however short, so a few
+// seconds of monitoring still add up. Its ignore list is empty: already ignored
+// applications are listed too, marked as such.
+func newFocusTracker() *tracker.Tracker {
+	t := tracker.New()
+	t.SetThresholds(tracker.DefaultMergeThreshold, 0)
+	return t
+}
+
+// rankedApps returns the tracked applications, the longest focused first (most recently
+// seen first among equals)
+func rankedApps(t *tracker.Tracker) []tracker.ActivitySummary {
+	summaries := t.GetCompletedActivitySummaries()
+	apps := make([]tracker.ActivitySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		apps = append(apps, summary)
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].TotalDuration != apps[j].TotalDuration {
+			return apps[i].TotalDuration > apps[j].TotalDuration
+		}
+		return apps[i].LastSeen.After(apps[j].LastSeen)
+	})
+	return apps
+}
 
-	// Monitor windows
-	fmt.Printf("\nMonitoring for %v...\n", monitorDuration)
-	seenApps := make(map[string]*SeenApplication)
+// openApp is an application with windows open, as listed by the extension's List method
+type openApp struct {
+	WmClass string
+	Windows int
+	Title   string // the focused window's title if it has it, else its first window's
+}
+
+// openApps groups windows by application, in alphabetical order ignoring case. Windows
+// without a WmClass (the desktop, some dialogs) can't be ignored and are skipped.
+func openApps(windows []common.MutterWindow) []openApp {
+	index := make(map[string]int)
+	var apps []openApp
+	for _, window := range windows {
+		if window.WmClass == "" {
+			continue
+		}
+		i, ok := index[window.WmClass]
+		if !ok {
+			i = len(apps)
+			index[window.WmClass] = i
+			apps = append(apps, openApp{WmClass: window.WmClass, Title: window.Title})
+		}
+		apps[i].Windows++
+		if window.Focus {
+			apps[i].Title = window.Title
+		}
+	}
+	sort.SliceStable(apps, func(i, j int) bool {
+		return strings.ToLower(apps[i].WmClass) < strings.ToLower(apps[j].WmClass)
+	})
+	return apps
+}
+
+// parseSelection parses a picker answer such as "1,3-5" against a list of count entries
+// into 0-based indexes, in order and without repeats. "0" or nothing selects none.
+func parseSelection(input string, count int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" || input == "0" {
+		return nil, nil
+	}
+
+	seen := make(map[int]bool)
+	var indexes []int
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		first, last := part, part
+		if from, to, ok := strings.Cut(part, "-"); ok {
+			first, last = strings.TrimSpace(from), strings.TrimSpace(to)
+		}
+		low, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		high, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		if low > high {
+			return nil, fmt.Errorf("range %q runs backwards", part)
+		}
+		if low < 1 || high > count {
+			return nil, fmt.Errorf("%q is outside 1-%d", part, count)
+		}
+		for n := low; n <= high; n++ {
+			if !seen[n] {
+				seen[n] = true
+				indexes = append(indexes, n-1)
+			}
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// watchApps times the focused applications for duration, polling every interval, after the
+// user presses Enter, and returns them ranked by focus time. It's the fallback for
+// extensions without List, which can only report the focused window.
+func watchApps(source windowSource, reader *bufio.Reader, duration, interval time.Duration) []tracker.ActivitySummary {
+	fmt.Printf("This tool will monitor your active windows for the next %v.\n", duration)
+	fmt.Println("Switch between applications you want to review.")
+	fmt.Println()
+	fmt.Print("Press Enter to start monitoring...")
+	reader.ReadString('\n')
+
+	// Monitor windows, timing each application like active-window does
+	fmt.Printf("\nMonitoring for %v...\n", duration)
+	focus := newFocusTracker()
+	seenApps := make(map[string]bool)
 
 	startTime := time.Now()
-	ticker := time.NewTicker(pollInterval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	progressTicker := time.NewTicker(1 * time.Second)
 	defer progressTicker.Stop()
 
-	for time.Since(startTime) < monitorDuration {
+	for time.Since(startTime) < duration {
 		select {
 		case <-ticker.C:
-			window, err := getWindowFromDBus()
+			window, err := source.ActiveWindow()
 			if err != nil {
 				continue
 			}
 
-			if window.WmClass != "" {
-				if _, exists := seenApps[window.WmClass]; !exists {
-					fmt.Printf("  Found: %s\n", window.WmClass)
-				}
-				seenApps[window.WmClass] = &SeenApplication{
-					WmClass:     window.WmClass,
-					LastSeen:    time.Now(),
-					WindowTitle: window.Title,
-				}
+			if window.WmClass != "" && !seenApps[window.WmClass] {
+				seenApps[window.WmClass] = true
+				fmt.Printf("  Found: %s\n", window.WmClass)
 			}
+			observeWindow(focus, window)
 
 		case <-progressTicker.C:
 			elapsed := time.Since(startTime)
-			remaining := monitorDuration - elapsed
+			remaining := duration - elapsed
 			fmt.Printf("  %v remaining... (%d apps found)\n", remaining.Round(time.Second), len(seenApps))
 		}
 	}
+	focus.EndCurrentSession()
 
-	fmt.Printf("\nFound %d unique applications.\n\n", len(seenApps))
+	return rankedApps(focus)
+}
 
-	if len(seenApps) == 0 {
-		fmt.Println("No applications detected. Make sure you switched between some windows.")
-		os.Exit(0)
+func main() {
+	log.SetFlags(0) // No timestamps for this interactive tool
+
+	monitorDuration := flag.Duration("duration", defaultMonitorDuration, "How long to watch the focused window before listing applications")
+	pollInterval := flag.Duration("interval", defaultPollInterval, "How often to check the focused window while watching")
+	watch := flag.Bool("watch", false, "Watch the focused window for -duration instead of listing the open applications")
+	ignoreCurrentFlag := flag.Bool("ignore-current", false, "Ignore the currently focused application at once, without watching or asking (e.g. from a keyboard shortcut)")
+	flag.Parse()
+
+	if *monitorDuration <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -duration must be positive, got %v\n", *monitorDuration)
+		os.Exit(1)
+	}
+	if *pollInterval < minPollInterval || *pollInterval > *monitorDuration {
+		fmt.Fprintf(os.Stderr, "Error: -interval must be between %v and -duration (%v), got %v\n", minPollInterval, *monitorDuration, *pollInterval)
+		os.Exit(1)
 	}
 
-	// Load current ignore list
-	currentlyIgnored := loadCurrentIgnoreList()
+	// Check D-Bus connection
+	source, err := newDBusSource()
+	if err == nil {
+		_, err = source.ActiveWindow()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to connect to GNOME Shell FocusedWindow extension.\n")
+		fmt.Fprintf(os.Stderr, "Make sure the extension is installed and enabled.\n")
+		fmt.Fprintf(os.Stderr, "Details: %v\n", err)
+		os.Exit(1)
+	}
+	defer source.Close()
 
-	// Display applications
-	fmt.Println("Applications detected:")
-	fmt.Println()
+	if *ignoreCurrentFlag {
+		wmClass, added, err := ignoreCurrent(source, ignoreFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !added {
+			fmt.Printf("'%s' is already in the ignore list.\n", wmClass)
+			return
+		}
+		fmt.Printf("✓ Added '%s' to ignore list (%s)\n", wmClass, ignoreFilePath)
+		return
+	}
 
-	appList := make([]*SeenApplication, 0, len(seenApps))
-	for _, app := range seenApps {
-		appList = append(appList, app)
+	fmt.Println("=== RescueTime Application Ignore Tool ===")
+	fmt.Println()
+	reader := bufio.NewReader(os.Stdin)
+	currentlyIgnored := loadCurrentIgnoreList(ignoreFilePath)
+
+	// List the open applications, or watch the focused window when the extension is too old
+	// to list windows (or -watch asks for it)
+	var names []string
+	var open []openApp
+	if !*watch {
+		windows, err := source.ListWindows()
+		if err != nil {
+			fmt.Printf("Can't list open windows: %v.\nWatching the focused window instead.\n\n", err)
+		} else {
+			open = openApps(windows)
+		}
 	}
 
-	// Sort by last seen (most recent first)
-	for i := 0; i < len(appList)-1; i++ {
-		for j := i + 1; j < len(appList); j++ {
-			if appList[j].LastSeen.After(appList[i].LastSeen) {
-				appList[i], appList[j] = appList[j], appList[i]
+	if len(open) > 0 {
+		fmt.Println("Open applications:")
+		fmt.Println()
+		for i, app := range open {
+			names = append(names, app.WmClass)
+			status := ""
+			if isIgnored(currentlyIgnored, app.WmClass) {
+				status = " [ALREADY IGNORED]"
+			}
+			fmt.Printf("  %d) %s - %d window(s)%s\n", i+1, app.WmClass, app.Windows, status)
+			if app.Title != "" {
+				fmt.Printf("     Window: %s\n", app.Title)
 			}
 		}
-	}
+	} else {
+		appList := watchApps(source, reader, *monitorDuration, *pollInterval)
+		fmt.Printf("\nFound %d unique applications.\n\n", len(appList))
 
-	// Display numbered list
-	for i, app := range appList {
-		status := ""
-		if currentlyIgnored[app.WmClass] {
-			status = " [ALREADY IGNORED]"
+		if len(appList) == 0 {
+			fmt.Println("No applications detected. Make sure you switched between some windows.")
+			os.Exit(0)
 		}
-		fmt.Printf("  %d) %s%s\n", i+1, app.WmClass, status)
-		if app.WindowTitle != "" {
-			fmt.Printf("     Last window: %s\n", app.WindowTitle)
+
+		// Display applications, the most focused first
+		fmt.Println("Applications detected:")
+		fmt.Println()
+		for i, app := range appList {
+			names = append(names, app.AppClass)
+			status := ""
+			if isIgnored(currentlyIgnored, app.AppClass) {
+				status = " [ALREADY IGNORED]"
+			}
+			share := float64(app.TotalDuration) / float64(*monitorDuration) * 100
+			fmt.Printf("  %d) %s - %v focused (%.0f%%)%s\n", i+1, app.AppClass, app.TotalDuration.Round(time.Second), share, status)
+			if app.ActivityDetails != "" {
+				fmt.Printf("     Last window: %s\n", app.ActivityDetails)
+			}
 		}
 	}
 
 	fmt.Println()
-	fmt.Println("Enter the number of the application to ignore (or 0 to cancel):")
+	fmt.Println("Enter the applications to ignore, e.g. 2 or 1,3-5 (or 0 to cancel):")
 	fmt.Print("> ")
 
-	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-
-	choice, err := strconv.Atoi(input)
-	if err != nil || choice < 0 || choice > len(appList) {
-		fmt.Println("Invalid choice. Exiting.")
+	selection, err := parseSelection(input, len(names))
+	if err != nil {
+		fmt.Printf("Invalid choice: %v. Exiting.\n", err)
 		os.Exit(0)
 	}
 
-	if choice == 0 {
+	if len(selection) == 0 {
 		fmt.Println("Cancelled.")
 		os.Exit(0)
 	}
 
 	// Add to ignore list
-	selectedApp := appList[choice-1]
-
-	if currentlyIgnored[selectedApp.WmClass] {
-		fmt.Printf("\n'%s' is already in the ignore list.\n", selectedApp.WmClass)
-		os.Exit(0)
+	var selected []string
+	for _, index := range selection {
+		selected = append(selected, names[index])
 	}
-
-	currentlyIgnored[selectedApp.WmClass] = true
-
-	err = saveIgnoreList(currentlyIgnored)
+	added, err := addToIgnoreList(ignoreFilePath, selected)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving ignore list: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("\n✓ Added '%s' to ignore list (%s)\n", selectedApp.WmClass, ignoreFilePath)
 	fmt.Println()
-	fmt.Println("This application will now be excluded from RescueTime tracking.")
-	fmt.Println("Restart active-window if it's currently running to apply changes.")
+	for _, wmClass := range selected {
+		if isIgnored(currentlyIgnored, wmClass) {
+			fmt.Printf("'%s' is already in the ignore list.\n", wmClass)
+		}
+	}
+	for _, wmClass := range added {
+		fmt.Printf("✓ Added '%s' to ignore list (%s)\n", wmClass, ignoreFilePath)
+	}
+	if len(added) == 0 {
+		os.Exit(0)
+	}
+	fmt.Println()
+	fmt.Println("These applications will now be excluded from RescueTime tracking.")
+	fmt.Println("A running active-window picks up the change on its next poll.")
 }
@@ -0,0 +1,150 @@
+// +build ignore_app
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// fakeSource reports a fixed focused window
+type fakeSource struct {
+	window *common.MutterWindow
+	err    error
+}
+
+func (s fakeSource) ActiveWindow() (*common.MutterWindow, error) { return s.window, s.err }
+
+// TestParseSelection tests numbers, ranges, repeats, cancelling and out-of-range answers
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+		err   string
+	}{
+		{"2", []int{1}, ""},
+		{"1,3-5", []int{0, 2, 3, 4}, ""},
+		{" 5 , 1 - 2 ,2 ", []int{0, 1, 4}, ""},
+		{"3-3", []int{2}, ""},
+		{"0", nil, ""},
+		{"", nil, ""},
+		{"6", nil, "outside 1-5"},
+		{"0-2", nil, "outside 1-5"},
+		{"4-2", nil, "backwards"},
+		{"two", nil, "not a number"},
+		{"1,", nil, "not a number"},
+		{"1-", nil, "not a number"},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 5)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: expected an error containing %q, got %v, %v", tt.input, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v, %v", tt.input, tt.want, got, err)
+		}
+	}
+}
+
+// TestIgnoreCurrent tests that -ignore-current adds the focused application once, keeping
+// the file's #include lines and entries, and refuses a window without a WmClass
+func TestIgnoreCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".rescuetime-ignore")
+	if err := os.WriteFile(path, []byte("#include ignore.d/games\nCode\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wmClass, added, err := ignoreCurrent(fakeSource{window: &common.MutterWindow{WmClass: "slack", Title: "general"}}, path)
+	if err != nil || wmClass != "slack" || !added {
+		t.Fatalf("Expected slack to be added, got %q, %v, %v", wmClass, added, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"#include ignore.d/games", "Code", "slack"} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expected %q in the ignore file, got:\n%s", line, data)
+		}
+	}
+	if strings.Count(string(data), "#include") != 1 {
+		t.Errorf("Expected the include line once, got:\n%s", data)
+	}
+
+	// Already ignored, in another case
+	if _, added, err := ignoreCurrent(fakeSource{window: &common.MutterWindow{WmClass: "code"}}, path); err != nil || added {
+		t.Errorf("Expected code to count as already ignored, got %v, %v", added, err)
+	}
+	if _, _, err := ignoreCurrent(fakeSource{window: &common.MutterWindow{Title: "Desktop"}}, path); err == nil {
+		t.Error("Expected an error for a window without a WmClass")
+	}
+}
+
+// TestRankedApps tests that polls are timed through the tracker and listed longest first,
+// the most recently seen first among equals
+func TestRankedApps(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	now := start
+	focus := newFocusTracker()
+	focus.SetClock(func() time.Time { return now })
+
+	polls := []struct {
+		at      time.Duration
+		wmClass string
+		title   string
+	}{
+		{0, "slack", "general"},
+		{1 * time.Second, "code", "main.go"},
+		{5 * time.Second, "code", "main_test.go"},
+		{7 * time.Second, "slack", "random"},
+		{8 * time.Second, "firefox", "Docs"},
+	}
+	for _, poll := range polls {
+		now = start.Add(poll.at)
+		observeWindow(focus, &common.MutterWindow{WmClass: poll.wmClass, Title: poll.title})
+	}
+	now = start.Add(10 * time.Second)
+	focus.EndCurrentSession()
+
+	apps := rankedApps(focus)
+	var got []string
+	for _, app := range apps {
+		got = append(got, app.AppClass+" "+app.TotalDuration.String())
+	}
+	want := []string{"code 6s", "firefox 2s", "slack 2s"} // firefox was seen last
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// TestOpenApps tests grouping listed windows by application, preferring the focused
+// window's title and skipping windows without a WmClass
+func TestOpenApps(t *testing.T) {
+	windows := []common.MutterWindow{
+		{WmClass: "firefox", Title: "Jira"},
+		{WmClass: "Code", Title: "main.go"},
+		{WmClass: "", Title: "Desktop"},
+		{WmClass: "firefox", Title: "GitHub", Focus: true},
+		{WmClass: "anki", Title: "Decks"},
+	}
+	want := []openApp{
+		{WmClass: "anki", Windows: 1, Title: "Decks"},
+		{WmClass: "Code", Windows: 1, Title: "main.go"},
+		{WmClass: "firefox", Windows: 2, Title: "GitHub"},
+	}
+	if got := openApps(windows); !reflect.DeepEqual(got, want) {
+		t.Errorf("openApps() = %+v, want %+v", got, want)
+	}
+	if got := openApps(nil); len(got) != 0 {
+		t.Errorf("Expected no applications for no windows, got %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package anonymize replaces application classes and window titles with keyed hashes before
+// they leave the machine. A hash is an HMAC-SHA256 keyed by a local secret, so the same
+// application gets the same pseudonym every day (remote totals still group by it) while the
+// pseudonym means nothing without the secret. Only this machine can reverse it, by hashing
+// the classes it has seen; see Reveal.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Pseudonym prefixes, e.g. "app-3f2a9c1d7e4b6a08"
+const (
+	AppPrefix   = "app-"
+	TitlePrefix = "title-"
+)
+
+// What to do with window titles when anonymizing
+const (
+	TitlesKeep = "keep" // send them unchanged
+	TitlesDrop = "drop" // send none
+	TitlesHash = "hash" // send Title pseudonyms
+)
+
+// ValidTitleMode reports whether mode is a Titles* mode
+func ValidTitleMode(mode string) bool {
+	return mode == TitlesKeep || mode == TitlesDrop || mode == TitlesHash
+}
+
+// App returns the pseudonym for an application class: the HMAC-SHA256 of the class keyed
+// by secret, shortened to 16 hex digits
+func App(appClass, secret string) string {
+	return AppPrefix + digest(appClass, secret)
+}
+
+// Title returns the pseudonym for a window title. Titles are hashed in their own domain,
+// so a title that happens to equal a class name doesn't share its pseudonym. An empty
+// title stays empty.
+func Title(title, secret string) string {
+	if title == "" {
+		return ""
+	}
+	return TitlePrefix + digest("title:"+title, secret)
+}
+
+// ApplyTitle returns title as mode sends it
+func ApplyTitle(title, mode, secret string) string {
+	switch mode {
+	case TitlesDrop:
+		return ""
+	case TitlesHash:
+		return Title(title, secret)
+	default:
+		return title
+	}
+}
+
+// Reveal returns the candidate class whose App pseudonym is hash, for debugging what a
+// pseudonym seen remotely stands for
+func Reveal(hash, secret string, candidates []string) (string, bool) {
+	for _, candidate := range candidates {
+		if hmac.Equal([]byte(App(candidate, secret)), []byte(hash)) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func digest(value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
@@ -0,0 +1,53 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestApp tests that a class hashes to the same pseudonym with the same secret, and to a
+// different one with another class or secret
+func TestApp(t *testing.T) {
+	code := App("code", "secret")
+	if code != App("code", "secret") {
+		t.Error("Expected identical classes to hash identically")
+	}
+	if code == App("firefox", "secret") || code == App("code", "other secret") {
+		t.Error("Expected another class or secret to change the pseudonym")
+	}
+	if !strings.HasPrefix(code, AppPrefix) || len(code) != len(AppPrefix)+16 || strings.Contains(code, "code") {
+		t.Errorf("Unexpected pseudonym %q", code)
+	}
+	// Pinned so a change to the hash, which would split every remote total, fails here
+	if want := "app-bc5c1537e9ad84b5"; App("code", "secret") != want {
+		t.Errorf("Expected %s, got %s", want, App("code", "secret"))
+	}
+}
+
+// TestApplyTitle tests each title mode, and that titles don't share class pseudonyms
+func TestApplyTitle(t *testing.T) {
+	if got := ApplyTitle("main.go", TitlesDrop, "secret"); got != "" {
+		t.Errorf("Expected drop to send no title, got %q", got)
+	}
+	if got := ApplyTitle("main.go", TitlesKeep, "secret"); got != "main.go" {
+		t.Errorf("Expected keep to send the title, got %q", got)
+	}
+	hashed := ApplyTitle("code", TitlesHash, "secret")
+	if !strings.HasPrefix(hashed, TitlePrefix) || strings.TrimPrefix(hashed, TitlePrefix) == strings.TrimPrefix(App("code", "secret"), AppPrefix) {
+		t.Errorf("Unexpected title pseudonym %q", hashed)
+	}
+	if ApplyTitle("", TitlesHash, "secret") != "" {
+		t.Error("Expected an empty title to stay empty")
+	}
+}
+
+// TestReveal tests looking a pseudonym up among known classes
+func TestReveal(t *testing.T) {
+	candidates := []string{"firefox", "code", "slack"}
+	if class, ok := Reveal(App("code", "secret"), "secret", candidates); !ok || class != "code" {
+		t.Errorf("Expected code, got %q, %v", class, ok)
+	}
+	if _, ok := Reveal(App("code", "other secret"), "secret", candidates); ok {
+		t.Error("Expected a pseudonym from another secret not to be revealed")
+	}
+}
@@ -1,45 +1,193 @@
 package common
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
 // D-Bus configuration shared between active-window and ignoreApplication
 const (
 	DbusDestination = "org.gnome.Shell"
 	DbusObjectPath  = "/org/gnome/shell/extensions/FocusedWindow"
 	DbusInterface   = "org.gnome.shell.extensions.FocusedWindow"
 	DbusMethod      = DbusInterface + ".Get"
-	
+	DbusListMethod  = DbusInterface + ".List" // every open window, not just the focused one
+
+	// DbusUnknownMethod is the D-Bus error name for a call to a method the object doesn't have
+	DbusUnknownMethod = "org.freedesktop.DBus.Error.UnknownMethod"
+
 	// Mutter idle monitor D-Bus configuration
 	IdleMonitorDestination = "org.gnome.Mutter.IdleMonitor"
 	IdleMonitorObjectPath  = "/org/gnome/Mutter/IdleMonitor/Core"
 	IdleMonitorInterface   = "org.gnome.Mutter.IdleMonitor"
 	IdleMonitorMethod      = IdleMonitorInterface + ".GetIdletime"
+
+	// IdleMonitor watches: AddIdleWatch (interval in ms) and AddUserActiveWatch return an ID
+	// that WatchFired carries when the watch fires. User-active watches fire once.
+	IdleMonitorAddIdleWatch       = IdleMonitorInterface + ".AddIdleWatch"
+	IdleMonitorAddUserActiveWatch = IdleMonitorInterface + ".AddUserActiveWatch"
+	IdleMonitorRemoveWatch        = IdleMonitorInterface + ".RemoveWatch"
+	IdleMonitorWatchFired         = "WatchFired"
+
+	// Mutter display configuration, whose PowerSaveMode property is the DPMS state
+	DisplayConfigDestination = "org.gnome.Mutter.DisplayConfig"
+	DisplayConfigObjectPath  = "/org/gnome/Mutter/DisplayConfig"
+	DisplayConfigInterface   = "org.gnome.Mutter.DisplayConfig"
+	DisplayConfigPowerSave   = DisplayConfigInterface + ".PowerSaveMode"
 )
 
 // MutterWindow represents the window information from GNOME Shell's FocusedWindow extension
 type MutterWindow struct {
-	Title              string      `json:"title"`
-	WmClass            string      `json:"wm_class"`
-	WmClassInstance    string      `json:"wm_class_instance"`
-	Pid                int32       `json:"pid"`
-	Id                 uint64      `json:"id"`
-	Width              int32       `json:"width"`
-	Height             int32       `json:"height"`
-	X                  int32       `json:"x"`
-	Y                  int32       `json:"y"`
-	Focus              bool        `json:"focus"`
-	InCurrentWorkspace bool        `json:"in_current_workspace"`
-	Moveable           bool        `json:"moveable"`
-	Resizeable         bool        `json:"resizeable"`
-	CanClose           bool        `json:"canclose"`
-	CanMaximize        bool        `json:"canmaximize"`
-	Maximized          bool        `json:"maximized"`
-	CanMinimize        bool        `json:"canminimize"`
-	Display            interface{} `json:"display"`
-	FrameType          int32       `json:"frame_type"`
-	WindowType         int32       `json:"window_type"`
-	Layer              int32       `json:"layer"`
-	Monitor            int32       `json:"monitor"`
-	Role               string      `json:"role"`
-	Area               interface{} `json:"area"`
-	AreaAll            interface{} `json:"area_all"`
-	AreaCust           interface{} `json:"area_cust"`
+	Title              string  `json:"title"`
+	WmClass            string  `json:"wm_class"`
+	WmClassInstance    string  `json:"wm_class_instance"`
+	Pid                int32   `json:"pid"`
+	Id                 uint64  `json:"id"`
+	Width              int32   `json:"width"`
+	Height             int32   `json:"height"`
+	X                  int32   `json:"x"`
+	Y                  int32   `json:"y"`
+	Focus              bool    `json:"focus"`
+	InCurrentWorkspace bool    `json:"in_current_workspace"`
+	Moveable           bool    `json:"moveable"`
+	Resizeable         bool    `json:"resizeable"`
+	CanClose           bool    `json:"canclose"`
+	CanMaximize        bool    `json:"canmaximize"`
+	Maximized          bool    `json:"maximized"`
+	CanMinimize        bool    `json:"canminimize"`
+	Display            Display `json:"display"`
+	FrameType          int32   `json:"frame_type"`
+	WindowType         int32   `json:"window_type"`
+	Layer              int32   `json:"layer"`
+	Monitor            int32   `json:"monitor"`
+	Role               string  `json:"role"`
+	Area               Rect    `json:"area"`      // work area of the window's monitor
+	AreaAll            Rect    `json:"area_all"`  // work area spanning all monitors
+	AreaCust           Rect    `json:"area_cust"` // work area of the monitor given by Monitor
+}
+
+// Rect is a rectangle in the extension's JSON (x/y/width/height in pixels)
+type Rect struct {
+	X      int32 `json:"x"`
+	Y      int32 `json:"y"`
+	Width  int32 `json:"width"`
+	Height int32 `json:"height"`
+}
+
+// Display identifies the monitor a window is on
+type Display struct {
+	Connector string `json:"connector"` // e.g. "DP-1", "eDP-1"
+	Model     string `json:"model"`
+}
+
+// UnmarshalJSON decodes a rectangle, leaving it zero when the extension sends
+// null or a non-object value (older extension versions serialize GObjects as {} or strings)
+func (r *Rect) UnmarshalJSON(data []byte) error {
+	if !isJSONObject(data) {
+		*r = Rect{}
+		return nil
+	}
+	type rect Rect
+	var decoded rect
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		*r = Rect{}
+		return nil
+	}
+	*r = Rect(decoded)
+	return nil
+}
+
+// UnmarshalJSON decodes display info with the same tolerance as Rect
+func (d *Display) UnmarshalJSON(data []byte) error {
+	if !isJSONObject(data) {
+		*d = Display{}
+		return nil
+	}
+	type display Display
+	var decoded display
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		*d = Display{}
+		return nil
+	}
+	*d = Display(decoded)
+	return nil
+}
+
+// isJSONObject reports whether data is a JSON object
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// ParseMutterWindow decodes the FocusedWindow extension's JSON response
+func ParseMutterWindow(jsonStr string) (*MutterWindow, error) {
+	var window MutterWindow
+	if err := json.Unmarshal([]byte(jsonStr), &window); err != nil {
+		return nil, fmt.Errorf("failed to parse window JSON: %v", err)
+	}
+	return &window, nil
+}
+
+// ParseWindowList decodes the extension's List response, a JSON array of windows in the
+// same format as Get
+func ParseWindowList(jsonStr string) ([]MutterWindow, error) {
+	var windows []MutterWindow
+	if err := json.Unmarshal([]byte(jsonStr), &windows); err != nil {
+		return nil, fmt.Errorf("failed to parse window list JSON: %v", err)
+	}
+	return windows, nil
+}
+
+// ErrListUnsupported means the extension answered List with DbusUnknownMethod: it's a
+// version from before List was added, which can only report the focused window
+var ErrListUnsupported = errors.New("the FocusedWindow extension has no List method; update it to list every open window")
+
+// ErrNotFocused means the extension answered with a window that doesn't have focus, which
+// it does for a window that just lost it (e.g. during a workspace animation)
+var ErrNotFocused = errors.New("window reported without focus")
+
+// ParseFocusedWindow decodes the extension's response like ParseMutterWindow, but returns
+// ErrNotFocused for a window whose focus field is false. A response without the field, from
+// an extension version that doesn't send it, is taken as focused.
+func ParseFocusedWindow(jsonStr string) (*MutterWindow, error) {
+	window, err := ParseMutterWindow(jsonStr)
+	if err != nil {
+		return nil, err
+	}
+	var focus struct {
+		Focus *bool `json:"focus"`
+	}
+	if json.Unmarshal([]byte(jsonStr), &focus) == nil && focus.Focus != nil && !*focus.Focus {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrNotFocused, window.Title, window.WmClass)
+	}
+	return window, nil
+}
+
+// TinyPopupMaxSize is the largest width and height (in pixels) of a window treated as a tiny popup
+const TinyPopupMaxSize = 200
+
+// IsTinyPopup reports whether the window is a small transient window (tooltip, menu,
+// notification bubble) that shouldn't count as switching applications.
+// Windows with unknown size (0x0) are not considered popups.
+func IsTinyPopup(window *MutterWindow) bool {
+	if window == nil || window.Width <= 0 || window.Height <= 0 {
+		return false
+	}
+	return window.Width < TinyPopupMaxSize && window.Height < TinyPopupMaxSize
+}
+
+// FillsScreen reports whether the window is maximized or fullscreen. The extension has no
+// fullscreen flag, so a window at least as large as its monitor's work area counts too.
+// Windows with an unknown work area (older extension versions) only count when maximized.
+func FillsScreen(window *MutterWindow) bool {
+	if window == nil {
+		return false
+	}
+	if window.Maximized {
+		return true
+	}
+	area := window.Area
+	return area.Width > 0 && area.Height > 0 && window.Width >= area.Width && window.Height >= area.Height
 }
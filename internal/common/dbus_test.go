@@ -0,0 +1,176 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestParseMutterWindow tests decoding captured FocusedWindow extension output
+func TestParseMutterWindow(t *testing.T) {
+	data, err := os.ReadFile("testdata/focused-window.json")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+
+	window, err := ParseMutterWindow(string(data))
+	if err != nil {
+		t.Fatalf("ParseMutterWindow failed: %v", err)
+	}
+
+	if window.WmClass != "firefox" || window.WmClassInstance != "Navigator" {
+		t.Errorf("Unexpected class: %s / %s", window.WmClass, window.WmClassInstance)
+	}
+	if window.Display != (Display{Connector: "DP-1", Model: "DELL U2720Q"}) {
+		t.Errorf("Unexpected display: %+v", window.Display)
+	}
+	if window.Area != (Rect{X: 2560, Y: 32, Width: 1920, Height: 1048}) {
+		t.Errorf("Unexpected area: %+v", window.Area)
+	}
+	if window.AreaAll.Width != 4480 || window.AreaAll.Height != 1408 {
+		t.Errorf("Unexpected area_all: %+v", window.AreaAll)
+	}
+	if window.AreaCust != window.Area {
+		t.Errorf("Expected area_cust to match area, got %+v", window.AreaCust)
+	}
+}
+
+// TestParseMutterWindowTolerant tests that missing or oddly typed geometry fields don't fail decoding
+func TestParseMutterWindowTolerant(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{name: "missing fields", json: `{"title":"Terminal","wm_class":"kitty"}`},
+		{name: "null geometry", json: `{"title":"Terminal","wm_class":"kitty","display":null,"area":null}`},
+		{name: "empty objects", json: `{"title":"Terminal","wm_class":"kitty","display":{},"area":{},"area_all":{}}`},
+		{name: "non-object values", json: `{"title":"Terminal","wm_class":"kitty","display":"[object Meta.Display]","area":[0,0,10,10]}`},
+		{name: "wrong field types", json: `{"title":"Terminal","wm_class":"kitty","area":{"x":"left","width":100}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ParseMutterWindow(tt.json)
+			if err != nil {
+				t.Fatalf("ParseMutterWindow failed: %v", err)
+			}
+			if window.WmClass != "kitty" {
+				t.Errorf("Expected wm_class kitty, got %q", window.WmClass)
+			}
+			if window.Display != (Display{}) {
+				t.Errorf("Expected zero display, got %+v", window.Display)
+			}
+		})
+	}
+
+	if _, err := ParseMutterWindow("not json"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+// TestParseFocusedWindow tests that a response without focus is rejected and that one
+// without the focus field is accepted
+func TestParseFocusedWindow(t *testing.T) {
+	data, err := os.ReadFile("testdata/focused-window.json")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+	if window, err := ParseFocusedWindow(string(data)); err != nil || window.WmClass != "firefox" {
+		t.Errorf("Expected the focused firefox window, got %+v, %v", window, err)
+	}
+
+	unfocused := strings.Replace(string(data), `"focus":true`, `"focus":false`, 1)
+	if _, err := ParseFocusedWindow(unfocused); !errors.Is(err, ErrNotFocused) {
+		t.Errorf("Expected ErrNotFocused, got %v", err)
+	}
+
+	if window, err := ParseFocusedWindow(`{"title":"Terminal","wm_class":"kitty"}`); err != nil || window.WmClass != "kitty" {
+		t.Errorf("Expected a response without focus to be taken as focused, got %+v, %v", window, err)
+	}
+	if _, err := ParseFocusedWindow("not json"); err == nil || errors.Is(err, ErrNotFocused) {
+		t.Errorf("Expected a parse error for invalid JSON, got %v", err)
+	}
+}
+
+// TestParseWindowList tests decoding a captured List response: every window in it, with the
+// Get fields, including one from an older GNOME whose display and work areas aren't objects
+func TestParseWindowList(t *testing.T) {
+	data, err := os.ReadFile("testdata/window-list.json")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+	windows, err := ParseWindowList(string(data))
+	if err != nil {
+		t.Fatalf("ParseWindowList failed: %v", err)
+	}
+	if len(windows) != 4 {
+		t.Fatalf("Expected 4 windows, got %d: %+v", len(windows), windows)
+	}
+
+	firefox, code, zoom := windows[0], windows[1], windows[2]
+	if firefox.WmClass != "firefox" || !firefox.Focus || !firefox.Maximized || firefox.Display.Connector != "DP-1" || firefox.Area.Width != 1920 {
+		t.Errorf("Unexpected firefox window %+v", firefox)
+	}
+	if code.WmClass != "Code" || code.Focus || code.Title != "main.go - rescuetime-linux-mutter - Visual Studio Code" || code.Pid != 51022 {
+		t.Errorf("Unexpected VS Code window %+v", code)
+	}
+	if zoom.WmClass != "zoom" || zoom.InCurrentWorkspace || zoom.Display != (Display{}) || zoom.Area != (Rect{}) || zoom.Role != "ConfWindow" {
+		t.Errorf("Expected zoom with its display and work areas left empty, got %+v", zoom)
+	}
+	if windows[3].WmClass != "" {
+		t.Errorf("Expected the desktop window without a WmClass, got %+v", windows[3])
+	}
+
+	if windows, err := ParseWindowList("[]"); err != nil || len(windows) != 0 {
+		t.Errorf("Expected no windows, got %+v, %v", windows, err)
+	}
+	if _, err := ParseWindowList(string(data[1 : len(data)-2])); err == nil {
+		t.Error("Expected an error for windows that aren't in a list")
+	}
+}
+
+// TestIsTinyPopup tests the size threshold for transient windows
+func TestIsTinyPopup(t *testing.T) {
+	tests := []struct {
+		name   string
+		window *MutterWindow
+		want   bool
+	}{
+		{name: "tooltip", window: &MutterWindow{Width: 120, Height: 24}, want: true},
+		{name: "normal window", window: &MutterWindow{Width: 1920, Height: 1043}, want: false},
+		{name: "narrow but tall", window: &MutterWindow{Width: 150, Height: 900}, want: false},
+		{name: "unknown size", window: &MutterWindow{}, want: false},
+		{name: "nil window", window: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTinyPopup(tt.window); got != tt.want {
+			t.Errorf("%s: IsTinyPopup() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestFillsScreen tests maximized windows, fullscreen ones covering the work area, and
+// windows whose work area is unknown
+func TestFillsScreen(t *testing.T) {
+	workArea := Rect{Width: 1920, Height: 1043}
+	tests := []struct {
+		name   string
+		window *MutterWindow
+		want   bool
+	}{
+		{name: "maximized", window: &MutterWindow{Width: 1920, Height: 1043, Maximized: true}, want: true},
+		{name: "fullscreen", window: &MutterWindow{Width: 1920, Height: 1080, Area: workArea}, want: true},
+		{name: "floating", window: &MutterWindow{Width: 1200, Height: 800, Area: workArea}, want: false},
+		{name: "tiled half", window: &MutterWindow{Width: 960, Height: 1043, Area: workArea}, want: false},
+		{name: "unknown area", window: &MutterWindow{Width: 1920, Height: 1080}, want: false},
+		{name: "nil window", window: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := FillsScreen(tt.window); got != tt.want {
+			t.Errorf("%s: FillsScreen() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
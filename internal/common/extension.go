@@ -0,0 +1,152 @@
+package common
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExtensionURL is the FocusedWindow extension's page, which lists its released versions
+const ExtensionURL = "https://extensions.gnome.org/extension/5839/focused-window-dbus/"
+
+// RequiredExtensionFields are the Get fields tracking can't do without
+var RequiredExtensionFields = []string{"title", "wm_class"}
+
+// OptionalExtensionFields are the Get fields older extension versions don't send, with the
+// feature that needs each
+var OptionalExtensionFields = []struct {
+	Field   string
+	Feature string
+}{
+	{Field: "wm_class_instance", Feature: "-group-by instance and .desktop names for web apps"},
+	{Field: "in_current_workspace", Feature: "which windows are on the current workspace (-windows)"},
+	{Field: "focus", Feature: "discarding windows that just lost focus"},
+	{Field: "maximized", Feature: "-track-geometry (maximized windows)"},
+	{Field: "area", Feature: "-track-geometry (fullscreen windows)"},
+}
+
+// ExtensionCapabilities is what the installed FocusedWindow extension supports: the methods
+// its D-Bus interface introspects with and the fields of a sample Get response
+type ExtensionCapabilities struct {
+	Methods []string // e.g. Get, List
+	Fields  []string // nil if there was no sample to look at
+}
+
+// introspectNode is the part of D-Bus introspection XML ProbeExtension reads
+type introspectNode struct {
+	Interfaces []struct {
+		Name    string `xml:"name,attr"`
+		Methods []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"method"`
+	} `xml:"interface"`
+}
+
+// ProbeExtension works out the extension's capabilities from the introspection XML of its
+// object and a sample Get response. An empty sample (or an empty object, which some versions
+// send with nothing focused) leaves Fields nil, so no field is reported missing.
+func ProbeExtension(introspectXML, sample string) (ExtensionCapabilities, error) {
+	var node introspectNode
+	if err := xml.Unmarshal([]byte(introspectXML), &node); err != nil {
+		return ExtensionCapabilities{}, fmt.Errorf("failed to parse introspection XML: %v", err)
+	}
+
+	var caps ExtensionCapabilities
+	found := false
+	for _, iface := range node.Interfaces {
+		if iface.Name != DbusInterface {
+			continue
+		}
+		found = true
+		for _, method := range iface.Methods {
+			caps.Methods = append(caps.Methods, method.Name)
+		}
+	}
+	if !found {
+		return ExtensionCapabilities{}, fmt.Errorf("%s is not on %s; is the FocusedWindow extension enabled?", DbusInterface, DbusObjectPath)
+	}
+	sort.Strings(caps.Methods)
+
+	if strings.TrimSpace(sample) == "" {
+		return caps, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(sample), &fields); err != nil {
+		return caps, fmt.Errorf("failed to parse sample Get response: %v", err)
+	}
+	if len(fields) == 0 {
+		return caps, nil
+	}
+	for field := range fields {
+		caps.Fields = append(caps.Fields, field)
+	}
+	sort.Strings(caps.Fields)
+	return caps, nil
+}
+
+// HasMethod reports whether the extension's interface has the method, e.g. "List"
+func (c ExtensionCapabilities) HasMethod(name string) bool {
+	for _, method := range c.Methods {
+		if method == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasField reports whether Get responses carry the field. Without a sample every field
+// counts as present, so nothing is turned off on a guess.
+func (c ExtensionCapabilities) HasField(name string) bool {
+	if c.Fields == nil {
+		return true
+	}
+	for _, field := range c.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingRequired returns the RequiredExtensionFields Get responses lack
+func (c ExtensionCapabilities) MissingRequired() []string {
+	var missing []string
+	for _, field := range RequiredExtensionFields {
+		if !c.HasField(field) {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// Warnings describes, one line each, what an older extension version can't do: required
+// fields that are missing, then List, then the optional fields and their features
+func (c ExtensionCapabilities) Warnings() []string {
+	var warnings []string
+	if missing := c.MissingRequired(); len(missing) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Get responses lack %s, which tracking needs; update the extension to a current version from %s", strings.Join(missing, ", "), ExtensionURL))
+	}
+	if !c.HasMethod("Get") {
+		warnings = append(warnings, "the extension has no Get method, so the focused window can't be read")
+	}
+	if !c.HasMethod("List") {
+		warnings = append(warnings, "no List method (needed for -background-apps, -windows and the ignore tool's open-window list)")
+	}
+	for _, optional := range OptionalExtensionFields {
+		if !c.HasField(optional.Field) {
+			warnings = append(warnings, fmt.Sprintf("no %s field (needed for %s)", optional.Field, optional.Feature))
+		}
+	}
+	return warnings
+}
+
+// Summary describes the capabilities in one line, e.g. "methods Get, List; 27 fields"
+func (c ExtensionCapabilities) Summary() string {
+	fields := "no sample response"
+	if c.Fields != nil {
+		fields = fmt.Sprintf("%d fields", len(c.Fields))
+	}
+	return fmt.Sprintf("methods %s; %s", strings.Join(c.Methods, ", "), fields)
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProbeExtension tests capability detection against captured introspection XML and a
+// current Get response, and against an older extension with only Get and fewer fields
+func TestProbeExtension(t *testing.T) {
+	introspection, err := os.ReadFile("testdata/introspect.xml")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+	sample, err := os.ReadFile("testdata/focused-window.json")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+
+	current, err := ProbeExtension(string(introspection), string(sample))
+	if err != nil {
+		t.Fatalf("ProbeExtension failed: %v", err)
+	}
+	if !reflect.DeepEqual(current.Methods, []string{"Get", "List"}) {
+		t.Errorf("Expected the extension's own methods only, got %v", current.Methods)
+	}
+	if warnings := current.Warnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings for a current extension, got %v", warnings)
+	}
+
+	// Only Get, and a response without wm_class_instance or in_current_workspace
+	old := strings.Replace(string(introspection), `<method name="List">`, `<method name="Unused">`, 1)
+	caps, err := ProbeExtension(old, `{"title":"main.go","wm_class":"code","pid":4242,"focus":true,"maximized":false,"area":{}}`)
+	if err != nil {
+		t.Fatalf("ProbeExtension failed: %v", err)
+	}
+	if caps.HasMethod("List") || !caps.HasMethod("Get") {
+		t.Errorf("Expected Get without List, got %v", caps.Methods)
+	}
+	if caps.HasField("wm_class_instance") || caps.HasField("in_current_workspace") || !caps.HasField("wm_class") {
+		t.Errorf("Unexpected fields %v", caps.Fields)
+	}
+	warnings := strings.Join(caps.Warnings(), "\n")
+	for _, want := range []string{"List", "wm_class_instance", "in_current_workspace"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Expected a warning about %s, got:\n%s", want, warnings)
+		}
+	}
+	if len(caps.MissingRequired()) != 0 {
+		t.Errorf("Expected the required fields present, got %v missing", caps.MissingRequired())
+	}
+
+	// A version too old to track with at all
+	caps, err = ProbeExtension(old, `{"title":"main.go","class":"code"}`)
+	if err != nil {
+		t.Fatalf("ProbeExtension failed: %v", err)
+	}
+	if missing := caps.MissingRequired(); !reflect.DeepEqual(missing, []string{"wm_class"}) {
+		t.Errorf("Expected wm_class missing, got %v", missing)
+	}
+	if warnings := caps.Warnings(); len(warnings) == 0 || !strings.Contains(warnings[0], ExtensionURL) {
+		t.Errorf("Expected the first warning to point at the extension page, got %v", warnings)
+	}
+}
+
+// TestProbeExtensionWithoutSample tests that no sample, or an empty one, reports no fields
+// missing, and that XML without the extension's interface or invalid input fails
+func TestProbeExtensionWithoutSample(t *testing.T) {
+	introspection, err := os.ReadFile("testdata/introspect.xml")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+
+	for _, sample := range []string{"", "{}", "  "} {
+		caps, err := ProbeExtension(string(introspection), sample)
+		if err != nil {
+			t.Fatalf("ProbeExtension(%q) failed: %v", sample, err)
+		}
+		if caps.Fields != nil || !caps.HasField("wm_class_instance") || len(caps.Warnings()) != 0 {
+			t.Errorf("Expected unknown fields to count as present for %q, got %+v", sample, caps)
+		}
+		if summary := caps.Summary(); summary != "methods Get, List; no sample response" {
+			t.Errorf("Unexpected summary %q", summary)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		xml    string
+		sample string
+	}{
+		{name: "invalid XML", xml: "<node><interface"},
+		{name: "interface missing", xml: `<node><interface name="org.freedesktop.DBus.Peer"><method name="Ping"/></interface></node>`},
+		{name: "invalid sample", xml: string(introspection), sample: "not json"},
+	}
+	for _, tt := range tests {
+		if _, err := ProbeExtension(tt.xml, tt.sample); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package desktopentry resolves a window's WmClass to the application's desktop entry
+// (the .desktop file under the XDG data directories), for its user-facing name and icon.
+//
+// A window matches an entry whose StartupWMClass is its class or instance, or else an
+// entry whose ID is its class (e.g. "org.gnome.Nautilus" for org.gnome.Nautilus.desktop),
+// compared case-insensitively, as GNOME Shell does. Entries in earlier data directories
+// take precedence, so a copy in ~/.local/share/applications overrides the system one.
+package desktopentry
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Entry is the part of a desktop entry dashboards show
+type Entry struct {
+	ID   string // desktop file ID, e.g. "code.desktop" or "org.gnome.Nautilus.desktop"
+	Name string // Name, localized for the user's locale when the entry has a translation
+	Icon string // icon theme name or absolute path
+}
+
+// DataDirs returns the XDG data directories in precedence order: XDG_DATA_HOME
+// (default ~/.local/share), then XDG_DATA_DIRS (default /usr/local/share:/usr/share)
+func DataDirs() []string {
+	var dirs []string
+	if home := os.Getenv("XDG_DATA_HOME"); home != "" {
+		dirs = append(dirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "share"))
+	}
+
+	system := os.Getenv("XDG_DATA_DIRS")
+	if system == "" {
+		system = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range strings.Split(system, ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Locale returns the locale names are localized for, from LC_ALL, LC_MESSAGES or LANG
+func Locale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Resolver looks up desktop entries by WmClass. The applications directories are scanned
+// once, on the first lookup; entries installed later are picked up by a new Resolver.
+// It's safe for concurrent use.
+type Resolver struct {
+	dirs   []string
+	locale string
+
+	once      sync.Once
+	byWMClass map[string]Entry // lowercased StartupWMClass
+	byID      map[string]Entry // lowercased ID without ".desktop"
+}
+
+// NewResolver returns a resolver over the given data directories (see DataDirs), with
+// names localized for locale (e.g. "de_DE.UTF-8"; "" for the untranslated Name)
+func NewResolver(dataDirs []string, locale string) *Resolver {
+	return &Resolver{dirs: dataDirs, locale: locale}
+}
+
+// Lookup returns the desktop entry for a window's WmClass and WmClassInstance (which may
+// be ""), and whether one was found
+func (r *Resolver) Lookup(wmClass, instance string) (Entry, bool) {
+	if r == nil {
+		return Entry{}, false
+	}
+	r.once.Do(r.scan)
+
+	for _, class := range []string{wmClass, instance} {
+		if entry, ok := r.byWMClass[strings.ToLower(class)]; ok && class != "" {
+			return entry, true
+		}
+	}
+	for _, class := range []string{wmClass, instance} {
+		if entry, ok := r.byID[strings.ToLower(class)]; ok && class != "" {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// scan indexes the entries in each data directory's applications subdirectory
+func (r *Resolver) scan() {
+	r.byWMClass = make(map[string]Entry)
+	r.byID = make(map[string]Entry)
+	seen := make(map[string]bool)
+
+	for _, dir := range r.dirs {
+		root := filepath.Join(dir, "applications")
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".desktop") {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil
+			}
+			// Subdirectories are part of the ID: kde/konsole.desktop is kde-konsole.desktop
+			id := strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+
+			fields, err := readDesktopEntry(path)
+			if err != nil || fields["Hidden"] == "true" || fields["Type"] != "Application" {
+				return nil
+			}
+			entry := Entry{ID: id, Name: localizedName(fields, r.locale), Icon: fields["Icon"]}
+			if class := strings.ToLower(fields["StartupWMClass"]); class != "" {
+				if _, ok := r.byWMClass[class]; !ok {
+					r.byWMClass[class] = entry
+				}
+			}
+			r.byID[strings.ToLower(strings.TrimSuffix(id, ".desktop"))] = entry
+			return nil
+		})
+	}
+}
+
+// readDesktopEntry returns the keys of a file's [Desktop Entry] group, including
+// localized ones like "Name[de]"
+func readDesktopEntry(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	inEntry := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			// Actions and other groups come after the main one
+			if inEntry {
+				break
+			}
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		if !inEntry {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return fields, scanner.Err()
+}
+
+// localizedName picks Name[lang_COUNTRY@MODIFIER] and its fallbacks for locale, as the
+// Desktop Entry spec describes, ending with the plain Name
+func localizedName(fields map[string]string, locale string) string {
+	// "de_DE.UTF-8@euro": the encoding isn't part of the key
+	locale, modifier, _ := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	lang, country, _ := strings.Cut(locale, "_")
+
+	var keys []string
+	if lang != "" && lang != "C" && lang != "POSIX" {
+		if country != "" && modifier != "" {
+			keys = append(keys, lang+"_"+country+"@"+modifier)
+		}
+		if country != "" {
+			keys = append(keys, lang+"_"+country)
+		}
+		if modifier != "" {
+			keys = append(keys, lang+"@"+modifier)
+		}
+		keys = append(keys, lang)
+	}
+	for _, key := range keys {
+		if name := fields["Name["+key+"]"]; name != "" {
+			return name
+		}
+	}
+	return fields["Name"]
+}
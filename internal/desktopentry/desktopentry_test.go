@@ -0,0 +1,132 @@
+package desktopentry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEntry writes a .desktop fixture under dir's applications directory
+func writeEntry(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, "applications", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create applications dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+// newFixtureTree builds user and system data directories with entries for VS Code,
+// Nautilus, Firefox and Konsole, and points XDG_DATA_HOME and XDG_DATA_DIRS at them
+func newFixtureTree(t *testing.T) {
+	t.Helper()
+	user, system := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_DATA_HOME", user)
+	t.Setenv("XDG_DATA_DIRS", system+":"+filepath.Join(system, "missing"))
+
+	writeEntry(t, system, "code.desktop", `[Desktop Entry]
+Name=Visual Studio Code
+Comment=Code Editing. Redefined.
+Exec=/usr/share/code/code %F
+Icon=vscode
+Type=Application
+StartupWMClass=Code
+
+[Desktop Action new-empty-window]
+Name=New Empty Window
+Icon=vscode-empty
+`)
+	writeEntry(t, system, "org.gnome.Nautilus.desktop", `# Files
+[Desktop Entry]
+Name=Files
+Name[de]=Dateien
+Name[pt_BR]=Arquivos
+Icon=org.gnome.Nautilus
+Type=Application
+`)
+	writeEntry(t, system, "firefox.desktop", `[Desktop Entry]
+Name=Firefox
+Icon=firefox
+Type=Application
+StartupWMClass=firefox
+`)
+	writeEntry(t, system, "kde/konsole.desktop", `[Desktop Entry]
+Name=Konsole
+Icon=utilities-terminal
+Type=Application
+StartupWMClass=konsole
+`)
+	writeEntry(t, system, "steam.desktop", `[Desktop Entry]
+Name=Steam
+Icon=steam
+Type=Application
+`)
+
+	// The user's own copies override the system entries, or hide them
+	writeEntry(t, user, "firefox.desktop", `[Desktop Entry]
+Name=Firefox (work profile)
+Icon=firefox-work
+Type=Application
+StartupWMClass=firefox
+`)
+	writeEntry(t, user, "steam.desktop", `[Desktop Entry]
+Name=Steam
+Type=Application
+Hidden=true
+`)
+}
+
+// TestLookup tests matching by StartupWMClass, by desktop file ID and by instance, with
+// user entries overriding system ones
+func TestLookup(t *testing.T) {
+	newFixtureTree(t)
+	resolver := NewResolver(DataDirs(), "")
+
+	tests := []struct {
+		class, instance string
+		want            Entry
+		found           bool
+	}{
+		{"Code", "code", Entry{ID: "code.desktop", Name: "Visual Studio Code", Icon: "vscode"}, true},
+		{"code", "", Entry{ID: "code.desktop", Name: "Visual Studio Code", Icon: "vscode"}, true},
+		{"org.gnome.Nautilus", "org.gnome.Nautilus", Entry{ID: "org.gnome.Nautilus.desktop", Name: "Files", Icon: "org.gnome.Nautilus"}, true},
+		{"Firefox", "Navigator", Entry{ID: "firefox.desktop", Name: "Firefox (work profile)", Icon: "firefox-work"}, true},
+		{"Konsole", "", Entry{ID: "kde-konsole.desktop", Name: "Konsole", Icon: "utilities-terminal"}, true},
+		{"Unknown-App", "konsole", Entry{ID: "kde-konsole.desktop", Name: "Konsole", Icon: "utilities-terminal"}, true},
+		{"steam", "", Entry{}, false},
+		{"gimp-2.10", "gimp", Entry{}, false},
+		{"", "", Entry{}, false},
+	}
+	for _, tt := range tests {
+		got, found := resolver.Lookup(tt.class, tt.instance)
+		if got != tt.want || found != tt.found {
+			t.Errorf("Lookup(%q, %q): expected %+v, %v, got %+v, %v", tt.class, tt.instance, tt.want, tt.found, got, found)
+		}
+	}
+
+	var none *Resolver
+	if _, found := none.Lookup("code", ""); found {
+		t.Error("Expected a nil resolver to find nothing")
+	}
+}
+
+// TestLocalizedName tests the Desktop Entry spec's locale fallbacks for Name
+func TestLocalizedName(t *testing.T) {
+	newFixtureTree(t)
+
+	for locale, want := range map[string]string{
+		"de_DE.UTF-8":      "Dateien",
+		"de_AT.UTF-8@euro": "Dateien",
+		"pt_BR.UTF-8":      "Arquivos",
+		"pt_PT.UTF-8":      "Files",
+		"C.UTF-8":          "Files",
+		"":                 "Files",
+	} {
+		entry, _ := NewResolver(DataDirs(), locale).Lookup("org.gnome.Nautilus", "")
+		if entry.Name != want {
+			t.Errorf("%q: expected %q, got %q", locale, want, entry.Name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package logging provides the leveled, timestamped log output shared by the
+// active-window commands.
+//
+// Debug and Verbose lines are only shown when enabled. Activity lines (window
+// changes) are shown with verbose or when enabled on their own, and quiet
+// suppresses them, so submission summaries stay visible without per-window noise.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultClock formats the timestamp every line starts with until SetClock is called:
+// time of day, no seconds, no date
+func defaultClock(t time.Time) string { return t.Format("15:04") }
+
+var (
+	logger = log.New(os.Stderr, "", 0)
+	clock  atomic.Pointer[func(time.Time) string]
+
+	debugEnabled    atomic.Bool
+	verboseEnabled  atomic.Bool
+	activityEnabled atomic.Bool
+	quietEnabled    atomic.Bool
+
+	// Color functions for different log levels
+	colorDebug   = color.New(color.FgCyan).SprintfFunc()
+	colorVerbose = color.New(color.FgBlue).SprintfFunc()
+	colorInfo    = color.New(color.FgGreen).SprintfFunc()
+	colorError   = color.New(color.FgRed, color.Bold).SprintfFunc()
+	colorWarning = color.New(color.FgYellow).SprintfFunc()
+	colorSuccess = color.New(color.FgGreen, color.Bold).SprintfFunc()
+)
+
+// SetOutput sends log lines, and console output printed through the color
+// package (e.g. by the rescuetime and webhook clients), to w.
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+	color.Output = w
+	color.Error = w
+}
+
+// SetClock sets how the timestamp every line starts with is formatted, e.g. the console's
+// -time-format clock. nil restores the default 24-hour clock.
+func SetClock(format func(time.Time) string) {
+	if format == nil {
+		clock.Store(nil)
+		return
+	}
+	clock.Store(&format)
+}
+
+// SetDebug enables Debug lines (and Verbose lines, which debug implies)
+func SetDebug(enabled bool) { debugEnabled.Store(enabled) }
+
+// SetVerbose enables Verbose and Activity lines
+func SetVerbose(enabled bool) { verboseEnabled.Store(enabled) }
+
+// SetActivity enables Activity lines without the rest of verbose output
+func SetActivity(enabled bool) { activityEnabled.Store(enabled) }
+
+// SetQuiet suppresses Activity lines even when verbose
+func SetQuiet(enabled bool) { quietEnabled.Store(enabled) }
+
+// DebugEnabled reports whether Debug lines are shown
+func DebugEnabled() bool { return debugEnabled.Load() }
+
+// VerboseEnabled reports whether Verbose lines are shown
+func VerboseEnabled() bool { return verboseEnabled.Load() || debugEnabled.Load() }
+
+// printLine writes one timestamped line
+func printLine(line string) {
+	format := defaultClock
+	if custom := clock.Load(); custom != nil {
+		format = *custom
+	}
+	logger.Printf("%s %s", format(time.Now()), line)
+}
+
+// Debug prints debug messages if debug mode is enabled
+func Debug(format string, args ...interface{}) {
+	if DebugEnabled() {
+		printLine(colorDebug("[DEBUG] "+format, args...))
+	}
+}
+
+// Verbose prints verbose messages if verbose mode is enabled
+func Verbose(format string, args ...interface{}) {
+	if VerboseEnabled() {
+		printLine(colorVerbose("[VERBOSE] "+format, args...))
+	}
+}
+
+// Activity prints a window change line if verbose or activity output is enabled,
+// unless quiet. The line is printed as given, since it's already formatted for the console.
+func Activity(format string, args ...interface{}) {
+	if (activityEnabled.Load() || VerboseEnabled()) && !quietEnabled.Load() {
+		printLine(fmt.Sprintf(format, args...))
+	}
+}
+
+// Info prints info messages (always shown)
+func Info(format string, args ...interface{}) {
+	printLine(colorInfo(format, args...))
+}
+
+// Error prints error messages (always shown)
+func Error(format string, args ...interface{}) {
+	printLine(colorError("[ERROR] "+format, args...))
+}
+
+// Warning prints warning messages (always shown)
+func Warning(format string, args ...interface{}) {
+	printLine(colorWarning("[WARNING] "+format, args...))
+}
+
+// Success prints success messages (always shown)
+func Success(format string, args ...interface{}) {
+	printLine(colorSuccess("[SUCCESS] "+format, args...))
+}
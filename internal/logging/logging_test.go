@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// captureOutput sends log output to a buffer for the duration of the test
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	oldOutput, oldError, oldNoColor := color.Output, color.Error, color.NoColor
+	color.NoColor = true
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		logger.SetOutput(oldOutput)
+		color.Output, color.Error, color.NoColor = oldOutput, oldError, oldNoColor
+		SetDebug(false)
+		SetVerbose(false)
+		SetActivity(false)
+		SetQuiet(false)
+	})
+	return &buf
+}
+
+// TestLevels tests which lines each mode shows
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		name                            string
+		debug, verbose, activity, quiet bool
+		want                            []string
+	}{
+		{"default", false, false, false, false, []string{"info", "[SUCCESS] success"}},
+		{"verbose", false, true, false, false, []string{"[VERBOSE] verbose", "window", "info", "[SUCCESS] success"}},
+		{"verbose quiet", false, true, false, true, []string{"[VERBOSE] verbose", "info", "[SUCCESS] success"}},
+		{"activity", false, false, true, false, []string{"window", "info", "[SUCCESS] success"}},
+		{"activity quiet", false, false, true, true, []string{"info", "[SUCCESS] success"}},
+		{"debug", true, false, false, false, []string{"[DEBUG] debug", "[VERBOSE] verbose", "window", "info", "[SUCCESS] success"}},
+	}
+	for _, tt := range tests {
+		buf := captureOutput(t)
+		SetDebug(tt.debug)
+		SetVerbose(tt.verbose)
+		SetActivity(tt.activity)
+		SetQuiet(tt.quiet)
+
+		Debug("debug")
+		Verbose("verbose")
+		Activity("window")
+		Info("info")
+		Success("success")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s: expected %d lines, got %q", tt.name, len(tt.want), lines)
+			continue
+		}
+		for i, line := range lines {
+			// Each line is "HH:MM message"
+			if _, msg, _ := strings.Cut(line, " "); msg != tt.want[i] {
+				t.Errorf("%s: line %d: expected %q, got %q", tt.name, i, tt.want[i], line)
+			}
+		}
+	}
+}
+
+// TestSetOutputRedirectsColor tests that color package output follows SetOutput
+func TestSetOutputRedirectsColor(t *testing.T) {
+	buf := captureOutput(t)
+	color.Yellow("[SKIPPED] app: too short")
+	if !strings.Contains(buf.String(), "[SKIPPED] app: too short") {
+		t.Errorf("Expected color output in the log, got %q", buf.String())
+	}
+}
+
+// TestSetClock tests that lines start with the clock SetClock gives, and that nil restores
+// the 24-hour default
+func TestSetClock(t *testing.T) {
+	buf := captureOutput(t)
+	t.Cleanup(func() { SetClock(nil) })
+
+	SetClock(func(time.Time) string { return "2:05 PM" })
+	Info("twelve")
+	SetClock(nil)
+	Info("default")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != "2:05 PM twelve" {
+		t.Fatalf("Expected the custom clock first, got %q", lines)
+	}
+	if stamp, _, _ := strings.Cut(lines[1], " "); len(stamp) != len("15:04") || stamp[2] != ':' {
+		t.Errorf("Expected a 24-hour timestamp, got %q", lines[1])
+	}
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that appends to a log file and rotates it once it
+// would exceed MaxSize bytes. Rotated files are gzip-compressed as path.1.gz
+// (newest) through path.<Keep>.gz (oldest); older archives are removed.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens (or creates) path for appending. keep is the number of
+// compressed archives to retain; 0 discards the old log on rotation.
+func OpenRotatingFile(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("log file max size must be positive, got %d", maxSize)
+	}
+	if keep < 0 {
+		return nil, fmt.Errorf("log file archive count must not be negative, got %d", keep)
+	}
+	r := &RotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the log file for appending and records its current size
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v\n\nTroubleshooting:\n  1. Check the directory exists and is writable: ls -ld $(dirname %s)\n  2. Use an absolute path when running from autostart or systemd", err, r.path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would push the file past the size cap.
+// A single write larger than the cap is written whole to a fresh file.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// archivePath returns the name of the nth compressed archive (1 is newest)
+func (r *RotatingFile) archivePath(n int) string {
+	return fmt.Sprintf("%s.%d.gz", r.path, n)
+}
+
+// rotate shifts the archives up by one, compresses the current file into
+// archive 1 and starts a new, empty log file (must be called with mu held)
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file for rotation: %v", err)
+	}
+	r.file = nil
+
+	if r.keep > 0 {
+		os.Remove(r.archivePath(r.keep))
+		for n := r.keep - 1; n >= 1; n-- {
+			os.Rename(r.archivePath(n), r.archivePath(n+1))
+		}
+		if err := compressFile(r.path, r.archivePath(1)); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove rotated log file: %v", err)
+	}
+	return r.open()
+}
+
+// compressFile writes a gzip-compressed copy of src to dst
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open log file for compression: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log archive: %v", err)
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		return fmt.Errorf("failed to compress log file: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to compress log file: %v", err)
+	}
+	return out.Close()
+}
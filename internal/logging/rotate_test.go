@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readArchive returns the decompressed contents of a rotated log
+func readArchive(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to read archive %s: %v", path, err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to decompress archive %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// TestRotatingFile tests size-based rotation and the archive cap
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "active-window.log")
+	r, err := OpenRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+
+	// Each line is 10 bytes, so every third line starts a new file
+	for _, line := range []string{"line 0001\n", "line 0002\n", "line 0003\n", "line 0004\n", "line 0005\n", "line 0006\n", "line 0007\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "line 0007\n" {
+		t.Errorf("Expected current log to hold the last line, got %q", current)
+	}
+	if got := readArchive(t, path+".1.gz"); got != "line 0005\nline 0006\n" {
+		t.Errorf("Unexpected newest archive: %q", got)
+	}
+	if got := readArchive(t, path+".2.gz"); got != "line 0003\nline 0004\n" {
+		t.Errorf("Unexpected oldest archive: %q", got)
+	}
+	// Lines 1-2 were in a third archive, which exceeds the cap
+	if _, err := os.Stat(path + ".3.gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 archives to be kept, stat err: %v", err)
+	}
+}
+
+// TestRotatingFileAppends tests that reopening continues the existing file and its size
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "active-window.log")
+	os.WriteFile(path, []byte(strings.Repeat("x", 15)), 0644)
+
+	r, err := OpenRotatingFile(path, 20, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("line 0001\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "line 0001\n" {
+		t.Errorf("Expected the existing 15 bytes to count toward the cap, got %q", current)
+	}
+	// keep=0 discards instead of archiving
+	if _, err := os.Stat(path + ".1.gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected no archive with keep=0, stat err: %v", err)
+	}
+}
+
+// TestOpenRotatingFileValidation tests invalid limits
+func TestOpenRotatingFileValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "active-window.log")
+	if _, err := OpenRotatingFile(path, 0, 1); err == nil {
+		t.Error("Expected error for zero max size")
+	}
+	if _, err := OpenRotatingFile(path, 10, -1); err == nil {
+		t.Error("Expected error for negative archive count")
+	}
+}
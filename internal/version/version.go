@@ -0,0 +1,87 @@
+// Package version identifies the build, so payloads and User-Agent strings can be traced
+// back to the binary that sent them. Release builds set the variables with -ldflags:
+//
+//	go build -ldflags "-X github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version.Version=v1.2.0 \
+//		-X github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version.Commit=$(git rev-parse --short HEAD) \
+//		-X github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, Commit and Date come from the VCS information the go command embeds.
+package version
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Product is the name in User-Agent strings and payloads
+const Product = "rescuetime-linux-mutter"
+
+// Build information, set with -ldflags -X
+var (
+	Version = "dev" // release version, e.g. v1.2.0
+	Commit  = ""    // short commit hash
+	Date    = ""    // build time, RFC 3339
+)
+
+// readBuildInfo is debug.ReadBuildInfo, replaced in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+func init() {
+	fillFromBuildInfo()
+}
+
+// fillFromBuildInfo sets Commit and Date from the embedded VCS information when -ldflags
+// didn't, marking the commit "-dirty" for a build with uncommitted changes
+func fillFromBuildInfo() {
+	info, ok := readBuildInfo()
+	if !ok {
+		return
+	}
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if Commit == "" && settings["vcs.revision"] != "" {
+		Commit = settings["vcs.revision"]
+		if len(Commit) > 7 {
+			Commit = Commit[:7]
+		}
+		if settings["vcs.modified"] == "true" {
+			Commit += "-dirty"
+		}
+	}
+	if Date == "" {
+		Date = settings["vcs.time"]
+	}
+}
+
+// String returns the version with the commit and build date when known, e.g.
+// "v1.2.0 (commit 1a2b3c4, built 2025-10-31T12:00:00Z)"
+func String() string {
+	var details []string
+	if Commit != "" {
+		details = append(details, "commit "+Commit)
+	}
+	if Date != "" {
+		details = append(details, "built "+Date)
+	}
+	if len(details) == 0 {
+		return Version
+	}
+	return Version + " (" + strings.Join(details, ", ") + ")"
+}
+
+// UserAgent returns the User-Agent for this tracker's own requests, e.g.
+// "rescuetime-linux-mutter/v1.2.0"
+func UserAgent() string {
+	return Product + "/" + Version
+}
+
+// Comment returns the build as a User-Agent comment to append to another product's
+// User-Agent, e.g. "(rescuetime-linux-mutter v1.2.0; 1a2b3c4)"
+func Comment() string {
+	if Commit == "" {
+		return "(" + Product + " " + Version + ")"
+	}
+	return "(" + Product + " " + Version + "; " + Commit + ")"
+}
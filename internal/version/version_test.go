@@ -0,0 +1,61 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+// setBuild replaces the build variables for a test
+func setBuild(t *testing.T, version, commit, date string) {
+	t.Helper()
+	oldVersion, oldCommit, oldDate := Version, Commit, Date
+	Version, Commit, Date = version, commit, date
+	t.Cleanup(func() { Version, Commit, Date = oldVersion, oldCommit, oldDate })
+}
+
+// TestString tests the version string with and without commit and date
+func TestString(t *testing.T) {
+	setBuild(t, "v1.2.0", "1a2b3c4", "2025-10-31T12:00:00Z")
+	if got, want := String(), "v1.2.0 (commit 1a2b3c4, built 2025-10-31T12:00:00Z)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got, want := UserAgent(), "rescuetime-linux-mutter/v1.2.0"; got != want {
+		t.Errorf("Expected User-Agent %q, got %q", want, got)
+	}
+	if got, want := Comment(), "(rescuetime-linux-mutter v1.2.0; 1a2b3c4)"; got != want {
+		t.Errorf("Expected comment %q, got %q", want, got)
+	}
+
+	setBuild(t, "dev", "", "")
+	if got := String(); got != "dev" {
+		t.Errorf("Expected just the version, got %q", got)
+	}
+	if got, want := Comment(), "(rescuetime-linux-mutter dev)"; got != want {
+		t.Errorf("Expected comment %q, got %q", want, got)
+	}
+}
+
+// TestFillFromBuildInfo tests that embedded VCS information fills in what -ldflags didn't set
+func TestFillFromBuildInfo(t *testing.T) {
+	oldRead := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = oldRead })
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "1a2b3c4d5e6f"},
+			{Key: "vcs.time", Value: "2025-10-31T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		}}, true
+	}
+
+	setBuild(t, "dev", "", "")
+	fillFromBuildInfo()
+	if Commit != "1a2b3c4-dirty" || Date != "2025-10-31T12:00:00Z" {
+		t.Errorf("Expected the VCS commit and time, got %q and %q", Commit, Date)
+	}
+
+	setBuild(t, "v1.2.0", "abcdef0", "2025-11-01T00:00:00Z")
+	fillFromBuildInfo()
+	if Commit != "abcdef0" || Date != "2025-11-01T00:00:00Z" {
+		t.Errorf("Expected -ldflags values kept, got %q and %q", Commit, Date)
+	}
+}
@@ -0,0 +1,291 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// MQTT 3.1.1 control packet headers (http://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html)
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPingreq    = 0xC0
+	packetPingresp   = 0xD0
+	packetDisconnect = 0xE0
+
+	protocolLevel = 4 // MQTT 3.1.1
+
+	connectFlagUsername   = 0x80
+	connectFlagPassword   = 0x40
+	connectFlagWillRetain = 0x20
+	connectFlagWill       = 0x04
+	connectFlagClean      = 0x02
+
+	publishFlagRetain = 0x01
+
+	maxRemainingLength = 268435455 // largest length the 4-byte encoding can express
+	ioTimeout          = 10 * time.Second
+)
+
+// connackErrors are the CONNACK return codes refusing a connection
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// tcpBroker speaks just enough MQTT 3.1.1 to publish: CONNECT, PUBLISH at QoS 0, PINGREQ and
+// DISCONNECT, over TCP or TLS. Nothing is subscribed, so the only packets the broker sends
+// are CONNACK and PINGRESP.
+type tcpBroker struct {
+	address  string
+	tls      *tls.Config // nil for plain TCP
+	username string      // from the URL
+	password string
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newTCPBroker parses a broker URL: mqtt:// or tcp:// (default port 1883), mqtts:// or
+// ssl:// (TLS, default port 8883)
+func newTCPBroker(rawURL string, tlsConfig *tls.Config) (*tcpBroker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid MQTT broker URL %q: expected mqtt://host[:port] or mqtts://host[:port]", rawURL)
+	}
+
+	b := &tcpBroker{}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		port = "8883"
+		b.tls = &tls.Config{}
+		if tlsConfig != nil {
+			b.tls = tlsConfig.Clone()
+		}
+		if b.tls.ServerName == "" {
+			b.tls.ServerName = u.Hostname()
+		}
+	default:
+		return nil, fmt.Errorf("invalid MQTT broker URL %q: unknown scheme %q (expected mqtt, tcp, mqtts or ssl)", rawURL, u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	b.address = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		b.username = u.User.Username()
+		b.password, _ = u.User.Password()
+	}
+	return b, nil
+}
+
+// Connect dials the broker and waits for it to accept the session
+func (b *tcpBroker) Connect(ctx context.Context, opts ConnectOptions) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", b.address)
+	if err != nil {
+		return err
+	}
+	if b.tls != nil {
+		tlsConn := tls.Client(conn, b.tls)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		conn = tlsConn
+	}
+	b.conn, b.reader = conn, bufio.NewReader(conn)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	err = b.handshake(opts)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		b.conn = nil
+		return err
+	}
+	return nil
+}
+
+// handshake sends CONNECT and reads the CONNACK
+func (b *tcpBroker) handshake(opts ConnectOptions) error {
+	if _, err := b.conn.Write(connectPacket(opts)); err != nil {
+		return err
+	}
+	header, body, err := readPacket(b.reader)
+	if err != nil {
+		return fmt.Errorf("no CONNACK from the broker: %v", err)
+	}
+	if header != packetConnack || len(body) != 2 {
+		return fmt.Errorf("expected CONNACK from the broker, got packet type 0x%02x", header)
+	}
+	if code := body[1]; code != 0 {
+		reason, ok := connackErrors[code]
+		if !ok {
+			reason = fmt.Sprintf("return code %d", code)
+		}
+		return fmt.Errorf("broker refused the connection: %s", reason)
+	}
+	return nil
+}
+
+// Publish sends a QoS 0 message
+func (b *tcpBroker) Publish(topic string, payload []byte, retain bool) error {
+	packet, err := publishPacket(topic, payload, retain)
+	if err != nil {
+		return err
+	}
+	return b.write(packet)
+}
+
+// Ping sends PINGREQ and waits for the PINGRESP
+func (b *tcpBroker) Ping() error {
+	if err := b.write([]byte{packetPingreq, 0}); err != nil {
+		return err
+	}
+	b.conn.SetReadDeadline(time.Now().Add(ioTimeout))
+	defer b.conn.SetReadDeadline(time.Time{})
+	header, _, err := readPacket(b.reader)
+	if err != nil {
+		return fmt.Errorf("no PINGRESP from the broker: %v", err)
+	}
+	if header != packetPingresp {
+		return fmt.Errorf("expected PINGRESP from the broker, got packet type 0x%02x", header)
+	}
+	return nil
+}
+
+// Close sends DISCONNECT (best effort) and closes the connection
+func (b *tcpBroker) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+	b.write([]byte{packetDisconnect, 0})
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// write sends a packet with a deadline, so a stalled broker can't block the publisher
+func (b *tcpBroker) write(packet []byte) error {
+	if b.conn == nil {
+		return errors.New("not connected")
+	}
+	b.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	_, err := b.conn.Write(packet)
+	return err
+}
+
+// connectPacket encodes CONNECT with a clean session
+func connectPacket(opts ConnectOptions) []byte {
+	flags := byte(connectFlagClean)
+	if opts.WillTopic != "" {
+		flags |= connectFlagWill | connectFlagWillRetain
+	}
+	if opts.Username != "" {
+		flags |= connectFlagUsername
+		if opts.Password != "" {
+			flags |= connectFlagPassword
+		}
+	}
+	keepAlive := uint16(opts.KeepAlive / time.Second)
+
+	body := appendString(nil, []byte("MQTT"))
+	body = append(body, protocolLevel, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, []byte(opts.ClientID))
+	if opts.WillTopic != "" {
+		body = appendString(body, []byte(opts.WillTopic))
+		body = appendString(body, opts.WillPayload)
+	}
+	if opts.Username != "" {
+		body = appendString(body, []byte(opts.Username))
+		if opts.Password != "" {
+			body = appendString(body, []byte(opts.Password))
+		}
+	}
+	return appendPacket(packetConnect, body)
+}
+
+// publishPacket encodes a QoS 0 PUBLISH
+func publishPacket(topic string, payload []byte, retain bool) ([]byte, error) {
+	if topic == "" || len(topic) > 0xFFFF {
+		return nil, fmt.Errorf("invalid MQTT topic %q", topic)
+	}
+	header := byte(packetPublish)
+	if retain {
+		header |= publishFlagRetain
+	}
+	body := appendString(nil, []byte(topic))
+	body = append(body, payload...)
+	if len(body) > maxRemainingLength {
+		return nil, fmt.Errorf("MQTT message for %s is too large (%d bytes)", topic, len(payload))
+	}
+	return appendPacket(header, body), nil
+}
+
+// appendPacket returns the fixed header (type and remaining length) followed by body
+func appendPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// appendString appends a length-prefixed UTF-8 string (or binary data)
+func appendString(b []byte, s []byte) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// readPacket reads one control packet, returning its first header byte and its body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
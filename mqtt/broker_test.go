@@ -0,0 +1,133 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTCPBroker tests the packets tcpBroker exchanges with a scripted broker: CONNECT with
+// credentials and a retained will, a retained PUBLISH, PINGREQ and DISCONNECT
+func TestTCPBroker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	type packet struct {
+		header byte
+		body   []byte
+	}
+	received := make(chan packet, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			header, body, err := readPacket(reader)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- packet{header, body}
+			switch header {
+			case packetConnect:
+				conn.Write([]byte{packetConnack, 2, 0, 0})
+			case packetPingreq:
+				conn.Write([]byte{packetPingresp, 0})
+			}
+		}
+	}()
+
+	broker, err := newTCPBroker("mqtt://ha:secret@"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("newTCPBroker failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := ConnectOptions{ClientID: "rescuetime-test", Username: broker.username, Password: broker.password, KeepAlive: time.Minute, WillTopic: "rescuetime/status", WillPayload: []byte("offline")}
+	if err := broker.Connect(ctx, opts); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := broker.Publish("rescuetime/current_app", []byte("code"), true); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := broker.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	broker.Close()
+
+	var packets []packet
+	for p := range received {
+		packets = append(packets, p)
+	}
+	if len(packets) != 4 {
+		t.Fatalf("Expected CONNECT, PUBLISH, PINGREQ and DISCONNECT, got %d packets", len(packets))
+	}
+
+	connect := packets[0].body
+	wantConnect := appendString(nil, []byte("MQTT"))
+	wantConnect = append(wantConnect, protocolLevel, connectFlagUsername|connectFlagPassword|connectFlagWillRetain|connectFlagWill|connectFlagClean, 0, 60)
+	for _, field := range []string{"rescuetime-test", "rescuetime/status", "offline", "ha", "secret"} {
+		wantConnect = appendString(wantConnect, []byte(field))
+	}
+	if packets[0].header != packetConnect || !bytes.Equal(connect, wantConnect) {
+		t.Errorf("Unexpected CONNECT:\n got %q\nwant %q", connect, wantConnect)
+	}
+
+	wantPublish := append(appendString(nil, []byte("rescuetime/current_app")), "code"...)
+	if packets[1].header != packetPublish|publishFlagRetain || !bytes.Equal(packets[1].body, wantPublish) {
+		t.Errorf("Unexpected PUBLISH 0x%02x %q", packets[1].header, packets[1].body)
+	}
+	if packets[2].header != packetPingreq || packets[3].header != packetDisconnect {
+		t.Errorf("Expected PINGREQ then DISCONNECT, got 0x%02x and 0x%02x", packets[2].header, packets[3].header)
+	}
+}
+
+// TestTCPBrokerRefused tests that a CONNACK refusal is reported with its reason
+func TestTCPBrokerRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readPacket(bufio.NewReader(conn))
+		conn.Write([]byte{packetConnack, 2, 0, 5})
+	}()
+
+	broker, err := newTCPBroker("tcp://"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("newTCPBroker failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = broker.Connect(ctx, ConnectOptions{ClientID: "rescuetime-test"})
+	if err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("Expected a not authorized error, got %v", err)
+	}
+}
+
+// TestRemainingLength tests the variable-length encoding around its byte boundaries
+func TestRemainingLength(t *testing.T) {
+	for _, length := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		packet := appendPacket(packetPublish, make([]byte, length))
+		header, body, err := readPacket(bufio.NewReader(bytes.NewReader(packet)))
+		if err != nil || header != packetPublish || len(body) != length {
+			t.Errorf("length %d: got header 0x%02x, %d bytes, err %v", length, header, len(body), err)
+		}
+	}
+}
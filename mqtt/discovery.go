@@ -0,0 +1,104 @@
+package mqtt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// haStateMaxLength is the longest state Home Assistant stores for an entity
+const haStateMaxLength = 255
+
+// discoveryConfig is a Home Assistant MQTT discovery message for one sensor
+// (https://www.home-assistant.io/integrations/sensor.mqtt/)
+type discoveryConfig struct {
+	Name                   string          `json:"name"`
+	UniqueID               string          `json:"unique_id"`
+	StateTopic             string          `json:"state_topic"`
+	AvailabilityTopic      string          `json:"availability_topic"`
+	Icon                   string          `json:"icon,omitempty"`
+	UnitOfMeasurement      string          `json:"unit_of_measurement,omitempty"`
+	ValueTemplate          string          `json:"value_template,omitempty"`
+	JSONAttributesTopic    string          `json:"json_attributes_topic,omitempty"`
+	JSONAttributesTemplate string          `json:"json_attributes_template,omitempty"`
+	Device                 discoveryDevice `json:"device"`
+}
+
+// discoveryDevice groups the sensors under one device per machine
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// discoveryMessages returns the retained config messages that make Home Assistant create a
+// sensor for the current application, the current title and today's tracked time (with the
+// per-application seconds as attributes)
+func (c *Client) discoveryMessages() ([]message, error) {
+	if c.cfg.NoDiscovery {
+		return nil, nil
+	}
+
+	device := discoveryDevice{
+		Identifiers:  []string{"rescuetime_" + c.cfg.NodeID},
+		Name:         "RescueTime " + c.cfg.NodeID,
+		Manufacturer: "rescuetime-linux-mutter",
+		Model:        "GNOME activity tracker",
+	}
+	sensors := []struct {
+		objectID string
+		config   discoveryConfig
+	}{
+		{"current_app", discoveryConfig{
+			Name:       "Current app",
+			StateTopic: c.topic(TopicCurrentApp),
+			Icon:       "mdi:application",
+		}},
+		{"current_title", discoveryConfig{
+			Name:       "Current title",
+			StateTopic: c.topic(TopicCurrentTitle),
+			Icon:       "mdi:format-title",
+			// Longer states are rejected, so long titles are cut
+			ValueTemplate: "{{ value[:" + strconv.Itoa(haStateMaxLength) + "] }}",
+		}},
+		{"tracked_today", discoveryConfig{
+			Name:                   "Tracked today",
+			StateTopic:             c.topic(TopicTotals),
+			Icon:                   "mdi:timer-outline",
+			UnitOfMeasurement:      "min",
+			ValueTemplate:          "{{ (value_json.total_seconds / 60) | round(0) }}",
+			JSONAttributesTopic:    c.topic(TopicTotals),
+			JSONAttributesTemplate: "{{ value_json.apps | tojson }}",
+		}},
+	}
+
+	messages := make([]message, 0, len(sensors))
+	for _, sensor := range sensors {
+		config := sensor.config
+		config.UniqueID = "rescuetime_" + c.cfg.NodeID + "_" + sensor.objectID
+		config.AvailabilityTopic = c.topic(TopicStatus)
+		config.Device = device
+
+		payload, err := rescuetime.EncodeJSON(config)
+		if err != nil {
+			return nil, err
+		}
+		topic := c.cfg.DiscoveryPrefix + "/sensor/rescuetime_" + c.cfg.NodeID + "/" + sensor.objectID + "/config"
+		messages = append(messages, message{topic, payload})
+	}
+	return messages, nil
+}
+
+// sanitizeID keeps the characters Home Assistant allows in discovery node and object IDs
+// (letters, digits, _ and -), replacing the rest with _
+func sanitizeID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, id)
+}
@@ -0,0 +1,449 @@
+// Package mqtt publishes the focused application and today's per-application totals to an
+// MQTT broker as retained messages, for home automation (e.g. Home Assistant turning on a
+// "do not disturb" light while a meeting app is focused). Home Assistant MQTT discovery
+// messages are sent on every connect, so the values appear as sensors automatically.
+//
+// Publishing never blocks the caller: the latest value of each topic is kept and a
+// background goroutine sends it, reconnecting with exponential backoff when the broker is
+// lost and republishing everything once it is back.
+//
+// Example usage:
+//
+//	client, err := mqtt.NewClient(mqtt.Config{BrokerURL: "mqtt://homeassistant.local:1883"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer client.Close(context.Background())
+//
+//	client.PublishWindow("code", "main.go - rescuetime-linux-mutter")
+//	client.PublishTotals(time.Now(), map[string]time.Duration{"code": 2 * time.Hour})
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+	"github.com/fatih/color"
+)
+
+// Configuration constants
+const (
+	// DefaultTopicPrefix is where the state topics are published unless Config.TopicPrefix is set
+	DefaultTopicPrefix = "rescuetime"
+
+	// DefaultDiscoveryPrefix is Home Assistant's default MQTT discovery prefix
+	DefaultDiscoveryPrefix = "homeassistant"
+
+	keepAlive         = 60 * time.Second // the broker drops the connection after 1.5x this without a packet
+	connectTimeout    = 10 * time.Second
+	syncInterval      = time.Second // how often the publisher retries the broker and checks the keepalive
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 5 * time.Minute
+)
+
+// State topics, below the topic prefix
+const (
+	TopicCurrentApp   = "current_app"   // WmClass of the focused window, "" while idle
+	TopicCurrentTitle = "current_title" // title of the focused window, "" while idle
+	TopicTotals       = "totals"        // today's Totals as JSON
+	TopicStatus       = "status"        // "online", or "offline" (also the connection's last will)
+)
+
+// Availability payloads for TopicStatus
+const (
+	statusOnline  = "online"
+	statusOffline = "offline"
+)
+
+// Broker is an MQTT connection that can publish. The client talks to it from one goroutine
+// at a time. Tests use an in-memory broker.
+type Broker interface {
+	// Connect opens a session; the broker publishes the will (retained) if it is lost
+	Connect(ctx context.Context, opts ConnectOptions) error
+	// Publish sends a message at QoS 0
+	Publish(topic string, payload []byte, retain bool) error
+	// Ping checks the connection is alive and keeps the broker from timing it out
+	Ping() error
+	// Close disconnects (cleanly, so the will isn't published)
+	Close() error
+}
+
+// ConnectOptions are the CONNECT parameters
+type ConnectOptions struct {
+	ClientID    string
+	Username    string
+	Password    string
+	KeepAlive   time.Duration
+	WillTopic   string // retained; "" for no will
+	WillPayload []byte
+}
+
+// Config configures a Client
+type Config struct {
+	// BrokerURL is mqtt:// or tcp:// (port 1883 by default), or mqtts:// or ssl:// for TLS
+	// (8883). user:password@ in the URL is used when Username isn't set.
+	BrokerURL string
+	Username  string
+	Password  string
+	TLS       *tls.Config // for mqtts://; nil verifies the broker against the system roots
+
+	TopicPrefix     string // default DefaultTopicPrefix
+	DiscoveryPrefix string // default DefaultDiscoveryPrefix
+	NoDiscovery     bool   // don't send Home Assistant discovery messages
+
+	// NodeID names this machine in the client ID and Home Assistant entity IDs
+	// (default: the hostname)
+	NodeID string
+
+	// Broker, if set, is used instead of dialing BrokerURL
+	Broker Broker
+}
+
+// Totals is the TopicTotals payload: today's tracked time in seconds, per application
+type Totals struct {
+	Date         string           `json:"date"` // local YYYY-MM-DD
+	TotalSeconds int64            `json:"total_seconds"`
+	Apps         map[string]int64 `json:"apps"`
+}
+
+// message is a retained message waiting to be published
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// Client publishes the tracker's state to an MQTT broker
+type Client struct {
+	DebugMode bool
+
+	cfg     Config
+	broker  Broker
+	options ConnectOptions
+
+	mu    sync.Mutex
+	state map[string][]byte // latest payload per topic
+	order []string          // topics in the order they were first set, for a stable publish order
+	dirty map[string]bool   // topics changed since they were last published
+
+	// Connection state, only touched by the goroutine running sync
+	connected bool
+	failing   bool          // the current outage was already logged
+	backoff   time.Duration // delay before the next connection attempt
+	retryAt   time.Time
+	lastSent  time.Time
+
+	wake      chan struct{} // buffered, never blocks
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient creates a client and starts publishing in the background. It doesn't wait for
+// the broker: an unreachable broker is retried until Close.
+func NewClient(cfg Config) (*Client, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go c.run()
+	return c, nil
+}
+
+// newClient creates a client without starting its goroutine, so tests can drive sync
+func newClient(cfg Config) (*Client, error) {
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = DefaultTopicPrefix
+	}
+	cfg.TopicPrefix = strings.TrimSuffix(cfg.TopicPrefix, "/")
+	if strings.ContainsAny(cfg.TopicPrefix, "+#") {
+		return nil, fmt.Errorf("invalid MQTT topic prefix %q: must not contain + or #", cfg.TopicPrefix)
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if cfg.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "linux"
+		}
+		cfg.NodeID = hostname
+	}
+	cfg.NodeID = sanitizeID(cfg.NodeID)
+
+	broker := cfg.Broker
+	if broker == nil {
+		b, err := newTCPBroker(cfg.BrokerURL, cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Username == "" && b.username != "" {
+			cfg.Username, cfg.Password = b.username, b.password
+		}
+		broker = b
+	}
+
+	c := &Client{
+		cfg:    cfg,
+		broker: broker,
+		state:  make(map[string][]byte),
+		dirty:  make(map[string]bool),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	c.options = ConnectOptions{
+		ClientID:    "rescuetime-" + cfg.NodeID,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		KeepAlive:   keepAlive,
+		WillTopic:   c.topic(TopicStatus),
+		WillPayload: []byte(statusOffline),
+	}
+	return c, nil
+}
+
+// topic returns the full topic for a state topic name
+func (c *Client) topic(name string) string {
+	return c.cfg.TopicPrefix + "/" + name
+}
+
+// PublishWindow publishes the focused application and window title. Empty values mean
+// nothing is being tracked (idle, or the display is off).
+func (c *Client) PublishWindow(appClass, title string) {
+	c.set(message{c.topic(TopicCurrentApp), []byte(appClass)}, message{c.topic(TopicCurrentTitle), []byte(title)})
+}
+
+// PublishTotals publishes today's per-application totals, see Totals. The date is date's
+// calendar day in its own location, so pass it in the zone the totals were counted in.
+func (c *Client) PublishTotals(date time.Time, totals map[string]time.Duration) {
+	payload := Totals{Date: date.Format("2006-01-02"), Apps: make(map[string]int64, len(totals))}
+	for app, duration := range totals {
+		seconds := int64(duration.Round(time.Second) / time.Second)
+		payload.Apps[app] = seconds
+		payload.TotalSeconds += seconds
+	}
+	data, err := rescuetime.EncodeJSON(payload)
+	if err != nil {
+		color.Red("[MQTT] ✗ Failed to encode totals: %v\n", err)
+		return
+	}
+	c.set(message{c.topic(TopicTotals), data})
+}
+
+// set records the latest payloads and wakes the publisher. Unchanged values aren't resent.
+func (c *Client) set(messages ...message) {
+	c.mu.Lock()
+	changed := false
+	for _, m := range messages {
+		previous, ok := c.state[m.topic]
+		if ok && string(previous) == string(m.payload) {
+			continue
+		}
+		if !ok {
+			c.order = append(c.order, m.topic)
+		}
+		c.state[m.topic] = m.payload
+		c.dirty[m.topic] = true
+		changed = true
+	}
+	c.mu.Unlock()
+
+	if changed {
+		select {
+		case c.wake <- struct{}{}:
+		default: // a sync is already pending
+		}
+	}
+}
+
+// takeDirty returns the changed messages in order and marks them published
+func (c *Client) takeDirty() []message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var messages []message
+	for _, topic := range c.order {
+		if c.dirty[topic] {
+			messages = append(messages, message{topic, c.state[topic]})
+			delete(c.dirty, topic)
+		}
+	}
+	return messages
+}
+
+// markDirty queues topics to be published again
+func (c *Client) markDirty(messages []message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range messages {
+		c.dirty[m.topic] = true
+	}
+}
+
+// markAllDirty queues every topic, after a (re)connect to a broker that may have lost them
+func (c *Client) markAllDirty() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, topic := range c.order {
+		c.dirty[topic] = true
+	}
+}
+
+// run syncs whenever a value changes, and every syncInterval for reconnects and keepalives
+func (c *Client) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		c.sync(time.Now())
+		select {
+		case <-c.wake:
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// sync connects when due, publishes the changed topics and pings when the connection has
+// been quiet for half the keepalive
+func (c *Client) sync(now time.Time) {
+	if !c.connected {
+		if now.Before(c.retryAt) {
+			return
+		}
+		if err := c.connect(); err != nil {
+			c.scheduleRetry(now)
+			if !c.failing {
+				color.Yellow("[MQTT] Can't reach the broker, retrying in %v: %v\n", c.backoff, err)
+				c.failing = true
+			} else {
+				c.debugLog("Broker still unreachable, retrying in %v: %v", c.backoff, err)
+			}
+			return
+		}
+		if c.failing {
+			color.Green("[MQTT] Reconnected to the broker\n")
+		}
+		c.connected, c.failing, c.backoff = true, false, 0
+		c.lastSent = now
+		c.markAllDirty()
+	}
+
+	messages := c.takeDirty()
+	for i, m := range messages {
+		if err := c.broker.Publish(m.topic, m.payload, true); err != nil {
+			c.markDirty(messages[i:])
+			c.lost(now, err)
+			return
+		}
+		c.debugLog("Published %s: %s", m.topic, m.payload)
+		c.lastSent = now
+	}
+
+	if now.Sub(c.lastSent) >= keepAlive/2 {
+		if err := c.broker.Ping(); err != nil {
+			c.lost(now, err)
+			return
+		}
+		c.lastSent = now
+	}
+}
+
+// connect opens a session and publishes the discovery messages and the online status
+func (c *Client) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := c.broker.Connect(ctx, c.options); err != nil {
+		return err
+	}
+
+	messages, err := c.discoveryMessages()
+	if err != nil {
+		c.broker.Close()
+		return err
+	}
+	messages = append(messages, message{c.topic(TopicStatus), []byte(statusOnline)})
+	for _, m := range messages {
+		if err := c.broker.Publish(m.topic, m.payload, true); err != nil {
+			c.broker.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// lost handles a failed publish or ping: the connection is dropped and retried after the backoff
+func (c *Client) lost(now time.Time, err error) {
+	c.broker.Close()
+	c.connected = false
+	c.failing = true
+	c.scheduleRetry(now)
+	color.Yellow("[MQTT] Lost the broker connection, reconnecting in %v: %v\n", c.backoff, err)
+}
+
+// scheduleRetry doubles the reconnect delay, from minReconnectDelay up to maxReconnectDelay
+func (c *Client) scheduleRetry(now time.Time) {
+	c.backoff = nextReconnectDelay(c.backoff)
+	c.retryAt = now.Add(c.backoff)
+}
+
+// nextReconnectDelay returns the delay after previous (0 for the first attempt)
+func nextReconnectDelay(previous time.Duration) time.Duration {
+	if previous <= 0 {
+		return minReconnectDelay
+	}
+	if next := previous * 2; next < maxReconnectDelay {
+		return next
+	}
+	return maxReconnectDelay
+}
+
+// Close stops the background publisher, sends anything not yet published, marks this
+// machine offline and disconnects. ctx bounds the wait for a publisher stuck on an
+// unreachable broker.
+func (c *Client) Close(ctx context.Context) error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			err = fmt.Errorf("MQTT publisher didn't stop: %v", ctx.Err())
+			return
+		}
+		err = c.shutdown(time.Now())
+	})
+	return err
+}
+
+// shutdown publishes what's left and the offline status if connected, then disconnects
+func (c *Client) shutdown(now time.Time) error {
+	if !c.connected {
+		return nil
+	}
+	c.sync(now)
+	if !c.connected {
+		return fmt.Errorf("lost the MQTT broker before the final publish")
+	}
+	err := c.broker.Publish(c.topic(TopicStatus), []byte(statusOffline), true)
+	c.broker.Close()
+	c.connected = false
+	return err
+}
+
+func (c *Client) debugLog(format string, args ...interface{}) {
+	if c.DebugMode {
+		color.Cyan("[MQTT DEBUG] "+format, args...)
+	}
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Field length limits from the Slack Block Kit and Discord embed documentation
+const (
+	slackHeaderMaxLength      = 150
+	slackSectionMaxLength     = 3000
+	discordTitleMaxLength     = 256
+	discordFieldNameMaxLength = 256
+	discordFooterMaxLength    = 2048
+	discordMaxFields          = 25
+)
+
+// dateLayout is how digests show their day
+const dateLayout = "Monday, January 2"
+
+// Slack Block Kit message (https://api.slack.com/reference/block-kit/blocks)
+type slackMessage struct {
+	Text   string       `json:"text"` // notification fallback
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type  string `json:"type"` // "plain_text" or "mrkdwn"
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// SlackMessage returns the digest as a Slack incoming-webhook message: a header, one line
+// per entry and the total
+func SlackMessage(digest Digest) ([]byte, error) {
+	lines := make([]string, len(digest.Entries))
+	for i, entry := range digest.Entries {
+		lines[i] = fmt.Sprintf("*%s*  %s", slackEscape(entry.Name), FormatDuration(entry.Duration))
+	}
+	body := strings.Join(lines, "\n")
+	if body == "" {
+		body = "_Nothing tracked._"
+	}
+
+	message := slackMessage{
+		Text: slackEscape(digest.Text()),
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(digest.Title, slackHeaderMaxLength), Emoji: true}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncate(body, slackSectionMaxLength)}},
+			{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: footer(digest)}}},
+		},
+	}
+	return encodeMessage(message)
+}
+
+// slackEscape escapes the characters Slack's mrkdwn reserves for links and mentions
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Discord webhook message with one embed (https://discord.com/developers/docs/resources/message#embed-object)
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// DiscordMessage returns the digest as a Discord webhook message: an embed with an inline
+// field per entry and the total in the footer
+func DiscordMessage(digest Digest) ([]byte, error) {
+	embed := discordEmbed{
+		Title:  truncate(digest.Title, discordTitleMaxLength),
+		Color:  discordEmbedColor,
+		Footer: &discordFooter{Text: truncate(footer(digest), discordFooterMaxLength)},
+	}
+	if !digest.Date.IsZero() {
+		embed.Timestamp = digest.Date.Format(time.RFC3339)
+	}
+	if len(digest.Entries) == 0 {
+		embed.Description = "Nothing tracked."
+	}
+	for _, entry := range digest.Entries {
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   truncate(entry.Name, discordFieldNameMaxLength),
+			Value:  FormatDuration(entry.Duration),
+			Inline: true,
+		})
+	}
+	return encodeMessage(discordMessage{Embeds: []discordEmbed{embed}})
+}
+
+// Message for a matrix-hookshot generic webhook, which posts text as the body and html as the
+// formatted body (https://matrix-org.github.io/matrix-hookshot/latest/setup/webhooks.html)
+type matrixMessage struct {
+	Text string `json:"text"`
+	HTML string `json:"html"`
+}
+
+// MatrixMessage returns the digest as a hookshot webhook message: the title, one line per
+// entry and the total, as plain text and as HTML
+func MatrixMessage(digest Digest) ([]byte, error) {
+	text := []string{digest.Title}
+	var items strings.Builder
+	for _, entry := range digest.Entries {
+		text = append(text, FormatDuration(entry.Duration)+" "+entry.Name)
+		fmt.Fprintf(&items, "<li><b>%s</b> %s</li>", html.EscapeString(entry.Name), FormatDuration(entry.Duration))
+	}
+	list := "<ul>" + items.String() + "</ul>"
+	if len(digest.Entries) == 0 {
+		text = append(text, "Nothing tracked.")
+		list = "<p><em>Nothing tracked.</em></p>"
+	}
+	text = append(text, footer(digest))
+
+	return encodeMessage(matrixMessage{
+		Text: strings.Join(text, "\n"),
+		HTML: "<h4>" + html.EscapeString(digest.Title) + "</h4>" + list + "<p>" + html.EscapeString(footer(digest)) + "</p>",
+	})
+}
+
+// footer returns the total and the digest's day
+func footer(digest Digest) string {
+	text := "Total " + FormatDuration(digest.Total)
+	if !digest.Date.IsZero() {
+		text += " · " + digest.Date.Format(dateLayout)
+	}
+	return text
+}
+
+// truncate shortens s to at most max characters, ending with "…" when cut
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,219 @@
+// Package notify posts a human-readable activity digest ("Today: 4h12m code, 1h30m
+// firefox, 47m slack") to a Slack or Discord incoming webhook, or to a Matrix room through a
+// matrix-hookshot generic webhook. It is separate from the
+// webhook package, which sends the raw data to endpoints of the user's own.
+//
+// Example usage:
+//
+//	client, err := notify.NewClient("https://hooks.slack.com/services/...", "")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	digest := notify.NewDigest("Today", time.Now(), map[string]time.Duration{
+//		"code":    4*time.Hour + 12*time.Minute,
+//		"firefox": 90 * time.Minute,
+//	}, notify.DefaultTopEntries)
+//
+//	err = client.Send(context.Background(), digest)
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// Message formats for the destinations' incoming webhooks
+const (
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+	FormatMatrix  = "matrix"
+)
+
+// Configuration constants
+const (
+	// DefaultTopEntries is how many entries a digest lists before folding the rest into "other"
+	DefaultTopEntries = 8
+
+	// MaxTopEntries keeps a digest with its "other" entry within Discord's 25 embed fields
+	MaxTopEntries = discordMaxFields - 1
+
+	defaultRequestTimeout = 30 * time.Second
+	otherEntryName        = "other"
+	discordEmbedColor     = 0x4A90D9
+)
+
+// Entry is one line of a digest: an application (or category) and its time
+type Entry struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Digest is the tracked time to report, longest first
+type Digest struct {
+	Title   string    // e.g. "Today" or "Activity for Monday"
+	Date    time.Time // the day the totals belong to
+	Entries []Entry
+	Total   time.Duration
+}
+
+// NewDigest builds a digest from per-key totals (e.g. the tracker's daily totals), keeping
+// the top entries and folding the rest into one "other" entry. Zero totals are dropped.
+// top is at most MaxTopEntries; 0 or less means DefaultTopEntries.
+func NewDigest(title string, date time.Time, totals map[string]time.Duration, top int) Digest {
+	if top <= 0 {
+		top = DefaultTopEntries
+	}
+	if top > MaxTopEntries {
+		top = MaxTopEntries
+	}
+	digest := Digest{Title: title, Date: date}
+	for name, duration := range totals {
+		if duration <= 0 {
+			continue
+		}
+		digest.Entries = append(digest.Entries, Entry{Name: name, Duration: duration})
+		digest.Total += duration
+	}
+	sort.Slice(digest.Entries, func(i, j int) bool {
+		if digest.Entries[i].Duration != digest.Entries[j].Duration {
+			return digest.Entries[i].Duration > digest.Entries[j].Duration
+		}
+		return digest.Entries[i].Name < digest.Entries[j].Name
+	})
+
+	if len(digest.Entries) > top {
+		other := Entry{Name: otherEntryName}
+		for _, entry := range digest.Entries[top:] {
+			other.Duration += entry.Duration
+		}
+		digest.Entries = append(digest.Entries[:top:top], other)
+	}
+	return digest
+}
+
+// Text returns the digest on one line, e.g. "Today: 4h12m code, 1h30m firefox, 47m slack"
+func (d Digest) Text() string {
+	if len(d.Entries) == 0 {
+		return d.Title + ": nothing tracked"
+	}
+	parts := make([]string, len(d.Entries))
+	for i, entry := range d.Entries {
+		parts[i] = FormatDuration(entry.Duration) + " " + entry.Name
+	}
+	return d.Title + ": " + strings.Join(parts, ", ")
+}
+
+// FormatDuration formats d as compact hours and minutes ("4h12m", "47m"), or "<1m"
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// Client posts digests to one Slack, Discord or Matrix (hookshot) webhook
+type Client struct {
+	url        string
+	format     string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for an incoming webhook URL. An empty format is detected from
+// the URL (hooks.slack.com or discord.com/api/webhooks); hookshot URLs are the bridge's own,
+// so Matrix needs FormatMatrix.
+func NewClient(url, format string) (*Client, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("invalid notification URL: must start with http:// or https://\n\nProvided: %s", url)
+	}
+	if format == "" {
+		format = DetectFormat(url)
+	}
+	if format != FormatSlack && format != FormatDiscord && format != FormatMatrix {
+		return nil, fmt.Errorf("unknown notification format %q (expected %s, %s or %s)", format, FormatSlack, FormatDiscord, FormatMatrix)
+	}
+	return &Client{
+		url:        url,
+		format:     format,
+		httpClient: &http.Client{Timeout: defaultRequestTimeout},
+	}, nil
+}
+
+// DetectFormat returns the format for a Slack or Discord incoming webhook URL, or "" if
+// the URL is neither
+func DetectFormat(url string) string {
+	switch {
+	case strings.Contains(url, "hooks.slack.com/"):
+		return FormatSlack
+	case strings.Contains(url, "discord.com/api/webhooks/"), strings.Contains(url, "discordapp.com/api/webhooks/"):
+		return FormatDiscord
+	}
+	return ""
+}
+
+// Format returns the client's message format
+func (c *Client) Format() string {
+	return c.format
+}
+
+// Message returns the JSON body Send would post for digest
+func (c *Client) Message(digest Digest) ([]byte, error) {
+	switch c.format {
+	case FormatDiscord:
+		return DiscordMessage(digest)
+	case FormatMatrix:
+		return MatrixMessage(digest)
+	}
+	return SlackMessage(digest)
+}
+
+// Send posts the digest once, without retries: a missed digest isn't worth delaying or
+// repeating, and the tracked data is submitted separately
+func (c *Client) Send(ctx context.Context, digest Digest) error {
+	body, err := c.Message(digest)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s notification failed: %v", c.format, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s notification returned error %d: %s", c.format, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// encodeMessage encodes a message without HTML escaping, like the other payloads
+func encodeMessage(message interface{}) ([]byte, error) {
+	data, err := rescuetime.EncodeJSONIndent(message, "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification: %v", err)
+	}
+	return data, nil
+}
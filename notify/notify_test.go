@@ -0,0 +1,255 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files: go test ./notify -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixtureDigest is a day with more applications than the digest lists
+func fixtureDigest() Digest {
+	return NewDigest("Today on laptop", time.Date(2025, 10, 27, 18, 0, 0, 0, time.UTC), map[string]time.Duration{
+		"code":           4*time.Hour + 12*time.Minute,
+		"firefox":        90 * time.Minute,
+		"slack":          47 * time.Minute,
+		"<R&D> tools":    20 * time.Minute,
+		"gnome-terminal": 12 * time.Minute,
+		"spotify":        0,
+	}, 4)
+}
+
+// checkGolden compares got with testdata/name, or rewrites it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, append(got, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(want), got) {
+		t.Errorf("%s doesn't match the golden file:\n%s", name, got)
+	}
+}
+
+// TestNewDigest tests sorting, folding into "other" and the one-line text
+func TestNewDigest(t *testing.T) {
+	digest := fixtureDigest()
+	if len(digest.Entries) != 5 || digest.Entries[4].Name != "other" || digest.Entries[4].Duration != 12*time.Minute {
+		t.Fatalf("Expected 4 entries plus other, got %+v", digest.Entries)
+	}
+	if digest.Total != 7*time.Hour+1*time.Minute {
+		t.Errorf("Expected 7h01m total, got %v", digest.Total)
+	}
+	want := "Today on laptop: 4h12m code, 1h30m firefox, 47m slack, 20m <R&D> tools, 12m other"
+	if got := digest.Text(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := NewDigest("Today", time.Time{}, nil, 0).Text(); got != "Today: nothing tracked" {
+		t.Errorf("Unexpected empty digest text %q", got)
+	}
+	if got := NewDigest("Today", time.Time{}, map[string]time.Duration{"a": time.Hour}, 100); len(got.Entries) != 1 {
+		t.Errorf("Expected one entry, got %+v", got.Entries)
+	}
+}
+
+// TestFormatDuration tests the compact durations used in digests
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		20 * time.Second:                           "<1m",
+		47 * time.Minute:                           "47m",
+		2 * time.Hour:                              "2h",
+		4*time.Hour + 12*time.Minute:               "4h12m",
+		time.Hour + 5*time.Minute + 40*time.Second: "1h06m",
+	} {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("%v: expected %q, got %q", d, want, got)
+		}
+	}
+}
+
+// TestSlackMessage checks the Slack message against the Block Kit rules for incoming
+// webhooks (at most 50 blocks, header plain_text up to 150 characters, section text up to
+// 3000, context elements of type mrkdwn or image) and the golden file
+func TestSlackMessage(t *testing.T) {
+	body, err := SlackMessage(fixtureDigest())
+	if err != nil {
+		t.Fatalf("SlackMessage failed: %v", err)
+	}
+
+	var message struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type     string      `json:"type"`
+			Text     *slackText  `json:"text"`
+			Elements []slackText `json:"elements"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if message.Text == "" || len(message.Blocks) == 0 || len(message.Blocks) > 50 {
+		t.Fatalf("Expected fallback text and 1-50 blocks, got %s", body)
+	}
+	for i, block := range message.Blocks {
+		switch block.Type {
+		case "header":
+			if block.Text == nil || block.Text.Type != "plain_text" || len([]rune(block.Text.Text)) > slackHeaderMaxLength {
+				t.Errorf("Block %d: header needs plain_text up to 150 characters, got %+v", i, block.Text)
+			}
+		case "section":
+			if block.Text == nil || (block.Text.Type != "mrkdwn" && block.Text.Type != "plain_text") || block.Text.Text == "" || len([]rune(block.Text.Text)) > slackSectionMaxLength {
+				t.Errorf("Block %d: section needs text up to 3000 characters, got %+v", i, block.Text)
+			}
+		case "context":
+			if len(block.Elements) == 0 || len(block.Elements) > 10 {
+				t.Errorf("Block %d: context needs 1-10 elements, got %d", i, len(block.Elements))
+			}
+			for _, element := range block.Elements {
+				if element.Type != "mrkdwn" && element.Type != "plain_text" {
+					t.Errorf("Block %d: unexpected context element type %q", i, element.Type)
+				}
+			}
+		default:
+			t.Errorf("Block %d: unexpected type %q", i, block.Type)
+		}
+	}
+	if !strings.Contains(string(body), "&lt;R&amp;D&gt; tools") {
+		t.Errorf("Expected mrkdwn control characters escaped, got %s", body)
+	}
+
+	checkGolden(t, "slack.golden.json", body)
+}
+
+// TestDiscordMessage checks the Discord message against the embed limits (at most 10
+// embeds, title up to 256 characters, at most 25 fields with names up to 256 and values up
+// to 1024, footer up to 2048, ISO 8601 timestamp) and the golden file
+func TestDiscordMessage(t *testing.T) {
+	body, err := DiscordMessage(fixtureDigest())
+	if err != nil {
+		t.Fatalf("DiscordMessage failed: %v", err)
+	}
+
+	var message struct {
+		Content string         `json:"content"`
+		Embeds  []discordEmbed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(message.Embeds) == 0 || len(message.Embeds) > 10 {
+		t.Fatalf("Expected 1-10 embeds, got %s", body)
+	}
+	for _, embed := range message.Embeds {
+		if embed.Title == "" || len([]rune(embed.Title)) > 256 {
+			t.Errorf("Expected a title up to 256 characters, got %q", embed.Title)
+		}
+		if len(embed.Fields) > 25 {
+			t.Errorf("Expected at most 25 fields, got %d", len(embed.Fields))
+		}
+		for _, field := range embed.Fields {
+			if field.Name == "" || len([]rune(field.Name)) > 256 || field.Value == "" || len([]rune(field.Value)) > 1024 {
+				t.Errorf("Field out of limits: %+v", field)
+			}
+		}
+		if embed.Footer == nil || len([]rune(embed.Footer.Text)) > 2048 {
+			t.Errorf("Expected a footer up to 2048 characters, got %+v", embed.Footer)
+		}
+		if _, err := time.Parse(time.RFC3339, embed.Timestamp); err != nil {
+			t.Errorf("Expected an ISO 8601 timestamp: %v", err)
+		}
+		if embed.Color < 0 || embed.Color > 0xFFFFFF {
+			t.Errorf("Color out of range: %d", embed.Color)
+		}
+	}
+
+	checkGolden(t, "discord.golden.json", body)
+}
+
+// TestMatrixMessage tests the hookshot message: plain text and HTML with the same lines,
+// names escaped in the HTML, and the golden file
+func TestMatrixMessage(t *testing.T) {
+	body, err := MatrixMessage(fixtureDigest())
+	if err != nil {
+		t.Fatalf("MatrixMessage failed: %v", err)
+	}
+
+	var message matrixMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if lines := strings.Split(message.Text, "\n"); len(lines) != 7 || lines[0] != "Today on laptop" || lines[1] != "4h12m code" || !strings.HasPrefix(lines[6], "Total 7h01m") {
+		t.Errorf("Expected the title, 5 entries and the total, got %q", message.Text)
+	}
+	if strings.Count(message.HTML, "<li>") != 5 || !strings.Contains(message.HTML, "<b>&lt;R&amp;D&gt; tools</b>") {
+		t.Errorf("Expected 5 list items with escaped names, got %s", message.HTML)
+	}
+
+	empty, _ := MatrixMessage(NewDigest("Today", time.Time{}, nil, 0))
+	if !strings.Contains(string(empty), "Nothing tracked.") || strings.Contains(string(empty), "<ul>") {
+		t.Errorf("Expected an empty digest without a list, got %s", empty)
+	}
+
+	checkGolden(t, "matrix.golden.json", body)
+}
+
+// TestClientSend tests format detection and that failures are reported, not retried
+func TestClientSend(t *testing.T) {
+	if got := DetectFormat("https://hooks.slack.com/services/T0/B0/x"); got != FormatSlack {
+		t.Errorf("Expected slack, got %q", got)
+	}
+	if got := DetectFormat("https://discord.com/api/webhooks/1/x"); got != FormatDiscord {
+		t.Errorf("Expected discord, got %q", got)
+	}
+	if _, err := NewClient("https://example.com/hook", ""); err == nil {
+		t.Error("Expected an error for an undetectable format")
+	}
+	if client, err := NewClient("https://hookshot.example.com/webhook/abc", FormatMatrix); err != nil || client.Format() != FormatMatrix {
+		t.Errorf("Expected a Matrix client, got %v", err)
+	}
+
+	requests := 0
+	status := http.StatusOK
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, FormatDiscord)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := client.Send(context.Background(), fixtureDigest()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if !json.Valid(body) || !strings.Contains(string(body), `"embeds"`) {
+		t.Errorf("Expected a Discord message, got %s", body)
+	}
+
+	status = http.StatusBadRequest
+	if err := client.Send(context.Background(), fixtureDigest()); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected a 400 error, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected one request per send, got %d", requests)
+	}
+}
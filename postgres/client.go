@@ -31,13 +31,15 @@ import (
 
 	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
 	"github.com/fatih/color"
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // Configuration constants
 const (
 	defaultConnectTimeout = 10 * time.Second
 	defaultQueryTimeout   = 5 * time.Second
+	defaultImportTimeout  = 5 * time.Minute // bulk imports insert many rows in one transaction
+	defaultSessionSource  = "tracker"       // source of sessions recorded live by this tracker
 	maxRetries            = 3
 	baseRetryDelay        = 1 * time.Second
 )
@@ -55,7 +57,45 @@ type ActivitySession struct {
 	WindowTitle string        `json:"window_title"`
 	Duration    time.Duration `json:"duration"`
 	Ignored     bool          `json:"ignored"` // true if app is in ignore list (excluded from RescueTime)
+	Source      string        `json:"source,omitempty"` // where the session came from: "tracker" (default) or an import format
+	PushedAt    time.Time     `json:"pushed_at,omitempty"` // when the session was pushed to RescueTime by -push-history (zero if never)
 	CreatedAt   time.Time     `json:"created_at,omitempty"`
+
+	// OutsideActiveHours is true if the session started outside the tracker's -active-hours
+	// schedule (excluded from RescueTime and -push-history)
+	OutsideActiveHours bool `json:"outside_active_hours,omitempty"`
+
+	// ActiveSeconds and IdleSeconds split the duration into time with input and time the
+	// window sat focused without input (e.g. reading). The columns are NULL for imported sessions.
+	ActiveSeconds int `json:"active_seconds"`
+	IdleSeconds   int `json:"idle_seconds"`
+
+	// Coverage is the share of the expected polls that saw the session, 0 to 1, for judging
+	// how far to trust its duration. 0 (stored as NULL) means unknown, e.g. imported sessions.
+	Coverage float64 `json:"coverage,omitempty"`
+
+	// MaximizedSeconds is the part of the duration the window was maximized or fullscreen,
+	// with the tracker's -track-geometry. 0 is stored as NULL.
+	MaximizedSeconds int `json:"maximized_seconds,omitempty"`
+
+	// Secondary is true for time a -background-apps application (e.g. a meeting) had a window
+	// open behind the focused one. It overlaps the focused sessions and is never pushed to
+	// RescueTime by -push-history.
+	Secondary bool `json:"secondary,omitempty"`
+}
+
+// source returns the session's source, defaulting to live tracker data
+func (s ActivitySession) source() string {
+	if s.Source == "" {
+		return defaultSessionSource
+	}
+	return s.Source
+}
+
+// Tracked reports whether the session was recorded live by the tracker rather than imported.
+// The tracker submits live sessions to RescueTime itself, so -push-history leaves them out.
+func (s ActivitySession) Tracked() bool {
+	return s.source() == defaultSessionSource
 }
 
 // StoredSummary represents a summary retrieved from the database with metadata
@@ -68,6 +108,11 @@ type StoredSummary struct {
 	FirstSeen       time.Time     `json:"first_seen"`
 	LastSeen        time.Time     `json:"last_seen"`
 	SubmittedAt     time.Time     `json:"submitted_at"`
+
+	// Session length statistics (zero for summaries stored before they were recorded)
+	MaxSessionDuration  time.Duration `json:"max_session_duration"`
+	AvgSessionDuration  time.Duration `json:"avg_session_duration"`
+	LongestSessionStart time.Time     `json:"longest_session_start,omitempty"`
 }
 
 // Client provides methods for storing activity data in PostgreSQL.
@@ -84,6 +129,23 @@ type Client struct {
 // If connectionStr is empty, it will attempt to read from POSTGRES_CONNECTION_STRING
 // environment variable.
 func NewClient(connectionStr string) (*Client, error) {
+	client, err := NewClientWithoutMigrations(connectionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize database schema
+	if err := client.initializeSchema(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to initialize database schema: %v", err)
+	}
+
+	return client, nil
+}
+
+// NewClientWithoutMigrations connects like NewClient but leaves the schema untouched.
+// Use it to inspect a database before changing it, e.g. with PlanMigrations.
+func NewClientWithoutMigrations(connectionStr string) (*Client, error) {
 	// Use provided connection string, or fall back to environment variable
 	if connectionStr == "" {
 		connectionStr = os.Getenv("POSTGRES_CONNECTION_STRING")
@@ -108,19 +170,11 @@ func NewClient(connectionStr string) (*Client, error) {
 		return nil, fmt.Errorf("failed to connect to database: %v\n\nTroubleshooting:\n  1. Verify PostgreSQL is running\n  2. Check credentials in connection string\n  3. Verify database exists\n  4. Check firewall/network settings", err)
 	}
 
-	client := &Client{
+	return &Client{
 		db:            db,
 		connectionStr: connectionStr,
 		DebugMode:     false,
-	}
-
-	// Initialize database schema
-	if err := client.initializeSchema(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to initialize database schema: %v", err)
-	}
-
-	return client, nil
+	}, nil
 }
 
 // Close closes the database connection.
@@ -131,6 +185,17 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// Ping verifies the database connection is still alive.
+func (c *Client) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultConnectTimeout)
+	defer cancel()
+
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %v", err)
+	}
+	return nil
+}
+
 // debugLog prints debug messages if debug mode is enabled
 func (c *Client) debugLog(format string, args ...interface{}) {
 	if c.DebugMode {
@@ -138,140 +203,107 @@ func (c *Client) debugLog(format string, args ...interface{}) {
 	}
 }
 
-// initializeSchema creates the necessary tables and indexes if they don't exist.
-func (c *Client) initializeSchema() error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
-	defer cancel()
-
-	// Create activity_sessions table
-	sessionsTableSQL := `
-	CREATE TABLE IF NOT EXISTS activity_sessions (
-		id SERIAL PRIMARY KEY,
-		start_time TIMESTAMP WITH TIME ZONE NOT NULL,
-		end_time TIMESTAMP WITH TIME ZONE NOT NULL,
-		app_class VARCHAR(255) NOT NULL,
-		window_title TEXT,
-		duration_seconds INTEGER NOT NULL,
-		ignored BOOLEAN DEFAULT FALSE,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		CONSTRAINT valid_duration CHECK (duration_seconds >= 0),
-		CONSTRAINT valid_time_range CHECK (end_time >= start_time)
-	);
-	`
-
-	if _, err := c.db.ExecContext(ctx, sessionsTableSQL); err != nil {
-		return fmt.Errorf("failed to create activity_sessions table: %v", err)
-	}
-
-	// Create indexes on activity_sessions for common queries
-	sessionIndexesSQL := []string{
-		`CREATE INDEX IF NOT EXISTS idx_sessions_app_class ON activity_sessions(app_class);`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_start_time ON activity_sessions(start_time);`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_end_time ON activity_sessions(end_time);`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_app_time ON activity_sessions(app_class, start_time);`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_ignored ON activity_sessions(ignored);`,
-	}
-
-	for _, indexSQL := range sessionIndexesSQL {
-		if _, err := c.db.ExecContext(ctx, indexSQL); err != nil {
-			return fmt.Errorf("failed to create index: %v", err)
-		}
-	}
-
-	// Create activity_summaries table
-	summariesTableSQL := `
-	CREATE TABLE IF NOT EXISTS activity_summaries (
-		id SERIAL PRIMARY KEY,
-		app_class VARCHAR(255) NOT NULL,
-		activity_details TEXT,
-		total_duration_seconds INTEGER NOT NULL,
-		session_count INTEGER NOT NULL,
-		first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
-		last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
-		submitted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		CONSTRAINT valid_summary_duration CHECK (total_duration_seconds >= 0),
-		CONSTRAINT valid_session_count CHECK (session_count > 0),
-		CONSTRAINT valid_summary_time_range CHECK (last_seen >= first_seen)
-	);
+// insertSessionSQL inserts a session unless one with the same natural key (app_class,
+// start_time, end_time) is already stored, returning the new row's ID
+const insertSessionSQL = `
+		INSERT INTO activity_sessions (start_time, end_time, app_class, window_title, duration_seconds, ignored, source, outside_active_hours, active_seconds, idle_seconds, coverage, maximized_seconds, secondary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (app_class, start_time, end_time) DO NOTHING
+		RETURNING id
 	`
 
-	if _, err := c.db.ExecContext(ctx, summariesTableSQL); err != nil {
-		return fmt.Errorf("failed to create activity_summaries table: %v", err)
-	}
-
-	// Create indexes on activity_summaries for common queries
-	summaryIndexesSQL := []string{
-		`CREATE INDEX IF NOT EXISTS idx_summaries_app_class ON activity_summaries(app_class);`,
-		`CREATE INDEX IF NOT EXISTS idx_summaries_first_seen ON activity_summaries(first_seen);`,
-		`CREATE INDEX IF NOT EXISTS idx_summaries_last_seen ON activity_summaries(last_seen);`,
-		`CREATE INDEX IF NOT EXISTS idx_summaries_submitted_at ON activity_summaries(submitted_at);`,
-	}
-
-	for _, indexSQL := range summaryIndexesSQL {
-		if _, err := c.db.ExecContext(ctx, indexSQL); err != nil {
-			return fmt.Errorf("failed to create index: %v", err)
-		}
-	}
-
-	c.debugLog("Database schema initialized successfully")
-	return nil
+// SubmitSession stores a single activity session in the database. It reports whether the
+// session was new: storing the same session again (a retry, a WAL replay) is a no-op.
+func (c *Client) SubmitSession(session ActivitySession) (bool, error) {
+	return c.SubmitSessionContext(context.Background(), session)
 }
 
-// SubmitSession stores a single activity session in the database.
-func (c *Client) SubmitSession(session ActivitySession) error {
+// SubmitSessionContext is SubmitSession within ctx: cancelling it (e.g. at shutdown) aborts
+// the insert. The query timeout still applies.
+func (c *Client) SubmitSessionContext(ctx context.Context, session ActivitySession) (bool, error) {
 	if err := c.validateSession(session); err != nil {
-		return fmt.Errorf("invalid session: %v", err)
+		return false, fmt.Errorf("invalid session: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 
-	insertSQL := `
-		INSERT INTO activity_sessions (start_time, end_time, app_class, window_title, duration_seconds, ignored)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
-	`
+	var coverage sql.NullFloat64
+	if session.Coverage > 0 {
+		coverage = sql.NullFloat64{Float64: session.Coverage, Valid: true}
+	}
+	var maximized sql.NullInt64
+	if session.MaximizedSeconds > 0 {
+		maximized = sql.NullInt64{Int64: int64(session.MaximizedSeconds), Valid: true}
+	}
 
 	var id int64
-	err := c.db.QueryRowContext(ctx, insertSQL,
+	err := c.db.QueryRowContext(ctx, insertSessionSQL,
 		session.StartTime,
 		session.EndTime,
 		session.AppClass,
 		session.WindowTitle,
 		int(session.Duration.Seconds()),
 		session.Ignored,
+		session.source(),
+		session.OutsideActiveHours,
+		session.ActiveSeconds,
+		session.IdleSeconds,
+		coverage,
+		maximized,
+		session.Secondary,
 	).Scan(&id)
 
+	if err == sql.ErrNoRows {
+		c.debugLog("Session already stored: %s at %s", session.AppClass, session.StartTime.Format(time.RFC3339))
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to insert session: %v", err)
+		return false, fmt.Errorf("failed to insert session: %v", err)
 	}
 
 	ignoredLabel := ""
 	if session.Ignored {
 		ignoredLabel = " (ignored)"
 	}
+	if session.Secondary {
+		ignoredLabel += " (secondary)"
+	}
 	c.debugLog("Inserted session ID %d: %s (%v)%s", id, session.AppClass, session.Duration, ignoredLabel)
-	return nil
+	return true, nil
 }
 
 // SubmitSummary stores an activity summary in the database.
 func (c *Client) SubmitSummary(summary ActivitySummary) error {
+	return c.SubmitSummaryContext(context.Background(), summary)
+}
+
+// SubmitSummaryContext is SubmitSummary within ctx: cancelling it (e.g. at shutdown) aborts
+// the insert. The query timeout still applies.
+func (c *Client) SubmitSummaryContext(ctx context.Context, summary ActivitySummary) error {
 	if err := c.validateSummary(summary); err != nil {
 		return fmt.Errorf("invalid summary: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 
 	insertSQL := `
 		INSERT INTO activity_summaries (
 			app_class, activity_details, total_duration_seconds, 
-			session_count, first_seen, last_seen
+			session_count, first_seen, last_seen,
+			max_session_seconds, avg_session_seconds, longest_session_start
 		)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id
 	`
 
+	// Summaries built without per-session data have no longest session
+	var longestStart sql.NullTime
+	if !summary.LongestSessionStart.IsZero() {
+		longestStart = sql.NullTime{Time: summary.LongestSessionStart, Valid: true}
+	}
+
 	var id int64
 	err := c.db.QueryRowContext(ctx, insertSQL,
 		summary.AppClass,
@@ -280,6 +312,9 @@ func (c *Client) SubmitSummary(summary ActivitySummary) error {
 		summary.SessionCount,
 		summary.FirstSeen,
 		summary.LastSeen,
+		int(summary.MaxSessionDuration.Seconds()),
+		int(summary.AvgSessionDuration.Seconds()),
+		longestStart,
 	).Scan(&id)
 
 	if err != nil {
@@ -299,9 +334,17 @@ func (c *Client) SubmitSummary(summary ActivitySummary) error {
 // This stores the same aggregated data that gets sent to RescueTime's API,
 // allowing users to build their own applications with the same data.
 func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
+	c.SubmitActivitiesContext(context.Background(), summaries)
+}
+
+// SubmitActivitiesContext is SubmitActivities within ctx; once it's cancelled, the insert in
+// progress aborts and the rest aren't attempted. It returns the summaries that weren't stored
+// and may be retried: failed inserts and those not attempted. Invalid summaries are reported
+// but not returned, as retrying can't fix them.
+func (c *Client) SubmitActivitiesContext(ctx context.Context, summaries map[string]ActivitySummary) map[string]ActivitySummary {
 	if len(summaries) == 0 {
 		// No activities to submit - silence is fine, no need to spam logs
-		return
+		return nil
 	}
 
 	color.New(color.FgCyan, color.Bold).Printf("\n=== Storing %d activities in PostgreSQL ===\n", len(summaries))
@@ -309,11 +352,25 @@ func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
 	successCount := 0
 	failCount := 0
 
-	for _, summary := range summaries {
-		err := c.SubmitSummary(summary)
+	abortedCount := 0
+	unsent := make(map[string]ActivitySummary)
+
+	for key, summary := range summaries {
+		if ctx.Err() != nil {
+			abortedCount++
+			unsent[key] = summary
+			continue
+		}
+		if err := c.validateSummary(summary); err != nil {
+			color.Red("[POSTGRES] ✗ Failed to store %s: invalid summary: %v\n", summary.AppClass, err)
+			failCount++
+			continue
+		}
+		err := c.SubmitSummaryContext(ctx, summary)
 		if err != nil {
 			color.Red("[POSTGRES] ✗ Failed to store %s: %v\n", summary.AppClass, err)
 			failCount++
+			unsent[key] = summary
 		} else {
 			successCount++
 		}
@@ -326,29 +383,59 @@ func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
 	if failCount > 0 {
 		color.Red("Failed: %d\n", failCount)
 	}
+	if abortedCount > 0 {
+		color.Yellow("Not attempted (cancelled): %d\n", abortedCount)
+	}
+	return unsent
 }
 
 // SubmitSessions stores multiple activity sessions in the database.
 // This stores individual session data (start/end times, window titles) which
 // provides more granular tracking data than the aggregated summaries.
 func (c *Client) SubmitSessions(sessions []ActivitySession) {
+	c.SubmitSessionsContext(context.Background(), sessions)
+}
+
+// SubmitSessionsContext is SubmitSessions within ctx; once it's cancelled, the insert in
+// progress aborts and the rest aren't attempted. It returns the sessions that weren't stored
+// and may be retried: failed inserts and those not attempted. Invalid sessions are reported
+// but not returned, as retrying can't fix them. Storing a session is idempotent, so retrying
+// all of them is safe too.
+func (c *Client) SubmitSessionsContext(ctx context.Context, sessions []ActivitySession) []ActivitySession {
 	if len(sessions) == 0 {
 		// No sessions to submit - silence is fine, no need to spam logs
-		return
+		return nil
 	}
 
 	color.New(color.FgCyan, color.Bold).Printf("\n=== Storing %d sessions in PostgreSQL ===\n", len(sessions))
 
 	successCount := 0
+	duplicateCount := 0
 	failCount := 0
 
+	abortedCount := 0
+	var unsent []ActivitySession
+
 	for _, session := range sessions {
-		err := c.SubmitSession(session)
+		if ctx.Err() != nil {
+			abortedCount++
+			unsent = append(unsent, session)
+			continue
+		}
+		if err := c.validateSession(session); err != nil {
+			color.Red("[POSTGRES] ✗ Failed to store session %s: invalid session: %v\n", session.AppClass, err)
+			failCount++
+			continue
+		}
+		inserted, err := c.SubmitSessionContext(ctx, session)
 		if err != nil {
 			color.Red("[POSTGRES] ✗ Failed to store session %s: %v\n", session.AppClass, err)
 			failCount++
-		} else {
+			unsent = append(unsent, session)
+		} else if inserted {
 			successCount++
+		} else {
+			duplicateCount++
 		}
 	}
 
@@ -356,12 +443,110 @@ func (c *Client) SubmitSessions(sessions []ActivitySession) {
 	if successCount > 0 {
 		color.Green("Stored: %d sessions\n", successCount)
 	}
+	if duplicateCount > 0 {
+		color.Yellow("Already stored: %d\n", duplicateCount)
+	}
 	if failCount > 0 {
 		color.Red("Failed: %d\n", failCount)
 	}
+	if abortedCount > 0 {
+		color.Yellow("Not attempted (cancelled): %d\n", abortedCount)
+	}
+	return unsent
+}
+
+// ImportSkip describes a session that was not imported and why.
+type ImportSkip struct {
+	Index int   // position of the session in the slice passed to ImportSessions
+	Err   error // validation error
+}
+
+// ImportResult reports the outcome of a bulk session import.
+type ImportResult struct {
+	Inserted   int
+	Duplicates int // sessions not inserted because an overlapping session for the same app already exists
+	Skipped    []ImportSkip
 }
 
-// validateSession checks if a session is valid before insertion.
+// ImportSessions bulk-inserts historical sessions in a single transaction.
+// Invalid sessions are skipped and reported in the result rather than aborting the import;
+// a database error rolls back the whole import. Sessions overlapping an existing session
+// of the same app (from any source) are counted as duplicates and not inserted, so
+// re-running an import or importing data that overlaps live tracking is safe. Zero-length
+// sessions don't overlap anything, so the natural key catches their repeats.
+func (c *Client) ImportSessions(sessions []ActivitySession) (ImportResult, error) {
+	var result ImportResult
+
+	valid := make([]ActivitySession, 0, len(sessions))
+	for i, session := range sessions {
+		if err := c.validateSession(session); err != nil {
+			result.Skipped = append(result.Skipped, ImportSkip{Index: i, Err: err})
+			continue
+		}
+		valid = append(valid, session)
+	}
+
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultImportTimeout)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin import transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO activity_sessions (start_time, end_time, app_class, window_title, duration_seconds, ignored, source)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (
+			SELECT 1 FROM activity_sessions
+			WHERE app_class = $3 AND start_time < $2 AND end_time > $1
+		)
+		ON CONFLICT (app_class, start_time, end_time) DO NOTHING
+	`)
+	if err != nil {
+		return result, fmt.Errorf("failed to prepare import statement: %v", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	duplicates := 0
+	for _, session := range valid {
+		res, err := stmt.ExecContext(ctx,
+			session.StartTime,
+			session.EndTime,
+			session.AppClass,
+			session.WindowTitle,
+			int(session.Duration.Seconds()),
+			session.Ignored,
+			session.source(),
+		)
+		if err != nil {
+			return result, fmt.Errorf("failed to insert session %s at %s: %v", session.AppClass, session.StartTime.Format(time.RFC3339), err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			duplicates++
+		} else {
+			inserted++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit import transaction: %v", err)
+	}
+
+	result.Inserted = inserted
+	result.Duplicates = duplicates
+	c.debugLog("Imported %d sessions (%d duplicates, %d skipped)", result.Inserted, result.Duplicates, len(result.Skipped))
+	return result, nil
+}
+
+// validateSession checks if a session is valid before insertion. app_class, start_time and
+// end_time are its natural key: a session with the same three is stored only once.
 func (c *Client) validateSession(session ActivitySession) error {
 	if session.AppClass == "" {
 		return fmt.Errorf("app_class is required")
@@ -415,20 +600,118 @@ func (c *Client) validateSummary(summary ActivitySummary) error {
 	return nil
 }
 
-// GetRecentSessions retrieves recent activity sessions from the database.
-// Limit specifies the maximum number of sessions to return.
+// recentSessionsSQL selects the latest sessions for GetRecentSessions, leaving out deleted ones
+const recentSessionsSQL = `
+		SELECT id, start_time, end_time, app_class, window_title, duration_seconds, source, outside_active_hours, coverage, created_at
+		FROM activity_sessions
+		WHERE deleted_at IS NULL
+		ORDER BY start_time DESC
+		LIMIT $1
+	`
+
+// GetRecentSessions retrieves recent activity sessions from the database, excluding
+// sessions removed with DeleteSession. Limit specifies the maximum number to return.
 func (c *Client) GetRecentSessions(limit int) ([]ActivitySession, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
 	defer cancel()
 
-	querySQL := `
-		SELECT id, start_time, end_time, app_class, window_title, duration_seconds, created_at
+	rows, err := c.db.QueryContext(ctx, recentSessionsSQL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []ActivitySession
+	for rows.Next() {
+		var session ActivitySession
+		var durationSeconds int
+		var coverage sql.NullFloat64
+		err := rows.Scan(
+			&session.ID,
+			&session.StartTime,
+			&session.EndTime,
+			&session.AppClass,
+			&session.WindowTitle,
+			&durationSeconds,
+			&session.Source,
+			&session.OutsideActiveHours,
+			&coverage,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %v", err)
+		}
+		session.Duration = time.Duration(durationSeconds) * time.Second
+		session.Coverage = coverage.Float64
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %v", err)
+	}
+
+	return sessions, nil
+}
+
+// PruneOlderThan deletes sessions and summaries older than the retention window d,
+// keeping the local database bounded. Both deletes run in one transaction and use the
+// start_time/first_seen indexes. Returns the total number of rows deleted.
+func (c *Client) PruneOlderThan(d time.Duration) (int64, error) {
+	if d <= 0 {
+		return 0, fmt.Errorf("retention must be positive, got %v", d)
+	}
+	cutoff := time.Now().Add(-d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultImportTimeout)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM activity_sessions WHERE start_time < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune sessions: %v", err)
+	}
+	sessionsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned sessions: %v", err)
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM activity_summaries WHERE first_seen < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune summaries: %v", err)
+	}
+	summariesDeleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned summaries: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit prune: %v", err)
+	}
+
+	c.debugLog("Pruned %d sessions and %d summaries older than %s", sessionsDeleted, summariesDeleted, cutoff.Format(time.RFC3339))
+	return sessionsDeleted + summariesDeleted, nil
+}
+
+// sessionsInRangeSQL selects the sessions for GetSessionsInRange, leaving out deleted ones
+const sessionsInRangeSQL = `
+		SELECT id, start_time, end_time, app_class, window_title, duration_seconds, source, pushed_at, outside_active_hours, created_at
 		FROM activity_sessions
-		ORDER BY start_time DESC
-		LIMIT $1
+		WHERE start_time < $2 AND end_time > $1 AND NOT ignored AND NOT secondary AND deleted_at IS NULL
+		ORDER BY start_time
 	`
 
-	rows, err := c.db.QueryContext(ctx, querySQL, limit)
+// GetSessionsInRange retrieves non-ignored, non-secondary, non-deleted sessions overlapping [start, end),
+// oldest first. Used by -push-history to read local data back for submission to RescueTime.
+func (c *Client) GetSessionsInRange(start, end time.Time) ([]ActivitySession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, sessionsInRangeSQL, start, end)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sessions: %v", err)
 	}
@@ -438,19 +721,28 @@ func (c *Client) GetRecentSessions(limit int) ([]ActivitySession, error) {
 	for rows.Next() {
 		var session ActivitySession
 		var durationSeconds int
+		var windowTitle sql.NullString
+		var pushedAt sql.NullTime
 		err := rows.Scan(
 			&session.ID,
 			&session.StartTime,
 			&session.EndTime,
 			&session.AppClass,
-			&session.WindowTitle,
+			&windowTitle,
 			&durationSeconds,
+			&session.Source,
+			&pushedAt,
+			&session.OutsideActiveHours,
 			&session.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %v", err)
 		}
+		session.WindowTitle = windowTitle.String
 		session.Duration = time.Duration(durationSeconds) * time.Second
+		if pushedAt.Valid {
+			session.PushedAt = pushedAt.Time
+		}
 		sessions = append(sessions, session)
 	}
 
@@ -461,6 +753,27 @@ func (c *Client) GetRecentSessions(limit int) ([]ActivitySession, error) {
 	return sessions, nil
 }
 
+// MarkSessionsPushed records that the given sessions were submitted to RescueTime,
+// so later -push-history runs skip them.
+func (c *Client) MarkSessionsPushed(ids []int64, pushedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	result, err := c.db.ExecContext(ctx, `UPDATE activity_sessions SET pushed_at = $1 WHERE id = ANY($2)`, pushedAt, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to mark sessions as pushed: %v", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil {
+		c.debugLog("Marked %d sessions as pushed", rows)
+	}
+	return nil
+}
+
 // GetRecentSummaries retrieves recent activity summaries from the database.
 // Limit specifies the maximum number of summaries to return.
 func (c *Client) GetRecentSummaries(limit int) ([]StoredSummary, error) {
@@ -469,7 +782,8 @@ func (c *Client) GetRecentSummaries(limit int) ([]StoredSummary, error) {
 
 	querySQL := `
 		SELECT id, app_class, activity_details, total_duration_seconds, 
-		       session_count, first_seen, last_seen, submitted_at
+		       session_count, first_seen, last_seen, submitted_at,
+		       max_session_seconds, avg_session_seconds, longest_session_start
 		FROM activity_summaries
 		ORDER BY submitted_at DESC
 		LIMIT $1
@@ -484,7 +798,8 @@ func (c *Client) GetRecentSummaries(limit int) ([]StoredSummary, error) {
 	var summaries []StoredSummary
 	for rows.Next() {
 		var summary StoredSummary
-		var durationSeconds int
+		var durationSeconds, maxSeconds, avgSeconds int
+		var longestStart sql.NullTime
 		err := rows.Scan(
 			&summary.ID,
 			&summary.AppClass,
@@ -494,11 +809,19 @@ func (c *Client) GetRecentSummaries(limit int) ([]StoredSummary, error) {
 			&summary.FirstSeen,
 			&summary.LastSeen,
 			&summary.SubmittedAt,
+			&maxSeconds,
+			&avgSeconds,
+			&longestStart,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan summary: %v", err)
 		}
 		summary.TotalDuration = time.Duration(durationSeconds) * time.Second
+		summary.MaxSessionDuration = time.Duration(maxSeconds) * time.Second
+		summary.AvgSessionDuration = time.Duration(avgSeconds) * time.Second
+		if longestStart.Valid {
+			summary.LongestSessionStart = longestStart.Time
+		}
 		summaries = append(summaries, summary)
 	}
 
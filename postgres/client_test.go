@@ -1,6 +1,15 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -227,3 +236,326 @@ func TestSubmitActivities_EmptyMap(t *testing.T) {
 	// Should not panic with empty map
 	client.SubmitActivities(summaries)
 }
+
+// TestSubmitContext_Cancelled tests that a cancelled context aborts a write before it
+// reaches the database
+func TestSubmitContext_Cancelled(t *testing.T) {
+	// sql.Open doesn't connect; nothing listens here anyway
+	db, err := sql.Open("postgres", "postgres://localhost:1/none?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	client := &Client{db: db}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	now := time.Now()
+	session := ActivitySession{AppClass: "code", StartTime: now.Add(-time.Minute), EndTime: now, Duration: time.Minute}
+	if _, err := client.SubmitSessionContext(ctx, session); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Expected SubmitSessionContext to abort with %v, got %v", context.Canceled, err)
+	}
+	summary := ActivitySummary{AppClass: "code", TotalDuration: time.Minute, SessionCount: 1, FirstSeen: now.Add(-time.Minute), LastSeen: now}
+	if err := client.SubmitSummaryContext(ctx, summary); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Expected SubmitSummaryContext to abort with %v, got %v", context.Canceled, err)
+	}
+
+	// The batch methods return what wasn't stored for a retry, but not invalid entries
+	invalid := ActivitySession{AppClass: "code", StartTime: now, EndTime: now.Add(-time.Minute)}
+	if unsent := client.SubmitSessionsContext(ctx, []ActivitySession{session, invalid}); len(unsent) != 2 {
+		t.Errorf("Expected both sessions returned as not attempted, got %d", len(unsent))
+	}
+	if unsent := client.SubmitSessionsContext(context.Background(), []ActivitySession{invalid}); len(unsent) != 0 {
+		t.Errorf("Expected an invalid session not to be returned for a retry, got %+v", unsent)
+	}
+	if unsent := client.SubmitActivitiesContext(ctx, map[string]ActivitySummary{"code": summary}); len(unsent) != 1 {
+		t.Errorf("Expected the summary returned as not attempted, got %d", len(unsent))
+	}
+}
+
+// TestPruneOlderThan_InvalidRetention tests that a non-positive retention is rejected before touching the database
+func TestPruneOlderThan_InvalidRetention(t *testing.T) {
+	client := &Client{DebugMode: false}
+
+	for _, d := range []time.Duration{0, -24 * time.Hour} {
+		if _, err := client.PruneOlderThan(d); err == nil {
+			t.Errorf("PruneOlderThan(%v) should fail", d)
+		}
+	}
+}
+
+// TestMigrationsOrdered tests that migration versions start at 1 and increase by one
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Migration %d has version %d, expected %d", i, m.Version, i+1)
+		}
+		if m.Description == "" || len(m.Statements) == 0 {
+			t.Errorf("Migration %d is missing a description or statements", m.Version)
+		}
+	}
+	if LatestSchemaVersion() != len(migrations) {
+		t.Errorf("LatestSchemaVersion() = %d, expected %d", LatestSchemaVersion(), len(migrations))
+	}
+}
+
+// TestNaturalKeyMigrationKeepsLiveCopy tests that migration 11 keeps a live copy of a
+// duplicated session over an older soft-deleted one. It needs a PostgreSQL database in
+// POSTGRES_TEST_CONNECTION_STRING and works in a schema it drops afterwards.
+func TestNaturalKeyMigrationKeepsLiveCopy(t *testing.T) {
+	connectionStr := os.Getenv("POSTGRES_TEST_CONNECTION_STRING")
+	if connectionStr == "" {
+		t.Skip("POSTGRES_TEST_CONNECTION_STRING not set")
+	}
+	db, err := sql.Open("postgres", connectionStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // search_path is per connection
+
+	schema := fmt.Sprintf("migration_test_%d", time.Now().UnixNano())
+	for _, statement := range []string{"CREATE SCHEMA " + schema, "SET search_path TO " + schema} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.Exec("DROP SCHEMA " + schema + " CASCADE")
+
+	var naturalKey migration
+	for _, m := range migrations {
+		if m.Version < 11 {
+			for _, statement := range m.Statements {
+				if _, err := db.Exec(statement); err != nil {
+					t.Fatalf("migration %d: %v", m.Version, err)
+				}
+			}
+		} else if m.Version == 11 {
+			naturalKey = m
+		}
+	}
+
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.UTC)
+	rows := []struct {
+		app     string
+		deleted bool
+	}{
+		{"code", true},     // 1: soft-deleted first copy
+		{"code", false},    // 2: kept, the first live copy
+		{"code", false},    // 3
+		{"firefox", true},  // 4: kept, every copy is deleted
+		{"firefox", true},  // 5
+		{"terminal", true}, // 6: kept, no duplicate
+	}
+	for _, row := range rows {
+		var deletedAt interface{}
+		if row.deleted {
+			deletedAt = start
+		}
+		if _, err := db.Exec(`INSERT INTO activity_sessions (start_time, end_time, app_class, duration_seconds, deleted_at) VALUES ($1, $2, $3, 600, $4)`,
+			start, start.Add(10*time.Minute), row.app, deletedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, statement := range naturalKey.Statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("migration 11: %v", err)
+		}
+	}
+
+	result, err := db.Query(`SELECT id FROM activity_sessions ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Close()
+	var kept []int64
+	for result.Next() {
+		var id int64
+		if err := result.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, id)
+	}
+	if fmt.Sprint(kept) != "[2 4 6]" {
+		t.Errorf("Expected rows 2, 4 and 6 to be kept, got %v", kept)
+	}
+}
+
+// TestPlanStatements tests the dry-run plan for fresh, partially migrated and current databases
+func TestPlanStatements(t *testing.T) {
+	latest := migrations[len(migrations)-1].Version
+
+	fresh := planStatements(0, false)
+	if len(fresh) == 0 || fresh[0] != schemaMigrationsTableSQL {
+		t.Fatalf("Fresh database plan should start by creating schema_migrations, got %v", fresh)
+	}
+
+	partial := planStatements(latest-1, true)
+	last := migrations[len(migrations)-1]
+	if len(partial) != len(last.Statements)+2 {
+		t.Errorf("Expected only the latest migration (%d statements plus header and bookkeeping), got %d: %v", len(last.Statements), len(partial), partial)
+	}
+	for _, statement := range partial {
+		if statement == schemaMigrationsTableSQL {
+			t.Error("Plan should not create schema_migrations when it already exists")
+		}
+	}
+
+	if current := planStatements(latest, true); len(current) != 0 {
+		t.Errorf("Up-to-date database should have an empty plan, got %v", current)
+	}
+}
+
+// TestEditedSession tests that an edit keeps unchanged fields, recomputes the duration from
+// the new range and is validated like a new session
+func TestEditedSession(t *testing.T) {
+	client := &Client{DebugMode: false}
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	current := ActivitySession{StartTime: start, EndTime: start.Add(2 * time.Hour), AppClass: "mpv", Duration: 2 * time.Hour}
+
+	tests := []struct {
+		name         string
+		newStart     time.Time
+		newEnd       time.Time
+		newAppClass  string
+		wantErr      bool
+		wantDuration time.Duration
+		wantAppClass string
+	}{
+		{name: "shorten end", newEnd: start.Add(20 * time.Minute), wantDuration: 20 * time.Minute, wantAppClass: "mpv"},
+		{name: "move start", newStart: start.Add(90 * time.Minute), wantDuration: 30 * time.Minute, wantAppClass: "mpv"},
+		{name: "rename only", newAppClass: "firefox", wantDuration: 2 * time.Hour, wantAppClass: "firefox"},
+		{name: "new range", newStart: start.Add(-time.Hour), newEnd: start, wantDuration: time.Hour, wantAppClass: "mpv"},
+		{name: "end before start", newEnd: start.Add(-time.Minute), wantErr: true},
+		{name: "start after end", newStart: start.Add(3 * time.Hour), wantErr: true},
+		{name: "empty range", newEnd: start, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited, err := client.editedSession(current, tt.newStart, tt.newEnd, tt.newAppClass)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("editedSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if edited.Duration != tt.wantDuration || edited.EndTime.Sub(edited.StartTime) != edited.Duration || edited.AppClass != tt.wantAppClass {
+				t.Errorf("Expected %s for %v, got %+v", tt.wantAppClass, tt.wantDuration, edited)
+			}
+			if err := client.validateSession(edited); err != nil {
+				t.Errorf("Edited session fails validation: %v", err)
+			}
+		})
+	}
+}
+
+// TestSessionQueriesExcludeDeleted tests that the queries reports and -push-history read
+// sessions through leave out soft-deleted ones
+func TestSessionQueriesExcludeDeleted(t *testing.T) {
+	for name, query := range map[string]string{"GetRecentSessions": recentSessionsSQL, "GetSessionsInRange": sessionsInRangeSQL} {
+		if !strings.Contains(query, "deleted_at IS NULL") {
+			t.Errorf("%s doesn't filter deleted sessions:\n%s", name, query)
+		}
+	}
+	found := false
+	for _, m := range migrations {
+		found = found || strings.Contains(strings.Join(m.Statements, "\n"), "deleted_at")
+	}
+	if !found {
+		t.Error("Expected a migration adding deleted_at")
+	}
+}
+
+// TestSessionsInRangeExcludesSecondary tests that -push-history never reads back the
+// background-app time that overlaps the focused sessions, and that a migration adds it
+func TestSessionsInRangeExcludesSecondary(t *testing.T) {
+	if !strings.Contains(sessionsInRangeSQL, "NOT secondary") {
+		t.Errorf("GetSessionsInRange doesn't filter secondary sessions:\n%s", sessionsInRangeSQL)
+	}
+	if !strings.Contains(insertSessionSQL, "secondary") {
+		t.Errorf("SubmitSession doesn't store secondary:\n%s", insertSessionSQL)
+	}
+	last := migrations[len(migrations)-1]
+	if !strings.Contains(strings.Join(last.Statements, "\n"), "secondary") {
+		t.Error("Expected a migration adding activity_sessions.secondary")
+	}
+}
+
+// naturalKeyDB stands in for PostgreSQL's unique index on activity_sessions: a session
+// insert returns a new ID unless its (app_class, start_time, end_time) was inserted before,
+// as ON CONFLICT DO NOTHING does. It only answers queries, which is all SubmitSession uses.
+type naturalKeyDB struct {
+	mu      sync.Mutex
+	queries []string
+	ids     map[string]int64
+}
+
+func (db *naturalKeyDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *naturalKeyDB) Driver() driver.Driver                        { return nil }
+func (db *naturalKeyDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (db *naturalKeyDB) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+func (db *naturalKeyDB) Close() error                                 { return nil }
+
+func (db *naturalKeyDB) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+
+	// $1 is start_time, $2 end_time and $3 app_class
+	key := fmt.Sprint(args[2].Value, args[0].Value, args[1].Value)
+	if _, ok := db.ids[key]; ok && strings.Contains(query, "ON CONFLICT (app_class, start_time, end_time) DO NOTHING") {
+		return &idRows{}, nil
+	}
+	db.ids[key] = int64(len(db.ids) + 1)
+	return &idRows{ids: []int64{db.ids[key]}}, nil
+}
+
+// idRows is the RETURNING id result: one row for an insert, none for a conflict
+type idRows struct{ ids []int64 }
+
+func (r *idRows) Columns() []string { return []string{"id"} }
+func (r *idRows) Close() error      { return nil }
+func (r *idRows) Next(dest []driver.Value) error {
+	if len(r.ids) == 0 {
+		return io.EOF
+	}
+	dest[0], r.ids = r.ids[0], r.ids[1:]
+	return nil
+}
+
+// TestSubmitSessionIdempotent tests that storing the same session twice leaves one row
+// and reports the second as not new, and that a migration adds the key ON CONFLICT uses
+func TestSubmitSessionIdempotent(t *testing.T) {
+	fake := &naturalKeyDB{ids: make(map[string]int64)}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	client := &Client{db: db}
+
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.UTC)
+	session := ActivitySession{AppClass: "code", WindowTitle: "main.go", StartTime: start, EndTime: start.Add(10 * time.Minute), Duration: 10 * time.Minute}
+	if inserted, err := client.SubmitSession(session); err != nil || !inserted {
+		t.Fatalf("Expected the first insert to be new, got %v, %v", inserted, err)
+	}
+	if inserted, err := client.SubmitSession(session); err != nil || inserted {
+		t.Errorf("Expected the second insert to be a no-op, got %v, %v", inserted, err)
+	}
+	if len(fake.ids) != 1 || len(fake.queries) != 2 {
+		t.Errorf("Expected one row after two inserts, got %d rows", len(fake.ids))
+	}
+
+	session.EndTime = session.EndTime.Add(time.Minute)
+	session.Duration += time.Minute
+	if inserted, err := client.SubmitSession(session); err != nil || !inserted {
+		t.Errorf("Expected a session with another end time to be new, got %v, %v", inserted, err)
+	}
+
+	found := false
+	for _, m := range migrations {
+		found = found || strings.Contains(strings.Join(m.Statements, "\n"), "UNIQUE INDEX IF NOT EXISTS idx_sessions_natural_key ON activity_sessions(app_class, start_time, end_time)")
+	}
+	if !found {
+		t.Error("Expected a migration adding the (app_class, start_time, end_time) unique index")
+	}
+}
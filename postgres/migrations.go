@@ -0,0 +1,266 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration is a versioned set of schema changes. Statements are idempotent
+// (IF NOT EXISTS) so databases created before versioning was added migrate cleanly.
+type migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// schemaMigrationsTableSQL tracks which migrations have been applied
+const schemaMigrationsTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);`
+
+// recordMigrationSQL marks a migration as applied
+const recordMigrationSQL = `INSERT INTO schema_migrations (version, description) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING;`
+
+// migrations lists every schema change in order. Append new migrations; never edit applied ones.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "create activity_sessions",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS activity_sessions (
+	id SERIAL PRIMARY KEY,
+	start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	app_class VARCHAR(255) NOT NULL,
+	window_title TEXT,
+	duration_seconds INTEGER NOT NULL,
+	ignored BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	CONSTRAINT valid_duration CHECK (duration_seconds >= 0),
+	CONSTRAINT valid_time_range CHECK (end_time >= start_time)
+);`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_app_class ON activity_sessions(app_class);`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_start_time ON activity_sessions(start_time);`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_end_time ON activity_sessions(end_time);`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_app_time ON activity_sessions(app_class, start_time);`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_ignored ON activity_sessions(ignored);`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create activity_summaries",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS activity_summaries (
+	id SERIAL PRIMARY KEY,
+	app_class VARCHAR(255) NOT NULL,
+	activity_details TEXT,
+	total_duration_seconds INTEGER NOT NULL,
+	session_count INTEGER NOT NULL,
+	first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	submitted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	CONSTRAINT valid_summary_duration CHECK (total_duration_seconds >= 0),
+	CONSTRAINT valid_session_count CHECK (session_count > 0),
+	CONSTRAINT valid_summary_time_range CHECK (last_seen >= first_seen)
+);`,
+			`CREATE INDEX IF NOT EXISTS idx_summaries_app_class ON activity_summaries(app_class);`,
+			`CREATE INDEX IF NOT EXISTS idx_summaries_first_seen ON activity_summaries(first_seen);`,
+			`CREATE INDEX IF NOT EXISTS idx_summaries_last_seen ON activity_summaries(last_seen);`,
+			`CREATE INDEX IF NOT EXISTS idx_summaries_submitted_at ON activity_summaries(submitted_at);`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add activity_sessions.source",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS source VARCHAR(64) NOT NULL DEFAULT 'tracker';`,
+			`CREATE INDEX IF NOT EXISTS idx_sessions_source ON activity_sessions(source);`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add activity_sessions.pushed_at",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS pushed_at TIMESTAMP WITH TIME ZONE;`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "add activity_sessions.outside_active_hours",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS outside_active_hours BOOLEAN NOT NULL DEFAULT FALSE;`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add activity_summaries session length statistics",
+		Statements: []string{
+			`ALTER TABLE activity_summaries ADD COLUMN IF NOT EXISTS max_session_seconds INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE activity_summaries ADD COLUMN IF NOT EXISTS avg_session_seconds INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE activity_summaries ADD COLUMN IF NOT EXISTS longest_session_start TIMESTAMP WITH TIME ZONE;`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "add activity_sessions active and idle split",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS active_seconds INTEGER;`,
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS idle_seconds INTEGER;`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "add activity_sessions.deleted_at",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "add activity_sessions.coverage",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS coverage REAL;`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "add activity_sessions.maximized_seconds",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS maximized_seconds INTEGER;`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "add activity_sessions natural key (app_class, start_time, end_time)",
+		Statements: []string{
+			// Retries and replays used to store a session twice; keep the first copy that
+			// isn't soft-deleted, so deleting one copy doesn't delete the session
+			`DELETE FROM activity_sessions a USING activity_sessions b
+WHERE a.app_class = b.app_class AND a.start_time = b.start_time AND a.end_time = b.end_time
+AND (a.deleted_at IS NOT NULL, a.id) > (b.deleted_at IS NOT NULL, b.id);`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_natural_key ON activity_sessions(app_class, start_time, end_time);`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "add activity_sessions.secondary",
+		Statements: []string{
+			`ALTER TABLE activity_sessions ADD COLUMN IF NOT EXISTS secondary BOOLEAN NOT NULL DEFAULT FALSE;`,
+		},
+	},
+}
+
+// pendingMigrations returns the migrations newer than the current version
+func pendingMigrations(current int) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// planStatements returns the SQL that migrating from the current version would run,
+// including schema_migrations bookkeeping, in execution order.
+func planStatements(current int, hasMigrationsTable bool) []string {
+	var statements []string
+	if !hasMigrationsTable {
+		statements = append(statements, schemaMigrationsTableSQL)
+	}
+	for _, m := range pendingMigrations(current) {
+		statements = append(statements, fmt.Sprintf("-- migration %d: %s", m.Version, m.Description))
+		statements = append(statements, m.Statements...)
+		statements = append(statements, fmt.Sprintf("INSERT INTO schema_migrations (version, description) VALUES (%d, '%s');", m.Version, m.Description))
+	}
+	return statements
+}
+
+// schemaVersion returns the latest applied migration version and whether schema_migrations exists.
+// It only reads; a database without schema_migrations reports version 0.
+func (c *Client) schemaVersion(ctx context.Context) (int, bool, error) {
+	var table sql.NullString
+	if err := c.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations')::text`).Scan(&table); err != nil {
+		return 0, false, fmt.Errorf("failed to check for schema_migrations: %v", err)
+	}
+	if !table.Valid {
+		return 0, false, nil
+	}
+
+	var version int
+	if err := c.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, true, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, true, nil
+}
+
+// SchemaVersion returns the latest applied migration version without changing anything.
+// A database that predates schema_migrations reports 0.
+func (c *Client) SchemaVersion() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	version, _, err := c.schemaVersion(ctx)
+	return version, err
+}
+
+// LatestSchemaVersion returns the version this build migrates databases to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// PlanMigrations returns the SQL statements that would run to bring the database up to
+// date, given the current schema_migrations version, without executing anything.
+// Returns an empty slice when the schema is current.
+func (c *Client) PlanMigrations() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	current, hasTable, err := c.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planStatements(current, hasTable), nil
+}
+
+// initializeSchema applies pending migrations, each in its own transaction.
+func (c *Client) initializeSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	if _, err := c.db.ExecContext(ctx, schemaMigrationsTableSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	current, _, err := c.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pendingMigrations(current) {
+		tx, err := c.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %v", m.Version, err)
+		}
+		for _, statement := range m.Statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Description, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, recordMigrationSQL, m.Version, m.Description); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %v", m.Version, err)
+		}
+		c.debugLog("Applied migration %d: %s", m.Version, m.Description)
+	}
+
+	c.debugLog("Database schema initialized successfully")
+	return nil
+}
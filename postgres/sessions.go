@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound is returned by DeleteSession and EditSession for an id that doesn't
+// exist or was already deleted
+var ErrSessionNotFound = errors.New("session not found")
+
+// DeleteSession soft-deletes a session: it sets deleted_at, so GetRecentSessions,
+// GetSessionsInRange and -push-history leave it out while the row stays for reference.
+// Imports still count it as a duplicate, so re-importing doesn't bring it back.
+func (c *Client) DeleteSession(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	result, err := c.db.ExecContext(ctx, `UPDATE activity_sessions SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session %d: %v", id, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: %d", ErrSessionNotFound, id)
+	}
+	c.debugLog("Deleted session ID %d", id)
+	return nil
+}
+
+// EditSession corrects a session's time range and application. A zero newStart or newEnd,
+// or an empty newAppClass, keeps the current value. The duration is recomputed from the
+// new range and checked like a new session's; the active/idle split no longer applies, so
+// it's cleared. A session already pushed to RescueTime isn't pushed again.
+func (c *Client) EditSession(id int64, newStart, newEnd time.Time, newAppClass string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var current ActivitySession
+	err = tx.QueryRowContext(ctx, `SELECT start_time, end_time, app_class FROM activity_sessions WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).
+		Scan(&current.StartTime, &current.EndTime, &current.AppClass)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %d", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session %d: %v", id, err)
+	}
+
+	edited, err := c.editedSession(current, newStart, newEnd, newAppClass)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE activity_sessions
+		SET start_time = $2, end_time = $3, app_class = $4, duration_seconds = $5, active_seconds = NULL, idle_seconds = NULL, coverage = NULL, maximized_seconds = NULL
+		WHERE id = $1
+	`, id, edited.StartTime, edited.EndTime, edited.AppClass, int(edited.Duration.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to edit session %d: %v", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit edit: %v", err)
+	}
+
+	c.debugLog("Edited session ID %d: %s %s to %s", id, edited.AppClass, edited.StartTime.Format(time.RFC3339), edited.EndTime.Format(time.RFC3339))
+	return nil
+}
+
+// editedSession applies an EditSession change to current and validates the result
+func (c *Client) editedSession(current ActivitySession, newStart, newEnd time.Time, newAppClass string) (ActivitySession, error) {
+	edited := current
+	if !newStart.IsZero() {
+		edited.StartTime = newStart
+	}
+	if !newEnd.IsZero() {
+		edited.EndTime = newEnd
+	}
+	if newAppClass != "" {
+		edited.AppClass = newAppClass
+	}
+	edited.Duration = edited.EndTime.Sub(edited.StartTime)
+
+	if err := c.validateSession(edited); err != nil {
+		return ActivitySession{}, fmt.Errorf("invalid edit: %v", err)
+	}
+	if edited.Duration == 0 {
+		return ActivitySession{}, fmt.Errorf("invalid edit: end_time must be after start_time (delete the session instead)")
+	}
+	return edited, nil
+}
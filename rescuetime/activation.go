@@ -0,0 +1,100 @@
+package rescuetime
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Activation error codes, the reasons RescueTime gives for refusing an activation
+const (
+	ActivationBadPassword    = "bad-password"    // the email is known but the password is wrong
+	ActivationUnknownAccount = "unknown-account" // no account with that email
+	ActivationRateLimited    = "rate-limited"    // too many attempts, try again later
+	ActivationAccountLocked  = "account-locked"  // the account is locked, disabled or suspended
+	ActivationUnknown        = "unknown"         // an error response with no recognized reason
+)
+
+// activationReasons maps phrases in RescueTime's error messages (lowercased) to codes.
+// They're checked in order, so the more specific phrases come first.
+var activationReasons = []struct {
+	phrase string
+	code   string
+}{
+	{"locked", ActivationAccountLocked},
+	{"disabled", ActivationAccountLocked},
+	{"suspended", ActivationAccountLocked},
+	{"too many", ActivationRateLimited},
+	{"rate limit", ActivationRateLimited},
+	{"try again later", ActivationRateLimited},
+	{"no account", ActivationUnknownAccount},
+	{"no user", ActivationUnknownAccount},
+	{"not found", ActivationUnknownAccount},
+	{"unknown email", ActivationUnknownAccount},
+	{"not registered", ActivationUnknownAccount},
+	{"password", ActivationBadPassword},
+	{"invalid login", ActivationBadPassword},
+	{"invalid credentials", ActivationBadPassword},
+}
+
+// activationRemediations is what the user can do about each code
+var activationRemediations = map[string]string{
+	ActivationBadPassword:    "Wrong password, check it and try again.",
+	ActivationUnknownAccount: "No RescueTime account uses that email. Check the address, or sign up at https://www.rescuetime.com first.",
+	ActivationRateLimited:    "Too many login attempts. Wait a few minutes before trying again.",
+	ActivationAccountLocked:  "The account is locked. Sign in at https://www.rescuetime.com to unlock it or contact RescueTime support.",
+	ActivationUnknown:        "RescueTime refused the login. Check the email and password, or try signing in at https://www.rescuetime.com.",
+}
+
+// ActivationError is returned by Activate when RescueTime answers with RT:error. Code is
+// one of the Activation* constants, Message is RescueTime's own message (empty if it gave
+// none) and Remediation tells the user what to do, e.g. to show in a setup prompt.
+type ActivationError struct {
+	Code        string
+	Message     string
+	Remediation string
+}
+
+func (e *ActivationError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("activation failed (%s)", e.Code)
+	}
+	return fmt.Sprintf("activation failed (%s): %s", e.Code, e.Message)
+}
+
+// parseActivationError reads an RT:error response. The format is the YAML-like one of a
+// successful response, "c:\n- 1\n- RT:error\n", with the reason as a further list item or
+// a message: (or error:) line. status is the HTTP status; 429 means rate limited whatever
+// the message says.
+func parseActivationError(body string, status int) *ActivationError {
+	var message string
+	afterError := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasSuffix(line, "RT:error"):
+			afterError = true
+		case afterError && strings.HasPrefix(line, "- ") && message == "":
+			message = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+		case strings.HasPrefix(line, "message:"):
+			message = strings.TrimSpace(strings.TrimPrefix(line, "message:"))
+		case strings.HasPrefix(line, "error:") && message == "":
+			message = strings.TrimSpace(strings.TrimPrefix(line, "error:"))
+		}
+	}
+	message = strings.Trim(message, `"'`)
+
+	code := ActivationUnknown
+	if status == http.StatusTooManyRequests {
+		code = ActivationRateLimited
+	} else {
+		lower := strings.ToLower(message)
+		for _, reason := range activationReasons {
+			if strings.Contains(lower, reason.phrase) {
+				code = reason.code
+				break
+			}
+		}
+	}
+	return &ActivationError{Code: code, Message: message, Remediation: activationRemediations[code]}
+}
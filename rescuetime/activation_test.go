@@ -0,0 +1,95 @@
+package rescuetime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestActivateErrors tests that RT:error responses come back as an *ActivationError with
+// the reason's code, RescueTime's message and the matching remediation
+func TestActivateErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		code        string
+		message     string
+		remediation string
+	}{
+		{
+			name:        "bad password",
+			body:        "c:\n- 1\n- RT:error\n- Invalid email or password\n",
+			code:        ActivationBadPassword,
+			message:     "Invalid email or password",
+			remediation: "Wrong password",
+		},
+		{
+			name:        "unknown account",
+			body:        "c:\n- 1\n- RT:error\nmessage: \"No account found for that email\"\n",
+			code:        ActivationUnknownAccount,
+			message:     "No account found for that email",
+			remediation: "sign up",
+		},
+		{
+			name:        "rate limited by message",
+			body:        "c:\n- 1\n- RT:error\n- Too many login attempts, try again later\n",
+			code:        ActivationRateLimited,
+			message:     "Too many login attempts, try again later",
+			remediation: "Wait a few minutes",
+		},
+		{
+			name:        "rate limited by status",
+			status:      http.StatusTooManyRequests,
+			body:        "Retry later",
+			code:        ActivationRateLimited,
+			remediation: "Wait a few minutes",
+		},
+		{
+			name:        "locked",
+			body:        "c:\n- 1\n- RT:error\nerror: Account locked after repeated failed logins\n",
+			code:        ActivationAccountLocked,
+			message:     "Account locked after repeated failed logins",
+			remediation: "locked",
+		},
+		{
+			name:        "no reason",
+			body:        "c:\n- 1\n- RT:error\n",
+			code:        ActivationUnknown,
+			remediation: "refused the login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := &Client{activateURL: server.URL}
+			keys, err := client.Activate("user@example.com", "secret")
+			if keys != nil {
+				t.Fatalf("Expected no keys, got %+v", keys)
+			}
+			var activationErr *ActivationError
+			if !errors.As(err, &activationErr) {
+				t.Fatalf("Expected an *ActivationError, got %T: %v", err, err)
+			}
+			if activationErr.Code != tt.code || activationErr.Message != tt.message {
+				t.Errorf("Expected %s %q, got %s %q", tt.code, tt.message, activationErr.Code, activationErr.Message)
+			}
+			if !strings.Contains(activationErr.Remediation, tt.remediation) {
+				t.Errorf("Expected remediation mentioning %q, got %q", tt.remediation, activationErr.Remediation)
+			}
+			if !strings.Contains(err.Error(), tt.code) {
+				t.Errorf("Expected the error to name the code, got %q", err.Error())
+			}
+		})
+	}
+}
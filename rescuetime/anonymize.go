@@ -0,0 +1,42 @@
+package rescuetime
+
+import (
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+)
+
+// anonymizeTitles returns the title mode to use, TitlesDrop if none was set
+func (c *Client) anonymizeTitles() string {
+	if c.AnonymizeTitles == "" {
+		return anonymize.TitlesDrop
+	}
+	return c.AnonymizeTitles
+}
+
+// AnonymizeLegacyPayload returns payload as SubmitLegacy sends it: with AnonymizeApps set,
+// activity_name becomes the class's keyed hash and activity_details is dropped or hashed.
+// Otherwise payload is returned unchanged. Dry-run previews use it to show what would be sent.
+func (c *Client) AnonymizeLegacyPayload(payload RescueTimePayload) RescueTimePayload {
+	if !c.AnonymizeApps {
+		return payload
+	}
+	payload.ActivityName = anonymize.App(payload.ActivityName, c.AnonymizeSecret)
+	payload.ActivityDetails = anonymize.ApplyTitle(payload.ActivityDetails, c.anonymizeTitles(), c.AnonymizeSecret)
+	return payload
+}
+
+// anonymizeNativePayload is AnonymizeLegacyPayload for user_client_events. The event ID was
+// derived from the cleartext event, so it's derived again from the anonymized one.
+func (c *Client) anonymizeNativePayload(payload UserClientEventPayload) UserClientEventPayload {
+	if !c.AnonymizeApps {
+		return payload
+	}
+	event := payload.UserClientEvent
+	event.Application = anonymize.App(event.Application, c.AnonymizeSecret)
+	event.EventDescription = ""
+	event.WindowTitle = anonymize.ApplyTitle(event.WindowTitle, c.anonymizeTitles(), c.AnonymizeSecret)
+	if event.ClientEventID != "" {
+		event.ClientEventID = ""
+		event.ClientEventID = IdempotencyKey(event)
+	}
+	return UserClientEventPayload{UserClientEvent: event}
+}
@@ -0,0 +1,73 @@
+package rescuetime
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+)
+
+// TestAnonymizedSubmission tests that with AnonymizeApps neither the native nor the legacy
+// request body contains an application class or title, that the same class is sent as the
+// same pseudonym across submissions, and that the result still names it in cleartext
+func TestAnonymizedSubmission(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"code":    {AppClass: "code", ActivityDetails: "payroll.go", RawTitle: "payroll.go - Visual Studio Code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"firefox": {AppClass: "firefox", ActivityDetails: "Job interview - Calendar", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(time.Hour), LastSeen: start.Add(70 * time.Minute)},
+	}
+	cleartext := []string{"code", "payroll", "Visual Studio", "firefox", "interview"}
+
+	native := NewClient("legacy-key", "account-key", "data-key")
+	native.nativeURL = server.URL
+	legacy := NewClient("legacy-key", "", "")
+	legacy.AccountKey, legacy.DataKey = "", ""
+	legacy.legacyURL = server.URL
+	for _, client := range []*Client{native, legacy} {
+		client.AnonymizeApps = true
+		client.AnonymizeSecret = "local-secret"
+		result := client.SubmitActivitiesResult(context.Background(), summaries)
+		if result.Count(OutcomeSucceededNative)+result.Count(OutcomeSucceededLegacy) != 2 || (result.Outcomes[0].AppClass != "code" && result.Outcomes[0].AppClass != "firefox") {
+			t.Fatalf("Expected both sent and reported in cleartext, got %+v", result.Outcomes)
+		}
+	}
+	native.SubmitActivities(map[string]ActivitySummary{"code": summaries["code"]})
+
+	if len(bodies) != 5 {
+		t.Fatalf("Expected 5 requests, got %d", len(bodies))
+	}
+	pseudonym := anonymize.App("code", "local-secret")
+	for i, body := range bodies {
+		for _, text := range cleartext {
+			if strings.Contains(body, text) {
+				t.Errorf("Request %d leaks %q: %s", i, text, body)
+			}
+		}
+	}
+	sent := 0
+	for _, body := range bodies {
+		if strings.Contains(body, pseudonym) {
+			sent++
+		}
+	}
+	if sent != 3 {
+		t.Errorf("Expected code sent as %s in 3 requests, got %d", pseudonym, sent)
+	}
+}
@@ -0,0 +1,141 @@
+package rescuetime
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+)
+
+// BenchmarkApp is the application name of the summaries Benchmark submits, so they're easy
+// to find (and categorize or delete) in RescueTime
+const BenchmarkApp = "rescuetime-linux-mutter-benchmark"
+
+// BenchmarkResult is how one endpoint did over a Benchmark run. Latencies are of whole
+// submissions as the tracker sees them, retries included, and count failed ones too.
+type BenchmarkResult struct {
+	Endpoint  string // "legacy" or "native"
+	Requests  int
+	Errors    int
+	Min       time.Duration
+	Avg       time.Duration
+	P95       time.Duration
+	Max       time.Duration
+	LastError error // the last submission's error, nil if none failed
+}
+
+// ErrorRate returns the share of submissions that failed, 0 to 1
+func (r BenchmarkResult) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// String describes the result in one line, e.g. "legacy: 10 submissions, min 210ms, avg
+// 340ms, p95 820ms, max 900ms, 0% errors"
+func (r BenchmarkResult) String() string {
+	return fmt.Sprintf("%s: %d submissions, min %v, avg %v, p95 %v, max %v, %.0f%% errors",
+		r.Endpoint, r.Requests, r.Min.Round(time.Millisecond), r.Avg.Round(time.Millisecond),
+		r.P95.Round(time.Millisecond), r.Max.Round(time.Millisecond), r.ErrorRate()*100)
+}
+
+// BenchmarkSummary returns the synthetic summary Benchmark submits: one minute of BenchmarkApp
+// ending at now
+func BenchmarkSummary(now time.Time) ActivitySummary {
+	start := now.Add(-time.Minute).Truncate(time.Second)
+	return ActivitySummary{
+		AppClass:        BenchmarkApp,
+		ActivityDetails: "API latency benchmark",
+		TotalDuration:   time.Minute,
+		SessionCount:    1,
+		FirstSeen:       start,
+		LastSeen:        start.Add(time.Minute),
+	}
+}
+
+// Benchmark submits summary n times, one after another, to the legacy endpoint (with an
+// APIKey) and then the native one (with native credentials), and measures each submission.
+// Unlike SubmitActivities there's no fallback between them and no minimum duration, so
+// each endpoint is timed on its own. progress, if set, is called after every submission.
+func (c *Client) Benchmark(ctx context.Context, summary ActivitySummary, n int, progress func(endpoint string, i int, took time.Duration, err error)) []BenchmarkResult {
+	var results []BenchmarkResult
+	if c.APIKey != "" {
+		payload := SummaryToPayloadMode(summary, c.PayloadMode)
+		results = append(results, benchmarkEndpoint(ctx, "legacy", n, progress, func() error {
+			return c.SubmitLegacyContext(ctx, payload)
+		}))
+	}
+	if c.hasNativeCredentials() {
+		payload := SummaryToUserClientEvent(summary)
+		results = append(results, benchmarkEndpoint(ctx, "native", n, progress, func() error {
+			return c.SubmitNativeContext(ctx, payload)
+		}))
+	}
+	return results
+}
+
+// benchmarkEndpoint times n calls of submit, stopping early if ctx ends
+func benchmarkEndpoint(ctx context.Context, endpoint string, n int, progress func(string, int, time.Duration, error), submit func() error) BenchmarkResult {
+	result := BenchmarkResult{Endpoint: endpoint}
+	var latencies []time.Duration
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		start := time.Now()
+		err := submit()
+		took := time.Since(start)
+
+		latencies = append(latencies, took)
+		if err != nil {
+			result.Errors++
+			result.LastError = err
+		}
+		if progress != nil {
+			progress(endpoint, i+1, took, err)
+		}
+	}
+	result.Requests = len(latencies)
+	result.Min, result.Avg, result.P95, result.Max = latencyStats(latencies)
+	return result
+}
+
+// latencyStats returns the minimum, mean, 95th percentile (nearest rank) and maximum of
+// latencies, all 0 for none
+func latencyStats(latencies []time.Duration) (fastest, mean, p95, slowest time.Duration) {
+	if len(latencies) == 0 {
+		return 0, 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	rank := int(math.Ceil(0.95 * float64(len(sorted))))
+	return sorted[0], total / time.Duration(len(sorted)), sorted[rank-1], sorted[len(sorted)-1]
+}
+
+// NewBenchmarkServer starts a local stand-in for the legacy and native endpoints that
+// accepts every submission after latency, for a benchmark that sends nothing to RescueTime.
+// Point a client at it with UseServer and Close it when done.
+func NewBenchmarkServer(latency time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+}
+
+// UseServer sends the client's legacy and native submissions to baseURL instead of
+// RescueTime, e.g. a NewBenchmarkServer
+func (c *Client) UseServer(baseURL string) {
+	c.legacyURL = baseURL + "/anapi/offline_time_post"
+	c.nativeURL = baseURL + c.profile().EventsPath
+}
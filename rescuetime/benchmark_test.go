@@ -0,0 +1,75 @@
+package rescuetime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestLatencyStats tests the nearest-rank p95 and the mean
+func TestLatencyStats(t *testing.T) {
+	var latencies []time.Duration
+	for i := 20; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	fastest, mean, p95, slowest := latencyStats(latencies)
+	if fastest != time.Millisecond || slowest != 20*time.Millisecond || p95 != 19*time.Millisecond || mean != 10500*time.Microsecond {
+		t.Errorf("Expected 1ms/10.5ms/19ms/20ms, got %v/%v/%v/%v", fastest, mean, p95, slowest)
+	}
+	if fastest, mean, p95, slowest := latencyStats([]time.Duration{5 * time.Millisecond}); fastest != mean || p95 != slowest || p95 != 5*time.Millisecond {
+		t.Errorf("Expected one sample to be every statistic, got %v/%v/%v/%v", fastest, mean, p95, slowest)
+	}
+	if _, _, p95, _ := latencyStats(nil); p95 != 0 {
+		t.Errorf("Expected 0 for no samples, got %v", p95)
+	}
+}
+
+// TestBenchmark tests that each endpoint is timed separately with the keys the client has,
+// that a rejected submission counts as an error without stopping the run, and that
+// UseServer keeps everything off RescueTime
+func TestBenchmark(t *testing.T) {
+	var legacy, native atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "offline_time_post") {
+			// Every other legacy submission is rejected
+			if legacy.Add(1)%2 == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		} else {
+			native.Add(1)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("legacy-key", "", "data-key")
+	client.UseServer(server.URL)
+	var progress int
+	results := client.Benchmark(context.Background(), BenchmarkSummary(time.Now()), 4, func(string, int, time.Duration, error) { progress++ })
+
+	if len(results) != 2 || results[0].Endpoint != "legacy" || results[1].Endpoint != "native" {
+		t.Fatalf("Expected legacy then native results, got %+v", results)
+	}
+	if results[0].Requests != 4 || results[0].Errors != 2 || results[0].ErrorRate() != 0.5 || results[0].LastError == nil {
+		t.Errorf("Expected 2 of 4 legacy submissions to fail, got %+v", results[0])
+	}
+	if results[1].Requests != 4 || results[1].Errors != 0 || results[1].Min <= 0 || results[1].P95 < results[1].Min {
+		t.Errorf("Expected 4 timed native submissions, got %+v", results[1])
+	}
+	if legacy.Load() != 4 || native.Load() != 4 || progress != 8 {
+		t.Errorf("Expected 4 requests per endpoint and 8 progress calls, got %d, %d, %d", legacy.Load(), native.Load(), progress)
+	}
+	if text := results[0].String(); !strings.HasPrefix(text, "legacy: 4 submissions, min ") || !strings.HasSuffix(text, "50% errors") {
+		t.Errorf("Unexpected description %q", text)
+	}
+
+	// Only the endpoints there are keys for
+	if results := NewClient("legacy-key", "", "").Benchmark(context.Background(), BenchmarkSummary(time.Now()), 0, nil); len(results) != 1 || results[0].Endpoint != "legacy" {
+		t.Errorf("Expected only the legacy endpoint without native keys, got %+v", results)
+	}
+}
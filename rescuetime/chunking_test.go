@@ -139,3 +139,63 @@ func TestChunkSizeIsUnderLimit(t *testing.T) {
 		t.Errorf("chunkSize (%v) seems too conservative, should be closer to %v", chunkSize, maxOfflineDuration)
 	}
 }
+
+// TestSummaryToUserClientEvents tests that a summary longer than the native event limit
+// becomes contiguous events with no gap or overlap that add up to the summary
+func TestSummaryToUserClientEvents(t *testing.T) {
+	start := time.Date(2025, 10, 31, 8, 0, 0, 0, time.UTC)
+	summary := ActivitySummary{
+		AppClass:        "code",
+		ActivityDetails: "main.go",
+		TotalDuration:   3*time.Hour + 50*time.Minute,
+		SessionCount:    2,
+		FirstSeen:       start,
+		LastSeen:        start.Add(4 * time.Hour),
+	}
+
+	events := SummaryToUserClientEvents(summary, time.Hour)
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	var total time.Duration
+	next := start
+	ids := make(map[string]bool)
+	for i, payload := range events {
+		event := payload.UserClientEvent
+		eventStart, err := time.Parse(time.RFC3339, event.StartTime)
+		if err != nil {
+			t.Fatalf("event %d: invalid start_time %q", i, event.StartTime)
+		}
+		eventEnd, err := time.Parse(time.RFC3339, event.EndTime)
+		if err != nil {
+			t.Fatalf("event %d: invalid end_time %q", i, event.EndTime)
+		}
+		if !eventStart.Equal(next) {
+			t.Errorf("event %d: expected to start at %v (the previous end), got %v", i, next, eventStart)
+		}
+		if eventEnd.Sub(eventStart) > time.Hour {
+			t.Errorf("event %d: %v exceeds the 1h limit", i, eventEnd.Sub(eventStart))
+		}
+		if event.Application != "code" || event.WindowTitle != "main.go" {
+			t.Errorf("event %d: expected code/main.go, got %s/%s", i, event.Application, event.WindowTitle)
+		}
+		ids[event.ClientEventID] = true
+		total += eventEnd.Sub(eventStart)
+		next = eventEnd
+	}
+	if total != summary.TotalDuration {
+		t.Errorf("Expected the events to total %v, got %v", summary.TotalDuration, total)
+	}
+	if len(ids) != len(events) {
+		t.Errorf("Expected a distinct client_event_id per event, got %d for %d events", len(ids), len(events))
+	}
+
+	// Within the limit, or with no limit, the summary is one event
+	if got := SummaryToUserClientEvents(summary, 4*time.Hour); len(got) != 1 || got[0] != SummaryToUserClientEvent(summary) {
+		t.Errorf("Expected one unchanged event within the limit, got %+v", got)
+	}
+	if got := SummaryToUserClientEvents(summary, 0); len(got) != 1 {
+		t.Errorf("Expected one event without a limit, got %d", len(got))
+	}
+}
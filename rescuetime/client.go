@@ -20,15 +20,23 @@ package rescuetime
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
 	"github.com/fatih/color"
 )
 
@@ -36,9 +44,22 @@ import (
 const (
 	maxAPIRetries      = 3
 	baseRetryDelay     = 1 * time.Second
+	maxRetryAfter      = 5 * time.Minute // longest Retry-After honored on a 429
 	apiTimeout         = 10 * time.Second
 	maxOfflineDuration = 4 * time.Hour       // RescueTime API limit for offline time
 	chunkSize          = 3*time.Hour + 55*time.Minute // Chunk size for splitting long sessions (slightly under 4h for safety)
+	legacyAPIURL       = "https://www.rescuetime.com/anapi/offline_time_post"
+	analyticAPIURL     = "https://www.rescuetime.com/anapi/data"
+	nativeAPIBase      = "https://api.rescuetime.com" // native API and activation, see Profile
+	officialUserAgent  = "RescueTime/2.16.5.1 (Linux)" // the desktop client's, sent to the native API
+
+	// DefaultLegacyMinDuration is the shortest activity the legacy offline time API records
+	DefaultLegacyMinDuration = 5 * time.Minute
+
+	// DefaultMaxNativeEventDuration is the longest single user_client_events event NewClient
+	// allows. The endpoint is undocumented and the desktop client sends short events, so
+	// longer spans are split rather than relying on it accepting hours-long events.
+	DefaultMaxNativeEventDuration = time.Hour
 )
 
 // ActivitySummary represents aggregated time spent in an application.
@@ -46,10 +67,68 @@ const (
 type ActivitySummary struct {
 	AppClass        string        `json:"app_class"`
 	ActivityDetails string        `json:"activity_details"`
+	RawTitle        string        `json:"raw_title,omitempty"` // unnormalized title for the native window_title
 	TotalDuration   time.Duration `json:"total_duration"`
 	SessionCount    int           `json:"session_count"`
 	FirstSeen       time.Time     `json:"first_seen"`
 	LastSeen        time.Time     `json:"last_seen"`
+
+	// Context is the label the time was tracked under (e.g. work or personal), "" for none.
+	// RescueTime payloads prefix it to the application, see ActivityName.
+	Context string `json:"context,omitempty"`
+
+	// Session length statistics, maintained by ObserveSession. They're for analysis
+	// (PostgreSQL, webhooks, the console summary); RescueTime payloads don't include them.
+	MaxSessionDuration  time.Duration `json:"max_session_duration"`
+	AvgSessionDuration  time.Duration `json:"avg_session_duration"`
+	LongestSessionStart time.Time     `json:"longest_session_start"`
+
+	// Coverage is the duration-weighted share of expected polls that saw the sessions, 0 to 1,
+	// maintained by ObserveCoverage; 0 when unknown. Like the statistics, it's for analysis.
+	Coverage       float64 `json:"coverage,omitempty"`
+	coverageWeight time.Duration
+
+	// MaximizedDuration is the part of TotalDuration the windows were maximized or fullscreen,
+	// when the tracker observes window geometry; 0 otherwise. See MaximizedShare.
+	MaximizedDuration time.Duration `json:"maximized_duration,omitempty"`
+
+	// From the application's desktop entry, when one matches AppClass, for dashboards
+	// ("Visual Studio Code" for code). RescueTime payloads and summary keys use AppClass.
+	DisplayName string `json:"display_name,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	DesktopID   string `json:"desktop_id,omitempty"`
+}
+
+// ObserveSession adds one session to the summary's total, count and running session statistics
+func (s *ActivitySummary) ObserveSession(start time.Time, duration time.Duration) {
+	s.TotalDuration += duration
+	s.SessionCount++
+	if duration > s.MaxSessionDuration {
+		s.MaxSessionDuration = duration
+		s.LongestSessionStart = start
+	}
+	s.AvgSessionDuration = s.TotalDuration / time.Duration(s.SessionCount)
+}
+
+// ObserveCoverage folds one session's poll coverage into Coverage, weighted by its duration,
+// so a long well-sampled session outweighs a few short gappy ones. Sessions of unknown
+// coverage (0) are left out.
+func (s *ActivitySummary) ObserveCoverage(duration time.Duration, coverage float64) {
+	if coverage <= 0 || duration <= 0 {
+		return
+	}
+	weight := s.coverageWeight + duration
+	s.Coverage = (s.Coverage*float64(s.coverageWeight) + coverage*float64(duration)) / float64(weight)
+	s.coverageWeight = weight
+}
+
+// MaximizedShare returns the share of TotalDuration the windows filled the screen, 0 to 1,
+// e.g. focused work in a maximized editor against a small reference window beside it
+func (s *ActivitySummary) MaximizedShare() float64 {
+	if s.TotalDuration <= 0 {
+		return 0
+	}
+	return min(1, float64(s.MaximizedDuration)/float64(s.TotalDuration))
 }
 
 // RescueTimePayload represents the data structure for RescueTime's legacy offline time API.
@@ -78,6 +157,7 @@ type UserClientEvent struct {
 	EndTime          string `json:"end_time"`          // RFC 3339 format: 2025-09-30T12:01:00Z
 	WindowTitle      string `json:"window_title"`      // window title
 	Application      string `json:"application"`       // application class (redundant with event_description)
+	ClientEventID    string `json:"client_event_id,omitempty"` // idempotency key, dropped if the API rejects it
 }
 
 // ActivationRequest represents the payload for the /activate endpoint.
@@ -100,6 +180,83 @@ type Client struct {
 	AccountKey string // Native API account key
 	DataKey    string // Native API data key (Bearer token)
 	DebugMode  bool   // Enable debug logging
+
+	// PayloadMode selects how legacy payloads express length: PayloadModeDuration
+	// (default, minutes of tracked time) or PayloadModeEndTime (FirstSeen to LastSeen span).
+	PayloadMode string
+
+	// SubmitOrder selects the order SubmitActivities sends summaries in: SubmitOrderUnordered
+	// (default), SubmitOrderLongestFirst or SubmitOrderChronological; see SubmissionOrder
+	SubmitOrder string
+
+	// MinNativeDuration and MinLegacyDuration skip summaries shorter than this on the native
+	// and legacy paths. NewClient sets 0 and DefaultLegacyMinDuration: user_client_events
+	// takes short events, while the offline time API doesn't record them. A short summary
+	// the native API fails to take isn't sent through the legacy fallback.
+	MinNativeDuration time.Duration
+	MinLegacyDuration time.Duration
+
+	// MaxNativeEventDuration splits a summary longer than this into sequential native
+	// events, each starting where the previous one ended; see SummaryToUserClientEvents.
+	// NewClient sets DefaultMaxNativeEventDuration; 0 sends every summary as one event.
+	MaxNativeEventDuration time.Duration
+
+	// ActiveHours, if set, limits submission to activity that started within the schedule
+	// (e.g. working hours). Other summaries are skipped whole; see SkipReason. A summary running
+	// across the schedule's edge is judged by its start, so callers with per-session data
+	// should leave out the sessions outside the schedule before summarizing, as the tracker does.
+	ActiveHours *Schedule
+
+	// Queue, if set, receives summaries that are still unsent when Close is called
+	Queue PendingQueue
+
+	// OnRequest and OnResponse, if set, are called for each SubmitLegacy and SubmitNative
+	// attempt, e.g. to see which auth path the native API took and what came back without
+	// DebugMode's full output. The URL and headers are as sent, credentials included.
+	// OnResponse isn't called for a request that got no response.
+	OnRequest  func(method, url string, headers http.Header)
+	OnResponse func(status int, body []byte)
+
+	// Sleep, if set, waits out the backoff between SubmitLegacy and SubmitNative attempts in
+	// place of a timer, e.g. so tests don't wait. It returns ctx's error if ctx ends first.
+	Sleep func(ctx context.Context, delay time.Duration) error
+
+	// Profile sets the native API User-Agent, endpoints and authentication order.
+	// NewClient sets the ProfileDesktop preset; see LookupProfile.
+	Profile Profile
+
+	// NativeUserAgentVersion appends this tracker's version to the profile's User-Agent on
+	// native API requests, e.g. "RescueTime/2.16.5.1 (Linux) (rescuetime-linux-mutter
+	// v1.2.0)". Off by default: the undocumented API may key on it.
+	NativeUserAgentVersion bool
+
+	// AnonymizeApps sends every application class as a keyed hash of itself (see
+	// internal/anonymize), so RescueTime totals time per application without learning which
+	// ones were used. AnonymizeTitles is an anonymize.Titles* mode for the window titles,
+	// TitlesDrop if empty. Only the payloads change: summaries, queues and logs keep cleartext.
+	AnonymizeApps   bool
+	AnonymizeSecret string
+	AnonymizeTitles string
+
+	// Credentials, if set, lets the client refresh native API keys that the server stopped
+	// accepting (e.g. after a password change) by calling Activate; see refreshNativeKeys
+	Credentials CredentialStore
+
+	inflight  sync.WaitGroup             // running SubmitActivitiesContext calls
+	pendingMu sync.Mutex                 // guards pending
+	pending   map[string]ActivitySummary // eligible summaries not yet sent or rejected, by IdempotencyKey
+
+	nativeRejectsEventID atomic.Bool            // set once the native API is known to reject client_event_id
+	nativeAuth           atomic.Pointer[string] // NativeAuth* strategy that last succeeded, tried first
+	legacyURL            string // offline_time_post endpoint, overridden in tests (default legacyAPIURL)
+	analyticURL          string // analytic data endpoint used by Ping, overridden in tests (default analyticAPIURL)
+	nativeURL            string // user_client_events endpoint, overridden in tests (default from Profile)
+	activateURL          string // activation endpoint, overridden in tests (default from Profile)
+}
+
+// PendingQueue persists summaries that couldn't be submitted so they can be retried later
+type PendingQueue interface {
+	Append(summaries map[string]ActivitySummary) error
 }
 
 // NewClient creates a new RescueTime API client.
@@ -119,14 +276,34 @@ func NewClient(apiKey, accountKey, dataKey string) *Client {
 		dataKey = os.Getenv("RESCUE_TIME_DATA_KEY")
 	}
 
+	profile, _ := LookupProfile(ProfileDesktop)
 	return &Client{
-		APIKey:     apiKey,
-		AccountKey: accountKey,
-		DataKey:    dataKey,
-		DebugMode:  false,
+		APIKey:            apiKey,
+		AccountKey:        accountKey,
+		DataKey:           dataKey,
+		DebugMode:         false,
+		MinNativeDuration: 0,
+		MinLegacyDuration: DefaultLegacyMinDuration,
+
+		MaxNativeEventDuration: DefaultMaxNativeEventDuration,
+		Profile:                profile,
 	}
 }
 
+// hasNativeCredentials reports whether summaries go to the native API first
+func (c *Client) hasNativeCredentials() bool {
+	return c.DataKey != "" || c.AccountKey != ""
+}
+
+// MinSubmitDuration returns the minimum of the path summaries are submitted through first:
+// MinNativeDuration with native credentials, MinLegacyDuration without
+func (c *Client) MinSubmitDuration() time.Duration {
+	if c.hasNativeCredentials() {
+		return c.MinNativeDuration
+	}
+	return c.MinLegacyDuration
+}
+
 // debugLog prints debug messages if debug mode is enabled
 func (c *Client) debugLog(format string, args ...interface{}) {
 	if c.DebugMode {
@@ -143,13 +320,10 @@ func SummaryToPayload(summary ActivitySummary) RescueTimePayload {
 	// Format start time as "YYYY-MM-DD HH:MM:SS"
 	startTimeFormatted := summary.FirstSeen.Format("2006-01-02 15:04:05")
 
-	// For offline time API, activity_name is the application name
-	activityName := summary.AppClass
-
 	return RescueTimePayload{
 		StartTime:       startTimeFormatted,
 		Duration:        durationMinutes,
-		ActivityName:    activityName,
+		ActivityName:    summary.ActivityName(),
 		ActivityDetails: summary.ActivityDetails,
 	}
 }
@@ -164,11 +338,92 @@ func SummaryToPayloadWithEndTime(summary ActivitySummary) RescueTimePayload {
 	return RescueTimePayload{
 		StartTime:       startTimeFormatted,
 		EndTime:         endTimeFormatted,
-		ActivityName:    summary.AppClass,
+		ActivityName:    summary.ActivityName(),
 		ActivityDetails: summary.ActivityDetails,
 	}
 }
 
+// Legacy payload modes for Client.PayloadMode and SummaryToPayloadMode
+const (
+	PayloadModeDuration = "duration" // send duration in minutes (default)
+	PayloadModeEndTime  = "end-time" // send end_time instead of duration
+)
+
+// SummaryToPayloadMode converts a summary to a legacy payload using the given mode.
+// An empty mode is treated as PayloadModeDuration. In end-time mode the payload covers
+// the wall-clock span from FirstSeen to LastSeen, so summaries spanning more than
+// 4 hours fail validation even if their tracked time is shorter.
+func SummaryToPayloadMode(summary ActivitySummary, mode string) RescueTimePayload {
+	if mode == PayloadModeEndTime {
+		return SummaryToPayloadWithEndTime(summary)
+	}
+	return SummaryToPayload(summary)
+}
+
+// ValidPayloadMode reports whether mode is a supported legacy payload mode
+func ValidPayloadMode(mode string) bool {
+	return mode == "" || mode == PayloadModeDuration || mode == PayloadModeEndTime
+}
+
+// EncodeJSON encodes v like json.Marshal but without HTML escaping, so a window title such
+// as "<b>R&D</b>" reaches RescueTime, webhooks and the logs exactly as written rather than as
+// "\u003cb\u003eR\u0026D...". Every payload and payload preview goes through it (or
+// EncodeJSONIndent) so the legacy, native and webhook bodies encode titles the same way.
+func EncodeJSON(v interface{}) ([]byte, error) {
+	return EncodeJSONIndent(v, "")
+}
+
+// EncodeJSONIndent is EncodeJSON with each level indented by indent, for console previews
+func EncodeJSONIndent(v interface{}, indent string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	// Remove the trailing newline that Encode adds
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// IdempotencyKey derives a deterministic UUID-formatted key from the JSON encoding of v.
+// The same logical content always yields the same key, including across process restarts,
+// so receivers can deduplicate retried or re-queued deliveries.
+func IdempotencyKey(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Fall back to the Go representation; still deterministic for the same value
+		data = []byte(fmt.Sprintf("%#v", v))
+	}
+	sum := sha256.Sum256(data)
+
+	// Format the first 16 bytes as an RFC 9562 version 8 (custom) UUID
+	b := sum[:16]
+	b[6] = (b[6] & 0x0f) | 0x80
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ActivityName returns the application as RescueTime payloads name it: AppClass, prefixed
+// with the context when there is one ("[work] firefox"), so RescueTime's reports keep the
+// same application's work and personal time apart
+func (s ActivitySummary) ActivityName() string {
+	if s.Context == "" {
+		return s.AppClass
+	}
+	return "[" + s.Context + "] " + s.AppClass
+}
+
+// WindowTitle returns the title for the native event's window_title. ActivityDetails may
+// be normalized for display and the other payloads, so this is RawTitle, falling back to
+// ActivityDetails for summaries that don't carry one (e.g. from older queue files).
+func (s ActivitySummary) WindowTitle() string {
+	if s.RawTitle != "" {
+		return s.RawTitle
+	}
+	return s.ActivityDetails
+}
+
 // SummaryToUserClientEvent converts an ActivitySummary to UserClientEventPayload format (native API).
 func SummaryToUserClientEvent(summary ActivitySummary) UserClientEventPayload {
 	// Calculate end time: start time + total duration
@@ -178,17 +433,90 @@ func SummaryToUserClientEvent(summary ActivitySummary) UserClientEventPayload {
 	startTimeFormatted := summary.FirstSeen.UTC().Format(time.RFC3339)
 	endTimeFormatted := endTime.UTC().Format(time.RFC3339)
 
-	return UserClientEventPayload{
-		UserClientEvent: UserClientEvent{
-			// EventDescription: summary.AppClass,
-			// Reduce redundancy by leaving EventDescription empty
-			EventDescription: "",
-			StartTime:        startTimeFormatted,
-			EndTime:          endTimeFormatted,
-			WindowTitle:      summary.ActivityDetails,
-			Application:      summary.AppClass, // Same as EventDescription
-		},
+	event := UserClientEvent{
+		// EventDescription: summary.AppClass,
+		// Reduce redundancy by leaving EventDescription empty
+		EventDescription: "",
+		StartTime:        startTimeFormatted,
+		EndTime:          endTimeFormatted,
+		WindowTitle:      summary.WindowTitle(),
+		Application:      summary.ActivityName(), // Same as EventDescription
+	}
+	// With a context, the description carries the prefixed name too, so reports that show
+	// either one separate the contexts
+	if summary.Context != "" {
+		event.EventDescription = summary.ActivityName()
+	}
+	// Key is computed before it is set, so it only depends on the event content
+	event.ClientEventID = IdempotencyKey(event)
+
+	return UserClientEventPayload{UserClientEvent: event}
+}
+
+// SummaryToUserClientEvents converts an ActivitySummary to one native event per span of at
+// most maxDuration, in order. Each event starts where the previous one ended, so together
+// they cover the summary exactly. A maxDuration of 0 or less returns a single event.
+func SummaryToUserClientEvents(summary ActivitySummary, maxDuration time.Duration) []UserClientEventPayload {
+	spans := splitNativeEvent(summary, maxDuration)
+	payloads := make([]UserClientEventPayload, len(spans))
+	for i, span := range spans {
+		payloads[i] = SummaryToUserClientEvent(span)
+	}
+	return payloads
+}
+
+// splitNativeEvent splits a summary into contiguous spans of at most maxDuration, like
+// splitLongDurationSummaries does for the legacy limit
+func splitNativeEvent(summary ActivitySummary, maxDuration time.Duration) []ActivitySummary {
+	if maxDuration <= 0 || summary.TotalDuration <= maxDuration {
+		return []ActivitySummary{summary}
+	}
+
+	var spans []ActivitySummary
+	for offset := time.Duration(0); offset < summary.TotalDuration; offset += maxDuration {
+		duration := summary.TotalDuration - offset
+		if duration > maxDuration {
+			duration = maxDuration
+		}
+		start := summary.FirstSeen.Add(offset)
+		spans = append(spans, ActivitySummary{
+			AppClass:        summary.AppClass,
+			Context:         summary.Context,
+			ActivityDetails: summary.ActivityDetails,
+			RawTitle:        summary.RawTitle,
+			TotalDuration:   duration,
+			SessionCount:    1,
+			FirstSeen:       start,
+			LastSeen:        start.Add(duration),
+		})
+	}
+	return spans
+}
+
+// joinNativeEvents returns the summary covering contiguous spans from splitNativeEvent, used
+// for the part of a summary whose events weren't sent
+func joinNativeEvents(summary ActivitySummary, spans []ActivitySummary) ActivitySummary {
+	if len(spans) == 0 {
+		return summary
+	}
+	summary.FirstSeen = spans[0].FirstSeen
+	summary.LastSeen = spans[len(spans)-1].LastSeen
+	summary.TotalDuration = 0
+	for _, span := range spans {
+		summary.TotalDuration += span.TotalDuration
+	}
+	return summary
+}
+
+// submitNativeEvents sends spans as native events in order, stopping at the first error.
+// It returns how many were sent.
+func (c *Client) submitNativeEvents(ctx context.Context, spans []ActivitySummary) (int, error) {
+	for i, span := range spans {
+		if err := c.SubmitNativeContext(ctx, SummaryToUserClientEvent(span)); err != nil {
+			return i, err
+		}
 	}
+	return len(spans), nil
 }
 
 // ValidatePayload checks if a RescueTimePayload is valid before submission.
@@ -247,11 +575,85 @@ func ValidatePayload(payload RescueTimePayload) error {
 	return nil
 }
 
+// parseRetryAfter returns how long a Retry-After header value (delay seconds or an HTTP
+// date) asks to wait from now, capped at maxRetryAfter. It returns 0 for a missing or
+// invalid value, or a date already past, leaving the default backoff in place.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	}
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+// sleepContext waits for the given delay, returning early with the context's error if it is cancelled
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sleep waits out a retry backoff with Sleep, if set
+func (c *Client) sleep(ctx context.Context, delay time.Duration) error {
+	if c.Sleep != nil {
+		return c.Sleep(ctx, delay)
+	}
+	return sleepContext(ctx, delay)
+}
+
+// requestError reports a request that got no response. The *url.Error from http.Client
+// names the URL, which carries the API key as ?key=, so only its cause is kept.
+func requestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Errorf("request failed: %v", err)
+}
+
+// traceRequest passes req to OnRequest, if set, with a copy of its headers
+func (c *Client) traceRequest(req *http.Request) {
+	if c.OnRequest != nil {
+		c.OnRequest(req.Method, req.URL.String(), req.Header.Clone())
+	}
+}
+
+// traceResponse passes a response's status and body to OnResponse, if set
+func (c *Client) traceResponse(status int, body []byte) {
+	if c.OnResponse != nil {
+		c.OnResponse(status, body)
+	}
+}
+
 // SubmitLegacy submits activity data to RescueTime's legacy offline_time_post API with retry logic.
 // Official API documentation: https://www.rescuetime.com/anapi/offline_time_post
 // Note: API is limited to 4 hour maximum duration and cannot post to future dates.
 func (c *Client) SubmitLegacy(payload RescueTimePayload) error {
+	return c.SubmitLegacyContext(context.Background(), payload)
+}
+
+// SubmitLegacyContext is like SubmitLegacy but stops retrying and aborts in-flight
+// requests when ctx is cancelled.
+func (c *Client) SubmitLegacyContext(ctx context.Context, payload RescueTimePayload) error {
 	var lastErr error
+	payload = c.AnonymizeLegacyPayload(payload)
 
 	// Check if API key is present
 	if c.APIKey == "" {
@@ -263,55 +665,65 @@ func (c *Client) SubmitLegacy(payload RescueTimePayload) error {
 		c.debugLog("API key first 5 chars: %s..., last 5 chars: ...%s", c.APIKey[:5], c.APIKey[len(c.APIKey)-5:])
 	}
 
+	var retryAfter time.Duration // from a 429's Retry-After, replaces the next backoff
+
 	for attempt := 0; attempt < maxAPIRetries; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s
+			// Exponential backoff: 1s, 2s, 4s, unless the server said how long to wait
 			delay := baseRetryDelay * time.Duration(math.Pow(2, float64(attempt-1)))
+			if retryAfter > 0 {
+				delay, retryAfter = retryAfter, 0
+			}
 			color.Yellow("Retrying in %v... (attempt %d/%d)", delay, attempt+1, maxAPIRetries)
-			time.Sleep(delay)
+			if err := c.sleep(ctx, delay); err != nil {
+				return fmt.Errorf("submission cancelled: %v (last error: %v)", err, lastErr)
+			}
 		}
 
-		// Convert payload to JSON (disable HTML escaping)
-		buffer := &bytes.Buffer{}
-		encoder := json.NewEncoder(buffer)
-		encoder.SetEscapeHTML(false)
-		err := encoder.Encode(payload)
+		// Convert payload to JSON (titles are sent as-is, see EncodeJSON)
+		jsonData, err := EncodeJSON(payload)
 		if err != nil {
 			return fmt.Errorf("failed to marshal payload: %v", err)
 		}
 
-		// Remove trailing newline that Encode adds
-		jsonData := bytes.TrimSpace(buffer.Bytes())
-
 		c.debugLog("Submitting payload: %s", string(jsonData))
 
 		// Create request - API key goes in query parameter per official docs
-		url := fmt.Sprintf("https://www.rescuetime.com/anapi/offline_time_post?key=%s", c.APIKey)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		endpoint := c.legacyURL
+		if endpoint == "" {
+			endpoint = legacyAPIURL
+		}
+		url := fmt.Sprintf("%s?key=%s", endpoint, c.APIKey)
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 		if err != nil {
 			lastErr = fmt.Errorf("failed to create request: %v", err)
 			continue
 		}
 
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "rescuetime-linux-mutter/1.0")
+		req.Header.Set("User-Agent", version.UserAgent())
 		req.Header.Set("Accept", "*/*")
 
-		c.debugLog("Sending POST to: %s", "https://www.rescuetime.com/anapi/offline_time_post?key=***")
+		c.debugLog("Sending POST to: %s?key=***", endpoint)
 		c.debugLog("Request headers: Content-Type=%s, User-Agent=%s", req.Header.Get("Content-Type"), req.Header.Get("User-Agent"))
 		c.debugLog("Request body: %s", string(jsonData))
 
 		// Send request
+		c.traceRequest(req)
 		client := &http.Client{Timeout: apiTimeout}
 		resp, err := client.Do(req)
 		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
+			lastErr = requestError(err)
+			if ctx.Err() != nil {
+				return lastErr
+			}
 			continue
 		}
 
 		// Read response body
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		c.traceResponse(resp.StatusCode, body)
 
 		c.debugLog("Response status: %d", resp.StatusCode)
 		c.debugLog("Response headers: %v", resp.Header)
@@ -330,13 +742,18 @@ func (c *Client) SubmitLegacy(payload RescueTimePayload) error {
 		}
 
 		lastErr = fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-		// Don't retry on client errors (4xx) - per API docs, 400 indicates bad request
+		// Rate limited: retry, after the server's Retry-After if it sent one
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			continue
+		}
+		// Don't retry on other client errors (4xx) - per API docs, 400 indicates bad request
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
 			return lastErr
 		}
 	}
 
-	return fmt.Errorf("failed after %d attempts: %v", maxAPIRetries, lastErr)
+	return fmt.Errorf("%w: failed after %d attempts: %v", ErrUnavailable, maxAPIRetries, lastErr)
 }
 
 // SubmitNative submits activity data to RescueTime's native user_client_events API.
@@ -344,30 +761,50 @@ func (c *Client) SubmitLegacy(payload RescueTimePayload) error {
 // It has been reverse-engineered from the official desktop client.
 // For official API support, use SubmitLegacy (offline_time_post) instead.
 func (c *Client) SubmitNative(payload UserClientEventPayload) error {
+	return c.SubmitNativeContext(context.Background(), payload)
+}
+
+// SubmitNativeContext is like SubmitNative but stops retrying and aborts in-flight
+// requests when ctx is cancelled.
+func (c *Client) SubmitNativeContext(ctx context.Context, payload UserClientEventPayload) error {
 	var lastErr error
-	var tryBearerAuth bool
+	authOrder := c.nativeAuthOrder()
+	auth := 0 // index into authOrder
+	c.debugLog("Native auth order: %s (remembered: %q)", strings.Join(authOrder, ", "), c.NativeAuth())
+
+	payload = c.anonymizeNativePayload(payload)
+	if c.nativeRejectsEventID.Load() {
+		payload.UserClientEvent.ClientEventID = ""
+	}
+	strippedEventID := false // retrying without client_event_id to see if it was the problem
+
+	var retryAfter time.Duration // from a 429's Retry-After, replaces the next backoff
 
 	for attempt := 0; attempt < maxAPIRetries; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s
+			// Exponential backoff: 1s, 2s, 4s, unless the server said how long to wait
 			delay := baseRetryDelay * time.Duration(math.Pow(2, float64(attempt-1)))
+			if retryAfter > 0 {
+				delay, retryAfter = retryAfter, 0
+			}
 			color.Yellow("Retrying in %v... (attempt %d/%d)", delay, attempt+1, maxAPIRetries)
-			time.Sleep(delay)
+			if err := c.sleep(ctx, delay); err != nil {
+				return fmt.Errorf("submission cancelled: %v (last error: %v)", err, lastErr)
+			}
 		}
 
-		// Convert payload to JSON
-		jsonData, err := json.Marshal(payload)
+		// Convert payload to JSON (titles are sent as-is, see EncodeJSON)
+		jsonData, err := EncodeJSON(payload)
 		if err != nil {
 			return fmt.Errorf("failed to marshal payload: %v", err)
 		}
 
+		endpoint := c.nativeEndpoint()
 		var req *http.Request
 
-		// Try Bearer token auth if query param auth failed with 401
-		if tryBearerAuth {
+		if authOrder[auth] == NativeAuthBearer {
 			// Create request WITHOUT query parameter
-			url := "https://api.rescuetime.com/api/resource/user_client_events"
-			req, err = http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+			req, err = http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
 			if err != nil {
 				lastErr = fmt.Errorf("failed to create request: %v", err)
 				continue
@@ -385,13 +822,13 @@ func (c *Client) SubmitNative(payload UserClientEventPayload) error {
 				req.URL.RawQuery = fmt.Sprintf("key=%s", c.AccountKey)
 			}
 		} else {
-			// Try query parameter authentication first with account_key
+			// Query parameter authentication with account_key
 			authKey := c.AccountKey
 			if authKey == "" {
 				authKey = c.APIKey
 			}
-			url := fmt.Sprintf("https://api.rescuetime.com/api/resource/user_client_events?key=%s", authKey)
-			req, err = http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+			url := fmt.Sprintf("%s?key=%s", endpoint, authKey)
+			req, err = http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 			if err != nil {
 				lastErr = fmt.Errorf("failed to create request: %v", err)
 				continue
@@ -400,28 +837,34 @@ func (c *Client) SubmitNative(payload UserClientEventPayload) error {
 
 		// Set headers matching the official app
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-		req.Header.Set("User-Agent", "RescueTime/2.16.5.1 (Linux)")
+		req.Header.Set("User-Agent", c.nativeUserAgent())
 
 		// Send request
+		c.traceRequest(req)
 		client := &http.Client{Timeout: apiTimeout}
 		resp, err := client.Do(req)
 		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
+			lastErr = requestError(err)
+			if ctx.Err() != nil {
+				return lastErr
+			}
 			continue
 		}
 
 		// Read response body
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		c.traceResponse(resp.StatusCode, body)
 
 		// Check response status
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			authMethod := "query parameter"
-			if tryBearerAuth {
-				authMethod = "Bearer token"
+			c.rememberNativeAuth(authOrder[auth])
+			if strippedEventID {
+				color.Yellow("[WARNING] Native API accepted the event without client_event_id; leaving it out of later submissions")
+				c.nativeRejectsEventID.Store(true)
 			}
 			color.New(color.FgGreen, color.Bold).Printf("[SUCCESS] Submitted to RescueTime via %s: %s (%s to %s)\n",
-				authMethod,
+				describeNativeAuth(authOrder[auth]),
 				payload.UserClientEvent.Application,
 				payload.UserClientEvent.StartTime,
 				payload.UserClientEvent.EndTime)
@@ -430,19 +873,48 @@ func (c *Client) SubmitNative(payload UserClientEventPayload) error {
 
 		lastErr = fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
 
-		// If we got 401 with query param auth, try Bearer token auth next
-		if resp.StatusCode == 401 && !tryBearerAuth {
-			color.Yellow("[WARNING] Query parameter auth failed (401), trying Bearer token authentication...")
-			tryBearerAuth = true
+		// Rate limited: retry, after the server's Retry-After if it sent one
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 			continue
 		}
+
+		// The client_event_id field is not part of the official client's payload. If the API
+		// names it in a rejection, drop it for this and later submissions. Other rejections
+		// may or may not be about it, so retry without it and only drop it for later
+		// submissions if that succeeds.
+		if (resp.StatusCode == 400 || resp.StatusCode == 422) && payload.UserClientEvent.ClientEventID != "" {
+			if bytes.Contains(body, []byte("client_event_id")) {
+				color.Yellow("[WARNING] Native API rejected client_event_id (%d), retrying without idempotency key...", resp.StatusCode)
+				c.nativeRejectsEventID.Store(true)
+			} else {
+				color.Yellow("[WARNING] Native API returned %d, retrying without idempotency key in case it was the cause...", resp.StatusCode)
+				strippedEventID = true
+			}
+			payload.UserClientEvent.ClientEventID = ""
+			continue
+		}
+
+		if resp.StatusCode == 401 {
+			c.forgetNativeAuth(authOrder[auth])
+		}
+		// If we got 401, try the next auth method
+		if resp.StatusCode == 401 && auth+1 < len(authOrder) {
+			color.Yellow("[WARNING] Native API refused %s auth (401), trying %s authentication...", describeNativeAuth(authOrder[auth]), describeNativeAuth(authOrder[auth+1]))
+			auth++
+			continue
+		}
+		// Every auth method was refused: the keys themselves are no longer valid
+		if resp.StatusCode == 401 {
+			return fmt.Errorf("%w: %v", ErrNativeUnauthorized, lastErr)
+		}
 		// Don't retry on other client errors (4xx)
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
 			return lastErr
 		}
 	}
 
-	return fmt.Errorf("failed after %d attempts: %v", maxAPIRetries, lastErr)
+	return fmt.Errorf("%w: failed after %d attempts: %v", ErrUnavailable, maxAPIRetries, lastErr)
 }
 
 // splitLongDurationSummaries splits summaries that exceed the 4-hour API limit into chunks.
@@ -481,7 +953,9 @@ func splitLongDurationSummaries(summaries map[string]ActivitySummary) map[string
 			// Create chunk summary
 			chunk := ActivitySummary{
 				AppClass:        summary.AppClass,
+				Context:         summary.Context,
 				ActivityDetails: summary.ActivityDetails,
+				RawTitle:        summary.RawTitle,
 				TotalDuration:   chunkDuration,
 				SessionCount:    1, // Each chunk is treated as one logical submission
 				FirstSeen:       chunkStart,
@@ -495,147 +969,421 @@ func splitLongDurationSummaries(summaries map[string]ActivitySummary) map[string
 	return result
 }
 
+// SkipReason explains why a summary would not be submitted, or returns "" if it is eligible.
+// SubmitActivities and dry-run previews both use it so they agree on what gets sent.
+func (c *Client) SkipReason(summary ActivitySummary) string {
+	_, reason := c.skipOutcome(summary)
+	return reason
+}
+
+// skipOutcome is SkipReason with the skipped-* outcome the reason falls under
+func (c *Client) skipOutcome(summary ActivitySummary) (outcome, reason string) {
+	if min := c.MinSubmitDuration(); summary.TotalDuration < min {
+		return OutcomeSkippedShort, fmt.Sprintf("duration %v is below the %v minimum", summary.TotalDuration.Round(time.Second), min)
+	}
+	if !c.inActiveHours(summary.FirstSeen) {
+		return OutcomeSkippedHours, fmt.Sprintf("started %s, outside active hours (%s)", summary.FirstSeen.Format("Mon 15:04"), c.ActiveHours)
+	}
+	return "", ""
+}
+
+// inActiveHours reports whether activity at t may be submitted (always true without ActiveHours)
+func (c *Client) inActiveHours(t time.Time) bool {
+	return c.ActiveHours == nil || c.ActiveHours.Contains(t)
+}
+
+// EligibleSummaries returns the summaries SubmitActivities would send: long summaries are
+// split into chunks under the 4-hour limit, overlapping ones are laid out by ResolveOverlaps,
+// and chunks that fail SkipReason are logged and dropped. skipped is the number dropped.
+func (c *Client) EligibleSummaries(summaries map[string]ActivitySummary) (eligible map[string]ActivitySummary, skipped int) {
+	eligible, result := c.ScreenSummaries(summaries)
+	return eligible, len(result.Outcomes)
+}
+
+// ScreenSummaries is EligibleSummaries with each dropped chunk recorded in result under
+// its skipped-* outcome, for previews that show the same buckets as a submission
+func (c *Client) ScreenSummaries(summaries map[string]ActivitySummary) (eligible map[string]ActivitySummary, result SubmissionResult) {
+	eligible = make(map[string]ActivitySummary)
+	resolved, adjustments := ResolveOverlaps(splitLongDurationSummaries(summaries))
+	for _, adjustment := range adjustments {
+		color.Yellow("[OVERLAP] %s\n", adjustment)
+	}
+	for key, summary := range resolved {
+		if outcome, reason := c.skipOutcome(summary); outcome != "" {
+			color.Yellow("[SKIPPED] %s: %s\n", summary.AppClass, reason)
+			result.Add(summary, outcome, reason)
+			continue
+		}
+		eligible[key] = summary
+	}
+	return eligible, result
+}
+
 // SubmitActivities submits all activity summaries to RescueTime.
 // Attempts native user_client_events API first if credentials are available,
 // falls back to offline_time_post API if native fails or credentials are missing.
-// Automatically splits summaries that exceed the 4-hour API limit into chunks.
+// Automatically splits summaries that exceed the 4-hour API limit into chunks, and sends
+// them in SubmitOrder.
 func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
+	c.SubmitActivitiesContext(context.Background(), summaries)
+}
+
+// SubmitActivitiesContext is like SubmitActivities but stops when ctx is cancelled.
+// It returns the eligible summaries that were not submitted because the context ended
+// (not yet attempted, or interrupted mid-request) so the caller can queue them for later.
+// With Credentials set, summaries whose native keys were rejected and couldn't be refreshed
+// are returned too, rather than sent through the legacy fallback.
+// Summaries that failed for other reasons are logged and not returned.
+func (c *Client) SubmitActivitiesContext(ctx context.Context, summaries map[string]ActivitySummary) map[string]ActivitySummary {
+	return c.SubmitActivitiesResult(ctx, summaries).Unsent
+}
+
+// SubmitActivitiesResult is SubmitActivitiesContext returning what happened to every
+// summary: which API took it, which failed, which were skipped and why, and which are
+// unsent. Failures RescueTime may accept later (ErrUnavailable) are also in Failed. The
+// buckets are printed at the end.
+func (c *Client) SubmitActivitiesResult(ctx context.Context, summaries map[string]ActivitySummary) SubmissionResult {
+	result := SubmissionResult{Unsent: make(map[string]ActivitySummary), Failed: make(map[string]ActivitySummary)}
 	if len(summaries) == 0 {
 		// No activities to submit - silence is fine, no need to spam logs
-		return
+		return result
 	}
 
-	// Split long-duration summaries into chunks (>4 hours → multiple <4h submissions)
-	summaries = splitLongDurationSummaries(summaries)
+	c.inflight.Add(1)
+	defer c.inflight.Done()
 
 	// Check if we have native API credentials
-	hasNativeCredentials := c.DataKey != "" || c.AccountKey != ""
-
-	// Pre-filter to count eligible activities
-	eligibleCount := 0
-	for _, summary := range summaries {
-		if summary.TotalDuration >= 5*time.Minute {
-			eligibleCount++
-		}
-	}
+	hasNativeCredentials := c.hasNativeCredentials()
 
 	color.New(color.FgCyan, color.Bold).Printf("\n=== Processing %d tracked activities ===\n", len(summaries))
-	if eligibleCount < len(summaries) {
-		color.Yellow("%d filtered out (<%d min duration requirement)\n", len(summaries)-eligibleCount, 5)
-	}
-	if eligibleCount == 0 {
+
+	// Split long-duration summaries into chunks (>4 hours → multiple <4h submissions), clip
+	// overlapping ones and drop short ones
+	summaries, screened := c.ScreenSummaries(summaries)
+	result.Outcomes = screened.Outcomes
+	defer func() { result.Print("RescueTime submission") }()
+	if len(summaries) == 0 {
 		color.Yellow("No activities meet submission criteria.\n")
-		return
+		return result
 	}
 
+	// Tracked until sent or rejected so Close can queue anything still in flight
+	c.trackPending(summaries)
+
 	if hasNativeCredentials {
 		color.Cyan("Attempting native API with legacy fallback\n")
 	} else {
 		color.Cyan("Using legacy offline time API\n")
 	}
 
-	successCount := 0
-	failCount := 0
-	skippedCount := 0
-	nativeSuccessCount := 0
-	legacyFallbackCount := 0
+	refresh := keyRefreshUntried
+	unsent := func(key string, summary ActivitySummary, reason string) {
+		result.Unsent[key] = summary
+		result.Add(summary, OutcomeUnsent, reason)
+	}
 
-	for _, summary := range summaries {
-		// RescueTime API appears to require minimum 5 minutes duration
-		if summary.TotalDuration < 5*time.Minute {
-			c.debugLog("Skipping %s: duration %v is less than 5 minutes", summary.AppClass, summary.TotalDuration)
-			skippedCount++
+	for _, key := range SubmissionOrder(summaries, c.SubmitOrder) {
+		summary := summaries[key]
+
+		// Out of time - leave the rest for the caller to queue
+		if ctx.Err() != nil {
+			unsent(key, summary, "deadline reached before it was sent")
 			continue
 		}
 
 		var err error
-		usedFallback := false
+		outcome := OutcomeFailedLegacy
+		reason := ""
+
+		// The keys were rejected and refreshing them failed, so native can't succeed this call
+		if hasNativeCredentials && refresh == keyRefreshFailed {
+			unsent(key, summary, "native API keys rejected and not refreshed")
+			continue
+		}
 
 		if hasNativeCredentials {
 			// Try native API first
 			color.Cyan("[ATTEMPT] Trying native API for %s...\n", summary.AppClass)
-			payload := SummaryToUserClientEvent(summary)
-			err = c.SubmitNative(payload)
+			spans := splitNativeEvent(summary, c.MaxNativeEventDuration)
+			if len(spans) > 1 {
+				c.debugLog("%s duration (%v) exceeds the %v native event limit, sending %d events", summary.AppClass, summary.TotalDuration, c.MaxNativeEventDuration, len(spans))
+			}
+			var sent int
+			sent, err = c.submitNativeEvents(ctx, spans)
+
+			// Rejected keys: refresh them once per call and retry, or give up and queue
+			if errors.Is(err, ErrNativeUnauthorized) && c.Credentials != nil && ctx.Err() == nil {
+				if refresh == keyRefreshUntried {
+					refresh = c.refreshNativeKeys()
+					if refresh == keyRefreshSucceeded {
+						var more int
+						more, err = c.submitNativeEvents(ctx, spans[sent:])
+						sent += more
+					}
+				}
+				if refresh == keyRefreshFailed {
+					color.Yellow("[WARNING] Native API keys were rejected and couldn't be refreshed, queuing the remaining activities\n")
+					if sent > 0 {
+						summary = c.replacePending(summary, joinNativeEvents(summary, spans[sent:]))
+					}
+					unsent(key, summary, "native API keys rejected and not refreshed")
+					continue
+				}
+			}
 
-			if err != nil {
+			// Events already sent stay sent: only the rest falls back or is queued
+			if err != nil && sent > 0 {
+				summary = c.replacePending(summary, joinNativeEvents(summary, spans[sent:]))
+			}
+
+			if err != nil && ctx.Err() == nil && summary.TotalDuration < c.MinLegacyDuration {
+				// Too short for the offline time API to record: the fallback would lose it anyway
+				color.Yellow("[WARNING] Native API failed for %s: %v\n", summary.AppClass, err)
+				color.Yellow("[SKIPPED] %s: duration %v is below the %v legacy minimum, not falling back\n", summary.AppClass, summary.TotalDuration.Round(time.Second), c.MinLegacyDuration)
+				outcome = OutcomeFailedNative
+				reason = fmt.Sprintf("%v; below the %v legacy minimum, no fallback", err, c.MinLegacyDuration)
+			} else if err != nil && ctx.Err() == nil {
 				// Native API failed, log and try legacy fallback
 				color.Yellow("[WARNING] Native API failed for %s: %v\n", summary.AppClass, err)
 				color.Yellow("[FALLBACK] Attempting legacy API for %s...\n", summary.AppClass)
+				reason = fmt.Sprintf("native: %v", err)
 
-				legacyPayload := SummaryToPayload(summary)
+				legacyPayload := SummaryToPayloadMode(summary, c.PayloadMode)
 
 				// Print the payload we're about to send
 				if c.DebugMode {
-					payloadJSON, _ := json.MarshalIndent(legacyPayload, "", "  ")
+					payloadJSON, _ := EncodeJSONIndent(legacyPayload, "  ")
 					c.debugLog("Legacy payload for %s:\n%s", summary.AppClass, string(payloadJSON))
 				}
 
 				// Validate before submitting
 				if validateErr := ValidatePayload(legacyPayload); validateErr != nil {
 					err = fmt.Errorf("invalid payload: %v", validateErr)
+					outcome = OutcomeSkippedInvalid
 				} else {
-					err = c.SubmitLegacy(legacyPayload)
-					usedFallback = true
+					err = c.SubmitLegacyContext(ctx, legacyPayload)
+					outcome = OutcomeSucceededLegacy
 				}
-			} else {
-				nativeSuccessCount++
+			} else if err == nil {
+				outcome = OutcomeSucceededNative
 			}
 		} else {
 			// No native credentials, use legacy API directly
-			payload := SummaryToPayload(summary)
+			payload := SummaryToPayloadMode(summary, c.PayloadMode)
 
 			// Print the payload we're about to send
 			if c.DebugMode {
-				payloadJSON, _ := json.MarshalIndent(payload, "", "  ")
+				payloadJSON, _ := EncodeJSONIndent(payload, "  ")
 				c.debugLog("Submitting payload for %s:\n%s", summary.AppClass, string(payloadJSON))
 			}
 
 			// Validate before submitting
 			if validateErr := ValidatePayload(payload); validateErr != nil {
 				err = fmt.Errorf("invalid payload: %v", validateErr)
+				outcome = OutcomeSkippedInvalid
 			} else {
-				err = c.SubmitLegacy(payload)
+				err = c.SubmitLegacyContext(ctx, payload)
+				outcome = OutcomeSucceededLegacy
 			}
 		}
 
+		if err != nil && ctx.Err() != nil {
+			// Interrupted by the deadline rather than rejected - safe to retry later
+			unsent(key, summary, fmt.Sprintf("interrupted by the deadline: %v", err))
+			continue
+		}
+
+		c.resolvePending(summary)
+		if errors.Is(err, ErrUnavailable) {
+			// Not refused, just not reachable: the caller can try it again later
+			result.Failed[key] = summary
+		}
 		if err != nil {
 			color.Red("✗ Failed to submit %s: %v\n", summary.AppClass, err)
-			failCount++
-		} else {
-			successCount++
-			if usedFallback {
-				legacyFallbackCount++
+			if outcome == OutcomeSucceededLegacy {
+				outcome = OutcomeFailedLegacy
+			}
+			if reason != "" {
+				reason += "; "
 			}
+			reason += err.Error()
 		}
+		result.Add(summary, outcome, reason)
 	}
+
+	if len(result.Unsent) > 0 {
+		color.Yellow("%d activities not submitted before the deadline\n", len(result.Unsent))
+	}
+	return result
+}
+
+// replacePending swaps a pending summary for the part of it still to be sent, and returns that part
+func (c *Client) replacePending(summary, rest ActivitySummary) ActivitySummary {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if _, ok := c.pending[IdempotencyKey(summary)]; ok {
+		delete(c.pending, IdempotencyKey(summary))
+		c.pending[IdempotencyKey(rest)] = rest
+	}
+	return rest
+}
+
+// trackPending records summaries that are about to be submitted
+func (c *Client) trackPending(summaries map[string]ActivitySummary) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if c.pending == nil {
+		c.pending = make(map[string]ActivitySummary)
+	}
+	for _, summary := range summaries {
+		c.pending[IdempotencyKey(summary)] = summary
+	}
+}
+
+// resolvePending forgets a summary once it was sent or permanently rejected
+func (c *Client) resolvePending(summary ActivitySummary) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	delete(c.pending, IdempotencyKey(summary))
+}
+
+// PendingCount returns how many summaries the client is tracking as not yet sent or rejected
+func (c *Client) PendingCount() int {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	return len(c.pending)
+}
+
+// PrunePending forgets pending summaries whose IdempotencyKey is in queued, since the offline
+// queue already holds them; otherwise every summary cut off by a deadline stays pending until
+// Close. It returns how many it forgot.
+func (c *Client) PrunePending(queued map[string]bool) int {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	pruned := 0
+	for key := range c.pending {
+		if queued[key] {
+			delete(c.pending, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// Close waits for in-flight submissions to finish (or ctx to end), then writes every
+// summary that wasn't sent to Queue so it can be retried on the next run. Summaries
+// rejected by the API are not queued. The client can still be used after Close.
+func (c *Client) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.debugLog("Submissions still in flight at close, queuing them")
+	}
+
+	c.pendingMu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingMu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("%d activities were not submitted and no queue is configured", len(pending))
+	}
+	if err := c.Queue.Append(pending); err != nil {
+		return fmt.Errorf("failed to queue %d unsent activities: %v", len(pending), err)
+	}
+	color.Yellow("Queued %d unsent activities, they will be submitted on the next run\n", len(pending))
+	return nil
 }
 
-// Activate authenticates with RescueTime and retrieves account keys.
+// Ping verifies the legacy API key with a read-only request: today's analytic data
+// at day resolution. Nothing is submitted.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.APIKey == "" {
+		return fmt.Errorf("API key is empty")
+	}
+
+	endpoint := c.analyticURL
+	if endpoint == "" {
+		endpoint = analyticAPIURL
+	}
+	today := time.Now().Format("2006-01-02")
+	url := fmt.Sprintf("%s?key=%s&format=json&perspective=interval&resolution_time=day&restrict_begin=%s&restrict_end=%s", endpoint, c.APIKey, today, today)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	c.debugLog("Sending GET to: %s?key=***", endpoint)
+
+	client := &http.Client{Timeout: apiTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return requestError(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	c.debugLog("Response status: %d, body: %s", resp.StatusCode, string(body))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// An unknown key can come back as 200 with an error object
+	var result struct {
+		Error    string `json:"error"`
+		Messages string `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &result); err == nil && result.Error != "" {
+		return fmt.Errorf("API rejected the key: %s", strings.TrimSpace(strings.TrimPrefix(result.Error, "#")))
+	}
+	return nil
+}
+
+// Activate authenticates with RescueTime and retrieves account keys. A refused login is
+// returned as an *ActivationError with the reason and what to do about it.
 // Note: This currently only retrieves the account_key. The data_key retrieval
 // mechanism is not yet fully reverse-engineered.
 func Activate(email, password string) (*ActivationResponse, error) {
+	return (&Client{}).Activate(email, password)
+}
+
+// Activate is the package-level Activate against the client's activation endpoint
+func (c *Client) Activate(email, password string) (*ActivationResponse, error) {
 	// Discovered through testing: endpoint uses form-encoded data with username/password fields
-	url := "https://api.rescuetime.com/activate"
+	endpoint := c.activateEndpoint()
 
 	// Create form-encoded payload
-	formData := fmt.Sprintf("username=%s&password=%s",
-		strings.ReplaceAll(email, "@", "%40"), // URL encode @ sign
-		password)
+	formData := url.Values{"username": {email}, "password": {password}}.Encode()
 
 	// Create request
-	req, err := http.NewRequest("POST", url, strings.NewReader(formData))
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(formData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "RescueTime/2.16.5.1 (Linux)")
+	req.Header.Set("User-Agent", c.nativeUserAgent())
 
 	// Send request
 	client := &http.Client{Timeout: apiTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, requestError(err)
 	}
 	defer resp.Body.Close()
 
@@ -648,8 +1396,8 @@ func Activate(email, password string) (*ActivationResponse, error) {
 	// Check for error in response
 	// Response format is YAML-like: "c:\n- 0\n- RT:ok\naccount_key: xxx\nkey: xxx"
 	bodyStr := string(body)
-	if strings.Contains(bodyStr, "RT:error") {
-		return nil, fmt.Errorf("activation failed: %s", bodyStr)
+	if strings.Contains(bodyStr, "RT:error") || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, parseActivationError(bodyStr, resp.StatusCode)
 	}
 
 	// Parse response to extract account_key
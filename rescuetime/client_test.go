@@ -1,8 +1,22 @@
 package rescuetime
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
 )
 
 // TestValidatePayload tests the ValidatePayload function
@@ -243,6 +257,45 @@ func TestSummaryToPayloadWithEndTime(t *testing.T) {
 	}
 }
 
+// TestSummaryToPayloadMode tests selecting duration or end_time payloads and their JSON encoding
+func TestSummaryToPayloadMode(t *testing.T) {
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summary := ActivitySummary{
+		AppClass:      "firefox",
+		TotalDuration: 10 * time.Minute,
+		SessionCount:  2,
+		FirstSeen:     testTime,
+		LastSeen:      testTime.Add(15 * time.Minute),
+	}
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: "", want: `{"start_time":"2025-10-31 10:00:00","duration":10,"activity_name":"firefox"}`},
+		{mode: PayloadModeDuration, want: `{"start_time":"2025-10-31 10:00:00","duration":10,"activity_name":"firefox"}`},
+		{mode: PayloadModeEndTime, want: `{"start_time":"2025-10-31 10:00:00","end_time":"2025-10-31 10:15:00","activity_name":"firefox"}`},
+	}
+
+	for _, tt := range tests {
+		payload := SummaryToPayloadMode(summary, tt.mode)
+		if err := ValidatePayload(payload); err != nil {
+			t.Errorf("mode %q: payload failed validation: %v", tt.mode, err)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("mode %q: marshal failed: %v", tt.mode, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("mode %q:\n got: %s\nwant: %s", tt.mode, data, tt.want)
+		}
+	}
+
+	if ValidPayloadMode("minutes") {
+		t.Error("Expected unknown payload mode to be invalid")
+	}
+}
+
 // TestSummaryToUserClientEvent tests the native API conversion
 func TestSummaryToUserClientEvent(t *testing.T) {
 	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
@@ -270,6 +323,108 @@ func TestSummaryToUserClientEvent(t *testing.T) {
 	}
 }
 
+// TestContextPrefix tests that a summary's context prefixes the application in the legacy
+// and native payloads RescueTime receives, and that summaries without one are unchanged
+func TestContextPrefix(t *testing.T) {
+	var mu sync.Mutex
+	var legacyBodies []RescueTimePayload
+	var nativeBodies []UserClientEventPayload
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body RescueTimePayload
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		legacyBodies = append(legacyBodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacyServer.Close()
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body UserClientEventPayload
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		nativeBodies = append(nativeBodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nativeServer.Close()
+
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.Local)
+	summaries := map[string]ActivitySummary{
+		"[work] firefox": {AppClass: "firefox", Context: "work", ActivityDetails: "Jira", TotalDuration: 20 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(20 * time.Minute)},
+	}
+
+	legacyClient := &Client{APIKey: "test-key", legacyURL: legacyServer.URL}
+	legacyClient.SubmitActivities(summaries)
+	if len(legacyBodies) != 1 || legacyBodies[0].ActivityName != "[work] firefox" {
+		t.Errorf("Expected a legacy activity_name of [work] firefox, got %+v", legacyBodies)
+	}
+
+	nativeClient := NewClient("legacy-key", "", "data-key")
+	nativeClient.nativeURL, nativeClient.legacyURL = nativeServer.URL, legacyServer.URL
+	nativeClient.SubmitActivities(summaries)
+	if len(nativeBodies) != 1 {
+		t.Fatalf("Expected 1 native request, got %d", len(nativeBodies))
+	}
+	if event := nativeBodies[0].UserClientEvent; event.Application != "[work] firefox" || event.EventDescription != "[work] firefox" {
+		t.Errorf("Expected the native application and event_description to be [work] firefox, got %+v", event)
+	}
+
+	// Every payload builder agrees, chunks and split events included
+	summary := summaries["[work] firefox"]
+	summary.TotalDuration = 5 * time.Hour
+	if payload := SummaryToPayloadWithEndTime(summary); payload.ActivityName != "[work] firefox" {
+		t.Errorf("Expected the end-time payload to be prefixed, got %q", payload.ActivityName)
+	}
+	for _, chunk := range splitLongDurationSummaries(map[string]ActivitySummary{"firefox": summary}) {
+		if name := SummaryToPayload(chunk).ActivityName; name != "[work] firefox" {
+			t.Errorf("Expected every chunk to be prefixed, got %q", name)
+		}
+	}
+	for _, event := range SummaryToUserClientEvents(summary, time.Hour) {
+		if event.UserClientEvent.Application != "[work] firefox" {
+			t.Errorf("Expected every split event to be prefixed, got %q", event.UserClientEvent.Application)
+		}
+	}
+
+	summary.Context = ""
+	if name := SummaryToPayload(summary).ActivityName; name != "firefox" {
+		t.Errorf("Expected no prefix without a context, got %q", name)
+	}
+}
+
+// TestWindowTitleUsesRawTitle tests that normalizing ActivityDetails leaves the native
+// window_title alone, including for chunks, and that summaries without RawTitle fall back
+func TestWindowTitleUsesRawTitle(t *testing.T) {
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	raw := "(3) Inbox - jane@example.com - Mail"
+	summary := ActivitySummary{
+		AppClass:        "firefox",
+		ActivityDetails: "Inbox - Mail",
+		RawTitle:        raw,
+		TotalDuration:   5 * time.Hour,
+		SessionCount:    1,
+		FirstSeen:       testTime,
+		LastSeen:        testTime.Add(5 * time.Hour),
+	}
+
+	if got := SummaryToUserClientEvent(summary).UserClientEvent.WindowTitle; got != raw {
+		t.Errorf("WindowTitle = %q, want the raw title %q", got, raw)
+	}
+	if got := SummaryToPayload(summary).ActivityDetails; got != "Inbox - Mail" {
+		t.Errorf("Legacy ActivityDetails = %q, want the normalized details", got)
+	}
+	for key, chunk := range splitLongDurationSummaries(map[string]ActivitySummary{"firefox": summary}) {
+		if got := SummaryToUserClientEvent(chunk).UserClientEvent.WindowTitle; got != raw {
+			t.Errorf("Chunk %s WindowTitle = %q, want %q", key, got, raw)
+		}
+	}
+
+	summary.RawTitle = ""
+	if got := SummaryToUserClientEvent(summary).UserClientEvent.WindowTitle; got != "Inbox - Mail" {
+		t.Errorf("WindowTitle without RawTitle = %q, want ActivityDetails", got)
+	}
+}
+
 // TestNewClient tests client creation
 func TestNewClient(t *testing.T) {
 	apiKey := "test-api-key"
@@ -291,3 +446,769 @@ func TestNewClient(t *testing.T) {
 		t.Errorf("DebugMode = %v, want false", client.DebugMode)
 	}
 }
+
+// TestIdempotencyKey tests that keys are deterministic and content-dependent
+func TestIdempotencyKey(t *testing.T) {
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summary := ActivitySummary{
+		AppClass:        "code",
+		ActivityDetails: "main.go",
+		TotalDuration:   30 * time.Minute,
+		FirstSeen:       testTime,
+		LastSeen:        testTime.Add(30 * time.Minute),
+	}
+
+	first := SummaryToUserClientEvent(summary).UserClientEvent.ClientEventID
+	second := SummaryToUserClientEvent(summary).UserClientEvent.ClientEventID
+	if first == "" || first != second {
+		t.Errorf("Expected stable non-empty key, got %q and %q", first, second)
+	}
+	if len(first) != 36 || first[14] != '8' {
+		t.Errorf("Expected version 8 UUID format, got %q", first)
+	}
+
+	summary.ActivityDetails = "client.go"
+	if other := SummaryToUserClientEvent(summary).UserClientEvent.ClientEventID; other == first {
+		t.Errorf("Expected different key for different content, got %q for both", first)
+	}
+}
+
+// TestNativeEventIDRejection tests that client_event_id is only left out of later submissions
+// when the API names it in a rejection or accepts the event without it, not after a 400 for
+// another reason
+func TestNativeEventIDRejection(t *testing.T) {
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	payload := SummaryToUserClientEvent(ActivitySummary{AppClass: "code", TotalDuration: 30 * time.Minute, FirstSeen: testTime, LastSeen: testTime.Add(30 * time.Minute)})
+
+	tests := []struct {
+		name      string
+		status    int    // the first response
+		body      string // its body
+		retried   int    // the retry without the ID
+		keepsID   bool   // whether the next submission still sends it
+		expectErr bool
+	}{
+		{"other 400", http.StatusBadRequest, `{"error":"invalid start_time"}`, http.StatusBadRequest, true, true},
+		{"named", http.StatusUnprocessableEntity, `{"error":"unknown field client_event_id"}`, http.StatusOK, false, false},
+		{"accepted without it", http.StatusBadRequest, `{"error":"bad request"}`, http.StatusOK, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ids []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var got UserClientEventPayload
+				json.NewDecoder(r.Body).Decode(&got)
+				mu.Lock()
+				defer mu.Unlock()
+				ids = append(ids, got.UserClientEvent.ClientEventID)
+				switch len(ids) {
+				case 1:
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+				case 2:
+					w.WriteHeader(tt.retried)
+				default:
+					w.WriteHeader(http.StatusOK)
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient("api-key", "", "")
+			client.nativeURL = server.URL
+			skipBackoff(client)
+			if err := client.SubmitNative(payload); (err != nil) != tt.expectErr {
+				t.Fatalf("Expected error %v, got %v", tt.expectErr, err)
+			}
+			if err := client.SubmitNative(payload); err != nil {
+				t.Fatalf("Second submission failed: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(ids) != 3 || ids[0] == "" || ids[1] != "" {
+				t.Fatalf("Expected the ID, a retry without it, then the next submission, got %q", ids)
+			}
+			if keeps := ids[2] != ""; keeps != tt.keepsID {
+				t.Errorf("Expected the next submission to send client_event_id: %v, got %q", tt.keepsID, ids[2])
+			}
+		})
+	}
+}
+
+// TestSubmitActivitiesPayloadMode tests that SubmitActivities sends end_time or duration on the wire
+func TestSubmitActivitiesPayloadMode(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.Local)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, SessionCount: 2, FirstSeen: testTime, LastSeen: testTime.Add(15 * time.Minute)},
+	}
+
+	for _, mode := range []string{PayloadModeDuration, PayloadModeEndTime} {
+		bodies = nil
+		client := &Client{APIKey: "test-key", PayloadMode: mode, legacyURL: server.URL}
+		client.SubmitActivities(summaries)
+
+		if len(bodies) != 1 {
+			t.Fatalf("mode %q: expected 1 request, got %d", mode, len(bodies))
+		}
+		_, hasDuration := bodies[0]["duration"]
+		endTime, hasEndTime := bodies[0]["end_time"]
+		if mode == PayloadModeEndTime {
+			if hasDuration || endTime != "2025-10-31 10:15:00" {
+				t.Errorf("mode %q: expected only end_time 10:15:00, got %v", mode, bodies[0])
+			}
+		} else if !hasDuration || hasEndTime {
+			t.Errorf("mode %q: expected only duration, got %v", mode, bodies[0])
+		}
+	}
+}
+
+// TestMinSubmitDurationPreviewAgrees tests that EligibleSummaries (used by dry-run previews)
+// selects exactly what SubmitActivities sends, across several thresholds
+func TestMinSubmitDurationPreviewAgrees(t *testing.T) {
+	var mu sync.Mutex
+	var submitted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		submitted = append(submitted, fmt.Sprintf("%v@%v", body["activity_name"], body["start_time"]))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	testTime := time.Date(2025, 10, 31, 8, 0, 0, 0, time.Local)
+	summary := func(app string, d time.Duration) ActivitySummary {
+		return ActivitySummary{AppClass: app, TotalDuration: d, SessionCount: 1, FirstSeen: testTime, LastSeen: testTime.Add(d)}
+	}
+	summaries := map[string]ActivitySummary{
+		"terminal": summary("terminal", 30*time.Second),
+		"slack":    summary("slack", 2*time.Minute),
+		"firefox":  summary("firefox", 5*time.Minute),
+		"code":     summary("code", 12*time.Minute),
+		// Splits into a 3h55m chunk and an 8m chunk
+		"blender": summary("blender", 4*time.Hour+3*time.Minute),
+	}
+
+	tests := []struct {
+		threshold time.Duration
+		want      int
+	}{
+		{0, 6},
+		{time.Minute, 5},
+		{3 * time.Minute, 4},
+		{DefaultLegacyMinDuration, 4},
+		{10 * time.Minute, 2},
+		{15 * time.Minute, 1},
+	}
+
+	for _, tt := range tests {
+		submitted = nil
+		client := &Client{APIKey: "test-key", MinLegacyDuration: tt.threshold, legacyURL: server.URL}
+
+		eligible, skipped := client.EligibleSummaries(summaries)
+		var previewed []string
+		for _, s := range eligible {
+			payload := SummaryToPayload(s)
+			previewed = append(previewed, fmt.Sprintf("%v@%v", payload.ActivityName, payload.StartTime))
+		}
+
+		client.SubmitActivities(summaries)
+
+		sort.Strings(previewed)
+		sort.Strings(submitted)
+		if len(previewed) != tt.want || skipped != 6-tt.want {
+			t.Errorf("threshold %v: expected %d eligible and %d skipped, got %d and %d", tt.threshold, tt.want, 6-tt.want, len(previewed), skipped)
+		}
+		if strings.Join(previewed, ",") != strings.Join(submitted, ",") {
+			t.Errorf("threshold %v: preview %v disagrees with submitted %v", tt.threshold, previewed, submitted)
+		}
+	}
+}
+
+// TestNewClientMinSubmitDuration tests the default thresholds and which one applies first
+// for legacy-only and native clients
+func TestNewClientMinSubmitDuration(t *testing.T) {
+	t.Setenv("RESCUE_TIME_ACCOUNT_KEY", "")
+	t.Setenv("RESCUE_TIME_DATA_KEY", "")
+
+	legacy := NewClient("legacy-key", "", "")
+	if legacy.MinNativeDuration != 0 || legacy.MinLegacyDuration != DefaultLegacyMinDuration {
+		t.Errorf("Expected 0 native and %v legacy, got %v and %v", DefaultLegacyMinDuration, legacy.MinNativeDuration, legacy.MinLegacyDuration)
+	}
+	if got := legacy.MinSubmitDuration(); got != DefaultLegacyMinDuration {
+		t.Errorf("legacy-only client: expected %v, got %v", DefaultLegacyMinDuration, got)
+	}
+	if got := NewClient("legacy-key", "", "data-key").MinSubmitDuration(); got != 0 {
+		t.Errorf("native client: expected 0, got %v", got)
+	}
+}
+
+// TestShortSummaryNativeOnly tests that a 2-minute summary is submitted natively, but isn't
+// sent through the legacy fallback when the native API fails, nor by a legacy-only client
+func TestShortSummaryNativeOnly(t *testing.T) {
+	var native, legacy atomic.Int32
+	nativeStatus := http.StatusOK
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		native.Add(1)
+		w.WriteHeader(nativeStatus)
+	}))
+	defer nativeServer.Close()
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		legacy.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacyServer.Close()
+
+	start := time.Date(2025, 10, 31, 8, 0, 0, 0, time.Local)
+	summaries := map[string]ActivitySummary{
+		"slack": {AppClass: "slack", ActivityDetails: "general", TotalDuration: 2 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(2 * time.Minute)},
+	}
+	newTestClient := func(dataKey string) *Client {
+		client := NewClient("legacy-key", "", dataKey)
+		client.nativeURL, client.legacyURL = nativeServer.URL, legacyServer.URL
+		skipBackoff(client)
+		return client
+	}
+
+	newTestClient("data-key").SubmitActivities(summaries)
+	if native.Load() != 1 || legacy.Load() != 0 {
+		t.Errorf("Expected the native submission only, got %d native and %d legacy requests", native.Load(), legacy.Load())
+	}
+
+	// Rejected as a client error, so the native API isn't retried
+	native.Store(0)
+	nativeStatus = http.StatusBadRequest
+	unsent := newTestClient("data-key").SubmitActivitiesContext(context.Background(), summaries)
+	if native.Load() == 0 || legacy.Load() != 0 || len(unsent) != 0 {
+		t.Errorf("Expected no legacy fallback below its minimum, got %d native and %d legacy requests, %d unsent", native.Load(), legacy.Load(), len(unsent))
+	}
+
+	newTestClient("").SubmitActivities(summaries)
+	if legacy.Load() != 0 {
+		t.Errorf("Expected a legacy-only client to skip the 2m summary, got %d requests", legacy.Load())
+	}
+}
+
+// TestNativeEventSplitFallback tests that a summary over the native event limit is sent as
+// several events, and that after one is rejected only the unsent remainder falls back
+func TestNativeEventSplitFallback(t *testing.T) {
+	start := time.Date(2025, 10, 31, 8, 0, 0, 0, time.Local)
+	rejected := start.Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	var nativeStarts []string
+	var legacyPayloads []RescueTimePayload
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload UserClientEventPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode native payload: %v", err)
+		}
+		nativeStarts = append(nativeStarts, payload.UserClientEvent.StartTime)
+		if payload.UserClientEvent.StartTime == rejected {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nativeServer.Close()
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload RescueTimePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode legacy payload: %v", err)
+		}
+		legacyPayloads = append(legacyPayloads, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacyServer.Close()
+
+	client := NewClient("legacy-key", "", "data-key")
+	client.nativeURL, client.legacyURL = nativeServer.URL, legacyServer.URL
+	client.MaxNativeEventDuration = time.Hour
+	skipBackoff(client)
+
+	client.SubmitActivities(map[string]ActivitySummary{
+		"code": {AppClass: "code", ActivityDetails: "main.go", TotalDuration: 3*time.Hour + 30*time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(3*time.Hour + 30*time.Minute)},
+	})
+
+	for _, eventStart := range nativeStarts {
+		if eventStart > rejected {
+			t.Errorf("Expected native submission to stop at the rejected third event, got one starting %s", eventStart)
+		}
+	}
+	if len(legacyPayloads) != 1 {
+		t.Fatalf("Expected one legacy fallback, got %d", len(legacyPayloads))
+	}
+	want := RescueTimePayload{StartTime: start.Add(2 * time.Hour).Format("2006-01-02 15:04:05"), Duration: 90, ActivityName: "code", ActivityDetails: "main.go"}
+	if legacyPayloads[0] != want {
+		t.Errorf("Expected the fallback to cover the unsent 1h30m only, got %+v", legacyPayloads[0])
+	}
+}
+
+// TestSubmitOrder tests that SubmitActivities sends summaries in the chosen order, and that
+// unordered submission still sends them all
+func TestSubmitOrder(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload RescueTimePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		sent = append(sent, payload.ActivityName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	start := time.Date(2025, 10, 31, 8, 0, 0, 0, time.Local)
+	summary := func(app string, offset, duration time.Duration) ActivitySummary {
+		return ActivitySummary{AppClass: app, ActivityDetails: app, TotalDuration: duration, SessionCount: 1, FirstSeen: start.Add(offset), LastSeen: start.Add(offset + duration)}
+	}
+	summaries := map[string]ActivitySummary{
+		"slack":   summary("slack", 0, 10*time.Minute),
+		"code":    summary("code", time.Hour, 2*time.Hour),
+		"firefox": summary("firefox", 30*time.Minute, 20*time.Minute),
+		"zoom":    summary("zoom", 3*time.Hour, 20*time.Minute),
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{SubmitOrderLongestFirst, []string{"code", "firefox", "zoom", "slack"}},
+		{SubmitOrderChronological, []string{"slack", "firefox", "code", "zoom"}},
+		{SubmitOrderUnordered, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			sent = nil
+			client := NewClient("legacy-key", "", "")
+			client.legacyURL = server.URL
+			client.SubmitOrder = tt.order
+			client.SubmitActivities(summaries)
+
+			if tt.want == nil {
+				sort.Strings(sent)
+				tt.want = []string{"code", "firefox", "slack", "zoom"}
+			}
+			if strings.Join(sent, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, sent)
+			}
+		})
+	}
+}
+
+// TestUserAgents tests that legacy requests identify this tracker's version, and that native
+// requests keep the official client's User-Agent unless NativeUserAgentVersion is set
+func TestUserAgents(t *testing.T) {
+	oldVersion, oldCommit := version.Version, version.Commit
+	version.Version, version.Commit = "v9.9.9", "abc1234"
+	defer func() { version.Version, version.Commit = oldVersion, oldCommit }()
+
+	var nativeAgent, legacyAgent string
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nativeAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nativeServer.Close()
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		legacyAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacyServer.Close()
+
+	client := NewClient("legacy-key", "", "data-key")
+	client.nativeURL, client.legacyURL = nativeServer.URL, legacyServer.URL
+	ctx := context.Background()
+	start := time.Date(2025, 10, 31, 8, 0, 0, 0, time.Local)
+	summary := ActivitySummary{AppClass: "code", ActivityDetails: "main.go", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)}
+
+	if err := client.SubmitLegacyContext(ctx, SummaryToPayload(summary)); err != nil {
+		t.Fatalf("Legacy submission failed: %v", err)
+	}
+	if legacyAgent != "rescuetime-linux-mutter/v9.9.9" {
+		t.Errorf("Expected the tracker's User-Agent on legacy requests, got %q", legacyAgent)
+	}
+
+	if err := client.SubmitNativeContext(ctx, SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("Native submission failed: %v", err)
+	}
+	if nativeAgent != officialUserAgent {
+		t.Errorf("Expected the official User-Agent on native requests by default, got %q", nativeAgent)
+	}
+
+	client.NativeUserAgentVersion = true
+	if err := client.SubmitNativeContext(ctx, SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("Native submission failed: %v", err)
+	}
+	if want := officialUserAgent + " (rescuetime-linux-mutter v9.9.9; abc1234)"; nativeAgent != want {
+		t.Errorf("Expected %q with -native-ua-version, got %q", want, nativeAgent)
+	}
+}
+
+// fakePendingQueue records summaries appended by Close
+type fakePendingQueue struct {
+	mu    sync.Mutex
+	items map[string]ActivitySummary
+}
+
+func (q *fakePendingQueue) Append(summaries map[string]ActivitySummary) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items == nil {
+		q.items = make(map[string]ActivitySummary)
+	}
+	for key, summary := range summaries {
+		q.items[key] = summary
+	}
+	return nil
+}
+
+// TestClosePersistsPending tests that Close queues a submission still in flight,
+// and queues nothing once every submission has completed
+func TestClosePersistsPending(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.Local)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: testTime, LastSeen: testTime.Add(10 * time.Minute)},
+	}
+
+	queue := &fakePendingQueue{}
+	client := &Client{APIKey: "test-key", Queue: queue, legacyURL: server.URL}
+
+	submitted := make(chan struct{})
+	go func() {
+		client.SubmitActivities(summaries)
+		close(submitted)
+	}()
+
+	// Wait for the submission to be in flight
+	deadline := time.Now().Add(time.Second)
+	for {
+		client.pendingMu.Lock()
+		inFlight := len(client.pending)
+		client.pendingMu.Unlock()
+		if inFlight > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(queue.items) != 1 {
+		t.Fatalf("Expected the in-flight summary to be queued, got %d items", len(queue.items))
+	}
+	for _, summary := range queue.items {
+		if summary.AppClass != "firefox" {
+			t.Errorf("Expected firefox to be queued, got %s", summary.AppClass)
+		}
+	}
+
+	close(release)
+	<-submitted
+
+	// Everything finished: nothing left to queue
+	queue.items = nil
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("Second Close failed: %v", err)
+	}
+	if len(queue.items) != 0 {
+		t.Errorf("Expected nothing queued after submissions completed, got %d", len(queue.items))
+	}
+}
+
+// TestPing tests the read-only key check against accepted and rejected keys
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET, got %s", r.Method)
+		}
+		switch r.URL.Query().Get("key") {
+		case "good-key":
+			w.Write([]byte(`{"notes":"data is an array of arrays","row_headers":["Date","Time Spent (seconds)"],"rows":[]}`))
+		case "unknown-key":
+			w.Write([]byte(`{"error":"# key not found","messages":"key not found"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		key     string
+		wantErr bool
+	}{
+		{"good-key", false},
+		{"unknown-key", true},
+		{"revoked-key", true},
+		{"", true},
+	} {
+		client := &Client{APIKey: tt.key, analyticURL: server.URL}
+		err := client.Ping(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("key %q: Ping() error = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+	}
+
+	// -doctor prints the error, so an unreachable server must not echo the key back
+	server.Close()
+	err := (&Client{APIKey: "secret-key", analyticURL: server.URL}).Ping(context.Background())
+	if err == nil || strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("Expected an error without the key, got %v", err)
+	}
+}
+
+// TestTraceHooks tests that OnRequest and OnResponse see each native attempt, including the
+// switch from query parameter to Bearer authentication, and the legacy request
+func TestTraceHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/native" && r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"bad key"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	type request struct {
+		method, url, auth string
+	}
+	var requests []request
+	var statuses []int
+	var bodies []string
+	client := &Client{APIKey: "legacy-key", AccountKey: "account-key", DataKey: "data-key", nativeURL: server.URL + "/native", legacyURL: server.URL + "/legacy"}
+	skipBackoff(client)
+	client.OnRequest = func(method, url string, headers http.Header) {
+		requests = append(requests, request{method, url, headers.Get("Authorization")})
+	}
+	client.OnResponse = func(status int, body []byte) {
+		statuses = append(statuses, status)
+		bodies = append(bodies, string(body))
+	}
+
+	summary := ActivitySummary{AppClass: "code", ActivityDetails: "main.go", TotalDuration: 10 * time.Minute, FirstSeen: time.Now().Add(-10 * time.Minute), LastSeen: time.Now()}
+	if err := client.SubmitNative(SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("SubmitNative failed: %v", err)
+	}
+	if err := client.SubmitLegacy(SummaryToPayload(summary)); err != nil {
+		t.Fatalf("SubmitLegacy failed: %v", err)
+	}
+
+	want := []request{
+		{"POST", server.URL + "/native?key=account-key", ""},
+		{"POST", server.URL + "/native?key=account-key", "Bearer data-key"},
+		{"POST", server.URL + "/legacy?key=legacy-key", ""},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("Expected %d requests, got %+v", len(want), requests)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("Request %d: expected %+v, got %+v", i, want[i], requests[i])
+		}
+	}
+	wantStatuses := []int{http.StatusUnauthorized, http.StatusOK, http.StatusOK}
+	wantBodies := []string{`{"error":"bad key"}`, `{"ok":true}`, `{"ok":true}`}
+	for i := range wantStatuses {
+		if i >= len(statuses) || statuses[i] != wantStatuses[i] || bodies[i] != wantBodies[i] {
+			t.Fatalf("Expected responses %v %v, got %v %v", wantStatuses, wantBodies, statuses, bodies)
+		}
+	}
+}
+
+// TestObserveSession tests running session statistics and that payloads don't carry them
+func TestObserveSession(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.UTC)
+	var summary ActivitySummary
+	summary.ObserveSession(start, 5*time.Minute)
+	summary.ObserveSession(start.Add(time.Hour), 25*time.Minute)
+	summary.ObserveSession(start.Add(2*time.Hour), 25*time.Minute) // ties keep the first longest
+	summary.ObserveSession(start.Add(3*time.Hour), 5*time.Minute)
+
+	if summary.TotalDuration != time.Hour || summary.SessionCount != 4 {
+		t.Errorf("Expected 1h in 4 sessions, got %v in %d", summary.TotalDuration, summary.SessionCount)
+	}
+	if summary.AvgSessionDuration != 15*time.Minute {
+		t.Errorf("Expected 15m average, got %v", summary.AvgSessionDuration)
+	}
+	if summary.MaxSessionDuration != 25*time.Minute || !summary.LongestSessionStart.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected 25m longest starting at 10:00, got %v at %v", summary.MaxSessionDuration, summary.LongestSessionStart)
+	}
+
+	summary.AppClass = "code"
+	summary.FirstSeen, summary.LastSeen = start, start.Add(4*time.Hour)
+	data, err := json.Marshal(SummaryToPayload(summary))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "session") {
+		t.Errorf("Expected legacy payloads not to include session statistics, got %s", data)
+	}
+}
+
+// TestObserveCoverage tests that summary coverage is weighted by duration and skips unknowns
+func TestObserveCoverage(t *testing.T) {
+	var summary ActivitySummary
+	summary.ObserveCoverage(30*time.Minute, 1)
+	summary.ObserveCoverage(10*time.Minute, 0.6)
+	summary.ObserveCoverage(time.Hour, 0) // unknown, e.g. tracked without a poll interval
+
+	if math.Abs(summary.Coverage-0.9) > 1e-9 {
+		t.Errorf("Expected duration-weighted coverage 0.9, got %v", summary.Coverage)
+	}
+}
+
+// adversarialTitle has format verbs, HTML-escapable characters and a long tail
+var adversarialTitle = "%s%n%% %!d(MISSING) <b>R&D</b> > 100%" + strings.Repeat(" %v", 500)
+
+// TestAdversarialTitlesSentVerbatim tests that legacy and native payloads carry a title with
+// format verbs and HTML characters byte for byte, encoded the same way
+func TestAdversarialTitlesSentVerbatim(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	testTime := time.Date(2025, 10, 31, 10, 0, 0, 0, time.Local)
+	summaries := map[string]ActivitySummary{
+		"firefox": {AppClass: "firefox", ActivityDetails: adversarialTitle, TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: testTime, LastSeen: testTime.Add(10 * time.Minute)},
+	}
+
+	legacy := &Client{APIKey: "test-key", legacyURL: server.URL}
+	legacy.SubmitActivities(summaries)
+	native := &Client{AccountKey: "account", DataKey: "data", nativeURL: server.URL}
+	native.SubmitActivities(summaries)
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected a legacy and a native request, got %d", len(bodies))
+	}
+	// Both paths must skip json.Marshal's HTML escaping of <, > and &
+	for i, body := range bodies {
+		if !bytes.Contains(body, []byte(adversarialTitle)) || bytes.Contains(body, []byte(`\u003c`)) {
+			t.Errorf("Request %d: title not sent verbatim: %.200s", i, body)
+		}
+	}
+}
+
+// TestParseRetryAfter tests Retry-After in delay seconds and as an HTTP date
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{" 0 ", 0},
+		{"-5", 0},
+		{"Fri, 31 Oct 2025 12:01:30 GMT", 90 * time.Second},
+		{"Fri, 31 Oct 2025 11:59:00 GMT", 0}, // already past
+		{"Fri, 31 Oct 2025 14:00:00 GMT", maxRetryAfter},
+		{"86400", maxRetryAfter},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// skipBackoff makes client retry without waiting and returns the delays it asked to wait
+func skipBackoff(client *Client) *[]time.Duration {
+	var mu sync.Mutex
+	delays := new([]time.Duration)
+	client.Sleep = func(ctx context.Context, delay time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*delays = append(*delays, delay)
+		return ctx.Err()
+	}
+	return delays
+}
+
+// TestRateLimitedRetry tests that a 429 is retried rather than dropped, after the
+// Retry-After delay in seconds (legacy) or as an HTTP date (native) instead of the 1s backoff
+func TestRateLimitedRetry(t *testing.T) {
+	var requests atomic.Int32
+	retryAfter := func() string { return "2" }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", retryAfter())
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient("legacy-key", "account-key", "data-key")
+	client.legacyURL, client.nativeURL = server.URL, server.URL
+	delays := skipBackoff(client)
+	summary := ActivitySummary{AppClass: "code", ActivityDetails: "main.go", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: time.Now().Add(-10 * time.Minute), LastSeen: time.Now()}
+
+	if err := client.SubmitLegacy(SummaryToPayload(summary)); err != nil {
+		t.Fatalf("Expected the legacy submission to succeed after the 429, got %v", err)
+	}
+	if requests.Load() != 2 || len(*delays) != 1 || (*delays)[0] != 2*time.Second {
+		t.Errorf("Expected one retry after 2s, got %d requests after waiting %v", requests.Load(), *delays)
+	}
+
+	requests.Store(0)
+	*delays = nil
+	retryAfter = func() string { return time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat) }
+	if err := client.SubmitNative(SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("Expected the native submission to succeed after the 429, got %v", err)
+	}
+	// HTTP dates have whole seconds, so the wait is a little under 30s
+	if requests.Load() != 2 || len(*delays) != 1 || (*delays)[0] <= 28*time.Second || (*delays)[0] > 30*time.Second {
+		t.Errorf("Expected one retry at the Retry-After date, got %d requests after waiting %v", requests.Load(), *delays)
+	}
+}
+
+// TestPrunePending tests that only the pending summaries the queue holds are forgotten
+func TestPrunePending(t *testing.T) {
+	client := &Client{}
+	start := time.Date(2025, 10, 29, 10, 0, 0, 0, time.UTC)
+	queued := ActivitySummary{AppClass: "firefox", TotalDuration: 10 * time.Minute, FirstSeen: start}
+	inFlight := ActivitySummary{AppClass: "code", TotalDuration: 20 * time.Minute, FirstSeen: start}
+	client.trackPending(map[string]ActivitySummary{"firefox": queued, "code": inFlight})
+
+	if pruned := client.PrunePending(map[string]bool{IdempotencyKey(queued): true, "unknown": true}); pruned != 1 {
+		t.Errorf("Expected 1 summary pruned, got %d", pruned)
+	}
+	if n := client.PendingCount(); n != 1 {
+		t.Errorf("Expected the in-flight summary to stay pending, got %d pending", n)
+	}
+	if _, ok := client.pending[IdempotencyKey(inFlight)]; !ok {
+		t.Error("Expected the in-flight summary to be the one left")
+	}
+}
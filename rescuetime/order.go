@@ -0,0 +1,45 @@
+package rescuetime
+
+import "sort"
+
+// Submission orders for Client.SubmitOrder and SubmissionOrder
+const (
+	SubmitOrderUnordered     = "unordered"     // map iteration order (default)
+	SubmitOrderLongestFirst  = "longest-first" // largest TotalDuration first
+	SubmitOrderChronological = "chronological" // earliest FirstSeen first
+)
+
+// ValidSubmitOrder reports whether order is a supported submission order
+func ValidSubmitOrder(order string) bool {
+	return order == "" || order == SubmitOrderUnordered || order == SubmitOrderLongestFirst || order == SubmitOrderChronological
+}
+
+// SubmissionOrder returns the keys of summaries in the order SubmitActivities sends them.
+// Longest-first puts the biggest activities ahead, so a submission cut short by a deadline
+// or rate limit loses the least time. Ties are broken by FirstSeen, then by key, so the
+// ordered modes are deterministic. An empty order is treated as SubmitOrderUnordered.
+func SubmissionOrder(summaries map[string]ActivitySummary, order string) []string {
+	keys := make([]string, 0, len(summaries))
+	for key := range summaries {
+		keys = append(keys, key)
+	}
+
+	earlier := func(a, b string) bool {
+		if !summaries[a].FirstSeen.Equal(summaries[b].FirstSeen) {
+			return summaries[a].FirstSeen.Before(summaries[b].FirstSeen)
+		}
+		return a < b
+	}
+	switch order {
+	case SubmitOrderLongestFirst:
+		sort.Slice(keys, func(i, j int) bool {
+			if summaries[keys[i]].TotalDuration != summaries[keys[j]].TotalDuration {
+				return summaries[keys[i]].TotalDuration > summaries[keys[j]].TotalDuration
+			}
+			return earlier(keys[i], keys[j])
+		})
+	case SubmitOrderChronological:
+		sort.Slice(keys, func(i, j int) bool { return earlier(keys[i], keys[j]) })
+	}
+	return keys
+}
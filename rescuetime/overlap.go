@@ -0,0 +1,174 @@
+package rescuetime
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OverlapAdjustment records how ResolveOverlaps moved one summary
+type OverlapAdjustment struct {
+	Key        string        // the summary's key in the map passed in
+	AppClass   string        // the summary's ActivityName
+	Overlapped string        // ActivityName of the first summary it would have overlapped
+	Moved      time.Duration // time sent somewhere other than FirstSeen + TotalDuration
+	Late       time.Duration // of that, placed after LastSeen because there was no room before
+	Pieces     int           // events the summary is sent as now
+}
+
+// String describes the adjustment for the submission log
+func (a OverlapAdjustment) String() string {
+	text := fmt.Sprintf("%s overlapped %s: moved %v", a.AppClass, a.Overlapped, a.Moved.Round(time.Second))
+	if a.Pieces > 1 {
+		text += fmt.Sprintf(" (%d events)", a.Pieces)
+	}
+	if a.Late > 0 {
+		text += fmt.Sprintf(", %v of it after it was last seen", a.Late.Round(time.Second))
+	}
+	return text
+}
+
+// timeSpan is a half-open stretch of the timeline, [start, end)
+type timeSpan struct {
+	start, end time.Time
+}
+
+// overlapJob is a summary being laid onto the timeline by ResolveOverlaps
+type overlapJob struct {
+	key        string
+	summary    ActivitySummary
+	deadline   time.Time     // LastSeen, or the original end if that's later
+	remaining  time.Duration // time still to place
+	spans      []timeSpan
+	overlapped string
+}
+
+// ResolveOverlaps lays summaries onto one timeline so no two events overlap. Payloads run
+// from FirstSeen for TotalDuration, so an application used on and off (code 9:00-9:10 and
+// 9:20-9:30, firefox in between) is sent as 9:00-9:20 over firefox's 9:10-9:20, and
+// RescueTime shows both at once.
+//
+// Summaries only know when their sessions began and ended (FirstSeen, LastSeen) and how much
+// time they add up to, so the timeline is rebuilt from those: the application last seen
+// soonest runs first, and a summary's event is clipped when one that ends sooner starts.
+// The clipped time goes into the next free time before its LastSeen, as extra "key#partN"
+// summaries. That puts code at 9:00-9:10 and 9:20-9:30 above. Real sessions never overlap,
+// so every summary fits before its LastSeen; only summaries that don't come from the tracker
+// can run past it. No time is dropped, summaries that overlap nothing are returned as they
+// were, and the input isn't modified.
+func ResolveOverlaps(summaries map[string]ActivitySummary) (map[string]ActivitySummary, []OverlapAdjustment) {
+	keys := SubmissionOrder(summaries, SubmitOrderChronological)
+	jobs := make([]*overlapJob, 0, len(keys))
+	for _, key := range keys {
+		summary := summaries[key]
+		if summary.TotalDuration <= 0 {
+			continue
+		}
+		job := &overlapJob{key: key, summary: summary, deadline: summary.LastSeen, remaining: summary.TotalDuration}
+		if end := summary.FirstSeen.Add(summary.TotalDuration); end.After(job.deadline) {
+			job.deadline = end
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Earliest deadline first: at any moment the started summary last seen soonest runs,
+	// until it's done or another one starts
+	var ready []*overlapJob
+	next := 0
+	var now time.Time
+	for next < len(jobs) || len(ready) > 0 {
+		if len(ready) == 0 && jobs[next].summary.FirstSeen.After(now) {
+			now = jobs[next].summary.FirstSeen
+		}
+		for next < len(jobs) && !jobs[next].summary.FirstSeen.After(now) {
+			ready = append(ready, jobs[next])
+			next++
+		}
+		sort.SliceStable(ready, func(i, j int) bool { return ready[i].deadline.Before(ready[j].deadline) })
+
+		// Whatever waits, started or not, is pushed later by this one
+		job := ready[0]
+		for _, waiting := range ready[1:] {
+			if waiting.overlapped == "" {
+				waiting.overlapped = job.summary.ActivityName()
+			}
+		}
+
+		end := now.Add(job.remaining)
+		if next < len(jobs) && jobs[next].summary.FirstSeen.Before(end) {
+			end = jobs[next].summary.FirstSeen
+		}
+		if n := len(job.spans); n > 0 && job.spans[n-1].end.Equal(now) {
+			job.spans[n-1].end = end
+		} else {
+			job.spans = append(job.spans, timeSpan{now, end})
+		}
+		job.remaining -= end.Sub(now)
+		now = end
+		if job.remaining <= 0 {
+			ready = ready[1:]
+		}
+	}
+
+	result := make(map[string]ActivitySummary, len(summaries))
+	for key, summary := range summaries {
+		result[key] = summary
+	}
+	var adjustments []OverlapAdjustment
+	for _, job := range jobs {
+		summary := job.summary
+		if len(job.spans) == 1 && job.spans[0].start.Equal(summary.FirstSeen) {
+			continue
+		}
+
+		adjustment := OverlapAdjustment{Key: job.key, AppClass: summary.ActivityName(), Overlapped: job.overlapped, Pieces: len(job.spans)}
+		originalEnd := summary.FirstSeen.Add(summary.TotalDuration)
+		for i, span := range job.spans {
+			adjustment.Moved += span.end.Sub(span.start) - overlapOf(span, timeSpan{summary.FirstSeen, originalEnd})
+			if span.end.After(summary.LastSeen) {
+				adjustment.Late += span.end.Sub(maxTime(span.start, summary.LastSeen))
+			}
+
+			if i == 0 {
+				first := summary
+				first.FirstSeen = span.start
+				first.LastSeen = span.end
+				first.TotalDuration = span.end.Sub(span.start)
+				result[job.key] = first
+				continue
+			}
+			result[fmt.Sprintf("%s#part%d", job.key, i+1)] = ActivitySummary{
+				AppClass:        summary.AppClass,
+				Context:         summary.Context,
+				ActivityDetails: summary.ActivityDetails,
+				RawTitle:        summary.RawTitle,
+				TotalDuration:   span.end.Sub(span.start),
+				SessionCount:    1,
+				FirstSeen:       span.start,
+				LastSeen:        span.end,
+			}
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return result, adjustments
+}
+
+// overlapOf returns how long a and b overlap
+func overlapOf(a, b timeSpan) time.Duration {
+	start, end := maxTime(a.start, b.start), a.end
+	if b.end.Before(end) {
+		end = b.end
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// maxTime returns the later of a and b
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,271 @@
+package rescuetime
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// overlapStart is 9:00 on the day the overlap tests use
+var overlapStart = time.Date(2025, 10, 31, 9, 0, 0, 0, time.UTC)
+
+// clock returns overlapStart plus minutes
+func clock(minutes int) time.Time {
+	return overlapStart.Add(time.Duration(minutes) * time.Minute)
+}
+
+// sessionsSummary aggregates back-to-back [start, end) minute pairs of one application the
+// way the tracker does: first start, last end and the total
+func sessionsSummary(app string, minutes ...int) ActivitySummary {
+	summary := ActivitySummary{AppClass: app, ActivityDetails: app + " window", FirstSeen: clock(minutes[0]), LastSeen: clock(minutes[len(minutes)-1])}
+	for i := 0; i < len(minutes); i += 2 {
+		summary.ObserveSession(clock(minutes[i]), clock(minutes[i+1]).Sub(clock(minutes[i])))
+	}
+	return summary
+}
+
+// timeline renders resolved summaries as "app start-end" in start order, with minutes from 9:00
+func timeline(summaries map[string]ActivitySummary) []string {
+	sorted := make([]ActivitySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FirstSeen.Before(sorted[j].FirstSeen) })
+
+	events := make([]string, len(sorted))
+	for i, summary := range sorted {
+		start := summary.FirstSeen.Sub(overlapStart) / time.Minute
+		end := summary.FirstSeen.Add(summary.TotalDuration).Sub(overlapStart) / time.Minute
+		events[i] = fmt.Sprintf("%s %02d-%02d", summary.AppClass, start, end)
+	}
+	return events
+}
+
+// checkResolved checks the invariants of any ResolveOverlaps result: events don't overlap,
+// and each application's total is what it was
+func checkResolved(t *testing.T, name string, input, resolved map[string]ActivitySummary) {
+	t.Helper()
+	events := make([]ActivitySummary, 0, len(resolved))
+	totals := make(map[string]time.Duration)
+	for _, summary := range resolved {
+		events = append(events, summary)
+		totals[summary.AppClass] += summary.TotalDuration
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].FirstSeen.Before(events[j].FirstSeen) })
+	for i := 1; i < len(events); i++ {
+		if end := events[i-1].FirstSeen.Add(events[i-1].TotalDuration); end.After(events[i].FirstSeen) {
+			t.Errorf("%s: %s overlaps %s: %v", name, events[i-1].AppClass, events[i].AppClass, timeline(resolved))
+		}
+	}
+	for _, summary := range input {
+		if totals[summary.AppClass] != summary.TotalDuration {
+			t.Errorf("%s: expected %s to keep %v, got %v", name, summary.AppClass, summary.TotalDuration, totals[summary.AppClass])
+		}
+	}
+}
+
+// TestResolveOverlaps tests interleaved activity: each pattern is real sessions, aggregated
+// into summaries whose naive events overlap, and the timeline they're laid out as
+func TestResolveOverlaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   map[string]ActivitySummary
+		want    []string
+		changed []string // keys with an adjustment
+	}{
+		{
+			name: "back to back",
+			input: map[string]ActivitySummary{
+				"code":    sessionsSummary("code", 0, 10),
+				"firefox": sessionsSummary("firefox", 10, 25),
+			},
+			want: []string{"code 00-10", "firefox 10-25"},
+		},
+		{
+			name: "one interruption",
+			input: map[string]ActivitySummary{
+				"code":    sessionsSummary("code", 0, 10, 20, 30),
+				"firefox": sessionsSummary("firefox", 10, 20),
+			},
+			want:    []string{"code 00-10", "firefox 10-20", "code 20-30"},
+			changed: []string{"code"},
+		},
+		{
+			name: "alternating",
+			input: map[string]ActivitySummary{
+				"code":    sessionsSummary("code", 0, 5, 10, 15, 20, 25),
+				"firefox": sessionsSummary("firefox", 5, 10, 15, 20, 25, 30),
+			},
+			// code can't be sent as one event without running into firefox's last session
+			want:    []string{"code 00-15", "firefox 15-30"},
+			changed: []string{"firefox"},
+		},
+		{
+			name: "three applications",
+			input: map[string]ActivitySummary{
+				"code":    sessionsSummary("code", 0, 5, 10, 15, 20, 25),
+				"slack":   sessionsSummary("slack", 5, 10, 25, 30),
+				"firefox": sessionsSummary("firefox", 15, 20),
+			},
+			want:    []string{"code 00-15", "firefox 15-20", "slack 20-30"},
+			changed: []string{"slack"},
+		},
+		{
+			name: "nested",
+			input: map[string]ActivitySummary{
+				"code":     sessionsSummary("code", 0, 10, 40, 50),
+				"firefox":  sessionsSummary("firefox", 10, 20, 30, 40),
+				"terminal": sessionsSummary("terminal", 20, 30),
+			},
+			want:    []string{"code 00-10", "firefox 10-20", "terminal 20-30", "firefox 30-40", "code 40-50"},
+			changed: []string{"code", "firefox"},
+		},
+		{
+			name: "gap between sessions",
+			input: map[string]ActivitySummary{
+				"code":    sessionsSummary("code", 0, 10, 40, 50),
+				"firefox": sessionsSummary("firefox", 15, 20),
+			},
+			// code's later session is sent in the first free time rather than where it was
+			want:    []string{"code 00-15", "firefox 15-20", "code 20-25"},
+			changed: []string{"code"},
+		},
+	}
+
+	for _, tt := range tests {
+		resolved, adjustments := ResolveOverlaps(tt.input)
+		checkResolved(t, tt.name, tt.input, resolved)
+		if got := timeline(resolved); strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		var changed []string
+		for _, adjustment := range adjustments {
+			changed = append(changed, adjustment.Key)
+		}
+		sort.Strings(changed)
+		if strings.Join(changed, ",") != strings.Join(tt.changed, ",") {
+			t.Errorf("%s: expected adjustments to %v, got %+v", tt.name, tt.changed, adjustments)
+		}
+	}
+}
+
+// TestResolveOverlapsAdjustment tests what an adjustment reports and that the pieces keep the
+// summary's application and title, under the original key first
+func TestResolveOverlapsAdjustment(t *testing.T) {
+	code := sessionsSummary("code", 0, 10, 20, 30)
+	code.Context = "work"
+	input := map[string]ActivitySummary{"[work] code": code, "firefox": sessionsSummary("firefox", 10, 20)}
+
+	resolved, adjustments := ResolveOverlaps(input)
+	if len(adjustments) != 1 {
+		t.Fatalf("Expected one adjustment, got %+v", adjustments)
+	}
+	adjustment := adjustments[0]
+	if adjustment.Key != "[work] code" || adjustment.AppClass != "[work] code" || adjustment.Overlapped != "firefox" ||
+		adjustment.Moved != 10*time.Minute || adjustment.Late != 0 || adjustment.Pieces != 2 {
+		t.Errorf("Unexpected adjustment %+v", adjustment)
+	}
+	if text := adjustment.String(); text != "[work] code overlapped firefox: moved 10m0s (2 events)" {
+		t.Errorf("Unexpected description %q", text)
+	}
+
+	first, rest := resolved["[work] code"], resolved["[work] code#part2"]
+	if !first.FirstSeen.Equal(clock(0)) || !first.LastSeen.Equal(clock(10)) || first.TotalDuration != 10*time.Minute || first.SessionCount != 2 {
+		t.Errorf("Expected the first piece under the original key at 9:00-9:10, got %+v", first)
+	}
+	if !rest.FirstSeen.Equal(clock(20)) || !rest.LastSeen.Equal(clock(30)) || rest.Context != "work" || rest.ActivityDetails != "code window" {
+		t.Errorf("Expected the rest at 9:20-9:30 with the same context and title, got %+v", rest)
+	}
+
+	// The input is untouched
+	if input["[work] code"].TotalDuration != 20*time.Minute || len(input) != 2 {
+		t.Errorf("Expected the input to be left alone, got %+v", input)
+	}
+}
+
+// TestResolveOverlapsImpossible tests summaries that claim more time than their span, which
+// the tracker never produces: nothing is dropped, the excess runs past LastSeen
+func TestResolveOverlapsImpossible(t *testing.T) {
+	summary := func(app string) ActivitySummary {
+		return ActivitySummary{AppClass: app, TotalDuration: 10 * time.Minute, FirstSeen: clock(0), LastSeen: clock(10)}
+	}
+	input := map[string]ActivitySummary{"a": summary("a"), "b": summary("b"), "c": summary("c")}
+
+	resolved, adjustments := ResolveOverlaps(input)
+	checkResolved(t, "same start", input, resolved)
+	if got := timeline(resolved); strings.Join(got, ", ") != "a 00-10, b 10-20, c 20-30" {
+		t.Errorf("Expected the summaries one after another, got %v", got)
+	}
+	if len(adjustments) != 2 || adjustments[0].Late != 10*time.Minute || adjustments[1].Late != 10*time.Minute {
+		t.Errorf("Expected b and c to be reported late, got %+v", adjustments)
+	}
+	if !strings.Contains(adjustments[1].String(), "after it was last seen") {
+		t.Errorf("Expected the description to mention late time, got %q", adjustments[1])
+	}
+
+	// Empty summaries are passed through
+	empty := map[string]ActivitySummary{"idle": {AppClass: "idle", FirstSeen: clock(0), LastSeen: clock(0)}}
+	if resolved, adjustments := ResolveOverlaps(empty); len(resolved) != 1 || adjustments != nil {
+		t.Errorf("Expected an empty summary to pass through, got %v, %+v", resolved, adjustments)
+	}
+}
+
+// TestSubmitResolvesOverlaps tests that native and legacy submissions both send the resolved
+// timeline rather than overlapping events
+func TestSubmitResolvesOverlaps(t *testing.T) {
+	var mu sync.Mutex
+	var legacyBodies []RescueTimePayload
+	var nativeBodies []UserClientEventPayload
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body RescueTimePayload
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		legacyBodies = append(legacyBodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacyServer.Close()
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body UserClientEventPayload
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		nativeBodies = append(nativeBodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer nativeServer.Close()
+
+	summaries := map[string]ActivitySummary{
+		"code":    sessionsSummary("code", 0, 10, 20, 30),
+		"firefox": sessionsSummary("firefox", 10, 20),
+	}
+
+	legacyClient := &Client{APIKey: "test-key", PayloadMode: PayloadModeEndTime, SubmitOrder: SubmitOrderChronological, legacyURL: legacyServer.URL}
+	legacyClient.SubmitActivities(summaries)
+	var legacy []string
+	for _, body := range legacyBodies {
+		legacy = append(legacy, body.ActivityName+" "+body.StartTime[11:16]+"-"+body.EndTime[11:16])
+	}
+	if want := "code 09:00-09:10, firefox 09:10-09:20, code 09:20-09:30"; strings.Join(legacy, ", ") != want {
+		t.Errorf("Expected legacy payloads %s, got %v", want, legacy)
+	}
+
+	nativeClient := NewClient("legacy-key", "", "data-key")
+	nativeClient.SubmitOrder = SubmitOrderChronological
+	nativeClient.nativeURL, nativeClient.legacyURL = nativeServer.URL, legacyServer.URL
+	nativeClient.SubmitActivities(summaries)
+	var native []string
+	for _, body := range nativeBodies {
+		event := body.UserClientEvent
+		native = append(native, event.Application+" "+event.StartTime[11:16]+"-"+event.EndTime[11:16])
+	}
+	if want := "code 09:00-09:10, firefox 09:10-09:20, code 09:20-09:30"; strings.Join(native, ", ") != want {
+		t.Errorf("Expected native events %s, got %v", want, native)
+	}
+}
@@ -0,0 +1,160 @@
+package rescuetime
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+)
+
+// Native API authentication strategies for Profile.AuthOrder
+const (
+	NativeAuthQuery  = "query"  // account key as the key query parameter
+	NativeAuthBearer = "bearer" // data key as a Bearer token, account key as the key parameter
+)
+
+// Native API profile names
+const (
+	ProfileDesktop = "desktop-2.16" // the official Linux desktop client (default)
+	ProfileGeneric = "generic"      // this tracker's own User-Agent, Bearer auth first
+)
+
+// Profile is how the client presents itself to the undocumented native API: the
+// User-Agent, where the endpoints are and which authentication to try first. The API has
+// been seen to behave differently for other User-Agents, so this can be switched when the
+// desktop client's goes stale. A Profile without a Name is treated as ProfileDesktop.
+type Profile struct {
+	Name         string
+	UserAgent    string
+	BaseURL      string   // e.g. https://api.rescuetime.com
+	EventsPath   string   // user_client_events endpoint, relative to BaseURL
+	ActivatePath string   // activation endpoint, relative to BaseURL
+	AuthOrder    []string // NativeAuth* strategies, tried in order on 401
+}
+
+// profiles are the presets LookupProfile returns
+var profiles = map[string]Profile{
+	ProfileDesktop: {
+		Name:         ProfileDesktop,
+		UserAgent:    officialUserAgent,
+		BaseURL:      nativeAPIBase,
+		EventsPath:   "/api/resource/user_client_events",
+		ActivatePath: "/activate",
+		AuthOrder:    []string{NativeAuthQuery, NativeAuthBearer},
+	},
+	ProfileGeneric: {
+		Name:         ProfileGeneric,
+		BaseURL:      nativeAPIBase,
+		EventsPath:   "/api/resource/user_client_events",
+		ActivatePath: "/activate",
+		AuthOrder:    []string{NativeAuthBearer, NativeAuthQuery},
+	},
+}
+
+// LookupProfile returns the preset with the given name
+func LookupProfile(name string) (Profile, bool) {
+	profile, ok := profiles[name]
+	if ok && profile.UserAgent == "" {
+		profile.UserAgent = version.UserAgent()
+	}
+	profile.AuthOrder = append([]string(nil), profile.AuthOrder...)
+	return profile, ok
+}
+
+// ProfileNames lists the presets, for help and error messages
+func ProfileNames() string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// profile returns the client's Profile, or ProfileDesktop if none was set
+func (c *Client) profile() Profile {
+	if c.Profile.Name == "" {
+		profile, _ := LookupProfile(ProfileDesktop)
+		return profile
+	}
+	return c.Profile
+}
+
+// nativeEndpoint returns the user_client_events URL
+func (c *Client) nativeEndpoint() string {
+	if c.nativeURL != "" {
+		return c.nativeURL
+	}
+	profile := c.profile()
+	return profile.BaseURL + profile.EventsPath
+}
+
+// activateEndpoint returns the activation URL
+func (c *Client) activateEndpoint() string {
+	if c.activateURL != "" {
+		return c.activateURL
+	}
+	profile := c.profile()
+	return profile.BaseURL + profile.ActivatePath
+}
+
+// nativeUserAgent returns the User-Agent for native API and activation requests
+func (c *Client) nativeUserAgent() string {
+	if c.NativeUserAgentVersion {
+		return c.profile().UserAgent + " " + version.Comment()
+	}
+	return c.profile().UserAgent
+}
+
+// NativeAuth returns the NativeAuth* strategy the native API last accepted, which is
+// tried first, or "" before the first success or after it stopped working
+func (c *Client) NativeAuth() string {
+	if auth := c.nativeAuth.Load(); auth != nil {
+		return *auth
+	}
+	return ""
+}
+
+// nativeAuthOrder returns the authentication strategies to try, starting with the one that
+// last succeeded in this process so a known 401 isn't repeated for every event
+func (c *Client) nativeAuthOrder() []string {
+	order := c.profile().AuthOrder
+	if len(order) == 0 {
+		order = []string{NativeAuthQuery, NativeAuthBearer}
+	}
+	remembered := c.NativeAuth()
+	if remembered == "" || remembered == order[0] {
+		return order
+	}
+	reordered := []string{remembered}
+	for _, auth := range order {
+		if auth != remembered {
+			reordered = append(reordered, auth)
+		}
+	}
+	return reordered
+}
+
+// describeNativeAuth names a strategy for log messages
+func describeNativeAuth(auth string) string {
+	if auth == NativeAuthBearer {
+		return "Bearer token"
+	}
+	return "query parameter"
+}
+
+// rememberNativeAuth records the strategy the native API accepted
+func (c *Client) rememberNativeAuth(auth string) {
+	if c.NativeAuth() != auth {
+		c.debugLog("Remembering %s auth for later native submissions", describeNativeAuth(auth))
+	}
+	c.nativeAuth.Store(&auth)
+}
+
+// forgetNativeAuth drops auth if it's the remembered strategy and was just refused, so the
+// profile's order applies again
+func (c *Client) forgetNativeAuth(auth string) {
+	if current := c.nativeAuth.Load(); current != nil && *current == auth && c.nativeAuth.CompareAndSwap(current, nil) {
+		c.debugLog("Remembered %s auth was refused, forgetting it", describeNativeAuth(auth))
+	}
+}
@@ -0,0 +1,150 @@
+package rescuetime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+)
+
+// authServer is a native API that only accepts one authentication strategy (Bearer unless
+// accept says otherwise), recording how each request authenticated, its path and User-Agent
+type authServer struct {
+	mu       sync.Mutex
+	accept   string
+	auths    []string
+	paths    []string
+	agents   []string
+	accepted int
+}
+
+func (s *authServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth := NativeAuthQuery
+	if r.Header.Get("Authorization") != "" {
+		auth = NativeAuthBearer
+	}
+	s.auths = append(s.auths, auth)
+	s.paths = append(s.paths, r.URL.Path)
+	s.agents = append(s.agents, r.Header.Get("User-Agent"))
+	accept := s.accept
+	if accept == "" {
+		accept = NativeAuthBearer
+	}
+	if auth != accept {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.accepted++
+}
+
+// TestNativeAuthRemembered tests that after query parameter auth gets a 401 and Bearer
+// succeeds, later events go straight to Bearer instead of repeating the 401
+func TestNativeAuthRemembered(t *testing.T) {
+	backend := &authServer{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	client := NewClient("legacy-key", "account-key", "data-key")
+	client.nativeURL = server.URL
+	skipBackoff(client)
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"code":    {AppClass: "code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(time.Hour), LastSeen: start.Add(70 * time.Minute)},
+		"slack":   {AppClass: "slack", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(2 * time.Hour), LastSeen: start.Add(130 * time.Minute)},
+	}
+	client.SubmitActivities(summaries)
+	client.SubmitActivities(summaries)
+
+	want := []string{NativeAuthQuery, NativeAuthBearer, NativeAuthBearer, NativeAuthBearer, NativeAuthBearer, NativeAuthBearer, NativeAuthBearer}
+	if len(backend.auths) != len(want) {
+		t.Fatalf("Expected auth attempts %v, got %v", want, backend.auths)
+	}
+	for i := range want {
+		if backend.auths[i] != want[i] {
+			t.Errorf("Attempt %d: expected %s, got %s", i, want[i], backend.auths[i])
+		}
+	}
+	if backend.accepted != 6 {
+		t.Errorf("Expected all six events accepted, got %d", backend.accepted)
+	}
+}
+
+// TestNativeAuthCached tests that across five submissions only the first pays for
+// discovering Bearer auth, and that a remembered strategy is dropped once it's refused
+func TestNativeAuthCached(t *testing.T) {
+	backend := &authServer{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	client := NewClient("legacy-key", "account-key", "data-key")
+	client.nativeURL = server.URL
+	skipBackoff(client)
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		summary := ActivitySummary{AppClass: "code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(time.Duration(i) * time.Hour), LastSeen: start.Add(time.Duration(i)*time.Hour + 10*time.Minute)}
+		if err := client.SubmitNative(SummaryToUserClientEvent(summary)); err != nil {
+			t.Fatalf("Submission %d failed: %v", i+1, err)
+		}
+	}
+	if len(backend.auths) != 6 || backend.accepted != 5 {
+		t.Errorf("Expected 6 requests (one 401) for 5 submissions, got %v", backend.auths)
+	}
+	if client.NativeAuth() != NativeAuthBearer {
+		t.Errorf("Expected Bearer remembered, got %q", client.NativeAuth())
+	}
+
+	// The server now refuses Bearer: it's forgotten and query parameter auth remembered
+	backend.accept = NativeAuthQuery
+	backend.auths = nil
+	summary := ActivitySummary{AppClass: "code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(6 * time.Hour), LastSeen: start.Add(6*time.Hour + 10*time.Minute)}
+	if err := client.SubmitNative(SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("Submission after the switch failed: %v", err)
+	}
+	if len(backend.auths) != 2 || backend.auths[0] != NativeAuthBearer || backend.auths[1] != NativeAuthQuery || client.NativeAuth() != NativeAuthQuery {
+		t.Errorf("Expected Bearer refused then query parameter remembered, got %v (remembered %q)", backend.auths, client.NativeAuth())
+	}
+}
+
+// TestProfiles tests that a profile sets the User-Agent, the endpoint path and which
+// authentication is tried first, and that unknown profiles are rejected
+func TestProfiles(t *testing.T) {
+	backend := &authServer{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	profile, ok := LookupProfile(ProfileGeneric)
+	if !ok {
+		t.Fatalf("Expected the %s profile", ProfileGeneric)
+	}
+	profile.BaseURL = server.URL
+	profile.EventsPath = "/v2/events"
+
+	client := NewClient("legacy-key", "account-key", "data-key")
+	client.Profile = profile
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summary := ActivitySummary{AppClass: "code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)}
+	if err := client.SubmitNative(SummaryToUserClientEvent(summary)); err != nil {
+		t.Fatalf("SubmitNative failed: %v", err)
+	}
+
+	if len(backend.auths) != 1 || backend.auths[0] != NativeAuthBearer {
+		t.Errorf("Expected Bearer tried first and accepted, got %v", backend.auths)
+	}
+	if backend.paths[0] != "/v2/events" || backend.agents[0] != version.UserAgent() {
+		t.Errorf("Expected /v2/events with %q, got %s with %q", version.UserAgent(), backend.paths[0], backend.agents[0])
+	}
+
+	if desktop, _ := LookupProfile(ProfileDesktop); desktop.UserAgent != officialUserAgent || desktop.AuthOrder[0] != NativeAuthQuery {
+		t.Errorf("Expected the desktop profile to match the official client, got %+v", desktop)
+	}
+	if _, ok := LookupProfile("desktop-1.0"); ok {
+		t.Error("Expected an unknown profile to be rejected")
+	}
+}
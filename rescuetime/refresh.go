@@ -0,0 +1,65 @@
+package rescuetime
+
+import (
+	"errors"
+
+	"github.com/fatih/color"
+)
+
+// ErrNativeUnauthorized is returned (wrapped) by SubmitNativeContext when the native API
+// refuses the keys with both query parameter and Bearer authentication. Retrying with the
+// same keys won't help; they have to be refreshed with Activate.
+var ErrNativeUnauthorized = errors.New("native API keys rejected")
+
+// ErrUnavailable is returned (wrapped) by SubmitLegacyContext and SubmitNativeContext when
+// every attempt failed without an answer, with a 5xx or rate limited. Unlike a rejection,
+// the same submission may succeed later.
+var ErrUnavailable = errors.New("RescueTime unavailable")
+
+// CredentialStore supplies the RescueTime login used to refresh rejected native API keys
+// and persists the keys Activate returns, so the next run starts with them
+type CredentialStore interface {
+	Login() (email, password string, err error)
+	SaveKeys(keys *ActivationResponse) error
+}
+
+// keyRefresh is how far a submission got refreshing rejected native keys
+type keyRefresh int
+
+const (
+	keyRefreshUntried keyRefresh = iota
+	keyRefreshSucceeded
+	keyRefreshFailed
+)
+
+// refreshNativeKeys re-runs Activate with the stored login and switches the client to the
+// new keys. Activate only returns an account key for now, so DataKey is replaced only when
+// one comes back. The keys are used for this run even if saving them fails.
+func (c *Client) refreshNativeKeys() keyRefresh {
+	color.Yellow("[WARNING] Native API keys were rejected (401), refreshing them with the stored login...\n")
+
+	email, password, err := c.Credentials.Login()
+	if err != nil {
+		color.Red("✗ Failed to read the RescueTime login: %v\n", err)
+		return keyRefreshFailed
+	}
+	keys, err := c.Activate(email, password)
+	if err != nil {
+		color.Red("✗ Failed to refresh native API keys: %v\n", err)
+		var activationErr *ActivationError
+		if errors.As(err, &activationErr) {
+			color.Yellow("  %s\n", activationErr.Remediation)
+		}
+		return keyRefreshFailed
+	}
+
+	c.AccountKey = keys.AccountKey
+	if keys.DataKey != "" {
+		c.DataKey = keys.DataKey
+	}
+	if err := c.Credentials.SaveKeys(keys); err != nil {
+		color.Yellow("[WARNING] Refreshed native API keys but failed to save them: %v\n", err)
+	}
+	color.Green("Refreshed native API keys\n")
+	return keyRefreshSucceeded
+}
@@ -0,0 +1,168 @@
+package rescuetime
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCredentialStore returns a fixed login and records the keys saved to it
+type fakeCredentialStore struct {
+	loginErr error
+	saved    []*ActivationResponse
+}
+
+func (f *fakeCredentialStore) Login() (string, string, error) {
+	return "jane@example.com", "p&ss=word", f.loginErr
+}
+
+func (f *fakeCredentialStore) SaveKeys(keys *ActivationResponse) error {
+	f.saved = append(f.saved, keys)
+	return nil
+}
+
+// refreshServer is a native API that only accepts validKey, an activation endpoint that
+// hands out new-account-key (or fails), and a legacy endpoint that counts fallbacks
+type refreshServer struct {
+	mu          sync.Mutex
+	validKey    string
+	activateErr bool
+	activations []string // email:password logins sent to the activation endpoint
+	native      int      // accepted native submissions
+	legacy      int      // legacy submissions
+}
+
+func (s *refreshServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/activate":
+		r.ParseForm()
+		s.activations = append(s.activations, r.PostForm.Get("username")+":"+r.PostForm.Get("password"))
+		if s.activateErr {
+			w.Write([]byte("c:\n- 1\n- RT:error\n"))
+			return
+		}
+		s.validKey = "new-account-key"
+		w.Write([]byte("c:\n- 0\n- RT:ok\naccount_key: new-account-key\n"))
+	case "/native":
+		if r.URL.Query().Get("key") != s.validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.native++
+	case "/legacy":
+		s.legacy++
+	}
+}
+
+// newRefreshClient returns a client with native keys the server no longer accepts
+func newRefreshClient(server *httptest.Server, store CredentialStore) *Client {
+	return &Client{
+		APIKey:      "legacy-key",
+		AccountKey:  "old-account-key",
+		DataKey:     "old-data-key",
+		Credentials: store,
+		legacyURL:   server.URL + "/legacy",
+		nativeURL:   server.URL + "/native",
+		activateURL: server.URL + "/activate",
+	}
+}
+
+// refreshSummaries returns two eligible summaries, so only the first should trigger a refresh
+func refreshSummaries() map[string]ActivitySummary {
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	return map[string]ActivitySummary{
+		"code":    {AppClass: "code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(10 * time.Minute)},
+		"firefox": {AppClass: "firefox", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: start.Add(time.Hour), LastSeen: start.Add(70 * time.Minute)},
+	}
+}
+
+// TestNativeUnauthorizedRefreshesKeys tests that persistent 401s refresh the keys with the
+// stored login, save them and retry the submission natively instead of falling back
+func TestNativeUnauthorizedRefreshesKeys(t *testing.T) {
+	backend := &refreshServer{validKey: "revoked"}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	store := &fakeCredentialStore{}
+	client := newRefreshClient(server, store)
+	skipBackoff(client)
+
+	unsent := client.SubmitActivitiesContext(context.Background(), refreshSummaries())
+
+	if len(unsent) != 0 {
+		t.Errorf("Expected everything sent, %d unsent", len(unsent))
+	}
+	if len(backend.activations) != 1 || backend.activations[0] != "jane@example.com:p&ss=word" {
+		t.Errorf("Expected one activation with the form-encoded login, got %q", backend.activations)
+	}
+	if backend.native != 2 || backend.legacy != 0 {
+		t.Errorf("Expected both summaries sent natively, got %d native and %d legacy", backend.native, backend.legacy)
+	}
+	if client.AccountKey != "new-account-key" || client.DataKey != "old-data-key" {
+		t.Errorf("Expected only the account key replaced, got %q / %q", client.AccountKey, client.DataKey)
+	}
+	if len(store.saved) != 1 || store.saved[0].AccountKey != "new-account-key" {
+		t.Errorf("Expected the new keys saved once, got %+v", store.saved)
+	}
+}
+
+// TestNativeUnauthorizedRefreshFails tests that when the refresh fails too, the summaries are
+// queued for a later run rather than sent through the legacy fallback, after a single attempt
+func TestNativeUnauthorizedRefreshFails(t *testing.T) {
+	for name, setup := range map[string]func(*refreshServer, *fakeCredentialStore){
+		"activation rejected": func(s *refreshServer, _ *fakeCredentialStore) { s.activateErr = true },
+		"no login":            func(_ *refreshServer, f *fakeCredentialStore) { f.loginErr = errors.New("keyring locked") },
+	} {
+		backend := &refreshServer{validKey: "revoked"}
+		store := &fakeCredentialStore{}
+		setup(backend, store)
+		server := httptest.NewServer(backend)
+		client := newRefreshClient(server, store)
+		skipBackoff(client)
+
+		unsent := client.SubmitActivitiesContext(context.Background(), refreshSummaries())
+		server.Close()
+
+		if len(unsent) != 2 {
+			t.Errorf("%s: expected both summaries returned for queuing, got %d", name, len(unsent))
+		}
+		if backend.legacy != 0 || backend.native != 0 {
+			t.Errorf("%s: expected no submissions, got %d native and %d legacy", name, backend.native, backend.legacy)
+		}
+		if len(backend.activations) > 1 {
+			t.Errorf("%s: expected at most one activation, got %d", name, len(backend.activations))
+		}
+		if len(store.saved) != 0 || client.AccountKey != "old-account-key" {
+			t.Errorf("%s: expected the keys left alone, saved %+v", name, store.saved)
+		}
+	}
+}
+
+// TestNativeUnauthorizedWithoutCredentials tests that without a CredentialStore the legacy
+// fallback is used as before
+func TestNativeUnauthorizedWithoutCredentials(t *testing.T) {
+	backend := &refreshServer{validKey: "revoked"}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	client := newRefreshClient(server, nil)
+	skipBackoff(client)
+
+	summaries := refreshSummaries()
+	delete(summaries, "firefox")
+	err := client.SubmitNative(SummaryToUserClientEvent(summaries["code"]))
+	if !errors.Is(err, ErrNativeUnauthorized) || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected ErrNativeUnauthorized with the status, got %v", err)
+	}
+
+	client.SubmitActivities(summaries)
+	if backend.legacy != 1 || len(backend.activations) != 0 {
+		t.Errorf("Expected a legacy fallback and no activation, got %d legacy and %d activations", backend.legacy, len(backend.activations))
+	}
+}
@@ -0,0 +1,104 @@
+package rescuetime
+
+import (
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Submission outcomes, the buckets of a SubmissionResult
+const (
+	OutcomeSucceededNative = "succeeded-native"      // accepted by the native API
+	OutcomeSucceededLegacy = "succeeded-legacy"      // accepted by the legacy API, directly or as the fallback
+	OutcomeFailedNative    = "failed-native"         // refused by the native API, with no legacy fallback
+	OutcomeFailedLegacy    = "failed-legacy"         // refused by the legacy API
+	OutcomeSkippedShort    = "skipped-short"         // below the minimum duration
+	OutcomeSkippedHours    = "skipped-outside-hours" // started outside ActiveHours
+	OutcomeSkippedInvalid  = "skipped-invalid"       // failed ValidatePayload, never sent
+	OutcomeUnsent          = "unsent"                // cut off by the deadline or rejected keys, returned to queue
+
+	// OutcomeWouldSubmit is a dry-run preview's bucket for summaries a submission would send
+	OutcomeWouldSubmit = "would-submit"
+)
+
+// The buckets Print and PrintPreview list, in order
+var (
+	outcomeOrder = []string{
+		OutcomeSucceededNative, OutcomeSucceededLegacy,
+		OutcomeFailedNative, OutcomeFailedLegacy,
+		OutcomeSkippedShort, OutcomeSkippedHours, OutcomeSkippedInvalid,
+		OutcomeUnsent,
+	}
+	previewOrder = []string{OutcomeWouldSubmit, OutcomeSkippedShort, OutcomeSkippedHours, OutcomeSkippedInvalid}
+)
+
+// SubmissionOutcome is what happened to one summary (or chunk of one)
+type SubmissionOutcome struct {
+	AppClass string        `json:"app_class"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Outcome  string        `json:"outcome"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+// SubmissionResult accounts for every summary passed to SubmitActivitiesResult
+type SubmissionResult struct {
+	Outcomes []SubmissionOutcome
+	// Unsent are the summaries with OutcomeUnsent, for the caller to queue
+	Unsent map[string]ActivitySummary
+	// Failed are the failed summaries RescueTime didn't refuse but couldn't be reached for
+	// (ErrUnavailable), for the caller to retry; rejected and invalid ones aren't included
+	Failed map[string]ActivitySummary
+}
+
+// Add records the outcome for summary
+func (r *SubmissionResult) Add(summary ActivitySummary, outcome, reason string) {
+	r.Outcomes = append(r.Outcomes, SubmissionOutcome{
+		AppClass: summary.AppClass,
+		Start:    summary.FirstSeen,
+		Duration: summary.TotalDuration,
+		Outcome:  outcome,
+		Reason:   reason,
+	})
+}
+
+// Count returns how many summaries ended with outcome
+func (r SubmissionResult) Count(outcome string) int {
+	count := 0
+	for _, o := range r.Outcomes {
+		if o.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Print lists every bucket, so "nothing sent" is clearly "everything was too short" rather
+// than a broken submission
+func (r SubmissionResult) Print(title string) {
+	r.printBuckets(title, outcomeOrder)
+}
+
+// PrintPreview lists a dry-run's buckets: what would be submitted and what would be skipped
+func (r SubmissionResult) PrintPreview(title string) {
+	r.printBuckets(title, previewOrder)
+}
+
+func (r SubmissionResult) printBuckets(title string, order []string) {
+	color.New(color.FgCyan, color.Bold).Printf("\n=== %s ===\n", title)
+	for _, outcome := range order {
+		count := r.Count(outcome)
+		line := color.New(color.Faint)
+		if count > 0 {
+			switch outcome {
+			case OutcomeSucceededNative, OutcomeSucceededLegacy, OutcomeWouldSubmit:
+				line = color.New(color.FgGreen)
+			case OutcomeFailedNative, OutcomeFailedLegacy:
+				line = color.New(color.FgRed)
+			default:
+				line = color.New(color.FgYellow)
+			}
+		}
+		line.Printf("  %-22s %d\n", outcome+":", count)
+	}
+}
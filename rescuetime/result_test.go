@@ -0,0 +1,86 @@
+package rescuetime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSubmissionResultBuckets tests that a mixed batch lands in the right buckets: sent
+// natively, sent through the legacy fallback, refused by each API, and skipped as too short,
+// invalid or outside active hours
+func TestSubmissionResultBuckets(t *testing.T) {
+	nativeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload UserClientEventPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload.UserClientEvent.Application != "code" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer nativeServer.Close()
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload RescueTimePayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload.ActivityName == "gimp" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer legacyServer.Close()
+
+	client := NewClient("legacy-key", "", "data-key")
+	client.nativeURL, client.legacyURL = nativeServer.URL, legacyServer.URL
+	client.MinNativeDuration = time.Minute
+	skipBackoff(client)
+	schedule, err := ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	client.ActiveHours = schedule
+
+	start := time.Date(2025, 10, 31, 10, 0, 0, 0, time.Local) // a Friday
+	summary := func(app string, offset, duration time.Duration) ActivitySummary {
+		return ActivitySummary{AppClass: app, TotalDuration: duration, SessionCount: 1, FirstSeen: start.Add(offset), LastSeen: start.Add(offset + duration)}
+	}
+	summaries := map[string]ActivitySummary{
+		"code":  summary("code", 0, 10*time.Minute),
+		"zoom":  summary("zoom", 15*time.Minute, 10*time.Minute),
+		"slack": summary("slack", 30*time.Minute, 2*time.Minute),
+		"gimp":  summary("gimp", 45*time.Minute, 10*time.Minute),
+		"tiny":  summary("tiny", time.Hour, 30*time.Second),
+		"blank": summary("", 75*time.Minute, 10*time.Minute),
+		"late":  summary("late", 10*time.Hour, 10*time.Minute),
+	}
+
+	result := client.SubmitActivitiesResult(context.Background(), summaries)
+
+	want := map[string]int{
+		OutcomeSucceededNative: 1,
+		OutcomeSucceededLegacy: 1,
+		OutcomeFailedNative:    1,
+		OutcomeFailedLegacy:    1,
+		OutcomeSkippedShort:    1,
+		OutcomeSkippedHours:    1,
+		OutcomeSkippedInvalid:  1,
+		OutcomeUnsent:          0,
+	}
+	for outcome, count := range want {
+		if got := result.Count(outcome); got != count {
+			t.Errorf("%s: expected %d, got %d", outcome, count, got)
+		}
+	}
+	if len(result.Failed) != 0 {
+		t.Errorf("Expected refused summaries not to be retried, got %v", result.Failed)
+	}
+	if len(result.Outcomes) != len(summaries) {
+		t.Errorf("Expected an outcome per summary, got %+v", result.Outcomes)
+	}
+	for _, outcome := range result.Outcomes {
+		succeeded := outcome.Outcome == OutcomeSucceededNative
+		if !succeeded && outcome.Reason == "" {
+			t.Errorf("Expected a reason for %s (%s)", outcome.AppClass, outcome.Outcome)
+		}
+	}
+}
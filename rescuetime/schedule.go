@@ -0,0 +1,126 @@
+package rescuetime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdayNames maps three-letter day abbreviations to time.Weekday for ParseSchedule
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule is a weekly set of active hours, e.g. "Mon-Fri 09:00-18:00".
+// Times are compared in the location of the time being checked (local time for tracked activity).
+type Schedule struct {
+	spec    string
+	windows []scheduleWindow
+}
+
+// scheduleWindow is one "Days HH:MM-HH:MM" entry; start is inclusive, end exclusive
+type scheduleWindow struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end time.Duration
+}
+
+// ParseSchedule parses a comma-separated list of "Days HH:MM-HH:MM" entries, where Days is a
+// day ("Sat") or an inclusive range ("Mon-Fri", "Fri-Mon" wraps over the weekend).
+// Example: "Mon-Fri 09:00-18:00, Sat 10:00-12:00". Windows can't cross midnight; use 24:00
+// as the end of the day and a second entry for the next morning.
+func ParseSchedule(spec string) (*Schedule, error) {
+	schedule := &Schedule{spec: strings.TrimSpace(spec)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		window, err := parseScheduleWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid active hours %q: %v\nExpected entries like \"Mon-Fri 09:00-18:00\", separated by commas", entry, err)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	if len(schedule.windows) == 0 {
+		return nil, fmt.Errorf("active hours schedule is empty\nExpected entries like \"Mon-Fri 09:00-18:00\", separated by commas")
+	}
+	return schedule, nil
+}
+
+// parseScheduleWindow parses a single "Days HH:MM-HH:MM" entry
+func parseScheduleWindow(entry string) (scheduleWindow, error) {
+	var window scheduleWindow
+	fields := strings.Fields(entry)
+	if len(fields) != 2 {
+		return window, fmt.Errorf("expected days and a time range separated by a space")
+	}
+
+	first, last, isRange := strings.Cut(fields[0], "-")
+	from, ok := weekdayNames[strings.ToLower(first)]
+	if !ok {
+		return window, fmt.Errorf("unknown day %q (use Mon, Tue, Wed, Thu, Fri, Sat or Sun)", first)
+	}
+	to := from
+	if isRange {
+		if to, ok = weekdayNames[strings.ToLower(last)]; !ok {
+			return window, fmt.Errorf("unknown day %q (use Mon, Tue, Wed, Thu, Fri, Sat or Sun)", last)
+		}
+	}
+	for day := from; ; day = (day + 1) % 7 {
+		window.days[day] = true
+		if day == to {
+			break
+		}
+	}
+
+	startText, endText, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return window, fmt.Errorf("time range %q must be HH:MM-HH:MM", fields[1])
+	}
+	var err error
+	if window.start, err = parseClock(startText); err != nil {
+		return window, err
+	}
+	if window.end, err = parseClock(endText); err != nil {
+		return window, err
+	}
+	if window.end <= window.start {
+		return window, fmt.Errorf("end %s must be after start %s", endText, startText)
+	}
+	return window, nil
+}
+
+// parseClock parses HH:MM (00:00 to 24:00) into the offset from midnight
+func parseClock(text string) (time.Duration, error) {
+	if text == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM between 00:00 and 24:00", text)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls within any of the schedule's windows
+func (s *Schedule) Contains(t time.Time) bool {
+	hour, minute, second := t.Clock()
+	sinceMidnight := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+	for _, window := range s.windows {
+		if window.days[t.Weekday()] && sinceMidnight >= window.start && sinceMidnight < window.end {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the schedule as it was given to ParseSchedule
+func (s *Schedule) String() string {
+	return s.spec
+}
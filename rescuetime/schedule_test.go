@@ -0,0 +1,115 @@
+package rescuetime
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScheduleBoundaries tests inclusive start and exclusive end times on working days
+func TestScheduleBoundaries(t *testing.T) {
+	schedule, err := ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+
+	// 2025-10-29 is a Wednesday
+	day := func(clock string) time.Time {
+		ts, err := time.ParseInLocation("2006-01-02 15:04:05", "2025-10-29 "+clock, time.Local)
+		if err != nil {
+			t.Fatalf("bad test time %q: %v", clock, err)
+		}
+		return ts
+	}
+	tests := []struct {
+		clock string
+		want  bool
+	}{
+		{"08:59:59", false},
+		{"09:00:00", true},
+		{"12:30:00", true},
+		{"17:59:59", true},
+		{"18:00:00", false},
+		{"23:30:00", false},
+	}
+	for _, tt := range tests {
+		if got := schedule.Contains(day(tt.clock)); got != tt.want {
+			t.Errorf("Wednesday %s: expected %v, got %v", tt.clock, tt.want, got)
+		}
+	}
+}
+
+// TestScheduleWeekendExclusion tests that days outside the range never match
+func TestScheduleWeekendExclusion(t *testing.T) {
+	schedule, err := ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	// 2025-11-01 is a Saturday, 2025-11-02 a Sunday, 2025-11-03 a Monday
+	for date, want := range map[string]bool{"2025-10-31": true, "2025-11-01": false, "2025-11-02": false, "2025-11-03": true} {
+		ts, _ := time.ParseInLocation("2006-01-02 15:04", date+" 10:00", time.Local)
+		if got := schedule.Contains(ts); got != want {
+			t.Errorf("%s (%s) 10:00: expected %v, got %v", date, ts.Weekday(), want, got)
+		}
+	}
+
+	// Ranges wrap around the end of the week, and entries combine
+	weekend, err := ParseSchedule("fri-sun 20:00-24:00, Sat 00:00-02:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	for clock, want := range map[string]bool{"2025-11-01 23:59": true, "2025-11-01 01:00": true, "2025-11-02 01:00": false, "2025-11-03 21:00": false} {
+		ts, _ := time.ParseInLocation("2006-01-02 15:04", clock, time.Local)
+		if got := weekend.Contains(ts); got != want {
+			t.Errorf("%s (%s): expected %v, got %v", clock, ts.Weekday(), want, got)
+		}
+	}
+}
+
+// TestParseScheduleErrors tests rejected schedules
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"Mon-Fri",
+		"Weekdays 09:00-18:00",
+		"Mon-Fri 9-18",
+		"Mon-Fri 09:00",
+		"Mon-Fri 18:00-09:00",
+		"Mon-Fri 09:00-24:30",
+		"Mon-Fri 09:00-18:00, Sun",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		} else if !strings.Contains(err.Error(), "Mon-Fri 09:00-18:00") {
+			t.Errorf("Expected %q error to show the expected format, got %v", spec, err)
+		}
+	}
+}
+
+// TestSkipReasonActiveHours tests that summaries starting outside active hours are skipped
+func TestSkipReasonActiveHours(t *testing.T) {
+	client := NewClient("test-key", "", "")
+	client.MinLegacyDuration = 0
+
+	// Saturday morning
+	saturday := time.Date(2025, 11, 1, 10, 0, 0, 0, time.Local)
+	summary := ActivitySummary{AppClass: "steam", TotalDuration: 30 * time.Minute, FirstSeen: saturday, LastSeen: saturday.Add(30 * time.Minute)}
+	if reason := client.SkipReason(summary); reason != "" {
+		t.Errorf("Expected no skip without active hours, got %q", reason)
+	}
+
+	schedule, err := ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	client.ActiveHours = schedule
+	if reason := client.SkipReason(summary); !strings.Contains(reason, "outside active hours") {
+		t.Errorf("Expected weekend summary to be skipped, got %q", reason)
+	}
+
+	monday := time.Date(2025, 11, 3, 10, 0, 0, 0, time.Local)
+	summary.FirstSeen, summary.LastSeen = monday, monday.Add(30*time.Minute)
+	if reason := client.SkipReason(summary); reason != "" {
+		t.Errorf("Expected working-hours summary to be eligible, got %q", reason)
+	}
+}
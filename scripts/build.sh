@@ -30,10 +30,16 @@ echo ""
 echo "Downloading dependencies..."
 go mod download
 
-# Build the binary
+# Build the binary, stamped with the version (see internal/version)
+VERSION_PKG="github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || true)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-X $VERSION_PKG.Version=$VERSION -X $VERSION_PKG.Commit=$COMMIT -X $VERSION_PKG.Date=$BUILD_DATE"
+
 echo ""
-echo "Building binaries..."
-go build -o active-window ./cmd/active-window
+echo "Building binaries ($VERSION)..."
+go build -ldflags "$LDFLAGS" -o active-window ./cmd/active-window
 go build -tags ignore_app -o ignoreApplication ./cmd/ignoreApplication
 
 if [ -f "active-window" ] && [ -f "ignoreApplication" ]; then
@@ -0,0 +1,139 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// appStatsAlpha is the weight of each new session in the moving average: recent habits
+// dominate after a few dozen visits, while one long session doesn't swamp the mean
+const appStatsAlpha = 0.2
+
+// AppStats describes how an application is typically used, across runs
+type AppStats struct {
+	MeanSession   time.Duration // exponential moving average of session length
+	TotalDuration time.Duration
+	SessionCount  int
+}
+
+// appStat is the persisted state behind an AppStats. PrevMean and LastSession let a merge
+// into the application's last session redo that session's update instead of adding a new one.
+type appStat struct {
+	Mean        time.Duration `json:"mean"`
+	PrevMean    time.Duration `json:"prev_mean"`
+	LastSession time.Duration `json:"last_session"`
+	Total       time.Duration `json:"total"`
+	Count       int           `json:"count"`
+}
+
+// observe adds a completed session of length d
+func (s *appStat) observe(d time.Duration) {
+	s.PrevMean = s.Mean
+	s.Count++
+	s.Total += d
+	s.LastSession = d
+	s.updateMean()
+}
+
+// extend replaces the last session's length with d, after a session was merged into it
+func (s *appStat) extend(d time.Duration) {
+	s.Total += d - s.LastSession
+	s.LastSession = d
+	s.updateMean()
+}
+
+// updateMean applies LastSession to PrevMean; the first session is the mean
+func (s *appStat) updateMean() {
+	if s.Count == 1 {
+		s.Mean = s.LastSession
+		return
+	}
+	s.Mean = s.PrevMean + time.Duration(appStatsAlpha*float64(s.LastSession-s.PrevMean))
+}
+
+// observeAppStatUnsafe records a completed session under key; merged means it was merged
+// into the key's last session, which now lasts d (must be called with lock held)
+func (at *Tracker) observeAppStatUnsafe(key string, d time.Duration, merged bool) {
+	if at.appStats == nil {
+		at.appStats = make(map[string]*appStat)
+	}
+	stat, ok := at.appStats[key]
+	if !ok {
+		stat = &appStat{}
+		at.appStats[key] = stat
+	}
+	if merged && stat.Count > 0 {
+		stat.extend(d)
+		return
+	}
+	stat.observe(d)
+}
+
+// lastAppSessionUnsafe returns the length of key's last session so far (must be called with lock held)
+func (at *Tracker) lastAppSessionUnsafe(key string) time.Duration {
+	if stat, ok := at.appStats[key]; ok {
+		return stat.LastSession
+	}
+	return 0
+}
+
+// GetAppStats returns per-key session statistics over completed sessions, including
+// previous runs when they were loaded with LoadAppStats. Ignored applications aren't counted.
+func (at *Tracker) GetAppStats() map[string]AppStats {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+
+	stats := make(map[string]AppStats, len(at.appStats))
+	for key, stat := range at.appStats {
+		stats[key] = AppStats{MeanSession: stat.Mean, TotalDuration: stat.Total, SessionCount: stat.Count}
+	}
+	return stats
+}
+
+// SaveAppStats writes the per-key statistics to path, replacing it atomically
+func (at *Tracker) SaveAppStats(path string) error {
+	at.mu.RLock()
+	data, err := json.MarshalIndent(at.appStats, "", "  ")
+	at.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal app stats: %v", err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't corrupt the stats
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write app stats: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace app stats file: %v", err)
+	}
+	return nil
+}
+
+// LoadAppStats restores statistics saved by an earlier run from path. A missing file is ignored.
+func (at *Tracker) LoadAppStats(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read app stats file %s: %v", path, err)
+	}
+
+	var saved map[string]*appStat
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse app stats file %s: %v\n\nDelete the file to start the statistics from zero", path, err)
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.appStats = make(map[string]*appStat, len(saved))
+	for key, stat := range saved {
+		if stat != nil {
+			at.appStats[key] = stat
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAppStatsMovingAverage tests that each completed session moves the mean by
+// appStatsAlpha, a merged session redoes its update, and ignored apps aren't counted
+func TestAppStatsMovingAverage(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minutes float64) time.Time { return start.Add(time.Duration(minutes * float64(time.Minute))) }
+
+	mean := func(key string) time.Duration { return tracker.GetAppStats()[key].MeanSession.Round(time.Millisecond) }
+
+	runSession(tracker, "slack", at(0), at(10))
+	if got := mean("slack"); got != 10*time.Minute {
+		t.Errorf("Expected the first session as the mean, got %v", got)
+	}
+	runSession(tracker, "firefox", at(10), at(30))
+	runSession(tracker, "slack", at(30), at(35))
+	if got := mean("slack"); got != 9*time.Minute {
+		t.Errorf("Expected 10m + 0.2*(5m-10m) = 9m, got %v", got)
+	}
+
+	// Back within the merge threshold: the 5m session becomes 6m rather than a new 1m visit
+	runSession(tracker, "slack", at(35).Add(20*time.Second), at(36))
+	stats := tracker.GetAppStats()["slack"]
+	if got := mean("slack"); got != 9*time.Minute+12*time.Second {
+		t.Errorf("Expected 10m + 0.2*(6m-10m) = 9m12s after the merge, got %v", got)
+	}
+	if stats.SessionCount != 2 || stats.TotalDuration != 16*time.Minute {
+		t.Errorf("Expected 2 sessions totalling 16m, got %d / %v", stats.SessionCount, stats.TotalDuration)
+	}
+
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: at(36), AppClass: "spotify", Active: true, Ignored: true}
+	tracker.endCurrentSessionUnsafe(at(50))
+	tracker.mu.Unlock()
+	if _, ok := tracker.GetAppStats()["spotify"]; ok {
+		t.Error("Expected ignored applications to have no stats")
+	}
+	if got := tracker.GetAppStats()["firefox"]; got.MeanSession != 20*time.Minute || got.SessionCount != 1 {
+		t.Errorf("Expected firefox at one 20m session, got %+v", got)
+	}
+}
+
+// TestAppStatsSurviveRestart tests that saved statistics are loaded and keep averaging
+func TestAppStatsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app-stats.json")
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	tracker := New()
+	runSession(tracker, "slack", start, start.Add(10*time.Minute))
+	tracker.ClearCompletedSessions()
+	if err := tracker.SaveAppStats(path); err != nil {
+		t.Fatalf("SaveAppStats failed: %v", err)
+	}
+
+	restarted := New()
+	if err := restarted.LoadAppStats(path); err != nil {
+		t.Fatalf("LoadAppStats failed: %v", err)
+	}
+	runSession(restarted, "slack", start.Add(time.Hour), start.Add(time.Hour+5*time.Minute))
+
+	stats := restarted.GetAppStats()["slack"]
+	if stats.MeanSession.Round(time.Millisecond) != 9*time.Minute || stats.SessionCount != 2 || stats.TotalDuration != 15*time.Minute {
+		t.Errorf("Expected the restart to continue from the saved mean, got %+v", stats)
+	}
+
+	if err := New().LoadAppStats(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Expected a missing file to be ignored, got %v", err)
+	}
+}
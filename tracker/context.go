@@ -0,0 +1,42 @@
+package tracker
+
+// SetContext labels the sessions that start from now on with label (e.g. work or personal),
+// so the same application is summarized and reported separately per context; "" clears it.
+// A change ends the active session at now and continues its window in a new session under
+// the new label, as switching to another window would.
+func (at *Tracker) SetContext(label string) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if label == at.context {
+		return
+	}
+	at.context = label
+
+	session := at.currentSession
+	if session == nil || !session.Active {
+		return
+	}
+	now := at.now()
+	at.endCurrentSessionUnsafe(now)
+	at.currentSession = at.newSessionUnsafe(session.AppClass, session.WindowTitle, session.Key, now, session.Ignored)
+	if !session.geometryAt.IsZero() {
+		at.currentSession.maximized = session.maximized
+		at.currentSession.geometryAt = now
+	}
+}
+
+// Context returns the label SetContext set, "" for none
+func (at *Tracker) Context() string {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return at.context
+}
+
+// contextKey returns key as summarized under context: "[work] firefox", or key itself
+// without one
+func contextKey(context, key string) string {
+	if context == "" {
+		return key
+	}
+	return "[" + context + "] " + key
+}
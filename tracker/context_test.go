@@ -0,0 +1,65 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSetContext tests that a context change splits the active session, that sessions of
+// the same application in different contexts neither merge nor share a summary, and that
+// clearing the context goes back to plain keys
+func TestSetContext(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	now := start
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+
+	tracker.SetContext("work")
+	tracker.StartSession("firefox", "Jira")
+	now = at(20)
+	tracker.SetContext("personal")
+	if tracker.Context() != "personal" {
+		t.Errorf("Expected the personal context, got %q", tracker.Context())
+	}
+
+	// Same window, no gap, but a different context: not merged
+	now = at(30)
+	tracker.SetContext("")
+	now = at(35)
+	tracker.EndCurrentSession()
+
+	sessions := tracker.GetSessions()
+	if len(sessions) != 3 {
+		t.Fatalf("Expected the context changes to split the session in three, got %+v", sessions)
+	}
+	for i, want := range []string{"work", "personal", ""} {
+		if sessions[i].Context != want || sessions[i].AppClass != "firefox" || sessions[i].WindowTitle != "Jira" {
+			t.Errorf("Session %d: expected firefox (Jira) in context %q, got %+v", i, want, sessions[i])
+		}
+	}
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	want := map[string]time.Duration{"[work] firefox": 20 * time.Minute, "[personal] firefox": 10 * time.Minute, "firefox": 5 * time.Minute}
+	if len(summaries) != len(want) {
+		t.Fatalf("Expected a summary per context, got %v", summaries)
+	}
+	for key, duration := range want {
+		summary := summaries[key]
+		if summary.TotalDuration != duration || summary.AppClass != "firefox" {
+			t.Errorf("%s: expected firefox for %v, got %+v", key, duration, summary)
+		}
+	}
+	if summaries["[work] firefox"].Context != "work" || summaries["[work] firefox"].ActivityName() != "[work] firefox" {
+		t.Errorf("Expected the work summary to carry its context, got %+v", summaries["[work] firefox"])
+	}
+
+	// Setting the same context again leaves the session alone
+	now = at(40)
+	tracker.StartSession("code", "main.go")
+	now = at(50)
+	tracker.SetContext("")
+	if current, ok := tracker.CurrentSession(); !ok || !current.StartTime.Equal(at(40)) {
+		t.Errorf("Expected an unchanged context to keep the session, got %+v", current)
+	}
+}
@@ -0,0 +1,64 @@
+package tracker
+
+import (
+	"math"
+	"time"
+)
+
+// SetPollInterval sets the interval the caller polls the focused window at, from which a session's duration
+// gives its ExpectedPolls; 0 leaves coverage unknown
+func (at *Tracker) SetPollInterval(interval time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.pollInterval = interval
+}
+
+// ObservePoll records that a poll saw the current session's window focused. The monitor
+// calls it after every poll that read the window, so polls lost to D-Bus errors or a stalled
+// process show up as a coverage below 1. With SetActiveHours, it also splits the session
+// where it crosses the schedule's edge.
+func (at *Tracker) ObservePoll() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.splitAtActiveHoursUnsafe(at.now())
+	if at.currentSession != nil && at.currentSession.Active {
+		at.currentSession.Polls++
+	}
+}
+
+// expectedPollsUnsafe returns how many polls a session lasting duration spans (at least one),
+// or 0 without a poll interval (must be called with at least a read lock held)
+func (at *Tracker) expectedPollsUnsafe(duration time.Duration) int {
+	if at.pollInterval <= 0 {
+		return 0
+	}
+	return max(1, int(math.Round(float64(duration)/float64(at.pollInterval))))
+}
+
+// CurrentCoverage returns the coverage of the active session as of now and the number of
+// polls it was expected to have; 0, 0 without one
+func (at *Tracker) CurrentCoverage(now time.Time) (float64, int) {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	session := at.currentSession
+	if session == nil || !session.Active {
+		return 0, 0
+	}
+	expected := at.expectedPollsUnsafe(now.Sub(session.StartTime))
+	return session.coverageAt(expected), expected
+}
+
+// Coverage returns the share of the session's expected polls that saw it, from 0 to 1, or 0
+// when ExpectedPolls is unknown. Tracking is poll-based, so Duration is only accurate to
+// about one poll interval even at full coverage, and less so as polls go missing.
+func (s *Session) Coverage() float64 {
+	return s.coverageAt(s.ExpectedPolls)
+}
+
+// coverageAt returns Polls as a share of expected, capped at 1; 0 if expected is unknown
+func (s *Session) coverageAt(expected int) float64 {
+	if expected <= 0 {
+		return 0
+	}
+	return min(1, float64(s.Polls)/float64(expected))
+}
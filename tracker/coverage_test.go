@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// pollSession runs a session of app from start to end on a tracker polling every second,
+// observing the polls at the seconds seen reports true for
+func pollSession(tracker *Tracker, app string, start, end time.Time, seen func(second int) bool) {
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start, AppClass: app, Active: true}
+	tracker.mu.Unlock()
+	for second := 0; start.Add(time.Duration(second) * time.Second).Before(end); second++ {
+		if seen(second) {
+			tracker.ObservePoll()
+		}
+	}
+	tracker.mu.Lock()
+	tracker.endCurrentSessionUnsafe(end)
+	tracker.mu.Unlock()
+}
+
+// TestSessionCoverageWithMissedPolls tests the coverage of sessions with every poll, with a
+// quarter of them failing, and with a long stall
+func TestSessionCoverageWithMissedPolls(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		seen func(second int) bool
+		want float64
+	}{
+		{"every poll", func(int) bool { return true }, 1},
+		{"every fourth poll fails", func(second int) bool { return second%4 != 3 }, 0.75},
+		{"stalled for 40s", func(second int) bool { return second < 10 || second >= 50 }, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := New()
+			tracker.SetPollInterval(time.Second)
+			pollSession(tracker, "code", start, start.Add(time.Minute), tt.seen)
+
+			session := tracker.GetSessions()[0]
+			if session.ExpectedPolls != 60 {
+				t.Errorf("Expected 60 expected polls, got %d", session.ExpectedPolls)
+			}
+			if math.Abs(session.Coverage()-tt.want) > 1e-9 {
+				t.Errorf("Expected coverage %v, got %v (%d polls)", tt.want, session.Coverage(), session.Polls)
+			}
+		})
+	}
+}
+
+// TestCoverageUnknownWithoutPollInterval tests that a tracker that isn't polled (e.g. one
+// fed by an import) leaves coverage unknown rather than 0%
+func TestCoverageUnknownWithoutPollInterval(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	pollSession(tracker, "code", start, start.Add(time.Minute), func(int) bool { return false })
+
+	session := tracker.GetSessions()[0]
+	if session.ExpectedPolls != 0 || session.Coverage() != 0 {
+		t.Errorf("Expected unknown coverage, got %d/%d polls", session.Polls, session.ExpectedPolls)
+	}
+}
+
+// TestMergedSessionCoverage tests that a merged session's coverage counts both parts' polls
+// and not the unpolled gap between them
+func TestMergedSessionCoverage(t *testing.T) {
+	tracker := New()
+	tracker.SetPollInterval(time.Second)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	pollSession(tracker, "code", start, start.Add(time.Minute), func(int) bool { return true })
+	pollSession(tracker, "code", start.Add(65*time.Second), start.Add(125*time.Second), func(second int) bool { return second%2 == 0 })
+
+	sessions := tracker.GetSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Expected the sessions to merge, got %d", len(sessions))
+	}
+	if sessions[0].Polls != 90 || sessions[0].ExpectedPolls != 120 {
+		t.Errorf("Expected 90 of 120 polls, got %d of %d", sessions[0].Polls, sessions[0].ExpectedPolls)
+	}
+}
+
+// TestSummaryCoverageWeightedByDuration tests that a summary's coverage weights each
+// session by its duration and that the active session counts too
+func TestSummaryCoverageWeightedByDuration(t *testing.T) {
+	tracker := New()
+	tracker.SetPollInterval(time.Second)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	pollSession(tracker, "code", start, start.Add(30*time.Minute), func(int) bool { return true })
+	pollSession(tracker, "firefox", start.Add(30*time.Minute), start.Add(40*time.Minute), func(int) bool { return true })
+	pollSession(tracker, "code", start.Add(40*time.Minute), start.Add(50*time.Minute), func(second int) bool { return second%5 < 3 })
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	if got := summaries["code"].Coverage; math.Abs(got-0.9) > 1e-9 {
+		t.Errorf("Expected code coverage (30m×1 + 10m×0.6)/40m = 0.9, got %v", got)
+	}
+	if got := summaries["firefox"].Coverage; got != 1 {
+		t.Errorf("Expected full firefox coverage, got %v", got)
+	}
+
+	// 20 of the active session's 40 seconds so far were polled
+	now := start.Add(50*time.Minute + 40*time.Second)
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(50 * time.Minute), AppClass: "firefox", Active: true, Polls: 20}
+	summary := tracker.aggregateSessionsUnsafe(tracker.sessions, true, now)["firefox"]
+	tracker.mu.Unlock()
+	if want := (600 + 0.5*40) / 640.0; math.Abs(summary.Coverage-want) > 1e-9 {
+		t.Errorf("Expected firefox coverage %v with the active session, got %v", want, summary.Coverage)
+	}
+	if coverage, expected := tracker.CurrentCoverage(now); coverage != 0.5 || expected != 40 {
+		t.Errorf("Expected the active session at 20/40 polls, got %v of %d", coverage, expected)
+	}
+}
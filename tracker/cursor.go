@@ -0,0 +1,214 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// Cursor is one destination's position in the tracker's completed sessions. Each
+// destination consumes sessions at its own pace (e.g. webhooks every minute, RescueTime every
+// 30 minutes) and the tracker only drops a session once every cursor has moved past it.
+type Cursor struct {
+	name string
+	next uint64 // number of the first session this destination hasn't consumed
+}
+
+// Submission is what a destination hasn't consumed yet (see Tracker.Consume)
+type Submission struct {
+	Completed map[string]ActivitySummary // completed sessions within active hours only, so RescueTime never gets the active session twice
+	All       map[string]ActivitySummary // including the active session, for real-time backends
+	Sessions  []Session                  // completed sessions, including ignored applications and secondary sessions
+	Final     bool                       // the last submission before shutdown
+}
+
+// NewCursor registers a destination that consumes completed sessions, starting with the
+// ones the tracker holds now (e.g. replayed from the WAL)
+func (at *Tracker) NewCursor(name string) *Cursor {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	cursor := &Cursor{name: name, next: at.floor}
+	at.cursors = append(at.cursors, cursor)
+	return cursor
+}
+
+// takeSeqUnsafe numbers a session being stored (must be called with lock held)
+func (at *Tracker) takeSeqUnsafe() uint64 {
+	seq := at.nextSeq
+	at.nextSeq++
+	return seq
+}
+
+// Consume returns a Submission of the completed sessions cursor hasn't seen yet and moves
+// it past them. Completed and Sessions hold only those sessions; All adds the active
+// session as of now, as before. Consumed sessions are sealed so a later merge can't extend
+// them behind the destination's back. They're only dropped by CompactSessions.
+func (at *Tracker) Consume(cursor *Cursor) Submission {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	return at.consumeUnsafe(cursor, at.now())
+}
+
+// DrainCompletedSessions freezes the tracker at now for a submission: it splits the active
+// session (and any secondary session) at now, so the time up to the snapshot is a completed
+// session and the rest starts a new one, then consumes a Submission for each cursor, all
+// under one lock. Sessions completed while the submissions are in flight belong to the next
+// drain, and no active time is handed out twice.
+func (at *Tracker) DrainCompletedSessions(now time.Time, cursors ...*Cursor) []Submission {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.splitCurrentSessionUnsafe(now)
+	at.splitSecondarySessionUnsafe(now)
+	submissions := make([]Submission, len(cursors))
+	for i, cursor := range cursors {
+		submissions[i] = at.consumeUnsafe(cursor, now)
+	}
+	return submissions
+}
+
+// PendingDuration returns how much submittable time a drain at now would hand cursor: its
+// unconsumed completed sessions, plus the active session if the drain would split it.
+// Ignored sessions, secondary ones and those outside active hours don't count.
+func (at *Tracker) PendingDuration(cursor *Cursor, now time.Time) time.Duration {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	var total time.Duration
+	for _, session := range unconsumed(at.sessions, cursor.next) {
+		if !session.OutsideActiveHours {
+			total += session.Duration
+		}
+	}
+	if session := at.currentSession; session != nil && session.Active && !session.Ignored && !session.OutsideActiveHours {
+		if elapsed := now.Sub(session.StartTime); elapsed >= at.minDuration {
+			total += elapsed
+		}
+	}
+	return total
+}
+
+// splitCurrentSessionUnsafe ends the active session at now and continues it in a new one.
+// A session that hasn't met the minimum duration is left alone, as ending it would drop it
+// (must be called with lock held).
+func (at *Tracker) splitCurrentSessionUnsafe(now time.Time) {
+	session := at.currentSession
+	if session == nil || !session.Active || now.Sub(session.StartTime) < at.minDuration {
+		return
+	}
+
+	at.endCurrentSessionUnsafe(now)
+	at.currentSession = &Session{
+		StartTime:          now,
+		AppClass:           session.AppClass,
+		Key:                session.Key,
+		WindowTitle:        session.WindowTitle,
+		Active:             true,
+		Ignored:            session.Ignored,
+		OutsideActiveHours: session.OutsideActiveHours,
+		Context:            session.Context,
+		continued:          true,
+	}
+	// The window keeps its geometry across the split
+	if !session.geometryAt.IsZero() {
+		at.currentSession.maximized = session.maximized
+		at.currentSession.geometryAt = now
+	}
+}
+
+// consumeUnsafe builds cursor's Submission as of now and moves it past the sessions in it
+// (must be called with lock held)
+func (at *Tracker) consumeUnsafe(cursor *Cursor, now time.Time) Submission {
+	sessions := unconsumed(at.sessions, cursor.next)
+	ignored := unconsumed(at.ignoredSessions, cursor.next)
+	secondary := unconsumed(at.secondarySessions, cursor.next)
+
+	submission := Submission{
+		Completed: at.aggregateSessionsUnsafe(insideActiveHours(sessions), false, now),
+		All:       at.aggregateSessionsUnsafe(sessions, true, now),
+		Sessions:  make([]Session, 0, len(sessions)+len(ignored)+len(secondary)),
+	}
+	submission.Sessions = append(submission.Sessions, sessions...)
+	submission.Sessions = append(submission.Sessions, ignored...)
+	submission.Sessions = append(submission.Sessions, secondary...)
+
+	cursor.next = at.nextSeq
+	at.sealed = at.nextSeq
+	return submission
+}
+
+// Rewind moves cursor back to the earliest of sessions, taken from Submissions it consumed, so
+// CompactSessions keeps them (and the WAL with them, for ReplayWAL after a restart) and the
+// next Consume hands them to it again, along with the sessions after them. It returns how
+// many of sessions were already dropped by CompactSessions and can't be kept.
+func (at *Tracker) Rewind(cursor *Cursor, sessions []Session) int {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	dropped := 0
+	for _, session := range sessions {
+		if session.seq < at.floor {
+			dropped++
+		} else if session.seq < cursor.next {
+			cursor.next = session.seq
+		}
+	}
+	return dropped
+}
+
+// insideActiveHours returns the sessions not tagged OutsideActiveHours, which alone go to
+// RescueTime
+func insideActiveHours(sessions []Session) []Session {
+	inside := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		if !session.OutsideActiveHours {
+			inside = append(inside, session)
+		}
+	}
+	return inside
+}
+
+// CompactSessions drops the completed sessions every cursor has consumed and rewrites the
+// WAL to hold only the rest. It does nothing until a cursor has been registered.
+func (at *Tracker) CompactSessions() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if len(at.cursors) == 0 {
+		return
+	}
+	lowest := at.nextSeq
+	for _, cursor := range at.cursors {
+		if cursor.next < lowest {
+			lowest = cursor.next
+		}
+	}
+	if lowest == at.floor {
+		return
+	}
+
+	at.sessions = unconsumed(at.sessions, lowest)
+	at.ignoredSessions = unconsumed(at.ignoredSessions, lowest)
+	at.secondarySessions = unconsumed(at.secondarySessions, lowest)
+	at.floor = lowest
+
+	// What's left is still owed to some destination, so a restart must replay only that
+	if at.wal == nil {
+		return
+	}
+	if err := at.wal.Rewrite(at.heldSessionsUnsafe()); err != nil {
+		logging.Warning("%v", err)
+	}
+}
+
+// unconsumed returns a copy of the sessions numbered from next on. Sessions are stored in
+// order, so that's a suffix of the slice.
+func unconsumed(sessions []Session, next uint64) []Session {
+	i := len(sessions)
+	for i > 0 && sessions[i-1].seq >= next {
+		i--
+	}
+	rest := make([]Session, len(sessions)-i)
+	copy(rest, sessions[i:])
+	return rest
+}
@@ -0,0 +1,268 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestCursorsConsumeIndependently tests that each cursor gets every session once and that
+// sessions are only dropped, from the tracker and the WAL, once every cursor has them
+func TestCursorsConsumeIndependently(t *testing.T) {
+	wal, err := OpenWAL(filepath.Join(t.TempDir(), "wal.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+	tracker := New()
+	tracker.SetWAL(wal)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	fast := tracker.NewCursor("fast")
+	slow := tracker.NewCursor("slow")
+
+	if got := tracker.Consume(fast); len(got.Sessions) != 1 || got.Completed["code"].SessionCount != 1 {
+		t.Fatalf("Expected the session held before the cursor was created, got %+v", got)
+	}
+	tracker.CompactSessions()
+	if sessions := tracker.GetSessions(); len(sessions) != 1 {
+		t.Fatalf("Expected the session to be kept for the slow cursor, got %d", len(sessions))
+	}
+
+	runSession(tracker, "firefox", start.Add(10*time.Minute), start.Add(20*time.Minute))
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(20 * time.Minute), AppClass: "slack", Ignored: true, Active: true}
+	tracker.endCurrentSessionUnsafe(start.Add(30 * time.Minute))
+	tracker.mu.Unlock()
+
+	got := tracker.Consume(fast)
+	if len(got.Sessions) != 2 || got.Sessions[0].AppClass != "firefox" || got.Sessions[1].AppClass != "slack" {
+		t.Errorf("Expected only the new regular and ignored sessions, got %+v", got.Sessions)
+	}
+	if _, ok := got.Completed["code"]; ok || len(got.Completed) != 1 {
+		t.Errorf("Expected summaries of the new sessions only, got %+v", got.Completed)
+	}
+	if got := tracker.Consume(slow); len(got.Sessions) != 3 {
+		t.Errorf("Expected the slow cursor to get all 3 sessions, got %d", len(got.Sessions))
+	}
+	if got := tracker.Consume(fast); len(got.Sessions) != 0 || len(got.Completed) != 0 {
+		t.Errorf("Expected nothing left for the fast cursor, got %+v", got)
+	}
+
+	tracker.CompactSessions()
+	if sessions := tracker.GetAllSessions(); len(sessions) != 0 {
+		t.Errorf("Expected every consumed session to be dropped, got %d", len(sessions))
+	}
+	if entries, _, _ := wal.Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty WAL once every cursor consumed the sessions, got %d entries", len(entries))
+	}
+}
+
+// TestCompactRewritesWAL tests that the WAL keeps exactly the sessions some cursor still needs
+func TestCompactRewritesWAL(t *testing.T) {
+	wal, err := OpenWAL(filepath.Join(t.TempDir(), "wal.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+	tracker := New()
+	tracker.SetWAL(wal)
+	fast := tracker.NewCursor("fast")
+	slow := tracker.NewCursor("slow")
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	tracker.Consume(fast)
+	tracker.Consume(slow)
+	runSession(tracker, "firefox", start.Add(10*time.Minute), start.Add(20*time.Minute))
+	tracker.Consume(fast)
+	tracker.CompactSessions()
+
+	entries, _, err := wal.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].AppClass != "firefox" {
+		t.Fatalf("Expected only the session the slow cursor hasn't consumed, got %+v", entries)
+	}
+
+	// Appending after a rewrite still works
+	runSession(tracker, "slack", start.Add(20*time.Minute), start.Add(30*time.Minute))
+	if entries, _, _ := wal.Entries(); len(entries) != 2 {
+		t.Errorf("Expected 2 entries after appending, got %d", len(entries))
+	}
+}
+
+// TestConsumedSessionIsNotMergedInto tests that a session a cursor has consumed is sealed,
+// so the next close-by session of the same app is stored on its own rather than extending
+// time that was already handed out
+func TestConsumedSessionIsNotMergedInto(t *testing.T) {
+	tracker := New()
+	cursor := tracker.NewCursor("webhook")
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	runSession(tracker, "code", start, start.Add(5*time.Minute))
+	runSession(tracker, "code", start.Add(5*time.Minute+10*time.Second), start.Add(10*time.Minute)) // merges
+	first := tracker.Consume(cursor)
+	runSession(tracker, "code", start.Add(10*time.Minute+10*time.Second), start.Add(15*time.Minute))
+	second := tracker.Consume(cursor)
+
+	if len(first.Sessions) != 1 || first.Sessions[0].Duration != 10*time.Minute {
+		t.Errorf("Expected one merged 10m session, got %+v", first.Sessions)
+	}
+	if len(second.Sessions) != 1 || second.Sessions[0].StartTime != start.Add(10*time.Minute+10*time.Second) {
+		t.Errorf("Expected the later session on its own, got %+v", second.Sessions)
+	}
+}
+
+// TestDrainSplitsActiveSession tests that a drain hands out the active session's time up to
+// the snapshot once, continues the session from there, and still counts one visit
+func TestDrainSplitsActiveSession(t *testing.T) {
+	tracker := New()
+	rescueTime, webhook := tracker.NewCursor("RescueTime"), tracker.NewCursor("webhook")
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	runSession(tracker, "firefox", start, start.Add(10*time.Minute))
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(10 * time.Minute), AppClass: "code", Active: true}
+	tracker.mu.Unlock()
+
+	first := tracker.DrainCompletedSessions(start.Add(30*time.Minute), rescueTime, webhook)
+	for i, submission := range first {
+		if code := submission.Completed["code"]; code.TotalDuration != 20*time.Minute {
+			t.Errorf("Submission %d: expected the active session's 20m so far, got %v", i, code.TotalDuration)
+		}
+		if code := submission.All["code"]; code.TotalDuration != 20*time.Minute {
+			t.Errorf("Submission %d: expected All to hold no more than the snapshot, got %v", i, code.TotalDuration)
+		}
+	}
+
+	// The continuation is stored however short it is, and isn't merged into the sealed part
+	tracker.mu.Lock()
+	tracker.endCurrentSessionUnsafe(start.Add(30*time.Minute + 500*time.Millisecond))
+	tracker.mu.Unlock()
+	second := tracker.DrainCompletedSessions(start.Add(time.Hour), rescueTime)
+	if len(second[0].Sessions) != 1 || second[0].Sessions[0].Duration != 500*time.Millisecond {
+		t.Errorf("Expected the 500ms continuation on its own, got %+v", second[0].Sessions)
+	}
+
+	if stats := tracker.GetAppStats()["code"]; stats.SessionCount != 1 || stats.MeanSession != 20*time.Minute+500*time.Millisecond {
+		t.Errorf("Expected the split session as one visit, got %+v", stats)
+	}
+
+	// Too short to be stored yet: left running rather than ended
+	tracker.StartSession("slack", "general")
+	tracker.DrainCompletedSessions(time.Now(), webhook)
+	if sessions := tracker.GetSessions(); sessions[len(sessions)-1].AppClass == "slack" {
+		t.Error("Expected a session under the minimum duration not to be split")
+	}
+}
+
+// TestPendingDuration tests that the pending time counts a cursor's unconsumed sessions and
+// the active session once a drain would split it, but not ignored time
+func TestPendingDuration(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	cursor := tracker.NewCursor("rescuetime")
+
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(10 * time.Minute), AppClass: "slack", Ignored: true, Active: true}
+	tracker.endCurrentSessionUnsafe(start.Add(20 * time.Minute))
+	tracker.currentSession = &Session{StartTime: start.Add(20 * time.Minute), AppClass: "firefox", Active: true}
+	tracker.mu.Unlock()
+
+	if got := tracker.PendingDuration(cursor, start.Add(20*time.Minute+5*time.Second)); got != 10*time.Minute {
+		t.Errorf("Expected only code's 10m while firefox is under the minimum, got %v", got)
+	}
+	if got := tracker.PendingDuration(cursor, start.Add(25*time.Minute)); got != 15*time.Minute {
+		t.Errorf("Expected code's 10m plus firefox's 5m, got %v", got)
+	}
+
+	tracker.DrainCompletedSessions(start.Add(25*time.Minute), cursor)
+	if got := tracker.PendingDuration(cursor, start.Add(25*time.Minute)); got != 0 {
+		t.Errorf("Expected nothing pending right after a drain, got %v", got)
+	}
+}
+
+// TestActiveHoursSplitSummary tests that a summary running across the edges of the active
+// hours hands RescueTime only its time inside them, while the other backends get all of it
+func TestActiveHoursSplitSummary(t *testing.T) {
+	schedule, err := rescuetime.ParseSchedule("Mon-Fri 09:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseSchedule failed: %v", err)
+	}
+	tracker := New()
+	tracker.SetActiveHours(schedule)
+	cursor := tracker.NewCursor("rescuetime")
+	day := time.Date(2025, 10, 29, 0, 0, 0, 0, time.Local) // Wednesday
+	at := func(hour, minute, second int) {
+		now := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second)
+		tracker.SetClock(func() time.Time { return now })
+	}
+
+	// 08:50-09:30 and 17:50-18:10 in one window each, polled across the schedule's edges
+	at(8, 50, 0)
+	tracker.StartSession("code", "main.go")
+	at(8, 55, 0)
+	tracker.ObservePoll()
+	at(9, 0, 1)
+	tracker.ObservePoll()
+	at(9, 30, 0)
+	tracker.EndCurrentSession()
+	at(17, 50, 0)
+	tracker.StartSession("code", "main.go")
+	at(18, 0, 1)
+	tracker.ObservePoll()
+	at(18, 10, 0)
+	tracker.EndCurrentSession()
+
+	got := tracker.Consume(cursor)
+	code := got.Completed["code"]
+	if want := 29*time.Minute + 59*time.Second + 10*time.Minute + time.Second; code.TotalDuration != want {
+		t.Errorf("Expected only the %v inside active hours for RescueTime, got %v", want, code.TotalDuration)
+	}
+	if code.FirstSeen.Hour() != 9 {
+		t.Errorf("Expected the RescueTime summary to start at 09:00, got %v", code.FirstSeen)
+	}
+	if all := got.All["code"]; all.TotalDuration != time.Hour {
+		t.Errorf("Expected the other backends to get the whole hour, got %v", all.TotalDuration)
+	}
+	if len(got.Sessions) != 4 {
+		t.Errorf("Expected each window's session split in two at the edge, got %d sessions", len(got.Sessions))
+	}
+	for _, session := range got.Sessions {
+		inside := session.StartTime.Hour() == 9 || session.StartTime.Hour() == 17
+		if session.OutsideActiveHours == inside {
+			t.Errorf("Session %v-%v tagged OutsideActiveHours=%v", session.StartTime.Format("15:04:05"), session.EndTime.Format("15:04:05"), session.OutsideActiveHours)
+		}
+	}
+}
+
+// TestRewindKeepsSessions tests that rewinding a cursor over sessions it consumed keeps them
+// from CompactSessions, and that sessions already dropped are counted
+func TestRewindKeepsSessions(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	cursor := tracker.NewCursor("postgres")
+
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	first := tracker.Consume(cursor)
+	tracker.CompactSessions()
+	runSession(tracker, "firefox", start.Add(10*time.Minute), start.Add(20*time.Minute))
+	second := tracker.Consume(cursor)
+
+	if dropped := tracker.Rewind(cursor, append(first.Sessions, second.Sessions...)); dropped != 1 {
+		t.Errorf("Expected the compacted code session to be counted as dropped, got %d", dropped)
+	}
+	tracker.CompactSessions()
+	if sessions := tracker.GetSessions(); len(sessions) != 1 || sessions[0].AppClass != "firefox" {
+		t.Fatalf("Expected firefox to be kept, got %+v", sessions)
+	}
+	if got := tracker.Consume(cursor); len(got.Sessions) != 1 || got.Sessions[0].AppClass != "firefox" {
+		t.Errorf("Expected firefox to be handed out again, got %+v", got.Sessions)
+	}
+}
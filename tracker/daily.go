@@ -0,0 +1,152 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// dailyTotals accumulates tracked time per grouping key since midnight in the tracker's
+// location (see SetLocation).
+// It's for display only: submissions are built from sessions, so nothing here is sent twice.
+type dailyTotals struct {
+	Date   string                   `json:"date"` // day the totals belong to (YYYY-MM-DD)
+	Totals map[string]time.Duration `json:"totals"`
+}
+
+// DayBounds returns the midnights starting and ending t's day in loc, so [start, end) is
+// "today" for t; nil loc is time.Local. Daily totals, digests and date ranges all count days
+// through it, so they agree with each other when the system zone isn't the user's. A day with
+// a DST change is 23 or 25 hours long.
+func DayBounds(t time.Time, loc *time.Location) (start, end time.Time) {
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// dayOf returns the date key for t's day in loc
+func dayOf(t time.Time, loc *time.Location) string {
+	start, _ := DayBounds(t, loc)
+	return start.Format("2006-01-02")
+}
+
+// SetLocation sets the time zone whose midnight starts a new day of daily totals; nil (the
+// default) is time.Local. Totals already accumulated stay until the next day in loc.
+func (at *Tracker) SetLocation(loc *time.Location) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.location = loc
+}
+
+// Location returns the time zone days are counted in, see SetLocation
+func (at *Tracker) Location() *time.Location {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	if at.location == nil {
+		return time.Local
+	}
+	return at.location
+}
+
+// resetIfNewDay starts empty totals when now is on a different day in loc than the ones
+// accumulated. There is no separate midnight job: the first update or read after midnight resets.
+func (d *dailyTotals) resetIfNewDay(now time.Time, loc *time.Location) {
+	if today := dayOf(now, loc); d.Date != today || d.Totals == nil {
+		d.Date = today
+		d.Totals = make(map[string]time.Duration)
+	}
+}
+
+// add counts the time from start to end under key, clipped to end's day
+// (time before midnight belonged to the previous day's totals)
+func (d *dailyTotals) add(key string, start, end time.Time, loc *time.Location) {
+	d.resetIfNewDay(end, loc)
+	if midnight, _ := DayBounds(end, loc); start.Before(midnight) {
+		start = midnight
+	}
+	if end.After(start) {
+		d.Totals[key] += end.Sub(start)
+	}
+}
+
+// GetDailyTotals returns tracked time per grouping key since midnight (see SetLocation), including the
+// active session once it has met the minimum duration. Ignored applications aren't counted.
+// These totals survive ClearCompletedSessions and are for display only.
+func (at *Tracker) GetDailyTotals() map[string]time.Duration {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	return at.dailyTotalsUnsafe(at.now())
+}
+
+// dailyTotalsUnsafe returns a copy of today's totals as of now (must be called with lock held)
+func (at *Tracker) dailyTotalsUnsafe(now time.Time) map[string]time.Duration {
+	at.daily.resetIfNewDay(now, at.location)
+
+	totals := make(map[string]time.Duration, len(at.daily.Totals)+1)
+	for key, duration := range at.daily.Totals {
+		totals[key] = duration
+	}
+
+	current := at.currentSession
+	if current != nil && current.Active && !current.Ignored && now.Sub(current.StartTime) >= at.minDuration {
+		start := current.StartTime
+		if midnight, _ := DayBounds(now, at.location); start.Before(midnight) {
+			start = midnight
+		}
+		totals[at.mergedKey(current)] += now.Sub(start)
+	}
+	return totals
+}
+
+// SaveDailyTotals writes today's completed totals to path, replacing it atomically
+func (at *Tracker) SaveDailyTotals(path string) error {
+	at.mu.Lock()
+	at.daily.resetIfNewDay(at.now(), at.location)
+	data, err := json.MarshalIndent(at.daily, "", "  ")
+	at.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily totals: %v", err)
+	}
+
+	// Write to a temp file first so a crash mid-write can't corrupt the totals
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write daily totals: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace daily totals file: %v", err)
+	}
+	return nil
+}
+
+// LoadDailyTotals restores totals saved earlier today from path. A missing file or
+// totals from another day are ignored.
+func (at *Tracker) LoadDailyTotals(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read daily totals file %s: %v", path, err)
+	}
+
+	var saved dailyTotals
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse daily totals file %s: %v\n\nDelete the file to start today's totals from zero", path, err)
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if saved.Date != dayOf(at.now(), at.location) || saved.Totals == nil {
+		return nil
+	}
+	at.daily.resetIfNewDay(at.now(), at.location)
+	for key, duration := range saved.Totals {
+		at.daily.Totals[key] += duration
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runSession simulates a focused window from start to end on the tracker
+func runSession(tracker *Tracker, appClass string, start, end time.Time) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.currentSession = &Session{StartTime: start, AppClass: appClass, Active: true}
+	tracker.endCurrentSessionUnsafe(end)
+}
+
+// TestDailyTotalsAcrossIntervals tests that totals survive ClearCompletedSessions and include merges
+func TestDailyTotalsAcrossIntervals(t *testing.T) {
+	tracker := New()
+	day := time.Date(2025, 10, 29, 0, 0, 0, 0, time.Local)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	// First submission interval
+	runSession(tracker, "code", at(9, 0), at(9, 10))
+	runSession(tracker, "firefox", at(9, 10), at(9, 15))
+	tracker.ClearCompletedSessions()
+
+	// Second interval: code again, then a short gap merged into the same session
+	runSession(tracker, "code", at(9, 15), at(9, 30))
+	runSession(tracker, "code", at(9, 30), at(9, 40))
+	runSession(tracker, "slack", at(9, 40), at(9, 40).Add(5*time.Second)) // below the minimum duration
+	tracker.ClearCompletedSessions()
+
+	tracker.mu.Lock()
+	totals := tracker.dailyTotalsUnsafe(at(10, 0))
+	tracker.mu.Unlock()
+	if totals["code"] != 35*time.Minute {
+		t.Errorf("Expected code 35m, got %v", totals["code"])
+	}
+	if totals["firefox"] != 5*time.Minute {
+		t.Errorf("Expected firefox 5m, got %v", totals["firefox"])
+	}
+	if _, ok := totals["slack"]; ok {
+		t.Errorf("Expected sessions below the minimum duration not to count, got %v", totals["slack"])
+	}
+
+	// The active session counts once it meets the minimum duration, without being stored
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: at(10, 0), AppClass: "firefox", Active: true}
+	totals = tracker.dailyTotalsUnsafe(at(10, 20))
+	stored := tracker.daily.Totals["firefox"]
+	tracker.mu.Unlock()
+	if totals["firefox"] != 25*time.Minute || stored != 5*time.Minute {
+		t.Errorf("Expected firefox 25m including the active session (5m stored), got %v (%v stored)", totals["firefox"], stored)
+	}
+}
+
+// TestDailyTotalsMidnightReset tests that a new day starts from zero and only counts time after midnight
+func TestDailyTotalsMidnightReset(t *testing.T) {
+	tracker := New()
+	midnight := time.Date(2025, 10, 30, 0, 0, 0, 0, time.Local)
+
+	runSession(tracker, "code", midnight.Add(-3*time.Hour), midnight.Add(-time.Hour))
+	// Crosses midnight: only the 20 minutes after it belong to the new day
+	runSession(tracker, "firefox", midnight.Add(-10*time.Minute), midnight.Add(20*time.Minute))
+
+	tracker.mu.Lock()
+	totals := tracker.dailyTotalsUnsafe(midnight.Add(time.Hour))
+	tracker.mu.Unlock()
+	if _, ok := totals["code"]; ok {
+		t.Errorf("Expected yesterday's code time to be reset, got %v", totals["code"])
+	}
+	if totals["firefox"] != 20*time.Minute {
+		t.Errorf("Expected firefox 20m after midnight, got %v", totals["firefox"])
+	}
+
+	// A read on the next day resets even without new sessions
+	tracker.mu.Lock()
+	totals = tracker.dailyTotalsUnsafe(midnight.AddDate(0, 0, 1).Add(time.Minute))
+	tracker.mu.Unlock()
+	if len(totals) != 0 {
+		t.Errorf("Expected empty totals on a new day, got %v", totals)
+	}
+}
+
+// TestDailyTotalsPersistence tests that today's totals survive a restart and stale ones don't
+func TestDailyTotalsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "today.json")
+	now := time.Now()
+
+	tracker := New()
+	runSession(tracker, "code", now.Add(-30*time.Minute), now.Add(-10*time.Minute))
+	if err := tracker.SaveDailyTotals(path); err != nil {
+		t.Fatalf("SaveDailyTotals failed: %v", err)
+	}
+
+	restarted := New()
+	if err := restarted.LoadDailyTotals(path); err != nil {
+		t.Fatalf("LoadDailyTotals failed: %v", err)
+	}
+	// Clipping at midnight can shorten the session when the test runs just after midnight
+	want := tracker.GetDailyTotals()["code"]
+	if got := restarted.GetDailyTotals()["code"]; got != want {
+		t.Errorf("Expected code %v after restart, got %v", want, got)
+	}
+
+	os.WriteFile(path, []byte(`{"date": "2020-01-01", "totals": {"code": 3600000000000}}`), 0600)
+	stale := New()
+	if err := stale.LoadDailyTotals(path); err != nil {
+		t.Fatalf("LoadDailyTotals failed: %v", err)
+	}
+	if totals := stale.GetDailyTotals(); len(totals) != 0 {
+		t.Errorf("Expected another day's totals to be ignored, got %v", totals)
+	}
+
+	if err := stale.LoadDailyTotals(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Expected a missing file to be ignored, got %v", err)
+	}
+}
+
+// TestDayBounds tests that days start at midnight in the given zone rather than the system's,
+// including the 25-hour day when DST ends
+func TestDayBounds(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*60*60)
+	// 13:30 UTC on the 29th is already 00:30 on the 30th in Sydney
+	now := time.Date(2025, 10, 29, 13, 30, 0, 0, time.UTC)
+
+	start, end := DayBounds(now, sydney)
+	if want := time.Date(2025, 10, 30, 0, 0, 0, 0, sydney); !start.Equal(want) || !end.Equal(want.AddDate(0, 0, 1)) {
+		t.Errorf("Expected the 30th in Sydney, got %v - %v", start, end)
+	}
+	if start, _ := DayBounds(now, time.UTC); !start.Equal(time.Date(2025, 10, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the 29th in UTC, got %v", start)
+	}
+	// One nanosecond before midnight is still the previous day
+	if start, _ := DayBounds(time.Date(2025, 10, 29, 12, 59, 59, 999999999, time.UTC), sydney); start.Day() != 29 {
+		t.Errorf("Expected the 29th just before Sydney midnight, got %v", start)
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No time zone database: %v", err)
+	}
+	start, end = DayBounds(time.Date(2025, 11, 2, 12, 0, 0, 0, newYork), newYork)
+	if end.Sub(start) != 25*time.Hour {
+		t.Errorf("Expected a 25-hour day when DST ends, got %v", end.Sub(start))
+	}
+}
+
+// TestDailyTotalsLocation tests that daily totals reset at midnight in the tracker's
+// location, not the system's
+func TestDailyTotalsLocation(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*60*60)
+	midnight := time.Date(2025, 10, 30, 0, 0, 0, 0, sydney) // 13:00 UTC on the 29th
+	tracker := New()
+	tracker.SetLocation(sydney)
+	if tracker.Location() != sydney {
+		t.Fatalf("Expected the Sydney location, got %v", tracker.Location())
+	}
+
+	runSession(tracker, "code", midnight.Add(-3*time.Hour), midnight.Add(-time.Hour))
+	// Crosses Sydney midnight: only the 20 minutes after it belong to the new day
+	runSession(tracker, "firefox", midnight.Add(-10*time.Minute), midnight.Add(20*time.Minute))
+
+	tracker.mu.Lock()
+	totals := tracker.dailyTotalsUnsafe(midnight.Add(time.Hour).UTC())
+	date := tracker.daily.Date
+	tracker.mu.Unlock()
+	if _, ok := totals["code"]; ok || totals["firefox"] != 20*time.Minute {
+		t.Errorf("Expected only firefox's 20m after Sydney midnight, got %v", totals)
+	}
+	if date != "2025-10-30" {
+		t.Errorf("Expected the totals dated by Sydney's day, got %s", date)
+	}
+
+	tracker.SetLocation(nil)
+	if tracker.Location() != time.Local {
+		t.Errorf("Expected nil to restore time.Local, got %v", tracker.Location())
+	}
+}
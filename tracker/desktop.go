@@ -0,0 +1,25 @@
+package tracker
+
+import "github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/desktopentry"
+
+// SetDesktopEntries sets the resolver summaries take their display name and icon from;
+// nil leaves them out
+func (at *Tracker) SetDesktopEntries(resolver *desktopentry.Resolver) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.desktopEntries = resolver
+}
+
+// describeAppUnsafe fills in summary's desktop entry fields from session's WmClass. A summary
+// under a merge target or category isn't one application, so it gets none (must be called
+// with at least a read lock held).
+func (at *Tracker) describeAppUnsafe(summary *ActivitySummary, session *Session) {
+	if at.desktopEntries == nil || summary.AppClass != session.AppClass {
+		return
+	}
+	if entry, ok := at.desktopEntries.Lookup(session.AppClass, ""); ok {
+		summary.DisplayName = entry.Name
+		summary.Icon = entry.Icon
+		summary.DesktopID = entry.ID
+	}
+}
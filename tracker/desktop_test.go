@@ -0,0 +1,54 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/desktopentry"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestSummariesCarryDesktopEntry tests that summaries get the desktop entry's name and icon
+// while keeping the WmClass as AppClass, and that merged summaries get none
+func TestSummariesCarryDesktopEntry(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "applications"), 0755); err != nil {
+		t.Fatalf("Failed to create applications dir: %v", err)
+	}
+	entries := map[string]string{
+		"code.desktop":  "[Desktop Entry]\nName=Visual Studio Code\nIcon=vscode\nType=Application\nStartupWMClass=Code\n",
+		"slack.desktop": "[Desktop Entry]\nName=Slack\nIcon=slack\nType=Application\n",
+	}
+	for name, content := range entries {
+		if err := os.WriteFile(filepath.Join(dataDir, "applications", name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tracker := New()
+	tracker.SetDesktopEntries(desktopentry.NewResolver([]string{dataDir}, ""))
+	tracker.SetMerges(map[string]string{"slack": "Chat", "discord": "Chat"})
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	runSession(tracker, "slack", start.Add(10*time.Minute), start.Add(15*time.Minute))
+	runSession(tracker, "gimp", start.Add(15*time.Minute), start.Add(20*time.Minute))
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	code := summaries["code"]
+	if code.AppClass != "code" || code.DisplayName != "Visual Studio Code" || code.Icon != "vscode" || code.DesktopID != "code.desktop" {
+		t.Errorf("Expected code with its desktop entry, got %+v", code)
+	}
+	if chat := summaries["Chat"]; chat.DisplayName != "" || chat.Icon != "" {
+		t.Errorf("Expected no desktop entry for a merge target, got %+v", chat)
+	}
+	if gimp := summaries["gimp"]; gimp.DisplayName != "" {
+		t.Errorf("Expected no display name without a desktop entry, got %q", gimp.DisplayName)
+	}
+
+	// RescueTime gets the class, not the display name
+	if payload := rescuetime.SummaryToPayload(code); payload.ActivityName != "code" {
+		t.Errorf("Expected the RescueTime payload to use the class, got %q", payload.ActivityName)
+	}
+}
@@ -0,0 +1,34 @@
+// Package tracker is the tracking engine behind active-window: it turns a stream of focused
+// windows into application sessions and aggregates them into RescueTime activity summaries.
+// It doesn't read the desktop itself; the caller polls the focused window and reports it.
+//
+// Example usage:
+//
+//	t := tracker.New()
+//	t.SetThresholds(30*time.Second, 10*time.Second)
+//	if err := t.SetIgnoreFile(".rescuetime-ignore"); err != nil && !os.IsNotExist(err) {
+//		log.Fatal(err)
+//	}
+//
+//	t.StartSession("firefox", "GitHub - Projects") // on each focus change
+//	...
+//	t.EndCurrentSession() // when the user goes idle
+//
+//	summaries := t.GetCompletedActivitySummaries()
+//	t.ClearCompletedSessions()
+//
+// Sessions shorter than the minimum duration are dropped, and a session that starts within
+// the merge threshold of the last one of the same application extends it. Destinations that
+// submit on their own schedule each register a Cursor and take what's new with
+// DrainCompletedSessions; CompactSessions then drops what every cursor has consumed.
+//
+// # Concurrency
+//
+// A Tracker is safe for concurrent use: every method holds the tracker's lock while it reads
+// or changes the tracker's state, so calls from different goroutines are serialized (readers
+// such as GetActivitySummaries share the lock) and never see a half-applied change. Methods
+// return copies, never slices or maps the tracker keeps using. Nothing runs in the
+// background; time only passes for the tracker when a method is called, reading the clock
+// set with SetClock. A KeyFunc is called with the lock held and must not call back into
+// the tracker. A WAL is likewise safe for concurrent use.
+package tracker
@@ -0,0 +1,33 @@
+// Example tests for the tracker package demonstrating usage patterns
+package tracker_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/tracker"
+)
+
+// Example demonstrates feeding window changes to a tracker and reading the summaries, with a
+// clock the example advances itself
+func Example() {
+	now := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	t := tracker.New()
+	t.SetClock(func() time.Time { return now })
+
+	t.StartSession("code", "main.go")
+	now = now.Add(20 * time.Minute)
+	t.StartSession("firefox", "GitHub - Projects")
+	now = now.Add(5 * time.Second) // too short to count
+	t.StartSession("code", "tracker.go")
+	now = now.Add(10 * time.Minute)
+	t.EndCurrentSession()
+
+	summary := t.GetCompletedActivitySummaries()["code"]
+	fmt.Printf("%s: %v in %d session(s), last on %s\n", summary.AppClass, summary.TotalDuration, summary.SessionCount, summary.ActivityDetails)
+	fmt.Printf("%d summaries\n", len(t.GetCompletedActivitySummaries()))
+
+	// Output:
+	// code: 30m5s in 1 session(s), last on tracker.go
+	// 1 summaries
+}
@@ -0,0 +1,31 @@
+package tracker
+
+import "time"
+
+// StartWindowSessionAt begins tracking a session for a window that has been focused since
+// start, ending the current session there instead of now. start is clamped to the current
+// session's start, so sessions never overlap.
+func (at *Tracker) StartWindowSessionAt(window *Window, start time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if now := at.now(); start.After(now) {
+		start = now
+	}
+	if at.currentSession != nil && at.currentSession.Active {
+		if start.Before(at.currentSession.StartTime) {
+			start = at.currentSession.StartTime
+		}
+		at.endCurrentSessionUnsafe(start)
+	}
+	ignored := at.ignoredLookup[at.ignoreKeyUnsafe(window.WmClass)]
+	at.currentSession = at.newSessionUnsafe(window.WmClass, window.Title, at.keyFunc(window), start, ignored)
+}
+
+// IsTracking reports whether the active session is for window's class and title
+func (at *Tracker) IsTracking(window *Window) bool {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	session := at.currentSession
+	return session != nil && session.Active && session.AppClass == window.WmClass && session.WindowTitle == window.Title
+}
@@ -0,0 +1,58 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+)
+
+// SetTrackGeometry sets whether ObserveGeometry splits each session's time into time with
+// its window maximized or fullscreen (MaximizedDuration) and the rest; off leaves it 0
+func (at *Tracker) SetTrackGeometry(enabled bool) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.trackGeometry = enabled
+}
+
+// ObserveGeometry records whether the current session's window, polled at now, fills the
+// screen. The monitor calls it after every poll that read the window. The time since the
+// previous poll counts under the state seen then, and the time from the session start
+// under the first state seen, so maximizing and restoring a window splits its session's
+// time without splitting the session.
+func (at *Tracker) ObserveGeometry(now time.Time, window *Window) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	session := at.currentSession
+	if session == nil || !session.Active || !at.trackGeometry {
+		return
+	}
+	filled := common.FillsScreen(window)
+	if session.geometryAt.IsZero() {
+		session.geometryAt = session.StartTime
+		session.maximized = filled
+	}
+	session.closeGeometryStretch(now)
+	session.maximized = filled
+}
+
+// closeGeometryStretch adds the time from the last observation until until to
+// MaximizedDuration if the window filled the screen, and starts the next stretch there
+func (s *Session) closeGeometryStretch(until time.Time) {
+	if s.geometryAt.IsZero() {
+		return
+	}
+	if s.maximized && until.After(s.geometryAt) {
+		s.MaximizedDuration += until.Sub(s.geometryAt)
+	}
+	s.geometryAt = until
+}
+
+// maximizedAt returns the active session's MaximizedDuration as of now, counting the
+// stretch since the last observation
+func (s *Session) maximizedAt(now time.Time) time.Duration {
+	if s.maximized && now.After(s.geometryAt) {
+		return s.MaximizedDuration + now.Sub(s.geometryAt)
+	}
+	return s.MaximizedDuration
+}
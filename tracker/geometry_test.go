@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+// pollGeometry feeds ObserveGeometry a poll every 10s from start until before end, with
+// filledAt telling whether the window filled the screen at each poll
+func pollGeometry(tracker *Tracker, start, end time.Time, filledAt func(t time.Time) bool) {
+	for t := start; t.Before(end); t = t.Add(10 * time.Second) {
+		tracker.ObserveGeometry(t, &Window{WmClass: "code", Width: 1200, Height: 800, Maximized: filledAt(t)})
+	}
+}
+
+// TestMaximizedAccounting tests that maximizing and restoring a window splits its session's
+// time without splitting the session, that the summary sums it, and that a merge keeps it
+func TestMaximizedAccounting(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	now := start
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+	tracker.SetTrackGeometry(true)
+
+	// Floating for 10 minutes, maximized for 20, restored for 5
+	tracker.StartSession("code", "main.go")
+	pollGeometry(tracker, at(0), at(35), func(t time.Time) bool { return !t.Before(at(10)) && t.Before(at(30)) })
+	now = at(35)
+	if summary := tracker.GetActivitySummaries()["code"]; summary.MaximizedDuration != 20*time.Minute {
+		t.Errorf("Expected 20m maximized in the active session, got %v", summary.MaximizedDuration)
+	}
+	tracker.EndCurrentSession()
+
+	sessions := tracker.GetSessions()
+	if len(sessions) != 1 || sessions[0].MaximizedDuration != 20*time.Minute {
+		t.Fatalf("Expected one session with 20m maximized, got %+v", sessions)
+	}
+
+	// Back within the merge threshold, maximized from its first poll until it ends
+	now = at(35).Add(10 * time.Second)
+	tracker.StartSession("code", "main.go")
+	pollGeometry(tracker, now, at(40), func(time.Time) bool { return true })
+	now = at(40)
+	tracker.EndCurrentSession()
+
+	sessions = tracker.GetSessions()
+	if len(sessions) != 1 || sessions[0].MaximizedDuration != 25*time.Minute-10*time.Second {
+		t.Fatalf("Expected the merged session to add the maximized time, got %+v", sessions)
+	}
+	summary := tracker.GetCompletedActivitySummaries()["code"]
+	if share := summary.MaximizedShare(); share < 0.62 || share > 0.63 {
+		t.Errorf("Expected about 62%% of 40m maximized, got %v of %v (%.3f)", summary.MaximizedDuration, summary.TotalDuration, share)
+	}
+}
+
+// TestMaximizedAcrossDrain tests that a submission split keeps the window's geometry, so
+// the continued session doesn't need another poll to count as maximized
+func TestMaximizedAcrossDrain(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	now := start
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+	tracker.SetTrackGeometry(true)
+	cursor := tracker.NewCursor("test")
+
+	tracker.StartSession("code", "main.go")
+	tracker.ObserveGeometry(start, &Window{WmClass: "code", Maximized: true})
+	tracker.DrainCompletedSessions(start.Add(15*time.Minute), cursor)
+	now = start.Add(20 * time.Minute)
+	tracker.EndCurrentSession()
+
+	sessions := tracker.GetSessions()
+	if len(sessions) != 2 || sessions[0].MaximizedDuration != 15*time.Minute || sessions[1].MaximizedDuration != 5*time.Minute {
+		t.Errorf("Expected 15m and 5m maximized either side of the drain, got %+v", sessions)
+	}
+}
+
+// TestMaximizedDisabled tests that without SetTrackGeometry nothing is counted
+func TestMaximizedDisabled(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	now := start
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+
+	tracker.StartSession("code", "main.go")
+	pollGeometry(tracker, start, start.Add(time.Minute), func(time.Time) bool { return true })
+	now = start.Add(time.Minute)
+	tracker.EndCurrentSession()
+
+	if sessions := tracker.GetSessions(); len(sessions) != 1 || sessions[0].MaximizedDuration != 0 {
+		t.Errorf("Expected no maximized time without -track-geometry, got %+v", sessions)
+	}
+}
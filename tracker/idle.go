@@ -0,0 +1,62 @@
+package tracker
+
+import "time"
+
+// SetPresentIdleThreshold sets how long a stretch without input has to last, while the same
+// window stays focused, to count as idle-but-present time; 0 counts all session time as active
+func (at *Tracker) SetPresentIdleThreshold(threshold time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.presentIdle = threshold
+}
+
+// ObserveIdle records an IdleMonitor sample: at now, there had been no input for idle.
+// The monitor samples every poll, below the AFK threshold too, so a session that stays
+// focused while the user reads accumulates that no-input stretch in IdleDuration. A stretch
+// counts from the last input (or the session start), not from when it passed the threshold.
+func (at *Tracker) ObserveIdle(now time.Time, idle time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	session := at.currentSession
+	if session == nil || !session.Active || at.presentIdle <= 0 {
+		return
+	}
+	if idle < at.presentIdle {
+		// The stretch lasted until the input, not just until the last sample
+		if lastInput := now.Add(-idle); !session.idleStart.IsZero() && lastInput.After(session.idleEnd) {
+			session.idleEnd = lastInput
+		}
+		session.closeIdleStretch()
+		return
+	}
+
+	start := now.Add(-idle)
+	if start.Before(session.StartTime) {
+		start = session.StartTime
+	}
+	// Input since the last sample ended the stretch being extended
+	if !session.idleEnd.IsZero() && start.After(session.idleEnd) {
+		session.closeIdleStretch()
+	}
+	if session.idleStart.IsZero() {
+		session.idleStart = start
+	}
+	session.idleEnd = now
+}
+
+// closeIdleStretch adds the no-input stretch being extended to IdleDuration
+func (s *Session) closeIdleStretch() {
+	if !s.idleStart.IsZero() {
+		s.IdleDuration += s.idleEnd.Sub(s.idleStart)
+	}
+	s.idleStart, s.idleEnd = time.Time{}, time.Time{}
+}
+
+// ActiveDuration returns the part of the session's Duration with input
+func (s *Session) ActiveDuration() time.Duration {
+	if s.IdleDuration >= s.Duration {
+		return 0
+	}
+	return s.Duration - s.IdleDuration
+}
@@ -0,0 +1,96 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+// sampleIdle feeds ObserveIdle a sample every 10s from start to end, with idleAt giving the
+// time without input at each sample
+func sampleIdle(tracker *Tracker, start, end time.Time, idleAt func(t time.Time) time.Duration) {
+	for t := start; !t.After(end); t = t.Add(10 * time.Second) {
+		tracker.ObserveIdle(t, idleAt(t))
+	}
+}
+
+// TestIdleFractionAccounting tests that a 60-minute session with 40 minutes without input
+// reports 20 minutes active and 40 idle
+func TestIdleFractionAccounting(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	lastInput := start.Add(20 * time.Minute)
+
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start, AppClass: "evince", Active: true}
+	tracker.mu.Unlock()
+
+	sampleIdle(tracker, start, start.Add(60*time.Minute), func(t time.Time) time.Duration {
+		if t.Before(lastInput) {
+			return 2 * time.Second // typing
+		}
+		return t.Sub(lastInput)
+	})
+	tracker.mu.Lock()
+	tracker.endCurrentSessionUnsafe(start.Add(60 * time.Minute))
+	tracker.mu.Unlock()
+
+	sessions := tracker.GetSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(sessions))
+	}
+	if sessions[0].IdleDuration != 40*time.Minute || sessions[0].ActiveDuration() != 20*time.Minute {
+		t.Errorf("Expected 20m active / 40m idle, got %v / %v", sessions[0].ActiveDuration(), sessions[0].IdleDuration)
+	}
+}
+
+// TestIdleStretchesEndOnInput tests that input ends a stretch, pauses under the threshold
+// count as active, and a stretch that began before the session counts from its start
+func TestIdleStretchesEndOnInput(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minutes float64) time.Time { return start.Add(time.Duration(minutes * float64(time.Minute))) }
+	inputs := []time.Time{at(-3), at(5), at(15), at(16.5), at(17), at(27), at(28), at(29)}
+
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start, AppClass: "firefox", Active: true}
+	tracker.mu.Unlock()
+
+	sampleIdle(tracker, start, at(30), func(t time.Time) time.Duration {
+		last := inputs[0]
+		for _, input := range inputs {
+			if !input.After(t) {
+				last = input
+			}
+		}
+		return t.Sub(last)
+	})
+	tracker.mu.Lock()
+	tracker.endCurrentSessionUnsafe(at(30))
+	tracker.mu.Unlock()
+
+	// 0-5 (from the session start), 5-15, 15-16.5, 17-27 and the minute left at the end;
+	// the pauses at 16.5, 27 and 28 are under a minute
+	want := 5*time.Minute + 10*time.Minute + 90*time.Second + 10*time.Minute + time.Minute
+	if got := tracker.GetSessions()[0].IdleDuration; got != want {
+		t.Errorf("Expected %v idle, got %v", want, got)
+	}
+}
+
+// TestPresentIdleDisabled tests that a zero threshold counts everything as active
+func TestPresentIdleDisabled(t *testing.T) {
+	tracker := New()
+	tracker.SetPresentIdleThreshold(0)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start, AppClass: "evince", Active: true}
+	tracker.mu.Unlock()
+	sampleIdle(tracker, start, start.Add(10*time.Minute), func(t time.Time) time.Duration { return t.Sub(start) })
+	tracker.mu.Lock()
+	tracker.endCurrentSessionUnsafe(start.Add(10 * time.Minute))
+	tracker.mu.Unlock()
+
+	if session := tracker.GetSessions()[0]; session.IdleDuration != 0 || session.ActiveDuration() != 10*time.Minute {
+		t.Errorf("Expected all 10m active, got %v idle", session.IdleDuration)
+	}
+}
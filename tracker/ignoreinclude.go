@@ -0,0 +1,140 @@
+package tracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ignoreIncludeDirective starts an ignore file line that loads another file's entries, e.g.
+// "#include ignore.d/games". Older versions read it as a comment.
+const ignoreIncludeDirective = "#include"
+
+// maxIgnoreIncludeDepth bounds how deeply included files may include others
+const maxIgnoreIncludeDepth = 8
+
+// IgnoreList is an ignore file as read by ReadIgnoreList: its own entries and #include
+// lines, plus the entries of every file it includes
+type IgnoreList struct {
+	ModTime  time.Time
+	Entries  []string             // entries written in the file itself
+	Includes []string             // #include lines as written, so saving keeps them
+	Included map[string]bool      // entries only found in included files
+	Files    map[string]time.Time // included files read, with their modification times
+	Warnings []string             // includes skipped: missing, cyclic or too deep
+}
+
+// parseIgnoreInclude returns the path of an #include line
+func parseIgnoreInclude(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, ignoreIncludeDirective)
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	target := strings.TrimSpace(rest)
+	return target, target != ""
+}
+
+// resolveIgnoreInclude resolves target relative to the directory of the including file
+func resolveIgnoreInclude(from, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(from), target)
+	}
+	if abs, err := filepath.Abs(target); err == nil {
+		return abs
+	}
+	return filepath.Clean(target)
+}
+
+// ReadIgnoreList reads the ignore file at path and, recursively, the files it includes.
+// Only path itself must exist: an include that can't be read is skipped with a warning.
+func ReadIgnoreList(path string) (*IgnoreList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &IgnoreList{Included: make(map[string]bool), Files: make(map[string]time.Time)}
+	if info, err := file.Stat(); err == nil {
+		list.ModTime = info.ModTime()
+	}
+	self := resolveIgnoreInclude(path, filepath.Base(path))
+	own := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if target, ok := parseIgnoreInclude(line); ok {
+			list.Includes = append(list.Includes, line)
+			list.include(resolveIgnoreInclude(path, target), []string{self}, 1)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list.Entries = append(list.Entries, line)
+		own[line] = true
+	}
+	for entry := range list.Included {
+		if own[entry] {
+			delete(list.Included, entry)
+		}
+	}
+	return list, scanner.Err()
+}
+
+// include adds the entries of the file at path, which stack (the chain of files including
+// it) reached at depth
+func (l *IgnoreList) include(path string, stack []string, depth int) {
+	for _, including := range stack {
+		if including == path {
+			l.Warnings = append(l.Warnings, fmt.Sprintf("Skipping include of %s: include cycle %s", path, strings.Join(append(stack, path), " -> ")))
+			return
+		}
+	}
+	if depth > maxIgnoreIncludeDepth {
+		l.Warnings = append(l.Warnings, fmt.Sprintf("Skipping include of %s: includes are nested more than %d deep", path, maxIgnoreIncludeDepth))
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		l.Warnings = append(l.Warnings, fmt.Sprintf("Skipping include of %s: %v", path, err))
+		return
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil {
+		l.Files[path] = info.ModTime()
+	}
+
+	stack = append(stack[:len(stack):len(stack)], path)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if target, ok := parseIgnoreInclude(line); ok {
+			l.include(resolveIgnoreInclude(path, target), stack, depth+1)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l.Included[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		l.Warnings = append(l.Warnings, fmt.Sprintf("Failed to read included ignore file %s: %v", path, err))
+	}
+}
+
+// includesChanged reports whether any included file read at the times in files has been
+// modified or removed since
+func includesChanged(files map[string]time.Time) bool {
+	for path, modTime := range files {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
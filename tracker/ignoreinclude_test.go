@@ -0,0 +1,120 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeIgnoreFiles writes each file under dir, creating directories as needed
+func writeIgnoreFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// TestIgnoreInclude tests that included files, found relative to the including file, add
+// their entries, and that saving keeps the #include line instead of copying them in
+func TestIgnoreInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeIgnoreFiles(t, dir, map[string]string{
+		".rescuetime-ignore":  "Slack\n#include ignore.d/games\n",
+		"ignore.d/games":      "# Games\nSteam\n#include more-games\n",
+		"ignore.d/more-games": "minecraft\n",
+	})
+
+	tracker := New()
+	tracker.ignoreConfigPath = filepath.Join(dir, ".rescuetime-ignore")
+	if err := tracker.loadIgnoredApps(); err != nil {
+		t.Fatalf("loadIgnoredApps failed: %v", err)
+	}
+	for _, appClass := range []string{"slack", "steam", "Minecraft"} {
+		if !tracker.IsIgnored(appClass) {
+			t.Errorf("Expected %q to be ignored", appClass)
+		}
+	}
+
+	if err := tracker.addIgnoredApp("discord"); err != nil {
+		t.Fatalf("addIgnoredApp failed: %v", err)
+	}
+	data, _ := os.ReadFile(tracker.ignoreConfigPath)
+	saved := string(data)
+	if !strings.Contains(saved, "#include ignore.d/games\n") || !strings.Contains(saved, "discord\n") || strings.Contains(saved, "Steam") {
+		t.Errorf("Expected the include kept and its entries left out, got:\n%s", saved)
+	}
+
+	// Editing an included file is picked up like editing the main one
+	future := time.Now().Add(time.Minute)
+	os.WriteFile(filepath.Join(dir, "ignore.d/more-games"), []byte("minecraft\nfactorio\n"), 0644)
+	os.Chtimes(filepath.Join(dir, "ignore.d/more-games"), future, future)
+	if added, err := tracker.ReloadIgnoredApps(); err != nil || len(added) != 1 || added[0] != "factorio" {
+		t.Errorf("Expected factorio added on reload, got %v, %v", added, err)
+	}
+}
+
+// TestIgnoreIncludeCycle tests that files including each other load once each, with a
+// warning, instead of recursing forever
+func TestIgnoreIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeIgnoreFiles(t, dir, map[string]string{
+		"main": "Slack\n#include a\n",
+		"a":    "Steam\n#include b\n",
+		"b":    "Discord\n#include a\n#include main\n",
+	})
+
+	done := make(chan *IgnoreList)
+	go func() {
+		list, _ := ReadIgnoreList(filepath.Join(dir, "main"))
+		done <- list
+	}()
+	select {
+	case list := <-done:
+		if !list.Included["Steam"] || !list.Included["Discord"] || len(list.Entries) != 1 {
+			t.Errorf("Expected every file's entries once, got %+v", list)
+		}
+		if len(list.Warnings) != 2 || !strings.Contains(list.Warnings[0], "cycle") {
+			t.Errorf("Expected two cycle warnings, got %v", list.Warnings)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReadIgnoreList didn't return for an include cycle")
+	}
+
+	// A chain deeper than the limit stops there
+	files := map[string]string{"main": "#include f1\n"}
+	for i := 1; i <= maxIgnoreIncludeDepth+2; i++ {
+		files["f"+strconv.Itoa(i)] = "app" + strconv.Itoa(i) + "\n#include f" + strconv.Itoa(i+1) + "\n"
+	}
+	deep := t.TempDir()
+	writeIgnoreFiles(t, deep, files)
+	list, _ := ReadIgnoreList(filepath.Join(deep, "main"))
+	if len(list.Included) != maxIgnoreIncludeDepth || len(list.Warnings) != 1 || !strings.Contains(list.Warnings[0], "deep") {
+		t.Errorf("Expected %d included entries and a depth warning, got %d, %v", maxIgnoreIncludeDepth, len(list.Included), list.Warnings)
+	}
+}
+
+// TestIgnoreIncludeMissing tests that a missing include is a warning, not an error, and the
+// rest of the list still loads
+func TestIgnoreIncludeMissing(t *testing.T) {
+	dir := t.TempDir()
+	writeIgnoreFiles(t, dir, map[string]string{".rescuetime-ignore": "#include missing\nSlack\n"})
+
+	tracker := New()
+	tracker.ignoreConfigPath = filepath.Join(dir, ".rescuetime-ignore")
+	if err := tracker.loadIgnoredApps(); err != nil {
+		t.Fatalf("Expected a missing include not to fail loading, got %v", err)
+	}
+	if !tracker.IsIgnored("Slack") {
+		t.Error("Expected the main file's entries loaded")
+	}
+	if list, _ := ReadIgnoreList(tracker.ignoreConfigPath); len(list.Warnings) != 1 || !strings.Contains(list.Warnings[0], "missing") {
+		t.Errorf("Expected a warning about the missing include, got %+v", list.Warnings)
+	}
+}
@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"os"
+	"sort"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// ReloadIgnoredApps re-reads the ignore file if it or a file it includes changed since it
+// was last loaded (e.g. the ignoreApplication tool added an entry while tracking) and returns the entries that
+// weren't ignored before, so the caller can purge them. A missing file changes nothing.
+func (at *Tracker) ReloadIgnoredApps() ([]string, error) {
+	at.mu.RLock()
+	path := at.ignoreConfigPath
+	at.mu.RUnlock()
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	at.mu.RLock()
+	unchanged := info.ModTime().Equal(at.ignoreModTime)
+	before := at.ignoredLookup
+	included := at.includeModTimes
+	at.mu.RUnlock()
+	if unchanged && !includesChanged(included) {
+		return nil, nil
+	}
+
+	if err := at.loadIgnoredApps(); err != nil {
+		return nil, err
+	}
+
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	var added []string
+	for appClass := range at.ignoredApps {
+		if !before[at.ignoreKeyUnsafe(appClass)] {
+			added = append(added, appClass)
+		}
+	}
+	sort.Strings(added)
+	return added, nil
+}
+
+// PurgeApp applies a newly added ignore entry to what's already tracked. The active session,
+// if it's appClass, is marked ignored, so none of its time reaches RescueTime. With
+// retroactive, completed sessions of appClass that some destination hasn't consumed yet are
+// moved to the ignored sessions too; otherwise they're kept and submitted as tracked.
+// Like ignored sessions, they still go to PostgreSQL and webhooks. It returns how many
+// completed sessions were purged.
+func (at *Tracker) PurgeApp(appClass string, retroactive bool) int {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	key := at.ignoreKeyUnsafe(appClass)
+	if current := at.currentSession; current != nil && current.Active && at.ignoreKeyUnsafe(current.AppClass) == key {
+		current.Ignored = true
+	}
+	if !retroactive {
+		return 0
+	}
+
+	kept := make([]Session, 0, len(at.sessions))
+	var purged []Session
+	for _, session := range at.sessions {
+		if at.ignoreKeyUnsafe(session.AppClass) == key {
+			session.Ignored = true
+			purged = append(purged, session)
+		} else {
+			kept = append(kept, session)
+		}
+	}
+	if len(purged) == 0 {
+		return 0
+	}
+	at.sessions = kept
+
+	// Cursors rely on each list being in storage order
+	at.ignoredSessions = append(at.ignoredSessions, purged...)
+	sort.SliceStable(at.ignoredSessions, func(i, j int) bool { return at.ignoredSessions[i].seq < at.ignoredSessions[j].seq })
+
+	// A restart must replay them as ignored
+	if at.wal != nil {
+		if err := at.wal.Rewrite(at.heldSessionsUnsafe()); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+	return len(purged)
+}
@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newPurgeTracker returns a tracker with an empty ignore file, completed slack and firefox
+// sessions, one consumed by an early cursor, and slack focused for the last 10 minutes
+func newPurgeTracker(t *testing.T) (*Tracker, *Cursor, *Cursor) {
+	t.Helper()
+	tracker := New()
+	tracker.ignoreConfigPath = filepath.Join(t.TempDir(), ".rescuetime-ignore")
+	early, late := tracker.NewCursor("RescueTime"), tracker.NewCursor("webhook")
+	start := time.Now().Add(-time.Hour)
+
+	runSession(tracker, "slack", start, start.Add(10*time.Minute))
+	tracker.Consume(early)
+	runSession(tracker, "firefox", start.Add(10*time.Minute), start.Add(30*time.Minute))
+	runSession(tracker, "Slack", start.Add(30*time.Minute), start.Add(50*time.Minute))
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(50 * time.Minute), AppClass: "slack", Active: true}
+	tracker.mu.Unlock()
+	return tracker, early, late
+}
+
+// TestPurgeAppKeepsStoredSessions tests that without -retroactive only the active session's
+// time is dropped
+func TestPurgeAppKeepsStoredSessions(t *testing.T) {
+	tracker, early, _ := newPurgeTracker(t)
+
+	if purged := tracker.PurgeApp("slack", false); purged != 0 {
+		t.Errorf("Expected no stored sessions purged, got %d", purged)
+	}
+	tracker.EndCurrentSession()
+
+	submission := tracker.Consume(early)
+	if slack := submission.Completed["Slack"]; slack.TotalDuration != 20*time.Minute {
+		t.Errorf("Expected the stored 20m of Slack to be kept, got %v", slack.TotalDuration)
+	}
+	if _, ok := submission.Completed["slack"]; ok {
+		t.Error("Expected the active slack session not to be submitted")
+	}
+	if ignored := tracker.GetIgnoredSessions(); len(ignored) != 1 || ignored[0].Duration < 10*time.Minute {
+		t.Errorf("Expected the active session among the ignored sessions, got %+v", ignored)
+	}
+}
+
+// TestPurgeAppRetroactive tests that -retroactive also moves stored sessions a destination
+// hasn't consumed to the ignored sessions, matching the class case-insensitively
+func TestPurgeAppRetroactive(t *testing.T) {
+	tracker, early, late := newPurgeTracker(t)
+
+	if purged := tracker.PurgeApp("slack", true); purged != 2 {
+		t.Errorf("Expected both stored slack sessions purged, got %d", purged)
+	}
+	tracker.EndCurrentSession()
+
+	for name, cursor := range map[string]*Cursor{"early": early, "late": late} {
+		submission := tracker.Consume(cursor)
+		if len(submission.Completed) != 1 || submission.Completed["firefox"].TotalDuration != 20*time.Minute {
+			t.Errorf("%s: expected only firefox submitted, got %v", name, submission.Completed)
+		}
+		for _, session := range submission.Sessions {
+			if session.AppClass != "firefox" && !session.Ignored {
+				t.Errorf("%s: expected %s to be handed over as ignored", name, session.AppClass)
+			}
+		}
+	}
+	if ignored := tracker.GetIgnoredSessions(); len(ignored) != 3 {
+		t.Errorf("Expected 3 ignored slack sessions, got %d", len(ignored))
+	}
+}
@@ -0,0 +1,23 @@
+package tracker
+
+import "strings"
+
+// SetMerges sets the class merges applied to summary keys, mapping a lowercased
+// WmClass to the name it's reported under; nil disables them
+func (at *Tracker) SetMerges(merges map[string]string) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.merges = merges
+}
+
+// mergedKey returns the key session is summarized under: its merge target if its WmClass
+// is merged, else its grouping key. Merges match the WmClass whatever -group-by says, so
+// a merged application is reported under its target with instance or category grouping too.
+// Unlike -group-by category, a merge replaces the application's identity in summaries
+// (must be called with at least a read lock held).
+func (at *Tracker) mergedKey(session *Session) string {
+	if target, ok := at.merges[strings.ToLower(session.AppClass)]; ok {
+		return target
+	}
+	return session.groupKey()
+}
@@ -0,0 +1,54 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMergedKey tests case-insensitive lookup by WmClass, pass-through of unmerged classes
+// and that merges win over instance and category grouping keys
+func TestMergedKey(t *testing.T) {
+	tracker := New()
+	if got := tracker.mergedKey(&Session{AppClass: "slack"}); got != "slack" {
+		t.Errorf("Expected no merges by default, got %q", got)
+	}
+
+	tracker.SetMerges(map[string]string{"slack": "Chat"})
+	tests := []struct {
+		session Session
+		want    string
+	}{
+		{Session{AppClass: "slack"}, "Chat"},
+		{Session{AppClass: "Slack"}, "Chat"},
+		{Session{AppClass: "firefox"}, "firefox"},
+		{Session{AppClass: "Slack", Key: "Slack/slack-workspace"}, "Chat"},    // -group-by instance
+		{Session{AppClass: "slack", Key: "Communication"}, "Chat"},            // -group-by category
+		{Session{AppClass: "discord", Key: "Communication"}, "Communication"}, // not merged
+	}
+	for _, tt := range tests {
+		if got := tracker.mergedKey(&tt.session); got != tt.want {
+			t.Errorf("mergedKey(%q, key %q) = %q, want %q", tt.session.AppClass, tt.session.Key, got, tt.want)
+		}
+	}
+}
+
+// TestMergeWithCategoryGrouping tests that a merged application is summarized under its
+// target when sessions are grouped by category
+func TestMergeWithCategoryGrouping(t *testing.T) {
+	tracker := New()
+	tracker.SetKeyFunc(KeyByCategory)
+	tracker.SetMerges(map[string]string{"slack": "Chat"})
+	now := time.Now().Add(-time.Hour)
+	tracker.SetClock(func() time.Time { return now })
+
+	for _, class := range []string{"slack", "discord", "slack"} {
+		tracker.StartWindowSession(&Window{WmClass: class, Title: class})
+		now = now.Add(10 * time.Minute)
+	}
+	tracker.EndCurrentSession()
+
+	summaries := tracker.GetActivitySummaries()
+	if summaries["Chat"].TotalDuration != 20*time.Minute || summaries["Communication"].TotalDuration != 10*time.Minute {
+		t.Errorf("Expected 20m of Chat and 10m of Communication, got %+v", summaries)
+	}
+}
@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// resumeState is the session in progress at shutdown. Its time up to then is stored and
+// submitted as usual; a restart within the resume window continues it from StartTime.
+type resumeState struct {
+	AppClass  string    `json:"app_class"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"` // where the resumed session starts
+	Continued bool      `json:"continued"`  // the time before StartTime was stored as this session
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// SaveResumeState saves the active session at now to path, before shutdown ends it, so
+// ResumeSession can continue it after a restart. Without an active session a stale file is
+// removed. A session too short to be stored yet resumes from its own start, so the restart
+// doesn't lose it.
+func (at *Tracker) SaveResumeState(path string, now time.Time) error {
+	at.mu.RLock()
+	session := at.currentSession
+	var state resumeState
+	active := session != nil && session.Active
+	if active {
+		state = resumeState{AppClass: session.AppClass, Title: session.WindowTitle, StartTime: session.StartTime, SavedAt: now}
+		if session.continued || now.Sub(session.StartTime) >= at.minDuration {
+			state.StartTime = now
+			state.Continued = true
+		}
+	}
+	at.mu.RUnlock()
+
+	if !active {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove resume file: %v", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal resume state: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write resume file: %v", err)
+	}
+	return nil
+}
+
+// ResumeSession continues the session saved by SaveResumeState if it was saved at most
+// window before now and window (already resolved, see resolveAmbiguousClass) is the same
+// application and title. It reports whether it started the session; if not, the caller
+// starts a fresh one. The file is used once either way.
+func (at *Tracker) ResumeSession(path string, window *Window, now time.Time, maxGap time.Duration) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Debug("Failed to read resume file: %v", err)
+		}
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		logging.Debug("Failed to remove resume file: %v", err)
+	}
+
+	var state resumeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logging.Debug("Ignoring unreadable resume file: %v", err)
+		return false
+	}
+	if gap := now.Sub(state.SavedAt); gap < 0 || gap > maxGap {
+		logging.Debug("Not resuming %s: saved %v ago", state.AppClass, gap.Round(time.Second))
+		return false
+	}
+	if window.WmClass != state.AppClass || window.Title != state.Title || state.StartTime.After(now) {
+		return false
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if at.currentSession != nil && at.currentSession.Active {
+		return false
+	}
+	at.currentSession = at.newSessionUnsafe(window.WmClass, window.Title, at.keyFunc(window), state.StartTime, at.ignoredLookup[at.ignoreKeyUnsafe(window.WmClass)])
+	at.currentSession.continued = state.Continued
+	return true
+}
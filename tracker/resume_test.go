@@ -0,0 +1,46 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestResumeSession tests that a session too short to be stored yet resumes from its own
+// start, and that a different window or a stale file starts fresh
+func TestResumeSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.json")
+	window := &Window{WmClass: "code", Title: "main.go"}
+
+	saved := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	tracker := New()
+	tracker.currentSession = tracker.newSessionUnsafe("code", "main.go", "code", saved.Add(-2*time.Second), false)
+	if err := tracker.SaveResumeState(path, saved); err != nil {
+		t.Fatalf("SaveResumeState failed: %v", err)
+	}
+	fresh := New()
+	if !fresh.ResumeSession(path, window, saved.Add(time.Second), time.Minute) {
+		t.Fatal("Expected the short session to resume")
+	}
+	if got := fresh.currentSession; got.continued || !got.StartTime.Equal(saved.Add(-2*time.Second)) {
+		t.Errorf("Expected the short session to resume from its start, got %+v", got)
+	}
+
+	// A different window or a stale file starts fresh
+	for _, tt := range []struct {
+		name   string
+		window *Window
+		after  time.Duration
+	}{
+		{"other app", &Window{WmClass: "firefox", Title: "main.go"}, time.Second},
+		{"other title", &Window{WmClass: "code", Title: "monitor.go"}, time.Second},
+		{"stale", window, 2 * time.Minute},
+	} {
+		if err := tracker.SaveResumeState(path, saved); err != nil {
+			t.Fatalf("SaveResumeState failed: %v", err)
+		}
+		if New().ResumeSession(path, tt.window, saved.Add(tt.after), time.Minute) {
+			t.Errorf("%s: expected a fresh session", tt.name)
+		}
+	}
+}
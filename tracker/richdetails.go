@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Caps on the details SetRichDetails lists: the titles per summary, and the length of the
+// whole list
+const (
+	RichDetailsMaxTitles = 3
+	richDetailsMaxLength = 255
+)
+
+// SetRichDetails sets whether summaries list their most used titles as activity details
+// (-rich-details) instead of just the latest one
+func (at *Tracker) SetRichDetails(enabled bool) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.richDetails = enabled
+}
+
+// titleTimes is the time spent under each title of a summary, for -rich-details
+type titleTimes map[string]time.Duration
+
+// observe adds d under title, if titles is collecting; a nil titleTimes ignores it
+func (titles titleTimes) observe(title string, d time.Duration) {
+	if titles == nil || strings.TrimSpace(title) == "" {
+		return
+	}
+	titles[title] += d
+}
+
+// details lists the titles by time spent, most first, e.g. "GitHub (12m), Docs (5m)". It
+// stops at RichDetailsMaxTitles, and at the last title that fits in richDetailsMaxLength;
+// one that doesn't fit on its own is cut short. "" if there are no titles.
+func (titles titleTimes) details() string {
+	names := make([]string, 0, len(titles))
+	for title := range titles {
+		names = append(names, title)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if titles[names[i]] != titles[names[j]] {
+			return titles[names[i]] > titles[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var b strings.Builder
+	length := 0
+	for i, title := range names {
+		if i == RichDetailsMaxTitles {
+			break
+		}
+		entry := fmt.Sprintf("%s (%s)", title, compactDuration(titles[title]))
+		if i > 0 {
+			entry = ", " + entry
+		}
+		if length+utf8.RuneCountInString(entry) > richDetailsMaxLength {
+			if i == 0 {
+				b.WriteString(truncateTitle(entry, richDetailsMaxLength))
+			}
+			break
+		}
+		b.WriteString(entry)
+		length += utf8.RuneCountInString(entry)
+	}
+	return b.String()
+}
+
+// compactDuration formats d to the largest unit that matters in a list of titles: "45s",
+// "12m", "2h05m"
+func compactDuration(d time.Duration) string {
+	minutes := d.Round(time.Minute)
+	switch {
+	case minutes >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(minutes/time.Hour), int(minutes%time.Hour/time.Minute))
+	case minutes >= time.Minute:
+		return fmt.Sprintf("%dm", int(minutes/time.Minute))
+	}
+	return fmt.Sprintf("%ds", int(d.Round(time.Second)/time.Second))
+}
+
+// truncateTitle shortens s to at most max characters, ending with "…" when cut
+func truncateTitle(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
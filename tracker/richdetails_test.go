@@ -0,0 +1,69 @@
+package tracker
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// TestRichDetails tests that -rich-details lists an application's titles by time spent,
+// counting the active session, and that the latest title is used without it
+func TestRichDetails(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	var sessions []Session
+	at := start
+	for _, s := range []struct {
+		title    string
+		duration time.Duration
+	}{
+		{"GitHub", 8 * time.Minute},
+		{"Docs", 5 * time.Minute},
+		{"GitHub", 4 * time.Minute},
+		{"Hacker News", 2 * time.Minute},
+	} {
+		sessions = append(sessions, Session{StartTime: at, EndTime: at.Add(s.duration), Duration: s.duration, AppClass: "firefox", WindowTitle: s.title})
+		at = at.Add(s.duration)
+	}
+
+	tracker := New()
+	if got := tracker.aggregateSessionsUnsafe(sessions, false, at)["firefox"].ActivityDetails; got != "Hacker News" {
+		t.Errorf("Expected the latest title without -rich-details, got %q", got)
+	}
+
+	tracker.SetRichDetails(true)
+	summary := tracker.aggregateSessionsUnsafe(sessions, false, at)["firefox"]
+	if want := "GitHub (12m), Docs (5m), Hacker News (2m)"; summary.ActivityDetails != want {
+		t.Errorf("Expected details %q, got %q", want, summary.ActivityDetails)
+	}
+	if summary.RawTitle != "Hacker News" {
+		t.Errorf("Expected the raw title to stay the latest, got %q", summary.RawTitle)
+	}
+
+	// 8 minutes back in Docs puts it ahead of GitHub
+	tracker.currentSession = &Session{StartTime: at, AppClass: "firefox", WindowTitle: "Docs", Active: true}
+	summary = tracker.aggregateSessionsUnsafe(sessions, true, at.Add(8*time.Minute))["firefox"]
+	if want := "Docs (13m), GitHub (12m), Hacker News (2m)"; summary.ActivityDetails != want {
+		t.Errorf("Expected details %q with the active session, got %q", want, summary.ActivityDetails)
+	}
+}
+
+// TestRichDetailsCaps tests that the list stops at RichDetailsMaxTitles and at
+// richDetailsMaxLength, cutting a single title that is too long on its own
+func TestRichDetailsCaps(t *testing.T) {
+	titles := titleTimes{"a": 5 * time.Minute, "b": 4 * time.Minute, "c": 3 * time.Minute, "d": 2 * time.Minute}
+	if got, want := titles.details(), "a (5m), b (4m), c (3m)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	long := strings.Repeat("x", richDetailsMaxLength-10)
+	titles = titleTimes{long: time.Hour, "short": time.Minute}
+	if got, want := titles.details(), long+" (1h00m)"; got != want {
+		t.Errorf("Expected the list to stop before a title that doesn't fit, got %q", got)
+	}
+
+	titles = titleTimes{strings.Repeat("é", 2*richDetailsMaxLength): 30 * time.Second}
+	if got := titles.details(); utf8.RuneCountInString(got) != richDetailsMaxLength || !strings.HasSuffix(got, "…") {
+		t.Errorf("Expected an overlong title cut to %d characters, got %d", richDetailsMaxLength, utf8.RuneCountInString(got))
+	}
+}
@@ -0,0 +1,161 @@
+package tracker
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// SetSecondaryApps sets the WmClasses (matched case-insensitively) that earn background
+// credit, e.g. meeting or music applications: while one has an open window and another
+// application is focused, it accrues a secondary session alongside the focused one (see
+// ObserveOpenWindows). Earlier entries win when several are open. nil disables it and ends
+// the secondary session.
+func (at *Tracker) SetSecondaryApps(classes []string) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.secondaryApps = nil
+	for _, class := range classes {
+		if class = strings.ToLower(strings.TrimSpace(class)); class != "" {
+			at.secondaryApps = append(at.secondaryApps, class)
+		}
+	}
+	if len(at.secondaryApps) == 0 {
+		at.endSecondarySessionUnsafe(at.now())
+	}
+}
+
+// TracksSecondary reports whether SetSecondaryApps was given any applications, so callers
+// only list windows when they'd be used
+func (at *Tracker) TracksSecondary() bool {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return len(at.secondaryApps) > 0
+}
+
+// ObserveOpenWindows picks the secondary application from the windows open at now: the
+// first SetSecondaryApps entry with a window that isn't the focused application. A new pick
+// ends the secondary session and starts one for it; none just ends it. Secondary sessions
+// are passive time: they reach Submission.Sessions (PostgreSQL, webhooks) tagged Secondary,
+// never the summaries, today's totals or the application statistics.
+func (at *Tracker) ObserveOpenWindows(now time.Time, windows []Window) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	focused := ""
+	if at.currentSession != nil && at.currentSession.Active {
+		focused = strings.ToLower(at.currentSession.AppClass)
+	}
+
+	var pick *Window
+	for _, class := range at.secondaryApps {
+		if class == focused {
+			continue
+		}
+		for i := range windows {
+			if strings.ToLower(windows[i].WmClass) == class {
+				pick = &windows[i]
+				break
+			}
+		}
+		if pick != nil {
+			break
+		}
+	}
+
+	current := at.secondarySession
+	if pick != nil && current != nil && strings.EqualFold(current.AppClass, pick.WmClass) {
+		return
+	}
+	at.endSecondarySessionUnsafe(now)
+	if pick == nil {
+		return
+	}
+
+	at.secondarySession = at.newSessionUnsafe(pick.WmClass, pick.Title, pick.WmClass, now, at.ignoredLookup[at.ignoreKeyUnsafe(pick.WmClass)])
+	at.secondarySession.Secondary = true
+	logging.Debug("Secondary activity: %s", pick.WmClass)
+}
+
+// EndSecondarySession ends the secondary session, e.g. when the user goes idle or tracking
+// stops; the next ObserveOpenWindows starts a new one
+func (at *Tracker) EndSecondarySession() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.endSecondarySessionUnsafe(at.now())
+}
+
+// SecondarySession returns a copy of the active secondary session, and false if there is none
+func (at *Tracker) SecondarySession() (Session, bool) {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	if at.secondarySession == nil {
+		return Session{}, false
+	}
+	return *at.secondarySession, true
+}
+
+// GetSecondarySessions returns a copy of the completed secondary sessions
+func (at *Tracker) GetSecondarySessions() []Session {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	sessionsCopy := make([]Session, len(at.secondarySessions))
+	copy(sessionsCopy, at.secondarySessions)
+	return sessionsCopy
+}
+
+// endSecondarySessionUnsafe ends the secondary session at endTime, storing it if it meets
+// the minimum duration (must be called with lock held)
+func (at *Tracker) endSecondarySessionUnsafe(endTime time.Time) {
+	session := at.secondarySession
+	if session == nil {
+		return
+	}
+	at.secondarySession = nil
+
+	session.EndTime = endTime
+	session.Duration = endTime.Sub(session.StartTime)
+	session.Active = false
+	if session.Duration < at.minDuration {
+		return
+	}
+	if at.wal != nil {
+		if err := at.wal.Append(*session); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+	at.storeSecondaryUnsafe(*session)
+}
+
+// splitSecondarySessionUnsafe ends the secondary session at now and continues it in a new
+// one, as DrainCompletedSessions does with the focused session, so a long meeting reaches
+// each submission rather than only the one after it ends (must be called with lock held)
+func (at *Tracker) splitSecondarySessionUnsafe(now time.Time) {
+	session := at.secondarySession
+	if session == nil || now.Sub(session.StartTime) < at.minDuration {
+		return
+	}
+	continued := *session
+	continued.StartTime = now
+	at.endSecondarySessionUnsafe(now)
+	at.secondarySession = &continued
+}
+
+// storeSecondaryUnsafe adds an ended secondary session to the completed secondary sessions
+// (must be called with lock held)
+func (at *Tracker) storeSecondaryUnsafe(session Session) {
+	session.seq = at.takeSeqUnsafe()
+	at.secondarySessions = append(at.secondarySessions, session)
+	logging.Debug("Stored secondary session: %s (%v)", session.AppClass, session.Duration)
+}
+
+// heldSessionsUnsafe returns every completed session the tracker holds: regular, ignored and
+// secondary, e.g. for rewriting the WAL (must be called with lock held)
+func (at *Tracker) heldSessionsUnsafe() []Session {
+	held := make([]Session, 0, len(at.sessions)+len(at.ignoredSessions)+len(at.secondarySessions))
+	held = append(held, at.sessions...)
+	held = append(held, at.ignoredSessions...)
+	held = append(held, at.secondarySessions...)
+	return held
+}
@@ -0,0 +1,143 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSecondarySession tests a meeting open behind the focused windows: it accrues one
+// secondary session alongside several primary ones, pauses while it's focused itself, and
+// reaches Submission.Sessions tagged Secondary but never the summaries or today's totals
+func TestSecondarySession(t *testing.T) {
+	start := time.Date(2025, 11, 3, 9, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	now := start
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+	tracker.SetSecondaryApps([]string{" Zoom ", "spotify", ""})
+	if !tracker.TracksSecondary() {
+		t.Fatal("Expected secondary tracking to be on")
+	}
+	cursor := tracker.NewCursor("webhook")
+
+	meeting := []Window{{WmClass: "firefox", Title: "Jira"}, {WmClass: "zoom", Title: "Standup"}, {WmClass: "spotify", Title: "Focus"}}
+
+	// 9:00-9:20 firefox then code in front, zoom behind
+	tracker.StartSession("firefox", "Jira")
+	tracker.ObserveOpenWindows(now, meeting)
+	now = at(10)
+	tracker.StartSession("code", "main.go")
+	tracker.ObserveOpenWindows(now, meeting)
+	if session, ok := tracker.SecondarySession(); !ok || session.AppClass != "zoom" || !session.StartTime.Equal(start) {
+		t.Errorf("Expected zoom's secondary session to carry on across the focus change, got %+v, %v", session, ok)
+	}
+
+	// 9:20 zoom itself is focused: that's primary time, so spotify takes over the slot
+	now = at(20)
+	tracker.StartSession("zoom", "Standup")
+	tracker.ObserveOpenWindows(now, meeting)
+	if session, ok := tracker.SecondarySession(); !ok || session.AppClass != "spotify" {
+		t.Errorf("Expected spotify behind the focused zoom, got %+v, %v", session, ok)
+	}
+
+	// 9:30 every background app is closed
+	now = at(30)
+	tracker.StartSession("code", "main.go")
+	tracker.ObserveOpenWindows(now, meeting[:1])
+	if session, ok := tracker.SecondarySession(); ok {
+		t.Errorf("Expected no secondary session without background windows, got %+v", session)
+	}
+	now = at(40)
+	tracker.EndCurrentSession()
+
+	submission := tracker.Consume(cursor)
+	var secondary []Session
+	var primary time.Duration
+	for _, session := range submission.Sessions {
+		if session.Secondary {
+			secondary = append(secondary, session)
+		} else {
+			primary += session.Duration
+		}
+	}
+	if primary != 40*time.Minute {
+		t.Errorf("Expected the focused sessions to keep all 40 minutes, got %v", primary)
+	}
+	if len(secondary) != 2 || secondary[0].AppClass != "zoom" || secondary[0].Duration != 20*time.Minute || secondary[0].WindowTitle != "Standup" ||
+		secondary[1].AppClass != "spotify" || secondary[1].Duration != 10*time.Minute {
+		t.Errorf("Expected zoom for 20m and spotify for 10m as secondary sessions, got %+v", secondary)
+	}
+
+	for key := range submission.Completed {
+		if submission.Completed[key].TotalDuration > 40*time.Minute {
+			t.Errorf("Secondary time leaked into summary %s: %+v", key, submission.Completed[key])
+		}
+	}
+	if summary, ok := submission.Completed["spotify"]; ok {
+		t.Errorf("Expected spotify, never focused, to have no summary, got %+v", summary)
+	}
+	if zoom := submission.Completed["zoom"]; zoom.TotalDuration != 10*time.Minute {
+		t.Errorf("Expected zoom's summary to hold only its focused 10m, got %+v", zoom)
+	}
+	if totals := tracker.GetDailyTotals(); totals["spotify"] != 0 || totals["zoom"] != 10*time.Minute {
+		t.Errorf("Expected today's totals without secondary time, got %v", totals)
+	}
+}
+
+// TestSecondarySessionSplitAndWAL tests that a drain splits a long secondary session so each
+// submission gets its share, that EndSecondarySession stores the rest, and that a replayed
+// WAL restores secondary sessions as secondary
+func TestSecondarySessionSplitAndWAL(t *testing.T) {
+	start := time.Date(2025, 11, 3, 14, 0, 0, 0, time.Local)
+	now := start
+	wal, err := OpenWAL(filepath.Join(t.TempDir(), "sessions.wal"))
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+
+	tracker := New()
+	tracker.SetClock(func() time.Time { return now })
+	tracker.SetWAL(wal)
+	tracker.SetSecondaryApps([]string{"zoom"})
+	cursor := tracker.NewCursor("postgres")
+
+	tracker.StartSession("code", "main.go")
+	tracker.ObserveOpenWindows(now, []Window{{WmClass: "Zoom", Title: "Planning"}})
+
+	now = start.Add(30 * time.Minute)
+	first := tracker.DrainCompletedSessions(now, cursor)[0]
+	now = start.Add(45 * time.Minute)
+	tracker.EndSecondarySession()
+	second := tracker.DrainCompletedSessions(now, cursor)[0]
+
+	durations := func(submission Submission) []time.Duration {
+		var list []time.Duration
+		for _, session := range submission.Sessions {
+			if session.Secondary {
+				list = append(list, session.Duration)
+			}
+		}
+		return list
+	}
+	if got := durations(first); len(got) != 1 || got[0] != 30*time.Minute {
+		t.Errorf("Expected the first drain to get 30m of the meeting, got %v", got)
+	}
+	if got := durations(second); len(got) != 1 || got[0] != 15*time.Minute {
+		t.Errorf("Expected the second drain to get the remaining 15m, got %v", got)
+	}
+
+	restored := New()
+	if n, err := restored.ReplayWAL(wal); err != nil || n == 0 {
+		t.Fatalf("ReplayWAL failed: %d, %v", n, err)
+	}
+	if sessions := restored.GetSecondarySessions(); len(sessions) != 2 || !sessions[0].Secondary || sessions[0].AppClass != "Zoom" {
+		t.Errorf("Expected both secondary sessions back as secondary, got %+v", sessions)
+	}
+	for _, session := range restored.GetSessions() {
+		if session.Secondary {
+			t.Errorf("Secondary session replayed as a regular one: %+v", session)
+		}
+	}
+}
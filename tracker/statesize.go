@@ -0,0 +1,53 @@
+package tracker
+
+import (
+	"fmt"
+	"time"
+)
+
+// StateSize counts what the tracker holds in memory. Over a run of weeks every count should
+// stay bounded: sessions by the slowest cursor's interval, the rest by the number of
+// applications.
+type StateSize struct {
+	Sessions          int // completed sessions still owed to some cursor
+	IgnoredSessions   int
+	SecondarySessions int
+	IgnoredApps       int // ignore list entries, included files' too
+	DailyTotals       int // keys with time today
+	AppStats          int // keys with session statistics
+	Cursors           int
+}
+
+// String describes the sizes in one line, e.g. "sessions 3 (ignored 0, secondary 1),
+// ignore list 12, today 8 keys, stats 40 keys"
+func (s StateSize) String() string {
+	return fmt.Sprintf("sessions %d (ignored %d, secondary %d), ignore list %d, today %d keys, stats %d keys",
+		s.Sessions, s.IgnoredSessions, s.SecondarySessions, s.IgnoredApps, s.DailyTotals, s.AppStats)
+}
+
+// StateSize returns the tracker's current StateSize
+func (at *Tracker) StateSize() StateSize {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return StateSize{
+		Sessions:          len(at.sessions),
+		IgnoredSessions:   len(at.ignoredSessions),
+		SecondarySessions: len(at.secondarySessions),
+		IgnoredApps:       len(at.ignoredApps),
+		DailyTotals:       len(at.daily.Totals),
+		AppStats:          len(at.appStats),
+		Cursors:           len(at.cursors),
+	}
+}
+
+// Checkpoint splits the active session (and any secondary session) at now, like a drain
+// does, so the time up to now is stored as a completed session (and logged to the WAL)
+// without any cursor consuming it. A crash then loses at most the time since the last
+// checkpoint rather than the whole active session.
+func (at *Tracker) Checkpoint(now time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.splitCurrentSessionUnsafe(now)
+	at.splitSecondarySessionUnsafe(now)
+}
@@ -0,0 +1,51 @@
+package tracker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TitleRuleGroup is the named capture a title rule must have; it becomes the sub-activity
+const TitleRuleGroup = "activity"
+
+// TitleRules maps a lowercased WmClass to the title patterns tried in order for it.
+// The first pattern whose activity capture is non-empty names the sub-activity.
+type TitleRules map[string][]*regexp.Regexp
+
+// SubActivity returns the sub-activity the rules extract from a window title, or "" if no
+// rule for appClass matches. A capture that just repeats the class (e.g. the default
+// "Alacritty" title) says nothing more than the class, so it counts as no match.
+func (r TitleRules) SubActivity(appClass, title string) string {
+	for _, re := range r[strings.ToLower(appClass)] {
+		match := re.FindStringSubmatch(title)
+		if match == nil {
+			continue
+		}
+		activity := strings.TrimSpace(match[re.SubexpIndex(TitleRuleGroup)])
+		if activity != "" && !strings.EqualFold(activity, appClass) {
+			return activity
+		}
+	}
+	return ""
+}
+
+// SetTitleRules sets the rules that split an application's summaries by sub-activity; nil disables them
+func (at *Tracker) SetTitleRules(rules TitleRules) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.titleRules = rules
+}
+
+// summaryKeyUnsafe returns the key a session is summarized under, the application it's
+// reported as and its activity details. With a title rule match, summaries are keyed by
+// application and sub-activity ("kitty/nvim") and the sub-activity is the details. A
+// session with a context is keyed apart from the same application without ("[work] kitty")
+// (must be called with at least a read lock held).
+func (at *Tracker) summaryKeyUnsafe(session *Session) (key, app, details string) {
+	app = at.mergedKey(session)
+	key, details = app, session.WindowTitle
+	if sub := at.titleRules.SubActivity(session.AppClass, session.WindowTitle); sub != "" {
+		key, details = app+"/"+sub, sub
+	}
+	return contextKey(session.Context, key), app, details
+}
@@ -0,0 +1,798 @@
+package tracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/common"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/desktopentry"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// ActivitySummary is the per-application aggregate the tracker produces
+type ActivitySummary = rescuetime.ActivitySummary
+
+// Window is a focused window as read from GNOME Shell, see StartWindowSession
+type Window = common.MutterWindow
+
+// Defaults of a new Tracker
+const (
+	DefaultMergeThreshold = 30 * time.Second // Merge sessions if gap is less than this
+	DefaultMinDuration    = 10 * time.Second // Ignore sessions shorter than this
+
+	// Within a session, count stretches this long without input as idle-but-present time
+	DefaultPresentIdleThreshold = 1 * time.Minute
+)
+
+// KeyFunc computes the key that sessions and summaries are grouped by for a window.
+// The key becomes the summary's AppClass (the activity name submitted to RescueTime).
+type KeyFunc func(window *Window) string
+
+// Categories maps lowercase WmClass values to the category KeyByCategory groups them under
+var Categories = map[string]string{
+	"firefox":               "Browsing",
+	"google-chrome":         "Browsing",
+	"chromium":              "Browsing",
+	"brave-browser":         "Browsing",
+	"code":                  "Development",
+	"jetbrains-idea":        "Development",
+	"jetbrains-goland":      "Development",
+	"gnome-terminal-server": "Development",
+	"org.gnome.terminal":    "Development",
+	"kitty":                 "Development",
+	"alacritty":             "Development",
+	"slack":                 "Communication",
+	"discord":               "Communication",
+	"thunderbird":           "Communication",
+	"org.gnome.evolution":   "Communication",
+	"libreoffice-writer":    "Writing",
+	"libreoffice-calc":      "Office",
+	"org.gnome.nautilus":    "Utilities",
+	"org.gnome.settings":    "Utilities",
+	"spotify":               "Entertainment",
+	"steam":                 "Entertainment",
+}
+
+// KeyByClass groups by application class (the default)
+func KeyByClass(window *Window) string {
+	return window.WmClass
+}
+
+// KeyByClassInstance groups by class and instance, e.g. separate Chrome web apps
+func KeyByClassInstance(window *Window) string {
+	if window.WmClassInstance == "" || window.WmClassInstance == window.WmClass {
+		return window.WmClass
+	}
+	return window.WmClass + "/" + window.WmClassInstance
+}
+
+// KeyByCategory groups by category, falling back to the class for unknown apps
+func KeyByCategory(window *Window) string {
+	if category, ok := Categories[strings.ToLower(window.WmClass)]; ok {
+		return category
+	}
+	return window.WmClass
+}
+
+// Session represents a single continuous session with an application
+type Session struct {
+	StartTime   time.Time     `json:"start_time"`
+	EndTime     time.Time     `json:"end_time"`
+	AppClass    string        `json:"app_class"`
+	Key         string        `json:"key,omitempty"` // grouping key from the tracker's KeyFunc (defaults to AppClass)
+	WindowTitle string        `json:"window_title"`
+	Duration    time.Duration `json:"duration"`
+	Active      bool          `json:"active"`  // true if session is currently ongoing
+	Ignored     bool          `json:"ignored"` // true if app is in ignore list (excluded from RescueTime)
+
+	// Secondary is true for a background-credit application's session kept alongside the
+	// focused one (see ObserveOpenWindows); it's never submitted to RescueTime
+	Secondary bool `json:"secondary,omitempty"`
+
+	// OutsideActiveHours is true if the session falls outside the SetActiveHours schedule (not
+	// submitted to RescueTime); the active session is split where it crosses the schedule's edge
+	OutsideActiveHours bool `json:"outside_active_hours,omitempty"`
+
+	// Context is the SetContext label (e.g. work or personal) when the session started; ""
+	// for none
+	Context string `json:"context,omitempty"`
+
+	// IdleDuration is the part of Duration the window sat focused without input for at least
+	// the present idle threshold (e.g. reading); see ObserveIdle
+	IdleDuration time.Duration `json:"idle_duration,omitempty"`
+
+	// Polls is how many polls saw the session and ExpectedPolls how many its Duration spans at
+	// the poll interval (0 when unknown); see Coverage
+	Polls         int `json:"polls,omitempty"`
+	ExpectedPolls int `json:"expected_polls,omitempty"`
+
+	// MaximizedDuration is the part of Duration the window was maximized or fullscreen; see
+	// ObserveGeometry
+	MaximizedDuration time.Duration `json:"maximized_duration,omitempty"`
+
+	idleStart, idleEnd time.Time // the no-input stretch ObserveIdle is extending
+	maximized          bool      // the window filled the screen at the last ObserveGeometry
+	geometryAt         time.Time // when that was; zero until the first observation
+
+	seq       uint64 // order the tracker stored it in, for cursors
+	continued bool   // the rest of a session split by DrainCompletedSessions
+}
+
+// Tracker manages tracking of application usage sessions
+type Tracker struct {
+	mu               sync.RWMutex
+	now              func() time.Time // the clock, see SetClock
+	currentSession   *Session
+	sessions         []Session
+	ignoredSessions  []Session              // sessions from ignored apps (still tracked for PostgreSQL/webhook)
+	mergeThreshold   time.Duration          // merge sessions shorter than this threshold
+	minDuration      time.Duration          // ignore sessions shorter than this
+	ignoredApps      map[string]bool        // WmClass values to ignore, as written in the ignore file
+	ignoredLookup    map[string]bool        // ignoredApps normalized by ignoreKeyUnsafe
+	ignoreExactCase  bool                   // match ignore entries exactly instead of case-insensitively
+	ignoreConfigPath string                 // path to ignore list file; "" for none
+	ignoreModTime    time.Time              // modification time of the ignore file when last loaded
+	ignoreIncludes   []string               // #include lines of the ignore file, kept when saving it
+	includedIgnores  map[string]bool        // ignoredApps entries that come from included files only
+	includeModTimes  map[string]time.Time   // included ignore files, for ReloadIgnoredApps
+	presentIdle      time.Duration          // no-input stretches at least this long count as IdleDuration; 0 disables
+	pollInterval     time.Duration          // the monitor's poll interval, for ExpectedPolls; 0 leaves coverage unknown
+	keyFunc          KeyFunc                // computes the grouping key for a window
+	desktopEntries   *desktopentry.Resolver // names and icons for summaries; nil for none
+	activeHours      *rescuetime.Schedule   // tags sessions that start outside it; nil tags none
+	summaryCountHint atomic.Int32           // number of summaries last aggregated, used to size the next map
+	daily            dailyTotals            // per-key time since midnight, kept across ClearCompletedSessions
+	location         *time.Location         // time zone days are counted in, see SetLocation; nil for time.Local
+	appStats         map[string]*appStat    // per-key session statistics across runs, see GetAppStats
+	titleRules       TitleRules             // -title-rules, splitting summaries by sub-activity; nil disables
+	richDetails      bool                   // -rich-details: summaries list their most used titles as details
+	trackGeometry    bool                   // -track-geometry: sessions count their maximized time, see ObserveGeometry
+	context          string                 // label for new sessions, see SetContext; "" for none
+	transientHelpers map[string]bool        // -transient-helpers, lowercased; see absorbTransientUnsafe
+	transientMax     time.Duration          // -transient-max: longer helper sessions are tracked as themselves; 0 disables
+	transientGap     time.Duration          // helper time absorbed since the last stored session
+	wal              *WAL                   // logs completed sessions until they're cleared; nil disables
+
+	// secondaryApps are the SetSecondaryApps WmClasses, lowercased, in priority order;
+	// secondarySession is the background application's session in progress (nil if none)
+	// and secondarySessions the completed ones, for PostgreSQL/webhook only
+	secondaryApps     []string
+	secondarySession  *Session
+	secondarySessions []Session
+
+	// merges maps lowercased WmClass to the name it's reported under (see mergedKey)
+	merges map[string]string
+
+	// Completed sessions are numbered as they're stored so each destination can consume them
+	// through its own Cursor (see cursor.go). nextSeq numbers the next stored session,
+	// floor is the lowest number still held, and sessions below sealed have been handed out
+	// and are no longer merged into.
+	cursors []*Cursor
+	nextSeq uint64
+	floor   uint64
+	sealed  uint64
+}
+
+// groupKey returns the key the session is aggregated under
+func (s *Session) groupKey() string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return s.AppClass
+}
+
+// New creates a new activity tracker with default settings and an empty ignore list
+func New() *Tracker {
+	return &Tracker{
+		now:             time.Now,
+		sessions:        make([]Session, 0),
+		ignoredSessions: make([]Session, 0),
+		mergeThreshold:  DefaultMergeThreshold,
+		minDuration:     DefaultMinDuration,
+		ignoredApps:     make(map[string]bool),
+		ignoredLookup:   make(map[string]bool),
+		keyFunc:         KeyByClass,
+		presentIdle:     DefaultPresentIdleThreshold,
+	}
+}
+
+// SetClock sets the clock the tracker reads the time from for methods that don't take it,
+// e.g. to replay recorded window changes; nil restores time.Now
+func (at *Tracker) SetClock(now func() time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if now == nil {
+		now = time.Now
+	}
+	at.now = now
+}
+
+// SetThresholds sets the gap under which a session is merged into the previous one of the
+// same application, and the duration under which a session is dropped. It only affects
+// sessions ended afterwards.
+func (at *Tracker) SetThresholds(mergeThreshold, minDuration time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.mergeThreshold = mergeThreshold
+	at.minDuration = minDuration
+}
+
+// SetIgnoreFile loads the ignore list from path, one WmClass per line, including the files it
+// pulls in with #include lines. Sessions of ignored applications are still tracked but kept
+// apart (see GetIgnoredSessions). ReloadIgnoredApps and Reset re-read the same file. A
+// missing file leaves the list empty and returns an error satisfying os.IsNotExist.
+func (at *Tracker) SetIgnoreFile(path string) error {
+	at.mu.Lock()
+	at.ignoreConfigPath = path
+	at.mu.Unlock()
+	return at.loadIgnoredApps()
+}
+
+// loadIgnoredApps loads the list of ignored applications from config file, including the
+// files it pulls in with #include lines
+func (at *Tracker) loadIgnoredApps() error {
+	at.mu.RLock()
+	path := at.ignoreConfigPath
+	at.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+	list, err := ReadIgnoreList(path)
+	if list == nil {
+		return err
+	}
+	for _, warning := range list.Warnings {
+		logging.Warning("%s", warning)
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.ignoreModTime = list.ModTime
+	at.ignoreIncludes = list.Includes
+	at.includedIgnores = list.Included
+	at.includeModTimes = list.Files
+	at.ignoredApps = make(map[string]bool)
+	for _, line := range list.Entries {
+		at.ignoredApps[line] = true
+		logging.Debug("Loaded ignored application: %s", line)
+	}
+	for line := range list.Included {
+		at.ignoredApps[line] = true
+		logging.Debug("Loaded ignored application: %s (included)", line)
+	}
+	at.rebuildIgnoredLookupUnsafe()
+
+	if len(at.ignoredApps) > 0 {
+		logging.Verbose("Loaded %d ignored applications from %s", len(at.ignoredApps), path)
+	}
+
+	return err
+}
+
+// ignoreKeyUnsafe normalizes a WmClass for ignore list lookups. WmClass casing varies
+// ("Code" vs "code"), so matching is case-insensitive unless ignoreExactCase is set.
+func (at *Tracker) ignoreKeyUnsafe(appClass string) string {
+	if at.ignoreExactCase {
+		return appClass
+	}
+	return strings.ToLower(appClass)
+}
+
+// rebuildIgnoredLookupUnsafe recomputes ignoredLookup from ignoredApps (must be called with lock held)
+func (at *Tracker) rebuildIgnoredLookupUnsafe() {
+	at.ignoredLookup = make(map[string]bool, len(at.ignoredApps))
+	for appClass := range at.ignoredApps {
+		at.ignoredLookup[at.ignoreKeyUnsafe(appClass)] = true
+	}
+}
+
+// SetIgnoreCaseSensitive switches ignore list matching between exact and case-insensitive (default)
+func (at *Tracker) SetIgnoreCaseSensitive(caseSensitive bool) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.ignoreExactCase = caseSensitive
+	at.rebuildIgnoredLookupUnsafe()
+}
+
+// IsIgnored checks if an application should be ignored
+func (at *Tracker) IsIgnored(appClass string) bool {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return at.ignoredLookup[at.ignoreKeyUnsafe(appClass)]
+}
+
+// addIgnoredApp adds an application to the ignore list and saves to file
+func (at *Tracker) addIgnoredApp(appClass string) error {
+	at.mu.Lock()
+	at.ignoredApps[appClass] = true
+	at.ignoredLookup[at.ignoreKeyUnsafe(appClass)] = true
+	at.mu.Unlock()
+
+	return at.saveIgnoredApps()
+}
+
+// saveIgnoredApps saves the current ignore list to file
+func (at *Tracker) saveIgnoredApps() error {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+
+	file, err := os.Create(at.ignoreConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to create ignore file: %v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	// Write header
+	fmt.Fprintln(writer, "# RescueTime Ignored Applications")
+	fmt.Fprintln(writer, "# One WmClass per line")
+	fmt.Fprintln(writer, "# Lines starting with # are comments")
+	fmt.Fprintln(writer, "")
+
+	// Keep includes; their entries stay in the included files
+	for _, include := range at.ignoreIncludes {
+		fmt.Fprintln(writer, include)
+	}
+	if len(at.ignoreIncludes) > 0 {
+		fmt.Fprintln(writer, "")
+	}
+
+	// Write ignored apps
+	for appClass := range at.ignoredApps {
+		if at.includedIgnores[appClass] {
+			continue
+		}
+		fmt.Fprintln(writer, appClass)
+	}
+
+	return writer.Flush()
+}
+
+// SetKeyFunc sets how sessions are grouped. It only affects sessions started afterwards.
+func (at *Tracker) SetKeyFunc(keyFunc KeyFunc) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.keyFunc = keyFunc
+}
+
+// SetActiveHours sets the schedule new sessions are checked against; sessions starting
+// outside it are tagged OutsideActiveHours, and ObservePoll splits the active session where
+// it crosses the schedule's edge. nil disables tagging.
+func (at *Tracker) SetActiveHours(schedule *rescuetime.Schedule) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.activeHours = schedule
+}
+
+// splitAtActiveHoursUnsafe splits the active session at now if it has crossed into or out of
+// the active hours since it started, so each part is tagged for its own side. A session too
+// short to split is retagged instead (must be called with lock held).
+func (at *Tracker) splitAtActiveHoursUnsafe(now time.Time) {
+	session := at.currentSession
+	if at.activeHours == nil || session == nil || !session.Active {
+		return
+	}
+	outside := !at.activeHours.Contains(now)
+	if session.OutsideActiveHours == outside {
+		return
+	}
+	at.splitCurrentSessionUnsafe(now)
+	at.currentSession.OutsideActiveHours = outside
+}
+
+// StartWindowSession begins tracking a new activity session for a window,
+// grouped by the tracker's KeyFunc
+func (at *Tracker) StartWindowSession(window *Window) {
+	at.mu.RLock()
+	key := at.keyFunc(window)
+	at.mu.RUnlock()
+
+	at.startSession(window.WmClass, window.Title, key)
+}
+
+// StartSession begins tracking a new activity session grouped by application class
+func (at *Tracker) StartSession(appClass, windowTitle string) {
+	at.startSession(appClass, windowTitle, appClass)
+}
+
+// startSession begins tracking a new activity session under the given grouping key
+func (at *Tracker) startSession(appClass, windowTitle, key string) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	now := at.now()
+
+	// Check if app should be ignored
+	isIgnored := at.ignoredLookup[at.ignoreKeyUnsafe(appClass)]
+
+	if isIgnored {
+		logging.Debug("Tracking ignored application: %s (will be sent to PostgreSQL/webhook but not RescueTime)", appClass)
+	}
+
+	// End the current session if one exists
+	if at.currentSession != nil && at.currentSession.Active {
+		at.endCurrentSessionUnsafe(now)
+	}
+
+	// Start new session (even for ignored apps - we want to track them)
+	at.currentSession = at.newSessionUnsafe(appClass, windowTitle, key, now, isIgnored)
+}
+
+// newSessionUnsafe returns an active session starting at start (must be called with lock held)
+func (at *Tracker) newSessionUnsafe(appClass, windowTitle, key string, start time.Time, ignored bool) *Session {
+	return &Session{
+		StartTime:          start,
+		AppClass:           appClass,
+		Key:                key,
+		WindowTitle:        windowTitle,
+		Active:             true,
+		Ignored:            ignored, // Mark as ignored
+		OutsideActiveHours: at.activeHours != nil && !at.activeHours.Contains(start),
+		Context:            at.context,
+	}
+}
+
+// endCurrentSessionUnsafe ends the current session (must be called with lock held)
+func (at *Tracker) endCurrentSessionUnsafe(endTime time.Time) {
+	if at.currentSession == nil || !at.currentSession.Active {
+		return
+	}
+
+	at.currentSession.EndTime = endTime
+	at.currentSession.Duration = endTime.Sub(at.currentSession.StartTime)
+	at.currentSession.Active = false
+	at.currentSession.ExpectedPolls = at.expectedPollsUnsafe(at.currentSession.Duration)
+	at.currentSession.closeIdleStretch()
+	at.currentSession.closeGeometryStretch(endTime)
+
+	if at.absorbTransientUnsafe() {
+		return
+	}
+
+	// Only store sessions that meet minimum duration requirement. The rest of a split
+	// session already did, so any of it counts.
+	if at.currentSession.Duration >= at.minDuration || (at.currentSession.continued && at.currentSession.Duration > 0) {
+		// Log it first so a crash before the next submission doesn't lose it
+		if at.wal != nil {
+			if err := at.wal.Append(*at.currentSession); err != nil {
+				logging.Warning("%v", err)
+			}
+		}
+		at.storeCurrentSessionUnsafe()
+	}
+}
+
+// storeCurrentSessionUnsafe adds the ended current session to the completed (or ignored)
+// sessions, merging it into the last one if close enough (must be called with lock held)
+func (at *Tracker) storeCurrentSessionUnsafe() {
+	if at.currentSession.Secondary {
+		// Only replayed from the WAL; live ones end through endSecondarySessionUnsafe
+		at.storeSecondaryUnsafe(*at.currentSession)
+	} else if at.currentSession.Ignored {
+		// Store ignored sessions separately (for PostgreSQL/webhook only)
+		at.currentSession.seq = at.takeSeqUnsafe()
+		at.ignoredSessions = append(at.ignoredSessions, *at.currentSession)
+		logging.Debug("Stored ignored session: %s (%v)", at.currentSession.AppClass, at.currentSession.Duration)
+	} else {
+		key := at.mergedKey(at.currentSession)
+		// Check if we should merge with the last session
+		if at.shouldMergeWithLastSession() {
+			// The merged session absorbs the gap too, so count from the previous end
+			at.daily.add(key, at.sessions[len(at.sessions)-1].EndTime, at.currentSession.EndTime, at.location)
+			at.mergeWithLastSession()
+			at.observeAppStatUnsafe(key, at.sessions[len(at.sessions)-1].Duration, true)
+		} else if at.currentSession.continued {
+			// A split doesn't end the visit, so the statistics see one longer session
+			at.daily.add(key, at.currentSession.StartTime, at.currentSession.EndTime, at.location)
+			at.observeAppStatUnsafe(key, at.lastAppSessionUnsafe(key)+at.currentSession.Duration, true)
+			at.currentSession.seq = at.takeSeqUnsafe()
+			at.sessions = append(at.sessions, *at.currentSession)
+		} else {
+			// Store the session
+			at.daily.add(key, at.currentSession.StartTime, at.currentSession.EndTime, at.location)
+			at.observeAppStatUnsafe(key, at.currentSession.Duration, false)
+			at.currentSession.seq = at.takeSeqUnsafe()
+			at.sessions = append(at.sessions, *at.currentSession)
+		}
+	}
+	at.transientGap = 0
+}
+
+// EndCurrentSession ends the currently active session
+func (at *Tracker) EndCurrentSession() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.endCurrentSessionUnsafe(at.now())
+}
+
+// shouldMergeWithLastSession checks if current session should be merged with the previous one
+func (at *Tracker) shouldMergeWithLastSession() bool {
+	if len(at.sessions) == 0 || at.currentSession == nil {
+		return false
+	}
+
+	lastSession := &at.sessions[len(at.sessions)-1]
+
+	// A destination already has it; extending it now would never reach that destination
+	if lastSession.seq < at.sealed {
+		return false
+	}
+
+	// Can only merge sessions of the same application (or group) in the same context, on the
+	// same side of the active hours
+	if lastSession.groupKey() != at.currentSession.groupKey() || lastSession.Context != at.currentSession.Context {
+		return false
+	}
+	// ...reported under the same name, as a merged and an unmerged class can share a group
+	if at.mergedKey(lastSession) != at.mergedKey(at.currentSession) {
+		return false
+	}
+	if lastSession.OutsideActiveHours != at.currentSession.OutsideActiveHours {
+		return false
+	}
+	// ...and sub-activity, or the merged session would be summarized under the latest one
+	if at.titleRules.SubActivity(lastSession.AppClass, lastSession.WindowTitle) != at.titleRules.SubActivity(at.currentSession.AppClass, at.currentSession.WindowTitle) {
+		return false
+	}
+
+	// Check if the gap between sessions is within merge threshold. Time in an absorbed
+	// transient helper doesn't count; it was the application's.
+	gap := at.currentSession.StartTime.Sub(lastSession.EndTime) - at.transientGap
+	return gap <= at.mergeThreshold
+}
+
+// mergeWithLastSession merges current session with the last stored session
+func (at *Tracker) mergeWithLastSession() {
+	if len(at.sessions) == 0 || at.currentSession == nil {
+		return
+	}
+
+	lastSession := &at.sessions[len(at.sessions)-1]
+
+	// Extend the last session to include the current session
+	lastSession.EndTime = at.currentSession.EndTime
+	lastSession.Duration = lastSession.EndTime.Sub(lastSession.StartTime)
+
+	// Use the most recent window title
+	lastSession.WindowTitle = at.currentSession.WindowTitle
+	lastSession.IdleDuration += at.currentSession.IdleDuration
+	lastSession.MaximizedDuration += at.currentSession.MaximizedDuration
+	// The gap between them wasn't polled for this application, so it isn't expected either
+	lastSession.Polls += at.currentSession.Polls
+	lastSession.ExpectedPolls += at.currentSession.ExpectedPolls
+}
+
+// GetActivitySummaries aggregates sessions by application class
+func (at *Tracker) GetActivitySummaries() map[string]ActivitySummary {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return at.aggregateSessionsUnsafe(at.sessions, true, at.now())
+}
+
+// GetCompletedActivitySummaries aggregates ONLY completed sessions by application class.
+// This excludes the current active session to prevent re-submitting the same time to RescueTime.
+// Use this for RescueTime API submissions. Use GetActivitySummaries() for real-time tracking
+// displays, PostgreSQL storage, and webhooks where you want to include ongoing activity.
+func (at *Tracker) GetCompletedActivitySummaries() map[string]ActivitySummary {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return at.aggregateSessionsUnsafe(at.sessions, false, at.now())
+}
+
+// aggregateSessionsUnsafe builds summaries from the given completed sessions and, if includeCurrent,
+// the active session as of now (must be called with at least a read lock held).
+// This runs every poll in some modes, so the map is sized from the previous result
+// to avoid regrowing it, and each summary is written back once per session.
+func (at *Tracker) aggregateSessionsUnsafe(sessions []Session, includeCurrent bool, now time.Time) map[string]ActivitySummary {
+	summaries := make(map[string]ActivitySummary, at.summaryCountHint.Load())
+
+	// With -rich-details, the time spent under each title of each summary
+	var titles map[string]titleTimes
+	if at.richDetails {
+		titles = make(map[string]titleTimes)
+	}
+	titlesOf := func(key string) titleTimes {
+		if titles == nil {
+			return nil
+		}
+		if titles[key] == nil {
+			titles[key] = make(titleTimes)
+		}
+		return titles[key]
+	}
+
+	// Process all completed sessions
+	for i := range sessions {
+		session := &sessions[i]
+		key, app, details := at.summaryKeyUnsafe(session)
+		summary, exists := summaries[key]
+		titlesOf(key).observe(details, session.Duration)
+
+		if !exists {
+			summary = ActivitySummary{
+				AppClass:        app,
+				Context:         session.Context,
+				ActivityDetails: details,
+				RawTitle:        session.WindowTitle,
+				FirstSeen:       session.StartTime,
+				LastSeen:        session.EndTime,
+			}
+			at.describeAppUnsafe(&summary, session)
+		}
+
+		// Update totals, session length statistics and coverage
+		summary.ObserveSession(session.StartTime, session.Duration)
+		summary.ObserveCoverage(session.Duration, session.Coverage())
+		summary.MaximizedDuration += session.MaximizedDuration
+
+		// Update time boundaries
+		if session.StartTime.Before(summary.FirstSeen) {
+			summary.FirstSeen = session.StartTime
+		}
+		if session.EndTime.After(summary.LastSeen) {
+			summary.LastSeen = session.EndTime
+			// Use the most recent window title as activity details
+			summary.ActivityDetails = details
+			summary.RawTitle = session.WindowTitle
+		}
+
+		summaries[key] = summary
+	}
+
+	// Include current active session if exists. Its time always counts, but until it
+	// has met the minimum duration it would be discarded if it ended now, so it must
+	// not contribute to SessionCount (e.g. rapid window cycling by a script).
+	if includeCurrent && at.currentSession != nil && at.currentSession.Active {
+		currentDuration := now.Sub(at.currentSession.StartTime)
+		key, app, details := at.summaryKeyUnsafe(at.currentSession)
+		summary, exists := summaries[key]
+		titlesOf(key).observe(details, currentDuration)
+
+		if !exists {
+			summary = ActivitySummary{
+				AppClass:  app,
+				Context:   at.currentSession.Context,
+				FirstSeen: at.currentSession.StartTime,
+			}
+			at.describeAppUnsafe(&summary, at.currentSession)
+		}
+
+		if currentDuration >= at.minDuration {
+			summary.ObserveSession(at.currentSession.StartTime, currentDuration)
+		} else {
+			summary.TotalDuration += currentDuration
+		}
+		summary.ObserveCoverage(currentDuration, at.currentSession.coverageAt(at.expectedPollsUnsafe(currentDuration)))
+		summary.MaximizedDuration += at.currentSession.maximizedAt(now)
+
+		// Update activity details to current window title
+		summary.ActivityDetails = details
+		summary.RawTitle = at.currentSession.WindowTitle
+		summary.LastSeen = now
+
+		summaries[key] = summary
+	}
+
+	for key, times := range titles {
+		if details := times.details(); details != "" {
+			summary := summaries[key]
+			summary.ActivityDetails = details
+			summaries[key] = summary
+		}
+	}
+
+	at.summaryCountHint.Store(int32(len(summaries)))
+	return summaries
+}
+
+// ClearCompletedSessions removes all completed sessions, keeping only the current active session.
+// Every cursor moves past them, as if each destination had consumed them.
+func (at *Tracker) ClearCompletedSessions() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	// Clear all stored sessions (both regular and ignored) but keep the current active one
+	at.sessions = make([]Session, 0)
+	at.ignoredSessions = make([]Session, 0)
+	at.secondarySessions = nil
+	for _, cursor := range at.cursors {
+		cursor.next = at.nextSeq
+	}
+	at.floor = at.nextSeq
+	at.sealed = at.nextSeq
+
+	// They've been handed to the backends, so a restart must not replay them
+	if at.wal != nil {
+		if err := at.wal.Truncate(); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+}
+
+// Reset drops all tracked sessions, including the active one, so the tracker starts over as
+// if new. Unlike ClearCompletedSessions it doesn't keep the active session; nothing is
+// ended or stored, so none of it is submitted. Cursors stay registered and move past
+// everything dropped. Today's totals and app statistics are kept. With reloadConfig, the
+// ignore list is re-read from its file.
+func (at *Tracker) Reset(reloadConfig bool) error {
+	at.mu.Lock()
+	at.currentSession = nil
+	at.secondarySession = nil
+	at.sessions = make([]Session, 0)
+	at.ignoredSessions = make([]Session, 0)
+	at.secondarySessions = nil
+	at.transientGap = 0
+	for _, cursor := range at.cursors {
+		cursor.next = at.nextSeq
+	}
+	at.floor = at.nextSeq
+	at.sealed = at.nextSeq
+	if at.wal != nil {
+		if err := at.wal.Truncate(); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+	at.mu.Unlock()
+
+	if !reloadConfig {
+		return nil
+	}
+	if err := at.loadIgnoredApps(); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reload ignore list: %v", err)
+	}
+	return nil
+}
+
+// GetSessions returns a copy of all completed sessions.
+// This provides access to individual session data for granular tracking.
+func (at *Tracker) GetSessions() []Session {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+
+	// Return a copy to prevent external modifications
+	sessionsCopy := make([]Session, len(at.sessions))
+	copy(sessionsCopy, at.sessions)
+	return sessionsCopy
+}
+
+// CurrentSession returns a copy of the active session, and false if there is none
+func (at *Tracker) CurrentSession() (Session, bool) {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	if at.currentSession == nil || !at.currentSession.Active {
+		return Session{}, false
+	}
+	return *at.currentSession, true
+}
+
+// GetIgnoredSessions returns a copy of all completed ignored sessions.
+// These are sessions from apps in the ignore list, excluded from RescueTime but sent to PostgreSQL/webhook.
+func (at *Tracker) GetIgnoredSessions() []Session {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+
+	// Return a copy to prevent external modifications
+	sessionsCopy := make([]Session, len(at.ignoredSessions))
+	copy(sessionsCopy, at.ignoredSessions)
+	return sessionsCopy
+}
+
+// GetAllSessions returns both regular and ignored sessions combined.
+func (at *Tracker) GetAllSessions() []Session {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+
+	// Combine both regular and ignored sessions
+	allSessions := make([]Session, 0, len(at.sessions)+len(at.ignoredSessions))
+	allSessions = append(allSessions, at.sessions...)
+	allSessions = append(allSessions, at.ignoredSessions...)
+	return allSessions
+}
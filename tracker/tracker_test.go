@@ -0,0 +1,353 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// TestInstantSwitchesDoNotCountAsSessions verifies that sessions shorter than
+// minDuration never contribute to SessionCount in summaries, while the active
+// session's time is still included in the live summaries
+func TestInstantSwitchesDoNotCountAsSessions(t *testing.T) {
+	tracker := New()
+	tracker.minDuration = 50 * time.Millisecond
+	tracker.mergeThreshold = 0
+
+	// Rapidly cycle windows - none of these should be counted
+	for i := 0; i < 5; i++ {
+		tracker.StartSession("AppA", "Window A")
+		tracker.StartSession("AppB", "Window B")
+	}
+
+	// One qualifying session
+	tracker.StartSession("Firefox", "GitHub")
+	time.Sleep(60 * time.Millisecond)
+
+	// More instant switches, leaving a fresh active session behind
+	tracker.StartSession("Terminal", "bash")
+	tracker.StartSession("Code", "main.go")
+
+	for name, summaries := range map[string]map[string]ActivitySummary{
+		"GetActivitySummaries":          tracker.GetActivitySummaries(),
+		"GetCompletedActivitySummaries": tracker.GetCompletedActivitySummaries(),
+	} {
+		for app := range summaries {
+			if app != "Firefox" && app != "Code" {
+				t.Errorf("%s: unexpected %s summary from an instant switch", name, app)
+			}
+		}
+		firefox, ok := summaries["Firefox"]
+		if !ok {
+			t.Errorf("%s: expected Firefox summary", name)
+			continue
+		}
+		if firefox.SessionCount != 1 {
+			t.Errorf("%s: expected Firefox SessionCount 1, got %d", name, firefox.SessionCount)
+		}
+	}
+
+	if _, ok := tracker.GetCompletedActivitySummaries()["Code"]; ok {
+		t.Error("GetCompletedActivitySummaries: expected no summary for the active session")
+	}
+	code, ok := tracker.GetActivitySummaries()["Code"]
+	if !ok {
+		t.Fatal("GetActivitySummaries: expected the active Code session's time to be included")
+	}
+	if code.SessionCount != 0 {
+		t.Errorf("GetActivitySummaries: expected Code SessionCount 0, got %d", code.SessionCount)
+	}
+	if code.TotalDuration <= 0 {
+		t.Errorf("GetActivitySummaries: expected Code TotalDuration > 0, got %v", code.TotalDuration)
+	}
+}
+
+// TestGroupByCategorySummaries verifies summaries use the configured KeyFunc
+func TestGroupByCategorySummaries(t *testing.T) {
+	tracker := New()
+	tracker.minDuration = 0
+	tracker.mergeThreshold = 0
+	tracker.SetKeyFunc(KeyByCategory)
+
+	tracker.StartWindowSession(&Window{WmClass: "firefox", Title: "GitHub"})
+	time.Sleep(5 * time.Millisecond)
+	tracker.StartWindowSession(&Window{WmClass: "MyCustomApp", Title: "Main"})
+	time.Sleep(5 * time.Millisecond)
+	tracker.StartWindowSession(&Window{WmClass: "chromium", Title: "Docs"})
+	time.Sleep(5 * time.Millisecond)
+	tracker.EndCurrentSession()
+
+	summaries := tracker.GetActivitySummaries()
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 summaries, got %d: %v", len(summaries), summaries)
+	}
+	browsing, ok := summaries["Browsing"]
+	if !ok {
+		t.Fatal("Expected Browsing summary")
+	}
+	if browsing.AppClass != "Browsing" || browsing.SessionCount != 2 {
+		t.Errorf("Expected Browsing with 2 sessions, got %+v", browsing)
+	}
+	if _, ok := summaries["MyCustomApp"]; !ok {
+		t.Error("Expected MyCustomApp summary")
+	}
+
+	// Sessions keep the raw application class
+	for _, session := range tracker.GetSessions() {
+		if session.AppClass == "Browsing" {
+			t.Errorf("Expected session AppClass to remain the WmClass, got %q", session.AppClass)
+		}
+	}
+}
+
+// TestTrackerPayloadWireFormat pins the exact legacy API payload produced from tracker
+// summaries, so conversions stay byte-identical now that they live only in the rescuetime package
+func TestTrackerPayloadWireFormat(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	tracker := New()
+	tracker.sessions = []Session{
+		{AppClass: "firefox", WindowTitle: "GitHub", StartTime: start, EndTime: start.Add(10 * time.Minute), Duration: 10 * time.Minute, MaximizedDuration: 10 * time.Minute},
+		{AppClass: "firefox", WindowTitle: "Docs", StartTime: start.Add(20 * time.Minute), EndTime: start.Add(25*time.Minute + 30*time.Second), Duration: 5*time.Minute + 30*time.Second},
+	}
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	summary, ok := summaries["firefox"]
+	if !ok {
+		t.Fatalf("Expected firefox summary, got %v", summaries)
+	}
+
+	got, err := json.Marshal(rescuetime.SummaryToPayload(summary))
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	want := `{"start_time":"2025-10-29 09:00:00","duration":16,"activity_name":"firefox","activity_details":"Docs"}`
+	if string(got) != want {
+		t.Errorf("Payload changed:\n got: %s\nwant: %s", got, want)
+	}
+
+	got, err = json.Marshal(rescuetime.SummaryToPayloadWithEndTime(summary))
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	want = `{"start_time":"2025-10-29 09:00:00","end_time":"2025-10-29 09:25:30","activity_name":"firefox","activity_details":"Docs"}`
+	if string(got) != want {
+		t.Errorf("End-time payload changed:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+// TestIgnoreListCaseInsensitive tests mixed-case ignore matching and that the saved file keeps original case
+func TestIgnoreListCaseInsensitive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".rescuetime-ignore")
+	if err := os.WriteFile(path, []byte("# comment\nCode\nSlack\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	tracker := New()
+	tracker.ignoreConfigPath = path
+	if err := tracker.loadIgnoredApps(); err != nil {
+		t.Fatalf("loadIgnoredApps failed: %v", err)
+	}
+
+	for _, appClass := range []string{"Code", "code", "CODE", "slack"} {
+		if !tracker.IsIgnored(appClass) {
+			t.Errorf("Expected %q to be ignored", appClass)
+		}
+	}
+	if tracker.IsIgnored("firefox") {
+		t.Error("Expected firefox not to be ignored")
+	}
+
+	tracker.startSession("code", "main.go", "code")
+	if tracker.currentSession == nil || !tracker.currentSession.Ignored {
+		t.Error("Expected session for lowercase code to be marked ignored")
+	}
+
+	// Case-sensitive matching only accepts the exact entry
+	tracker.SetIgnoreCaseSensitive(true)
+	if !tracker.IsIgnored("Code") || tracker.IsIgnored("code") {
+		t.Error("Expected only exact-case Code to be ignored in case-sensitive mode")
+	}
+	tracker.SetIgnoreCaseSensitive(false)
+
+	if err := tracker.addIgnoredApp("Firefox"); err != nil {
+		t.Fatalf("addIgnoredApp failed: %v", err)
+	}
+	if !tracker.IsIgnored("firefox") {
+		t.Error("Expected firefox to be ignored after adding Firefox")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read ignore file: %v", err)
+	}
+	for _, entry := range []string{"\nCode\n", "\nSlack\n", "\nFirefox\n"} {
+		if !strings.Contains(string(data), entry) {
+			t.Errorf("Expected saved file to keep %q, got:\n%s", strings.TrimSpace(entry), data)
+		}
+	}
+}
+
+// TestResetClearsAllSessions tests that Reset drops completed, ignored and active sessions
+// and the WAL, reloads the ignore list on request, and leaves the tracker usable
+func TestResetClearsAllSessions(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := OpenWAL(filepath.Join(dir, "wal.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+	tracker := New()
+	tracker.ignoreConfigPath = filepath.Join(dir, ".rescuetime-ignore")
+	tracker.SetWAL(wal)
+	cursor := tracker.NewCursor("RescueTime")
+	start := time.Now().Add(-time.Hour)
+
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	tracker.Consume(cursor)
+	runSession(tracker, "firefox", start.Add(10*time.Minute), start.Add(20*time.Minute))
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: start.Add(20 * time.Minute), AppClass: "slack", Active: true, Ignored: true}
+	tracker.endCurrentSessionUnsafe(start.Add(25 * time.Minute))
+	tracker.currentSession = &Session{StartTime: start.Add(25 * time.Minute), AppClass: "code", Active: true}
+	tracker.mu.Unlock()
+
+	if err := os.WriteFile(tracker.ignoreConfigPath, []byte("firefox\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+	if err := tracker.Reset(true); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+
+	if len(tracker.GetSessions()) != 0 || len(tracker.GetIgnoredSessions()) != 0 || tracker.currentSession != nil {
+		t.Errorf("Expected no sessions after Reset, got %d completed, %d ignored, active %+v", len(tracker.GetSessions()), len(tracker.GetIgnoredSessions()), tracker.currentSession)
+	}
+	if summaries := tracker.GetActivitySummaries(); len(summaries) != 0 {
+		t.Errorf("Expected no summaries after Reset, got %v", summaries)
+	}
+	if entries, _, _ := wal.Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty WAL after Reset, got %d entries", len(entries))
+	}
+	if !tracker.IsIgnored("firefox") {
+		t.Error("Expected Reset(true) to reload the ignore list")
+	}
+
+	// Reusable: new sessions are tracked and consumed as usual
+	tracker.StartSession("code", "main.go")
+	tracker.mu.Lock()
+	tracker.currentSession.StartTime = time.Now().Add(-time.Minute)
+	tracker.mu.Unlock()
+	tracker.EndCurrentSession()
+	submission := tracker.Consume(cursor)
+	if len(submission.Sessions) != 1 || submission.Completed["code"].SessionCount != 1 || len(submission.Completed) != 1 {
+		t.Errorf("Expected only the new code session after Reset, got %+v", submission.Completed)
+	}
+}
+
+// seededTracker returns a tracker with sessionCount completed sessions spread over appCount
+// applications, plus an active session that has met the minimum duration
+func seededTracker(sessionCount, appCount int) *Tracker {
+	tracker := New()
+	start := time.Now().Add(-time.Duration(sessionCount+1) * time.Minute)
+	for i := 0; i < sessionCount; i++ {
+		sessionStart := start.Add(time.Duration(i) * time.Minute)
+		tracker.sessions = append(tracker.sessions, Session{
+			StartTime:   sessionStart,
+			EndTime:     sessionStart.Add(time.Minute),
+			AppClass:    fmt.Sprintf("app%d", i%appCount),
+			WindowTitle: fmt.Sprintf("window %d", i),
+			Duration:    time.Minute,
+		})
+	}
+	tracker.currentSession = &Session{
+		StartTime: time.Now().Add(-time.Minute),
+		AppClass:  "app0",
+		Active:    true,
+	}
+	return tracker
+}
+
+// BenchmarkGetActivitySummaries measures aggregation cost per poll
+func BenchmarkGetActivitySummaries(b *testing.B) {
+	tracker := seededTracker(500, 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.GetActivitySummaries()
+	}
+}
+
+// TestGetActivitySummariesAllocs tests that sizing the map from the previous result
+// avoids regrowing it on every poll, without changing the aggregated totals
+func TestGetActivitySummariesAllocs(t *testing.T) {
+	tracker := seededTracker(500, 20)
+
+	cold := testing.AllocsPerRun(100, func() {
+		tracker.summaryCountHint.Store(0)
+		tracker.GetActivitySummaries()
+	})
+	warm := testing.AllocsPerRun(100, func() {
+		tracker.GetActivitySummaries()
+	})
+	if warm >= cold {
+		t.Errorf("Expected fewer allocations once sized (cold %.0f, warm %.0f)", cold, warm)
+	}
+
+	summaries := tracker.GetActivitySummaries()
+	if len(summaries) != 20 {
+		t.Fatalf("Expected 20 summaries, got %d", len(summaries))
+	}
+	// app0 gets every 20th completed session plus the active one
+	if got := summaries["app0"]; got.SessionCount != 26 || got.TotalDuration < 26*time.Minute {
+		t.Errorf("Expected app0 to have 26 sessions and at least 26m, got %d and %v", got.SessionCount, got.TotalDuration)
+	}
+	if completed := tracker.GetCompletedActivitySummaries(); completed["app0"].SessionCount != 25 {
+		t.Errorf("Expected 25 completed app0 sessions, got %d", completed["app0"].SessionCount)
+	}
+}
+
+// TestSummarySessionStatistics tests max/avg session length and the longest session's start
+func TestSummarySessionStatistics(t *testing.T) {
+	tracker := New()
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	at := func(minute int) time.Time { return start.Add(time.Duration(minute) * time.Minute) }
+
+	// code: 10m, 30m, 20m separated by firefox (2m each), so nothing merges
+	runSession(tracker, "code", at(0), at(10))
+	runSession(tracker, "firefox", at(10), at(12))
+	runSession(tracker, "code", at(12), at(42))
+	runSession(tracker, "firefox", at(42), at(44))
+	runSession(tracker, "code", at(44), at(64))
+
+	summaries := tracker.GetCompletedActivitySummaries()
+	code := summaries["code"]
+	if code.SessionCount != 3 || code.TotalDuration != 60*time.Minute {
+		t.Fatalf("Expected 3 code sessions totalling 60m, got %d and %v", code.SessionCount, code.TotalDuration)
+	}
+	if code.MaxSessionDuration != 30*time.Minute || !code.LongestSessionStart.Equal(at(12)) {
+		t.Errorf("Expected longest code session 30m at %v, got %v at %v", at(12), code.MaxSessionDuration, code.LongestSessionStart)
+	}
+	if code.AvgSessionDuration != 20*time.Minute {
+		t.Errorf("Expected average code session 20m, got %v", code.AvgSessionDuration)
+	}
+	firefox := summaries["firefox"]
+	if firefox.MaxSessionDuration != 2*time.Minute || firefox.AvgSessionDuration != 2*time.Minute || !firefox.LongestSessionStart.Equal(at(10)) {
+		t.Errorf("Expected firefox 2m sessions starting at %v, got %+v", at(10), firefox)
+	}
+
+	// An active session longer than the rest becomes the longest in live summaries only
+	tracker.mu.Lock()
+	tracker.currentSession = &Session{StartTime: time.Now().Add(-time.Hour), AppClass: "code", Active: true}
+	tracker.mu.Unlock()
+	live := tracker.GetActivitySummaries()["code"]
+	if live.SessionCount != 4 || live.MaxSessionDuration < time.Hour {
+		t.Errorf("Expected the hour-long active session to be the longest, got %d sessions, max %v", live.SessionCount, live.MaxSessionDuration)
+	}
+	if completed := tracker.GetCompletedActivitySummaries()["code"]; completed.MaxSessionDuration != 30*time.Minute {
+		t.Errorf("Expected completed summaries to ignore the active session, got max %v", completed.MaxSessionDuration)
+	}
+}
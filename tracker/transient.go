@@ -0,0 +1,56 @@
+package tracker
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// DefaultTransientHelpers are WmClasses of the dialogs applications open in a separate
+// process (file choosers, password prompts), for SetTransientHelpers
+var DefaultTransientHelpers = []string{
+	"xdg-desktop-portal-gtk",
+	"xdg-desktop-portal-gnome",
+	"xdg-desktop-portal-kde",
+	"gcr-prompter",
+	"pinentry",
+	"pinentry-gnome3",
+}
+
+// DefaultTransientHelperMax is the usual SetTransientHelpers max: a helper focused longer
+// than this is tracked as itself
+const DefaultTransientHelperMax = 30 * time.Second
+
+// SetTransientHelpers sets the WmClasses (matched case-insensitively) whose sessions shorter
+// than max count toward the application around them instead of themselves. A max of 0
+// disables it.
+func (at *Tracker) SetTransientHelpers(classes []string, max time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.transientHelpers = make(map[string]bool, len(classes))
+	for _, class := range classes {
+		if class = strings.ToLower(strings.TrimSpace(class)); class != "" {
+			at.transientHelpers[class] = true
+		}
+	}
+	at.transientMax = max
+}
+
+// absorbTransientUnsafe reports whether the ended current session is a brief transient
+// helper, such as a file chooser portal, that an application opened over its own window. It
+// isn't stored; instead its time counts as part of the gap to the next session, so focus
+// returning to the application merges it with the session before the helper, helper time
+// included (must be called with lock held).
+func (at *Tracker) absorbTransientUnsafe() bool {
+	session := at.currentSession
+	if at.transientMax <= 0 || session.Duration >= at.transientMax || len(at.sessions) == 0 {
+		return false
+	}
+	if !at.transientHelpers[strings.ToLower(session.AppClass)] {
+		return false
+	}
+	at.transientGap += session.Duration
+	logging.Debug("Absorbed transient helper %s (%v) into the surrounding application", session.AppClass, session.Duration)
+	return true
+}
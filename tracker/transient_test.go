@@ -0,0 +1,63 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTransientHelper tests that brief focus on a file chooser portal between two stretches
+// of an application yields one session for the application, portal time included, and
+// nothing for the portal
+func TestTransientHelper(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		portal time.Duration
+	}{
+		{"shorter than the minimum duration", 3 * time.Second},
+		{"longer than the minimum duration", 20 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := New()
+			tracker.SetTransientHelpers(DefaultTransientHelpers, DefaultTransientHelperMax)
+			at := start
+			for _, s := range []struct {
+				app      string
+				duration time.Duration
+			}{
+				{"gimp", 30 * time.Second},
+				{"xdg-desktop-portal-gtk", tt.portal},
+				{"gimp", 30 * time.Second},
+			} {
+				pollSession(tracker, s.app, at, at.Add(s.duration), func(int) bool { return false })
+				at = at.Add(s.duration)
+			}
+
+			sessions := tracker.GetSessions()
+			if len(sessions) != 1 || sessions[0].AppClass != "gimp" || sessions[0].Duration != time.Minute+tt.portal {
+				t.Fatalf("Expected one gimp session of %v, got %+v", time.Minute+tt.portal, sessions)
+			}
+			if _, ok := tracker.GetCompletedActivitySummaries()["xdg-desktop-portal-gtk"]; ok {
+				t.Error("Expected no summary for the portal")
+			}
+		})
+	}
+}
+
+// TestTransientHelperTrackedWhenLong tests that a helper focused for -transient-max or more,
+// and any other application, is tracked as itself
+func TestTransientHelperTrackedWhenLong(t *testing.T) {
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	for _, between := range []string{"xdg-desktop-portal-gtk", "firefox"} {
+		tracker := New()
+		tracker.SetTransientHelpers(DefaultTransientHelpers, 15*time.Second)
+		pollSession(tracker, "gimp", start, start.Add(30*time.Second), func(int) bool { return false })
+		pollSession(tracker, between, start.Add(30*time.Second), start.Add(50*time.Second), func(int) bool { return false })
+		pollSession(tracker, "gimp", start.Add(50*time.Second), start.Add(80*time.Second), func(int) bool { return false })
+
+		if sessions := tracker.GetSessions(); len(sessions) != 3 || sessions[1].AppClass != between {
+			t.Errorf("Expected %s tracked between the gimp sessions, got %+v", between, sessions)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/logging"
+)
+
+// WAL is an append-only log of completed sessions (one JSON object per line) that
+// haven't been handed to every backend yet. It's truncated once the tracker clears its sessions
+// (or rewritten as cursors consume them), so on startup anything left in it was lost by a
+// crash and is replayed.
+type WAL struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenWAL opens (or creates) the log at path for appending
+func OpenWAL(path string) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session log %s: %v\n\nCheck the directory exists and is writable, or pass a different -wal path", path, err)
+	}
+	return &WAL{path: path, file: file}, nil
+}
+
+// Append writes a completed session and syncs it to disk
+func (w *WAL) Append(session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session for the session log: %v", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append to session log: %v", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync session log: %v", err)
+	}
+	return nil
+}
+
+// Entries reads the logged sessions, oldest first. A line that can't be parsed (e.g. cut
+// short by a crash mid-write) is skipped and counted in skipped.
+func (w *WAL) Entries() (sessions []Session, skipped int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := os.Open(w.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read session log: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // window titles can be long
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			skipped++
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if err := scanner.Err(); err != nil {
+		return sessions, skipped, fmt.Errorf("failed to read session log: %v", err)
+	}
+	return sessions, skipped, nil
+}
+
+// Truncate empties the log once its sessions have been handed to the backends
+func (w *WAL) Truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate session log: %v", err)
+	}
+	return nil
+}
+
+// Rewrite replaces the log with sessions, once the ones before them have been handed to
+// every backend
+func (w *WAL) Rewrite(sessions []Session) error {
+	var data []byte
+	for _, session := range sessions {
+		line, err := json.Marshal(session)
+		if err != nil {
+			return fmt.Errorf("failed to marshal session for the session log: %v", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate session log: %v", err)
+	}
+	// The file is opened for appending, so this lands at the start
+	if _, err := w.file.Write(data); err != nil {
+		return fmt.Errorf("failed to rewrite session log: %v", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync session log: %v", err)
+	}
+	return nil
+}
+
+// Close closes the log file, keeping its contents
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// SetWAL logs each completed session to wal from now on; nil disables logging
+func (at *Tracker) SetWAL(wal *WAL) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.wal = wal
+}
+
+// ReplayWAL restores the sessions left in wal by a previous run that didn't submit them
+// (e.g. it crashed), as completed sessions. Returns how many were restored.
+func (at *Tracker) ReplayWAL(wal *WAL) (int, error) {
+	sessions, skipped, err := wal.Entries()
+	if err != nil {
+		return 0, err
+	}
+	if skipped > 0 {
+		logging.Warning("Skipped %d unreadable entries in the session log %s", skipped, wal.path)
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	current := at.currentSession
+	for i := range sessions {
+		at.currentSession = &sessions[i]
+		at.currentSession.Active = false
+		at.storeCurrentSessionUnsafe()
+	}
+	at.currentSession = current
+	return len(sessions), nil
+}
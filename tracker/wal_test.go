@@ -0,0 +1,96 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReplayWALAfterCrash tests that sessions completed since the last submission survive a crash
+func TestReplayWALAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+
+	wal, err := OpenWAL(path)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	tracker := New()
+	tracker.SetWAL(wal)
+
+	// First interval is submitted and cleared
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	tracker.ClearCompletedSessions()
+
+	// Second interval: two code segments that merge, one browser session, then a crash
+	runSession(tracker, "code", start.Add(15*time.Minute), start.Add(20*time.Minute))
+	runSession(tracker, "code", start.Add(20*time.Minute), start.Add(30*time.Minute))
+	runSession(tracker, "firefox", start.Add(30*time.Minute), start.Add(40*time.Minute))
+	runSession(tracker, "slack", start.Add(40*time.Minute), start.Add(40*time.Minute+time.Second)) // too short to log
+	want := tracker.GetCompletedActivitySummaries()
+	wal.Close() // the process dies without submitting
+
+	// A crash mid-write leaves a partial line at the end
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"start_time":"2025-10-29T09:40:`)
+	file.Close()
+
+	reopened, err := OpenWAL(path)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer reopened.Close()
+	restarted := New()
+	restored, err := restarted.ReplayWAL(reopened)
+	if err != nil {
+		t.Fatalf("ReplayWAL failed: %v", err)
+	}
+	if restored != 3 {
+		t.Errorf("Expected 3 logged segments to be replayed, got %d", restored)
+	}
+
+	got := restarted.GetCompletedActivitySummaries()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d summaries after replay, got %d: %v", len(want), len(got), got)
+	}
+	for key, summary := range want {
+		if got[key].TotalDuration != summary.TotalDuration || got[key].SessionCount != summary.SessionCount {
+			t.Errorf("%s: expected %v in %d sessions, got %v in %d", key, summary.TotalDuration, summary.SessionCount, got[key].TotalDuration, got[key].SessionCount)
+		}
+	}
+	if got["code"].TotalDuration != 15*time.Minute || got["code"].SessionCount != 1 {
+		t.Errorf("Expected the merged 15m code session only (first interval was submitted), got %+v", got["code"])
+	}
+}
+
+// TestWALTruncatedAfterSubmission tests that cleared sessions aren't replayed
+func TestWALTruncatedAfterSubmission(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	wal, err := OpenWAL(path)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	defer wal.Close()
+
+	tracker := New()
+	tracker.SetWAL(wal)
+	start := time.Date(2025, 10, 29, 9, 0, 0, 0, time.Local)
+	runSession(tracker, "code", start, start.Add(10*time.Minute))
+	tracker.ClearCompletedSessions()
+
+	// Appends after truncation start at the beginning of the file
+	runSession(tracker, "firefox", start.Add(20*time.Minute), start.Add(30*time.Minute))
+	entries, skipped, err := wal.Entries()
+	if err != nil || skipped != 0 {
+		t.Fatalf("Entries failed: %v (%d skipped)", err, skipped)
+	}
+	if len(entries) != 1 || entries[0].AppClass != "firefox" {
+		t.Errorf("Expected only the firefox session after truncation, got %+v", entries)
+	}
+
+	tracker.ClearCompletedSessions()
+	if entries, _, _ := wal.Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty log after clearing, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+)
+
+// anonymizedPrefix marks hashed application classes, e.g. "app-3f2a9c1d7e4b6a08"
+const anonymizedPrefix = anonymize.AppPrefix
+
+// AnonymizeApp returns the stable pseudonym Client.AnonymizeApps sends for appClass: an
+// HMAC-SHA256 of the class keyed by salt, shortened to 16 hex digits. The same class and
+// salt always give the same pseudonym, so a receiver can still group and total by it.
+func AnonymizeApp(appClass, salt string) string {
+	return anonymize.App(appClass, salt)
+}
+
+// anonymize returns a copy of payload with every application class replaced by its
+// AnonymizeApp pseudonym and titles handled as AnonymizeTitles says. Desktop entry details
+// would name the application, so they are dropped. Durations, counts and times are unchanged.
+func (c *Client) anonymize(payload WebhookPayload) WebhookPayload {
+	summaries := make([]ActivitySummary, len(payload.Summaries))
+	for i, summary := range payload.Summaries {
+		summary.AppClass = AnonymizeApp(summary.AppClass, c.AnonymizeSalt)
+		summary.DisplayName, summary.Icon, summary.DesktopID = "", "", ""
+		summary.ActivityDetails = anonymize.ApplyTitle(summary.ActivityDetails, c.AnonymizeTitles, c.AnonymizeSalt)
+		summary.RawTitle = anonymize.ApplyTitle(summary.RawTitle, c.AnonymizeTitles, c.AnonymizeSalt)
+		summaries[i] = summary
+	}
+	payload.Summaries = summaries
+
+	if payload.Sessions != nil {
+		sessions := make([]ActivitySession, len(payload.Sessions))
+		for i, session := range payload.Sessions {
+			session.AppClass = AnonymizeApp(session.AppClass, c.AnonymizeSalt)
+			session.WindowTitle = anonymize.ApplyTitle(session.WindowTitle, c.AnonymizeTitles, c.AnonymizeSalt)
+			sessions[i] = session
+		}
+		payload.Sessions = sessions
+	}
+	return payload
+}
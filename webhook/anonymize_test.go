@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/anonymize"
+)
+
+// TestAnonymizeApp tests that a class always hashes to the same pseudonym, that other
+// classes and other salts give different ones, and that the class isn't recoverable from it
+func TestAnonymizeApp(t *testing.T) {
+	code := AnonymizeApp("code", "salt")
+	if code != AnonymizeApp("code", "salt") {
+		t.Error("Expected identical classes to hash identically")
+	}
+	if code == AnonymizeApp("firefox", "salt") {
+		t.Error("Expected different classes to hash differently")
+	}
+	if code == AnonymizeApp("code", "other salt") {
+		t.Error("Expected a different salt to change the hash")
+	}
+	if !strings.HasPrefix(code, anonymizedPrefix) || len(code) != len(anonymizedPrefix)+16 || strings.Contains(code, "code") {
+		t.Errorf("Unexpected pseudonym %q", code)
+	}
+}
+
+// TestAnonymizeApps tests that summaries and sessions go out with hashed classes and no
+// desktop entry details, while durations and counts are kept and the caller's data is untouched
+func TestAnonymizeApps(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.AnonymizeApps = true
+	client.AnonymizeSalt = "team-dashboard"
+
+	now := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summaries := map[string]ActivitySummary{
+		"code": {AppClass: "code", ActivityDetails: "main.go", DisplayName: "Visual Studio Code", Icon: "vscode", DesktopID: "code.desktop", TotalDuration: 40 * time.Minute, SessionCount: 2, FirstSeen: now, LastSeen: now.Add(time.Hour)},
+		"zoom": {AppClass: "zoom", ActivityDetails: "Meeting", TotalDuration: 20 * time.Minute, SessionCount: 1, FirstSeen: now.Add(time.Hour), LastSeen: now.Add(80 * time.Minute)},
+	}
+	sessions := []ActivitySession{{StartTime: now, EndTime: now.Add(40 * time.Minute), AppClass: "code", WindowTitle: "main.go", Duration: 40 * time.Minute}}
+	client.SubmitActivitiesWithSessions(summaries, sessions)
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	for _, name := range []string{"\"code\"", "\"zoom\"", "Visual Studio Code", "vscode", "code.desktop"} {
+		if bytes.Contains(body, []byte(name)) {
+			t.Errorf("Payload reveals %s: %s", name, body)
+		}
+	}
+
+	hashed := AnonymizeApp("code", "team-dashboard")
+	found := false
+	for _, summary := range payload.Summaries {
+		if summary.AppClass == hashed {
+			found = true
+			if summary.TotalDuration != 40*time.Minute || summary.SessionCount != 2 {
+				t.Errorf("Expected the duration and count kept, got %v and %d", summary.TotalDuration, summary.SessionCount)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected a summary for %s, got %+v", hashed, payload.Summaries)
+	}
+	if len(payload.Sessions) != 1 || payload.Sessions[0].AppClass != hashed || payload.Sessions[0].Duration != 40*time.Minute {
+		t.Errorf("Expected the session under the same pseudonym as its summary, got %+v", payload.Sessions)
+	}
+
+	if summaries["code"].AppClass != "code" || sessions[0].AppClass != "code" {
+		t.Error("Expected the caller's summaries and sessions unchanged")
+	}
+}
+
+// TestAnonymizeTitles tests that AnonymizeTitles drops or hashes summary and session titles
+func TestAnonymizeTitles(t *testing.T) {
+	client := &Client{AnonymizeApps: true, AnonymizeSalt: "salt"}
+	payload := WebhookPayload{
+		Summaries: []ActivitySummary{{AppClass: "code", ActivityDetails: "main.go", RawTitle: "main.go - Code"}},
+		Sessions:  []ActivitySession{{AppClass: "code", WindowTitle: "main.go"}},
+	}
+
+	if kept := client.anonymize(payload); kept.Summaries[0].ActivityDetails != "main.go" || kept.Sessions[0].WindowTitle != "main.go" {
+		t.Errorf("Expected titles kept by default, got %+v", kept)
+	}
+	client.AnonymizeTitles = anonymize.TitlesDrop
+	if dropped := client.anonymize(payload); dropped.Summaries[0].ActivityDetails != "" || dropped.Summaries[0].RawTitle != "" || dropped.Sessions[0].WindowTitle != "" {
+		t.Errorf("Expected titles dropped, got %+v", dropped)
+	}
+	client.AnonymizeTitles = anonymize.TitlesHash
+	hashed := client.anonymize(payload)
+	if title := anonymize.Title("main.go", "salt"); hashed.Summaries[0].ActivityDetails != title || hashed.Sessions[0].WindowTitle != title {
+		t.Errorf("Expected titles hashed to %s, got %+v", title, hashed)
+	}
+}
@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+	"github.com/fatih/color"
+)
+
+// maxBatchItems is how many summaries and sessions a batch keeps for the next flush while
+// the endpoint stays unreachable; the oldest beyond it are dropped
+const maxBatchItems = 10000
+
+// batch holds submissions waiting to be sent together (see EnableBatching)
+type batch struct {
+	window   time.Duration // flush at least this often
+	maxItems int           // flush early once this many summaries and sessions are waiting; 0 disables
+
+	mu        sync.Mutex
+	summaries []ActivitySummary
+	sessions  []ActivitySession
+	closed    bool // Close has run; later submissions are sent immediately
+
+	full      chan struct{} // signals the flusher that maxItems was reached (buffered, never blocks)
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error // result of the final flush
+}
+
+// EnableBatching makes SubmitActivities and SubmitActivitiesWithSessions queue their data
+// instead of sending it, so a rate-limited endpoint gets fewer, larger requests. A background
+// goroutine sends everything queued as one payload every window, or as soon as maxItems
+// summaries and sessions are waiting (0 for no size limit). Close sends whatever is left.
+//
+// Call it once, before the first submission. A batch that fails with a retryable error
+// (DeliveryError.Class) is kept and sent with the next flush, up to maxBatchItems; one the
+// endpoint refused, or the final flush at Close, is dropped.
+func (c *Client) EnableBatching(window time.Duration, maxItems int) error {
+	if window <= 0 {
+		return fmt.Errorf("webhook batch window must be positive, got %v", window)
+	}
+	if maxItems < 0 {
+		return fmt.Errorf("webhook batch size must not be negative, got %d", maxItems)
+	}
+	if c.batch != nil {
+		return fmt.Errorf("webhook batching is already enabled")
+	}
+
+	c.batch = &batch{
+		window:   window,
+		maxItems: maxItems,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.runBatch(c.batch)
+	return nil
+}
+
+// batching reports whether submissions are queued rather than sent immediately
+func (c *Client) batching() bool {
+	return c.batch != nil
+}
+
+// enqueue adds validated summaries and sessions to the batch, waking the flusher if the
+// batch is full. After Close they are sent immediately instead.
+func (c *Client) enqueue(summaries []ActivitySummary, sessions []ActivitySession) {
+	if len(summaries) == 0 && len(sessions) == 0 {
+		return
+	}
+	b := c.batch
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		if err := c.sendBatch(summaries, sessions); err != nil {
+			color.Red("[WEBHOOK] ✗ Failed to send activities: %v\n", err)
+		}
+		return
+	}
+	b.summaries = append(b.summaries, summaries...)
+	b.sessions = append(b.sessions, sessions...)
+	waiting := len(b.summaries) + len(b.sessions)
+	b.mu.Unlock()
+
+	c.debugLog("Queued %d summaries and %d sessions for the next batch (%d items waiting)", len(summaries), len(sessions), waiting)
+	if b.maxItems > 0 && waiting >= b.maxItems {
+		select {
+		case b.full <- struct{}{}:
+		default: // a flush is already pending
+		}
+	}
+}
+
+// runBatch flushes the batch every window, when it fills up, and once more when stopped
+func (c *Client) runBatch(b *batch) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flushBatch(b, false)
+		case <-b.full:
+			c.flushBatch(b, false)
+			ticker.Reset(b.window) // the batch was just emptied, so give the next one a full window
+		case <-b.stop:
+			b.closeErr = c.flushBatch(b, true)
+			return
+		}
+	}
+}
+
+// flushBatch sends everything queued as one payload. The final flush also marks the batch
+// closed in the same step, so nothing can be queued after it and never sent.
+func (c *Client) flushBatch(b *batch, final bool) error {
+	b.mu.Lock()
+	summaries, sessions := b.summaries, b.sessions
+	b.summaries, b.sessions = nil, nil
+	b.closed = b.closed || final
+	b.mu.Unlock()
+
+	if len(summaries) == 0 && len(sessions) == 0 {
+		return nil
+	}
+	err := c.sendBatch(summaries, sessions)
+	if err == nil {
+		return nil
+	}
+	color.Red("[WEBHOOK] ✗ Failed to send batched activities: %v\n", err)
+	var delivery *DeliveryError
+	if !final && errors.As(err, &delivery) && delivery.Class == ClassRetryable {
+		c.keepBatch(b, summaries, sessions)
+	}
+	return err
+}
+
+// keepBatch puts a batch that failed to send back in front of what was queued since, so
+// the next flush sends it, dropping the oldest items beyond maxBatchItems
+func (c *Client) keepBatch(b *batch, summaries []ActivitySummary, sessions []ActivitySession) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.summaries = append(summaries, b.summaries...)
+	b.sessions = append(sessions, b.sessions...)
+	over := len(b.summaries) + len(b.sessions) - maxBatchItems
+	if over <= 0 {
+		color.Yellow("[WEBHOOK] Keeping %d summaries and %d sessions for the next batch\n", len(summaries), len(sessions))
+		return
+	}
+	color.Yellow("[WEBHOOK] Dropping the %d oldest batched items, more than %d are waiting\n", over, maxBatchItems)
+	dropped := min(over, len(b.summaries))
+	b.summaries = b.summaries[dropped:]
+	b.sessions = b.sessions[over-dropped:]
+}
+
+// sendBatch sends already validated summaries and sessions as a single payload
+func (c *Client) sendBatch(summaries []ActivitySummary, sessions []ActivitySession) error {
+	color.New(color.FgCyan, color.Bold).Printf("\n=== Sending batch of %d activities and %d sessions to webhook ===\n", len(summaries), len(sessions))
+
+	payload := WebhookPayload{
+		Timestamp: time.Now(),
+		Source:    version.Product,
+		Version:   version.Version,
+		Summaries: summaries,
+		Sessions:  sessions,
+		Metadata: c.metadata(map[string]interface{}{
+			"summary_count": len(summaries),
+			"session_count": len(sessions),
+			"submitted":     time.Now().Format(time.RFC3339),
+		}),
+	}
+	if err := c.sendPayload(payload); err != nil {
+		return err
+	}
+
+	color.New(color.FgGreen, color.Bold).Printf("[SUCCESS] Sent %d summaries and %d sessions to webhook\n", len(summaries), len(sessions))
+	return nil
+}
+
+// stopBatching stops the flusher after a final flush, returning that flush's error
+func (c *Client) stopBatching() error {
+	b := c.batch
+	if b == nil {
+		return nil
+	}
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+	})
+	return b.closeErr
+}
@@ -0,0 +1,228 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer records the payloads it receives
+type batchServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads []WebhookPayload
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	t.Helper()
+	s := &batchServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		s.mu.Lock()
+		s.payloads = append(s.payloads, payload)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received returns a copy of the payloads received so far
+func (s *batchServer) received() []WebhookPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]WebhookPayload(nil), s.payloads...)
+}
+
+// waitFor polls until n payloads have arrived or the timeout passes
+func (s *batchServer) waitFor(n int, timeout time.Duration) []WebhookPayload {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if payloads := s.received(); len(payloads) >= n {
+			return payloads
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return s.received()
+}
+
+// batchSummary returns a valid summary for appClass
+func batchSummary(appClass string) ActivitySummary {
+	now := time.Now()
+	return ActivitySummary{AppClass: appClass, TotalDuration: time.Minute, SessionCount: 1, FirstSeen: now.Add(-time.Minute), LastSeen: now}
+}
+
+// TestBatchingTimeFlush tests that queued submissions are sent together once the window passes
+func TestBatchingTimeFlush(t *testing.T) {
+	server := newBatchServer(t)
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.EnableBatching(100*time.Millisecond, 0); err != nil {
+		t.Fatalf("EnableBatching failed: %v", err)
+	}
+	defer client.Close()
+
+	now := time.Now()
+	session := ActivitySession{StartTime: now.Add(-time.Minute), EndTime: now, AppClass: "code", Duration: time.Minute}
+	client.SubmitActivities(map[string]ActivitySummary{"firefox": batchSummary("firefox")})
+	client.SubmitActivitiesWithSessions(map[string]ActivitySummary{"code": batchSummary("code")}, []ActivitySession{session})
+
+	if payloads := server.received(); len(payloads) != 0 {
+		t.Fatalf("Expected submissions to be queued, got %d payloads immediately", len(payloads))
+	}
+
+	payloads := server.waitFor(1, 2*time.Second)
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 batched payload after the window, got %d", len(payloads))
+	}
+	if len(payloads[0].Summaries) != 2 || len(payloads[0].Sessions) != 1 {
+		t.Errorf("Expected 2 summaries and 1 session in the batch, got %d and %d", len(payloads[0].Summaries), len(payloads[0].Sessions))
+	}
+	if payloads[0].Metadata["summary_count"] != float64(2) || payloads[0].Metadata["session_count"] != float64(1) {
+		t.Errorf("Expected batch counts in metadata, got %v", payloads[0].Metadata)
+	}
+}
+
+// TestBatchingSizeFlush tests that a full batch is sent before the window passes
+func TestBatchingSizeFlush(t *testing.T) {
+	server := newBatchServer(t)
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.EnableBatching(time.Hour, 3); err != nil {
+		t.Fatalf("EnableBatching failed: %v", err)
+	}
+	defer client.Close()
+
+	client.SubmitActivities(map[string]ActivitySummary{"a": batchSummary("a"), "b": batchSummary("b")})
+	time.Sleep(50 * time.Millisecond)
+	if payloads := server.received(); len(payloads) != 0 {
+		t.Fatalf("Expected no flush below the size limit, got %d payloads", len(payloads))
+	}
+
+	client.SubmitActivities(map[string]ActivitySummary{"c": batchSummary("c")})
+	payloads := server.waitFor(1, 2*time.Second)
+	if len(payloads) != 1 || len(payloads[0].Summaries) != 3 {
+		t.Fatalf("Expected one payload with 3 summaries once the batch filled, got %+v", payloads)
+	}
+}
+
+// TestBatchingCloseFlushes tests that Close sends the remainder and later submissions go out directly
+func TestBatchingCloseFlushes(t *testing.T) {
+	server := newBatchServer(t)
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.EnableBatching(time.Hour, 0); err != nil {
+		t.Fatalf("EnableBatching failed: %v", err)
+	}
+
+	client.SubmitActivities(map[string]ActivitySummary{"firefox": batchSummary("firefox")})
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if payloads := server.received(); len(payloads) != 1 || len(payloads[0].Summaries) != 1 {
+		t.Fatalf("Expected Close to send the queued summary, got %+v", payloads)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Expected a second Close to succeed, got %v", err)
+	}
+
+	client.SubmitActivities(map[string]ActivitySummary{"code": batchSummary("code")})
+	if payloads := server.received(); len(payloads) != 2 {
+		t.Errorf("Expected a submission after Close to be sent immediately, got %d payloads", len(payloads))
+	}
+}
+
+// TestBatchingKeepsFailedBatch tests that a batch the endpoint couldn't take is sent with
+// the next flush, along with what was queued since
+func TestBatchingKeepsFailedBatch(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+	var payloads []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.retryDelay = time.Millisecond
+	if err := client.EnableBatching(time.Hour, 1); err != nil {
+		t.Fatalf("EnableBatching failed: %v", err)
+	}
+
+	client.SubmitActivities(map[string]ActivitySummary{"firefox": batchSummary("firefox")})
+	// The size limit flushes right away; wait for that flush to fail and keep the batch
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client.batch.mu.Lock()
+		kept := len(client.batch.summaries)
+		client.batch.mu.Unlock()
+		if kept == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	client.SubmitActivities(map[string]ActivitySummary{"code": batchSummary("code")})
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var apps []string
+	for _, payload := range payloads {
+		for _, summary := range payload.Summaries {
+			apps = append(apps, summary.AppClass)
+		}
+	}
+	sort.Strings(apps)
+	if len(apps) != 2 || apps[0] != "code" || apps[1] != "firefox" {
+		t.Errorf("Expected the failed firefox summary sent along with code, got %v", apps)
+	}
+}
+
+// TestEnableBatchingValidation tests that invalid settings and enabling twice are rejected
+func TestEnableBatchingValidation(t *testing.T) {
+	client, err := NewClient("https://example.com/webhook")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.EnableBatching(0, 10); err == nil {
+		t.Error("Expected an error for a zero window")
+	}
+	if err := client.EnableBatching(time.Minute, -1); err == nil {
+		t.Error("Expected an error for a negative size")
+	}
+	if err := client.EnableBatching(time.Minute, 10); err != nil {
+		t.Fatalf("EnableBatching failed: %v", err)
+	}
+	defer client.Close()
+	if err := client.EnableBatching(time.Minute, 10); err == nil {
+		t.Error("Expected an error when enabling batching twice")
+	}
+}
@@ -23,22 +23,34 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
 	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
 	"github.com/fatih/color"
 )
 
 // Configuration constants
 const (
-	defaultRequestTimeout = 30 * time.Second
-	maxRetries            = 3
-	baseRetryDelay        = 1 * time.Second
+	defaultRequestTimeout        = 30 * time.Second
+	defaultDialTimeout           = 10 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 20 * time.Second
+	maxRetries                   = 3
+	baseRetryDelay               = 1 * time.Second
+	duplicateMarker              = "duplicate" // 409 response bodies containing this are treated as already delivered
 )
 
 // Type aliases to use RescueTime's types for consistency
@@ -52,6 +64,31 @@ type ActivitySession struct {
 	WindowTitle string        `json:"window_title"`
 	Duration    time.Duration `json:"duration"`
 	Ignored     bool          `json:"ignored"` // true if app is in ignore list (excluded from RescueTime)
+
+	// OutsideActiveHours is true if the session started outside the tracker's -active-hours
+	// schedule (excluded from RescueTime)
+	OutsideActiveHours bool `json:"outside_active_hours"`
+
+	// ActiveSeconds and IdleSeconds split the duration into time with input and time the
+	// window sat focused without input (e.g. reading a long document)
+	ActiveSeconds int `json:"active_seconds"`
+	IdleSeconds   int `json:"idle_seconds"`
+
+	// Coverage is the share of expected polls that saw the session, 0 to 1; omitted when
+	// unknown. Low values mean the duration is less certain.
+	Coverage float64 `json:"coverage,omitempty"`
+
+	// MaximizedSeconds is the part of the duration the window was maximized or fullscreen,
+	// with the tracker's -track-geometry; omitted otherwise
+	MaximizedSeconds int `json:"maximized_seconds,omitempty"`
+
+	// Context is the tracker's context label (e.g. work) when the session started; omitted
+	// without one
+	Context string `json:"context,omitempty"`
+
+	// Secondary is true for time a -background-apps application (e.g. a meeting) had a window
+	// open behind the focused one; it overlaps the other sessions and isn't in the summaries
+	Secondary bool `json:"secondary,omitempty"`
 }
 
 // WebhookPayload represents the JSON structure sent to the webhook endpoint.
@@ -65,12 +102,82 @@ type WebhookPayload struct {
 	Metadata   map[string]interface{}     `json:"metadata,omitempty"`
 }
 
+// TransportOptions configures the connection-level timeouts of the webhook client.
+// These are fixed when the client is created; the overall per-request deadline is
+// controlled separately via SetTimeout.
+type TransportOptions struct {
+	DialTimeout           time.Duration // TCP connect timeout
+	TLSHandshakeTimeout   time.Duration // TLS handshake timeout (HTTPS only)
+	ResponseHeaderTimeout time.Duration // time to wait for response headers after the request is written
+}
+
+// DefaultTransportOptions returns the transport timeouts used by NewClient.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		DialTimeout:           defaultDialTimeout,
+		TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: defaultResponseHeaderTimeout,
+	}
+}
+
+// IdempotencyKey returns a deterministic key for the payload's activity content.
+// Submission timestamps and metadata are excluded, so the same summaries and sessions
+// always produce the same key, even when re-sent after a restart.
+// Summaries are built from a map, so both lists are sorted before hashing.
+func (p WebhookPayload) IdempotencyKey() string {
+	summaries := append([]ActivitySummary(nil), p.Summaries...)
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].FirstSeen.Equal(summaries[j].FirstSeen) {
+			return summaries[i].FirstSeen.Before(summaries[j].FirstSeen)
+		}
+		return summaries[i].ActivityName() < summaries[j].ActivityName()
+	})
+	sessions := append([]ActivitySession(nil), p.Sessions...)
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].StartTime.Equal(sessions[j].StartTime) {
+			return sessions[i].StartTime.Before(sessions[j].StartTime)
+		}
+		return sessions[i].AppClass < sessions[j].AppClass
+	})
+
+	return rescuetime.IdempotencyKey(struct {
+		Summaries []ActivitySummary `json:"summaries"`
+		Sessions  []ActivitySession `json:"sessions"`
+	}{summaries, sessions})
+}
+
 // Client provides methods for sending activity data to a webhook endpoint.
 type Client struct {
-	webhookURL    string
-	httpClient    *http.Client
-	DebugMode     bool
-	CustomHeaders map[string]string
+	webhookURL     string
+	httpClient     *http.Client
+	requestTimeout atomic.Int64 // overall per-request deadline in nanoseconds, read on every attempt
+	retryDelay     time.Duration
+	DebugMode      bool
+	CustomHeaders  map[string]string
+	CustomMetadata map[string]string // static metadata merged into every payload (built-in keys take precedence)
+
+	batch *batch // set by EnableBatching; nil sends every submission immediately
+
+	// Template, if set, reshapes each payload into the body the endpoint expects (see
+	// LoadTemplate); nil sends the WebhookPayload JSON as is
+	Template *PayloadTemplate
+
+	// PrettyPrint indents the JSON body (templated ones too), for receivers read by people
+	// during development. Off by default: the indentation only costs bandwidth.
+	PrettyPrint bool
+
+	// AnonymizeApps replaces the application class of every summary and session with a
+	// salted hash (see AnonymizeApp) before sending, for endpoints that shouldn't learn which
+	// applications were used. AnonymizeTitles is an anonymize.Titles* mode for the window
+	// titles; empty sends them unchanged.
+	AnonymizeApps   bool
+	AnonymizeSalt   string
+	AnonymizeTitles string
+
+	// RetryOn decides whether a failed attempt should be retried. It receives the
+	// HTTP status (0 if no response) and the transport error (nil if a response
+	// was received). If nil, DefaultRetryOn is used.
+	RetryOn func(status int, err error) bool
 }
 
 // NewClient creates a new webhook client.
@@ -79,6 +186,12 @@ type Client struct {
 // If webhookURL is empty, it will attempt to read from WEBHOOK_URL
 // environment variable.
 func NewClient(webhookURL string) (*Client, error) {
+	return NewClientWithTransport(webhookURL, DefaultTransportOptions())
+}
+
+// NewClientWithTransport creates a new webhook client with custom connection timeouts.
+// Zero values in opts fall back to the defaults used by NewClient.
+func NewClientWithTransport(webhookURL string, opts TransportOptions) (*Client, error) {
 	// Use provided URL, or fall back to environment variable
 	if webhookURL == "" {
 		webhookURL = os.Getenv("WEBHOOK_URL")
@@ -93,22 +206,48 @@ func NewClient(webhookURL string) (*Client, error) {
 		return nil, fmt.Errorf("invalid webhook URL: must start with http:// or https://\n\nProvided: %s", webhookURL)
 	}
 
+	defaults := DefaultTransportOptions()
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaults.DialTimeout
+	}
+	if opts.TLSHandshakeTimeout <= 0 {
+		opts.TLSHandshakeTimeout = defaults.TLSHandshakeTimeout
+	}
+	if opts.ResponseHeaderTimeout <= 0 {
+		opts.ResponseHeaderTimeout = defaults.ResponseHeaderTimeout
+	}
+
+	// The http.Client itself has no Timeout: the overall deadline is applied per
+	// request via context so it can be changed safely while submissions are in flight.
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: opts.DialTimeout}).DialContext,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+	}
+
 	client := &Client{
-		webhookURL: webhookURL,
-		httpClient: &http.Client{
-			Timeout: defaultRequestTimeout,
-		},
-		DebugMode:     false,
-		CustomHeaders: make(map[string]string),
+		webhookURL:     webhookURL,
+		httpClient:     &http.Client{Transport: transport},
+		retryDelay:     baseRetryDelay,
+		DebugMode:      false,
+		CustomHeaders:  make(map[string]string),
+		CustomMetadata: make(map[string]string),
+	}
+	client.requestTimeout.Store(int64(defaultRequestTimeout))
+
+	// Lets receivers tell machines apart without extra configuration
+	if hostname, err := os.Hostname(); err == nil {
+		client.CustomMetadata["hostname"] = hostname
 	}
 
 	return client, nil
 }
 
-// Close performs any necessary cleanup.
-// For webhook client, this is a no-op but included for consistency with other modules.
+// Close sends anything still waiting in the batch (see EnableBatching) and stops the
+// background flusher. Without batching it is a no-op. It is safe to call more than once.
 func (c *Client) Close() error {
-	return nil
+	return c.stopBatching()
 }
 
 // debugLog prints debug messages if debug mode is enabled
@@ -126,9 +265,10 @@ func (c *Client) SubmitSummary(summary ActivitySummary) error {
 
 	payload := WebhookPayload{
 		Timestamp: time.Now(),
-		Source:    "rescuetime-linux-mutter",
-		Version:   "1.0.0",
+		Source:    version.Product,
+		Version:   version.Version,
 		Summaries: []ActivitySummary{summary},
+		Metadata:  c.metadata(nil),
 	}
 
 	return c.sendPayload(payload)
@@ -142,18 +282,15 @@ func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
 		return
 	}
 
+	if c.batching() {
+		c.enqueue(c.validSummaries(summaries), nil)
+		return
+	}
+
 	color.New(color.FgCyan, color.Bold).Printf("\n=== Sending %d activities to webhook ===\n", len(summaries))
 
 	// Convert map to slice for JSON payload
-	summaryList := make([]ActivitySummary, 0, len(summaries))
-	for _, summary := range summaries {
-		if err := c.validateSummary(summary); err != nil {
-			color.Red("[WEBHOOK] ✗ Skipping invalid summary for %s: %v\n", summary.AppClass, err)
-			continue
-		}
-		summaryList = append(summaryList, summary)
-	}
-
+	summaryList := c.validSummaries(summaries)
 	if len(summaryList) == 0 {
 		color.Red("[WEBHOOK] No valid activities to submit after validation.")
 		return
@@ -161,13 +298,13 @@ func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
 
 	payload := WebhookPayload{
 		Timestamp: time.Now(),
-		Source:    "rescuetime-linux-mutter",
-		Version:   "1.0.0",
+		Source:    version.Product,
+		Version:   version.Version,
 		Summaries: summaryList,
-		Metadata: map[string]interface{}{
+		Metadata: c.metadata(map[string]interface{}{
 			"count":     len(summaryList),
 			"submitted": time.Now().Format(time.RFC3339),
-		},
+		}),
 	}
 
 	if err := c.sendPayload(payload); err != nil {
@@ -180,16 +317,55 @@ func (c *Client) SubmitActivities(summaries map[string]ActivitySummary) {
 
 // SubmitActivitiesWithSessions sends both activity summaries and individual sessions to the webhook endpoint.
 // This provides the same granular data that gets sent to RescueTime's API, allowing users to build
-// their own applications with complete tracking information.
-func (c *Client) SubmitActivitiesWithSessions(summaries map[string]ActivitySummary, sessions []ActivitySession) {
+// their own applications with complete tracking information. It returns an error if the
+// payload couldn't be delivered, so the caller can send it again (receivers deduplicate by
+// its Idempotency-Key); with batching the data is queued and nil is returned.
+func (c *Client) SubmitActivitiesWithSessions(summaries map[string]ActivitySummary, sessions []ActivitySession) error {
 	if len(summaries) == 0 && len(sessions) == 0 {
 		// No activities to submit - silence is fine, no need to spam logs
-		return
+		return nil
+	}
+
+	if c.batching() {
+		c.enqueue(c.validSummaries(summaries), c.validSessions(sessions))
+		return nil
 	}
 
 	color.New(color.FgCyan, color.Bold).Printf("\n=== Sending %d activities and %d sessions to webhook ===\n", len(summaries), len(sessions))
 
-	// Convert summaries map to slice for JSON payload
+	// Convert summaries map to slice for JSON payload, dropping invalid entries
+	summaryList := c.validSummaries(summaries)
+	validSessions := c.validSessions(sessions)
+
+	if len(summaryList) == 0 && len(validSessions) == 0 {
+		color.Red("[WEBHOOK] No valid activities to submit after validation.")
+		return nil
+	}
+
+	payload := WebhookPayload{
+		Timestamp: time.Now(),
+		Source:    version.Product,
+		Version:   version.Version,
+		Summaries: summaryList,
+		Sessions:  validSessions,
+		Metadata: c.metadata(map[string]interface{}{
+			"summary_count": len(summaryList),
+			"session_count": len(validSessions),
+			"submitted":     time.Now().Format(time.RFC3339),
+		}),
+	}
+
+	if err := c.sendPayload(payload); err != nil {
+		color.Red("[WEBHOOK] ✗ Failed to send activities: %v\n", err)
+		return err
+	}
+
+	color.New(color.FgGreen, color.Bold).Printf("[SUCCESS] Sent %d summaries and %d sessions to webhook\n", len(summaryList), len(validSessions))
+	return nil
+}
+
+// validSummaries returns the summaries that pass validation, reporting the ones skipped
+func (c *Client) validSummaries(summaries map[string]ActivitySummary) []ActivitySummary {
 	summaryList := make([]ActivitySummary, 0, len(summaries))
 	for _, summary := range summaries {
 		if err := c.validateSummary(summary); err != nil {
@@ -198,8 +374,11 @@ func (c *Client) SubmitActivitiesWithSessions(summaries map[string]ActivitySumma
 		}
 		summaryList = append(summaryList, summary)
 	}
+	return summaryList
+}
 
-	// Validate sessions
+// validSessions returns the sessions that pass validation, reporting the ones skipped
+func (c *Client) validSessions(sessions []ActivitySession) []ActivitySession {
 	validSessions := make([]ActivitySession, 0, len(sessions))
 	for _, session := range sessions {
 		if err := c.validateSession(session); err != nil {
@@ -208,45 +387,91 @@ func (c *Client) SubmitActivitiesWithSessions(summaries map[string]ActivitySumma
 		}
 		validSessions = append(validSessions, session)
 	}
+	return validSessions
+}
 
-	if len(summaryList) == 0 && len(validSessions) == 0 {
-		color.Red("[WEBHOOK] No valid activities to submit after validation.")
-		return
+// metadata merges the client's custom metadata with the built-in values.
+// Built-in keys (counts, timestamps) win over custom values with the same name.
+func (c *Client) metadata(builtin map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(c.CustomMetadata)+len(builtin))
+	for key, value := range c.CustomMetadata {
+		merged[key] = value
 	}
+	for key, value := range builtin {
+		merged[key] = value
+	}
+	return merged
+}
 
+// Ping sends a single payload with no summaries and metadata "ping": true, without
+// retries, to check that the endpoint is reachable and accepts requests.
+// Receivers can recognize and drop these payloads.
+func (c *Client) Ping() error {
 	payload := WebhookPayload{
 		Timestamp: time.Now(),
-		Source:    "rescuetime-linux-mutter",
-		Version:   "1.0.0",
-		Summaries: summaryList,
-		Sessions:  validSessions,
-		Metadata: map[string]interface{}{
-			"summary_count": len(summaryList),
-			"session_count": len(validSessions),
-			"submitted":     time.Now().Format(time.RFC3339),
-		},
-	}
-
-	if err := c.sendPayload(payload); err != nil {
-		color.Red("[WEBHOOK] ✗ Failed to send activities: %v\n", err)
-		return
+		Source:    version.Product,
+		Version:   version.Version,
+		Summaries: []ActivitySummary{},
+		Metadata: c.metadata(map[string]interface{}{
+			"ping": true,
+		}),
 	}
-
-	color.New(color.FgGreen, color.Bold).Printf("[SUCCESS] Sent %d summaries and %d sessions to webhook\n", len(summaryList), len(validSessions))
+	return c.send(payload, func(int, error) bool { return false })
 }
 
 // sendPayload sends the webhook payload with retry logic.
+// Failures are returned as *DeliveryError so callers can inspect the classification.
 func (c *Client) sendPayload(payload WebhookPayload) error {
-	// Marshal payload to JSON
-	jsonData, err := json.Marshal(payload)
+	return c.send(payload, c.RetryOn)
+}
+
+// send delivers the payload, retrying failed attempts that retryOn accepts (DefaultRetryOn if nil)
+func (c *Client) send(payload WebhookPayload, retryOn func(status int, err error) bool) error {
+	if c.AnonymizeApps {
+		payload = c.anonymize(payload)
+	}
+
+	// The same key goes in the header and the body so receivers can deduplicate retries
+	idempotencyKey := payload.IdempotencyKey()
+	if payload.Metadata == nil {
+		payload.Metadata = make(map[string]interface{})
+	}
+	payload.Metadata["idempotency_key"] = idempotencyKey
+	// The build that sent it, for tracing receiver-side problems to a commit
+	if version.Commit != "" {
+		payload.Metadata["commit"] = version.Commit
+	}
+
+	// Marshal payload to JSON, without HTML escaping like the RescueTime payloads
+	indent := ""
+	if c.PrettyPrint {
+		indent = "  "
+	}
+	jsonData, err := rescuetime.EncodeJSONIndent(payload, indent)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %v", err)
 	}
+	if c.Template != nil {
+		if jsonData, err = c.Template.Render(payload); err != nil {
+			return fmt.Errorf("failed to render webhook template: %v", err)
+		}
+		if c.PrettyPrint {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, jsonData, "", indent); err != nil {
+				return fmt.Errorf("failed to indent webhook template output: %v", err)
+			}
+			jsonData = indented.Bytes()
+		}
+	}
 
 	c.debugLog("Payload: %s", string(jsonData))
 
-	var lastErr error
-	retryDelay := baseRetryDelay
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+
+	var lastErr *DeliveryError
+	retryDelay := c.retryDelay
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		if attempt > 1 {
@@ -255,16 +480,21 @@ func (c *Client) sendPayload(payload WebhookPayload) error {
 			retryDelay *= 2 // Exponential backoff
 		}
 
+		// Each attempt gets its own deadline based on the timeout at the time of sending
+		ctx, cancel := context.WithTimeout(context.Background(), c.RequestTimeout())
+
 		// Create request
-		req, err := http.NewRequest("POST", c.webhookURL, bytes.NewBuffer(jsonData))
+		req, err := http.NewRequestWithContext(ctx, "POST", c.webhookURL, bytes.NewBuffer(jsonData))
 		if err != nil {
-			lastErr = fmt.Errorf("failed to create request: %v", err)
-			continue
+			cancel()
+			// A request that can't be built won't succeed on retry
+			return &DeliveryError{Class: ClassPermanent, Attempts: attempt, Err: fmt.Errorf("failed to create request: %v", err)}
 		}
 
 		// Set headers
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "rescuetime-linux-mutter/1.0.0")
+		req.Header.Set("User-Agent", version.UserAgent())
+		req.Header.Set("Idempotency-Key", idempotencyKey)
 
 		// Add custom headers if configured
 		for key, value := range c.CustomHeaders {
@@ -276,13 +506,19 @@ func (c *Client) sendPayload(payload WebhookPayload) error {
 		// Send request
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
-			continue
+			cancel()
+			lastErr = &DeliveryError{Class: ClassPermanent, Attempts: attempt, Err: err}
+			if retryOn(0, err) {
+				lastErr.Class = ClassRetryable
+				continue
+			}
+			return lastErr
 		}
 
 		// Read response body
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		cancel()
 
 		c.debugLog("Response status: %d, body: %s", resp.StatusCode, string(body))
 
@@ -292,17 +528,118 @@ func (c *Client) sendPayload(payload WebhookPayload) error {
 			return nil
 		}
 
-		// Handle different error codes
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			// Client errors - don't retry
-			return fmt.Errorf("webhook endpoint returned error %d: %s\n\nTroubleshooting:\n  1. Verify webhook URL is correct\n  2. Check authentication headers if required\n  3. Verify endpoint accepts JSON payloads", resp.StatusCode, string(body))
+		// Receivers that deduplicate deliveries may reject a replay with 409
+		if isDuplicateResponse(resp, body) {
+			c.debugLog("Endpoint reported duplicate delivery (409), treating as success")
+			return nil
 		}
 
-		// Server errors - retry
-		lastErr = fmt.Errorf("webhook endpoint returned error %d: %s", resp.StatusCode, string(body))
+		lastErr = &DeliveryError{StatusCode: resp.StatusCode, Body: string(body), Class: ClassPermanent, Attempts: attempt}
+		if retryOn(resp.StatusCode, nil) {
+			lastErr.Class = ClassRetryable
+			continue
+		}
+		return lastErr
 	}
 
-	return fmt.Errorf("failed after %d attempts: %v\n\nTroubleshooting:\n  1. Check network connectivity\n  2. Verify webhook endpoint is accessible\n  3. Check endpoint logs for errors", maxRetries, lastErr)
+	return lastErr
+}
+
+// ErrorClass describes how a failed webhook delivery was classified.
+type ErrorClass int
+
+const (
+	// ClassPermanent means retrying the same request is not expected to help (e.g. 400, 401, 404).
+	ClassPermanent ErrorClass = iota
+	// ClassRetryable means the failure looked transient and retries were exhausted.
+	ClassRetryable
+)
+
+// String returns a human-readable name for the error class.
+func (ec ErrorClass) String() string {
+	switch ec {
+	case ClassRetryable:
+		return "retryable"
+	default:
+		return "permanent"
+	}
+}
+
+// DeliveryError is returned when a webhook payload could not be delivered.
+type DeliveryError struct {
+	StatusCode int        // HTTP status of the last response, 0 if no response was received
+	Body       string     // response body of the last response
+	Class      ErrorClass // classification of the final failure
+	Attempts   int        // number of attempts made
+	Err        error      // underlying transport error, if any
+}
+
+// Error implements the error interface with troubleshooting hints for the user.
+func (e *DeliveryError) Error() string {
+	var cause string
+	if e.StatusCode != 0 {
+		cause = fmt.Sprintf("webhook endpoint returned error %d: %s", e.StatusCode, e.Body)
+	} else {
+		cause = fmt.Sprintf("request failed: %v", e.Err)
+	}
+
+	if e.Class == ClassPermanent && e.StatusCode >= 400 && e.StatusCode < 500 {
+		return fmt.Sprintf("%s\n\nTroubleshooting:\n  1. Verify webhook URL is correct\n  2. Check authentication headers if required\n  3. Verify endpoint accepts JSON payloads", cause)
+	}
+
+	return fmt.Sprintf("failed after %d attempts (%s): %s\n\nTroubleshooting:\n  1. Check network connectivity\n  2. Verify webhook endpoint is accessible\n  3. Check endpoint logs for errors", e.Attempts, e.Class, cause)
+}
+
+// Unwrap returns the underlying transport error, if any.
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultRetryOn is the retry policy used when Client.RetryOn is nil.
+// It retries 408 Request Timeout, 429 Too Many Requests, all 5xx responses,
+// and transient network errors (timeouts, connection resets/refusals, unexpected EOF).
+func DefaultRetryOn(status int, err error) bool {
+	if err != nil {
+		return isTransientNetError(err)
+	}
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 500 && status < 600:
+		return true
+	}
+	return false
+}
+
+// isTransientNetError reports whether a transport error is likely to succeed on retry.
+func isTransientNetError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// isDuplicateResponse reports whether a 409 response indicates the payload was
+// already received (a replay), which is treated as a successful delivery.
+func isDuplicateResponse(resp *http.Response, body []byte) bool {
+	if resp.StatusCode != http.StatusConflict {
+		return false
+	}
+	if strings.EqualFold(resp.Header.Get("Idempotent-Replayed"), "true") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(string(body)), duplicateMarker)
 }
 
 // validateSummary checks if a summary is valid before submission.
@@ -354,7 +691,21 @@ func (c *Client) SetHeader(key, value string) {
 	c.CustomHeaders[key] = value
 }
 
-// SetTimeout sets the HTTP request timeout.
+// SetMetadata sets a static metadata value included in every webhook payload,
+// e.g. a machine id or environment tag receivers can route on. It cannot
+// override the built-in count and timestamp keys.
+func (c *Client) SetMetadata(key, value string) {
+	c.CustomMetadata[key] = value
+}
+
+// SetTimeout sets the overall deadline for each webhook request attempt.
+// It is safe to call while submissions are in progress; the new value applies
+// to the next attempt.
 func (c *Client) SetTimeout(timeout time.Duration) {
-	c.httpClient.Timeout = timeout
+	c.requestTimeout.Store(int64(timeout))
+}
+
+// RequestTimeout returns the overall deadline applied to each webhook request attempt.
+func (c *Client) RequestTimeout() time.Duration {
+	return time.Duration(c.requestTimeout.Load())
 }
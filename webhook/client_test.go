@@ -1,9 +1,22 @@
 package webhook
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
 	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
 )
 
@@ -220,8 +233,84 @@ func TestSetTimeout(t *testing.T) {
 	client.SetTimeout(customTimeout)
 
 	// Verify timeout is set
-	if client.httpClient.Timeout != customTimeout {
-		t.Errorf("Expected timeout %v, got %v", customTimeout, client.httpClient.Timeout)
+	if client.RequestTimeout() != customTimeout {
+		t.Errorf("Expected timeout %v, got %v", customTimeout, client.RequestTimeout())
+	}
+
+	// The overall deadline must not be stored on the shared http.Client
+	if client.httpClient.Timeout != 0 {
+		t.Errorf("Expected http.Client.Timeout to be unset, got %v", client.httpClient.Timeout)
+	}
+}
+
+// TestNewClientWithTransport tests custom transport timeouts and defaults for zero values
+func TestNewClientWithTransport(t *testing.T) {
+	client, err := NewClientWithTransport("https://example.com/webhook", TransportOptions{
+		ResponseHeaderTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", client.httpClient.Transport)
+	}
+	if transport.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("Expected response header timeout 5s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.TLSHandshakeTimeout != defaultTLSHandshakeTimeout {
+		t.Errorf("Expected default TLS handshake timeout %v, got %v", defaultTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	}
+	if client.RequestTimeout() != defaultRequestTimeout {
+		t.Errorf("Expected default request timeout %v, got %v", defaultRequestTimeout, client.RequestTimeout())
+	}
+}
+
+// TestConcurrentSubmitWithSetTimeout changes the timeout while submissions are in flight.
+// Run with -race to verify the timeout is not shared mutable state.
+func TestConcurrentSubmitWithSetTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	now := time.Now()
+	summary := rescuetime.ActivitySummary{
+		AppClass:      "Firefox",
+		TotalDuration: 15 * time.Minute,
+		SessionCount:  1,
+		FirstSeen:     now.Add(-15 * time.Minute),
+		LastSeen:      now,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- client.SubmitSummary(summary)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			client.SetTimeout(time.Duration(10+i) * time.Second)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Unexpected submission error: %v", err)
+		}
 	}
 }
 
@@ -244,3 +333,432 @@ func TestDebugMode(t *testing.T) {
 		t.Error("Failed to enable debug mode")
 	}
 }
+
+// TestRetryClassification enumerates status codes against a test server and checks
+// how many attempts are made and how the final failure is classified
+func TestRetryClassification(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		header       map[string]string
+		wantErr      bool
+		wantAttempts int
+		wantClass    ErrorClass
+	}{
+		{name: "200 OK", status: http.StatusOK, wantAttempts: 1},
+		{name: "204 No Content", status: http.StatusNoContent, wantAttempts: 1},
+		{name: "400 Bad Request", status: http.StatusBadRequest, wantErr: true, wantAttempts: 1, wantClass: ClassPermanent},
+		{name: "401 Unauthorized", status: http.StatusUnauthorized, wantErr: true, wantAttempts: 1, wantClass: ClassPermanent},
+		{name: "404 Not Found", status: http.StatusNotFound, wantErr: true, wantAttempts: 1, wantClass: ClassPermanent},
+		{name: "408 Request Timeout", status: http.StatusRequestTimeout, wantErr: true, wantAttempts: maxRetries, wantClass: ClassRetryable},
+		{name: "409 without marker", status: http.StatusConflict, body: "conflict", wantErr: true, wantAttempts: 1, wantClass: ClassPermanent},
+		{name: "409 duplicate body", status: http.StatusConflict, body: `{"error":"Duplicate delivery"}`, wantAttempts: 1},
+		{name: "409 replay header", status: http.StatusConflict, header: map[string]string{"Idempotent-Replayed": "true"}, wantAttempts: 1},
+		{name: "429 Too Many Requests", status: http.StatusTooManyRequests, wantErr: true, wantAttempts: maxRetries, wantClass: ClassRetryable},
+		{name: "500 Internal Server Error", status: http.StatusInternalServerError, wantErr: true, wantAttempts: maxRetries, wantClass: ClassRetryable},
+		{name: "503 Service Unavailable", status: http.StatusServiceUnavailable, wantErr: true, wantAttempts: maxRetries, wantClass: ClassRetryable},
+	}
+
+	now := time.Now()
+	summary := rescuetime.ActivitySummary{
+		AppClass:      "Firefox",
+		TotalDuration: 15 * time.Minute,
+		SessionCount:  1,
+		FirstSeen:     now.Add(-15 * time.Minute),
+		LastSeen:      now,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			client.retryDelay = time.Millisecond
+
+			err = client.SubmitSummary(summary)
+			if int(hits.Load()) != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, hits.Load())
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("Expected *DeliveryError, got %T: %v", err, err)
+			}
+			if deliveryErr.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, deliveryErr.StatusCode)
+			}
+			if deliveryErr.Class != tt.wantClass {
+				t.Errorf("Expected class %v, got %v", tt.wantClass, deliveryErr.Class)
+			}
+			if deliveryErr.Attempts != tt.wantAttempts {
+				t.Errorf("Expected error to report %d attempts, got %d", tt.wantAttempts, deliveryErr.Attempts)
+			}
+		})
+	}
+}
+
+// TestRetryOnOverride verifies the user-supplied retry policy replaces the default
+func TestRetryOnOverride(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.retryDelay = time.Millisecond
+	client.RetryOn = func(status int, err error) bool { return false }
+
+	now := time.Now()
+	err = client.SubmitSummary(rescuetime.ActivitySummary{
+		AppClass:      "Firefox",
+		TotalDuration: 15 * time.Minute,
+		SessionCount:  1,
+		FirstSeen:     now.Add(-15 * time.Minute),
+		LastSeen:      now,
+	})
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Expected *DeliveryError, got %T: %v", err, err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected 1 attempt with retries disabled, got %d", hits.Load())
+	}
+	if deliveryErr.Class != ClassPermanent {
+		t.Errorf("Expected permanent class, got %v", deliveryErr.Class)
+	}
+}
+
+// TestDefaultRetryOnNetErrors tests classification of transport errors
+func TestDefaultRetryOnNetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: true},
+		{name: "other error", err: errors.New("x509: certificate signed by unknown authority"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultRetryOn(0, tt.err); got != tt.want {
+				t.Errorf("DefaultRetryOn(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIdempotencyKey verifies retries reuse the same key and different payloads differ
+func TestIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	var bodyKeys []string
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodyKey, _ := payload.Metadata["idempotency_key"].(string)
+		bodyKeys = append(bodyKeys, bodyKey)
+		mu.Unlock()
+		// Fail the first attempt so the payload is retried
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.retryDelay = time.Millisecond
+
+	now := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	firefox := rescuetime.ActivitySummary{AppClass: "Firefox", TotalDuration: 15 * time.Minute, SessionCount: 1, FirstSeen: now, LastSeen: now.Add(15 * time.Minute)}
+	code := rescuetime.ActivitySummary{AppClass: "Code", TotalDuration: 10 * time.Minute, SessionCount: 1, FirstSeen: now, LastSeen: now.Add(10 * time.Minute)}
+
+	if err := client.SubmitSummary(firefox); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected same non-empty key across retries, got %q and %q", keys[0], keys[1])
+	}
+	if bodyKeys[0] != keys[0] {
+		t.Errorf("Expected metadata key %q to match header key %q", bodyKeys[0], keys[0])
+	}
+
+	// Key depends only on content, not on submission time or order
+	a := WebhookPayload{Timestamp: now, Summaries: []ActivitySummary{firefox, code}}
+	b := WebhookPayload{Timestamp: now.Add(time.Hour), Summaries: []ActivitySummary{code, firefox}}
+	if a.IdempotencyKey() != b.IdempotencyKey() {
+		t.Error("Expected same key for same content submitted at different times")
+	}
+
+	c := WebhookPayload{Summaries: []ActivitySummary{firefox}}
+	if a.IdempotencyKey() == c.IdempotencyKey() {
+		t.Error("Expected different keys for different payloads")
+	}
+}
+
+// TestHTMLCharactersNotEscaped tests that &, < and > in titles reach the endpoint as written,
+// encoded the same way as the RescueTime legacy and native bodies
+func TestHTMLCharactersNotEscaped(t *testing.T) {
+	const title = "R&D <planning> -> Q3"
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	now := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summary := rescuetime.ActivitySummary{AppClass: "Firefox", ActivityDetails: title, TotalDuration: 15 * time.Minute, SessionCount: 1, FirstSeen: now, LastSeen: now.Add(15 * time.Minute)}
+	session := ActivitySession{StartTime: now, EndTime: now.Add(15 * time.Minute), AppClass: "Firefox", WindowTitle: title, Duration: 15 * time.Minute}
+	if err := client.SubmitActivitiesWithSessions(map[string]ActivitySummary{"Firefox": summary}, []ActivitySession{session}); err != nil {
+		t.Fatalf("SubmitActivitiesWithSessions failed: %v", err)
+	}
+
+	if count := bytes.Count(body, []byte(title)); count != 2 {
+		t.Errorf("Expected the summary and session titles verbatim, found %d in %s", count, body)
+	}
+	for _, escaped := range []string{`\u0026`, `\u003c`, `\u003e`} {
+		if bytes.Contains(body, []byte(escaped)) {
+			t.Errorf("Body contains HTML escape %s: %s", escaped, body)
+		}
+	}
+}
+
+// TestSubmitActivitiesWithSessionsReportsFailure tests that an undelivered payload is reported
+// to the caller, so it can be sent again
+func TestSubmitActivitiesWithSessionsReportsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	now := time.Date(2025, 10, 31, 10, 0, 0, 0, time.UTC)
+	summary := rescuetime.ActivitySummary{AppClass: "Firefox", TotalDuration: 15 * time.Minute, SessionCount: 1, FirstSeen: now, LastSeen: now.Add(15 * time.Minute)}
+	if err := client.SubmitActivitiesWithSessions(map[string]ActivitySummary{"Firefox": summary}, nil); err == nil {
+		t.Error("Expected an error for a payload the endpoint refused")
+	}
+}
+
+// TestPrettyPrint tests that PrettyPrint indents the body, templated ones too, and that it
+// decodes to exactly what the compact body does
+func TestPrettyPrint(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	send := func(pretty bool) (raw []byte, decoded interface{}) {
+		client.PrettyPrint = pretty
+		if err := client.sendPayload(fixturePayload()); err != nil {
+			t.Fatalf("sendPayload failed: %v", err)
+		}
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			t.Fatalf("Expected valid JSON, got %s: %v", body, err)
+		}
+		return body, decoded
+	}
+
+	compact, compactDecoded := send(false)
+	pretty, prettyDecoded := send(true)
+	if bytes.Contains(compact, []byte("\n")) {
+		t.Errorf("Expected a compact body by default, got %s", compact)
+	}
+	if !bytes.Contains(pretty, []byte("\n  \"summaries\": [\n    {")) {
+		t.Errorf("Expected an indented body, got %s", pretty)
+	}
+	if !reflect.DeepEqual(compactDecoded, prettyDecoded) {
+		t.Errorf("Expected the pretty body to decode like the compact one:\n%s\n%s", compact, pretty)
+	}
+
+	client.Template = loadExample(t, "webhook-flat.example.tmpl")
+	_, templated := send(false)
+	pretty, prettyTemplated := send(true)
+	if !bytes.Contains(pretty, []byte("[\n  {\n    \"")) || !reflect.DeepEqual(templated, prettyTemplated) {
+		t.Errorf("Expected the templated body indented and otherwise the same, got %s", pretty)
+	}
+}
+
+// TestSetMetadata tests that custom metadata is sent alongside the built-in keys
+func TestSetMetadata(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received = append(received, payload.Metadata)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.CustomMetadata["hostname"] == "" {
+		t.Error("Expected hostname metadata to be set automatically")
+	}
+
+	client.SetMetadata("environment", "work")
+	client.SetMetadata("count", "overridden") // must not replace the built-in count
+
+	now := time.Now()
+	summary := rescuetime.ActivitySummary{AppClass: "Firefox", TotalDuration: 15 * time.Minute, SessionCount: 1, FirstSeen: now.Add(-15 * time.Minute), LastSeen: now}
+	client.SubmitActivities(map[string]ActivitySummary{"Firefox": summary})
+	client.SubmitActivitiesWithSessions(map[string]ActivitySummary{"Firefox": summary}, nil)
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 payloads, got %d", len(received))
+	}
+	for i, metadata := range received {
+		if metadata["environment"] != "work" {
+			t.Errorf("Payload %d: expected environment=work, got %v", i, metadata["environment"])
+		}
+		if metadata["hostname"] != client.CustomMetadata["hostname"] {
+			t.Errorf("Payload %d: expected hostname %q, got %v", i, client.CustomMetadata["hostname"], metadata["hostname"])
+		}
+		if metadata["submitted"] == nil || metadata["idempotency_key"] == nil {
+			t.Errorf("Payload %d: missing built-in keys: %v", i, metadata)
+		}
+	}
+	if received[0]["count"] != float64(1) {
+		t.Errorf("Expected built-in count 1 to win over custom value, got %v", received[0]["count"])
+	}
+	if received[1]["summary_count"] != float64(1) || received[1]["session_count"] != float64(0) {
+		t.Errorf("Expected built-in summary/session counts, got %v", received[1])
+	}
+}
+
+// TestPing tests that Ping sends one marked payload and doesn't retry failures
+func TestPing(t *testing.T) {
+	var hits atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	var mu sync.Mutex
+	var received WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		mu.Lock()
+		json.NewDecoder(r.Body).Decode(&received)
+		mu.Unlock()
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.retryDelay = time.Millisecond
+
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if received.Metadata["ping"] != true || len(received.Summaries) != 0 {
+		t.Errorf("Expected an empty payload marked as ping, got %+v", received)
+	}
+
+	hits.Store(0)
+	status.Store(http.StatusServiceUnavailable)
+	if err := client.Ping(); err == nil {
+		t.Error("Expected Ping to fail on 503")
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected Ping not to retry, got %d attempts", hits.Load())
+	}
+}
+
+// TestPayloadVersion tests that payloads and the User-Agent carry the build's version, and
+// the metadata its commit
+func TestPayloadVersion(t *testing.T) {
+	oldVersion, oldCommit := version.Version, version.Commit
+	version.Version, version.Commit = "v9.9.9", "abc1234"
+	defer func() { version.Version, version.Commit = oldVersion, oldCommit }()
+
+	var body []byte
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		agent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if payload.Version != "v9.9.9" || payload.Source != version.Product {
+		t.Errorf("Expected version v9.9.9 from %s, got %q from %q", version.Product, payload.Version, payload.Source)
+	}
+	if payload.Metadata["commit"] != "abc1234" {
+		t.Errorf("Expected the commit in metadata, got %v", payload.Metadata)
+	}
+	if agent != "rescuetime-linux-mutter/v9.9.9" {
+		t.Errorf("Expected the versioned User-Agent, got %q", agent)
+	}
+}
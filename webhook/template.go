@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/internal/version"
+	"github.com/Christopher-Hayes/rescuetime-linux-mutter/rescuetime"
+)
+
+// PayloadTemplate reshapes the canonical WebhookPayload into the JSON a destination
+// expects (a flat array for n8n, a Slack message, ...) before it is sent. It is a Go
+// text/template executed with the WebhookPayload as its data, plus templateFuncs.
+type PayloadTemplate struct {
+	tmpl *template.Template
+}
+
+// templateFuncs are the functions available to payload templates
+var templateFuncs = template.FuncMap{
+	// seconds, minutes and hours convert a time.Duration to a float64
+	"seconds": func(d time.Duration) float64 { return d.Seconds() },
+	"minutes": func(d time.Duration) float64 { return d.Minutes() },
+	"hours":   func(d time.Duration) float64 { return d.Hours() },
+	// round rounds x to the given number of decimal places
+	"round": func(x float64, places int) float64 {
+		scale := math.Pow(10, float64(places))
+		return math.Round(x*scale) / scale
+	},
+	// duration formats a time.Duration for people, e.g. "1h 5m" or "45s"
+	"duration": formatDuration,
+	// json encodes any value as JSON, e.g. a quoted and escaped string (without HTML
+	// escaping, like the default payload)
+	"json": func(v interface{}) (string, error) {
+		data, err := rescuetime.EncodeJSON(v)
+		return string(data), err
+	},
+	// jsonEscape escapes a string for use inside a JSON string literal, without the quotes
+	"jsonEscape": func(s string) (string, error) {
+		data, err := rescuetime.EncodeJSON(s)
+		if err != nil {
+			return "", err
+		}
+		return string(data[1 : len(data)-1]), nil
+	},
+	// byDuration returns the summaries sorted by total duration, longest first
+	"byDuration": func(summaries []ActivitySummary) []ActivitySummary {
+		sorted := append([]ActivitySummary(nil), summaries...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].TotalDuration != sorted[j].TotalDuration {
+				return sorted[i].TotalDuration > sorted[j].TotalDuration
+			}
+			return sorted[i].AppClass < sorted[j].AppClass
+		})
+		return sorted
+	},
+}
+
+// locationFuncs are the template functions that work in a time zone, loc (see SetLocation)
+func locationFuncs(loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		// date formats a time as its calendar date, YYYY-MM-DD
+		"date": func(t time.Time) string { return t.In(loc).Format("2006-01-02") },
+		// truncate returns the start of the hour, day or week (Monday) containing t
+		"truncate": func(unit string, t time.Time) (time.Time, error) { return truncateTime(unit, t, loc) },
+		// formatTime formats t with a Go layout, e.g. formatTime "15:04" .LastSeen
+		"formatTime": func(layout string, t time.Time) string { return t.In(loc).Format(layout) },
+	}
+}
+
+// LoadTemplate reads and checks a payload template file, see ParseTemplate
+func LoadTemplate(path string) (*PayloadTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %v", err)
+	}
+	return ParseTemplate(path, string(data))
+}
+
+// ParseTemplate parses a payload template and renders it once against a sample payload, so
+// syntax errors, unknown fields or functions and output that isn't JSON are reported when
+// the template is loaded rather than at the first submission.
+func ParseTemplate(name, text string) (*PayloadTemplate, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Funcs(locationFuncs(time.Local)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %v", err)
+	}
+	t := &PayloadTemplate{tmpl: tmpl}
+	if _, err := t.Render(samplePayload()); err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %v", err)
+	}
+	return t, nil
+}
+
+// SetLocation sets the time zone the date, truncate and formatTime functions work in
+// (-timezone); templates start in time.Local
+func (t *PayloadTemplate) SetLocation(loc *time.Location) {
+	t.tmpl.Funcs(locationFuncs(loc))
+}
+
+// Render executes the template against payload and returns the body to send, which must be
+// valid JSON
+func (t *PayloadTemplate) Render(payload WebhookPayload) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("%s: output is not valid JSON:\n%s", t.tmpl.Name(), buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// samplePayload is the payload ParseTemplate checks templates against, with a summary and a
+// session so ranges over both are executed
+func samplePayload() WebhookPayload {
+	end := time.Date(2025, 10, 31, 14, 30, 0, 0, time.Local)
+	start := end.Add(-15 * time.Minute)
+	return WebhookPayload{
+		Timestamp: end,
+		Source:    version.Product,
+		Version:   version.Version,
+		Summaries: []ActivitySummary{{
+			AppClass:            "Firefox",
+			ActivityDetails:     "GitHub - \"Projects\"",
+			TotalDuration:       15 * time.Minute,
+			SessionCount:        1,
+			FirstSeen:           start,
+			LastSeen:            end,
+			MaxSessionDuration:  15 * time.Minute,
+			AvgSessionDuration:  15 * time.Minute,
+			LongestSessionStart: start,
+		}},
+		Sessions: []ActivitySession{{
+			StartTime:     start,
+			EndTime:       end,
+			AppClass:      "Firefox",
+			WindowTitle:   "GitHub - \"Projects\"",
+			Duration:      15 * time.Minute,
+			ActiveSeconds: 900,
+		}},
+		Metadata: map[string]interface{}{"hostname": "sample", "idempotency_key": "sample"},
+	}
+}
+
+// formatDuration formats d as hours and minutes, or seconds under a minute
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// truncateTime returns the start of the "hour", "day" or "week" (from Monday) containing t in loc
+func truncateTime(unit string, t time.Time, loc *time.Location) (time.Time, error) {
+	t = t.In(loc)
+	switch strings.ToLower(unit) {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, fmt.Errorf("truncate: unknown unit %q (expected hour, day or week)", unit)
+}
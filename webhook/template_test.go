@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixturePayload is a two-application payload like SubmitActivitiesWithSessions sends
+func fixturePayload() WebhookPayload {
+	start := time.Date(2025, 10, 31, 9, 0, 0, 0, time.Local)
+	return WebhookPayload{
+		Timestamp: start.Add(90 * time.Minute),
+		Source:    "rescuetime-linux-mutter",
+		Version:   "1.0.0",
+		Summaries: []ActivitySummary{
+			{AppClass: "slack", TotalDuration: 12*time.Minute + 30*time.Second, SessionCount: 3, FirstSeen: start.Add(time.Hour), LastSeen: start.Add(90 * time.Minute)},
+			{AppClass: "R&D \"tools\"", TotalDuration: 65 * time.Minute, SessionCount: 1, FirstSeen: start, LastSeen: start.Add(65 * time.Minute)},
+		},
+		Metadata: map[string]interface{}{"hostname": "laptop", "idempotency_key": "key"},
+	}
+}
+
+// loadExample loads one of the example templates shipped in the repository root
+func loadExample(t *testing.T, name string) *PayloadTemplate {
+	t.Helper()
+	tmpl, err := LoadTemplate(filepath.Join("..", name))
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", name, err)
+	}
+	return tmpl
+}
+
+// TestFlatArrayTemplate tests that the n8n example renders one {app, minutes, date} object
+// per summary
+func TestFlatArrayTemplate(t *testing.T) {
+	body, err := loadExample(t, "webhook-flat.example.tmpl").Render(fixturePayload())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var rows []struct {
+		App     string  `json:"app"`
+		Minutes float64 `json:"minutes"`
+		Date    string  `json:"date"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		t.Fatalf("Expected a JSON array, got %s: %v", body, err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %s", body)
+	}
+	if rows[0].App != "slack" || rows[0].Minutes != 12.5 || rows[0].Date != "2025-10-31" {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+	if rows[1].App != "R&D \"tools\"" || rows[1].Minutes != 65 {
+		t.Errorf("Unexpected second row: %+v", rows[1])
+	}
+
+	// No summaries is an empty array, not a syntax error
+	empty := fixturePayload()
+	empty.Summaries = nil
+	if body, err := loadExample(t, "webhook-flat.example.tmpl").Render(empty); err != nil || !json.Valid(body) {
+		t.Errorf("Expected an empty array, got %s (%v)", body, err)
+	}
+}
+
+// TestSlackTemplate tests that the Slack example renders a message listing the applications
+// longest first
+func TestSlackTemplate(t *testing.T) {
+	body, err := loadExample(t, "webhook-slack.example.tmpl").Render(fixturePayload())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var message struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Expected a JSON object, got %s: %v", body, err)
+	}
+	want := "*Activity on laptop, Fri 10:30*\n• R&D \"tools\": 1h 5m\n• slack: 12m"
+	if message.Text != want {
+		t.Errorf("Expected text %q, got %q", want, message.Text)
+	}
+}
+
+// TestParseTemplateFailsFast tests that templates that can't render valid JSON are
+// rejected when parsed
+func TestParseTemplateFailsFast(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{"syntax", `[{{range .Summaries}}{}]`, "unexpected EOF"},
+		{"unknown field", `[{{range .Summaries}}{{json .App}}{{end}}]`, "can't evaluate field App"},
+		{"unknown function", `{{minutez 1}}`, "not defined"},
+		{"bad unit", `{{json (truncate "month" .Timestamp)}}`, "unknown unit"},
+		{"not JSON", `{{range .Summaries}}{{.AppClass}}{{end}}`, "not valid JSON"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTemplate(tt.name, tt.template); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	if _, err := ParseTemplate("ok", `{"week": {{json (truncate "week" .Timestamp)}}}`); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// TestTemplateLocation tests that SetLocation moves the date functions to another time zone
+func TestTemplateLocation(t *testing.T) {
+	tmpl, err := ParseTemplate("dates", `{"date": {{json (date .Timestamp)}}, "day": {{json (truncate "day" .Timestamp)}}, "time": {{json (formatTime "15:04" .Timestamp)}}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	tmpl.SetLocation(time.FixedZone("NZDT", 13*60*60))
+
+	payload := fixturePayload()
+	payload.Timestamp = time.Date(2025, 10, 31, 12, 30, 0, 0, time.UTC) // 01:30 on Nov 1st in Auckland
+	body, err := tmpl.Render(payload)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := `{"date": "2025-11-01", "day": "2025-11-01T00:00:00+13:00", "time": "01:30"}`
+	if string(body) != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+}
+
+// TestClientSendsTemplatedBody tests that the client posts the rendered body instead of the
+// canonical payload
+func TestClientSendsTemplatedBody(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.Template = loadExample(t, "webhook-flat.example.tmpl")
+
+	summaries := fixturePayload().Summaries
+	client.SubmitActivities(map[string]ActivitySummary{summaries[0].AppClass: summaries[0]})
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) != 1 || rows[0]["app"] != "slack" {
+		t.Errorf("Expected the flat array for slack, got %s (%v)", body, err)
+	}
+}